@@ -0,0 +1,22 @@
+package firewall
+
+import "fmt"
+
+// Block drops the forwarded packets of a protocol directed to a port.
+type Block struct {
+	Interface string
+	Protocol  string
+	DstPort   int
+}
+
+func NewBlock(iface string, proto string, port int) *Block {
+	return &Block{
+		Interface: iface,
+		Protocol:  proto,
+		DstPort:   port,
+	}
+}
+
+func (b Block) String() string {
+	return fmt.Sprintf("[%s] (%s) drop *:%d", b.Interface, b.Protocol, b.DstPort)
+}
@@ -4,5 +4,6 @@ type FirewallManager interface {
 	IsForwardingEnabled() bool
 	EnableForwarding(enabled bool) error
 	EnableRedirection(r *Redirection, enabled bool) error
+	EnableBlock(b *Block, enabled bool) error
 	Restore()
 }
@@ -123,50 +123,87 @@ func (f *PfFirewall) enable(enabled bool) {
 	}
 }
 
-func (f PfFirewall) EnableRedirection(r *Redirection, enabled bool) error {
-	rule := f.generateRule(r)
+func (f PfFirewall) readRules() []string {
+	rules := []string{}
+	fd, err := os.Open(f.filename)
+	if err != nil {
+		return rules
+	}
+	defer fd.Close()
 
-	if enabled {
-		fd, err := os.OpenFile(f.filename, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
-		if err != nil {
-			return err
+	scanner := bufio.NewScanner(fd)
+	for scanner.Scan() {
+		if line := str.Trim(scanner.Text()); line != "" {
+			rules = append(rules, line)
 		}
-		defer fd.Close()
+	}
+	return rules
+}
 
-		if _, err = fd.WriteString(rule + "\n"); err != nil {
-			return err
+func (f *PfFirewall) addRule(rule string) error {
+	// pf wants the translation rules before the filtering ones
+	translation, filtering := []string{}, []string{}
+	for _, line := range append(f.readRules(), rule) {
+		if strings.HasPrefix(line, "rdr ") {
+			translation = append(translation, line)
+		} else {
+			filtering = append(filtering, line)
 		}
+	}
+
+	lines := strings.Join(append(translation, filtering...), "\n") + "\n"
+	if err := ioutil.WriteFile(f.filename, []byte(lines), 0600); err != nil {
+		return err
+	}
+
+	// enable pf
+	f.enable(true)
 
-		// enable pf
-		f.enable(true)
+	// load the rules
+	if _, err := core.Exec("pfctl", []string{"-f", f.filename}); err != nil {
+		return err
+	}
 
-		// load the rule
-		if _, err := core.Exec("pfctl", []string{"-f", f.filename}); err != nil {
-			return err
+	return nil
+}
+
+func (f *PfFirewall) delRule(rule string) {
+	lines := ""
+	for _, line := range f.readRules() {
+		if line != rule {
+			lines += line + "\n"
 		}
+	}
+
+	if str.Trim(lines) == "" {
+		os.Remove(f.filename)
+		f.enable(false)
 	} else {
-		fd, err := os.Open(f.filename)
-		if err == nil {
-			defer fd.Close()
-
-			lines := ""
-			scanner := bufio.NewScanner(fd)
-			for scanner.Scan() {
-				line := str.Trim(scanner.Text())
-				if line != rule {
-					lines += line + "\n"
-				}
-			}
-
-			if str.Trim(lines) == "" {
-				os.Remove(f.filename)
-				f.enable(false)
-			} else {
-				ioutil.WriteFile(f.filename, []byte(lines), 0600)
-			}
-		}
+		ioutil.WriteFile(f.filename, []byte(lines), 0600)
+		core.Exec("pfctl", []string{"-f", f.filename})
+	}
+}
+
+func (f PfFirewall) EnableRedirection(r *Redirection, enabled bool) error {
+	rule := f.generateRule(r)
+
+	if enabled {
+		return f.addRule(rule)
+	}
+
+	f.delRule(rule)
+	return nil
+}
+
+func (f PfFirewall) EnableBlock(b *Block, enabled bool) error {
+	rule := fmt.Sprintf("block drop quick on %s proto %s from any to any port %d",
+		b.Interface, b.Protocol, b.DstPort)
+
+	if enabled {
+		return f.addRule(rule)
 	}
 
+	f.delRule(rule)
 	return nil
 }
 
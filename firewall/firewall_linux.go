@@ -17,6 +17,7 @@ type LinuxFirewall struct {
 	iface        *network.Endpoint
 	forwarding   bool
 	redirections map[string]*Redirection
+	blocks       map[string]*Block
 }
 
 const (
@@ -29,6 +30,7 @@ func Make(iface *network.Endpoint) FirewallManager {
 		iface:        iface,
 		forwarding:   false,
 		redirections: make(map[string]*Redirection),
+		blocks:       make(map[string]*Block),
 	}
 
 	firewall.forwarding = firewall.IsForwardingEnabled()
@@ -155,6 +157,42 @@ func (f *LinuxFirewall) EnableRedirection(r *Redirection, enabled bool) error {
 	return nil
 }
 
+func (f *LinuxFirewall) EnableBlock(b *Block, enabled bool) error {
+	action := "-I"
+	bkey := b.String()
+	_, found := f.blocks[bkey]
+
+	if enabled {
+		if found {
+			return fmt.Errorf("Block '%s' already enabled.", bkey)
+		}
+		f.blocks[bkey] = b
+	} else {
+		if !found {
+			return nil
+		}
+		action = "-D"
+		delete(f.blocks, bkey)
+	}
+
+	cmdLine := []string{
+		action, "FORWARD",
+		"-i", b.Interface,
+		"-p", b.Protocol,
+		"--dport", fmt.Sprintf("%d", b.DstPort),
+		"-j", "DROP",
+	}
+
+	if _, err := core.Exec("iptables", cmdLine); err != nil {
+		return err
+	} else if fs.Exists(IPV6ForwardingFile) {
+		// IPv6 filtering is best effort
+		core.Exec("ip6tables", cmdLine)
+	}
+
+	return nil
+}
+
 func (f LinuxFirewall) Restore() {
 	for _, r := range f.redirections {
 		if err := f.EnableRedirection(r, false); err != nil {
@@ -162,6 +200,12 @@ func (f LinuxFirewall) Restore() {
 		}
 	}
 
+	for _, b := range f.blocks {
+		if err := f.EnableBlock(b, false); err != nil {
+			fmt.Printf("%s", err)
+		}
+	}
+
 	if err := f.EnableForwarding(f.forwarding); err != nil {
 		fmt.Printf("%s", err)
 	}
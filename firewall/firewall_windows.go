@@ -106,6 +106,25 @@ func (f *WindowsFirewall) EnableRedirection(r *Redirection, enabled bool) error
 	return nil
 }
 
+func (f *WindowsFirewall) EnableBlock(b *Block, enabled bool) error {
+	nameField := fmt.Sprintf(`name="bettercap-block-%s-%d"`, b.Protocol, b.DstPort)
+	protoField := fmt.Sprintf("protocol=%s", b.Protocol)
+	portField := fmt.Sprintf("remoteport=%d", b.DstPort)
+
+	cmd := []string{}
+	if enabled {
+		cmd = []string{"advfirewall", "firewall", "add", "rule", nameField, protoField, "dir=out", portField, "action=block"}
+	} else {
+		cmd = []string{"advfirewall", "firewall", "delete", "rule", nameField, protoField, portField}
+	}
+
+	if _, err := core.Exec("netsh", cmd); err != nil {
+		return err
+	}
+
+	return nil
+}
+
 func (f WindowsFirewall) Restore() {
 	for _, r := range f.redirections {
 		if err := f.EnableRedirection(r, false); err != nil {
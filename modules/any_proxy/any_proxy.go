@@ -15,6 +15,7 @@ type AnyProxy struct {
 	// not using map[int]*firewall.Redirection to preserve order
 	ports        []int
 	redirections []*firewall.Redirection
+	block        *firewall.Block
 }
 
 func NewAnyProxy(s *session.Session) *AnyProxy {
@@ -52,6 +53,10 @@ func NewAnyProxy(s *session.Session) *AnyProxy {
 		"8080",
 		"Port where the proxy is listening."))
 
+	mod.AddParam(session.NewBoolParameter("any.proxy.block_quic",
+		"false",
+		"If true, drop the forwarded UDP traffic to port 443 so that clients fall back from QUIC to TLS over TCP."))
+
 	mod.AddHandler(session.NewModuleHandler("any.proxy on", "",
 		"Start the custom proxy redirection.",
 		func(args []string) error {
@@ -87,6 +92,7 @@ func (mod *AnyProxy) Configure() error {
 	var protocol string
 	var srcAddress string
 	var dstAddress string
+	var blockQUIC bool
 
 	if mod.Running() {
 		return session.ErrAlreadyStarted(mod.Name())
@@ -100,6 +106,8 @@ func (mod *AnyProxy) Configure() error {
 		return err
 	} else if err, dstAddress = mod.StringParam("any.proxy.dst_address"); err != nil {
 		return err
+	} else if err, blockQUIC = mod.BoolParam("any.proxy.block_quic"); err != nil {
+		return err
 	}
 
 	if err, srcPorts = mod.StringParam("any.proxy.src_port"); err != nil {
@@ -167,6 +175,15 @@ func (mod *AnyProxy) Configure() error {
 		mod.Info("applied redirection %s", redir.String())
 	}
 
+	mod.block = nil
+	if blockQUIC {
+		mod.block = firewall.NewBlock(iface, "udp", 443)
+		if err := mod.Session.Firewall.EnableBlock(mod.block, true); err != nil {
+			return err
+		}
+		mod.Info("applied block %s", mod.block.String())
+	}
+
 	return nil
 }
 
@@ -185,5 +202,11 @@ func (mod *AnyProxy) Stop() error {
 			return err
 		}
 	}
+	if mod.block != nil {
+		mod.Info("disabling block %s", mod.block.String())
+		if err := mod.Session.Firewall.EnableBlock(mod.block, false); err != nil {
+			return err
+		}
+	}
 	return mod.SetRunning(false, func() {})
 }
@@ -154,10 +154,25 @@ func (mod *RestAPI) showHID(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+func (mod *RestAPI) showCredentials(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Get("format") == "csv" {
+		w.Header().Set("Content-Type", "text/csv")
+		if err := mod.Session.Credentials.WriteCSV(w); err != nil {
+			mod.Debug("error while encoding credentials to CSV: %v", err)
+		}
+		return
+	}
+	mod.toJSON(w, mod.Session.Credentials)
+}
+
 func (mod *RestAPI) showEnv(w http.ResponseWriter, r *http.Request) {
 	mod.toJSON(w, mod.Session.Env)
 }
 
+func (mod *RestAPI) showFlows(w http.ResponseWriter, r *http.Request) {
+	mod.toJSON(w, mod.Session.Queue.Flows)
+}
+
 func (mod *RestAPI) showGateway(w http.ResponseWriter, r *http.Request) {
 	mod.toJSON(w, mod.Session.Gateway)
 }
@@ -325,9 +340,15 @@ func (mod *RestAPI) sessionRoute(w http.ResponseWriter, r *http.Request) {
 	case path == "/api/session":
 		mod.showSession(w, r)
 
+	case path == "/api/session/credentials":
+		mod.showCredentials(w, r)
+
 	case path == "/api/session/env":
 		mod.showEnv(w, r)
 
+	case path == "/api/session/flows":
+		mod.showFlows(w, r)
+
 	case path == "/api/session/gateway":
 		mod.showGateway(w, r)
 
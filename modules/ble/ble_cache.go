@@ -0,0 +1,183 @@
+//go:build !windows
+// +build !windows
+
+package ble
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/bettercap/bettercap/network"
+
+	"github.com/bettercap/gatt"
+
+	"github.com/evilsocket/islazy/fs"
+)
+
+type gattCachedCharacteristic struct {
+	UUID        string `json:"uuid"`
+	Properties  uint8  `json:"properties"`
+	Handle      uint16 `json:"handle"`
+	ValueHandle uint16 `json:"value_handle"`
+	EndHandle   uint16 `json:"end_handle"`
+	CCCDHandle  uint16 `json:"cccd_handle"`
+}
+
+type gattCachedService struct {
+	UUID            string                     `json:"uuid"`
+	Handle          uint16                     `json:"handle"`
+	EndHandle       uint16                     `json:"end_handle"`
+	Characteristics []gattCachedCharacteristic `json:"characteristics"`
+}
+
+type gattCachedDevice struct {
+	Updated  time.Time           `json:"updated"`
+	Services []gattCachedService `json:"services"`
+}
+
+// gattCache persists the attribute handles of enumerated devices so that
+// characteristics can be accessed without a new discovery.
+type gattCache struct {
+	sync.Mutex
+	fileName string
+	devices  map[string]*gattCachedDevice
+}
+
+func loadGATTCache(fileName string) (*gattCache, error) {
+	fileName, err := fs.Expand(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	cache := &gattCache{
+		fileName: fileName,
+		devices:  make(map[string]*gattCachedDevice),
+	}
+
+	if fs.Exists(fileName) {
+		if raw, err := ioutil.ReadFile(fileName); err != nil {
+			return nil, err
+		} else if err = json.Unmarshal(raw, &cache.devices); err != nil {
+			return nil, fmt.Errorf("error parsing %s: %v", fileName, err)
+		}
+	}
+
+	return cache, nil
+}
+
+func (c *gattCache) save() error {
+	raw, err := json.MarshalIndent(c.devices, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(c.fileName, raw, 0644)
+}
+
+func (c *gattCache) Has(mac string) bool {
+	c.Lock()
+	defer c.Unlock()
+	_, found := c.devices[network.NormalizeMac(mac)]
+	return found
+}
+
+// Get rebuilds the characteristics of the device from the cached handles.
+func (c *gattCache) Get(mac string) ([]*gatt.Characteristic, bool) {
+	c.Lock()
+	defer c.Unlock()
+
+	dev, found := c.devices[network.NormalizeMac(mac)]
+	if !found {
+		return nil, false
+	}
+
+	chars := make([]*gatt.Characteristic, 0)
+	for _, cs := range dev.Services {
+		svcUUID, err := gatt.ParseUUID(cs.UUID)
+		if err != nil {
+			return nil, false
+		}
+		svc := gatt.NewService(svcUUID)
+		svc.SetHandle(cs.Handle)
+		svc.SetEndHandle(cs.EndHandle)
+
+		svcChars := make([]*gatt.Characteristic, 0)
+		for _, cc := range cs.Characteristics {
+			charUUID, err := gatt.ParseUUID(cc.UUID)
+			if err != nil {
+				return nil, false
+			}
+			ch := gatt.NewCharacteristic(charUUID, svc, gatt.Property(cc.Properties), cc.Handle, cc.ValueHandle)
+			ch.SetEndHandle(cc.EndHandle)
+			if cc.CCCDHandle != 0 {
+				ch.SetDescriptor(gatt.NewDescriptor(gatt.UUID16(0x2902), cc.CCCDHandle, ch))
+			}
+			svcChars = append(svcChars, ch)
+		}
+		svc.SetCharacteristics(svcChars)
+		chars = append(chars, svcChars...)
+	}
+
+	return chars, true
+}
+
+// Set stores the services and characteristics discovered for the device.
+func (c *gattCache) Set(mac string, services []*gatt.Service) error {
+	c.Lock()
+	defer c.Unlock()
+
+	dev := &gattCachedDevice{
+		Updated:  time.Now(),
+		Services: make([]gattCachedService, 0),
+	}
+
+	for _, svc := range services {
+		cs := gattCachedService{
+			UUID:            svc.UUID().String(),
+			Handle:          svc.Handle(),
+			EndHandle:       svc.EndHandle(),
+			Characteristics: make([]gattCachedCharacteristic, 0),
+		}
+		for _, ch := range svc.Characteristics() {
+			cc := gattCachedCharacteristic{
+				UUID:        ch.UUID().String(),
+				Properties:  uint8(ch.Properties()),
+				Handle:      ch.Handle(),
+				ValueHandle: ch.VHandle(),
+				EndHandle:   ch.EndHandle(),
+			}
+			if cccd := ch.Descriptor(); cccd != nil {
+				cc.CCCDHandle = cccd.Handle()
+			}
+			cs.Characteristics = append(cs.Characteristics, cc)
+		}
+		dev.Services = append(dev.Services, cs)
+	}
+
+	c.devices[network.NormalizeMac(mac)] = dev
+	return c.save()
+}
+
+// Clear removes the given device from the cache, or every device if mac is empty.
+func (c *gattCache) Clear(mac string) error {
+	c.Lock()
+	defer c.Unlock()
+
+	if mac == "" {
+		c.devices = make(map[string]*gattCachedDevice)
+		if fs.Exists(c.fileName) {
+			return os.Remove(c.fileName)
+		}
+		return nil
+	}
+
+	mac = network.NormalizeMac(mac)
+	if _, found := c.devices[mac]; !found {
+		return fmt.Errorf("%s is not cached", mac)
+	}
+	delete(c.devices, mac)
+	return c.save()
+}
@@ -0,0 +1,28 @@
+//go:build !windows
+// +build !windows
+
+package ble
+
+type CharacteristicEvent struct {
+	MAC    string `json:"mac"`
+	UUID   string `json:"uuid"`
+	Name   string `json:"name"`
+	Handle uint16 `json:"handle"`
+	Data   []byte `json:"data"`
+}
+
+type FuzzEvent struct {
+	MAC          string `json:"mac"`
+	UUID         string `json:"uuid"`
+	Round        int    `json:"round"`
+	Payload      []byte `json:"payload"`
+	Error        string `json:"error"`
+	Disconnected bool   `json:"disconnected"`
+}
+
+type RotationEvent struct {
+	Track    int    `json:"track"`
+	Previous string `json:"previous"`
+	Address  string `json:"address"`
+	IRK      string `json:"irk"`
+}
@@ -0,0 +1,137 @@
+//go:build !windows
+// +build !windows
+
+package ble
+
+import (
+	"math/rand"
+	"time"
+
+	"github.com/bettercap/bettercap/network"
+
+	"github.com/bettercap/gatt"
+)
+
+var bleFuzzInteresting = []byte{0x00, 0x01, 0x7f, 0x80, 0xfe, 0xff}
+
+// bleFuzzMutate returns a mutated copy of seed, at most maxSize bytes long.
+func bleFuzzMutate(rnd *rand.Rand, seed []byte, maxSize int) []byte {
+	data := append([]byte{}, seed...)
+	if len(data) == 0 {
+		data = make([]byte, 1+rnd.Intn(maxSize))
+		rnd.Read(data)
+	}
+
+	switch rnd.Intn(6) {
+	case 0:
+		// flip a few bits
+		for i := 0; i < 1+rnd.Intn(4); i++ {
+			data[rnd.Intn(len(data))] ^= 1 << uint(rnd.Intn(8))
+		}
+	case 1:
+		// boundary values
+		data[rnd.Intn(len(data))] = bleFuzzInteresting[rnd.Intn(len(bleFuzzInteresting))]
+	case 2:
+		// insert random bytes
+		pos := rnd.Intn(len(data) + 1)
+		extra := make([]byte, 1+rnd.Intn(8))
+		rnd.Read(extra)
+		data = append(data[:pos], append(extra, data[pos:]...)...)
+	case 3:
+		// truncate
+		data = data[:rnd.Intn(len(data))]
+	case 4:
+		// repeat the last byte up to the maximum size
+		for len(data) < maxSize {
+			data = append(data, data[len(data)-1])
+		}
+	case 5:
+		// completely random
+		data = make([]byte, 1+rnd.Intn(maxSize))
+		rnd.Read(data)
+	}
+
+	if len(data) > maxSize {
+		data = data[:maxSize]
+	} else if len(data) == 0 {
+		data = []byte{bleFuzzInteresting[rnd.Intn(len(bleFuzzInteresting))]}
+	}
+
+	return data
+}
+
+func (mod *BLERecon) fuzzTargets(a *bleAction, chars []*gatt.Characteristic) []*gatt.Characteristic {
+	targets := make([]*gatt.Characteristic, 0)
+	for _, ch := range chars {
+		if _, _, isWritable, _ := parseProperties(ch); !isWritable {
+			continue
+		} else if a.uuid != nil && !a.uuid.Equal(ch.UUID()) {
+			continue
+		}
+		targets = append(targets, ch)
+	}
+	return targets
+}
+
+// fuzz writes mutated payloads to the writable characteristics of the device,
+// starting from their current value if readable, and reports the payloads
+// causing errors or disconnections.
+func (mod *BLERecon) fuzz(p gatt.Peripheral, a *bleAction, chars []*gatt.Characteristic) {
+	targets := mod.fuzzTargets(a, chars)
+	if len(targets) == 0 {
+		mod.Error("no writable characteristics to fuzz.")
+		return
+	}
+
+	rnd := rand.New(rand.NewSource(time.Now().UnixNano()))
+	mac := network.NormalizeMac(p.ID())
+	failed := 0
+
+	mod.Info("fuzzing %d characteristics of %s with %d rounds each ...", len(targets), mac, mod.fuzzRounds)
+
+	for _, ch := range targets {
+		seed := []byte(nil)
+		if _, isReadable, _, _ := parseProperties(ch); isReadable {
+			mod.gattRequest(p, a, func() (err error) {
+				seed, err = p.ReadCharacteristic(ch)
+				return
+			})
+		}
+
+		mod.Debug("fuzzing %s starting from %x", ch.UUID(), seed)
+
+		for round := 1; round <= mod.fuzzRounds; round++ {
+			if a.stopped() {
+				mod.Info("fuzzing stopped.")
+				return
+			}
+
+			payload := bleFuzzMutate(rnd, seed, mod.fuzzSize)
+			event := FuzzEvent{
+				MAC:     mac,
+				UUID:    ch.UUID().String(),
+				Round:   round,
+				Payload: payload,
+			}
+
+			err := mod.writeCharacteristic(a, p, ch, payload)
+			if err == errBLEDisconnected || err == errBLETimeout || !mod.connected {
+				event.Disconnected = true
+				if err != nil {
+					event.Error = err.Error()
+				}
+				mod.Session.Events.Add("ble.fuzz.crash", event)
+				mod.Warning("%s stopped responding after writing %x to %s (round %d)", mac, payload, ch.UUID(), round)
+				return
+			} else if err != nil {
+				failed++
+				event.Error = err.Error()
+				mod.Session.Events.Add("ble.fuzz.error", event)
+			}
+
+			time.Sleep(time.Duration(mod.fuzzDelay) * time.Millisecond)
+		}
+	}
+
+	mod.Info("fuzzing of %s completed, %d writes returned an error.", mac, failed)
+}
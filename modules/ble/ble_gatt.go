@@ -0,0 +1,300 @@
+//go:build !windows
+// +build !windows
+
+package ble
+
+import (
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/bettercap/bettercap/network"
+
+	"github.com/bettercap/gatt"
+)
+
+type bleActionKind int
+
+const (
+	bleActionEnum bleActionKind = iota
+	bleActionWrite
+	bleActionRead
+	bleActionNotify
+	bleActionFuzz
+)
+
+var (
+	errBLEDisconnected = errors.New("device disconnected")
+	errBLETimeout      = errors.New("operation timed out")
+)
+
+// bleAction is what we want to do with a device once connected.
+type bleAction struct {
+	kind bleActionKind
+	// target characteristic, nil for every writable characteristic while fuzzing
+	uuid *gatt.UUID
+	data []byte
+	quit chan struct{}
+	once sync.Once
+}
+
+func newBLEAction(kind bleActionKind, uuid *gatt.UUID, data []byte) *bleAction {
+	return &bleAction{
+		kind: kind,
+		uuid: uuid,
+		data: data,
+		quit: make(chan struct{}),
+	}
+}
+
+func (a *bleAction) stop() {
+	a.once.Do(func() {
+		close(a.quit)
+	})
+}
+
+func (a *bleAction) stopped() bool {
+	select {
+	case <-a.quit:
+		return true
+	default:
+		return false
+	}
+}
+
+// gatt requests block forever if the device goes away, so each one is
+// executed with a timeout and aborted if the device disconnects. gatt sends
+// one request at a time and waits for its response before the next, so after
+// a timeout the connection is dropped and no other request is sent on it.
+func (mod *BLERecon) gattRequest(p gatt.Peripheral, a *bleAction, cb func() error) error {
+	if a.stopped() {
+		return errBLEDisconnected
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- cb()
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-a.quit:
+		return errBLEDisconnected
+	case <-time.After(time.Duration(mod.connTimeout) * time.Second):
+		a.stop()
+		mod.Debug("request timed out, disconnecting from %s ...", p.ID())
+		p.Device().CancelConnection(p)
+		return errBLETimeout
+	}
+}
+
+// discoverGATT enumerates services, characteristics and the configuration
+// descriptors of the characteristics supporting notifications.
+func (mod *BLERecon) discoverGATT(p gatt.Peripheral) ([]*gatt.Service, error) {
+	services, err := p.DiscoverServices(nil)
+	// https://github.com/bettercap/bettercap/issues/498
+	if err != nil && err.Error() != "success" {
+		return nil, err
+	}
+
+	for _, svc := range services {
+		chars, err := p.DiscoverCharacteristics(nil, svc)
+		if err != nil {
+			mod.Warning("error while enumerating chars for service %s: %s", svc.UUID(), err)
+			continue
+		}
+		mod.discoverCCCD(p, chars)
+	}
+
+	return services, nil
+}
+
+func (mod *BLERecon) discoverCCCD(p gatt.Peripheral, chars []*gatt.Characteristic) {
+	for _, ch := range chars {
+		if ch.Properties()&(gatt.CharNotify|gatt.CharIndicate) != 0 && ch.Descriptor() == nil {
+			if _, err := p.DiscoverDescriptors(nil, ch); err != nil {
+				mod.Debug("error while enumerating descriptors for %s: %s", ch.UUID(), err)
+			}
+		}
+	}
+}
+
+func (mod *BLERecon) updateCache(p gatt.Peripheral, services []*gatt.Service) {
+	if mod.cache == nil {
+		return
+	} else if err := mod.cache.Set(p.ID(), services); err != nil {
+		mod.Warning("could not update the GATT cache: %v", err)
+	} else {
+		mod.Debug("cached %d services of %s", len(services), p.ID())
+	}
+}
+
+// characteristics returns the characteristics of the device, from the cache
+// if available, otherwise from a new discovery.
+func (mod *BLERecon) characteristics(p gatt.Peripheral) ([]*gatt.Characteristic, error) {
+	if mod.cache != nil {
+		if chars, found := mod.cache.Get(p.ID()); found {
+			mod.Debug("using cached GATT handles for %s", p.ID())
+			return chars, nil
+		}
+	}
+
+	mod.Info("discovering services of %s ...", p.ID())
+	services, err := mod.discoverGATT(p)
+	if err != nil {
+		return nil, err
+	}
+	mod.updateCache(p, services)
+
+	chars := make([]*gatt.Characteristic, 0)
+	for _, svc := range services {
+		chars = append(chars, svc.Characteristics()...)
+	}
+	return chars, nil
+}
+
+func findCharacteristic(chars []*gatt.Characteristic, uuid gatt.UUID) *gatt.Characteristic {
+	for _, ch := range chars {
+		if ch.UUID().Equal(uuid) {
+			return ch
+		}
+	}
+	return nil
+}
+
+func newCharacteristicEvent(p gatt.Peripheral, ch *gatt.Characteristic, data []byte) CharacteristicEvent {
+	return CharacteristicEvent{
+		MAC:    network.NormalizeMac(p.ID()),
+		UUID:   ch.UUID().String(),
+		Name:   ch.Name(),
+		Handle: ch.VHandle(),
+		Data:   data,
+	}
+}
+
+// writeCharacteristic writes data to the characteristic, splitting it in
+// multiple consecutive writes if longer than ble.write.chunk bytes. These are
+// not ATT long writes, as gatt always writes at offset 0, each chunk replaces
+// the value of the characteristic: they are meant for characteristics which
+// receive a stream of data, like serial over BLE ones.
+func (mod *BLERecon) writeCharacteristic(a *bleAction, p gatt.Peripheral, ch *gatt.Characteristic, data []byte) error {
+	_, _, _, withResponse := parseProperties(ch)
+	for {
+		size := len(data)
+		if mod.writeChunk > 0 && size > mod.writeChunk {
+			size = mod.writeChunk
+		}
+
+		chunk := data[:size]
+		data = data[size:]
+		if err := mod.gattRequest(p, a, func() error {
+			return p.WriteCharacteristic(ch, chunk, !withResponse)
+		}); err != nil {
+			return err
+		} else if len(data) == 0 {
+			return nil
+		}
+	}
+}
+
+func (mod *BLERecon) runAction(p gatt.Peripheral, a *bleAction) {
+	chars, err := mod.characteristics(p)
+	if err != nil {
+		mod.Error("error discovering services: %s", err)
+		return
+	}
+
+	if a.kind == bleActionFuzz {
+		mod.fuzz(p, a, chars)
+		return
+	}
+
+	ch := findCharacteristic(chars, *a.uuid)
+	if ch == nil {
+		mod.Error("characteristic %s not found.", a.uuid)
+		return
+	}
+
+	switch a.kind {
+	case bleActionWrite:
+		mod.doWrite(p, a, ch)
+	case bleActionRead:
+		mod.doRead(p, a, ch)
+	case bleActionNotify:
+		mod.doNotify(p, a, ch)
+	}
+}
+
+func (mod *BLERecon) doWrite(p gatt.Peripheral, a *bleAction, ch *gatt.Characteristic) {
+	if _, _, isWritable, _ := parseProperties(ch); !isWritable {
+		mod.Warning("attempt to write %d bytes to non writable characteristics %s ...", len(a.data), ch.UUID())
+	}
+
+	if err := mod.writeCharacteristic(a, p, ch, a.data); err != nil {
+		mod.Error("error while writing: %s", err)
+	} else {
+		mod.Info("wrote %d bytes to %s", len(a.data), ch.UUID())
+	}
+}
+
+func (mod *BLERecon) doRead(p gatt.Peripheral, a *bleAction, ch *gatt.Characteristic) {
+	var data []byte
+	err := mod.gattRequest(p, a, func() (err error) {
+		data, err = p.ReadLongCharacteristic(ch)
+		return
+	})
+
+	if err != nil {
+		mod.Error("error while reading %s: %s", ch.UUID(), err)
+		return
+	}
+
+	mod.Session.Events.Add("ble.device.characteristic.read", newCharacteristicEvent(p, ch, data))
+	mod.Info("read %d bytes from %s:\n\n%s", len(data), ch.UUID(), hex.Dump(data))
+}
+
+func (mod *BLERecon) doNotify(p gatt.Peripheral, a *bleAction, ch *gatt.Characteristic) {
+	mask := ch.Properties()
+	if mask&(gatt.CharNotify|gatt.CharIndicate) == 0 {
+		mod.Error("characteristic %s does not support notifications nor indications.", ch.UUID())
+		return
+	} else if ch.Descriptor() == nil {
+		// cached or discovered without descriptors
+		mod.discoverCCCD(p, []*gatt.Characteristic{ch})
+		if ch.Descriptor() == nil {
+			mod.Error("client configuration descriptor for %s not found.", ch.UUID())
+			return
+		}
+	}
+
+	onValue := func(c *gatt.Characteristic, data []byte, err error) {
+		if err != nil {
+			mod.Warning("notification error from %s: %v", c.UUID(), err)
+		} else {
+			mod.Session.Events.Add("ble.device.characteristic.notification", newCharacteristicEvent(p, c, data))
+		}
+	}
+
+	subscribe := p.SetNotifyValue
+	what := "notifications"
+	if mask&gatt.CharNotify == 0 {
+		subscribe = p.SetIndicateValue
+		what = "indications"
+	}
+
+	if err := mod.gattRequest(p, a, func() error { return subscribe(ch, onValue) }); err != nil {
+		mod.Error("error while subscribing to %s: %s", ch.UUID(), err)
+		return
+	}
+
+	mod.Info("receiving %s from %s, use ble.notify off to stop.", what, ch.UUID())
+
+	<-a.quit
+
+	if mod.connected {
+		mod.gattRequest(p, newBLEAction(bleActionNotify, nil, nil), func() error { return subscribe(ch, nil) })
+	}
+	mod.Info("stopped %s from %s", what, ch.UUID())
+}
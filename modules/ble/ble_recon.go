@@ -7,6 +7,7 @@ import (
 	"encoding/hex"
 	"fmt"
 	golog "log"
+	"strings"
 	"time"
 
 	"github.com/bettercap/bettercap/modules/utils"
@@ -23,11 +24,16 @@ type BLERecon struct {
 	deviceId    int
 	gattDevice  gatt.Device
 	currDevice  *network.BLEDevice
-	writeUUID   *gatt.UUID
-	writeData   []byte
+	action      *bleAction
+	cache       *gattCache
 	connected   bool
 	connTimeout int
 	devTTL      int
+	trackTTL    int
+	writeChunk  int
+	fuzzRounds  int
+	fuzzSize    int
+	fuzzDelay   int
 	quit        chan bool
 	done        chan bool
 	selector    *utils.ViewSelector
@@ -42,6 +48,10 @@ func NewBLERecon(s *session.Session) *BLERecon {
 		done:          make(chan bool),
 		connTimeout:   5,
 		devTTL:        30,
+		trackTTL:      1800,
+		fuzzRounds:    100,
+		fuzzSize:      64,
+		fuzzDelay:     50,
 		currDevice:    nil,
 		connected:     false,
 	}
@@ -50,7 +60,7 @@ func NewBLERecon(s *session.Session) *BLERecon {
 
 	mod.selector = utils.ViewSelectorFor(&mod.SessionModule,
 		"ble.show",
-		[]string{"rssi", "mac", "seen"}, "rssi asc")
+		[]string{"rssi", "mac", "seen", "track"}, "rssi asc")
 
 	mod.AddHandler(session.NewModuleHandler("ble.recon on", "",
 		"Start Bluetooth Low Energy devices discovery.",
@@ -84,10 +94,7 @@ func NewBLERecon(s *session.Session) *BLERecon {
 				return fmt.Errorf("An enumeration for %s is already running, please wait.", mod.currDevice.Device.ID())
 			}
 
-			mod.writeData = nil
-			mod.writeUUID = nil
-
-			return mod.enumAllTheThings(network.NormalizeMac(args[0]))
+			return mod.enumAllTheThings(network.NormalizeMac(args[0]), newBLEAction(bleActionEnum, nil, nil))
 		})
 
 	enum.Complete("ble.enum", s.BLECompleter)
@@ -114,6 +121,73 @@ func NewBLERecon(s *session.Session) *BLERecon {
 
 	mod.AddHandler(write)
 
+	read := session.NewModuleHandler("ble.read MAC UUID", "ble.read "+network.BLEMacValidator+" ([a-fA-F0-9]+)",
+		"Read the value of the characteristics with the given UUID from the BLE device with the specified MAC address.",
+		func(args []string) error {
+			uuid, err := gatt.ParseUUID(args[1])
+			if err != nil {
+				return fmt.Errorf("Error parsing %s: %s", args[1], err)
+			}
+			return mod.enumAllTheThings(network.NormalizeMac(args[0]), newBLEAction(bleActionRead, &uuid, nil))
+		})
+
+	read.Complete("ble.read", s.BLECompleter)
+
+	mod.AddHandler(read)
+
+	notify := session.NewModuleHandler("ble.notify on MAC UUID", "ble.notify on "+network.BLEMacValidator+" ([a-fA-F0-9]+)",
+		"Subscribe to notifications or indications of the characteristics with the given UUID, values are reported as ble.device.characteristic.notification events.",
+		func(args []string) error {
+			uuid, err := gatt.ParseUUID(args[1])
+			if err != nil {
+				return fmt.Errorf("Error parsing %s: %s", args[1], err)
+			}
+			return mod.enumAllTheThings(network.NormalizeMac(args[0]), newBLEAction(bleActionNotify, &uuid, nil))
+		})
+
+	notify.Complete("ble.notify on", s.BLECompleter)
+
+	mod.AddHandler(notify)
+
+	mod.AddHandler(session.NewModuleHandler("ble.notify off", "",
+		"Stop receiving notifications and disconnect from the device.",
+		func(args []string) error {
+			return mod.stopAction(bleActionNotify)
+		}))
+
+	fuzz := session.NewModuleHandler("ble.fuzz MAC [UUID]", "ble.fuzz "+network.BLEMacValidator+"(?:\\s+([a-fA-F0-9]+))?",
+		"Write mutated payloads to the writable characteristics of the BLE device (or only to the one with the given UUID) and report errors and disconnections.",
+		func(args []string) error {
+			var uuid *gatt.UUID
+			if args[1] != "" {
+				if u, err := gatt.ParseUUID(args[1]); err != nil {
+					return fmt.Errorf("Error parsing %s: %s", args[1], err)
+				} else {
+					uuid = &u
+				}
+			}
+			return mod.enumAllTheThings(network.NormalizeMac(args[0]), newBLEAction(bleActionFuzz, uuid, nil))
+		})
+
+	fuzz.Complete("ble.fuzz", s.BLECompleter)
+
+	mod.AddHandler(fuzz)
+
+	mod.AddHandler(session.NewModuleHandler("ble.fuzz off", "",
+		"Stop fuzzing and disconnect from the device.",
+		func(args []string) error {
+			return mod.stopAction(bleActionFuzz)
+		}))
+
+	mod.AddHandler(session.NewModuleHandler("ble.cache.clear", "ble.cache.clear\\s*"+network.BLEMacValidator+"?",
+		"Remove the cached GATT handles of the BLE device with the given MAC address or of every device if no address is specified.",
+		func(args []string) error {
+			if err := mod.loadCache(); err != nil {
+				return err
+			}
+			return mod.cache.Clear(args[0])
+		}))
+
 	mod.AddParam(session.NewIntParameter("ble.device",
 		fmt.Sprintf("%d", mod.deviceId),
 		"Index of the HCI device to use, -1 to autodetect."))
@@ -126,6 +200,40 @@ func NewBLERecon(s *session.Session) *BLERecon {
 		fmt.Sprintf("%d", mod.devTTL),
 		"Seconds of inactivity for a device to be pruned."))
 
+	mod.AddParam(session.NewStringParameter("ble.track.irk",
+		"",
+		"",
+		"Comma separated list of identity resolving keys, in the NAME=HEX or HEX form, used to resolve the random addresses of known devices."))
+
+	mod.AddParam(session.NewIntParameter("ble.track.window",
+		"60",
+		"Seconds within which a new address with the same advertisement fingerprint of a silent one is considered the same device."))
+
+	mod.AddParam(session.NewIntParameter("ble.track.ttl",
+		fmt.Sprintf("%d", mod.trackTTL),
+		"Seconds of inactivity for a tracked device to be forgotten."))
+
+	mod.AddParam(session.NewIntParameter("ble.write.chunk",
+		"0",
+		"If greater than 0, buffers longer than this number of bytes will be split in multiple consecutive writes, each one replacing the value of the characteristic, as expected by stream characteristics like serial over BLE ones."))
+
+	mod.AddParam(session.NewStringParameter("ble.cache.file",
+		"~/bettercap.ble.cache",
+		"",
+		"File used to store the GATT handles of enumerated devices, so that they can be accessed without a new discovery."))
+
+	mod.AddParam(session.NewIntParameter("ble.fuzz.rounds",
+		fmt.Sprintf("%d", mod.fuzzRounds),
+		"Number of mutated payloads to write to each characteristic."))
+
+	mod.AddParam(session.NewIntParameter("ble.fuzz.size",
+		fmt.Sprintf("%d", mod.fuzzSize),
+		"Maximum size in bytes of the mutated payloads."))
+
+	mod.AddParam(session.NewIntParameter("ble.fuzz.delay",
+		fmt.Sprintf("%d", mod.fuzzDelay),
+		"Milliseconds to wait between fuzzing writes."))
+
 	return mod
 }
 
@@ -185,11 +293,72 @@ func (mod *BLERecon) Configure() (err error) {
 		return err
 	} else if err, mod.devTTL = mod.IntParam("ble.ttl"); err != nil {
 		return err
+	} else if err, mod.writeChunk = mod.IntParam("ble.write.chunk"); err != nil {
+		return err
+	} else if err, mod.fuzzRounds = mod.IntParam("ble.fuzz.rounds"); err != nil {
+		return err
+	} else if err, mod.fuzzSize = mod.IntParam("ble.fuzz.size"); err != nil {
+		return err
+	} else if err, mod.fuzzDelay = mod.IntParam("ble.fuzz.delay"); err != nil {
+		return err
+	} else if mod.fuzzSize < 1 {
+		return fmt.Errorf("ble.fuzz.size must be greater than 0")
+	} else if err, mod.trackTTL = mod.IntParam("ble.track.ttl"); err != nil {
+		return err
+	} else if err = mod.configureTracker(); err != nil {
+		return err
+	}
+
+	return mod.loadCache()
+}
+
+func (mod *BLERecon) configureTracker() error {
+	err, window := mod.IntParam("ble.track.window")
+	if err != nil {
+		return err
+	}
+
+	err, irks := mod.StringParam("ble.track.irk")
+	if err != nil {
+		return err
+	}
+
+	tracker := mod.Session.BLE.Tracker
+	tracker.Window = time.Duration(window) * time.Second
+	tracker.ClearIRKs()
+
+	for i, entry := range str.Comma(irks) {
+		name := fmt.Sprintf("irk%d", i+1)
+		if parts := strings.SplitN(entry, "=", 2); len(parts) == 2 {
+			name, entry = str.Trim(parts[0]), parts[1]
+		}
+
+		if key, err := network.ParseBLEIRK(entry); err != nil {
+			return fmt.Errorf("error parsing IRK %s: %v", name, err)
+		} else {
+			tracker.SetIRK(name, key)
+		}
 	}
 
 	return nil
 }
 
+func (mod *BLERecon) loadCache() error {
+	err, fileName := mod.StringParam("ble.cache.file")
+	if err != nil {
+		return err
+	} else if mod.cache != nil && mod.cache.fileName == fileName {
+		return nil
+	}
+
+	cache, err := loadGATTCache(fileName)
+	if err != nil {
+		return err
+	}
+	mod.cache = cache
+	return nil
+}
+
 func (mod *BLERecon) Start() error {
 	if err := mod.Configure(); err != nil {
 		return err
@@ -242,6 +411,8 @@ func (mod *BLERecon) pruner() {
 			}
 		}
 
+		mod.Session.BLE.Tracker.Prune(time.Duration(mod.trackTTL)*time.Second, time.Now())
+
 		time.Sleep(5 * time.Second)
 	}
 }
@@ -253,19 +424,29 @@ func (mod *BLERecon) setCurrentDevice(dev *network.BLEDevice) {
 }
 
 func (mod *BLERecon) writeBuffer(mac string, uuid gatt.UUID, data []byte) error {
-	mod.writeUUID = &uuid
-	mod.writeData = data
-	return mod.enumAllTheThings(mac)
+	return mod.enumAllTheThings(mac, newBLEAction(bleActionWrite, &uuid, data))
+}
+
+func (mod *BLERecon) stopAction(kind bleActionKind) error {
+	if a := mod.action; a == nil || a.kind != kind || a.stopped() {
+		return fmt.Errorf("nothing to stop")
+	} else {
+		a.stop()
+	}
+	return nil
 }
 
-func (mod *BLERecon) enumAllTheThings(mac string) error {
+func (mod *BLERecon) enumAllTheThings(mac string, action *bleAction) error {
 	dev, found := mod.Session.BLE.Get(mac)
 	if !found || dev == nil {
 		return fmt.Errorf("BLE device with address %s not found.", mac)
+	} else if mod.isEnumerating() {
+		return fmt.Errorf("An operation on %s is already running, please wait.", mod.currDevice.Device.ID())
 	} else if mod.Running() {
 		mod.gattDevice.StopScanning()
 	}
 
+	mod.action = action
 	mod.setCurrentDevice(dev)
 	if err := mod.Configure(); err != nil && err.Error() != session.ErrAlreadyStarted("ble.recon").Error() {
 		return err
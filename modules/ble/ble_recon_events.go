@@ -30,11 +30,23 @@ func (mod *BLERecon) onStateChanged(dev gatt.Device, s gatt.State) {
 
 func (mod *BLERecon) onPeriphDiscovered(p gatt.Peripheral, a *gatt.Advertisement, rssi int) {
 	mod.Session.BLE.AddIfNew(p.ID(), p, a, rssi)
+
+	if track, previous := mod.Session.BLE.Track(p.ID(), a, rssi); previous != "" {
+		mod.Session.Events.Add("ble.device.rotated", RotationEvent{
+			Track:    track.ID,
+			Previous: previous,
+			Address:  track.Address(),
+			IRK:      track.IRK,
+		})
+	}
 }
 
 func (mod *BLERecon) onPeriphDisconnected(p gatt.Peripheral, err error) {
 	mod.Session.Events.Add("ble.device.disconnected", mod.currDevice)
 	mod.setCurrentDevice(nil)
+	if mod.action != nil {
+		mod.action.stop()
+	}
 	if mod.Running() {
 		mod.Debug("device disconnected, restoring discovery.")
 		mod.gattDevice.Scan([]gatt.UUID{}, true)
@@ -64,6 +76,11 @@ func (mod *BLERecon) onPeriphConnected(p gatt.Peripheral, err error) {
 		mod.Warning("failed to set MTU: %s", err)
 	}
 
+	if action := mod.action; action != nil && action.kind != bleActionEnum {
+		mod.runAction(p, action)
+		return
+	}
+
 	mod.Debug("connected, enumerating all the things for %s!", p.ID())
 	services, err := p.DiscoverServices(nil)
 	// https://github.com/bettercap/bettercap/issues/498
@@ -73,4 +90,9 @@ func (mod *BLERecon) onPeriphConnected(p gatt.Peripheral, err error) {
 	}
 
 	mod.showServices(p, services)
+
+	for _, svc := range services {
+		mod.discoverCCCD(p, svc.Characteristics())
+	}
+	mod.updateCache(p, services)
 }
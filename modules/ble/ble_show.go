@@ -4,6 +4,7 @@
 package ble
 
 import (
+	"fmt"
 	"sort"
 	"time"
 
@@ -24,6 +25,17 @@ func (mod *BLERecon) getRow(dev *network.BLEDevice, withName bool) []string {
 	isConnectable := ops.Ternary(dev.Advertisement.Connectable, tui.Green("✔"), tui.Red("✖")).(string)
 	sinceSeen := time.Since(dev.LastSeen)
 	lastSeen := dev.LastSeen.Format("15:04:05")
+	advData := tui.Dim(network.BLEAdvDataString(dev.AdvData))
+	track := ""
+	if t, found := mod.Session.BLE.Tracker.Get(address); found {
+		track = fmt.Sprintf("#%d", t.ID)
+		if n := len(t.Addresses); n > 1 {
+			track += tui.Dim(fmt.Sprintf(" (%d addresses)", n))
+		}
+		if t.IRK != "" {
+			track += " " + tui.Green(t.IRK)
+		}
+	}
 
 	blePresentInterval := time.Duration(mod.devTTL) * time.Second
 	if sinceSeen <= bleAliveInterval {
@@ -37,9 +49,11 @@ func (mod *BLERecon) getRow(dev *network.BLEDevice, withName bool) []string {
 		return []string{
 			rssi,
 			address,
+			track,
 			tui.Yellow(dev.Name()),
 			vendor,
 			dev.Advertisement.Flags.String(),
+			advData,
 			isConnectable,
 			lastSeen,
 		}
@@ -47,8 +61,10 @@ func (mod *BLERecon) getRow(dev *network.BLEDevice, withName bool) []string {
 		return []string{
 			rssi,
 			address,
+			track,
 			vendor,
 			dev.Advertisement.Flags.String(),
+			advData,
 			isConnectable,
 			lastSeen,
 		}
@@ -61,7 +77,8 @@ func (mod *BLERecon) doFilter(dev *network.BLEDevice) bool {
 	}
 	return mod.selector.Expression.MatchString(dev.Device.ID()) ||
 		mod.selector.Expression.MatchString(dev.Device.Name()) ||
-		mod.selector.Expression.MatchString(dev.Vendor)
+		mod.selector.Expression.MatchString(dev.Vendor) ||
+		mod.selector.Expression.MatchString(network.BLEAdvDataString(dev.AdvData))
 }
 
 func (mod *BLERecon) doSelection() (devices []*network.BLEDevice, err error) {
@@ -83,6 +100,8 @@ func (mod *BLERecon) doSelection() (devices []*network.BLEDevice, err error) {
 		sort.Sort(ByBLEMacSorter(devices))
 	case "seen":
 		sort.Sort(ByBLESeenSorter(devices))
+	case "track":
+		sort.Sort(ByBLETrackSorter(devices))
 	default:
 		sort.Sort(ByBLERSSISorter(devices))
 	}
@@ -109,17 +128,19 @@ func (mod *BLERecon) doSelection() (devices []*network.BLEDevice, err error) {
 }
 
 func (mod *BLERecon) colNames(withName bool) []string {
-	colNames := []string{"RSSI", "MAC", "Vendor", "Flags", "Connect", "Seen"}
-	seenIdx := 5
+	colNames := []string{"RSSI", "MAC", "Track", "Vendor", "Flags", "Data", "Connect", "Seen"}
+	seenIdx := 7
 	if withName {
-		colNames = []string{"RSSI", "MAC", "Name", "Vendor", "Flags", "Connect", "Seen"}
-		seenIdx = 6
+		colNames = []string{"RSSI", "MAC", "Track", "Name", "Vendor", "Flags", "Data", "Connect", "Seen"}
+		seenIdx = 8
 	}
 	switch mod.selector.SortField {
 	case "rssi":
 		colNames[0] += " " + mod.selector.SortSymbol
 	case "mac":
 		colNames[1] += " " + mod.selector.SortSymbol
+	case "track":
+		colNames[2] += " " + mod.selector.SortSymbol
 	case "seen":
 		colNames[seenIdx] += " " + mod.selector.SortSymbol
 	}
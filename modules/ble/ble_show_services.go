@@ -273,9 +273,6 @@ func (mod *BLERecon) showServices(p gatt.Peripheral, services []*gatt.Service) {
 	columns := []string{"Handles", "Service > Characteristics", "Properties", "Data"}
 	rows := make([][]string, 0)
 
-	wantsToWrite := mod.writeUUID != nil
-	foundToWrite := false
-
 	mod.currDevice.Services = make([]network.BLEService, 0)
 
 	for _, svc := range services {
@@ -310,7 +307,7 @@ func (mod *BLERecon) showServices(p gatt.Peripheral, services []*gatt.Service) {
 			mod.Error("error while enumerating chars for service %s: %s", svc.UUID(), err)
 		} else {
 			for _, ch := range chars {
-				props, isReadable, isWritable, withResponse := parseProperties(ch)
+				props, isReadable, _, _ := parseProperties(ch)
 
 				char := network.BLECharacteristic{
 					UUID:       ch.UUID().String(),
@@ -328,19 +325,6 @@ func (mod *BLERecon) showServices(p gatt.Peripheral, services []*gatt.Service) {
 					name = fmt.Sprintf("    %s (%s)", tui.Green(name), tui.Dim(ch.UUID().String()))
 				}
 
-				if wantsToWrite && mod.writeUUID.Equal(ch.UUID()) {
-					foundToWrite = true
-					if isWritable {
-						mod.Debug("writing %d bytes to characteristics %s ...", len(mod.writeData), mod.writeUUID)
-					} else {
-						mod.Warning("attempt to write %d bytes to non writable characteristics %s ...", len(mod.writeData), mod.writeUUID)
-					}
-
-					if err := p.WriteCharacteristic(ch, mod.writeData, !withResponse); err != nil {
-						mod.Error("error while writing: %s", err)
-					}
-				}
-
 				sz := 0
 				raw := ([]byte)(nil)
 				err := error(nil)
@@ -403,10 +387,6 @@ func (mod *BLERecon) showServices(p gatt.Peripheral, services []*gatt.Service) {
 		mod.currDevice.Services = append(mod.currDevice.Services, service)
 	}
 
-	if wantsToWrite && !foundToWrite {
-		mod.Error("writable characteristics %s not found.", mod.writeUUID)
-	} else {
-		tui.Table(mod.Session.Events.Stdout, columns, rows)
-		mod.Session.Refresh()
-	}
+	tui.Table(mod.Session.Events.Stdout, columns, rows)
+	mod.Session.Refresh()
 }
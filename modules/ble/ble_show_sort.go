@@ -31,3 +31,14 @@ type ByBLESeenSorter []*network.BLEDevice
 func (a ByBLESeenSorter) Len() int           { return len(a) }
 func (a ByBLESeenSorter) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
 func (a ByBLESeenSorter) Less(i, j int) bool { return a[i].LastSeen.Before(a[j].LastSeen) }
+
+type ByBLETrackSorter []*network.BLEDevice
+
+func (a ByBLETrackSorter) Len() int      { return len(a) }
+func (a ByBLETrackSorter) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+func (a ByBLETrackSorter) Less(i, j int) bool {
+	if a[i].Track == a[j].Track {
+		return a[i].LastSeen.Before(a[j].LastSeen)
+	}
+	return a[i].Track < a[j].Track
+}
@@ -0,0 +1,71 @@
+package creds
+
+import (
+	"github.com/bettercap/bettercap/session"
+)
+
+type CredsModule struct {
+	session.SessionModule
+}
+
+func NewCredsModule(s *session.Session) *CredsModule {
+	mod := &CredsModule{
+		SessionModule: session.NewSessionModule("creds", s),
+	}
+
+	mod.AddParam(session.NewStringParameter("creds.show.filter",
+		"",
+		"",
+		"If set, only credentials matching this regular expression on service, host, client or username will be shown."))
+
+	mod.AddHandler(session.NewModuleHandler("creds.show", "",
+		"Show the credentials captured so far by every module.",
+		func(args []string) error {
+			return mod.Show()
+		}))
+
+	mod.AddHandler(session.NewModuleHandler("creds.export FILENAME", `creds\.export\s+(.+)`,
+		"Export the captured credentials to FILENAME, as CSV if its extension is .csv, as JSON otherwise.",
+		func(args []string) error {
+			return mod.Export(args[0])
+		}))
+
+	mod.AddHandler(session.NewModuleHandler("creds.export.hashcat PREFIX", `creds\.export\.hashcat\s+(.+)`,
+		"Export the captured hashes to PREFIX.<mode>.txt files, one for each hashcat mode and ready to be cracked with hashcat or john.",
+		func(args []string) error {
+			return mod.ExportHashcat(args[0])
+		}))
+
+	mod.AddHandler(session.NewModuleHandler("creds.clear", "",
+		"Clear the captured credentials.",
+		func(args []string) error {
+			mod.Session.Credentials.Clear()
+			return nil
+		}))
+
+	return mod
+}
+
+func (mod *CredsModule) Name() string {
+	return "creds"
+}
+
+func (mod *CredsModule) Description() string {
+	return "A module to show and export the credentials captured by the other modules."
+}
+
+func (mod *CredsModule) Author() string {
+	return "Simone Margaritelli <evilsocket@gmail.com>"
+}
+
+func (mod *CredsModule) Configure() error {
+	return nil
+}
+
+func (mod *CredsModule) Stop() error {
+	return nil
+}
+
+func (mod *CredsModule) Start() error {
+	return nil
+}
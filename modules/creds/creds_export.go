@@ -0,0 +1,74 @@
+package creds
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/evilsocket/islazy/fs"
+)
+
+func (mod *CredsModule) Export(fileName string) error {
+	fileName, err := fs.Expand(fileName)
+	if err != nil {
+		return err
+	}
+
+	fp, err := os.Create(fileName)
+	if err != nil {
+		return err
+	}
+	defer fp.Close()
+
+	if strings.ToLower(filepath.Ext(fileName)) == ".csv" {
+		err = mod.Session.Credentials.WriteCSV(fp)
+	} else {
+		err = mod.Session.Credentials.WriteJSON(fp)
+	}
+	if err != nil {
+		return err
+	}
+
+	mod.Info("%d credentials saved to %s", mod.Session.Credentials.Len(), fileName)
+	return nil
+}
+
+// ExportHashcat writes one file per hashcat mode, since hashcat can't crack
+// different hash types in the same session, with one hash per line.
+func (mod *CredsModule) ExportHashcat(prefix string) error {
+	prefix, err := fs.Expand(prefix)
+	if err != nil {
+		return err
+	}
+	prefix = strings.TrimSuffix(prefix, filepath.Ext(prefix))
+
+	byMode := make(map[int][]string)
+	for _, c := range mod.Session.Credentials.List() {
+		if mode, ok := c.HashcatMode(); ok {
+			byMode[mode] = append(byMode[mode], c.Secret)
+		}
+	}
+
+	if len(byMode) == 0 {
+		return fmt.Errorf("no hashes captured yet")
+	}
+
+	modes := make([]int, 0, len(byMode))
+	for mode := range byMode {
+		modes = append(modes, mode)
+	}
+	sort.Ints(modes)
+
+	for _, mode := range modes {
+		fileName := fmt.Sprintf("%s.%d.txt", prefix, mode)
+		data := strings.Join(byMode[mode], "\n") + "\n"
+		if err := os.WriteFile(fileName, []byte(data), 0644); err != nil {
+			return err
+		}
+		mod.Info("%d hashes saved to %s (hashcat -m %d)", len(byMode[mode]), fileName, mode)
+	}
+
+	return nil
+}
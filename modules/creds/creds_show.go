@@ -0,0 +1,106 @@
+package creds
+
+import (
+	"regexp"
+	"strconv"
+
+	"github.com/bettercap/bettercap/session"
+
+	"github.com/evilsocket/islazy/tui"
+)
+
+var hashTypes = map[int]string{
+	4800:  "iSCSI CHAP",
+	5500:  "NetNTLMv1",
+	5600:  "NetNTLMv2",
+	7500:  "AS-REQ Pre-Auth (RC4)",
+	11100: "PostgreSQL CRAM (MD5)",
+	11200: "MySQL CRAM (SHA1)",
+	11400: "SIP digest",
+	13100: "TGS-REP (RC4)",
+	16400: "CRAM-MD5",
+	18200: "AS-REP (RC4)",
+	19800: "AS-REQ Pre-Auth (AES128)",
+	19900: "AS-REQ Pre-Auth (AES256)",
+	22000: "WPA-PMKID",
+	32100: "AS-REP (AES128)",
+	32200: "AS-REP (AES256)",
+}
+
+const maxSecretLen = 40
+
+func formatName(c session.Credential) string {
+	if mode, ok := c.HashcatMode(); ok {
+		if name, found := hashTypes[mode]; found {
+			return name
+		}
+	}
+	return c.Format
+}
+
+func secretView(c session.Credential) string {
+	secret := c.Secret
+	if len(secret) > maxSecretLen {
+		secret = secret[:maxSecretLen] + "..."
+	}
+
+	if c.Format == session.CredentialPlain {
+		return tui.Red(secret)
+	}
+	return tui.Dim(secret)
+}
+
+func (mod *CredsModule) Show() error {
+	err, filter := mod.StringParam("creds.show.filter")
+	if err != nil {
+		return err
+	}
+
+	var expr *regexp.Regexp
+	if filter != "" {
+		if expr, err = regexp.Compile(filter); err != nil {
+			return err
+		}
+	}
+
+	colNames := []string{
+		"Service",
+		"Host",
+		"Client",
+		"Username",
+		"Secret",
+		"Format",
+		"Source",
+		"Hits",
+		"Last Seen",
+	}
+	rows := [][]string{}
+
+	for _, c := range mod.Session.Credentials.List() {
+		if expr != nil && !expr.MatchString(c.Service) && !expr.MatchString(c.Host) &&
+			!expr.MatchString(c.Client) && !expr.MatchString(c.Username) {
+			continue
+		}
+
+		rows = append(rows, []string{
+			tui.Bold(c.Service),
+			c.Host,
+			c.Client,
+			tui.Green(c.Username),
+			secretView(c),
+			formatName(c),
+			tui.Dim(c.Source),
+			strconv.Itoa(c.Hits),
+			c.LastSeen.Format("15:04:05"),
+		})
+	}
+
+	if len(rows) == 0 {
+		mod.Info("no credentials captured yet")
+		return nil
+	}
+
+	tui.Table(mod.Session.Events.Stdout, colNames, rows)
+
+	return nil
+}
@@ -86,13 +86,43 @@ func (mod *EventsStream) viewSnifferEvent(output io.Writer, e session.Event) {
 
 func (mod *EventsStream) viewSynScanEvent(output io.Writer, e session.Event) {
 	se := e.Data.(syn_scan.SynScanEvent)
-	fmt.Fprintf(output, "[%s] [%s] found open port %d for %s\n",
+	if se.Details != nil {
+		fmt.Fprintf(output, "[%s] [%s] port %d/%s of %s is %s\n",
+			e.Time.Format(mod.timeFormat),
+			tui.Green(e.Tag),
+			se.Port,
+			se.Proto,
+			tui.Bold(se.Address),
+			tui.Yellow(se.Details.Description()))
+		return
+	}
+
+	fmt.Fprintf(output, "[%s] [%s] found %s port %d/%s for %s\n",
 		e.Time.Format(mod.timeFormat),
 		tui.Green(e.Tag),
+		se.State,
 		se.Port,
+		se.Proto,
 		tui.Bold(se.Address))
 }
 
+func (mod *EventsStream) viewCredentialEvent(output io.Writer, e session.Event) {
+	cred := e.Data.(session.Credential)
+	secret := cred.Format
+	if cred.Format == session.CredentialPlain {
+		secret = tui.Red(cred.Secret)
+	}
+
+	fmt.Fprintf(output, "[%s] [%s] %s credentials for %s from %s: %s %s\n",
+		e.Time.Format(mod.timeFormat),
+		tui.Green(e.Tag),
+		tui.Bold(cred.Service),
+		tui.Yellow(cred.Host),
+		cred.Client,
+		tui.Bold(cred.Username),
+		secret)
+}
+
 func (mod *EventsStream) viewUpdateEvent(output io.Writer, e session.Event) {
 	update := e.Data.(*github.RepositoryRelease)
 
@@ -130,6 +160,8 @@ func (mod *EventsStream) Render(output io.Writer, e session.Event) {
 		mod.viewSnifferEvent(output, e)
 	} else if e.Tag == "syn.scan" {
 		mod.viewSynScanEvent(output, e)
+	} else if e.Tag == "creds.new" {
+		mod.viewCredentialEvent(output, e)
 	} else if e.Tag == "update.available" {
 		mod.viewUpdateEvent(output, e)
 	} else if e.Tag == "gateway.change" {
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"io"
 
+	"github.com/bettercap/bettercap/modules/ble"
 	"github.com/bettercap/bettercap/network"
 	"github.com/bettercap/bettercap/session"
 
@@ -25,13 +26,19 @@ func (mod *EventsStream) viewBLEEvent(output io.Writer, e session.Event) {
 			vend = fmt.Sprintf(" (%s)", tui.Yellow(vend))
 		}
 
-		fmt.Fprintf(output, "[%s] [%s] new BLE device%s detected as %s%s %s.\n",
+		adv := ""
+		if len(dev.AdvData) > 0 {
+			adv = fmt.Sprintf(" [%s]", network.BLEAdvDataString(dev.AdvData))
+		}
+
+		fmt.Fprintf(output, "[%s] [%s] new BLE device%s detected as %s%s %s%s.\n",
 			e.Time.Format(mod.timeFormat),
 			tui.Green(e.Tag),
 			name,
 			dev.Device.ID(),
 			vend,
-			tui.Dim(fmt.Sprintf("%d dBm", dev.RSSI)))
+			tui.Dim(fmt.Sprintf("%d dBm", dev.RSSI)),
+			tui.Dim(adv))
 	} else if e.Tag == "ble.device.lost" {
 		dev := e.Data.(*network.BLEDevice)
 		name := dev.Device.Name()
@@ -49,5 +56,47 @@ func (mod *EventsStream) viewBLEEvent(output io.Writer, e session.Event) {
 			name,
 			dev.Device.ID(),
 			vend)
+	} else if e.Tag == "ble.device.rotated" {
+		ev := e.Data.(ble.RotationEvent)
+		how := "advertisement fingerprint"
+		if ev.IRK != "" {
+			how = fmt.Sprintf("IRK %s", tui.Green(ev.IRK))
+		}
+
+		fmt.Fprintf(output, "[%s] [%s] BLE device #%d rotated its address from %s to %s %s.\n",
+			e.Time.Format(mod.timeFormat),
+			tui.Green(e.Tag),
+			ev.Track,
+			ev.Previous,
+			tui.Bold(ev.Address),
+			tui.Dim("(matched by "+how+")"))
+	} else if e.Tag == "ble.device.characteristic.notification" || e.Tag == "ble.device.characteristic.read" {
+		ev := e.Data.(ble.CharacteristicEvent)
+		name := ev.UUID
+		if ev.Name != "" {
+			name = fmt.Sprintf("%s (%s)", ev.Name, ev.UUID)
+		}
+
+		fmt.Fprintf(output, "[%s] [%s] %s %s : %x\n",
+			e.Time.Format(mod.timeFormat),
+			tui.Green(e.Tag),
+			ev.MAC,
+			tui.Bold(name),
+			ev.Data)
+	} else if e.Tag == "ble.fuzz.crash" || e.Tag == "ble.fuzz.error" {
+		ev := e.Data.(ble.FuzzEvent)
+		tag := tui.Yellow(e.Tag)
+		if ev.Disconnected {
+			tag = tui.Red(e.Tag)
+		}
+
+		fmt.Fprintf(output, "[%s] [%s] writing %x to %s of %s (round %d): %s\n",
+			e.Time.Format(mod.timeFormat),
+			tag,
+			ev.Payload,
+			tui.Bold(ev.UUID),
+			ev.MAC,
+			ev.Round,
+			ev.Error)
 	}
 }
@@ -3,7 +3,9 @@ package events_stream
 import (
 	"fmt"
 	"io"
+	"strconv"
 
+	"github.com/bettercap/bettercap/modules/hid"
 	"github.com/bettercap/bettercap/network"
 	"github.com/bettercap/bettercap/session"
 
@@ -11,6 +13,18 @@ import (
 )
 
 func (mod *EventsStream) viewHIDEvent(output io.Writer, e session.Event) {
+	if e.Tag == "hid.keystrokes" {
+		ev := e.Data.(hid.KeystrokesEvent)
+		fmt.Fprintf(output, "[%s] [%s] %s (%s) typed %s : %s\n",
+			e.Time.Format(mod.timeFormat),
+			tui.Green(e.Tag),
+			tui.Bold(ev.Address),
+			tui.Dim(ev.Type),
+			tui.Yellow(strconv.Quote(ev.Keys)),
+			ev.Line)
+		return
+	}
+
 	dev := e.Data.(*network.HIDDevice)
 	if e.Tag == "hid.device.new" {
 		fmt.Fprintf(output, "[%s] [%s] new HID device %s detected on channel %s.\n",
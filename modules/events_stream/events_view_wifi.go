@@ -25,13 +25,18 @@ func (mod *EventsStream) viewWiFiApEvent(output io.Writer, e session.Event) {
 	}
 
 	if e.Tag == "wifi.ap.new" {
-		fmt.Fprintf(output, "[%s] [%s] wifi access point %s%s detected as %s%s.\n",
+		where := ""
+		if fix := ap.Geo.Last(); fix != nil {
+			where = fmt.Sprintf(" at %f,%f", fix.Latitude, fix.Longitude)
+		}
+		fmt.Fprintf(output, "[%s] [%s] wifi access point %s%s detected as %s%s%s.\n",
 			e.Time.Format(mod.timeFormat),
 			tui.Green(e.Tag),
 			tui.Bold(ap.ESSID()),
 			tui.Dim(tui.Yellow(rssi)),
 			tui.Green(ap.BSSID()),
-			tui.Dim(vend))
+			tui.Dim(vend),
+			tui.Dim(where))
 	} else if e.Tag == "wifi.ap.lost" {
 		fmt.Fprintf(output, "[%s] [%s] wifi access point %s (%s) lost.\n",
 			e.Time.Format(mod.timeFormat),
@@ -97,6 +102,28 @@ func (mod *EventsStream) viewWiFiHandshakeEvent(output io.Writer, e session.Even
 		hand.File)
 }
 
+func (mod *EventsStream) viewWiFiWPSEvent(output io.Writer, e session.Event) {
+	wps := e.Data.(wifi.WPSEvent)
+
+	to := wps.AP
+	if ap, found := mod.Session.WiFi.Get(wps.AP); found {
+		to = fmt.Sprintf("%s (%s)", tui.Bold(ap.ESSID()), tui.Dim(ap.BSSID()))
+	}
+
+	key := ""
+	if wps.Key != "" {
+		key = fmt.Sprintf(" passphrase %s", tui.Red(wps.Key))
+	}
+
+	fmt.Fprintf(output, "[%s] [%s] found WPS PIN %s for %s (%s)%s\n",
+		e.Time.Format(mod.timeFormat),
+		tui.Green(e.Tag),
+		tui.Red(wps.PIN),
+		to,
+		wps.Mode,
+		key)
+}
+
 func (mod *EventsStream) viewWiFiClientEvent(output io.Writer, e session.Event) {
 	ce := e.Data.(wifi.ClientEvent)
 
@@ -141,6 +168,8 @@ func (mod *EventsStream) viewWiFiEvent(output io.Writer, e session.Event) {
 		mod.viewWiFiClientProbeEvent(output, e)
 	} else if e.Tag == "wifi.client.handshake" {
 		mod.viewWiFiHandshakeEvent(output, e)
+	} else if e.Tag == "wifi.wps.pin" {
+		mod.viewWiFiWPSEvent(output, e)
 	} else if e.Tag == "wifi.client.new" || e.Tag == "wifi.client.lost" {
 		mod.viewWiFiClientEvent(output, e)
 	} else {
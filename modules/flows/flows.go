@@ -0,0 +1,163 @@
+package flows
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/bettercap/bettercap/session"
+)
+
+type FlowsModule struct {
+	session.SessionModule
+
+	activeTimeout   time.Duration
+	inactiveTimeout time.Duration
+	exporter        *flowExporter
+}
+
+func NewFlowsModule(s *session.Session) *FlowsModule {
+	mod := &FlowsModule{
+		SessionModule: session.NewSessionModule("flows", s),
+	}
+
+	mod.AddParam(session.NewIntParameter("flows.timeout.active",
+		"120",
+		"Number of seconds after which a long lasting flow is expired and exported, even if still active."))
+
+	mod.AddParam(session.NewIntParameter("flows.timeout.inactive",
+		"15",
+		"Number of seconds without packets after which a flow is expired and exported."))
+
+	mod.AddParam(session.NewStringParameter("flows.export.format",
+		"netflow9",
+		"^(netflow9|ipfix)$",
+		"Format of the exported flows, netflow9 or ipfix."))
+
+	mod.AddParam(session.NewStringParameter("flows.export.address",
+		"",
+		"",
+		"If set, the expired flows will be sent via UDP to this collector, as HOST or HOST:PORT (default port 2055 for netflow9 and 4739 for ipfix)."))
+
+	mod.AddParam(session.NewStringParameter("flows.export.file",
+		"",
+		"",
+		"If set, the exported flows will be saved to this pcap file, wrapped in UDP packets, for offline testing."))
+
+	mod.AddParam(session.NewIntParameter("flows.show.limit",
+		"25",
+		"Maximum number of flows to show, sorted by bytes, 0 for all of them."))
+
+	mod.AddHandler(session.NewModuleHandler("flows on", "",
+		"Start tracking the traffic as flows and exporting the expired ones.",
+		func(args []string) error {
+			return mod.Start()
+		}))
+
+	mod.AddHandler(session.NewModuleHandler("flows off", "",
+		"Stop tracking the traffic as flows, exporting the current ones.",
+		func(args []string) error {
+			return mod.Stop()
+		}))
+
+	mod.AddHandler(session.NewModuleHandler("flows.show", "",
+		"Show the current flows.",
+		func(args []string) error {
+			return mod.Show()
+		}))
+
+	return mod
+}
+
+func (mod *FlowsModule) Name() string {
+	return "flows"
+}
+
+func (mod *FlowsModule) Description() string {
+	return "Account for the traffic as flows and export them as NetFlow v9 or IPFIX."
+}
+
+func (mod *FlowsModule) Author() string {
+	return "Simone Margaritelli <evilsocket@gmail.com>"
+}
+
+func (mod *FlowsModule) Configure() (err error) {
+	var active, inactive int
+	var format, address, fileName string
+
+	if mod.Running() {
+		return session.ErrAlreadyStarted(mod.Name())
+	} else if mod.Session.Queue == nil {
+		return fmt.Errorf("the packets queue is not available")
+	} else if err, active = mod.IntParam("flows.timeout.active"); err != nil {
+		return err
+	} else if err, inactive = mod.IntParam("flows.timeout.inactive"); err != nil {
+		return err
+	} else if active <= 0 || inactive <= 0 {
+		return fmt.Errorf("flows.timeout.active and flows.timeout.inactive must be greater than 0")
+	} else if err, format = mod.StringParam("flows.export.format"); err != nil {
+		return err
+	} else if err, address = mod.StringParam("flows.export.address"); err != nil {
+		return err
+	} else if err, fileName = mod.StringParam("flows.export.file"); err != nil {
+		return err
+	}
+
+	mod.activeTimeout = time.Duration(active) * time.Second
+	mod.inactiveTimeout = time.Duration(inactive) * time.Second
+
+	mod.exporter = nil
+	if address != "" || fileName != "" {
+		if mod.exporter, err = newFlowExporter(mod.Session.Interface, format, address, fileName); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (mod *FlowsModule) Start() error {
+	if err := mod.Configure(); err != nil {
+		return err
+	}
+
+	mod.Session.Queue.Flows.Enable(true)
+
+	return mod.SetRunning(true, func() {
+		if mod.exporter != nil {
+			mod.Info("tracking flows, exporting them to %s", mod.exporter)
+		} else {
+			mod.Info("tracking flows")
+		}
+
+		tick := time.NewTicker(time.Second)
+		defer tick.Stop()
+
+		for now := range tick.C {
+			if !mod.Running() {
+				break
+			}
+
+			expired := mod.Session.Queue.Flows.Expire(now, mod.activeTimeout, mod.inactiveTimeout)
+			if len(expired) > 0 && mod.exporter != nil {
+				if err := mod.exporter.Export(expired); err != nil {
+					mod.Error("error exporting flows: %v", err)
+				}
+			}
+		}
+	})
+}
+
+func (mod *FlowsModule) Stop() error {
+	return mod.SetRunning(false, func() {
+		flows := mod.Session.Queue.Flows.Flush()
+		mod.Session.Queue.Flows.Enable(false)
+
+		if mod.exporter != nil {
+			if err := mod.exporter.Export(flows); err != nil {
+				mod.Error("error exporting flows: %v", err)
+			}
+			mod.exporter.Close()
+			mod.Info("%d flows exported to %s", mod.exporter.Exported(), mod.exporter)
+		}
+	})
+}
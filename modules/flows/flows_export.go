@@ -0,0 +1,216 @@
+package flows
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bettercap/bettercap/network"
+	"github.com/bettercap/bettercap/packets"
+
+	"github.com/evilsocket/islazy/fs"
+	"github.com/gopacket/gopacket"
+	"github.com/gopacket/gopacket/layers"
+	"github.com/gopacket/gopacket/pcapgo"
+)
+
+const (
+	// keeps every message, templates included, below the ethernet MTU
+	flowsPerMessage = 15
+	// collectors forget templates, so send them again every now and then
+	flowTemplatesRefresh = time.Duration(1) * time.Minute
+)
+
+type flowExporter struct {
+	sync.Mutex
+
+	iface    *network.Endpoint
+	ipfix    bool
+	address  string
+	fileName string
+	conn     net.Conn
+	fp       *os.File
+	writer   *pcapgo.Writer
+
+	bootTime      time.Time
+	sourceID      uint32
+	messages      uint32
+	records       uint32
+	lastTemplates time.Time
+}
+
+func newFlowExporter(iface *network.Endpoint, format string, address string, fileName string) (exp *flowExporter, err error) {
+	exp = &flowExporter{
+		iface:    iface,
+		ipfix:    format == "ipfix",
+		bootTime: time.Now(),
+	}
+
+	if ip := iface.IP.To4(); ip != nil {
+		exp.sourceID = binary.BigEndian.Uint32(ip)
+	}
+
+	if address != "" {
+		if _, _, err := net.SplitHostPort(address); err != nil {
+			address = net.JoinHostPort(address, strconv.Itoa(exp.port()))
+		}
+		if exp.conn, err = net.Dial("udp", address); err != nil {
+			return nil, err
+		}
+		exp.address = address
+	}
+
+	if fileName != "" {
+		if exp.fileName, err = fs.Expand(fileName); err != nil {
+			exp.Close()
+			return nil, err
+		}
+		if exp.fp, err = os.Create(exp.fileName); err != nil {
+			exp.Close()
+			return nil, err
+		}
+		exp.writer = pcapgo.NewWriter(exp.fp)
+		if err = exp.writer.WriteFileHeader(65536, layers.LinkTypeEthernet); err != nil {
+			exp.Close()
+			return nil, err
+		}
+	}
+
+	return exp, nil
+}
+
+func (exp *flowExporter) port() int {
+	if exp.ipfix {
+		return packets.IPFIXPort
+	}
+	return packets.NetFlowPort
+}
+
+func (exp *flowExporter) String() string {
+	format := "NetFlow v9"
+	if exp.ipfix {
+		format = "IPFIX"
+	}
+
+	targets := []string{}
+	if exp.address != "" {
+		targets = append(targets, exp.address)
+	}
+	if exp.fileName != "" {
+		targets = append(targets, exp.fileName)
+	}
+	return fmt.Sprintf("%s (%s)", strings.Join(targets, " and "), format)
+}
+
+func (exp *flowExporter) Exported() uint32 {
+	exp.Lock()
+	defer exp.Unlock()
+	return exp.records
+}
+
+// Export encodes the flows in messages of at most flowsPerMessage records
+// and sends them to the collector and/or to the pcap file.
+func (exp *flowExporter) Export(flows []*packets.Flow) error {
+	exp.Lock()
+	defer exp.Unlock()
+
+	for len(flows) > 0 {
+		batch := flows
+		if len(batch) > flowsPerMessage {
+			batch = batch[:flowsPerMessage]
+		}
+		flows = flows[len(batch):]
+
+		now := time.Now()
+		withTemplates := now.Sub(exp.lastTemplates) >= flowTemplatesRefresh
+		if withTemplates {
+			exp.lastTemplates = now
+		}
+
+		var msg []byte
+		if exp.ipfix {
+			msg = packets.NewIPFIX(batch, withTemplates, now, exp.records, exp.sourceID)
+		} else {
+			msg = packets.NewNetFlowV9(batch, withTemplates, exp.bootTime, now, exp.messages, exp.sourceID)
+		}
+
+		exp.messages++
+		exp.records += uint32(len(batch))
+
+		if err := exp.write(now, msg); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (exp *flowExporter) write(now time.Time, msg []byte) error {
+	if exp.conn != nil {
+		if _, err := exp.conn.Write(msg); err != nil {
+			return err
+		}
+	}
+
+	if exp.writer != nil {
+		err, raw := exp.wrap(msg)
+		if err != nil {
+			return err
+		}
+		info := gopacket.CaptureInfo{
+			Timestamp:     now,
+			CaptureLength: len(raw),
+			Length:        len(raw),
+		}
+		if err = exp.writer.WritePacket(info, raw); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// wrap encapsulates the message in a UDP packet from the interface to
+// itself, so that it can be decoded by wireshark or by a collector
+// replaying the pcap.
+func (exp *flowExporter) wrap(msg []byte) (error, []byte) {
+	eth := layers.Ethernet{
+		SrcMAC:       exp.iface.HW,
+		DstMAC:       exp.iface.HW,
+		EthernetType: layers.EthernetTypeIPv4,
+	}
+	ip4 := layers.IPv4{
+		Version:  4,
+		TTL:      64,
+		Protocol: layers.IPProtocolUDP,
+		SrcIP:    exp.iface.IP,
+		DstIP:    exp.iface.IP,
+	}
+	udp := layers.UDP{
+		SrcPort: layers.UDPPort(exp.port()),
+		DstPort: layers.UDPPort(exp.port()),
+	}
+	udp.SetNetworkLayerForChecksum(&ip4)
+
+	return packets.Serialize(&eth, &ip4, &udp, gopacket.Payload(msg))
+}
+
+func (exp *flowExporter) Close() {
+	exp.Lock()
+	defer exp.Unlock()
+
+	if exp.conn != nil {
+		exp.conn.Close()
+		exp.conn = nil
+	}
+	if exp.fp != nil {
+		exp.fp.Close()
+		exp.fp = nil
+		exp.writer = nil
+	}
+}
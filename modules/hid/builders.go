@@ -1,6 +1,10 @@
 package hid
 
 import (
+	"fmt"
+	"sort"
+	"strings"
+
 	"github.com/bettercap/bettercap/network"
 )
 
@@ -8,27 +12,55 @@ type FrameBuilder interface {
 	BuildFrames(*network.HIDDevice, []*Command) error
 }
 
+// builders for the detected device types
 var FrameBuilders = map[network.HIDType]FrameBuilder{
-	network.HIDTypeLogitech:  LogitechBuilder{},
-	network.HIDTypeAmazon:    AmazonBuilder{},
-	network.HIDTypeMicrosoft: MicrosoftBuilder{},
+	network.HIDTypeLogitech:           LogitechBuilder{},
+	network.HIDTypeAmazon:             AmazonBuilder{},
+	network.HIDTypeMicrosoft:          MicrosoftBuilder{},
+	network.HIDTypeMicrosoftEncrypted: MicrosoftBuilder{Encrypted: true},
+}
+
+// builders that can be selected with hid.force.type
+var namedBuilders = map[string]FrameBuilder{
+	"logitech":      LogitechBuilder{},
+	"amazon":        AmazonBuilder{},
+	"microsoft":     MicrosoftBuilder{},
+	"microsoft-enc": MicrosoftBuilder{Encrypted: true},
 }
 
+// MouseJack affected vendors that can be selected with hid.force.type, but
+// whose frame format is not known, so injection fails with an explicit error
+var unsupportedTypes = []string{"dell", "gigabyte", "hp", "lenovo"}
+
 func availBuilders() []string {
-	return []string{
-		"logitech",
-		"amazon",
-		"microsoft",
+	names := make([]string, 0, len(namedBuilders))
+	for name := range namedBuilders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func builderFromName(name string) (FrameBuilder, error) {
+	if builder, found := namedBuilders[name]; found {
+		return builder, nil
+	}
+	for _, unsupported := range unsupportedTypes {
+		if name == unsupported {
+			return nil, fmt.Errorf("HID frame injection is not implemented for %s devices, their frame format is unknown", name)
+		}
 	}
+	return nil, fmt.Errorf("HID frame injection is not supported for device type '%s', supported types are: %s",
+		name, strings.Join(availBuilders(), ", "))
 }
 
-func builderFromName(name string) FrameBuilder {
-	switch name {
-	case "amazon":
-		return AmazonBuilder{}
-	case "microsoft":
-		return MicrosoftBuilder{}
-	default:
-		return LogitechBuilder{}
+func builderForType(dev *network.HIDDevice) (FrameBuilder, error) {
+	if builder, found := FrameBuilders[dev.Type]; found {
+		return builder, nil
 	}
+	return nil, errNotSupported(dev)
+}
+
+func errNoMouse(builder string) error {
+	return fmt.Errorf("mouse injection is not supported for %s devices", builder)
 }
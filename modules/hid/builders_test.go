@@ -0,0 +1,24 @@
+package hid
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBuilderFromName(t *testing.T) {
+	for _, name := range availBuilders() {
+		if _, err := builderFromName(name); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+	}
+
+	for _, name := range unsupportedTypes {
+		if _, err := builderFromName(name); err == nil || !strings.Contains(err.Error(), "not implemented for "+name) {
+			t.Fatalf("%s: unexpected error %v", name, err)
+		}
+	}
+
+	if _, err := builderFromName("foo"); err == nil || !strings.Contains(err.Error(), "supported types are") {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
@@ -16,10 +16,21 @@ func NewFrame(buf []byte, delay int) Frame {
 	}
 }
 
+// MouseEvent is a relative movement, wheel motion or click of a mouse.
+type MouseEvent struct {
+	Buttons byte
+	X       int
+	Y       int
+	Wheel   int
+}
+
 type Command struct {
-	Mode   byte
-	HID    byte
-	Sleep  int
+	Mode  byte
+	HID   byte
+	Sleep int
+	// keep the keys pressed instead of releasing them right after
+	Hold   bool
+	Mouse  *MouseEvent
 	Frames []Frame
 }
 
@@ -31,7 +42,11 @@ func (cmd *Command) AddFrame(buf []byte, delay int) {
 }
 
 func (cmd Command) IsHID() bool {
-	return cmd.HID != 0 || cmd.Mode != 0
+	return cmd.Mouse == nil && (cmd.HID != 0 || cmd.Mode != 0 || cmd.Hold)
+}
+
+func (cmd Command) IsMouse() bool {
+	return cmd.Mouse != nil
 }
 
 func (cmd Command) IsSleep() bool {
@@ -0,0 +1,79 @@
+package hid
+
+import (
+	"github.com/bettercap/bettercap/network"
+	"github.com/bettercap/bettercap/packets"
+)
+
+// KeyReport is a keyboard HID report decoded from a sniffed payload.
+type KeyReport struct {
+	Mode byte
+	Keys []byte
+}
+
+type FrameDecoder interface {
+	DecodeFrame(*network.HIDDevice, []byte) (*KeyReport, bool)
+}
+
+var FrameDecoders = map[network.HIDType]FrameDecoder{
+	network.HIDTypeLogitech:           LogitechDecoder{},
+	network.HIDTypeAmazon:             AmazonDecoder{},
+	network.HIDTypeMicrosoft:          MicrosoftDecoder{},
+	network.HIDTypeMicrosoftEncrypted: MicrosoftDecoder{Encrypted: true},
+}
+
+func keysOf(buf []byte) []byte {
+	keys := make([]byte, 0)
+	for _, k := range buf {
+		if k != 0 {
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
+
+// LogitechDecoder decodes unencrypted Unifying keystroke frames, encrypted
+// ones (0xd3) can't be decoded without the link key.
+type LogitechDecoder struct {
+}
+
+func (d LogitechDecoder) DecodeFrame(dev *network.HIDDevice, p []byte) (*KeyReport, bool) {
+	if len(p) != 10 || p[1] != packets.HIDLogitechKeystroke || packets.HIDLogitechChecksum(p) != p[9] {
+		return nil, false
+	}
+
+	return &KeyReport{Mode: p[2], Keys: keysOf(p[3:9])}, true
+}
+
+// MicrosoftDecoder decodes keystroke frames, either in clear text or XORed
+// with the device address.
+type MicrosoftDecoder struct {
+	Encrypted bool
+}
+
+func (d MicrosoftDecoder) DecodeFrame(dev *network.HIDDevice, p []byte) (*KeyReport, bool) {
+	if len(p) != 19 {
+		return nil, false
+	} else if d.Encrypted {
+		p = packets.HIDMicrosoftCrypt(dev.RawAddress, p)
+	}
+
+	if p[6] != packets.HIDMicrosoftKeystroke || packets.HIDMicrosoftChecksum(p) != p[18] {
+		return nil, false
+	}
+
+	return &KeyReport{Mode: p[7], Keys: keysOf(p[9:15])}, true
+}
+
+// AmazonDecoder decodes keystroke frames, ending with the mode and key
+// bytes separated by zeros.
+type AmazonDecoder struct {
+}
+
+func (d AmazonDecoder) DecodeFrame(dev *network.HIDDevice, p []byte) (*KeyReport, bool) {
+	sz := len(p)
+	if sz < 6 || p[sz-5] != 0 || p[sz-3] != 0 || p[sz-1] != 0 {
+		return nil, false
+	}
+	return &KeyReport{Mode: p[sz-4], Keys: keysOf(p[sz-2 : sz-1])}, true
+}
@@ -0,0 +1,260 @@
+package hid
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type duckyTokenKind int
+
+const (
+	duckyTokNum duckyTokenKind = iota
+	duckyTokVar
+	duckyTokCall
+	duckyTokOp
+	duckyTokEOF
+)
+
+type duckyToken struct {
+	kind  duckyTokenKind
+	text  string
+	value int
+}
+
+// longest operators first so that "<=" is not parsed as "<" followed by "="
+var duckyOperators = []string{
+	"&&", "||", "==", "!=", "<=", ">=", "<<", ">>",
+	"+", "-", "*", "/", "%", "^", "<", ">", "&", "|", "!", "(", ")",
+}
+
+var duckyPrecedence = map[string]int{
+	"||": 1,
+	"&&": 2,
+	"|":  3,
+	"&":  4,
+	"==": 5, "!=": 5,
+	"<": 6, ">": 6, "<=": 6, ">=": 6,
+	"<<": 7, ">>": 7,
+	"+": 8, "-": 8,
+	"*": 9, "/": 9, "%": 9,
+	"^": 10,
+}
+
+func isDuckyIdentChar(c byte, first bool) bool {
+	if c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') {
+		return true
+	}
+	return !first && c >= '0' && c <= '9'
+}
+
+func duckyTokenize(expr string) ([]duckyToken, error) {
+	tokens := make([]duckyToken, 0)
+	for i := 0; i < len(expr); {
+		c := expr[i]
+		if c == ' ' || c == '\t' {
+			i++
+			continue
+		}
+
+		if c >= '0' && c <= '9' {
+			j := i
+			for j < len(expr) && isDuckyIdentChar(expr[j], false) {
+				j++
+			}
+			v, err := strconv.ParseInt(expr[i:j], 0, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid number '%s'", expr[i:j])
+			}
+			tokens = append(tokens, duckyToken{kind: duckyTokNum, text: expr[i:j], value: int(v)})
+			i = j
+			continue
+		}
+
+		if c == '$' || isDuckyIdentChar(c, true) {
+			j := i + 1
+			for j < len(expr) && isDuckyIdentChar(expr[j], false) {
+				j++
+			}
+			name := expr[i:j]
+			if c == '$' {
+				if len(name) == 1 {
+					return nil, fmt.Errorf("invalid variable name at '%s'", expr[i:])
+				}
+				tokens = append(tokens, duckyToken{kind: duckyTokVar, text: name})
+			} else if name == "TRUE" || name == "FALSE" {
+				tokens = append(tokens, duckyToken{kind: duckyTokNum, text: name, value: boolToInt(name == "TRUE")})
+			} else if strings.HasPrefix(expr[j:], "()") {
+				tokens = append(tokens, duckyToken{kind: duckyTokCall, text: name})
+				j += 2
+			} else {
+				return nil, fmt.Errorf("unexpected identifier '%s'", name)
+			}
+			i = j
+			continue
+		}
+
+		found := false
+		for _, op := range duckyOperators {
+			if strings.HasPrefix(expr[i:], op) {
+				tokens = append(tokens, duckyToken{kind: duckyTokOp, text: op})
+				i += len(op)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unexpected character '%c'", c)
+		}
+	}
+	return append(tokens, duckyToken{kind: duckyTokEOF}), nil
+}
+
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// duckyExpr is a precedence climbing evaluator for DuckyScript 3.0 integer
+// expressions, where booleans are represented as 0 and 1.
+type duckyExpr struct {
+	script *duckyScript
+	tokens []duckyToken
+	pos    int
+}
+
+func (e *duckyExpr) next() duckyToken {
+	tok := e.tokens[e.pos]
+	if tok.kind != duckyTokEOF {
+		e.pos++
+	}
+	return tok
+}
+
+func (e *duckyExpr) peek() duckyToken {
+	return e.tokens[e.pos]
+}
+
+func (e *duckyExpr) binary(minPrec int) (int, error) {
+	lhs, err := e.unary()
+	if err != nil {
+		return 0, err
+	}
+
+	for {
+		tok := e.peek()
+		prec, isBinary := duckyPrecedence[tok.text]
+		if tok.kind != duckyTokOp || !isBinary || prec < minPrec {
+			return lhs, nil
+		}
+		e.next()
+
+		// exponentiation is right associative
+		nextPrec := prec + 1
+		if tok.text == "^" {
+			nextPrec = prec
+		}
+
+		rhs, err := e.binary(nextPrec)
+		if err != nil {
+			return 0, err
+		} else if lhs, err = duckyApply(tok.text, lhs, rhs); err != nil {
+			return 0, err
+		}
+	}
+}
+
+func (e *duckyExpr) unary() (int, error) {
+	tok := e.next()
+	switch tok.kind {
+	case duckyTokNum:
+		return tok.value, nil
+	case duckyTokVar:
+		if v, found := e.script.getVar(tok.text); found {
+			return v, nil
+		}
+		return 0, fmt.Errorf("undefined variable %s", tok.text)
+	case duckyTokCall:
+		return e.script.call(tok.text)
+	case duckyTokOp:
+		switch tok.text {
+		case "!":
+			v, err := e.unary()
+			return boolToInt(v == 0), err
+		case "-":
+			v, err := e.unary()
+			return -v, err
+		case "(":
+			v, err := e.binary(1)
+			if err != nil {
+				return 0, err
+			} else if e.next().text != ")" {
+				return 0, fmt.Errorf("missing closing parenthesis")
+			}
+			return v, nil
+		}
+	}
+
+	if tok.kind == duckyTokEOF {
+		return 0, fmt.Errorf("unexpected end of expression")
+	}
+	return 0, fmt.Errorf("unexpected '%s'", tok.text)
+}
+
+func duckyApply(op string, a, b int) (int, error) {
+	switch op {
+	case "||":
+		return boolToInt(a != 0 || b != 0), nil
+	case "&&":
+		return boolToInt(a != 0 && b != 0), nil
+	case "|":
+		return a | b, nil
+	case "&":
+		return a & b, nil
+	case "==":
+		return boolToInt(a == b), nil
+	case "!=":
+		return boolToInt(a != b), nil
+	case "<":
+		return boolToInt(a < b), nil
+	case ">":
+		return boolToInt(a > b), nil
+	case "<=":
+		return boolToInt(a <= b), nil
+	case ">=":
+		return boolToInt(a >= b), nil
+	case "<<":
+		return a << uint(b), nil
+	case ">>":
+		return a >> uint(b), nil
+	case "+":
+		return a + b, nil
+	case "-":
+		return a - b, nil
+	case "*":
+		return a * b, nil
+	case "/", "%":
+		if b == 0 {
+			return 0, fmt.Errorf("division by zero")
+		} else if op == "/" {
+			return a / b, nil
+		}
+		return a % b, nil
+	case "^":
+		if b < 0 {
+			return 0, fmt.Errorf("negative exponent")
+		}
+		// exponentiation by squaring
+		r := 1
+		for ; b > 0; b >>= 1 {
+			if b&1 == 1 {
+				r *= a
+			}
+			a *= a
+		}
+		return r, nil
+	}
+	return 0, fmt.Errorf("unknown operator %s", op)
+}
@@ -2,186 +2,730 @@ package hid
 
 import (
 	"fmt"
+	"math/rand"
+	"regexp"
 	"strconv"
 	"strings"
+	"time"
+
+	"github.com/bettercap/bettercap/packets"
 
 	"github.com/evilsocket/islazy/fs"
 )
 
+const (
+	// maximum number of statements executed, to catch infinite loops
+	duckyMaxSteps = 1000000
+	// maximum nesting of function calls
+	duckyMaxDepth = 64
+)
+
+var (
+	duckyModifiers = map[string]byte{
+		"CTRL":    1,
+		"CONTROL": 1,
+		"SHIFT":   2,
+		"ALT":     4,
+		"OPTION":  4,
+		"GUI":     8,
+		"WINDOWS": 8,
+		"COMMAND": 8,
+	}
+
+	duckyKeyAliases = map[string]string{
+		"ESC":        "ESCAPE",
+		"APP":        "ESCAPE",
+		"UPARROW":    "UP",
+		"DOWNARROW":  "DOWN",
+		"LEFTARROW":  "LEFT",
+		"RIGHTARROW": "RIGHT",
+		"BACKSPACE":  "DELETE",
+		"BREAK":      "PAUSE",
+		"PAGE_UP":    "PAGEUP",
+		"PAGE_DOWN":  "PAGEDOWN",
+	}
+
+	duckyMouseButtons = map[string]byte{
+		"LEFT":   packets.HIDMouseLeft,
+		"RIGHT":  packets.HIDMouseRight,
+		"MIDDLE": packets.HIDMouseMiddle,
+	}
+
+	duckyRandomChars = map[string]string{
+		"RANDOM_LOWERCASE_LETTER": "abcdefghijklmnopqrstuvwxyz",
+		"RANDOM_UPPERCASE_LETTER": "ABCDEFGHIJKLMNOPQRSTUVWXYZ",
+		"RANDOM_LETTER":           "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ",
+		"RANDOM_NUMBER":           "0123456789",
+		"RANDOM_SPECIAL":          "!@#$%^&*()",
+		"RANDOM_CHAR":             "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789!@#$%^&*()",
+	}
+
+	duckyAssignParser  = regexp.MustCompile(`^(\$[A-Za-z_][A-Za-z0-9_]*)\s*=\s*(.+)$`)
+	duckyCallParser    = regexp.MustCompile(`^([A-Za-z_][A-Za-z0-9_]*)\(\)$`)
+	duckyFuncDefParser = regexp.MustCompile(`^FUNCTION\s+([A-Za-z_][A-Za-z0-9_]*)\(\)$`)
+	duckyVarRefParser  = regexp.MustCompile(`\$[A-Za-z_][A-Za-z0-9_]*`)
+)
+
 type DuckyParser struct {
 	mod *HIDRecon
 }
 
-func (p DuckyParser) parseLiteral(what string, kmap KeyMap) (*Command, error) {
-	// get reference command from the layout
-	ref, found := kmap[what]
-	if found == false {
-		return nil, fmt.Errorf("can't find '%s' in current keymap", what)
-	}
-	return &Command{
-		HID:  ref.HID,
-		Mode: ref.Mode,
-	}, nil
-}
+// Parse compiles a classic or 3.0 DuckyScript file into a list of commands,
+// control flow, variables and functions are evaluated at this stage.
+func (p DuckyParser) Parse(kmap KeyMap, path string) (cmds []*Command, err error) {
+	lines := []string{}
+	reader := (chan string)(nil)
 
-func (p DuckyParser) parseModifier(line string, kmap KeyMap, modMask byte) (*Command, error) {
-	// get optional key after the modifier
-	ch := ""
-	if idx := strings.IndexRune(line, ' '); idx != -1 {
-		ch = line[idx+1:]
+	if reader, err = fs.LineReader(path); err != nil {
+		return
+	} else {
+		for line := range reader {
+			lines = append(lines, line)
+		}
 	}
-	cmd, err := p.parseLiteral(ch, kmap)
+
+	script, err := newDuckyScript(kmap, lines)
 	if err != nil {
 		return nil, err
 	}
-	// apply modifier mask
-	cmd.Mode |= modMask
-	return cmd, nil
+	return script.run()
+}
+
+type duckyLine struct {
+	num  int
+	text string
+	cmd  string
+	arg  string
+	// content of STRING and STRINGLN blocks
+	block []string
+}
+
+type duckyError struct {
+	line int
+	msg  string
+}
+
+func (e duckyError) Error() string {
+	return fmt.Sprintf("error on line %d: %s", e.line, e.msg)
+}
+
+func (l duckyLine) errorf(format string, args ...interface{}) error {
+	return duckyError{line: l.num, msg: fmt.Sprintf(format, args...)}
 }
 
-func (p DuckyParser) parseNumber(from string) (int, error) {
-	idx := strings.IndexRune(from, ' ')
-	if idx == -1 {
-		return 0, fmt.Errorf("can't parse number from '%s'", from)
+// wrap adds the line number to err, unless it already refers to a line
+// executed by a function call.
+func (l duckyLine) wrap(err error) error {
+	if _, ok := err.(duckyError); ok {
+		return err
 	}
+	return l.errorf("%v", err)
+}
 
-	num, err := strconv.Atoi(from[idx+1:])
-	if err != nil {
-		return 0, fmt.Errorf("can't parse number from '%s': %v", from, err)
+func duckySplit(text string) (string, string) {
+	if idx := strings.IndexAny(text, " \t"); idx != -1 {
+		return text[:idx], text[idx+1:]
 	}
+	return text, ""
+}
 
-	return num, nil
+type duckyDefine struct {
+	name  string
+	value string
 }
 
-func (p DuckyParser) parseString(from string) (string, error) {
-	idx := strings.IndexRune(from, ' ')
-	if idx == -1 {
-		return "", fmt.Errorf("can't parse string from '%s'", from)
+type duckyBlock struct {
+	line    int
+	branch  int
+	members []int
+}
+
+type duckyScript struct {
+	kmap    KeyMap
+	lines   []duckyLine
+	defines []duckyDefine
+	// index of the line ending a WHILE or FUNCTION block, or of the next
+	// branch of an IF block
+	jumps map[int]int
+	// index of the END_IF line for each branch of an IF block
+	ends  map[int]int
+	funcs map[string]int
+	vars  map[string]int
+	rnd   *rand.Rand
+	cmds  []*Command
+
+	defaultDelay int
+	held         byte
+	heldKey      byte
+	last         int
+	steps        int
+	depth        int
+	returning    bool
+	retval       int
+}
+
+func newDuckyScript(kmap KeyMap, source []string) (*duckyScript, error) {
+	s := &duckyScript{
+		kmap:    kmap,
+		lines:   make([]duckyLine, 0),
+		defines: make([]duckyDefine, 0),
+		jumps:   make(map[int]int),
+		ends:    make(map[int]int),
+		funcs:   make(map[string]int),
+		vars: map[string]int{
+			"$_RANDOM_MIN": 0,
+			"$_RANDOM_MAX": 65535,
+		},
+		rnd:  rand.New(rand.NewSource(time.Now().UnixNano())),
+		cmds: make([]*Command, 0),
+		last: -1,
+	}
+
+	if err := s.preprocess(source); err != nil {
+		return nil, err
+	} else if err := s.structure(); err != nil {
+		return nil, err
 	}
-	return from[idx+1:], nil
+
+	return s, nil
 }
 
-func (p DuckyParser) lineIs(line string, tokens ...string) bool {
-	for _, tok := range tokens {
-		if strings.HasPrefix(line, tok) {
-			return true
+// expand replaces the DEFINE constants, longest names first.
+func (s *duckyScript) expand(text string) string {
+	for _, def := range s.defines {
+		text = strings.Replace(text, def.name, def.value, -1)
+	}
+	return text
+}
+
+func (s *duckyScript) define(name, value string) {
+	for i, def := range s.defines {
+		if def.name == name {
+			s.defines[i].value = value
+			return
 		}
 	}
-	return false
+
+	s.defines = append(s.defines, duckyDefine{name, value})
+	for i := len(s.defines) - 1; i > 0 && len(s.defines[i].name) > len(s.defines[i-1].name); i-- {
+		s.defines[i], s.defines[i-1] = s.defines[i-1], s.defines[i]
+	}
 }
 
-func (p DuckyParser) Parse(kmap KeyMap, path string) (cmds []*Command, err error) {
-	lines := []string{}
-	source := []string{}
-	reader := (chan string)(nil)
+// preprocess strips comments, collects constants and string blocks.
+func (s *duckyScript) preprocess(source []string) error {
+	for i := 0; i < len(source); i++ {
+		// trailing spaces are significant for STRING
+		text := strings.TrimLeft(strings.TrimRight(source[i], "\r\n"), " \t")
+		line := duckyLine{num: i + 1}
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
 
-	if reader, err = fs.LineReader(path); err != nil {
-		return
-	} else {
-		for line := range reader {
-			lines = append(lines, line)
+		cmd, arg := duckySplit(text)
+		if cmd == "REM" {
+			continue
+		} else if cmd == "REM_BLOCK" {
+			for i++; i < len(source) && strings.TrimSpace(source[i]) != "END_REM"; i++ {
+			}
+			if i == len(source) {
+				return line.errorf("REM_BLOCK without END_REM")
+			}
+			continue
+		} else if cmd == "DEFINE" {
+			name, value := duckySplit(strings.TrimSpace(arg))
+			if name == "" {
+				return line.errorf("DEFINE requires a name")
+			}
+			s.define(name, strings.TrimSpace(value))
+			continue
 		}
-	}
 
-	// preprocessing
-	for lineno, line := range lines {
-		if p.lineIs(line, "REPEAT") {
-			if lineno == 0 {
-				err = fmt.Errorf("error on line %d: REPEAT instruction at the beginning of the script", lineno+1)
-				return
+		line.text = s.expand(text)
+		line.cmd, line.arg = duckySplit(line.text)
+
+		if (line.cmd == "STRING" || line.cmd == "STRINGLN") && strings.TrimSpace(line.arg) == "" {
+			// multi line block if terminated, otherwise a classic empty string
+			end := "END_" + line.cmd
+			j := i + 1
+			for ; j < len(source) && strings.TrimSpace(source[j]) != end; j++ {
 			}
-			times := 1
-			times, err = p.parseNumber(line)
-			if err != nil {
-				return
+			if j < len(source) {
+				line.block = make([]string, 0)
+				for i++; i < j; i++ {
+					line.block = append(line.block, s.expand(strings.TrimLeft(strings.TrimRight(source[i], "\r\n"), " \t")))
+				}
 			}
+		} else if line.cmd != "STRING" && line.cmd != "STR" && line.cmd != "STRINGLN" {
+			line.text = strings.TrimSpace(line.text)
+			line.arg = strings.TrimSpace(line.arg)
+		}
 
-			for i := 0; i < times; i++ {
-				source = append(source, lines[lineno-1])
+		s.lines = append(s.lines, line)
+	}
+	return nil
+}
+
+// structure matches the IF, WHILE and FUNCTION blocks with their ends.
+func (s *duckyScript) structure() error {
+	stack := make([]*duckyBlock, 0)
+	top := func(cmd string) *duckyBlock {
+		if len(stack) == 0 || s.lines[stack[len(stack)-1].line].cmd != cmd {
+			return nil
+		}
+		return stack[len(stack)-1]
+	}
+
+	for i, line := range s.lines {
+		switch line.cmd {
+		case "IF", "WHILE":
+			stack = append(stack, &duckyBlock{line: i, branch: i, members: []int{i}})
+		case "FUNCTION":
+			m := duckyFuncDefParser.FindStringSubmatch(line.text)
+			if m == nil {
+				return line.errorf("invalid function definition '%s'", line.text)
+			} else if len(stack) > 0 {
+				return line.errorf("functions can't be defined inside other blocks")
+			} else if _, found := s.funcs[m[1]]; found {
+				return line.errorf("function %s already defined", m[1])
 			}
-		} else {
-			source = append(source, line)
+			s.funcs[m[1]] = i
+			stack = append(stack, &duckyBlock{line: i})
+		case "ELSE":
+			b := top("IF")
+			if b == nil {
+				return line.errorf("ELSE without IF")
+			}
+			s.jumps[b.branch] = i
+			b.branch = i
+			b.members = append(b.members, i)
+		case "END_IF":
+			b := top("IF")
+			if b == nil {
+				return line.errorf("END_IF without IF")
+			}
+			s.jumps[b.branch] = i
+			for _, m := range b.members {
+				s.ends[m] = i
+			}
+			stack = stack[:len(stack)-1]
+		case "END_WHILE", "END_FUNCTION":
+			b := top(strings.TrimPrefix(line.cmd, "END_"))
+			if b == nil {
+				return line.errorf("%s without %s", line.cmd, strings.TrimPrefix(line.cmd, "END_"))
+			}
+			s.jumps[b.line] = i
+			stack = stack[:len(stack)-1]
 		}
 	}
 
-	cmds = make([]*Command, 0)
-	for _, line := range source {
-		cmd := &Command{}
-		if p.lineIs(line, "CTRL", "CONTROL") {
-			if cmd, err = p.parseModifier(line, kmap, 1); err != nil {
-				return
+	if len(stack) > 0 {
+		line := s.lines[stack[len(stack)-1].line]
+		return line.errorf("%s without END_%s", line.cmd, line.cmd)
+	}
+	return nil
+}
+
+func (s *duckyScript) run() ([]*Command, error) {
+	if err := s.exec(0, len(s.lines)); err != nil {
+		return nil, err
+	}
+	// don't leave keys pressed on the target
+	if s.held != 0 || s.heldKey != 0 {
+		s.emit(&Command{Hold: true})
+	}
+	return s.cmds, nil
+}
+
+func (s *duckyScript) step(line duckyLine) error {
+	if s.steps++; s.steps > duckyMaxSteps {
+		return line.errorf("too many instructions executed, infinite loop?")
+	}
+	return nil
+}
+
+func (s *duckyScript) emit(cmd *Command) {
+	s.cmds = append(s.cmds, cmd)
+}
+
+// exec executes the lines in the [from, to) range.
+func (s *duckyScript) exec(from, to int) error {
+	for pc := from; pc < to && !s.returning; pc++ {
+		line := s.lines[pc]
+		if err := s.step(line); err != nil {
+			return err
+		}
+
+		switch line.cmd {
+		case "IF":
+			end, err := s.execIf(pc)
+			if err != nil {
+				return err
 			}
-		} else if p.lineIs(line, "SHIFT") {
-			if cmd, err = p.parseModifier(line, kmap, 2); err != nil {
-				return
+			pc = end
+		case "WHILE":
+			end := s.jumps[pc]
+			for !s.returning {
+				if ok, err := s.condition(line, line.arg); err != nil {
+					return err
+				} else if !ok {
+					break
+				} else if err = s.exec(pc+1, end); err != nil {
+					return err
+				} else if err = s.step(line); err != nil {
+					return err
+				}
 			}
-		} else if p.lineIs(line, "ALT") {
-			if cmd, err = p.parseModifier(line, kmap, 4); err != nil {
-				return
+			pc = end
+		case "FUNCTION":
+			pc = s.jumps[pc]
+		case "RETURN":
+			if line.arg != "" {
+				v, err := s.eval(line, line.arg)
+				if err != nil {
+					return err
+				}
+				s.retval = v
 			}
-		} else if p.lineIs(line, "GUI", "WINDOWS", "COMMAND") {
-			if cmd, err = p.parseModifier(line, kmap, 8); err != nil {
-				return
+			s.returning = true
+		case "REPEAT":
+			if s.last == -1 {
+				return line.errorf("REPEAT without a previous instruction")
 			}
-		} else if p.lineIs(line, "CTRL-ALT", "CONTROL-ALT") {
-			if cmd, err = p.parseModifier(line, kmap, 4|1); err != nil {
-				return
+			times, err := s.eval(line, line.arg)
+			if err != nil {
+				return err
+			}
+			for i := 0; i < times; i++ {
+				if err := s.step(line); err != nil {
+					return err
+				} else if err := s.statement(s.lines[s.last]); err != nil {
+					return err
+				}
 			}
-		} else if p.lineIs(line, "CTRL-SHIFT", "CONTROL-SHIFT") {
-			if cmd, err = p.parseModifier(line, kmap, 1|2); err != nil {
-				return
+		default:
+			if err := s.statement(line); err != nil {
+				return err
 			}
-		} else if p.lineIs(line, "ESC", "ESCAPE", "APP") {
-			if cmd, err = p.parseLiteral("ESCAPE", kmap); err != nil {
-				return
+			s.last = pc
+		}
+	}
+	return nil
+}
+
+func (s *duckyScript) execIf(pc int) (int, error) {
+	end := s.ends[pc]
+	for b := pc; b != end; b = s.jumps[b] {
+		line := s.lines[b]
+		cond := line.arg
+		if line.cmd == "ELSE" {
+			if kw, arg := duckySplit(line.arg); kw == "IF" {
+				cond = arg
+			} else {
+				return end, s.exec(b+1, s.jumps[b])
 			}
-		} else if p.lineIs(line, "ENTER") {
-			if cmd, err = p.parseLiteral("ENTER", kmap); err != nil {
-				return
+		}
+
+		if ok, err := s.condition(line, cond); err != nil {
+			return end, err
+		} else if ok {
+			return end, s.exec(b+1, s.jumps[b])
+		}
+	}
+	return end, nil
+}
+
+func (s *duckyScript) condition(line duckyLine, cond string) (bool, error) {
+	cond = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(cond), "THEN"))
+	v, err := s.eval(line, cond)
+	return v != 0, err
+}
+
+func (s *duckyScript) eval(line duckyLine, expr string) (int, error) {
+	tokens, err := duckyTokenize(expr)
+	if err != nil {
+		return 0, line.errorf("%v", err)
+	}
+
+	e := &duckyExpr{script: s, tokens: tokens}
+	v, err := e.binary(1)
+	if err != nil {
+		return 0, line.wrap(err)
+	} else if tok := e.peek(); tok.kind != duckyTokEOF {
+		return 0, line.errorf("unexpected '%s' in expression", tok.text)
+	}
+	return v, nil
+}
+
+func (s *duckyScript) getVar(name string) (int, bool) {
+	if name == "$_RANDOM_INT" {
+		min, max := s.vars["$_RANDOM_MIN"], s.vars["$_RANDOM_MAX"]
+		if max < min {
+			min, max = max, min
+		}
+		return min + s.rnd.Intn(max-min+1), true
+	}
+	v, found := s.vars[name]
+	return v, found
+}
+
+func (s *duckyScript) setVar(name string, value int) {
+	if name == "$_RANDOM_SEED" {
+		s.rnd.Seed(int64(value))
+	}
+	s.vars[name] = value
+}
+
+func (s *duckyScript) call(name string) (int, error) {
+	start, found := s.funcs[name]
+	if !found {
+		return 0, fmt.Errorf("undefined function %s", name)
+	} else if s.depth >= duckyMaxDepth {
+		return 0, fmt.Errorf("too many nested calls of %s", name)
+	}
+
+	s.depth++
+	err := s.exec(start+1, s.jumps[start])
+	s.depth--
+
+	ret := s.retval
+	s.returning = false
+	s.retval = 0
+
+	return ret, err
+}
+
+// statement executes a line which is not a control flow instruction.
+func (s *duckyScript) statement(line duckyLine) error {
+	switch line.cmd {
+	case "STRING", "STR", "STRINGLN":
+		str := line.arg
+		if line.block != nil {
+			sep := ""
+			if line.cmd == "STRINGLN" {
+				sep = "\n"
+			}
+			str = strings.Join(line.block, sep)
+		}
+		if err := s.typeString(line, s.interpolate(str)); err != nil {
+			return err
+		} else if line.cmd == "STRINGLN" {
+			if err := s.pressKeys(line, "ENTER"); err != nil {
+				return err
+			}
+		}
+
+	case "DELAY", "SLEEP":
+		ms, err := s.eval(line, line.arg)
+		if err != nil {
+			return err
+		}
+		s.emit(&Command{Sleep: ms})
+		return nil
+
+	case "DEFAULT_DELAY", "DEFAULTDELAY":
+		ms, err := s.eval(line, line.arg)
+		if err != nil {
+			return err
+		}
+		s.defaultDelay = ms
+		return nil
+
+	case "VAR":
+		m := duckyAssignParser.FindStringSubmatch(line.arg)
+		if m == nil {
+			return line.errorf("invalid variable declaration '%s'", line.arg)
+		}
+		v, err := s.eval(line, m[2])
+		if err != nil {
+			return err
+		}
+		s.setVar(m[1], v)
+		return nil
+
+	case "HOLD":
+		cmd, err := s.parseKeys(line.arg)
+		if err != nil {
+			return line.errorf("%v", err)
+		}
+		s.held |= cmd.Mode
+		if cmd.HID != 0 {
+			s.heldKey = cmd.HID
+		}
+		s.emit(&Command{Mode: s.held, HID: s.heldKey, Hold: true})
+
+	case "RELEASE":
+		if line.arg == "" {
+			s.held, s.heldKey = 0, 0
+		} else {
+			cmd, err := s.parseKeys(line.arg)
+			if err != nil {
+				return line.errorf("%v", err)
 			}
-		} else if p.lineIs(line, "UP", "UPARROW") {
-			if cmd, err = p.parseLiteral("UP", kmap); err != nil {
-				return
+			s.held &^= cmd.Mode
+			if cmd.HID == s.heldKey {
+				s.heldKey = 0
 			}
-		} else if p.lineIs(line, "DOWN", "DOWNARROW") {
-			if cmd, err = p.parseLiteral("DOWN", kmap); err != nil {
-				return
+		}
+		s.emit(&Command{Mode: s.held, HID: s.heldKey, Hold: true})
+
+	case "MOUSE_MOVE":
+		args := strings.Fields(line.arg)
+		if len(args) != 2 {
+			return line.errorf("MOUSE_MOVE requires the X and Y offsets")
+		}
+		x, err := s.eval(line, args[0])
+		if err != nil {
+			return err
+		}
+		y, err := s.eval(line, args[1])
+		if err != nil {
+			return err
+		}
+		s.emit(&Command{Mouse: &MouseEvent{X: x, Y: y}})
+
+	case "MOUSE_SCROLL":
+		wheel, err := s.eval(line, line.arg)
+		if err != nil {
+			return err
+		}
+		s.emit(&Command{Mouse: &MouseEvent{Wheel: wheel}})
+
+	case "MOUSE_CLICK":
+		buttons := byte(0)
+		for _, name := range strings.Fields(line.arg) {
+			if b, found := duckyMouseButtons[name]; found {
+				buttons |= b
+			} else {
+				return line.errorf("unknown mouse button '%s'", name)
 			}
-		} else if p.lineIs(line, "LEFT", "LEFTARROW") {
-			if cmd, err = p.parseLiteral("LEFT", kmap); err != nil {
-				return
+		}
+		if buttons == 0 {
+			buttons = packets.HIDMouseLeft
+		}
+		s.emit(&Command{Mouse: &MouseEvent{Buttons: buttons}})
+
+	default:
+		if m := duckyAssignParser.FindStringSubmatch(line.text); m != nil {
+			if _, found := s.vars[m[1]]; !found && m[1] != "$_RANDOM_SEED" {
+				return line.errorf("variable %s must be declared with VAR", m[1])
 			}
-		} else if p.lineIs(line, "RIGHT", "RIGHTARROW") {
-			if cmd, err = p.parseLiteral("RIGHT", kmap); err != nil {
-				return
+			v, err := s.eval(line, m[2])
+			if err != nil {
+				return err
 			}
-		} else if p.lineIs(line, "DELAY", "SLEEP") {
-			secs := 0
-			if secs, err = p.parseNumber(line); err != nil {
-				return
+			s.setVar(m[1], v)
+			return nil
+		} else if m := duckyCallParser.FindStringSubmatch(line.text); m != nil {
+			if _, err := s.call(m[1]); err != nil {
+				return line.wrap(err)
 			}
-			cmd = &Command{Sleep: secs}
-		} else if p.lineIs(line, "STRING", "STR") {
-			str := ""
-			if str, err = p.parseString(line); err != nil {
-				return
+			return nil
+		} else if chars, found := duckyRandomChars[line.text]; found {
+			if err := s.typeString(line, string(chars[s.rnd.Intn(len(chars))])); err != nil {
+				return err
 			}
+		} else if err := s.pressKeys(line, line.text); err != nil {
+			return err
+		}
+	}
+
+	if s.defaultDelay > 0 {
+		s.emit(&Command{Sleep: s.defaultDelay})
+	}
+	return nil
+}
 
-			for _, c := range str {
-				if cmd, err = p.parseLiteral(string(c), kmap); err != nil {
-					return
+// interpolate replaces the references to declared variables with their values,
+// anything else starting with $ is typed as is.
+func (s *duckyScript) interpolate(str string) string {
+	return duckyVarRefParser.ReplaceAllStringFunc(str, func(ref string) string {
+		if v, found := s.getVar(ref); found {
+			return strconv.Itoa(v)
+		}
+		return ref
+	})
+}
+
+func (s *duckyScript) parseLiteral(what string) (*Command, error) {
+	// get reference command from the layout
+	ref, found := s.kmap[what]
+	if found == false {
+		return nil, fmt.Errorf("can't find '%s' in current keymap", what)
+	}
+	return &Command{
+		HID:  ref.HID,
+		Mode: ref.Mode,
+	}, nil
+}
+
+func (s *duckyScript) typeString(line duckyLine, str string) error {
+	for _, c := range str {
+		what := string(c)
+		if c == '\n' {
+			what = "ENTER"
+		} else if c == '\t' {
+			what = "TAB"
+		}
+		cmd, err := s.parseLiteral(what)
+		if err != nil {
+			return line.errorf("%v", err)
+		}
+		cmd.Mode |= s.held
+		s.emit(cmd)
+	}
+	return nil
+}
+
+// parseKeys parses a key combination like "CTRL ALT DELETE", "CTRL-SHIFT ESC"
+// or "GUI r" into a single command, without the modifiers being held.
+func (s *duckyScript) parseKeys(combo string) (*Command, error) {
+	mode := byte(0)
+	key := ""
+	for _, tok := range strings.Fields(combo) {
+		if m, found := duckyModifiers[tok]; found {
+			mode |= m
+			continue
+		} else if parts := strings.Split(tok, "-"); len(parts) > 1 {
+			mask := byte(0)
+			for _, part := range parts {
+				if m, found := duckyModifiers[part]; found {
+					mask |= m
+				} else {
+					mask = 0
+					break
 				}
-				cmds = append(cmds, cmd)
 			}
+			if mask != 0 {
+				mode |= mask
+				continue
+			}
+		}
 
-			continue
-		} else if cmd, err = p.parseLiteral(line, kmap); err != nil {
-			err = fmt.Errorf("error parsing '%s': %s", line, err)
-			return
+		if key != "" {
+			return nil, fmt.Errorf("unknown command or key combination '%s'", combo)
 		}
+		key = tok
+	}
 
-		cmds = append(cmds, cmd)
+	if alias, found := duckyKeyAliases[key]; found {
+		key = alias
 	}
 
-	return
+	cmd, err := s.parseLiteral(key)
+	if err != nil {
+		return nil, err
+	}
+	cmd.Mode |= mode
+	return cmd, nil
+}
+
+func (s *duckyScript) pressKeys(line duckyLine, combo string) error {
+	cmd, err := s.parseKeys(combo)
+	if err != nil {
+		return line.errorf("%v", err)
+	}
+	cmd.Mode |= s.held
+	s.emit(cmd)
+	return nil
 }
@@ -0,0 +1,263 @@
+package hid
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bettercap/bettercap/packets"
+)
+
+func duckyRun(t *testing.T, source string) ([]*Command, error) {
+	t.Helper()
+	script, err := newDuckyScript(KeyMapFor("US"), strings.Split(source, "\n"))
+	if err != nil {
+		return nil, err
+	}
+	return script.run()
+}
+
+func duckyDump(cmds []*Command) []string {
+	dump := make([]string, 0, len(cmds))
+	for _, cmd := range cmds {
+		if cmd.IsMouse() {
+			dump = append(dump, fmt.Sprintf("mouse %d %d,%d %d", cmd.Mouse.Buttons, cmd.Mouse.X, cmd.Mouse.Y, cmd.Mouse.Wheel))
+		} else if cmd.IsSleep() {
+			dump = append(dump, fmt.Sprintf("sleep %d", cmd.Sleep))
+		} else if cmd.Hold {
+			dump = append(dump, fmt.Sprintf("hold %d:%d", cmd.Mode, cmd.HID))
+		} else {
+			dump = append(dump, fmt.Sprintf("key %d:%d", cmd.Mode, cmd.HID))
+		}
+	}
+	return dump
+}
+
+// typed returns the dump of the commands typing str with the US layout.
+func typed(str string) []string {
+	kmap := KeyMapFor("US")
+	dump := make([]string, 0, len(str))
+	for _, c := range str {
+		what := string(c)
+		if c == '\n' {
+			what = "ENTER"
+		}
+		cmd := kmap[what]
+		dump = append(dump, fmt.Sprintf("key %d:%d", cmd.Mode, cmd.HID))
+	}
+	return dump
+}
+
+func join(parts ...[]string) []string {
+	all := []string{}
+	for _, part := range parts {
+		all = append(all, part...)
+	}
+	return all
+}
+
+func TestDuckyExpressions(t *testing.T) {
+	script, err := newDuckyScript(KeyMapFor("US"), []string{"VAR $a = 6"})
+	if err != nil {
+		t.Fatal(err)
+	} else if _, err = script.run(); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		expr string
+		exp  int
+	}{
+		{"1 + 2 * 3", 7},
+		{"(1 + 2) * 3", 9},
+		{"2 ^ 3 ^ 2", 512},
+		{"7 / 2", 3},
+		{"7 % 3", 1},
+		{"1 << 4 >> 2", 4},
+		{"0x10 | 1", 17},
+		{"6 & 3", 2},
+		{"-3 + 1", -2},
+		{"!0", 1},
+		{"!($a == 6)", 0},
+		{"1 < 2 && 2 < 1", 0},
+		{"1 > 2 || 2 >= 2", 1},
+		{"TRUE != FALSE", 1},
+		{"$a * $a - 1 <= 35", 1},
+	}
+	for _, c := range cases {
+		if got, err := script.eval(duckyLine{num: 1}, c.expr); err != nil {
+			t.Fatalf("%s: %v", c.expr, err)
+		} else if got != c.exp {
+			t.Fatalf("%s: expected %d, got %d", c.expr, c.exp, got)
+		}
+	}
+
+	for _, expr := range []string{"1 / 0", "1 %", "(1 + 2", "$b + 1", "2 ^ -1", "1 2", "foo", "1 # 2"} {
+		if _, err := script.eval(duckyLine{num: 1}, expr); err == nil {
+			t.Fatalf("%s: expected an error", expr)
+		}
+	}
+}
+
+func TestDuckyScripts(t *testing.T) {
+	kmap := KeyMapFor("US")
+	key := func(name string, mode byte) string {
+		cmd := kmap[name]
+		return fmt.Sprintf("key %d:%d", cmd.Mode|mode, cmd.HID)
+	}
+
+	cases := []struct {
+		name   string
+		source string
+		exp    []string
+	}{
+		{"classic", "REM comment\nSTRING hi\nDELAY 100\nGUI r\nCTRL-ALT DELETE",
+			join(typed("hi"), []string{"sleep 100", key("r", 8), key("DELETE", 5)})},
+		{"repeat", "STRING a\nREPEAT 2",
+			typed("aaa")},
+		{"rem block", "REM_BLOCK\nSTRING no\nEND_REM\nSTRING yes",
+			typed("yes")},
+		{"variables", "VAR $a = 2\nVAR $b = $a * 3 + 1\n$a = $b - $a\nSTRING $a $b $c",
+			typed("5 7 $c")},
+		{"if", "VAR $x = 2\nIF ($x == 1) THEN\nSTRING a\nELSE IF ($x == 2) THEN\nSTRING b\nELSE\nSTRING c\nEND_IF\nSTRING d",
+			typed("bd")},
+		{"else", "IF FALSE THEN\nSTRING a\nELSE IF ($_RANDOM_MIN > 0) THEN\nSTRING b\nELSE\nSTRING c\nEND_IF",
+			typed("c")},
+		{"nested if", "VAR $x = 1\nIF $x THEN\nIF ($x > 1) THEN\nSTRING a\nELSE\nSTRING b\nEND_IF\nEND_IF",
+			typed("b")},
+		{"while", "VAR $i = 0\nWHILE ($i < 3)\nSTRING $i\n$i = $i + 1\nEND_WHILE",
+			typed("012")},
+		{"functions", "FUNCTION hello()\nSTRING hi\nEND_FUNCTION\nhello()\nhello()",
+			typed("hihi")},
+		{"return", "FUNCTION twice()\nRETURN $n * 2\nSTRING never\nEND_FUNCTION\nVAR $n = 4\nVAR $r = twice()\nSTRING $r",
+			typed("8")},
+		{"define", "DEFINE #W 1\nDEFINE #WAIT 500\nDELAY #WAIT\nSTRING #W",
+			join([]string{"sleep 500"}, typed("1"))},
+		{"string block", "STRING\nab\n  c\nEND_STRING",
+			typed("abc")},
+		{"stringln block", "STRINGLN\nab\nc\nEND_STRINGLN",
+			typed("ab\nc\n")},
+		{"stringln", "STRINGLN a",
+			typed("a\n")},
+		{"hold", "HOLD SHIFT\nSTRING a\nRELEASE SHIFT\nSTRING a",
+			join([]string{"hold 2:0", key("a", 2), "hold 0:0"}, typed("a"))},
+		{"hold key", "HOLD CTRL a\nRELEASE a\nRELEASE",
+			[]string{fmt.Sprintf("hold 1:%d", kmap["a"].HID), "hold 1:0", "hold 0:0"}},
+		{"unreleased", "HOLD ALT",
+			[]string{"hold 4:0", "hold 0:0"}},
+		{"default delay", "DEFAULT_DELAY 100\nSTRING ab\nVAR $x = 1\nGUI r",
+			join(typed("ab"), []string{"sleep 100", key("r", 8), "sleep 100"})},
+		{"random int", "$_RANDOM_SEED = 1\nVAR $x = 0\n$_RANDOM_MIN = 5\n$_RANDOM_MAX = 5\nSTRING $_RANDOM_INT",
+			typed("5")},
+		{"mouse", "MOUSE_MOVE 10 -5\nMOUSE_SCROLL -1\nMOUSE_CLICK\nMOUSE_CLICK LEFT RIGHT",
+			[]string{"mouse 0 10,-5 0", "mouse 0 0,0 -1", "mouse 1 0,0 0", "mouse 3 0,0 0"}},
+	}
+
+	for _, c := range cases {
+		cmds, err := duckyRun(t, c.source)
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		if got := duckyDump(cmds); strings.Join(got, ", ") != strings.Join(c.exp, ", ") {
+			t.Fatalf("%s: expected\n  %v\ngot\n  %v", c.name, c.exp, got)
+		}
+	}
+}
+
+func TestDuckyRandomChars(t *testing.T) {
+	for name, chars := range duckyRandomChars {
+		allowed := map[string]bool{}
+		for _, dump := range typed(chars) {
+			allowed[dump] = true
+		}
+
+		for i := 0; i < 20; i++ {
+			cmds, err := duckyRun(t, name)
+			if err != nil {
+				t.Fatalf("%s: %v", name, err)
+			} else if len(cmds) != 1 {
+				t.Fatalf("%s: expected one keystroke, got %d", name, len(cmds))
+			} else if dump := duckyDump(cmds)[0]; !allowed[dump] {
+				t.Fatalf("%s: unexpected keystroke %s", name, dump)
+			}
+		}
+	}
+}
+
+func TestDuckyErrors(t *testing.T) {
+	cases := []struct {
+		source string
+		err    string
+	}{
+		{"STRING a\nIF TRUE THEN\nSTRING b", "error on line 2: IF without END_IF"},
+		{"END_IF", "error on line 1: END_IF without IF"},
+		{"ELSE", "error on line 1: ELSE without IF"},
+		{"WHILE TRUE\nEND_IF", "error on line 2: END_IF without IF"},
+		{"REM_BLOCK\nSTRING a", "error on line 1: REM_BLOCK without END_REM"},
+		{"IF TRUE THEN\nFUNCTION f()\nEND_FUNCTION\nEND_IF", "error on line 2: functions can't be defined inside other blocks"},
+		{"FUNCTION f()\nEND_FUNCTION\nFUNCTION f()\nEND_FUNCTION", "error on line 3: function f already defined"},
+		{"$x = 1", "error on line 1: variable $x must be declared with VAR"},
+		{"VAR x = 1", "error on line 1: invalid variable declaration 'x = 1'"},
+		{"REPEAT 3", "error on line 1: REPEAT without a previous instruction"},
+		{"NOTAKEY", "error on line 1: can't find 'NOTAKEY' in current keymap"},
+		{"CTRL a b", "error on line 1: unknown command or key combination 'CTRL a b'"},
+		{"MOUSE_CLICK BACK", "error on line 1: unknown mouse button 'BACK'"},
+		{"undefined()", "error on line 1: undefined function undefined"},
+		// the error refers to the line executed by the function
+		{"FUNCTION f()\nDELAY 1 / 0\nEND_FUNCTION\nf()", "error on line 2: division by zero"},
+		{"FUNCTION f()\nf()\nEND_FUNCTION\nf()", "error on line 2: too many nested calls of f"},
+		{"VAR $i = 0\nWHILE TRUE\n$i = $i + 1\nEND_WHILE", "error on line 3: too many instructions executed, infinite loop?"},
+	}
+
+	for _, c := range cases {
+		started := time.Now()
+		if _, err := duckyRun(t, c.source); err == nil {
+			t.Fatalf("%q: expected an error", c.source)
+		} else if err.Error() != c.err {
+			t.Fatalf("%q: expected '%s', got '%s'", c.source, c.err, err)
+		} else if time.Since(started) > 10*time.Second {
+			t.Fatalf("%q: took %s to fail", c.source, time.Since(started))
+		}
+	}
+}
+
+func TestDuckyLogitechFrames(t *testing.T) {
+	cmds, err := duckyRun(t, "HOLD SHIFT\nSTRING a\nRELEASE\nDELAY 20")
+	if err != nil {
+		t.Fatal(err)
+	} else if err = (LogitechBuilder{}).BuildFrames(nil, cmds); err != nil {
+		t.Fatal(err)
+	}
+
+	a := KeyMapFor("US")["a"].HID
+	keystroke := func(mode byte, keys ...byte) string {
+		_, data := packets.NewHIDLogitechKeystroke(mode, keys...)
+		return fmt.Sprintf("%x/%d", data, 12)
+	}
+	keepAlive := func(delay int) string {
+		return fmt.Sprintf("%x/%d", packets.HIDLogitechKeepAliveData, delay)
+	}
+
+	exp := []string{
+		// HOLD SHIFT
+		fmt.Sprintf("%x/%d", packets.HIDLogitechHelloData, 12), keystroke(2), keepAlive(0),
+		// 'a' with SHIFT held, then the next command holds again
+		keystroke(2, a), keepAlive(0),
+		// RELEASE, followed by a sleep
+		keystroke(0), keepAlive(0),
+		// DELAY 20
+		keepAlive(10), keepAlive(10),
+	}
+
+	got := []string{}
+	for _, cmd := range cmds {
+		for _, frame := range cmd.Frames {
+			got = append(got, fmt.Sprintf("%x/%d", frame.Data, frame.Delay/time.Millisecond))
+		}
+	}
+
+	if strings.Join(got, " ") != strings.Join(exp, " ") {
+		t.Fatalf("expected frames\n  %v\ngot\n  %v", exp, got)
+	}
+}
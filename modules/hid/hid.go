@@ -37,6 +37,7 @@ type HIDRecon struct {
 	keyLayout    string
 	scriptPath   string
 	parser       DuckyParser
+	keylog       *keyLogger
 	selector     *utils.ViewSelector
 }
 
@@ -63,6 +64,7 @@ func NewHIDRecon(s *session.Session) *HIDRecon {
 		pingPayload:   []byte{0x0f, 0x0f, 0x0f, 0x0f},
 		keyLayout:     "US",
 		scriptPath:    "",
+		keylog:        newKeyLogger(),
 	}
 
 	mod.State.Store("sniffing", &mod.sniffAddr)
@@ -89,7 +91,7 @@ func NewHIDRecon(s *session.Session) *HIDRecon {
 		}))
 
 	sniff := session.NewModuleHandler("hid.sniff ADDRESS", `(?i)^hid\.sniff ([a-f0-9]{2}:[a-f0-9]{2}:[a-f0-9]{2}:[a-f0-9]{2}:[a-f0-9]{2}|clear)$`,
-		"Start sniffing a specific ADDRESS in order to collect payloads, use 'clear' to stop collecting.",
+		"Start sniffing a specific ADDRESS in order to collect payloads and decode keystrokes, use 'clear' to stop collecting.",
 		func(args []string) error {
 			return mod.setSniffMode(args[0], false)
 		})
@@ -139,11 +141,21 @@ func NewHIDRecon(s *session.Session) *HIDRecon {
 		"500",
 		"Time in milliseconds to automatically sniff payloads from a device, once it's detected, in order to determine its type."))
 
+	mod.AddParam(session.NewStringParameter("hid.sniff.layout",
+		"US",
+		"",
+		"Keyboard layout used to decode the keystrokes sniffed with hid.sniff."))
+
+	mod.AddParam(session.NewStringParameter("hid.sniff.keylog",
+		"",
+		"",
+		"If not empty, folder where the keystrokes sniffed from each device will be logged as plain text."))
+
 	builders := availBuilders()
 
 	mod.AddParam(session.NewStringParameter("hid.force.type",
 		"logitech",
-		fmt.Sprintf("(%s)", strings.Join(builders, "|")),
+		fmt.Sprintf("(%s)", strings.Join(append(builders, unsupportedTypes...), "|")),
 		fmt.Sprintf("If the device is not visible or its type has not being detected, force the device type to this value. Accepted values: %s", strings.Join(builders, ", "))))
 
 	mod.parser = DuckyParser{mod}
@@ -225,6 +237,7 @@ func (mod *HIDRecon) forceStop() error {
 func (mod *HIDRecon) Stop() error {
 	return mod.SetRunning(false, func() {
 		mod.waitGroup.Wait()
+		mod.keylog.Close()
 		if mod.dongle != nil {
 			mod.dongle.Close()
 			mod.Debug("device closed")
@@ -0,0 +1,9 @@
+package hid
+
+type KeystrokesEvent struct {
+	Address string `json:"address"`
+	Type    string `json:"type"`
+	Keys    string `json:"keys"`
+	// line being typed on the device
+	Line string `json:"line"`
+}
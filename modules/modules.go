@@ -8,11 +8,13 @@ import (
 	"github.com/bettercap/bettercap/modules/ble"
 	"github.com/bettercap/bettercap/modules/c2"
 	"github.com/bettercap/bettercap/modules/caplets"
+	"github.com/bettercap/bettercap/modules/creds"
 
 	//"github.com/bettercap/bettercap/modules/dhcp4_sniff"
 	"github.com/bettercap/bettercap/modules/dhcp6_spoof"
 	"github.com/bettercap/bettercap/modules/dns_spoof"
 	"github.com/bettercap/bettercap/modules/events_stream"
+	"github.com/bettercap/bettercap/modules/flows"
 	"github.com/bettercap/bettercap/modules/gps"
 	"github.com/bettercap/bettercap/modules/hid"
 	"github.com/bettercap/bettercap/modules/http_proxy"
@@ -70,6 +72,8 @@ func LoadModules(sess *session.Session) {
 	sess.Register(ndp_spoof.NewNDPSpoofer(sess))
 
 	sess.Register(caplets.NewCapletsModule(sess))
+	sess.Register(creds.NewCredsModule(sess))
+	sess.Register(flows.NewFlowsModule(sess))
 	sess.Register(update.NewUpdateModule(sess))
 	sess.Register(ui.NewUIModule(sess))
 }
@@ -2,7 +2,6 @@ package mysql_server
 
 import (
 	"bufio"
-	"bytes"
 	"fmt"
 	"io/ioutil"
 	"net"
@@ -121,7 +120,7 @@ func (mod *MySQLServer) Start() error {
 				if _, err := conn.Write(packets.MySQLGreeting); err != nil {
 					mod.Warning("error while writing server greeting: %s", err)
 					continue
-				} else if _, err = reader.Read(readBuffer); err != nil {
+				} else if read, err = reader.Read(readBuffer); err != nil {
 					mod.Warning("error while reading client message: %s", err)
 					continue
 				}
@@ -131,11 +130,25 @@ func (mod *MySQLServer) Start() error {
 				//       display additional connection attributes
 				capabilities := fmt.Sprintf("%08b", (int(uint32(readBuffer[4]) | uint32(readBuffer[5])<<8)))
 				loadData := string(capabilities[8])
-				username := string(bytes.Split(readBuffer[36:], []byte{0})[0])
+				username, scramble := packets.MySQLParseLogin(readBuffer[:read])
 
 				mod.Info("can use LOAD DATA LOCAL: %s", loadData)
 				mod.Info("login request username: %s", tui.Bold(username))
 
+				cred := session.Credential{
+					Service:  "mysql",
+					Host:     mod.address.String(),
+					Client:   clientAddress,
+					Username: username,
+					Format:   session.CredentialPlain,
+					Source:   "mysql.server",
+				}
+				if scramble != nil {
+					cred.Secret = packets.MySQLHashcatString(packets.MySQLGreetingSalt(), scramble)
+					cred.Format = session.HashcatFormat(11200)
+				}
+				mod.Session.Credentials.Add(cred)
+
 				if _, err := conn.Write(packets.MySQLFirstResponseOK); err != nil {
 					mod.Warning("error while writing server first response ok: %s", err)
 					continue
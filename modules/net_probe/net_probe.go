@@ -1,32 +1,50 @@
 package net_probe
 
 import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/bettercap/bettercap/network"
+	"github.com/bettercap/bettercap/packets"
 	"github.com/bettercap/bettercap/session"
 
 	"github.com/malfunkt/iprange"
 )
 
 type Probes struct {
-	NBNS bool
-	MDNS bool
-	UPNP bool
-	WSD  bool
+	NBNS  bool
+	MDNS  bool
+	UPNP  bool
+	WSD   bool
+	ARP   bool
+	ICMP  bool
+	ICMP6 bool
+	TCP   bool
 }
 
 type Prober struct {
 	session.SessionModule
 	throttle  int
 	probes    Probes
-	waitGroup *sync.WaitGroup
+	arpRate   int
+	tcpPorts  []int
+	discovery packets.DiscoveryProbes
+	seq       uint16
+	// when the last MLD query was sent, in nanoseconds
+	mldQueried *atomic.Int64
+	stats      *discoveryStats
+	waitGroup  *sync.WaitGroup
 }
 
 func NewProber(s *session.Session) *Prober {
 	mod := &Prober{
 		SessionModule: session.NewSessionModule("net.probe", s),
+		mldQueried:    &atomic.Int64{},
 		waitGroup:     &sync.WaitGroup{},
 	}
 
@@ -48,6 +66,31 @@ func NewProber(s *session.Session) *Prober {
 		"true",
 		"Enable WSD discovery probes."))
 
+	mod.AddParam(session.NewBoolParameter("net.probe.arp",
+		"false",
+		"Enable ARP sweep discovery probes."))
+
+	mod.AddParam(session.NewIntParameter("net.probe.arp.rate",
+		"100",
+		"Number of ARP requests per second of the ARP sweep."))
+
+	mod.AddParam(session.NewBoolParameter("net.probe.icmp",
+		"false",
+		"Enable ICMP echo and timestamp discovery probes."))
+
+	mod.AddParam(session.NewBoolParameter("net.probe.icmp6",
+		"false",
+		"Enable ICMPv6 multicast echo and MLD query discovery probes, to find IPv6 only hosts."))
+
+	mod.AddParam(session.NewBoolParameter("net.probe.tcp",
+		"false",
+		"Enable TCP SYN and ACK discovery probes, for hosts dropping UDP and ICMP."))
+
+	mod.AddParam(session.NewStringParameter("net.probe.tcp.ports",
+		"22,80,443,445,3389",
+		`^(\d+)(\s*,\s*\d+)*$`,
+		"Comma separated list of ports the TCP discovery probes are sent to."))
+
 	mod.AddParam(session.NewIntParameter("net.probe.throttle",
 		"10",
 		"If greater than 0, probe packets will be throttled by this value in milliseconds."))
@@ -64,6 +107,12 @@ func NewProber(s *session.Session) *Prober {
 			return mod.Stop()
 		}))
 
+	mod.AddHandler(session.NewModuleHandler("net.probe stats", "",
+		"Show how many hosts each discovery technique found, and which one found each host.",
+		func(args []string) error {
+			return mod.showStats()
+		}))
+
 	return mod
 }
 
@@ -72,7 +121,7 @@ func (mod Prober) Name() string {
 }
 
 func (mod Prober) Description() string {
-	return "Keep probing for new hosts on the network by sending dummy UDP packets to every possible IP on the subnet."
+	return "Keep probing for new hosts on the network by sending dummy UDP packets to every possible IP on the subnet, optionally with ARP, ICMP, ICMPv6 and TCP probes."
 }
 
 func (mod Prober) Author() string {
@@ -81,6 +130,7 @@ func (mod Prober) Author() string {
 
 func (mod *Prober) Configure() error {
 	var err error
+	var ports string
 	if err, mod.throttle = mod.IntParam("net.probe.throttle"); err != nil {
 		return err
 	} else if err, mod.probes.NBNS = mod.BoolParam("net.probe.nbns"); err != nil {
@@ -91,9 +141,43 @@ func (mod *Prober) Configure() error {
 		return err
 	} else if err, mod.probes.WSD = mod.BoolParam("net.probe.wsd"); err != nil {
 		return err
+	} else if err, mod.probes.ARP = mod.BoolParam("net.probe.arp"); err != nil {
+		return err
+	} else if err, mod.arpRate = mod.IntParam("net.probe.arp.rate"); err != nil {
+		return err
+	} else if mod.arpRate <= 0 {
+		return fmt.Errorf("net.probe.arp.rate must be greater than 0")
+	} else if err, mod.probes.ICMP = mod.BoolParam("net.probe.icmp"); err != nil {
+		return err
+	} else if err, mod.probes.ICMP6 = mod.BoolParam("net.probe.icmp6"); err != nil {
+		return err
+	} else if err, mod.probes.TCP = mod.BoolParam("net.probe.tcp"); err != nil {
+		return err
+	} else if err, ports = mod.StringParam("net.probe.tcp.ports"); err != nil {
+		return err
 	} else {
 		mod.Debug("Throttling packets of %d ms.", mod.throttle)
 	}
+
+	mod.tcpPorts = []int{}
+	for _, s := range strings.Split(ports, ",") {
+		if port, err := strconv.Atoi(strings.TrimSpace(s)); err != nil || port < 1 || port > 65535 {
+			return fmt.Errorf("invalid port %s in net.probe.tcp.ports", s)
+		} else {
+			mod.tcpPorts = append(mod.tcpPorts, port)
+		}
+	}
+
+	// to tell the replies to our probes apart
+	synPort := 40000 + rand.Intn(20000)
+	mod.discovery = packets.DiscoveryProbes{
+		IP:      mod.Session.Interface.IP,
+		ICMPID:  uint16(rand.Intn(0xffff)),
+		SynPort: synPort,
+		AckPort: synPort + 1,
+	}
+	mod.stats = newDiscoveryStats()
+
 	return nil
 }
 
@@ -121,6 +205,10 @@ func (mod *Prober) Start() error {
 			go mod.mdnsProber()
 		}
 
+		if err := mod.startListener(); err != nil {
+			mod.Warning("can't capture the replies, the discovery statistics won't be available: %v", err)
+		}
+
 		fromIP := mod.Session.Interface.IP
 		fromHW := mod.Session.Interface.HW
 		addresses := list.Expand()
@@ -141,15 +229,31 @@ func (mod *Prober) Start() error {
 				mod.sendProbeWSD(fromIP, fromHW)
 			}
 
+			if mod.probes.ICMP6 {
+				mod.sendProbeICMP6(fromHW)
+			}
+
+			if mod.probes.ARP {
+				mod.arpSweep(fromIP, fromHW, addresses)
+			}
+
 			for _, ip := range addresses {
 				if !mod.Running() {
 					return
 				} else if mod.Session.Skip(ip) {
 					mod.Debug("skipping address %s from probing.", ip)
 					continue
-				} else if mod.probes.NBNS {
+				}
+
+				if mod.probes.NBNS {
 					mod.sendProbeNBNS(fromIP, fromHW, ip)
 				}
+				if mod.probes.ICMP {
+					mod.sendProbeICMP(fromIP, fromHW, ip)
+				}
+				if mod.probes.TCP {
+					mod.sendProbeTCP(fromIP, fromHW, ip)
+				}
 				time.Sleep(throttle)
 			}
 
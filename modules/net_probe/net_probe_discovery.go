@@ -0,0 +1,312 @@
+package net_probe
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bettercap/bettercap/network"
+	"github.com/bettercap/bettercap/packets"
+
+	"github.com/gopacket/gopacket"
+
+	"github.com/evilsocket/islazy/tui"
+)
+
+// the order in which the techniques are shown
+var techniques = []string{
+	packets.DiscoveryARP,
+	packets.DiscoveryICMPEcho,
+	packets.DiscoveryICMPTimestamp,
+	packets.DiscoveryICMP6Echo,
+	packets.DiscoveryMLD,
+	packets.DiscoveryTCPSyn,
+	packets.DiscoveryTCPAck,
+	packets.DiscoveryNBNS,
+	packets.DiscoveryMDNS,
+	packets.DiscoveryUPNP,
+	packets.DiscoveryWSD,
+}
+
+var broadcastHW = net.HardwareAddr{0xff, 0xff, 0xff, 0xff, 0xff, 0xff}
+
+type techniqueStats struct {
+	Sent    uint64
+	Replies uint64
+	Hosts   uint64
+}
+
+type discoveredHost struct {
+	IP         string
+	MAC        string
+	FoundBy    string
+	FoundAt    time.Time
+	Techniques map[string]bool
+}
+
+// discoveryStats keeps track of the probes sent by each technique and of
+// the hosts replying to them.
+type discoveryStats struct {
+	sync.Mutex
+	techniques map[string]*techniqueStats
+	hosts      map[string]*discoveredHost
+}
+
+func newDiscoveryStats() *discoveryStats {
+	stats := &discoveryStats{
+		techniques: make(map[string]*techniqueStats),
+		hosts:      make(map[string]*discoveredHost),
+	}
+	for _, t := range techniques {
+		stats.techniques[t] = &techniqueStats{}
+	}
+	return stats
+}
+
+func (s *discoveryStats) OnSent(technique string) {
+	s.Lock()
+	defer s.Unlock()
+	s.techniques[technique].Sent++
+}
+
+// OnReply returns the technique which found the host first.
+func (s *discoveryStats) OnReply(reply packets.DiscoveryReply) string {
+	s.Lock()
+	defer s.Unlock()
+
+	s.techniques[reply.Technique].Replies++
+
+	address := reply.IP.String()
+	host, found := s.hosts[address]
+	if !found {
+		host = &discoveredHost{
+			IP:         address,
+			MAC:        reply.MAC.String(),
+			FoundBy:    reply.Technique,
+			FoundAt:    time.Now(),
+			Techniques: make(map[string]bool),
+		}
+		s.hosts[address] = host
+		s.techniques[reply.Technique].Hosts++
+	}
+	host.Techniques[reply.Technique] = true
+
+	return host.FoundBy
+}
+
+func (mod *Prober) send(technique string, err error, raw []byte) bool {
+	if err != nil {
+		mod.Error("error creating %s probe: %v", technique, err)
+	} else if err = mod.Session.Queue.Send(raw); err != nil {
+		mod.Debug("error sending %s probe: %v", technique, err)
+	} else {
+		mod.stats.OnSent(technique)
+		return true
+	}
+	return false
+}
+
+// hwFor returns the known hardware address of the target, or the broadcast
+// one as for the UDP probes, which the hosts accept anyway.
+func (mod *Prober) hwFor(ip net.IP) net.HardwareAddr {
+	if e := mod.Session.Lan.GetByIp(ip.String()); e != nil && e.HW != nil {
+		return e.HW
+	}
+	return broadcastHW
+}
+
+func (mod *Prober) arpSweep(from net.IP, from_hw net.HardwareAddr, addresses []net.IP) {
+	period := time.Second / time.Duration(mod.arpRate)
+	for _, ip := range addresses {
+		if !mod.Running() {
+			return
+		} else if mod.Session.Skip(ip) {
+			continue
+		}
+		err, raw := packets.NewARPRequest(from, from_hw, ip)
+		mod.send(packets.DiscoveryARP, err, raw)
+		time.Sleep(period)
+	}
+}
+
+func (mod *Prober) sendProbeICMP(from net.IP, from_hw net.HardwareAddr, ip net.IP) {
+	to_hw := mod.hwFor(ip)
+	mod.seq++
+
+	err, raw := packets.NewICMPEcho(from, from_hw, ip, to_hw, mod.discovery.ICMPID, mod.seq)
+	mod.send(packets.DiscoveryICMPEcho, err, raw)
+
+	err, raw = packets.NewICMPTimestamp(from, from_hw, ip, to_hw, mod.discovery.ICMPID, mod.seq)
+	mod.send(packets.DiscoveryICMPTimestamp, err, raw)
+}
+
+func (mod *Prober) sendProbeICMP6(from_hw net.HardwareAddr) {
+	if from := mod.Session.Interface.IPv6; from == nil {
+		mod.Debug("no IPv6 address, skipping ICMPv6 echo probes")
+	} else {
+		mod.seq++
+		err, raw := packets.NewICMP6MulticastEcho(from, from_hw, mod.discovery.ICMPID, mod.seq)
+		mod.send(packets.DiscoveryICMP6Echo, err, raw)
+	}
+
+	if from, err := network.FindLinkLocalIPv6(mod.Session.Interface); err != nil {
+		mod.Debug("%v, skipping MLD query", err)
+	} else if err, raw := packets.NewMLDQuery(from, from_hw); mod.send(packets.DiscoveryMLD, err, raw) {
+		mod.mldQueried.Store(time.Now().UnixNano())
+	}
+}
+
+// answersMLDQuery tells if a report received at the given time can be an
+// answer to the last query.
+func (mod *Prober) answersMLDQuery(at time.Time) bool {
+	queried := mod.mldQueried.Load()
+	if queried == 0 {
+		return false
+	}
+	elapsed := at.Sub(time.Unix(0, queried))
+	return elapsed >= 0 && elapsed <= packets.MLDQueryResponseTime
+}
+
+func (mod *Prober) sendProbeTCP(from net.IP, from_hw net.HardwareAddr, ip net.IP) {
+	to_hw := mod.hwFor(ip)
+	for _, port := range mod.tcpPorts {
+		err, raw := packets.NewTCPSyn(from, from_hw, ip, to_hw, mod.discovery.SynPort, port)
+		mod.send(packets.DiscoveryTCPSyn, err, raw)
+
+		err, raw = packets.NewTCPAck(from, from_hw, ip, to_hw, mod.discovery.AckPort, port)
+		mod.send(packets.DiscoveryTCPAck, err, raw)
+	}
+}
+
+func (mod *Prober) startListener() error {
+	handle, err := network.CaptureWithTimeout(mod.Session.Interface.Name(), 500*time.Millisecond)
+	if err != nil {
+		return err
+	}
+
+	filter := fmt.Sprintf("arp or icmp or icmp6 or (tcp and (dst port %d or dst port %d)) or (udp and (src port %d or src port %d or src port %d or src port %d))",
+		mod.discovery.SynPort,
+		mod.discovery.AckPort,
+		packets.NBNSPort,
+		packets.MDNSPort,
+		packets.UPNPPort,
+		packets.WSDPort)
+	if err = handle.SetBPFFilter(filter); err != nil {
+		handle.Close()
+		return err
+	}
+
+	mod.waitGroup.Add(1)
+	go func() {
+		defer mod.waitGroup.Done()
+		defer handle.Close()
+
+		mod.Debug("discovery listener started")
+		defer mod.Debug("discovery listener stopped")
+
+		src := gopacket.NewPacketSource(handle, handle.LinkType())
+		src.DecodeOptions.NoCopy = true
+		for mod.Running() {
+			pkt, err := src.NextPacket()
+			if err != nil {
+				continue
+			} else if reply, ok := packets.ParseDiscoveryReply(pkt, mod.discovery); !ok {
+				continue
+			} else if reply.Technique != packets.DiscoveryMLD || mod.answersMLDQuery(pkt.Metadata().Timestamp) {
+				mod.onReply(reply)
+			}
+		}
+	}()
+
+	return nil
+}
+
+func (mod *Prober) onReply(reply packets.DiscoveryReply) {
+	iface := mod.Session.Interface
+	if reply.IP == nil || reply.IP.IsUnspecified() || reply.IP.IsMulticast() || mod.Session.Skip(reply.IP) {
+		return
+	} else if reply.MAC.String() == iface.HwAddress || reply.MAC.String() == network.BroadcastMac {
+		return
+	} else if reply.IP.To4() != nil && !iface.Net.Contains(reply.IP) {
+		return
+	}
+
+	foundBy := mod.stats.OnReply(reply)
+
+	// net.recon only adds the IPv4 hosts
+	mac := network.NormalizeMac(reply.MAC.String())
+	if reply.IP.To4() == nil {
+		if e, found := mod.Session.Lan.Get(mac); found {
+			if e.IPv6 == nil {
+				e.SetIPv6(reply.IP.String())
+			}
+		} else {
+			mod.Session.Lan.AddIfNew(network.IpVersions{IPv6: reply.IP.String()}, mac)
+		}
+	}
+
+	if e := mod.Session.Lan.GetByIp(reply.IP.String()); e != nil && e.Meta.Get("probe:technique") == nil {
+		e.Meta.Set("probe:technique", foundBy)
+	}
+}
+
+func (mod *Prober) showStats() error {
+	if mod.stats == nil {
+		mod.Info("net.probe has not been started yet")
+		return nil
+	}
+
+	mod.stats.Lock()
+	defer mod.stats.Unlock()
+
+	rows := [][]string{}
+	for _, t := range techniques {
+		s := mod.stats.techniques[t]
+		if s.Sent == 0 && s.Replies == 0 {
+			continue
+		}
+		rows = append(rows, []string{
+			tui.Bold(t),
+			strconv.FormatUint(s.Sent, 10),
+			strconv.FormatUint(s.Replies, 10),
+			tui.Green(strconv.FormatUint(s.Hosts, 10)),
+		})
+	}
+	tui.Table(mod.Session.Events.Stdout, []string{"Technique", "Sent", "Replies", "Hosts Found"}, rows)
+
+	hosts := make([]*discoveredHost, 0, len(mod.stats.hosts))
+	for _, h := range mod.stats.hosts {
+		hosts = append(hosts, h)
+	}
+	if len(hosts) == 0 {
+		return nil
+	}
+	sort.Slice(hosts, func(i, j int) bool {
+		return hosts[i].FoundAt.Before(hosts[j].FoundAt)
+	})
+
+	rows = [][]string{}
+	for _, h := range hosts {
+		others := []string{}
+		for _, t := range techniques {
+			if h.Techniques[t] && t != h.FoundBy {
+				others = append(others, t)
+			}
+		}
+		rows = append(rows, []string{
+			h.IP,
+			h.MAC,
+			tui.Green(h.FoundBy),
+			tui.Dim(strings.Join(others, ", ")),
+			h.FoundAt.Format("15:04:05"),
+		})
+	}
+	fmt.Fprintln(mod.Session.Events.Stdout)
+	tui.Table(mod.Session.Events.Stdout, []string{"Address", "MAC", "Found By", "Also Replied To", "Found At"}, rows)
+
+	return nil
+}
@@ -33,6 +33,7 @@ func (mod *Prober) sendProbeMDNS(from net.IP, from_hw net.HardwareAddr) {
 		mod.Error("error sending mdns packet: %s", err)
 	} else {
 		mod.Debug("sent %d bytes of MDNS probe", len(raw))
+		mod.stats.OnSent(packets.DiscoveryMDNS)
 	}
 }
 
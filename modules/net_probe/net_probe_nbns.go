@@ -17,6 +17,7 @@ func (mod *Prober) sendProbeNBNS(from net.IP, from_hw net.HardwareAddr, ip net.I
 		defer con.Close()
 		if wrote, _ := con.Write(packets.NBNSRequest); wrote > 0 {
 			mod.Session.Queue.TrackSent(uint64(wrote))
+			mod.stats.OnSent(packets.DiscoveryNBNS)
 		} else {
 			mod.Session.Queue.TrackError()
 		}
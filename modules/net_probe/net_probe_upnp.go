@@ -17,6 +17,7 @@ func (mod *Prober) sendProbeUPNP(from net.IP, from_hw net.HardwareAddr) {
 		defer con.Close()
 		if wrote, _ := con.Write(packets.UPNPDiscoveryPayload); wrote > 0 {
 			mod.Session.Queue.TrackSent(uint64(wrote))
+			mod.stats.OnSent(packets.DiscoveryUPNP)
 		} else {
 			mod.Session.Queue.TrackError()
 		}
@@ -82,11 +82,23 @@ func (mod *Discovery) getRow(e *network.Endpoint, withMeta bool) [][]string {
 		seen = tui.Dim(seen)
 	}
 
+	osName := e.Meta.Get("os").(string)
+	if device := e.Meta.Get("os:device").(string); device != "" {
+		if osName != "" {
+			osName += " "
+		}
+		osName += "(" + device + ")"
+	}
+	if osName != "" {
+		osName += " " + tui.Dim(e.Meta.Get("os:confidence").(string))
+	}
+
 	row := []string{
 		addr,
 		mac,
 		name,
 		tui.Dim(e.Vendor),
+		osName,
 		humanize.Bytes(traffic.Sent),
 		humanize.Bytes(traffic.Received),
 		seen,
@@ -116,7 +128,7 @@ func (mod *Discovery) getRow(e *network.Endpoint, withMeta bool) [][]string {
 		if i == 0 {
 			rows = append(rows, append(row, m))
 		} else {
-			rows = append(rows, []string{"", "", "", "", "", "", "", m})
+			rows = append(rows, []string{"", "", "", "", "", "", "", "", m})
 		}
 	}
 
@@ -132,7 +144,9 @@ func (mod *Discovery) doFilter(target *network.Endpoint) bool {
 		mod.selector.Expression.MatchString(target.HwAddress) ||
 		mod.selector.Expression.MatchString(target.Hostname) ||
 		mod.selector.Expression.MatchString(target.Alias) ||
-		mod.selector.Expression.MatchString(target.Vendor)
+		mod.selector.Expression.MatchString(target.Vendor) ||
+		mod.selector.Expression.MatchString(target.Meta.Get("os").(string)) ||
+		mod.selector.Expression.MatchString(target.Meta.Get("os:device").(string))
 }
 
 func (mod *Discovery) doSelection(arg string) (err error, targets []*network.Endpoint) {
@@ -202,7 +216,7 @@ func (mod *Discovery) doSelection(arg string) (err error, targets []*network.End
 }
 
 func (mod *Discovery) colNames(hasMeta bool) []string {
-	colNames := []string{"IP", "MAC", "Name", "Vendor", "Sent", "Recvd", "Seen"}
+	colNames := []string{"IP", "MAC", "Name", "Vendor", "OS", "Sent", "Recvd", "Seen"}
 	if hasMeta {
 		colNames = append(colNames, "Meta")
 	}
@@ -211,11 +225,11 @@ func (mod *Discovery) colNames(hasMeta bool) []string {
 	case "mac":
 		colNames[1] += " " + mod.selector.SortSymbol
 	case "sent":
-		colNames[4] += " " + mod.selector.SortSymbol
-	case "rcvd":
 		colNames[5] += " " + mod.selector.SortSymbol
-	case "seen":
+	case "rcvd":
 		colNames[6] += " " + mod.selector.SortSymbol
+	case "seen":
+		colNames[7] += " " + mod.selector.SortSymbol
 	case "ip":
 		colNames[0] += " " + mod.selector.SortSymbol
 	}
@@ -319,7 +333,10 @@ func (mod *Discovery) showMeta(arg string) (err error) {
 					for _, info := range ports {
 						val += fmt.Sprintf("%s:%d", info.Proto, info.Port)
 						if info.Service != "" {
-							val += fmt.Sprintf("(%s)", info.Service)
+							val += fmt.Sprintf("(%s)", info.Description())
+						}
+						if info.State != "" && info.State != "open" {
+							val += " " + info.State
 						}
 						if info.Banner != "" {
 							val += fmt.Sprintf(" [%s]", info.Banner)
@@ -2,6 +2,7 @@ package net_sniff
 
 import (
 	"fmt"
+	"net"
 	"time"
 
 	"github.com/bettercap/bettercap/session"
@@ -21,6 +22,15 @@ type Sniffer struct {
 	fuzzLayers []string
 	fuzzRate   float64
 	fuzzRatio  float64
+
+	fuzzTarget       net.IP
+	fuzzOracle       string
+	fuzzOraclePeriod time.Duration
+	fuzzOracleStop   chan struct{}
+	fuzzCrashes      string
+	fuzzCorpusFile   string
+	fuzzCorpus       *fuzzCorpus
+	fuzzHistory      *fuzzHistory
 }
 
 func NewSniffer(s *session.Session) *Sniffer {
@@ -52,7 +62,7 @@ func NewSniffer(s *session.Session) *Sniffer {
 	mod.AddParam(session.NewStringParameter("net.sniff.output",
 		"",
 		"",
-		"If set, the sniffer will write captured packets to this file."))
+		"If set, the sniffer will write captured packets to this file, as pcapng with the matching events as packet comments if its extension is .pcapng."))
 
 	mod.AddParam(session.NewStringParameter("net.sniff.source",
 		"",
@@ -84,7 +94,7 @@ func NewSniffer(s *session.Session) *Sniffer {
 		}))
 
 	mod.AddHandler(session.NewModuleHandler("net.fuzz on", "",
-		"Enable fuzzing for every sniffed packet containing the specified layers.",
+		"Enable fuzzing of the sniffed packets containing the specified layers, by sending mutated copies of them.",
 		func(args []string) error {
 			return mod.StartFuzzing()
 		}))
@@ -98,7 +108,7 @@ func NewSniffer(s *session.Session) *Sniffer {
 	mod.AddParam(session.NewStringParameter("net.fuzz.layers",
 		"Payload",
 		"",
-		"Types of layer to fuzz."))
+		"Types of layer to fuzz, the fields of Ethernet, ARP, IPv4, IPv6, ICMPv4, ICMPv6, TCP, UDP and DNS are mutated according to their meaning, the bytes of the other ones at random."))
 
 	mod.AddParam(session.NewDecimalParameter("net.fuzz.rate",
 		"1.0",
@@ -106,12 +116,44 @@ func NewSniffer(s *session.Session) *Sniffer {
 
 	mod.AddParam(session.NewDecimalParameter("net.fuzz.ratio",
 		"0.4",
-		"Rate in the [0.0,1.0] interval of bytes to fuzz for each packet."))
+		"Rate in the [0.0,1.0] interval of fields, or bytes for the unstructured ones, to fuzz for each packet."))
 
 	mod.AddParam(session.NewBoolParameter("net.fuzz.silent",
 		"false",
 		"If true it will not report fuzzed packets."))
 
+	mod.AddParam(session.NewStringParameter("net.fuzz.target",
+		"",
+		"",
+		"If set, only the packets to this address will be fuzzed and its liveness will be checked with net.fuzz.oracle."))
+
+	mod.AddParam(session.NewStringParameter("net.fuzz.oracle",
+		"icmp",
+		`^(icmp|arp|tcp:\d+)$`,
+		"How to check if net.fuzz.target is still alive: icmp echo or arp requests, or tcp:PORT to connect to PORT."))
+
+	mod.AddParam(session.NewIntParameter("net.fuzz.oracle.period",
+		"2",
+		"Period in seconds of the liveness checks of net.fuzz.target."))
+
+	mod.AddParam(session.NewStringParameter("net.fuzz.corpus",
+		"",
+		"",
+		"If set, pcap file to load the seed packets from, the ones captured while fuzzing will be saved to it when net.fuzz is turned off."))
+
+	mod.AddParam(session.NewIntParameter("net.fuzz.corpus.size",
+		"256",
+		"Maximum number of seed packets, one for each combination of layers and destination port seen."))
+
+	mod.AddParam(session.NewIntParameter("net.fuzz.history",
+		"32",
+		"How many of the last fuzzed packets to save when net.fuzz.target stops responding."))
+
+	mod.AddParam(session.NewStringParameter("net.fuzz.crashes",
+		"~/bettercap-fuzz-crashes",
+		"",
+		"Folder where the fuzzed packets preceding a crash of net.fuzz.target will be saved, as pcap files with the description of their mutations."))
+
 	return mod
 }
 
@@ -146,7 +188,7 @@ func (mod Sniffer) isLocalPacket(packet gopacket.Packet) bool {
 	return false
 }
 
-func (mod *Sniffer) onPacketMatched(pkt gopacket.Packet) {
+func (mod *Sniffer) onPacketMatched(pkt *commentedPacket) {
 	if mainParser(pkt, mod.Ctx.Verbose) {
 		mod.Stats.NumDumped++
 	}
@@ -178,10 +220,25 @@ func (mod *Sniffer) Start() error {
 
 		src := gopacket.NewPacketSource(mod.Ctx.Handle, mod.Ctx.Handle.LinkType())
 		mod.pktSourceChan = src.Packets()
-		for packet := range mod.pktSourceChan {
-			if !mod.Running() {
+		mod.Session.Queue.ConsumeDecrypted(true)
+		defer mod.Session.Queue.ConsumeDecrypted(false)
+		for {
+			// packets decrypted by other modules (wifi.decrypt) are
+			// parsed as if they were captured from the interface
+			var packet gopacket.Packet
+			var ok bool
+			decrypted := false
+			select {
+			case packet, ok = <-mod.pktSourceChan:
+			case packet, ok = <-mod.Session.Queue.Decrypted:
+				decrypted = true
+			}
+
+			if !ok || !mod.Running() {
 				mod.Debug("end pkt loop (pkt=%v filter='%s')", packet, mod.Ctx.Filter)
 				break
+			} else if packet == nil {
+				continue
 			}
 
 			now := time.Now()
@@ -204,9 +261,21 @@ func (mod *Sniffer) Start() error {
 				if mod.Ctx.Compiled == nil || mod.Ctx.Compiled.Match(data) {
 					mod.Stats.NumMatched++
 
-					mod.onPacketMatched(packet)
-
-					if mod.Ctx.OutputWriter != nil {
+					parsed := &commentedPacket{Packet: packet}
+					mod.onPacketMatched(parsed)
+
+					if mod.Ctx.OutputNg != nil {
+						iface := 0
+						if decrypted {
+							iface = mod.Ctx.OutputDecrypted
+						}
+						if err := mod.Ctx.OutputNg.WritePacket(iface, packet.Metadata().CaptureInfo, data, parsed.comments...); err != nil {
+							mod.Debug("error writing packet: %v", err)
+						} else {
+							mod.Stats.NumWrote++
+						}
+					} else if mod.Ctx.OutputWriter != nil && !decrypted {
+						// classic pcap files have a single link type, the one of the capture
 						mod.Ctx.OutputWriter.WritePacket(packet.Metadata().CaptureInfo, data)
 						mod.Stats.NumWrote++
 					}
@@ -226,6 +295,10 @@ func (mod *Sniffer) Stop() error {
 			mod.pktSourceChan <- nil
 			mod.Debug("nil sent")
 		}
+		// hosts found while sniffing are resolved too
+		if err := mod.Ctx.WriteNames(mod.Session.Lan); err != nil {
+			mod.Debug("error writing names: %v", err)
+		}
 		mod.Debug("closing ctx")
 		mod.Ctx.Close()
 		mod.Debug("ctx closed")
@@ -0,0 +1,114 @@
+package net_sniff
+
+import (
+	"net"
+	"strconv"
+
+	"github.com/bettercap/bettercap/packets"
+	"github.com/bettercap/bettercap/session"
+
+	"github.com/gopacket/gopacket"
+	"github.com/gopacket/gopacket/layers"
+
+	"github.com/evilsocket/islazy/tui"
+)
+
+type authParseFunc func(packets.AuthFlow, []byte) *packets.AuthCredentials
+
+var (
+	mailAuth = packets.NewMailAuthState()
+
+	mailParser     = authTCPParser(mailParse, append(append(packets.SMTPPorts, packets.POP3Ports...), packets.IMAPPorts...)...)
+	telnetParser   = authTCPParser(packets.NewTelnetAuthState().Parse, packets.TelnetPort)
+	ldapParser     = authTCPParser(packets.LDAPCredentials, packets.LDAPPort)
+	redisParser    = authTCPParser(packets.RedisCredentials, packets.RedisPort)
+	postgresParser = authTCPParser(packets.NewPostgresAuthState().Parse, packets.PostgresPort)
+	mysqlParser    = authTCPParser(packets.NewMySQLAuthState().Parse, packets.MySQLPort)
+	vncParser      = authTCPParser(packets.NewVNCAuthState().Parse, packets.VNCPorts...)
+	sipTCPParser   = authTCPParser(packets.SIPCredentials, packets.SIPPorts...)
+
+	snmpParser   = authUDPParser(packets.SNMPCredentials, packets.SNMPPorts...)
+	sipParser    = authUDPParser(packets.SIPCredentials, packets.SIPPorts...)
+	radiusParser = authUDPParser(packets.RADIUSCredentials, packets.RADIUSPorts...)
+)
+
+func mailParse(flow packets.AuthFlow, payload []byte) *packets.AuthCredentials {
+	_, port, _ := net.SplitHostPort(flow.Server)
+	p, _ := strconv.Atoi(port)
+	return mailAuth.Parse(flow, packets.MailProtocol(p), payload)
+}
+
+func authTCPParser(parse authParseFunc, ports ...int) func(net.IP, net.IP, []byte, gopacket.Packet, *layers.TCP) bool {
+	return func(srcIP, dstIP net.IP, payload []byte, pkt gopacket.Packet, tcp *layers.TCP) bool {
+		if len(tcp.Payload) == 0 {
+			return false
+		}
+		flow, ok := packets.NewAuthFlow(srcIP, int(tcp.SrcPort), dstIP, int(tcp.DstPort), ports...)
+		if !ok {
+			return false
+		}
+		return onAuthPayload(parse, flow, tcp.Payload, pkt)
+	}
+}
+
+func authUDPParser(parse authParseFunc, ports ...int) func(net.IP, net.IP, []byte, gopacket.Packet, *layers.UDP) bool {
+	return func(srcIP, dstIP net.IP, payload []byte, pkt gopacket.Packet, udp *layers.UDP) bool {
+		flow, ok := packets.NewAuthFlow(srcIP, int(udp.SrcPort), dstIP, int(udp.DstPort), ports...)
+		if !ok {
+			return false
+		}
+		return onAuthPayload(parse, flow, udp.Payload, pkt)
+	}
+}
+
+func onAuthPayload(parse authParseFunc, flow packets.AuthFlow, payload []byte, pkt gopacket.Packet) bool {
+	creds := parse(flow, payload)
+	if creds == nil {
+		return false
+	}
+
+	clientHost, _, _ := net.SplitHostPort(creds.Client)
+	serverHost, _, _ := net.SplitHostPort(creds.Server)
+	clientIP, serverIP := net.ParseIP(clientHost), net.ParseIP(serverHost)
+
+	// logins without a secret, like SASL binds, are only reported
+	if creds.Password != "" || creds.Hash != "" {
+		cred := session.Credential{
+			Service:  creds.Protocol,
+			Host:     creds.Server,
+			Client:   clientHost,
+			Username: creds.Username,
+			Secret:   creds.Password,
+			Format:   session.CredentialPlain,
+			Source:   "net.sniff." + creds.Protocol,
+			Info:     creds.Info,
+			LastSeen: pkt.Metadata().Timestamp,
+		}
+		if creds.Hash != "" {
+			cred.Secret = creds.Hash
+			if creds.HashcatMode != 0 {
+				cred.Format = session.HashcatFormat(creds.HashcatMode)
+			} else {
+				cred.Format = creds.HashFormat
+			}
+		}
+		if !session.I.Credentials.Add(cred) {
+			return true
+		}
+	}
+
+	NewSnifferEvent(
+		pkt.Metadata().Timestamp,
+		creds.Protocol,
+		clientHost,
+		serverHost,
+		creds,
+		"%s %s > %s | %s",
+		tui.Wrap(tui.BACKYELLOW+tui.FOREWHITE, creds.Protocol),
+		vIP(clientIP),
+		vIP(serverIP),
+		tui.Yellow(creds.String()),
+	).Push(pkt)
+
+	return true
+}
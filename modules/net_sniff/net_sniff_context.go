@@ -9,6 +9,7 @@ import (
 	"github.com/bettercap/bettercap/network"
 	"github.com/bettercap/bettercap/session"
 
+	"github.com/gopacket/gopacket/layers"
 	"github.com/gopacket/gopacket/pcap"
 	"github.com/gopacket/gopacket/pcapgo"
 
@@ -26,6 +27,10 @@ type SnifferContext struct {
 	Output       string
 	OutputFile   *os.File
 	OutputWriter *pcapgo.Writer
+	// used instead of OutputWriter when the output is a .pcapng file, the
+	// capture is its first interface and the decrypted packets the second
+	OutputNg        *network.PcapNgWriter
+	OutputDecrypted int
 }
 
 func (mod *Sniffer) GetContext() (error, *SnifferContext) {
@@ -84,13 +89,58 @@ func (mod *Sniffer) GetContext() (error, *SnifferContext) {
 			return err, ctx
 		}
 
-		ctx.OutputWriter = pcapgo.NewWriter(ctx.OutputFile)
-		ctx.OutputWriter.WriteFileHeader(65536, ctx.Handle.LinkType())
+		if network.IsPcapNg(ctx.Output) {
+			if err = ctx.createPcapNg(mod.Session); err != nil {
+				return err, ctx
+			}
+		} else {
+			ctx.OutputWriter = pcapgo.NewWriter(ctx.OutputFile)
+			ctx.OutputWriter.WriteFileHeader(65536, ctx.Handle.LinkType())
+		}
 	}
 
 	return nil, ctx
 }
 
+func (c *SnifferContext) createPcapNg(sess *session.Session) (err error) {
+	if c.OutputNg, err = network.NewPcapNgWriter(c.OutputFile); err != nil {
+		return err
+	}
+
+	capture := network.PcapNgInterface{
+		Name:        sess.Interface.Name(),
+		Description: "captured by net.sniff",
+		LinkType:    c.Handle.LinkType(),
+		SnapLen:     65536,
+	}
+	if c.Source != "" {
+		capture.Name = c.Source
+		capture.Description = "read by net.sniff from " + c.Source
+	}
+
+	if _, err = c.OutputNg.AddInterface(capture); err != nil {
+		return err
+	} else if c.OutputDecrypted, err = c.OutputNg.AddInterface(network.PcapNgInterface{
+		Name:        "decrypted",
+		Description: "frames decrypted by wifi.decrypt",
+		LinkType:    layers.LinkTypeEthernet,
+		SnapLen:     65536,
+	}); err != nil {
+		return err
+	}
+
+	return c.WriteNames(sess.Lan)
+}
+
+// WriteNames saves the hostnames and aliases of the LAN, if the output is
+// pcapng, so that they show up in wireshark.
+func (c *SnifferContext) WriteNames(lan *network.LAN) error {
+	if c.OutputNg == nil || lan == nil {
+		return nil
+	}
+	return c.OutputNg.WriteNames(lan.Names())
+}
+
 func NewSnifferContext() *SnifferContext {
 	return &SnifferContext{
 		Handle:       nil,
@@ -102,6 +152,7 @@ func NewSnifferContext() *SnifferContext {
 		Output:       "",
 		OutputFile:   nil,
 		OutputWriter: nil,
+		OutputNg:     nil,
 	}
 }
 
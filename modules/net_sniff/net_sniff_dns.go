@@ -60,7 +60,7 @@ func dnsParser(srcIP, dstIP net.IP, payload []byte, pkt gopacket.Packet, udp *la
 			vIP(dstIP),
 			tui.Yellow(hostname),
 			tui.Dim(strings.Join(ips, ", ")),
-		).Push()
+		).Push(pkt)
 	}
 
 	return true
@@ -22,5 +22,5 @@ func onDOT11(radiotap *layers.RadioTap, dot11 *layers.Dot11, pkt gopacket.Packet
 		dot11.Address4,
 		dot11.SequenceNumber,
 		dot11.FragmentNumber,
-	).Push()
+	).Push(pkt)
 }
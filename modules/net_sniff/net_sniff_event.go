@@ -2,9 +2,12 @@ package net_sniff
 
 import (
 	"fmt"
+	"regexp"
 	"time"
 
 	"github.com/bettercap/bettercap/session"
+
+	"github.com/gopacket/gopacket"
 )
 
 type SniffData map[string]interface{}
@@ -29,7 +32,21 @@ func NewSnifferEvent(t time.Time, proto string, src string, dst string, data int
 	}
 }
 
-func (e SnifferEvent) Push() {
+var ansiCodes = regexp.MustCompile("\033\\[[0-9;]*[mK]")
+
+// commentedPacket collects the messages of the events pushed while parsing
+// the packet, to save them as its comments in the pcapng output.
+type commentedPacket struct {
+	gopacket.Packet
+	comments []string
+}
+
+// Push sends the event parsed from pkt, which is nil for events not
+// related to a captured packet.
+func (e SnifferEvent) Push(pkt gopacket.Packet) {
+	if c, ok := pkt.(*commentedPacket); ok {
+		c.comments = append(c.comments, ansiCodes.ReplaceAllString(e.Message, ""))
+	}
 	session.I.Events.Add("net.sniff."+e.Protocol, e)
 	session.I.Refresh()
 }
@@ -1,8 +1,12 @@
 package net_sniff
 
 import (
+	"fmt"
 	"net"
 	"regexp"
+	"sync"
+
+	"github.com/bettercap/bettercap/session"
 
 	"github.com/gopacket/gopacket"
 	"github.com/gopacket/gopacket/layers"
@@ -13,14 +17,48 @@ import (
 
 var (
 	ftpRe = regexp.MustCompile(`^(USER|PASS) (.+)[\n\r]+$`)
+	// last username sent by each client to each server
+	ftpUsers    = make(map[string]string)
+	ftpUsersMtx = sync.Mutex{}
 )
 
+func onFTPCredential(srcIP, dstIP net.IP, tcp *layers.TCP, what, value string, pkt gopacket.Packet) {
+	key := fmt.Sprintf("%s>%s:%d", srcIP, dstIP, tcp.DstPort)
+
+	ftpUsersMtx.Lock()
+	defer ftpUsersMtx.Unlock()
+
+	if what == "USER" {
+		ftpUsers[key] = value
+		return
+	}
+
+	username, found := ftpUsers[key]
+	if !found {
+		return
+	}
+	delete(ftpUsers, key)
+
+	session.I.Credentials.Add(session.Credential{
+		Service:  "ftp",
+		Host:     fmt.Sprintf("%s:%d", dstIP, tcp.DstPort),
+		Client:   srcIP.String(),
+		Username: username,
+		Secret:   value,
+		Format:   session.CredentialPlain,
+		Source:   "net.sniff.ftp",
+		LastSeen: pkt.Metadata().Timestamp,
+	})
+}
+
 func ftpParser(srcIP, dstIP net.IP, payload []byte, pkt gopacket.Packet, tcp *layers.TCP) bool {
 	data := string(tcp.Payload)
 
 	if matches := ftpRe.FindAllStringSubmatch(data, -1); matches != nil {
 		what := str.Trim(matches[0][1])
 		cred := str.Trim(matches[0][2])
+		onFTPCredential(srcIP, dstIP, tcp, what, cred, pkt)
+
 		NewSnifferEvent(
 			pkt.Metadata().Timestamp,
 			"ftp",
@@ -34,7 +72,7 @@ func ftpParser(srcIP, dstIP net.IP, payload []byte, pkt gopacket.Packet, tcp *la
 			vPort(tcp.DstPort),
 			tui.Bold(what),
 			tui.Yellow(cred),
-		).Push()
+		).Push(pkt)
 
 		return true
 	}
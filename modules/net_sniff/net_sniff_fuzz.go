@@ -1,75 +1,256 @@
 package net_sniff
 
 import (
+	"fmt"
 	"math/rand"
+	"net"
+	"os"
 	"strings"
+	"sync"
+	"time"
+
+	"github.com/bettercap/bettercap/packets"
 
 	"github.com/gopacket/gopacket"
+	"github.com/gopacket/gopacket/layers"
+	"github.com/gopacket/gopacket/pcap"
+	"github.com/gopacket/gopacket/pcapgo"
 
+	"github.com/evilsocket/islazy/fs"
 	"github.com/evilsocket/islazy/str"
 )
 
-var mutators = []func(byte) byte{
-	func(b byte) byte {
-		return byte(rand.Intn(256) & 0xff)
-	},
-	func(b byte) byte {
-		return byte(b << uint(rand.Intn(9)))
-	},
-	func(b byte) byte {
-		return byte(b >> uint(rand.Intn(9)))
-	},
-}
-
-func (mod *Sniffer) fuzz(data []byte) int {
-	changes := 0
-	for off, b := range data {
-		if rand.Float64() > mod.fuzzRatio {
-			continue
+// fuzzCorpus holds the seed packets to mutate, one for each distinct
+// combination of layers and destination port seen.
+type fuzzCorpus struct {
+	sync.Mutex
+	max    int
+	seeds  []gopacket.Packet
+	shapes map[string]bool
+}
+
+func newFuzzCorpus(max int) *fuzzCorpus {
+	return &fuzzCorpus{
+		max:    max,
+		seeds:  make([]gopacket.Packet, 0),
+		shapes: make(map[string]bool),
+	}
+}
+
+func fuzzShape(pkt gopacket.Packet) string {
+	parts := []string{}
+	for _, layer := range pkt.Layers() {
+		parts = append(parts, layer.LayerType().String())
+	}
+	if tcp, ok := pkt.Layer(layers.LayerTypeTCP).(*layers.TCP); ok {
+		parts = append(parts, fmt.Sprintf("%d", tcp.DstPort))
+	} else if udp, ok := pkt.Layer(layers.LayerTypeUDP).(*layers.UDP); ok {
+		parts = append(parts, fmt.Sprintf("%d", udp.DstPort))
+	}
+	return strings.Join(parts, "/")
+}
+
+// Add keeps the packet as a seed if it has any of the layers to fuzz and
+// a shape not seen before.
+func (c *fuzzCorpus) Add(pkt gopacket.Packet, layerNames []string) bool {
+	found := false
+	for _, layer := range pkt.Layers() {
+		for _, name := range layerNames {
+			if layer.LayerType().String() == name {
+				found = true
+				break
+			}
 		}
+	}
+	if !found {
+		return false
+	}
+
+	shape := fuzzShape(pkt)
+
+	c.Lock()
+	defer c.Unlock()
 
-		data[off] = mutators[rand.Intn(len(mutators))](b)
-		changes++
+	if c.shapes[shape] || len(c.seeds) >= c.max {
+		return false
 	}
-	return changes
+	c.shapes[shape] = true
+	c.seeds = append(c.seeds, pkt)
+	return true
 }
 
-func (mod *Sniffer) doFuzzing(pkt gopacket.Packet) {
-	if rand.Float64() > mod.fuzzRate {
-		return
+func (c *fuzzCorpus) Pick() gopacket.Packet {
+	c.Lock()
+	defer c.Unlock()
+
+	if len(c.seeds) == 0 {
+		return nil
 	}
+	return c.seeds[rand.Intn(len(c.seeds))]
+}
 
-	layersChanged := 0
-	bytesChanged := 0
+func (c *fuzzCorpus) Len() int {
+	c.Lock()
+	defer c.Unlock()
+	return len(c.seeds)
+}
 
-	for _, fuzzLayerType := range mod.fuzzLayers {
-		for _, layer := range pkt.Layers() {
-			if layer.LayerType().String() == fuzzLayerType {
-				fuzzData := layer.LayerContents()
-				changes := mod.fuzz(fuzzData)
-				if changes > 0 {
-					layersChanged++
-					bytesChanged += changes
-				}
+func (c *fuzzCorpus) Load(fileName string, layerNames []string) (int, error) {
+	handle, err := pcap.OpenOffline(fileName)
+	if err != nil {
+		return 0, err
+	}
+	defer handle.Close()
 
-			}
+	loaded := 0
+	src := gopacket.NewPacketSource(handle, handle.LinkType())
+	for pkt := range src.Packets() {
+		if c.Add(pkt, layerNames) {
+			loaded++
 		}
 	}
+	return loaded, nil
+}
+
+func (c *fuzzCorpus) Save(fileName string, linkType layers.LinkType) error {
+	c.Lock()
+	defer c.Unlock()
+
+	fp, err := os.Create(fileName)
+	if err != nil {
+		return err
+	}
+	defer fp.Close()
 
-	if bytesChanged > 0 {
-		logFn := mod.Info
-		if mod.fuzzSilent {
-			logFn = mod.Debug
+	writer := pcapgo.NewWriter(fp)
+	if err = writer.WriteFileHeader(65536, linkType); err != nil {
+		return err
+	}
+	for _, pkt := range c.seeds {
+		if err = writer.WritePacket(pkt.Metadata().CaptureInfo, pkt.Data()); err != nil {
+			return err
 		}
-		logFn("changed %d bytes in %d layers.", bytesChanged, layersChanged)
-		if err := mod.Session.Queue.Send(pkt.Data()); err != nil {
-			mod.Error("error sending fuzzed packet: %s", err)
+	}
+	return nil
+}
+
+type fuzzedPacket struct {
+	Time      time.Time
+	Data      []byte
+	Mutations []packets.FuzzMutation
+}
+
+// fuzzHistory keeps the last fuzzed packets, to be saved if the target
+// stops responding.
+type fuzzHistory struct {
+	sync.Mutex
+	max  int
+	sent []fuzzedPacket
+}
+
+func newFuzzHistory(max int) *fuzzHistory {
+	return &fuzzHistory{
+		max:  max,
+		sent: make([]fuzzedPacket, 0),
+	}
+}
+
+func (h *fuzzHistory) Add(data []byte, mutations []packets.FuzzMutation) {
+	h.Lock()
+	defer h.Unlock()
+
+	h.sent = append(h.sent, fuzzedPacket{
+		Time:      time.Now(),
+		Data:      data,
+		Mutations: mutations,
+	})
+	if len(h.sent) > h.max {
+		h.sent = h.sent[len(h.sent)-h.max:]
+	}
+}
+
+// Has returns true if the data is one of the packets we sent, so that they
+// are not fuzzed again once sniffed.
+func (h *fuzzHistory) Has(data []byte) bool {
+	h.Lock()
+	defer h.Unlock()
+
+	for _, sent := range h.sent {
+		if string(sent.Data) == string(data) {
+			return true
 		}
 	}
+	return false
+}
+
+func (h *fuzzHistory) Flush() []fuzzedPacket {
+	h.Lock()
+	defer h.Unlock()
+
+	sent := h.sent
+	h.sent = make([]fuzzedPacket, 0)
+	return sent
+}
+
+// fuzzTargets returns true if the packet is addressed to the target.
+func fuzzTargets(pkt gopacket.Packet, target net.IP) bool {
+	if ip4, ok := pkt.Layer(layers.LayerTypeIPv4).(*layers.IPv4); ok {
+		return ip4.DstIP.Equal(target)
+	} else if ip6, ok := pkt.Layer(layers.LayerTypeIPv6).(*layers.IPv6); ok {
+		return ip6.DstIP.Equal(target)
+	} else if arp, ok := pkt.Layer(layers.LayerTypeARP).(*layers.ARP); ok {
+		return net.IP(arp.DstProtAddress).Equal(target)
+	}
+	return false
+}
+
+func (mod *Sniffer) doFuzzing(pkt gopacket.Packet) {
+	if mod.fuzzTarget != nil && !fuzzTargets(pkt, mod.fuzzTarget) {
+		return
+	} else if mod.fuzzHistory.Has(pkt.Data()) {
+		return
+	}
+
+	if mod.fuzzCorpus.Add(pkt, mod.fuzzLayers) {
+		mod.Debug("new seed packet %s (%d in the corpus)", fuzzShape(pkt), mod.fuzzCorpus.Len())
+	}
+
+	if rand.Float64() > mod.fuzzRate {
+		return
+	}
+
+	seed := mod.fuzzCorpus.Pick()
+	if seed == nil {
+		return
+	}
+
+	data, mutations := packets.FuzzPacket(seed, mod.fuzzLayers, mod.fuzzRatio)
+	if data == nil {
+		return
+	}
+
+	logFn := mod.Info
+	if mod.fuzzSilent {
+		logFn = mod.Debug
+	}
+	changes := make([]string, len(mutations))
+	for i, m := range mutations {
+		changes[i] = m.String()
+	}
+	logFn("mutated %d fields: %s", len(mutations), strings.Join(changes, ", "))
+
+	mod.fuzzHistory.Add(data, mutations)
+	if err := mod.Session.Queue.Send(data); err != nil {
+		mod.Error("error sending fuzzed packet: %s", err)
+	}
 }
 
 func (mod *Sniffer) configureFuzzing() (err error) {
 	layers := ""
+	target := ""
+	corpusSize := 0
+	historySize := 0
+	period := 0
 
 	if err, layers = mod.StringParam("net.fuzz.layers"); err != nil {
 		return
@@ -89,6 +270,51 @@ func (mod *Sniffer) configureFuzzing() (err error) {
 		return
 	}
 
+	if err, target = mod.StringParam("net.fuzz.target"); err != nil {
+		return
+	} else if target == "" {
+		mod.fuzzTarget = nil
+	} else if mod.fuzzTarget = net.ParseIP(target); mod.fuzzTarget == nil {
+		return fmt.Errorf("invalid target address %s", target)
+	}
+
+	if err, mod.fuzzOracle = mod.StringParam("net.fuzz.oracle"); err != nil {
+		return
+	} else if err, period = mod.IntParam("net.fuzz.oracle.period"); err != nil {
+		return
+	} else {
+		mod.fuzzOraclePeriod = time.Duration(period) * time.Second
+	}
+
+	if err, mod.fuzzCrashes = mod.StringParam("net.fuzz.crashes"); err != nil {
+		return
+	} else if mod.fuzzCrashes, err = fs.Expand(mod.fuzzCrashes); err != nil {
+		return
+	}
+
+	if err, historySize = mod.IntParam("net.fuzz.history"); err != nil {
+		return
+	} else {
+		mod.fuzzHistory = newFuzzHistory(historySize)
+	}
+
+	if err, corpusSize = mod.IntParam("net.fuzz.corpus.size"); err != nil {
+		return
+	} else if err, mod.fuzzCorpusFile = mod.StringParam("net.fuzz.corpus"); err != nil {
+		return
+	} else if mod.fuzzCorpusFile, err = fs.Expand(mod.fuzzCorpusFile); err != nil {
+		return
+	}
+
+	mod.fuzzCorpus = newFuzzCorpus(corpusSize)
+	if mod.fuzzCorpusFile != "" && fs.Exists(mod.fuzzCorpusFile) {
+		if loaded, err := mod.fuzzCorpus.Load(mod.fuzzCorpusFile, mod.fuzzLayers); err != nil {
+			return err
+		} else {
+			mod.Info("loaded %d seed packets from %s", loaded, mod.fuzzCorpusFile)
+		}
+	}
+
 	return
 }
 
@@ -109,10 +335,35 @@ func (mod *Sniffer) StartFuzzing() error {
 
 	mod.Info("active on layer types %s (rate:%f ratio:%f)", strings.Join(mod.fuzzLayers, ","), mod.fuzzRate, mod.fuzzRatio)
 
+	if mod.fuzzTarget != nil {
+		mod.fuzzOracleStop = make(chan struct{})
+		go mod.fuzzOracleLoop(mod.fuzzOracleStop)
+	}
+
 	return nil
 }
 
 func (mod *Sniffer) StopFuzzing() error {
+	if !mod.fuzzActive {
+		return nil
+	}
+
 	mod.fuzzActive = false
+	if mod.fuzzOracleStop != nil {
+		close(mod.fuzzOracleStop)
+		mod.fuzzOracleStop = nil
+	}
+
+	if mod.fuzzCorpusFile != "" {
+		linkType := layers.LinkTypeEthernet
+		if mod.Ctx != nil && mod.Ctx.Handle != nil {
+			linkType = mod.Ctx.Handle.LinkType()
+		}
+		if err := mod.fuzzCorpus.Save(mod.fuzzCorpusFile, linkType); err != nil {
+			return err
+		}
+		mod.Info("saved %d seed packets to %s", mod.fuzzCorpus.Len(), mod.fuzzCorpusFile)
+	}
+
 	return nil
 }
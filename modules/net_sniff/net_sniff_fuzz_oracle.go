@@ -0,0 +1,251 @@
+package net_sniff
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/bettercap/bettercap/network"
+	"github.com/bettercap/bettercap/packets"
+
+	"github.com/gopacket/gopacket"
+	"github.com/gopacket/gopacket/layers"
+	"github.com/gopacket/gopacket/pcap"
+	"github.com/gopacket/gopacket/pcapgo"
+)
+
+const (
+	// how long to wait for the target to answer a liveness check
+	fuzzOracleTimeout = time.Duration(1) * time.Second
+	// how many checks in a row must fail before the target is considered down
+	fuzzOracleFailures = 2
+)
+
+// oracleFilter returns the BPF filter matching the replies of the target to
+// the icmp and arp liveness probes.
+func (mod *Sniffer) oracleFilter() string {
+	target := mod.fuzzTarget.String()
+	if mod.fuzzOracle == "arp" && mod.fuzzTarget.To4() != nil {
+		return fmt.Sprintf("arp and arp[6:2] == 2 and arp src host %s", target)
+	} else if mod.fuzzTarget.To4() == nil {
+		return fmt.Sprintf("icmp6 and ip6[40] == 129 and src host %s", target)
+	}
+	return fmt.Sprintf("icmp and icmp[icmptype] == icmp-echoreply and src host %s", target)
+}
+
+func (mod *Sniffer) openOracleHandle() (*pcap.Handle, error) {
+	handle, err := network.CaptureWithTimeout(mod.Session.Interface.Name(), 100*time.Millisecond)
+	if err != nil {
+		return nil, err
+	} else if err = handle.SetBPFFilter(mod.oracleFilter()); err != nil {
+		handle.Close()
+		return nil, err
+	}
+	return handle, nil
+}
+
+// fuzzTargetMAC resolves the hardware address of the target, IPv6 neighbors
+// are only known if net.recon or net.probe found them.
+func (mod *Sniffer) fuzzTargetMAC() (net.HardwareAddr, error) {
+	if mod.fuzzTarget.To4() == nil {
+		if e := mod.Session.Lan.GetByIp(mod.fuzzTarget.String()); e != nil && e.HW != nil {
+			return e.HW, nil
+		}
+		return nil, fmt.Errorf("%s is not a known IPv6 neighbor", mod.fuzzTarget)
+	}
+	return mod.Session.FindMAC(mod.fuzzTarget, true)
+}
+
+// waitForTarget sends the probe and returns true if a reply matching isReply
+// is captured within the timeout.
+func (mod *Sniffer) waitForTarget(handle *pcap.Handle, probe []byte, isReply func(gopacket.Packet) bool) bool {
+	if err := mod.Session.Queue.Send(probe); err != nil {
+		mod.Debug("error sending liveness probe: %v", err)
+		return false
+	}
+
+	src := gopacket.NewPacketSource(handle, handle.LinkType())
+	for deadline := time.Now().Add(fuzzOracleTimeout); time.Now().Before(deadline); {
+		if pkt, err := src.NextPacket(); err == nil && isReply(pkt) {
+			return true
+		}
+	}
+	return false
+}
+
+func (mod *Sniffer) isTargetAlive(handle *pcap.Handle) bool {
+	if strings.HasPrefix(mod.fuzzOracle, "tcp:") {
+		address := net.JoinHostPort(mod.fuzzTarget.String(), strings.TrimPrefix(mod.fuzzOracle, "tcp:"))
+		conn, err := net.DialTimeout("tcp", address, fuzzOracleTimeout)
+		if err == nil {
+			conn.Close()
+			return true
+		}
+		// a closed port still means a live host
+		return errors.Is(err, syscall.ECONNREFUSED)
+	}
+
+	iface := mod.Session.Interface
+	from := iface.IP
+	if mod.fuzzTarget.To4() == nil {
+		from = iface.IPv6
+	}
+
+	if mod.fuzzOracle == "arp" && mod.fuzzTarget.To4() != nil {
+		err, probe := packets.NewARPRequest(from, iface.HW, mod.fuzzTarget)
+		if err != nil {
+			mod.Debug("error creating liveness probe: %v", err)
+			return false
+		}
+		// the filter only matches the replies from the target
+		return mod.waitForTarget(handle, probe, func(gopacket.Packet) bool { return true })
+	}
+
+	hw, err := mod.fuzzTargetMAC()
+	if err != nil {
+		mod.Debug("can't check the liveness of %s: %v", mod.fuzzTarget, err)
+		return false
+	}
+
+	id := uint16(rand.Intn(0xffff))
+	err, probe := packets.NewICMPEcho(from, iface.HW, mod.fuzzTarget, hw, id, 1)
+	if err != nil {
+		mod.Debug("error creating liveness probe: %v", err)
+		return false
+	}
+	return mod.waitForTarget(handle, probe, func(pkt gopacket.Packet) bool {
+		if layer := pkt.Layer(layers.LayerTypeICMPv4); layer != nil {
+			return layer.(*layers.ICMPv4).Id == id
+		} else if layer := pkt.Layer(layers.LayerTypeICMPv6Echo); layer != nil {
+			return layer.(*layers.ICMPv6Echo).Identifier == id
+		}
+		return false
+	})
+}
+
+func (mod *Sniffer) fuzzOracleLoop(stop chan struct{}) {
+	alive := false
+	failures := 0
+
+	var handle *pcap.Handle
+	if !strings.HasPrefix(mod.fuzzOracle, "tcp:") {
+		var err error
+		if handle, err = mod.openOracleHandle(); err != nil {
+			mod.Error("can't check the liveness of %s: %v", mod.fuzzTarget, err)
+			return
+		}
+		defer handle.Close()
+	}
+
+	mod.Info("checking the liveness of %s every %s (%s)", mod.fuzzTarget, mod.fuzzOraclePeriod, mod.fuzzOracle)
+
+	for {
+		if mod.isTargetAlive(handle) {
+			if !alive {
+				mod.Info("%s is alive", mod.fuzzTarget)
+			}
+			alive = true
+			failures = 0
+		} else if alive {
+			failures++
+			if failures >= fuzzOracleFailures {
+				alive = false
+				mod.onFuzzTargetDown()
+			}
+		}
+
+		select {
+		case <-stop:
+			return
+		case <-time.After(mod.fuzzOraclePeriod):
+		}
+	}
+}
+
+// onFuzzTargetDown saves the last fuzzed packets as a pcap file to replay
+// them, along with a description of their mutations.
+func (mod *Sniffer) onFuzzTargetDown() {
+	sent := mod.fuzzHistory.Flush()
+	if len(sent) == 0 {
+		mod.Warning("%s stopped responding, but no fuzzed packet has been sent to it", mod.fuzzTarget)
+		return
+	}
+
+	fileName, err := mod.saveFuzzCrash(sent)
+	if err != nil {
+		mod.Error("error saving the fuzzed packets: %v", err)
+		return
+	}
+
+	mod.Warning("%s stopped responding, the last %d fuzzed packets have been saved to %s", mod.fuzzTarget, len(sent), fileName)
+
+	NewSnifferEvent(
+		time.Now(),
+		"fuzz.crash",
+		mod.Session.Interface.IpAddress,
+		mod.fuzzTarget.String(),
+		SniffData{
+			"file":    fileName,
+			"packets": len(sent),
+		},
+		"%s stopped responding after %d fuzzed packets, saved to %s",
+		mod.fuzzTarget,
+		len(sent),
+		fileName,
+	).Push(nil)
+}
+
+func (mod *Sniffer) saveFuzzCrash(sent []fuzzedPacket) (string, error) {
+	if err := os.MkdirAll(mod.fuzzCrashes, os.ModePerm); err != nil {
+		return "", err
+	}
+
+	name := fmt.Sprintf("%s-%s", strings.Replace(mod.fuzzTarget.String(), ":", "_", -1), time.Now().Format("20060102-150405"))
+	fileName := filepath.Join(mod.fuzzCrashes, name+".pcap")
+
+	fp, err := os.Create(fileName)
+	if err != nil {
+		return "", err
+	}
+	defer fp.Close()
+
+	linkType := layers.LinkTypeEthernet
+	if mod.Ctx != nil && mod.Ctx.Handle != nil {
+		linkType = mod.Ctx.Handle.LinkType()
+	}
+
+	writer := pcapgo.NewWriter(fp)
+	if err = writer.WriteFileHeader(65536, linkType); err != nil {
+		return "", err
+	}
+
+	report := strings.Builder{}
+	for i, pkt := range sent {
+		info := gopacket.CaptureInfo{
+			Timestamp:     pkt.Time,
+			CaptureLength: len(pkt.Data),
+			Length:        len(pkt.Data),
+		}
+		if err = writer.WritePacket(info, pkt.Data); err != nil {
+			return "", err
+		}
+
+		changes := make([]string, len(pkt.Mutations))
+		for j, m := range pkt.Mutations {
+			changes[j] = m.String()
+		}
+		fmt.Fprintf(&report, "#%d %s %s\n", i+1, pkt.Time.Format(time.RFC3339Nano), strings.Join(changes, ", "))
+	}
+
+	if err = os.WriteFile(filepath.Join(mod.fuzzCrashes, name+".txt"), []byte(report.String()), 0644); err != nil {
+		return "", err
+	}
+
+	return fileName, nil
+}
@@ -7,8 +7,12 @@ import (
 	"io/ioutil"
 	"net"
 	"net/http"
+	"net/url"
+	"regexp"
 	"strings"
 
+	"github.com/bettercap/bettercap/session"
+
 	"github.com/gopacket/gopacket"
 	"github.com/gopacket/gopacket/layers"
 
@@ -17,6 +21,11 @@ import (
 	"github.com/evilsocket/islazy/tui"
 )
 
+var (
+	userFieldRe = regexp.MustCompile(`(?i)(user|login|email|account|uname|usr)`)
+	passFieldRe = regexp.MustCompile(`(?i)(pass|pwd|secret)`)
+)
+
 type HTTPRequest struct {
 	Method      string      `json:"method"`
 	Proto       string      `json:"proto"`
@@ -117,16 +126,57 @@ func toSerializableResponse(res *http.Response) HTTPResponse {
 	}
 }
 
+// formCredentials looks for username and password fields in a form posted
+// in clear text.
+func formCredentials(req HTTPRequest) (user string, pass string, found bool) {
+	if req.Method != "POST" || !req.IsType("application/x-www-form-urlencoded") {
+		return "", "", false
+	}
+
+	form, err := url.ParseQuery(string(req.Body))
+	if err != nil {
+		return "", "", false
+	}
+
+	for name, values := range form {
+		if len(values) == 0 || values[0] == "" {
+			continue
+		} else if user == "" && userFieldRe.MatchString(name) {
+			user = values[0]
+		} else if pass == "" && passFieldRe.MatchString(name) {
+			pass = values[0]
+		}
+	}
+
+	return user, pass, user != "" && pass != ""
+}
+
+func onHTTPCredential(srcIP net.IP, req HTTPRequest, user, pass, info string, pkt gopacket.Packet) {
+	session.I.Credentials.Add(session.Credential{
+		Service:  "http",
+		Host:     req.Host,
+		Client:   srcIP.String(),
+		Username: user,
+		Secret:   pass,
+		Format:   session.CredentialPlain,
+		Source:   "net.sniff.http.request",
+		Info:     info,
+		LastSeen: pkt.Metadata().Timestamp,
+	})
+}
+
 func httpParser(srcIP, dstIP net.IP, payload []byte, pkt gopacket.Packet, tcp *layers.TCP) bool {
 	data := tcp.Payload
 	if req, err := http.ReadRequest(bufio.NewReader(bytes.NewReader(data))); err == nil {
+		sreq := toSerializableRequest(req)
 		if user, pass, ok := req.BasicAuth(); ok {
+			onHTTPCredential(srcIP, sreq, user, pass, "basic auth "+sreq.URL, pkt)
 			NewSnifferEvent(
 				pkt.Metadata().Timestamp,
 				"http.request",
 				srcIP.String(),
 				req.Host,
-				toSerializableRequest(req),
+				sreq,
 				"%s %s %s %s%s - %s %s, %s %s",
 				tui.Wrap(tui.BACKRED+tui.FOREBLACK, "http"),
 				vIP(srcIP),
@@ -137,21 +187,24 @@ func httpParser(srcIP, dstIP net.IP, payload []byte, pkt gopacket.Packet, tcp *l
 				tui.Red(user),
 				tui.Bold("PASS"),
 				tui.Red(pass),
-			).Push()
+			).Push(pkt)
 		} else {
+			if user, pass, ok := formCredentials(sreq); ok {
+				onHTTPCredential(srcIP, sreq, user, pass, "form "+sreq.URL, pkt)
+			}
 			NewSnifferEvent(
 				pkt.Metadata().Timestamp,
 				"http.request",
 				srcIP.String(),
 				req.Host,
-				toSerializableRequest(req),
+				sreq,
 				"%s %s %s %s%s",
 				tui.Wrap(tui.BACKRED+tui.FOREBLACK, "http"),
 				vIP(srcIP),
 				tui.Wrap(tui.BACKLIGHTBLUE+tui.FOREBLACK, req.Method),
 				tui.Yellow(req.Host),
 				vURL(req.URL.String()),
-			).Push()
+			).Push(pkt)
 		}
 
 		return true
@@ -171,7 +224,7 @@ func httpParser(srcIP, dstIP net.IP, payload []byte, pkt gopacket.Packet, tcp *l
 			vIP(dstIP),
 			tui.Dim(humanize.Bytes(uint64(len(sres.Body)))),
 			tui.Yellow(sres.ContentType),
-		).Push()
+		).Push(pkt)
 
 		return true
 	}
@@ -2,9 +2,14 @@ package net_sniff
 
 import (
 	"encoding/asn1"
+	"encoding/binary"
+	"fmt"
 	"net"
+	"sync"
+	"time"
 
 	"github.com/bettercap/bettercap/packets"
+	"github.com/bettercap/bettercap/session"
 
 	"github.com/gopacket/gopacket"
 	"github.com/gopacket/gopacket/layers"
@@ -12,33 +17,168 @@ import (
 	"github.com/evilsocket/islazy/tui"
 )
 
+const (
+	// replies carrying service tickets usually span several TCP segments
+	krb5MaxRecord = 65535
+	krb5StreamTTL = 30 * time.Second
+)
+
+type krb5Stream struct {
+	size int
+	seen time.Time
+	data []byte
+}
+
+var (
+	krb5Lock    = sync.Mutex{}
+	krb5Streams = make(map[string]*krb5Stream)
+)
+
 func krb5Parser(srcIP, dstIP net.IP, payload []byte, pkt gopacket.Packet, udp *layers.UDP) bool {
-	if udp.DstPort != 88 {
+	if udp.DstPort == 88 {
+		return onKrb5Request(srcIP, dstIP, udp.Payload, pkt, "udp")
+	} else if udp.SrcPort == 88 {
+		return onKrb5Reply(srcIP, dstIP, udp.Payload, pkt, "udp")
+	}
+	return false
+}
+
+// krb5TCPParser handles Kerberos over TCP, where each message is prefixed by
+// its length.
+func krb5TCPParser(srcIP, dstIP net.IP, payload []byte, pkt gopacket.Packet, tcp *layers.TCP) bool {
+	if tcp.DstPort != 88 && tcp.SrcPort != 88 {
+		return false
+	} else if len(tcp.Payload) == 0 {
 		return false
 	}
 
+	key := fmt.Sprintf("%s:%d>%s:%d", srcIP, tcp.SrcPort, dstIP, tcp.DstPort)
+
+	krb5Lock.Lock()
+	stream, found := krb5Streams[key]
+	if !found {
+		if len(tcp.Payload) < 4 {
+			krb5Lock.Unlock()
+			return false
+		}
+		size := int(binary.BigEndian.Uint32(tcp.Payload))
+		if size == 0 || size > krb5MaxRecord {
+			krb5Lock.Unlock()
+			return false
+		}
+		// forget streams whose segments were lost
+		now := pkt.Metadata().Timestamp
+		for k, old := range krb5Streams {
+			if now.Sub(old.seen) > krb5StreamTTL {
+				delete(krb5Streams, k)
+			}
+		}
+		stream = &krb5Stream{size: size, seen: now, data: make([]byte, 0, size)}
+		krb5Streams[key] = stream
+		stream.data = append(stream.data, tcp.Payload[4:]...)
+	} else {
+		stream.data = append(stream.data, tcp.Payload...)
+	}
+
+	if len(stream.data) < stream.size {
+		krb5Lock.Unlock()
+		return true
+	}
+	delete(krb5Streams, key)
+	krb5Lock.Unlock()
+
+	data := stream.data[:stream.size]
+	if tcp.DstPort == 88 {
+		return onKrb5Request(srcIP, dstIP, data, pkt, "tcp")
+	}
+	return onKrb5Reply(srcIP, dstIP, data, pkt, "tcp")
+}
+
+func krb5Credential(h packets.Krb5Hash, kdc, client net.IP, proto string, pkt gopacket.Packet) session.Credential {
+	return session.Credential{
+		Service:  "krb5/" + proto,
+		Host:     kdc.String(),
+		Client:   client.String(),
+		Username: h.User + "@" + h.Realm,
+		Secret:   h.Hash,
+		Format:   session.HashcatFormat(h.Mode),
+		Source:   "net.sniff.krb5",
+		Info:     h.Service,
+		LastSeen: pkt.Metadata().Timestamp,
+	}
+}
+
+func onKrb5Request(srcIP, dstIP net.IP, data []byte, pkt gopacket.Packet, proto string) bool {
 	var req packets.Krb5Request
-	_, err := asn1.UnmarshalWithParams(udp.Payload, &req, packets.Krb5AsReqParam)
+	_, err := asn1.UnmarshalWithParams(data, &req, packets.Krb5AsReqParam)
+	if err != nil {
+		return false
+	}
+
+	s, err := req.String()
 	if err != nil {
 		return false
 	}
 
-	if s, err := req.String(); err == nil {
-		NewSnifferEvent(
-			pkt.Metadata().Timestamp,
-			"krb5",
-			srcIP.String(),
-			dstIP.String(),
-			nil,
-			"%s %s -> %s : %s",
-			tui.Wrap(tui.BACKRED+tui.FOREBLACK, "krb-as-req"),
-			vIP(srcIP),
-			vIP(dstIP),
-			s,
-		).Push()
+	var evData interface{}
+	if h, err := req.Hash(); err == nil {
+		cred := krb5Credential(h, dstIP, srcIP, proto, pkt)
+		if !session.I.Credentials.Add(cred) {
+			return true
+		}
+		evData = cred
+	}
 
+	NewSnifferEvent(
+		pkt.Metadata().Timestamp,
+		"krb5",
+		srcIP.String(),
+		dstIP.String(),
+		evData,
+		"%s %s -> %s : %s",
+		tui.Wrap(tui.BACKRED+tui.FOREBLACK, "krb-as-req"),
+		vIP(srcIP),
+		vIP(dstIP),
+		s,
+	).Push(pkt)
+
+	return true
+}
+
+func onKrb5Reply(srcIP, dstIP net.IP, data []byte, pkt gopacket.Packet, proto string) bool {
+	rep, err := packets.ParseKrb5Reply(data)
+	if err != nil {
+		return false
+	}
+
+	h, err := rep.Hash()
+	if err != nil {
+		return false
+	}
+
+	// the client is the one who will use the ticket
+	cred := krb5Credential(h, srcIP, dstIP, proto, pkt)
+	if !session.I.Credentials.Add(cred) {
 		return true
 	}
 
-	return false
+	what := "krb-as-rep"
+	if rep.MsgType == packets.Krb5TgsReplyType {
+		what = "krb-tgs-rep"
+	}
+
+	NewSnifferEvent(
+		pkt.Metadata().Timestamp,
+		"krb5",
+		srcIP.String(),
+		dstIP.String(),
+		cred,
+		"%s %s -> %s : %s",
+		tui.Wrap(tui.BACKRED+tui.FOREBLACK, what),
+		vIP(srcIP),
+		vIP(dstIP),
+		h.Hash,
+	).Push(pkt)
+
+	return true
 }
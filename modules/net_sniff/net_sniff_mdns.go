@@ -29,7 +29,7 @@ func mdnsParser(srcIP, dstIP net.IP, payload []byte, pkt gopacket.Packet, udp *l
 					vIP(srcIP),
 					tui.Dim(q.Type.String()),
 					tui.Yellow(string(q.Name)),
-				).Push()
+				).Push(pkt)
 			}
 
 			m := make(map[string][]string)
@@ -65,7 +65,7 @@ func mdnsParser(srcIP, dstIP net.IP, payload []byte, pkt gopacket.Packet, udp *l
 					vIP(srcIP),
 					tui.Yellow(hostname),
 					tui.Dim(strings.Join(ips, ", ")),
-				).Push()
+				).Push(pkt)
 			}
 
 			return true
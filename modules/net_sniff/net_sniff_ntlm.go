@@ -1,11 +1,13 @@
 package net_sniff
 
 import (
+	"fmt"
 	"net"
 	"regexp"
 	"strings"
 
 	"github.com/bettercap/bettercap/packets"
+	"github.com/bettercap/bettercap/session"
 
 	"github.com/gopacket/gopacket"
 	"github.com/gopacket/gopacket/layers"
@@ -48,18 +50,36 @@ func ntlmParser(srcIP, dstIP net.IP, payload []byte, pkt gopacket.Packet, tcp *l
 			} else if isResponse(line) {
 				ok = true
 				ntlm.AddClientResponse(tcp.Seq, tokens[2], func(data packets.NTLMChallengeResponseParsed) {
+					cred := session.Credential{
+						Service:  "http/ntlm",
+						Host:     fmt.Sprintf("%s:%d", dstIP, tcp.DstPort),
+						Client:   srcIP.String(),
+						Username: data.User,
+						Secret:   data.HashcatString(),
+						Format:   session.HashcatFormat(data.HashcatMode()),
+						Source:   "net.sniff.ntlm.response",
+						Info:     data.Workstation,
+						LastSeen: pkt.Metadata().Timestamp,
+					}
+					if data.Domain != "" {
+						cred.Username = data.Domain + "\\" + data.User
+					}
+					if !session.I.Credentials.Add(cred) {
+						return
+					}
+
 					NewSnifferEvent(
 						pkt.Metadata().Timestamp,
 						"ntlm.response",
 						srcIP.String(),
 						dstIP.String(),
-						nil,
+						cred,
 						"%s %s > %s | %s",
 						tui.Wrap(tui.BACKDARKGRAY+tui.FOREWHITE, "ntlm.response"),
 						vIP(srcIP),
 						vIP(dstIP),
 						data.LcString(),
-					).Push()
+					).Push(pkt)
 				})
 			}
 		}
@@ -6,6 +6,7 @@ import (
 
 	"github.com/bettercap/bettercap/log"
 	"github.com/bettercap/bettercap/packets"
+	"github.com/bettercap/bettercap/session"
 
 	"github.com/gopacket/gopacket"
 	"github.com/gopacket/gopacket/layers"
@@ -29,7 +30,15 @@ func onUNK(srcIP, dstIP net.IP, payload []byte, pkt gopacket.Packet, verbose boo
 			vIP(srcIP),
 			vIP(dstIP),
 			tui.Dim(fmt.Sprintf("%d bytes", sz)),
-		).Push()
+		).Push(pkt)
+	}
+}
+
+// labelFlow tags the flow of the packet with the application protocol
+// detected by one of the parsers, if the flows module is tracking it.
+func labelFlow(pkt gopacket.Packet, app string) {
+	if session.I != nil && session.I.Queue != nil {
+		session.I.Queue.Flows.Label(pkt, app)
 	}
 }
 
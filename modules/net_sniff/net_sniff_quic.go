@@ -0,0 +1,73 @@
+package net_sniff
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/bettercap/bettercap/packets"
+
+	"github.com/gopacket/gopacket"
+	"github.com/gopacket/gopacket/layers"
+
+	"github.com/evilsocket/islazy/tui"
+)
+
+var quicHellos = packets.NewQUICClientHellos()
+
+func quicParser(srcIP, dstIP net.IP, payload []byte, pkt gopacket.Packet, udp *layers.UDP) bool {
+	data := udp.Payload
+	if len(data) < packets.QUICMinInitialSize || !packets.QUICIsInitial(data) {
+		return false
+	}
+
+	initial, err := packets.ParseQUICInitial(data)
+	if err != nil {
+		// server Initial packets are protected with the server keys
+		return false
+	}
+
+	flow := packets.AuthFlow{
+		Client:     fmt.Sprintf("%s:%d", srcIP, udp.SrcPort),
+		Server:     fmt.Sprintf("%s:%d", dstIP, udp.DstPort),
+		FromClient: true,
+	}
+	hello, err := quicHellos.Add(flow, initial)
+	if err != nil || hello == nil {
+		// wait for the rest of the handshake
+		return err == nil
+	}
+
+	domain := hello.ServerName
+	if domain == "" {
+		domain = dstIP.String()
+	}
+	if udp.DstPort != 443 {
+		domain = fmt.Sprintf("%s:%d", domain, udp.DstPort)
+	}
+
+	ja4 := hello.JA4('q')
+	addFingerprint(srcIP, "quic:ja4", ja4)
+
+	NewSnifferEvent(
+		pkt.Metadata().Timestamp,
+		"quic",
+		srcIP.String(),
+		domain,
+		SniffData{
+			"Version": fmt.Sprintf("0x%08x", initial.Version),
+			"DCID":    fmt.Sprintf("%x", initial.DCID),
+			"SNI":     hello.ServerName,
+			"ALPN":    hello.ALPN,
+			"JA4":     ja4,
+		},
+		"%s %s > %s %s %s",
+		tui.Wrap(tui.BACKYELLOW+tui.FOREWHITE, "quic"),
+		vIP(srcIP),
+		tui.Yellow("https://"+domain),
+		tui.Dim(strings.Join(hello.ALPN, ",")),
+		tui.Dim(ja4),
+	).Push(pkt)
+
+	return true
+}
@@ -3,8 +3,10 @@ package net_sniff
 import (
 	"fmt"
 	"net"
+	"strings"
 
-	"regexp"
+	"github.com/bettercap/bettercap/packets"
+	"github.com/bettercap/bettercap/session"
 
 	"github.com/gopacket/gopacket"
 	"github.com/gopacket/gopacket/layers"
@@ -12,38 +14,137 @@ import (
 	"github.com/evilsocket/islazy/tui"
 )
 
-// poor man's TLS Client Hello with SNI extension parser :P
-var sniRe = regexp.MustCompile("\x00\x00.{4}\x00.{2}([a-z0-9]+([\\-\\.]{1}[a-z0-9]+)*\\.[a-z]{2,6})\x00")
+// max number of distinct fingerprints kept in the metadata of an endpoint
+const maxFingerprints = 8
+
+var tlsHellos = packets.NewTLSHellos()
+
+// addFingerprint adds the fingerprint to the comma separated list stored in
+// the metadata of the endpoint with the given address, if known.
+func addFingerprint(ip net.IP, name string, fingerprint string) {
+	endpoint := session.I.Lan.GetByIp(ip.String())
+	if endpoint == nil {
+		return
+	}
+
+	list := []string{}
+	if value, ok := endpoint.Meta.Get(name).(string); ok && value != "" {
+		list = strings.Split(value, ",")
+	}
+	for _, f := range list {
+		if f == fingerprint {
+			return
+		}
+	}
+
+	list = append(list, fingerprint)
+	if len(list) > maxFingerprints {
+		list = list[len(list)-maxFingerprints:]
+	}
+
+	endpoint.OnMeta(map[string]string{
+		name: strings.Join(list, ","),
+	})
+}
 
 func sniParser(srcIP, dstIP net.IP, payload []byte, pkt gopacket.Packet, tcp *layers.TCP) bool {
-	data := tcp.Payload
-	dataSize := len(data)
+	src := fmt.Sprintf("%s:%d", srcIP, tcp.SrcPort)
+	dst := fmt.Sprintf("%s:%d", dstIP, tcp.DstPort)
 
-	if dataSize < 2 || data[0] != 0x16 || data[1] != 0x03 {
+	msg := tlsHellos.Add(src, dst, tcp.Payload)
+	if msg == nil {
 		return false
+	} else if msg[0] == packets.TLSHandshakeServerHello {
+		return onServerHello(srcIP, dstIP, msg, pkt, tcp)
 	}
+	return onClientHello(srcIP, dstIP, msg, pkt, tcp)
+}
 
-	m := sniRe.FindSubmatch(data)
-	if len(m) < 2 {
+func onClientHello(srcIP, dstIP net.IP, msg []byte, pkt gopacket.Packet, tcp *layers.TCP) bool {
+	hello, err := packets.ParseTLSClientHello(msg)
+	if err != nil {
 		return false
 	}
 
-	domain := string(m[1])
+	domain := hello.ServerName
+	if domain == "" {
+		domain = dstIP.String()
+	}
 	if tcp.DstPort != 443 {
 		domain = fmt.Sprintf("%s:%d", domain, tcp.DstPort)
 	}
 
+	ja3, ja4 := hello.JA3(), hello.JA4('t')
+	addFingerprint(srcIP, "tls:ja3", ja3)
+	addFingerprint(srcIP, "tls:ja4", ja4)
+
+	versions := make([]string, 0)
+	for _, v := range hello.SupportedVersions {
+		if !packets.TLSIsGREASE(v) {
+			versions = append(versions, fmt.Sprintf("0x%04x", v))
+		}
+	}
+
 	NewSnifferEvent(
 		pkt.Metadata().Timestamp,
 		"https",
 		srcIP.String(),
 		domain,
-		nil,
-		"%s %s > %s",
+		SniffData{
+			"SNI":        hello.ServerName,
+			"ALPN":       hello.ALPN,
+			"Version":    fmt.Sprintf("0x%04x", hello.MaxVersion()),
+			"Versions":   versions,
+			"Ciphers":    hello.CipherSuites,
+			"Extensions": hello.Extensions,
+			"JA3":        ja3,
+			"JA3String":  hello.JA3String(),
+			"JA4":        ja4,
+		},
+		"%s %s > %s %s",
 		tui.Wrap(tui.BACKYELLOW+tui.FOREWHITE, "sni"),
 		vIP(srcIP),
 		tui.Yellow("https://"+domain),
-	).Push()
+		tui.Dim(ja4),
+	).Push(pkt)
+
+	return true
+}
+
+func onServerHello(srcIP, dstIP net.IP, msg []byte, pkt gopacket.Packet, tcp *layers.TCP) bool {
+	hello, err := packets.ParseTLSServerHello(msg)
+	if err != nil {
+		return false
+	}
+
+	ja3s := hello.JA3S()
+	addFingerprint(srcIP, "tls:ja3s", ja3s)
+
+	version := hello.SupportedVersion
+	if version == 0 {
+		version = hello.Version
+	}
+
+	NewSnifferEvent(
+		pkt.Metadata().Timestamp,
+		"https",
+		fmt.Sprintf("%s:%d", srcIP, tcp.SrcPort),
+		dstIP.String(),
+		SniffData{
+			"ALPN":       hello.ALPN,
+			"Version":    fmt.Sprintf("0x%04x", version),
+			"Cipher":     hello.CipherSuite,
+			"Extensions": hello.Extensions,
+			"JA3S":       ja3s,
+			"JA3SString": hello.JA3SString(),
+		},
+		"%s %s:%s > %s %s",
+		tui.Wrap(tui.BACKYELLOW+tui.FOREWHITE, "tls"),
+		vIP(srcIP),
+		vPort(tcp.SrcPort),
+		vIP(dstIP),
+		tui.Dim("ja3s "+ja3s),
+	).Push(pkt)
 
 	return true
 }
@@ -10,18 +10,32 @@ import (
 	"github.com/evilsocket/islazy/tui"
 )
 
-var tcpParsers = []func(net.IP, net.IP, []byte, gopacket.Packet, *layers.TCP) bool{
-	sniParser,
-	ntlmParser,
-	httpParser,
-	ftpParser,
-	teamViewerParser,
+// the name of each parser is used to label the flows it matches
+var tcpParsers = []struct {
+	Name  string
+	Parse func(net.IP, net.IP, []byte, gopacket.Packet, *layers.TCP) bool
+}{
+	{"tls", sniParser},
+	{"ntlm", ntlmParser},
+	{"kerberos", krb5TCPParser},
+	{"mail", mailParser},
+	{"telnet", telnetParser},
+	{"ldap", ldapParser},
+	{"redis", redisParser},
+	{"postgresql", postgresParser},
+	{"mysql", mysqlParser},
+	{"vnc", vncParser},
+	{"sip", sipTCPParser},
+	{"http", httpParser},
+	{"ftp", ftpParser},
+	{"teamviewer", teamViewerParser},
 }
 
 func onTCP(srcIP, dstIP net.IP, payload []byte, pkt gopacket.Packet, verbose bool) {
 	tcp := pkt.Layer(layers.LayerTypeTCP).(*layers.TCP)
 	for _, parser := range tcpParsers {
-		if parser(srcIP, dstIP, payload, pkt, tcp) {
+		if parser.Parse(srcIP, dstIP, payload, pkt, tcp) {
+			labelFlow(pkt, parser.Name)
 			return
 		}
 	}
@@ -43,6 +57,6 @@ func onTCP(srcIP, dstIP net.IP, payload []byte, pkt gopacket.Packet, verbose boo
 			vIP(dstIP),
 			vPort(tcp.DstPort),
 			tui.Dim(fmt.Sprintf("%d bytes", sz)),
-		).Push()
+		).Push(pkt)
 	}
 }
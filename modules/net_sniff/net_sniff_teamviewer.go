@@ -25,7 +25,7 @@ func teamViewerParser(srcIP, dstIP net.IP, payload []byte, pkt gopacket.Packet,
 				vIP(srcIP),
 				tui.Yellow(tv.Command),
 				vIP(dstIP),
-			).Push()
+			).Push(pkt)
 			return true
 		}
 	}
@@ -10,17 +10,26 @@ import (
 	"github.com/evilsocket/islazy/tui"
 )
 
-var udpParsers = []func(net.IP, net.IP, []byte, gopacket.Packet, *layers.UDP) bool{
-	dnsParser,
-	mdnsParser,
-	krb5Parser,
-	upnpParser,
+// the name of each parser is used to label the flows it matches
+var udpParsers = []struct {
+	Name  string
+	Parse func(net.IP, net.IP, []byte, gopacket.Packet, *layers.UDP) bool
+}{
+	{"dns", dnsParser},
+	{"mdns", mdnsParser},
+	{"kerberos", krb5Parser},
+	{"quic", quicParser},
+	{"snmp", snmpParser},
+	{"sip", sipParser},
+	{"radius", radiusParser},
+	{"upnp", upnpParser},
 }
 
 func onUDP(srcIP, dstIP net.IP, payload []byte, pkt gopacket.Packet, verbose bool) {
 	udp := pkt.Layer(layers.LayerTypeUDP).(*layers.UDP)
 	for _, parser := range udpParsers {
-		if parser(srcIP, dstIP, payload, pkt, udp) {
+		if parser.Parse(srcIP, dstIP, payload, pkt, udp) {
+			labelFlow(pkt, parser.Name)
 			return
 		}
 	}
@@ -42,6 +51,6 @@ func onUDP(srcIP, dstIP net.IP, payload []byte, pkt gopacket.Packet, verbose boo
 			vIP(dstIP),
 			vPort(udp.DstPort),
 			tui.Dim(fmt.Sprintf("%d bytes", sz)),
-		).Push()
+		).Push(pkt)
 	}
 }
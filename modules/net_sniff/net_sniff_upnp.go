@@ -31,7 +31,7 @@ func upnpParser(srcIP, dstIP net.IP, payload []byte, pkt gopacket.Packet, udp *l
 			vIP(srcIP),
 			vIP(dstIP),
 			str.Trim(s),
-		).Push()
+		).Push(pkt)
 
 		return true
 	}
@@ -1,19 +1,26 @@
 package syn_scan
 
 import (
-	"fmt"
 	"time"
 
+	"github.com/bettercap/bettercap/network"
+
 	"github.com/evilsocket/islazy/async"
 )
 
-const bannerGrabTimeout = time.Duration(5) * time.Second
-
-type bannerGrabberFn func(mod *SynScanner, ip string, port int) string
+const (
+	bannerGrabTimeout = time.Duration(5) * time.Second
+	probeReadTimeout  = time.Duration(3) * time.Second
+	// once the first bytes arrived, wait this long for the rest
+	probeIdleTimeout = time.Duration(300) * time.Millisecond
+	probeMaxResponse = 64 * 1024
+)
 
 type grabberJob struct {
-	IP   string
-	Port *OpenPort
+	IP      string
+	Host    *network.Endpoint
+	Port    *OpenPort
+	Results *scanResults
 }
 
 func (mod *SynScanner) bannerGrabber(arg async.Job) {
@@ -24,18 +31,28 @@ func (mod *SynScanner) bannerGrabber(arg async.Job) {
 
 	ip := job.IP
 	port := job.Port.Port
-	sport := fmt.Sprintf("%d", port)
 
-	fn := tcpGrabber
-	if port == 80 || port == 443 || sport[0] == '8' {
-		fn = httpGrabber
-	} else if port == 53 || port == 5353 {
-		fn = dnsGrabber
+	err, intensity := mod.IntParam("syn.scan.version-intensity")
+	if err != nil {
+		mod.Warning("%v", err)
+		intensity = 7
+	} else if intensity < 0 {
+		intensity = 0
+	} else if intensity > 9 {
+		intensity = 9
 	}
 
-	mod.Debug("grabbing banner for %s:%d", ip, port)
-	job.Port.Banner = fn(mod, ip, port)
-	if job.Port.Banner != "" {
-		mod.Info("found banner for %s:%d -> %s", ip, port, job.Port.Banner)
+	mod.Debug("probing service on %s:%d", ip, port)
+	info, banner := mod.detectService(ip, port, intensity)
+	job.Results.OnService(job.Port, banner, info)
+	if info == nil {
+		if banner != "" {
+			mod.Info("found banner for %s:%d -> %s", ip, port, banner)
+		}
+		return
 	}
+
+	mod.Info("found %s on %s:%d", info, ip, port)
+
+	NewSynScanServiceEvent(ip, job.Host, job.Port).Push()
 }
@@ -0,0 +1,40 @@
+package syn_scan
+
+import (
+	"bytes"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+func isTitleElement(n *html.Node) bool {
+	return n.Type == html.ElementNode && strings.ToLower(n.Data) == "title"
+}
+
+func searchForTitle(n *html.Node) string {
+	if isTitleElement(n) && n.FirstChild != nil {
+		return n.FirstChild.Data
+	}
+
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if result := searchForTitle(c); result != "" {
+			return result
+		}
+	}
+
+	return ""
+}
+
+// httpTitle returns the title of the page in a raw HTTP response.
+func httpTitle(response []byte) string {
+	body := response
+	if idx := bytes.Index(response, []byte("\r\n\r\n")); idx != -1 {
+		body = response[idx+4:]
+	}
+
+	doc, err := html.Parse(bytes.NewReader(body))
+	if err != nil {
+		return ""
+	}
+	return cleanBanner(strings.TrimSpace(searchForTitle(doc)))
+}
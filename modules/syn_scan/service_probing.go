@@ -0,0 +1,146 @@
+package syn_scan
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bettercap/bettercap/network"
+)
+
+func cleanBanner(banner string) string {
+	clean := ""
+	for _, c := range banner {
+		if strconv.IsPrint(c) {
+			clean += string(c)
+		}
+	}
+	return clean
+}
+
+func readResponse(conn net.Conn) []byte {
+	response := make([]byte, 0)
+	buf := make([]byte, 4096)
+
+	conn.SetReadDeadline(time.Now().Add(probeReadTimeout))
+	for len(response) < probeMaxResponse {
+		n, err := conn.Read(buf)
+		response = append(response, buf[:n]...)
+		if err != nil {
+			break
+		}
+		conn.SetReadDeadline(time.Now().Add(probeIdleTimeout))
+	}
+
+	return response
+}
+
+func (mod *SynScanner) sendProbe(addr string, probe *network.ServiceProbe, overTLS bool) ([]byte, error) {
+	dialer := net.Dialer{
+		Timeout: bannerGrabTimeout,
+	}
+
+	conn, err := dialer.Dial("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if overTLS {
+		tlsConn := tls.Client(conn, &tls.Config{InsecureSkipVerify: true})
+		tlsConn.SetDeadline(time.Now().Add(bannerGrabTimeout))
+		if err = tlsConn.Handshake(); err != nil {
+			return nil, err
+		}
+		tlsConn.SetDeadline(time.Time{})
+		conn = tlsConn
+	}
+
+	if len(probe.Payload) > 0 {
+		conn.SetWriteDeadline(time.Now().Add(bannerGrabTimeout))
+		if _, err = conn.Write(probe.Payload); err != nil {
+			return nil, err
+		}
+	}
+
+	return readResponse(conn), nil
+}
+
+// runProbes sends the probes one by one until one of them identifies the
+// service, it returns the best match and the response that produced it.
+func (mod *SynScanner) runProbes(addr string, port int, intensity int, overTLS bool) (*network.ServiceInfo, *network.ServiceProbe, []byte) {
+	var best *network.ServiceInfo
+	var bestProbe *network.ServiceProbe
+	var bestResponse []byte
+
+	for _, probe := range network.ServiceProbesFor(port, intensity) {
+		if overTLS && probe == network.ServiceProbeTLS() {
+			continue
+		}
+
+		response, err := mod.sendProbe(addr, probe, overTLS)
+		if err != nil {
+			mod.Debug("%s probe to %s: %v", probe.Name, addr, err)
+			if opErr, ok := err.(*net.OpError); ok && opErr.Op == "dial" {
+				// the port is not reachable anymore
+				break
+			}
+			continue
+		}
+
+		info, soft := probe.Match(response)
+		if info == nil {
+			if bestResponse == nil && len(response) > 0 {
+				bestProbe, bestResponse = probe, response
+			}
+			continue
+		} else if !soft {
+			return info, probe, response
+		} else if best == nil {
+			best, bestProbe, bestResponse = info, probe, response
+		}
+	}
+
+	return best, bestProbe, bestResponse
+}
+
+// detectService identifies the service listening on the port and returns it
+// together with a short banner.
+func (mod *SynScanner) detectService(ip string, port int, intensity int) (*network.ServiceInfo, string) {
+	// https://stackoverflow.com/questions/12260003/connect-returns-invalid-argument-with-ipv6-address
+	if parsed := net.ParseIP(ip); parsed != nil && parsed.To4() == nil && parsed.IsLinkLocalUnicast() {
+		ip = ip + "%" + mod.Session.Interface.Name()
+	}
+	addr := net.JoinHostPort(ip, strconv.Itoa(port))
+
+	info, probe, response := mod.runProbes(addr, port, intensity, false)
+	if info != nil && info.Service == "ssl" {
+		if tunneled, tunneledProbe, tunneledResponse := mod.runProbes(addr, port, intensity, true); tunneled != nil {
+			overTLS := tunneled.OverTLS()
+			info, probe, response = &overTLS, tunneledProbe, tunneledResponse
+		}
+	}
+
+	return info, bannerOf(info, probe, response)
+}
+
+func bannerOf(info *network.ServiceInfo, probe *network.ServiceProbe, response []byte) string {
+	if len(response) == 0 {
+		return ""
+	} else if info != nil && (info.Service == "http" || info.Service == "https") {
+		if title := httpTitle(response); title != "" {
+			return title
+		}
+	}
+
+	// only the services talking first have a meaningful text banner
+	if probe != nil && len(probe.Payload) == 0 {
+		line, _ := bufio.NewReader(bytes.NewReader(response)).ReadString('\n')
+		return cleanBanner(strings.Trim(line, "\r\n\t "))
+	}
+	return ""
+}
@@ -3,6 +3,7 @@ package syn_scan
 import (
 	"fmt"
 	"net"
+	"sort"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -17,7 +18,11 @@ import (
 	"github.com/gopacket/gopacket/pcap"
 )
 
-const synSourcePort = 666
+const (
+	synSourcePort = 666
+	// how long to wait for late UDP replies and ICMP errors
+	udpReplyTimeout = time.Duration(2) * time.Second
+)
 
 type synScannerStats struct {
 	numPorts     uint64
@@ -25,6 +30,7 @@ type synScannerStats struct {
 	totProbes    uint64
 	doneProbes   uint64
 	openPorts    uint64
+	retransmits  uint64
 	started      time.Time
 }
 
@@ -40,6 +46,25 @@ type SynScanner struct {
 	waitGroup     *sync.WaitGroup
 	scanQueue     *async.WorkQueue
 	bannerQueue   *async.WorkQueue
+	// "tcp" or "udp"
+	protocol   string
+	udpLock    sync.Mutex
+	udpReplies map[string]map[int]string
+	// probes waiting for a reply, by address and port
+	pendingLock sync.Mutex
+	pending     map[string]map[int]*pendingProbe
+	limiter     *rateLimiter
+	hostRate    int
+	retries     int
+	timing      *rttEstimator
+	state       *scanState
+	resultsLock sync.Mutex
+	results     *scanResults
+}
+
+type pendingProbe struct {
+	sent  time.Time
+	tries int
 }
 
 func NewSynScanner(s *session.Session) *SynScanner {
@@ -48,6 +73,9 @@ func NewSynScanner(s *session.Session) *SynScanner {
 		addresses:     make([]net.IP, 0),
 		waitGroup:     &sync.WaitGroup{},
 		progressEvery: time.Duration(1) * time.Second,
+		protocol:      "tcp",
+		udpReplies:    make(map[string]map[int]string),
+		pending:       make(map[string]map[int]*pendingProbe),
 	}
 
 	mod.scanQueue = async.NewQueue(0, mod.scanWorker)
@@ -60,6 +88,32 @@ func NewSynScanner(s *session.Session) *SynScanner {
 		"1",
 		"Period in seconds for the scanning progress reporting."))
 
+	mod.AddParam(session.NewIntParameter("syn.scan.version-intensity",
+		"7",
+		"From 0 to 9, how many service probes to send to each open port besides the ones for its number."))
+
+	mod.AddParam(session.NewIntParameter("syn.scan.rate",
+		"1000",
+		"Maximum number of probes per second, it is lowered automatically when probes get lost, 0 for no limit."))
+
+	mod.AddParam(session.NewIntParameter("syn.scan.host-rate",
+		"100",
+		"Maximum number of probes per second to each host, 0 for no limit."))
+
+	mod.AddParam(session.NewIntParameter("syn.scan.retries",
+		"1",
+		"How many times to resend the probes which got no reply."))
+
+	mod.AddParam(session.NewStringParameter("syn.scan.state-file",
+		"~/bettercap-syn-scan.json",
+		"",
+		"File where the progress of the scan is saved so that it can be resumed with syn.scan resume, empty to disable."))
+
+	mod.AddParam(session.NewStringParameter("syn.scan.export.file",
+		"",
+		"",
+		"If set, the results will be exported to this file when a scan completes or is stopped, as line delimited JSON if its extension is .json or .jsonl, as nmap XML otherwise."))
+
 	mod.AddHandler(session.NewModuleHandler("syn.scan stop", "syn\\.scan (stop|off)",
 		"Stop the current syn scanning session.",
 		func(args []string) error {
@@ -69,22 +123,28 @@ func NewSynScanner(s *session.Session) *SynScanner {
 			return mod.Stop()
 		}))
 
+	mod.AddHandler(session.NewModuleHandler("syn.scan resume", "syn\\.scan resume",
+		"Resume the last interrupted scan.",
+		func(args []string) error {
+			return mod.resumeScan()
+		}))
+
 	mod.AddHandler(session.NewModuleHandler("syn.scan IP-RANGE START-PORT END-PORT", "syn.scan ([^\\s]+) ?(\\d+)?([\\s\\d]*)?",
-		"Perform a syn port scanning against an IP address within the provided ports range.",
+		"Perform a syn port scanning against an IP address within the provided ports range, IP-RANGE can also be a list of IPv6 addresses or /112 networks, or ipv6 for all the IPv6 neighbors found by net.recon.",
 		func(args []string) error {
-			period := 0
-			if mod.Running() {
-				return fmt.Errorf("A scan is already running, wait for it to end before starting a new one.")
-			} else if err := mod.parseTargets(args[0]); err != nil {
-				return err
-			} else if err = mod.parsePorts(args); err != nil {
-				return err
-			} else if err, period = mod.IntParam("syn.scan.show-progress-every"); err != nil {
-				return err
-			} else {
-				mod.progressEvery = time.Duration(period) * time.Second
-			}
-			return mod.synScan()
+			return mod.startScan("tcp", args)
+		}))
+
+	mod.AddHandler(session.NewModuleHandler("syn.scan.udp IP-RANGE START-PORT END-PORT", "syn\\.scan\\.udp ([^\\s]+) ?(\\d+)?([\\s\\d]*)?",
+		"Perform a UDP port scanning against an IP address within the provided ports range, sending protocol specific payloads to DNS, NTP, NetBIOS, SNMP and SSDP.",
+		func(args []string) error {
+			return mod.startScan("udp", args)
+		}))
+
+	mod.AddHandler(session.NewModuleHandler("syn.scan.export FILENAME", `syn\.scan\.export\s+(.+)`,
+		"Export the results of the last scan to FILENAME, as line delimited JSON if its extension is .json or .jsonl, as nmap XML otherwise.",
+		func(args []string) error {
+			return mod.export(args[0])
 		}))
 
 	mod.AddHandler(session.NewModuleHandler("syn.scan.progress", "syn\\.scan\\.progress",
@@ -118,7 +178,7 @@ func (mod *SynScanner) Configure() (err error) {
 	if mod.handle == nil {
 		if mod.handle, err = network.Capture(mod.Session.Interface.Name()); err != nil {
 			return err
-		} else if err = mod.handle.SetBPFFilter(fmt.Sprintf("tcp dst port %d", synSourcePort)); err != nil {
+		} else if err = mod.handle.SetBPFFilter(fmt.Sprintf("((tcp or udp) and dst port %d) or (icmp and icmp[icmptype] == icmp-unreach) or (icmp6 and ip6[40] == 1)", synSourcePort)); err != nil {
 			return err
 		}
 		mod.packets = gopacket.NewPacketSource(mod.handle, mod.handle.LinkType()).Packets()
@@ -126,6 +186,94 @@ func (mod *SynScanner) Configure() (err error) {
 	return nil
 }
 
+func (mod *SynScanner) configureTiming() (err error) {
+	period := 0
+	rate := 0
+	if err, period = mod.IntParam("syn.scan.show-progress-every"); err != nil {
+		return err
+	} else if err, rate = mod.IntParam("syn.scan.rate"); err != nil {
+		return err
+	} else if err, mod.hostRate = mod.IntParam("syn.scan.host-rate"); err != nil {
+		return err
+	} else if err, mod.retries = mod.IntParam("syn.scan.retries"); err != nil {
+		return err
+	}
+
+	mod.progressEvery = time.Duration(period) * time.Second
+	mod.limiter = newRateLimiter(rate)
+	mod.timing = &rttEstimator{}
+	return nil
+}
+
+func (mod *SynScanner) startScan(protocol string, args []string) error {
+	if mod.Running() {
+		return fmt.Errorf("A scan is already running, wait for it to end before starting a new one.")
+	} else if err := mod.parseTargets(args[0]); err != nil {
+		return err
+	} else if err = mod.parsePorts(args); err != nil {
+		return err
+	} else if err = mod.configureTiming(); err != nil {
+		return err
+	}
+
+	err, stateFile := mod.StringParam("syn.scan.state-file")
+	if err != nil {
+		return err
+	} else if mod.state, err = newScanState(stateFile, protocol, mod.addresses, mod.startPort, mod.endPort); err != nil {
+		return err
+	}
+
+	mod.protocol = protocol
+	mod.resetResults()
+	return mod.synScan()
+}
+
+func (mod *SynScanner) resetResults() {
+	mod.resultsLock.Lock()
+	defer mod.resultsLock.Unlock()
+	mod.results = newScanResults(mod.protocol, mod.startPort, mod.endPort, len(mod.state.cp.Addresses))
+}
+
+func (mod *SynScanner) resumeScan() error {
+	if mod.Running() {
+		return fmt.Errorf("A scan is already running, wait for it to end before starting a new one.")
+	}
+
+	err, stateFile := mod.StringParam("syn.scan.state-file")
+	if err != nil {
+		return err
+	} else if mod.state, err = loadScanState(stateFile); err != nil {
+		return err
+	} else if err = mod.configureTiming(); err != nil {
+		return err
+	}
+
+	mod.protocol = mod.state.cp.Protocol
+	mod.startPort = mod.state.cp.StartPort
+	mod.endPort = mod.state.cp.EndPort
+	mod.addresses = mod.state.Addresses()
+	mod.resetResults()
+
+	// report again what was found before the interruption
+	for address, ports := range mod.state.Open() {
+		if ip := net.ParseIP(address); ip != nil {
+			for _, port := range ports {
+				mod.reportPort(ip, mod.protocol, port, portOpen)
+			}
+		}
+	}
+
+	if len(mod.addresses) == 0 {
+		mod.state.Remove()
+		return fmt.Errorf("the last scan was already completed")
+	}
+
+	mod.Info("resuming %s scan of %d host%s updated at %s", mod.protocol, len(mod.addresses),
+		plural(uint64(len(mod.addresses))), mod.state.cp.Updated.Format("2006-01-02 15:04:05"))
+
+	return mod.synScan()
+}
+
 func (mod *SynScanner) Start() error {
 	return nil
 }
@@ -140,7 +288,11 @@ func plural(n uint64) string {
 func (mod *SynScanner) showProgress() error {
 	progress := 100.0 * (float64(mod.stats.doneProbes) / float64(mod.stats.totProbes))
 	mod.State.Store("progress", progress)
-	mod.Info("[%.2f%%] found %d open port%s for %d address%s, sent %d/%d packets in %s",
+	rate := "unlimited"
+	if mod.limiter != nil && mod.limiter.max > 0 {
+		rate = fmt.Sprintf("%.0f pps", mod.limiter.Rate())
+	}
+	mod.Info("[%.2f%%] found %d open port%s for %d address%s, sent %d/%d packets (%d retransmitted, %s) in %s",
 		progress,
 		mod.stats.openPorts,
 		plural(mod.stats.openPorts),
@@ -148,6 +300,8 @@ func (mod *SynScanner) showProgress() error {
 		plural(mod.stats.numAddresses),
 		mod.stats.doneProbes,
 		mod.stats.totProbes,
+		mod.stats.retransmits,
+		rate,
 		time.Since(mod.stats.started))
 	return nil
 }
@@ -165,8 +319,84 @@ type scanJob struct {
 	Mac     net.HardwareAddr
 }
 
+func (mod *SynScanner) sendScanProbe(scan scanJob, fromIP net.IP, fromHW net.HardwareAddr, dstPort int, hostLimiter *rateLimiter) {
+	hostLimiter.Wait()
+	mod.limiter.Wait()
+
+	var err error
+	var raw []byte
+	if mod.protocol == "udp" {
+		err, raw = packets.NewUDPScanProbe(fromIP, fromHW, scan.Address, scan.Mac, synSourcePort, dstPort)
+	} else {
+		err, raw = packets.NewTCPSyn(fromIP, fromHW, scan.Address, scan.Mac, synSourcePort, dstPort)
+	}
+	if err != nil {
+		mod.Error("error creating %s probe: %s", mod.protocol, err)
+		return
+	}
+
+	address := scan.Address.String()
+	mod.pendingLock.Lock()
+	ports, found := mod.pending[address]
+	if !found {
+		ports = make(map[int]*pendingProbe)
+		mod.pending[address] = ports
+	}
+	probe, found := ports[dstPort]
+	if !found {
+		probe = &pendingProbe{}
+		ports[dstPort] = probe
+	}
+	probe.sent = time.Now()
+	probe.tries++
+	mod.pendingLock.Unlock()
+
+	if err := mod.Session.Queue.Send(raw); err != nil {
+		mod.Error("error sending %s probe: %s", mod.protocol, err)
+	} else {
+		mod.Debug("sent %d bytes of %s probe to %s for port %d", len(raw), mod.protocol, address, dstPort)
+	}
+}
+
+// unanswered returns the ports of the address whose probes got no reply.
+func (mod *SynScanner) unanswered(address string) []int {
+	mod.pendingLock.Lock()
+	defer mod.pendingLock.Unlock()
+
+	ports := make([]int, 0, len(mod.pending[address]))
+	for port := range mod.pending[address] {
+		ports = append(ports, port)
+	}
+	sort.Ints(ports)
+	return ports
+}
+
+// onReply updates the timings with the reply to a probe.
+func (mod *SynScanner) onReply(ip net.IP, port int) {
+	address := ip.String()
+	mod.results.OnReply(ip)
+
+	mod.pendingLock.Lock()
+	probe, found := mod.pending[address][port]
+	if found {
+		delete(mod.pending[address], port)
+	}
+	mod.pendingLock.Unlock()
+
+	if !found {
+		return
+	} else if probe.tries == 1 {
+		mod.timing.Sample(time.Since(probe.sent))
+		mod.limiter.OnReply()
+	} else {
+		// either the first probe or its reply got lost
+		mod.limiter.OnDrop()
+	}
+}
+
 func (mod *SynScanner) scanWorker(job async.Job) {
 	scan := job.(scanJob)
+	address := scan.Address.String()
 
 	fromHW := mod.Session.Interface.HW
 	fromIP := mod.Session.Interface.IP
@@ -174,29 +404,73 @@ func (mod *SynScanner) scanWorker(job async.Job) {
 		fromIP = mod.Session.Interface.IPv6
 	}
 
+	hostLimiter := newRateLimiter(mod.hostRate)
+
 	for dstPort := mod.startPort; dstPort < mod.endPort+1; dstPort++ {
 		if !mod.Running() {
-			break
+			return
 		}
 
 		atomic.AddUint64(&mod.stats.doneProbes, 1)
+		mod.sendScanProbe(scan, fromIP, fromHW, dstPort, hostLimiter)
+	}
 
-		err, raw := packets.NewTCPSyn(fromIP, fromHW, scan.Address, scan.Mac, synSourcePort, dstPort)
-		if err != nil {
-			mod.Error("error creating SYN packet: %s", err)
-			continue
+	for try := 0; try < mod.retries; try++ {
+		time.Sleep(mod.timing.Timeout())
+
+		ports := mod.unanswered(address)
+		if len(ports) == 0 {
+			break
 		}
 
-		if err := mod.Session.Queue.Send(raw); err != nil {
-			mod.Error("error sending SYN packet: %s", err)
-		} else {
-			mod.Debug("sent %d bytes of SYN packet to %s for port %d", len(raw), scan.Address.String(), dstPort)
+		mod.Debug("resending %d %s probes to %s", len(ports), mod.protocol, address)
+		for _, dstPort := range ports {
+			if !mod.Running() {
+				return
+			}
+			atomic.AddUint64(&mod.stats.retransmits, 1)
+			mod.sendScanProbe(scan, fromIP, fromHW, dstPort, hostLimiter)
 		}
+	}
+
+	// wait for the last replies
+	time.Sleep(mod.timing.Timeout())
 
-		time.Sleep(time.Duration(15) * time.Millisecond)
+	mod.pendingLock.Lock()
+	delete(mod.pending, address)
+	mod.pendingLock.Unlock()
+
+	// an interrupted host is scanned again when resuming
+	if !mod.Running() {
+		return
+	}
+
+	mod.state.SetDone(address)
+	if err := mod.state.Save(); err != nil {
+		mod.Warning("error saving the scan state: %v", err)
 	}
 }
 
+// onScanDone finalizes both the completed and the interrupted scans.
+func (mod *SynScanner) onScanDone() {
+	mod.showProgress()
+	// keep the checkpoint of an interrupted scan around to resume it
+	if err := mod.state.Save(); err != nil {
+		mod.Warning("error saving the scan state: %v", err)
+	}
+	mod.results.OnDone()
+	if err, fileName := mod.StringParam("syn.scan.export.file"); err != nil {
+		mod.Warning("%v", err)
+	} else if fileName != "" {
+		if err = mod.export(fileName); err != nil {
+			mod.Error("error exporting the scan results: %v", err)
+		}
+	}
+	mod.addresses = []net.IP{}
+	mod.State.Store("progress", 0.0)
+	mod.State.Store("scanning", &mod.addresses)
+}
+
 func (mod *SynScanner) synScan() error {
 	if err := mod.Configure(); err != nil {
 		return err
@@ -206,29 +480,36 @@ func (mod *SynScanner) synScan() error {
 		mod.waitGroup.Add(1)
 		defer mod.waitGroup.Done()
 
-		defer mod.SetRunning(false, func() {
-			mod.showProgress()
-			mod.addresses = []net.IP{}
-			mod.State.Store("progress", 0.0)
-			mod.State.Store("scanning", &mod.addresses)
-			mod.packets <- nil
-		})
+		defer func() {
+			// the module is already stopped if the scan was interrupted
+			mod.SetRunning(false, func() {
+				mod.packets <- nil
+			})
+			mod.onScanDone()
+		}()
 
 		mod.stats.openPorts = 0
 		mod.stats.numPorts = uint64(mod.endPort - mod.startPort + 1)
 		mod.stats.started = time.Now()
 		mod.stats.numAddresses = uint64(len(mod.addresses))
-		mod.stats.totProbes = mod.stats.numAddresses * mod.stats.numPorts
-		mod.stats.doneProbes = 0
+		mod.stats.totProbes = uint64(len(mod.state.cp.Addresses)) * mod.stats.numPorts
+		mod.stats.doneProbes = mod.state.Probed()
+		mod.stats.retransmits = 0
+		mod.pendingLock.Lock()
+		mod.pending = make(map[string]map[int]*pendingProbe)
+		mod.pendingLock.Unlock()
+		mod.udpLock.Lock()
+		mod.udpReplies = make(map[string]map[int]string)
+		mod.udpLock.Unlock()
 		plural := "es"
 		if mod.stats.numAddresses == 1 {
 			plural = ""
 		}
 
 		if mod.stats.numPorts > 1 {
-			mod.Info("scanning %d address%s from port %d to port %d ...", mod.stats.numAddresses, plural, mod.startPort, mod.endPort)
+			mod.Info("scanning %d address%s from %s port %d to port %d ...", mod.stats.numAddresses, plural, mod.protocol, mod.startPort, mod.endPort)
 		} else {
-			mod.Info("scanning %d address%s on port %d ...", mod.stats.numAddresses, plural, mod.startPort)
+			mod.Info("scanning %d address%s on %s port %d ...", mod.stats.numAddresses, plural, mod.protocol, mod.startPort)
 		}
 
 		mod.State.Store("progress", 0.0)
@@ -252,6 +533,9 @@ func (mod *SynScanner) synScan() error {
 				time.Sleep(mod.progressEvery)
 				if mod.Running() {
 					mod.showProgress()
+					if err := mod.state.Save(); err != nil {
+						mod.Warning("error saving the scan state: %v", err)
+					}
 				} else {
 					break
 				}
@@ -263,9 +547,18 @@ func (mod *SynScanner) synScan() error {
 			if !mod.Running() {
 				break
 			}
-			mac, err := mod.Session.FindMAC(address, true)
+			if address.To4() == nil && mod.Session.Interface.IPv6 == nil {
+				atomic.AddUint64(&mod.stats.doneProbes, mod.stats.numPorts)
+				mod.Warning("can't scan %s, %s has no IPv6 address", address.String(), mod.Session.Interface.Name())
+				mod.state.SetDone(address.String())
+				continue
+			}
+
+			mac, err := mod.findMAC(address)
 			if err != nil {
 				atomic.AddUint64(&mod.stats.doneProbes, mod.stats.numPorts)
+				// nothing to resume for hosts which are unreachable
+				mod.state.SetDone(address.String())
 				mod.Debug("could not get MAC for %s: %s", address.String(), err)
 				continue
 			}
@@ -277,6 +570,19 @@ func (mod *SynScanner) synScan() error {
 		}
 
 		mod.scanQueue.WaitDone()
+
+		if mod.protocol == "udp" && mod.Running() {
+			time.Sleep(udpReplyTimeout)
+			mod.onUDPScanDone()
+		}
+
+		if mod.Running() {
+			// give the service detection a chance to complete before exporting
+			mod.bannerQueue.WaitDone()
+			if err := mod.state.Remove(); err != nil {
+				mod.Warning("error removing the scan state: %v", err)
+			}
+		}
 	})
 
 	return nil
@@ -9,13 +9,30 @@ type SynScanEvent struct {
 	Address string
 	Host    *network.Endpoint
 	Port    int
+	Proto   string
+	State   string
+	// set once the service listening on the port has been identified
+	Details *OpenPort
 }
 
-func NewSynScanEvent(address string, h *network.Endpoint, port int) SynScanEvent {
+func NewSynScanEvent(address string, h *network.Endpoint, port *OpenPort) SynScanEvent {
 	return SynScanEvent{
 		Address: address,
 		Host:    h,
-		Port:    port,
+		Port:    port.Port,
+		Proto:   port.Proto,
+		State:   port.State,
+	}
+}
+
+func NewSynScanServiceEvent(address string, h *network.Endpoint, port *OpenPort) SynScanEvent {
+	return SynScanEvent{
+		Address: address,
+		Host:    h,
+		Port:    port.Port,
+		Proto:   port.Proto,
+		State:   port.State,
+		Details: port,
 	}
 }
 
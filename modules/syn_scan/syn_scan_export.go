@@ -0,0 +1,463 @@
+package syn_scan
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bettercap/bettercap/core"
+	"github.com/bettercap/bettercap/network"
+	"github.com/bettercap/bettercap/packets"
+
+	"github.com/evilsocket/islazy/fs"
+)
+
+// scanHost holds what has been found for a scanned address.
+type scanHost struct {
+	IP    net.IP
+	Seen  time.Time
+	Ports map[string]*OpenPort
+}
+
+// scanResults collects the hosts that replied during the last scan.
+type scanResults struct {
+	sync.Mutex
+	Protocol  string
+	StartPort int
+	EndPort   int
+	Targets   int
+	Started   time.Time
+	Finished  time.Time
+	hosts     map[string]*scanHost
+}
+
+func newScanResults(protocol string, startPort, endPort int, targets int) *scanResults {
+	return &scanResults{
+		Protocol:  protocol,
+		StartPort: startPort,
+		EndPort:   endPort,
+		Targets:   targets,
+		Started:   time.Now(),
+		hosts:     make(map[string]*scanHost),
+	}
+}
+
+func (r *scanResults) host(ip net.IP) *scanHost {
+	address := ip.String()
+	h, found := r.hosts[address]
+	if !found {
+		h = &scanHost{
+			IP:    ip,
+			Ports: make(map[string]*OpenPort),
+		}
+		r.hosts[address] = h
+	}
+	h.Seen = time.Now()
+	return h
+}
+
+// OnReply marks the address as up, even if none of its ports is open.
+func (r *scanResults) OnReply(ip net.IP) {
+	r.Lock()
+	defer r.Unlock()
+	r.host(ip)
+}
+
+func (r *scanResults) OnPort(ip net.IP, port *OpenPort) {
+	r.Lock()
+	defer r.Unlock()
+	r.host(ip).Ports[fmt.Sprintf("%s/%d", port.Proto, port.Port)] = port
+}
+
+// OnService updates the port with what the service detection found, while
+// the results can be exported.
+func (r *scanResults) OnService(port *OpenPort, banner string, info *network.ServiceInfo) {
+	r.Lock()
+	defer r.Unlock()
+
+	port.Banner = banner
+	if info != nil {
+		port.Service = info.Service
+		port.Product = info.Product
+		port.Version = info.Version
+		port.Info = info.Info
+		port.CPE = info.CPE
+	}
+}
+
+func (r *scanResults) OnDone() {
+	r.Lock()
+	defer r.Unlock()
+	r.Finished = time.Now()
+}
+
+// Hosts returns the hosts sorted by address, with a copy of their ports
+// sorted by protocol and number.
+func (r *scanResults) Hosts() ([]*scanHost, [][]*OpenPort) {
+	r.Lock()
+	defer r.Unlock()
+
+	hosts := make([]*scanHost, 0, len(r.hosts))
+	for _, h := range r.hosts {
+		hosts = append(hosts, h)
+	}
+	sort.Slice(hosts, func(i, j int) bool {
+		return bytes.Compare(hosts[i].IP.To16(), hosts[j].IP.To16()) < 0
+	})
+
+	ports := make([][]*OpenPort, len(hosts))
+	for i, h := range hosts {
+		for _, p := range h.Ports {
+			port := *p
+			ports[i] = append(ports[i], &port)
+		}
+		sort.Slice(ports[i], func(a, b int) bool {
+			if ports[i][a].Proto != ports[i][b].Proto {
+				return ports[i][a].Proto < ports[i][b].Proto
+			}
+			return ports[i][a].Port < ports[i][b].Port
+		})
+	}
+
+	return hosts, ports
+}
+
+// the subset of the nmap XML output format (https://nmap.org/book/nmap-dtd.html)
+// understood by the tools parsing it
+type nmapRun struct {
+	XMLName          xml.Name     `xml:"nmaprun"`
+	Scanner          string       `xml:"scanner,attr"`
+	Args             string       `xml:"args,attr"`
+	Start            int64        `xml:"start,attr"`
+	StartStr         string       `xml:"startstr,attr"`
+	Version          string       `xml:"version,attr"`
+	XMLOutputVersion string       `xml:"xmloutputversion,attr"`
+	ScanInfo         nmapScanInfo `xml:"scaninfo"`
+	Hosts            []nmapHost   `xml:"host"`
+	RunStats         nmapRunStats `xml:"runstats"`
+}
+
+type nmapScanInfo struct {
+	Type        string `xml:"type,attr"`
+	Protocol    string `xml:"protocol,attr"`
+	NumServices int    `xml:"numservices,attr"`
+	Services    string `xml:"services,attr"`
+}
+
+type nmapHost struct {
+	StartTime int64          `xml:"starttime,attr"`
+	EndTime   int64          `xml:"endtime,attr"`
+	Status    nmapStatus     `xml:"status"`
+	Addresses []nmapAddress  `xml:"address"`
+	Hostnames []nmapHostname `xml:"hostnames>hostname"`
+	Ports     []nmapPort     `xml:"ports>port"`
+	OS        *nmapOS        `xml:"os,omitempty"`
+}
+
+type nmapStatus struct {
+	State  string `xml:"state,attr"`
+	Reason string `xml:"reason,attr"`
+}
+
+type nmapAddress struct {
+	Addr     string `xml:"addr,attr"`
+	AddrType string `xml:"addrtype,attr"`
+	Vendor   string `xml:"vendor,attr,omitempty"`
+}
+
+type nmapHostname struct {
+	Name string `xml:"name,attr"`
+	Type string `xml:"type,attr"`
+}
+
+type nmapPort struct {
+	Protocol string       `xml:"protocol,attr"`
+	PortID   int          `xml:"portid,attr"`
+	State    nmapState    `xml:"state"`
+	Service  *nmapService `xml:"service,omitempty"`
+	Scripts  []nmapScript `xml:"script"`
+}
+
+type nmapState struct {
+	State     string `xml:"state,attr"`
+	Reason    string `xml:"reason,attr"`
+	ReasonTTL int    `xml:"reason_ttl,attr"`
+}
+
+type nmapService struct {
+	Name      string   `xml:"name,attr"`
+	Product   string   `xml:"product,attr,omitempty"`
+	Version   string   `xml:"version,attr,omitempty"`
+	ExtraInfo string   `xml:"extrainfo,attr,omitempty"`
+	Method    string   `xml:"method,attr"`
+	Conf      int      `xml:"conf,attr"`
+	CPE       []string `xml:"cpe,omitempty"`
+}
+
+type nmapScript struct {
+	ID     string `xml:"id,attr"`
+	Output string `xml:"output,attr"`
+}
+
+type nmapOS struct {
+	Matches []nmapOSMatch `xml:"osmatch"`
+}
+
+type nmapOSMatch struct {
+	Name     string `xml:"name,attr"`
+	Accuracy int    `xml:"accuracy,attr"`
+}
+
+type nmapRunStats struct {
+	Finished nmapFinished  `xml:"finished"`
+	Hosts    nmapHostStats `xml:"hosts"`
+}
+
+type nmapFinished struct {
+	Time    int64  `xml:"time,attr"`
+	TimeStr string `xml:"timestr,attr"`
+	Elapsed string `xml:"elapsed,attr"`
+	Summary string `xml:"summary,attr"`
+	Exit    string `xml:"exit,attr"`
+}
+
+type nmapHostStats struct {
+	Up    int `xml:"up,attr"`
+	Down  int `xml:"down,attr"`
+	Total int `xml:"total,attr"`
+}
+
+func nmapReason(port *OpenPort) string {
+	if port.State == packets.UDPPortOpenFiltered {
+		return "no-response"
+	} else if port.Proto == "udp" {
+		return "udp-response"
+	}
+	return "syn-ack"
+}
+
+func nmapServiceOf(port *OpenPort) *nmapService {
+	if port.Service == "" {
+		return nil
+	}
+
+	service := &nmapService{
+		Name:      port.Service,
+		Product:   port.Product,
+		Version:   port.Version,
+		ExtraInfo: port.Info,
+		// guessed from the port number
+		Method: "table",
+		Conf:   3,
+	}
+	if port.Product != "" || port.Version != "" || port.CPE != "" {
+		service.Method = "probed"
+		service.Conf = 10
+	}
+	if port.CPE != "" {
+		service.CPE = []string{port.CPE}
+	}
+	return service
+}
+
+// toNmapXML renders the results in the same format as nmap -oX.
+func (mod *SynScanner) toNmapXML(results *scanResults) ([]byte, error) {
+	hosts, ports := results.Hosts()
+
+	results.Lock()
+	started := results.Started
+	finished := results.Finished
+	results.Unlock()
+	if finished.IsZero() {
+		finished = time.Now()
+	}
+
+	scanType := "syn"
+	if results.Protocol == "udp" {
+		scanType = "udp"
+	}
+
+	run := nmapRun{
+		Scanner:          "bettercap",
+		Args:             fmt.Sprintf("%s %d-%d", mod.Name(), results.StartPort, results.EndPort),
+		Start:            started.Unix(),
+		StartStr:         started.Format(time.ANSIC),
+		Version:          core.Version,
+		XMLOutputVersion: "1.05",
+		ScanInfo: nmapScanInfo{
+			Type:        scanType,
+			Protocol:    results.Protocol,
+			NumServices: results.EndPort - results.StartPort + 1,
+			Services:    fmt.Sprintf("%d-%d", results.StartPort, results.EndPort),
+		},
+		Hosts: make([]nmapHost, 0, len(hosts)),
+	}
+
+	// the reply of the hosts without open ports
+	closedReason := "reset"
+	if results.Protocol == "udp" {
+		closedReason = "port-unreach"
+	}
+
+	for i, h := range hosts {
+		addrType := "ipv4"
+		if h.IP.To4() == nil {
+			addrType = "ipv6"
+		}
+
+		host := nmapHost{
+			StartTime: started.Unix(),
+			EndTime:   h.Seen.Unix(),
+			Status:    nmapStatus{State: "up", Reason: closedReason},
+			Addresses: []nmapAddress{{Addr: h.IP.String(), AddrType: addrType}},
+			Ports:     make([]nmapPort, 0, len(ports[i])),
+		}
+
+		if endpoint := mod.hostFor(h.IP); endpoint != nil {
+			if endpoint.HwAddress != "" {
+				host.Addresses = append(host.Addresses, nmapAddress{
+					Addr:     strings.ToUpper(endpoint.HwAddress),
+					AddrType: "mac",
+					Vendor:   endpoint.Vendor,
+				})
+			}
+			if endpoint.Hostname != "" {
+				host.Hostnames = append(host.Hostnames, nmapHostname{Name: endpoint.Hostname, Type: "PTR"})
+			}
+			if guess := endpoint.OS(); guess.OS != "" {
+				host.OS = &nmapOS{Matches: []nmapOSMatch{{Name: guess.OS, Accuracy: guess.Confidence}}}
+			}
+		}
+
+		for _, p := range ports[i] {
+			port := nmapPort{
+				Protocol: p.Proto,
+				PortID:   p.Port,
+				State:    nmapState{State: p.State, Reason: nmapReason(p)},
+				Service:  nmapServiceOf(p),
+			}
+			if p.Banner != "" {
+				port.Scripts = append(port.Scripts, nmapScript{ID: "banner", Output: p.Banner})
+			}
+			host.Ports = append(host.Ports, port)
+
+			if p.State == portOpen {
+				host.Status.Reason = nmapReason(p)
+			}
+		}
+
+		run.Hosts = append(run.Hosts, host)
+	}
+
+	elapsed := finished.Sub(started).Seconds()
+	addresses := "addresses"
+	if results.Targets == 1 {
+		addresses = "address"
+	}
+	run.RunStats = nmapRunStats{
+		Finished: nmapFinished{
+			Time:    finished.Unix(),
+			TimeStr: finished.Format(time.ANSIC),
+			Elapsed: fmt.Sprintf("%.2f", elapsed),
+			Summary: fmt.Sprintf("bettercap done at %s; %d IP %s (%d host%s up) scanned in %.2f seconds",
+				finished.Format(time.ANSIC), results.Targets, addresses,
+				len(hosts), plural(uint64(len(hosts))), elapsed),
+			Exit: "success",
+		},
+		Hosts: nmapHostStats{
+			Up:    len(hosts),
+			Down:  results.Targets - len(hosts),
+			Total: results.Targets,
+		},
+	}
+
+	raw, err := xml.MarshalIndent(run, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	buf := bytes.Buffer{}
+	buf.WriteString(xml.Header)
+	buf.WriteString("<!DOCTYPE nmaprun>\n")
+	buf.Write(raw)
+	buf.WriteString("\n")
+	return buf.Bytes(), nil
+}
+
+type jsonScanResult struct {
+	Time     time.Time `json:"time"`
+	Address  string    `json:"address"`
+	MAC      string    `json:"mac,omitempty"`
+	Vendor   string    `json:"vendor,omitempty"`
+	Hostname string    `json:"hostname,omitempty"`
+	OS       string    `json:"os,omitempty"`
+	*OpenPort
+}
+
+// toJSONLines renders the results as one JSON object for each port.
+func (mod *SynScanner) toJSONLines(results *scanResults) ([]byte, error) {
+	hosts, ports := results.Hosts()
+	buf := bytes.Buffer{}
+	enc := json.NewEncoder(&buf)
+
+	for i, h := range hosts {
+		result := jsonScanResult{
+			Time:    h.Seen,
+			Address: h.IP.String(),
+		}
+		if endpoint := mod.hostFor(h.IP); endpoint != nil {
+			result.MAC = endpoint.HwAddress
+			result.Vendor = endpoint.Vendor
+			result.Hostname = endpoint.Hostname
+			result.OS = endpoint.OS().OS
+		}
+
+		for _, p := range ports[i] {
+			result.OpenPort = p
+			if err := enc.Encode(result); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return buf.Bytes(), nil
+}
+
+func (mod *SynScanner) export(fileName string) (err error) {
+	if fileName, err = fs.Expand(strings.TrimSpace(fileName)); err != nil {
+		return err
+	}
+
+	mod.resultsLock.Lock()
+	results := mod.results
+	mod.resultsLock.Unlock()
+	if results == nil {
+		return fmt.Errorf("no scan results to export")
+	}
+
+	var data []byte
+	switch strings.ToLower(filepath.Ext(fileName)) {
+	case ".json", ".jsonl":
+		data, err = mod.toJSONLines(results)
+	default:
+		data, err = mod.toNmapXML(results)
+	}
+
+	if err != nil {
+		return err
+	} else if err = ioutil.WriteFile(fileName, data, 0644); err != nil {
+		return err
+	}
+
+	hosts, _ := results.Hosts()
+	mod.Info("exported the scan results of %d host%s to %s", len(hosts), plural(uint64(len(hosts))), fileName)
+	return nil
+}
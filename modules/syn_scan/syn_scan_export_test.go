@@ -0,0 +1,148 @@
+package syn_scan
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"net"
+	"testing"
+
+	"github.com/bettercap/bettercap/network"
+	"github.com/bettercap/bettercap/session"
+)
+
+func buildExportScanner() (*SynScanner, *scanResults) {
+	iface := network.NewEndpointNoResolve(network.IpVersions{IPv4: "10.0.0.100"}, "aa:bb:cc:dd:ee:ff", "eth0", 24)
+	gateway := network.NewEndpointNoResolve(network.IpVersions{IPv4: "10.0.0.1"}, "00:11:22:33:44:55", "", 24)
+	gateway.Vendor = "Example Corp"
+
+	mod := &SynScanner{
+		SessionModule: session.NewSessionModule("syn.scan", &session.Session{
+			Interface: iface,
+			Gateway:   gateway,
+			Lan:       network.NewLAN(iface, gateway, nil, nil, nil),
+		}),
+	}
+
+	results := newScanResults("tcp", 20, 30, 2)
+	ssh := &OpenPort{Proto: "tcp", Port: 22, State: portOpen, Service: "ssh"}
+	results.OnPort(net.ParseIP("10.0.0.1"), ssh)
+	results.OnService(ssh, "SSH-2.0-OpenSSH_8.4", &network.ServiceInfo{Service: "ssh", Product: "OpenSSH", Version: "8.4"})
+	// not in the LAN
+	results.OnPort(net.ParseIP("10.0.0.2"), &OpenPort{Proto: "tcp", Port: 25, State: portOpen, Service: "smtp"})
+	results.OnDone()
+
+	return mod, results
+}
+
+func TestExportNmapXML(t *testing.T) {
+	mod, results := buildExportScanner()
+
+	data, err := mod.toNmapXML(results)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	run := nmapRun{}
+	if err = xml.Unmarshal(data, &run); err != nil {
+		t.Fatal(err)
+	} else if len(run.Hosts) != 2 {
+		t.Fatalf("expected 2 hosts, got %d", len(run.Hosts))
+	} else if run.RunStats.Hosts.Up != 2 || run.RunStats.Hosts.Total != 2 {
+		t.Fatalf("unexpected host stats %+v", run.RunStats.Hosts)
+	}
+
+	gw := run.Hosts[0]
+	exp := []nmapAddress{
+		{Addr: "10.0.0.1", AddrType: "ipv4"},
+		{Addr: "00:11:22:33:44:55", AddrType: "mac", Vendor: "Example Corp"},
+	}
+	if len(gw.Addresses) != len(exp) {
+		t.Fatalf("expected %v, got %v", exp, gw.Addresses)
+	}
+	for i := range exp {
+		if gw.Addresses[i] != exp[i] {
+			t.Fatalf("expected %v, got %v", exp[i], gw.Addresses[i])
+		}
+	}
+
+	if gw.Status.State != "up" || gw.Status.Reason != "syn-ack" {
+		t.Fatalf("unexpected status %+v", gw.Status)
+	} else if len(gw.Ports) != 1 {
+		t.Fatalf("expected 1 port, got %d", len(gw.Ports))
+	}
+
+	port := gw.Ports[0]
+	if port.Protocol != "tcp" || port.PortID != 22 || port.State.State != "open" || port.State.Reason != "syn-ack" {
+		t.Fatalf("unexpected port %+v", port)
+	} else if port.Service == nil || port.Service.Name != "ssh" || port.Service.Product != "OpenSSH" ||
+		port.Service.Version != "8.4" || port.Service.Method != "probed" {
+		t.Fatalf("unexpected service %+v", port.Service)
+	} else if len(port.Scripts) != 1 || port.Scripts[0].ID != "banner" || port.Scripts[0].Output != "SSH-2.0-OpenSSH_8.4" {
+		t.Fatalf("unexpected scripts %+v", port.Scripts)
+	}
+
+	other := run.Hosts[1]
+	if len(other.Addresses) != 1 || other.Addresses[0].Addr != "10.0.0.2" {
+		t.Fatalf("unexpected addresses %v", other.Addresses)
+	} else if len(other.Ports) != 1 || other.Ports[0].PortID != 25 || other.Ports[0].Service.Method != "table" {
+		t.Fatalf("unexpected ports %+v", other.Ports)
+	}
+}
+
+func TestExportJSONLines(t *testing.T) {
+	mod, results := buildExportScanner()
+
+	data, err := mod.toJSONLines(results)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := bytes.Split(bytes.TrimSpace(data), []byte("\n"))
+	if len(lines) != 2 {
+		t.Fatalf("expected one record per host, got %d", len(lines))
+	}
+
+	records := make([]map[string]interface{}, len(lines))
+	for i, line := range lines {
+		if err = json.Unmarshal(line, &records[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	gw := records[0]
+	if gw["address"] != "10.0.0.1" || gw["mac"] != "00:11:22:33:44:55" || gw["vendor"] != "Example Corp" {
+		t.Fatalf("unexpected host %v", gw)
+	} else if gw["port"] != float64(22) || gw["state"] != "open" || gw["service"] != "ssh" || gw["banner"] != "SSH-2.0-OpenSSH_8.4" {
+		t.Fatalf("unexpected port %v", gw)
+	}
+
+	other := records[1]
+	if other["address"] != "10.0.0.2" || other["port"] != float64(25) {
+		t.Fatalf("unexpected record %v", other)
+	} else if _, found := other["mac"]; found {
+		t.Fatalf("unexpected mac in %v", other)
+	}
+}
+
+// the services of a stopped scan can still be being probed while exporting
+func TestExportWhileProbing(t *testing.T) {
+	mod, results := buildExportScanner()
+	smtp := &OpenPort{Proto: "tcp", Port: 25, State: portOpen}
+	results.OnPort(net.ParseIP("10.0.0.2"), smtp)
+
+	done := make(chan bool)
+	go func() {
+		for i := 0; i < 100; i++ {
+			results.OnService(smtp, "220 mail.example.com ESMTP", &network.ServiceInfo{Service: "smtp", Product: "Postfix"})
+		}
+		done <- true
+	}()
+
+	for i := 0; i < 100; i++ {
+		if _, err := mod.toJSONLines(results); err != nil {
+			t.Fatal(err)
+		}
+	}
+	<-done
+}
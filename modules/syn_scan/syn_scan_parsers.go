@@ -6,26 +6,89 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/bettercap/bettercap/network"
+
 	"github.com/evilsocket/islazy/str"
 	"github.com/malfunkt/iprange"
 )
 
-func (mod *SynScanner) parseTargets(arg string) error {
-	if strings.Contains(arg, ":") {
-		// parse as IPv6 address
-		if ip := net.ParseIP(arg); ip == nil {
-			return fmt.Errorf("error while parsing IPv6 '%s'", arg)
-		} else {
-			mod.addresses = []net.IP{ip}
+// the largest IPv6 network that can be scanned as a whole
+const maxIPv6ScanBits = 16
+
+func parseIPv6Network(arg string) ([]net.IP, error) {
+	_, ipnet, err := net.ParseCIDR(arg)
+	if err != nil {
+		return nil, fmt.Errorf("error while parsing IPv6 network '%s': %s", arg, err)
+	}
+
+	ones, bits := ipnet.Mask.Size()
+	if bits-ones > maxIPv6ScanBits {
+		return nil, fmt.Errorf("IPv6 network '%s' is too large, the prefix must be at least /%d", arg, bits-maxIPv6ScanBits)
+	}
+
+	addresses := make([]net.IP, 0, 1<<uint(bits-ones))
+	for ip := ipnet.IP.To16(); ipnet.Contains(ip); {
+		addresses = append(addresses, ip)
+
+		next := make(net.IP, len(ip))
+		copy(next, ip)
+		for i := len(next) - 1; i >= 0; i-- {
+			if next[i]++; next[i] != 0 {
+				break
+			}
 		}
-	} else {
-		if list, err := iprange.Parse(arg); err != nil {
-			return fmt.Errorf("error while parsing IP range '%s': %s", arg, err)
+		ip = next
+	}
+
+	return addresses, nil
+}
+
+// the IPv6 addresses of the endpoints found by net.recon
+func (mod *SynScanner) ipv6Neighbors() []net.IP {
+	addresses := make([]net.IP, 0)
+	if gw := mod.Session.Gateway; gw != nil && gw.IPv6 != nil {
+		addresses = append(addresses, gw.IPv6)
+	}
+	mod.Session.Lan.EachHost(func(mac string, e *network.Endpoint) {
+		if e.IPv6 != nil {
+			addresses = append(addresses, e.IPv6)
+		}
+	})
+	return addresses
+}
+
+func (mod *SynScanner) parseTargets(arg string) error {
+	mod.addresses = make([]net.IP, 0)
+
+	for _, target := range str.Comma(arg) {
+		if target == "ipv6" {
+			mod.addresses = append(mod.addresses, mod.ipv6Neighbors()...)
+		} else if strings.Contains(target, ":") && strings.Contains(target, "/") {
+			if list, err := parseIPv6Network(target); err != nil {
+				return err
+			} else {
+				mod.addresses = append(mod.addresses, list...)
+			}
+		} else if strings.Contains(target, ":") {
+			// parse as IPv6 address
+			if ip := net.ParseIP(target); ip == nil {
+				return fmt.Errorf("error while parsing IPv6 '%s'", target)
+			} else {
+				mod.addresses = append(mod.addresses, ip)
+			}
 		} else {
-			mod.addresses = list.Expand()
+			if list, err := iprange.Parse(target); err != nil {
+				return fmt.Errorf("error while parsing IP range '%s': %s", target, err)
+			} else {
+				mod.addresses = append(mod.addresses, list.Expand()...)
+			}
 		}
 	}
 
+	if len(mod.addresses) == 0 {
+		return fmt.Errorf("no addresses to scan in '%s'", arg)
+	}
+
 	return nil
 }
 
@@ -1,9 +1,11 @@
 package syn_scan
 
 import (
+	"net"
 	"sync/atomic"
 
 	"github.com/bettercap/bettercap/network"
+	"github.com/bettercap/bettercap/packets"
 
 	"github.com/gopacket/gopacket"
 	"github.com/gopacket/gopacket/layers"
@@ -11,18 +13,160 @@ import (
 	"github.com/evilsocket/islazy/async"
 )
 
+// a port replying to the probe, see packets.UDPPort* for the other states
+// of the UDP ones
+const portOpen = "open"
+
 type OpenPort struct {
 	Proto   string `json:"proto"`
 	Banner  string `json:"banner"`
 	Service string `json:"service"`
+	State   string `json:"state"`
+	Product string `json:"product"`
+	Version string `json:"version"`
+	Info    string `json:"info"`
+	CPE     string `json:"cpe"`
 	Port    int    `json:"port"`
 }
 
+// Description returns the service with its product, version and extra
+// information, if known.
+func (p *OpenPort) Description() string {
+	return network.ServiceInfo{
+		Service: p.Service,
+		Product: p.Product,
+		Version: p.Version,
+		Info:    p.Info,
+	}.String()
+}
+
+func (mod *SynScanner) findMAC(address net.IP) (net.HardwareAddr, error) {
+	if address.To4() == nil {
+		// IPv6 neighbors are not in the ARP table
+		if e := mod.Session.Lan.GetByIp(address.String()); e != nil && e.HW != nil {
+			return e.HW, nil
+		}
+	}
+	return mod.Session.FindMAC(address, true)
+}
+
+func (mod *SynScanner) hostFor(ip net.IP) *network.Endpoint {
+	if ip.Equal(mod.Session.Interface.IP) || ip.Equal(mod.Session.Interface.IPv6) {
+		return mod.Session.Interface
+	} else if ip.Equal(mod.Session.Gateway.IP) || ip.Equal(mod.Session.Gateway.IPv6) {
+		return mod.Session.Gateway
+	}
+	return mod.Session.Lan.GetByIp(ip.String())
+}
+
+func (mod *SynScanner) onOpenPort(ip net.IP, proto string, port int, state string) {
+	// retransmitted probes and SYN/ACKs can report the same port twice
+	if state == portOpen && !mod.state.AddOpen(ip.String(), port) {
+		return
+	}
+	mod.reportPort(ip, proto, port, state)
+}
+
+func (mod *SynScanner) reportPort(ip net.IP, proto string, port int, state string) {
+	if state == portOpen {
+		atomic.AddUint64(&mod.stats.openPorts, 1)
+	}
+
+	openPort := &OpenPort{
+		Proto:   proto,
+		Port:    port,
+		State:   state,
+		Service: network.GetServiceByPort(port, proto),
+	}
+
+	from := ip.String()
+	host := mod.hostFor(ip)
+	if host != nil {
+		// the same port number can be open for both protocols
+		key := "ports"
+		if proto == "udp" {
+			key = "udp-ports"
+		}
+		ports := host.Meta.GetOr(key, map[int]*OpenPort{}).(map[int]*OpenPort)
+		if _, found := ports[port]; !found {
+			ports[port] = openPort
+		}
+		host.Meta.Set(key, ports)
+	}
+
+	mod.results.OnPort(ip, openPort)
+	mod.bannerQueue.Add(async.Job(grabberJob{from, host, openPort, mod.results}))
+
+	NewSynScanEvent(from, host, openPort).Push()
+}
+
+func (mod *SynScanner) onUDPReply(reply *packets.UDPScanReply) {
+	addr := reply.Address.String()
+
+	mod.udpLock.Lock()
+	ports, found := mod.udpReplies[addr]
+	if !found {
+		ports = make(map[int]string)
+		mod.udpReplies[addr] = ports
+	}
+	prev := ports[reply.Port]
+	if prev != packets.UDPPortOpen {
+		ports[reply.Port] = reply.State
+	}
+	mod.udpLock.Unlock()
+
+	mod.Debug("%s udp port %d is %s", addr, reply.Port, reply.State)
+
+	if reply.State == packets.UDPPortOpen && prev != packets.UDPPortOpen {
+		mod.onOpenPort(reply.Address, "udp", reply.Port, packets.UDPPortOpen)
+	}
+}
+
+// onUDPScanDone reports the ports of the well known services that did not
+// reply, as open|filtered, for the hosts that do send ICMP errors for the
+// closed ones. A host that never answered is most likely behind a firewall.
+func (mod *SynScanner) onUDPScanDone() {
+	mod.udpLock.Lock()
+	defer mod.udpLock.Unlock()
+
+	for _, address := range mod.addresses {
+		ports, found := mod.udpReplies[address.String()]
+		if !found {
+			continue
+		}
+
+		answers := false
+		for _, state := range ports {
+			if state == packets.UDPPortClosed {
+				answers = true
+				break
+			}
+		}
+		if !answers {
+			continue
+		}
+
+		for port := range packets.UDPScanPayloads {
+			if _, replied := ports[port]; !replied && port >= mod.startPort && port <= mod.endPort {
+				mod.onOpenPort(address, "udp", port, packets.UDPPortOpenFiltered)
+			}
+		}
+	}
+}
+
 func (mod *SynScanner) onPacket(pkt gopacket.Packet) {
 	if pkt == nil || pkt.Data() == nil {
 		return
 	}
 
+	if mod.protocol == "udp" {
+		if reply := packets.ParseUDPScanReply(pkt, synSourcePort); reply != nil {
+			mod.onReply(reply.Address, reply.Port)
+			mod.onUDPReply(reply)
+		}
+		return
+	}
+
 	var eth layers.Ethernet
 	var ip4 layers.IPv4
 	var ip6 layers.IPv6
@@ -53,52 +197,20 @@ func (mod *SynScanner) onPacket(pkt gopacket.Packet) {
 		isIPv6 = true
 	}
 
-	if tcp.DstPort == synSourcePort && tcp.SYN && tcp.ACK {
-		atomic.AddUint64(&mod.stats.openPorts, 1)
-
-		port := int(tcp.SrcPort)
-
-		openPort := &OpenPort{
-			Proto:   "tcp",
-			Port:    port,
-			Service: network.GetServiceByPort(port, "tcp"),
-		}
-
-		var host *network.Endpoint
-
-		from := ""
-
-		if isIPv6 {
-			from = ip6.SrcIP.String()
-			if ip6.SrcIP.Equal(mod.Session.Interface.IPv6) {
-				host = mod.Session.Interface
-			} else if ip6.SrcIP.Equal(mod.Session.Gateway.IPv6) {
-				host = mod.Session.Gateway
-			} else {
-				host = mod.Session.Lan.GetByIp(from)
-			}
-		} else {
-			from = ip4.SrcIP.String()
-
-			if ip4.SrcIP.Equal(mod.Session.Interface.IP) {
-				host = mod.Session.Interface
-			} else if ip4.SrcIP.Equal(mod.Session.Gateway.IP) {
-				host = mod.Session.Gateway
-			} else {
-				host = mod.Session.Lan.GetByIp(from)
-			}
-		}
-
-		if host != nil {
-			ports := host.Meta.GetOr("ports", map[int]*OpenPort{}).(map[int]*OpenPort)
-			if _, found := ports[port]; !found {
-				ports[port] = openPort
-			}
-			host.Meta.Set("ports", ports)
-		}
+	if tcp.DstPort != synSourcePort {
+		return
+	}
 
-		mod.bannerQueue.Add(async.Job(grabberJob{from, openPort}))
+	from := ip4.SrcIP
+	if isIPv6 {
+		from = ip6.SrcIP
+	}
 
-		NewSynScanEvent(from, host, port).Push()
+	if tcp.SYN && tcp.ACK {
+		mod.onReply(from, int(tcp.SrcPort))
+		mod.onOpenPort(from, "tcp", int(tcp.SrcPort), portOpen)
+	} else if tcp.RST {
+		// closed ports answer too, which is enough to measure the RTT
+		mod.onReply(from, int(tcp.SrcPort))
 	}
 }
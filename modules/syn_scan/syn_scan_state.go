@@ -0,0 +1,188 @@
+package syn_scan
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/evilsocket/islazy/fs"
+)
+
+type scanCheckpoint struct {
+	Protocol  string   `json:"protocol"`
+	StartPort int      `json:"start_port"`
+	EndPort   int      `json:"end_port"`
+	Addresses []string `json:"addresses"`
+	// addresses whose ports have all been probed and retried, the others
+	// are scanned again from the first port when resuming
+	Done    map[string]bool  `json:"done"`
+	Open    map[string][]int `json:"open"`
+	Updated time.Time        `json:"updated"`
+}
+
+// scanState tracks the progress of a scan and checkpoints it to disk so
+// that it can be resumed if interrupted.
+type scanState struct {
+	sync.Mutex
+	fileName string
+	dirty    bool
+	cp       scanCheckpoint
+}
+
+func newScanState(fileName string, protocol string, addresses []net.IP, startPort, endPort int) (*scanState, error) {
+	fileName, err := fs.Expand(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &scanState{
+		fileName: fileName,
+		dirty:    true,
+		cp: scanCheckpoint{
+			Protocol:  protocol,
+			StartPort: startPort,
+			EndPort:   endPort,
+			Addresses: make([]string, len(addresses)),
+			Done:      make(map[string]bool),
+			Open:      make(map[string][]int),
+		},
+	}
+	for i, address := range addresses {
+		s.cp.Addresses[i] = address.String()
+	}
+
+	return s, nil
+}
+
+func loadScanState(fileName string) (*scanState, error) {
+	fileName, err := fs.Expand(fileName)
+	if err != nil {
+		return nil, err
+	} else if fileName == "" || !fs.Exists(fileName) {
+		return nil, fmt.Errorf("no interrupted scan to resume")
+	}
+
+	s := &scanState{fileName: fileName}
+	if raw, err := ioutil.ReadFile(fileName); err != nil {
+		return nil, err
+	} else if err = json.Unmarshal(raw, &s.cp); err != nil {
+		return nil, fmt.Errorf("error parsing %s: %v", fileName, err)
+	}
+
+	if s.cp.Done == nil {
+		s.cp.Done = make(map[string]bool)
+	}
+	if s.cp.Open == nil {
+		s.cp.Open = make(map[string][]int)
+	}
+
+	return s, nil
+}
+
+// Addresses returns the addresses of the scan which have not been completely
+// scanned yet.
+func (s *scanState) Addresses() []net.IP {
+	s.Lock()
+	defer s.Unlock()
+
+	addresses := make([]net.IP, 0)
+	for _, address := range s.cp.Addresses {
+		if !s.cp.Done[address] {
+			if ip := net.ParseIP(address); ip != nil {
+				addresses = append(addresses, ip)
+			}
+		}
+	}
+	return addresses
+}
+
+// Probed returns the number of ports probed on the completed addresses.
+func (s *scanState) Probed() uint64 {
+	s.Lock()
+	defer s.Unlock()
+
+	return uint64(len(s.cp.Done)) * uint64(s.cp.EndPort-s.cp.StartPort+1)
+}
+
+// SetDone marks the address as completely scanned.
+func (s *scanState) SetDone(address string) {
+	s.Lock()
+	defer s.Unlock()
+
+	s.cp.Done[address] = true
+	s.dirty = true
+}
+
+// AddOpen records the open port and returns false if it was already known.
+func (s *scanState) AddOpen(address string, port int) bool {
+	s.Lock()
+	defer s.Unlock()
+
+	for _, p := range s.cp.Open[address] {
+		if p == port {
+			return false
+		}
+	}
+	s.cp.Open[address] = append(s.cp.Open[address], port)
+	sort.Ints(s.cp.Open[address])
+	s.dirty = true
+	return true
+}
+
+// Open returns the open ports found so far for each address.
+func (s *scanState) Open() map[string][]int {
+	s.Lock()
+	defer s.Unlock()
+
+	open := make(map[string][]int)
+	for address, ports := range s.cp.Open {
+		open[address] = append([]int{}, ports...)
+	}
+	return open
+}
+
+// Save writes the checkpoint if anything changed since the last one.
+func (s *scanState) Save() error {
+	s.Lock()
+	defer s.Unlock()
+
+	if s.fileName == "" || !s.dirty {
+		return nil
+	}
+
+	s.cp.Updated = time.Now()
+	raw, err := json.MarshalIndent(s.cp, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// write and rename so that an interruption can't corrupt it
+	tmpName := s.fileName + ".tmp"
+	if err = ioutil.WriteFile(tmpName, raw, 0644); err != nil {
+		return err
+	} else if err = os.Rename(tmpName, s.fileName); err != nil {
+		return err
+	}
+
+	s.dirty = false
+	return nil
+}
+
+// Remove deletes the checkpoint of a completed scan.
+func (s *scanState) Remove() error {
+	s.Lock()
+	defer s.Unlock()
+
+	fileName := s.fileName
+	// nothing else to save once the scan is completed
+	s.fileName = ""
+	if fileName != "" && fs.Exists(fileName) {
+		return os.Remove(fileName)
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package syn_scan
+
+import (
+	"net"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestScanStateResume(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "scan.json")
+	addresses := []net.IP{net.ParseIP("10.0.0.1"), net.ParseIP("10.0.0.2"), net.ParseIP("fe80::1")}
+
+	s, err := newScanState(fileName, "tcp", addresses, 20, 29)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s.SetDone("10.0.0.1")
+	if !s.AddOpen("10.0.0.1", 22) || !s.AddOpen("10.0.0.2", 25) || !s.AddOpen("10.0.0.2", 21) {
+		t.Fatal("expected the ports to be added")
+	} else if s.AddOpen("10.0.0.1", 22) {
+		t.Fatal("expected the port to be already known")
+	} else if err = s.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the scan is interrupted while 10.0.0.2 is being scanned
+	loaded, err := loadScanState(fileName)
+	if err != nil {
+		t.Fatal(err)
+	} else if loaded.cp.Protocol != "tcp" || loaded.cp.StartPort != 20 || loaded.cp.EndPort != 29 {
+		t.Fatalf("unexpected checkpoint %+v", loaded.cp)
+	}
+
+	exp := []net.IP{net.ParseIP("10.0.0.2"), net.ParseIP("fe80::1")}
+	if got := loaded.Addresses(); !reflect.DeepEqual(got, exp) {
+		t.Fatalf("expected %v to be resumed, got %v", exp, got)
+	} else if got := loaded.Probed(); got != 10 {
+		t.Fatalf("expected 10 probed ports, got %d", got)
+	}
+
+	open := map[string][]int{"10.0.0.1": {22}, "10.0.0.2": {21, 25}}
+	if got := loaded.Open(); !reflect.DeepEqual(got, open) {
+		t.Fatalf("expected %v, got %v", open, got)
+	}
+
+	loaded.SetDone("10.0.0.2")
+	loaded.SetDone("fe80::1")
+	if got := loaded.Addresses(); len(got) != 0 {
+		t.Fatalf("expected nothing to resume, got %v", got)
+	} else if got := loaded.Probed(); got != 30 {
+		t.Fatalf("expected 30 probed ports, got %d", got)
+	}
+
+	if err = loaded.Remove(); err != nil {
+		t.Fatal(err)
+	} else if _, err = loadScanState(fileName); err == nil {
+		t.Fatal("expected no scan to resume")
+	} else if err = loaded.Save(); err != nil {
+		t.Fatal(err)
+	} else if _, err = loadScanState(fileName); err == nil {
+		t.Fatal("expected a completed scan not to be saved again")
+	}
+}
@@ -0,0 +1,132 @@
+package syn_scan
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	// the lowest rate the limiter will slow down to after losses
+	minScanRate = 10.0
+	// the rate is halved at most once per window
+	scanDropWindow = time.Duration(1) * time.Second
+
+	initialScanTimeout = time.Duration(1) * time.Second
+	minScanTimeout     = time.Duration(100) * time.Millisecond
+	maxScanTimeout     = time.Duration(5) * time.Second
+)
+
+// rateLimiter spaces the probes to send at most rate packets per second,
+// the rate is halved when probes get lost and slowly grows back up to the
+// configured maximum as replies come in.
+type rateLimiter struct {
+	sync.Mutex
+	max      float64
+	rate     float64
+	next     time.Time
+	lastDrop time.Time
+}
+
+// newRateLimiter returns a limiter for up to max packets per second, 0
+// means unlimited.
+func newRateLimiter(max int) *rateLimiter {
+	return &rateLimiter{
+		max:  float64(max),
+		rate: float64(max),
+	}
+}
+
+// Wait blocks until the next probe can be sent.
+func (r *rateLimiter) Wait() {
+	if r.max <= 0 {
+		return
+	}
+
+	r.Lock()
+	now := time.Now()
+	if r.next.Before(now) {
+		r.next = now
+	}
+	wait := r.next.Sub(now)
+	r.next = r.next.Add(time.Duration(float64(time.Second) / r.rate))
+	r.Unlock()
+
+	if wait > 0 {
+		time.Sleep(wait)
+	}
+}
+
+// OnDrop slows down after a probe or its reply got lost.
+func (r *rateLimiter) OnDrop() {
+	r.Lock()
+	defer r.Unlock()
+
+	if r.max > 0 && time.Since(r.lastDrop) >= scanDropWindow {
+		r.rate = math.Max(r.rate/2, math.Min(minScanRate, r.max))
+		r.lastDrop = time.Now()
+	}
+}
+
+// OnReply speeds up again after a probe got a reply on the first try.
+func (r *rateLimiter) OnReply() {
+	r.Lock()
+	defer r.Unlock()
+
+	if r.max > 0 {
+		r.rate = math.Min(r.rate+1, r.max)
+	}
+}
+
+func (r *rateLimiter) Rate() float64 {
+	r.Lock()
+	defer r.Unlock()
+	return r.rate
+}
+
+// rttEstimator computes how long to wait for replies from the observed
+// round trip times, as TCP does for its retransmission timeout (RFC 6298).
+type rttEstimator struct {
+	sync.Mutex
+	srtt    time.Duration
+	rttvar  time.Duration
+	samples int
+}
+
+// Sample adds the round trip time of a probe answered on the first try,
+// retransmitted ones are ambiguous and must not be sampled.
+func (e *rttEstimator) Sample(rtt time.Duration) {
+	e.Lock()
+	defer e.Unlock()
+
+	if e.samples == 0 {
+		e.srtt = rtt
+		e.rttvar = rtt / 2
+	} else {
+		delta := e.srtt - rtt
+		if delta < 0 {
+			delta = -delta
+		}
+		e.rttvar = (3*e.rttvar + delta) / 4
+		e.srtt = (7*e.srtt + rtt) / 8
+	}
+	e.samples++
+}
+
+// Timeout returns how long to wait for the replies to the last probes.
+func (e *rttEstimator) Timeout() time.Duration {
+	e.Lock()
+	defer e.Unlock()
+
+	if e.samples == 0 {
+		return initialScanTimeout
+	}
+
+	timeout := e.srtt + 4*e.rttvar
+	if timeout < minScanTimeout {
+		return minScanTimeout
+	} else if timeout > maxScanTimeout {
+		return maxScanTimeout
+	}
+	return timeout
+}
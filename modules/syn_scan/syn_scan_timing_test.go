@@ -0,0 +1,103 @@
+package syn_scan
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRateLimiterUnlimited(t *testing.T) {
+	r := newRateLimiter(0)
+	started := time.Now()
+	for i := 0; i < 1000; i++ {
+		r.Wait()
+	}
+	r.OnDrop()
+	if elapsed := time.Since(started); elapsed > 100*time.Millisecond {
+		t.Fatalf("unlimited limiter waited %s", elapsed)
+	} else if r.Rate() != 0 {
+		t.Fatalf("unexpected rate %f", r.Rate())
+	}
+}
+
+func TestRateLimiterWait(t *testing.T) {
+	r := newRateLimiter(100)
+	started := time.Now()
+	// the first probe is sent right away, the other ten 10ms apart
+	for i := 0; i < 11; i++ {
+		r.Wait()
+	}
+	if elapsed := time.Since(started); elapsed < 90*time.Millisecond {
+		t.Fatalf("expected 11 probes to take at least 100ms, took %s", elapsed)
+	}
+}
+
+func TestRateLimiterAdapt(t *testing.T) {
+	r := newRateLimiter(100)
+
+	r.OnDrop()
+	if r.Rate() != 50 {
+		t.Fatalf("expected the rate to be halved, got %f", r.Rate())
+	}
+	// once per window
+	r.OnDrop()
+	if r.Rate() != 50 {
+		t.Fatalf("expected the rate to be halved once, got %f", r.Rate())
+	}
+
+	r.OnReply()
+	if r.Rate() != 51 {
+		t.Fatalf("expected the rate to grow after a reply, got %f", r.Rate())
+	}
+	for i := 0; i < 100; i++ {
+		r.OnReply()
+	}
+	if r.Rate() != 100 {
+		t.Fatalf("expected the rate to be capped to the maximum, got %f", r.Rate())
+	}
+
+	for i := 0; i < 10; i++ {
+		r.lastDrop = time.Time{}
+		r.OnDrop()
+	}
+	if r.Rate() != minScanRate {
+		t.Fatalf("expected the rate to be floored to %f, got %f", minScanRate, r.Rate())
+	}
+
+	// the floor can't exceed the maximum
+	r = newRateLimiter(4)
+	r.OnDrop()
+	if r.Rate() != 4 {
+		t.Fatalf("unexpected rate %f", r.Rate())
+	}
+}
+
+func TestRTTEstimator(t *testing.T) {
+	e := &rttEstimator{}
+	if got := e.Timeout(); got != initialScanTimeout {
+		t.Fatalf("expected %s without samples, got %s", initialScanTimeout, got)
+	}
+
+	// srtt = 100ms, rttvar = 50ms
+	e.Sample(100 * time.Millisecond)
+	if got := e.Timeout(); got != 300*time.Millisecond {
+		t.Fatalf("expected 300ms, got %s", got)
+	}
+
+	// rttvar = (3*50 + 100) / 4, srtt = (7*100 + 200) / 8
+	e.Sample(200 * time.Millisecond)
+	if got := e.Timeout(); got != 362500*time.Microsecond {
+		t.Fatalf("expected 362.5ms, got %s", got)
+	}
+
+	e = &rttEstimator{}
+	e.Sample(time.Millisecond)
+	if got := e.Timeout(); got != minScanTimeout {
+		t.Fatalf("expected %s, got %s", minScanTimeout, got)
+	}
+
+	e = &rttEstimator{}
+	e.Sample(10 * time.Second)
+	if got := e.Timeout(); got != maxScanTimeout {
+		t.Fatalf("expected %s, got %s", maxScanTimeout, got)
+	}
+}
@@ -2,6 +2,7 @@ package wifi
 
 import (
 	"bytes"
+	"encoding/hex"
 	"fmt"
 	"net"
 	"regexp"
@@ -20,6 +21,7 @@ import (
 
 	"github.com/evilsocket/islazy/fs"
 	"github.com/evilsocket/islazy/ops"
+	"github.com/evilsocket/islazy/plugin"
 	"github.com/evilsocket/islazy/str"
 	"github.com/evilsocket/islazy/tui"
 )
@@ -66,6 +68,18 @@ type WiFiModule struct {
 	reads               *sync.WaitGroup
 	chanLock            *sync.Mutex
 	selector            *utils.ViewSelector
+	wps                 *wpsAttack
+	wpsLock             *sync.Mutex
+	wpsTimeout          time.Duration
+	wpsDelay            time.Duration
+	wpsLockDelay        time.Duration
+	wpsRetries          int
+	wpsStartFirst       uint
+	pmkid               *pmkidHarvest
+	pmkidLock           *sync.Mutex
+	script              *wifiScriptState
+	decrypt             *decryptState
+	decryptLock         *sync.Mutex
 }
 
 func NewWiFiModule(s *session.Session) *WiFiModule {
@@ -97,9 +111,16 @@ func NewWiFiModule(s *session.Session) *WiFiModule {
 		writes:          &sync.WaitGroup{},
 		reads:           &sync.WaitGroup{},
 		chanLock:        &sync.Mutex{},
+		wpsLock:         &sync.Mutex{},
+		pmkidLock:       &sync.Mutex{},
+		script:          &wifiScriptState{},
+		decryptLock:     &sync.Mutex{},
 	}
 
-	mod.InitState("channels")
+	// expose frame builders, injection and sniffing to session scripts
+	plugin.Defines["wifi"] = wifiScriptPackage{mod: mod}
+
+	mod.InitState("channels", "wps.pin", "pmkid.progress")
 
 	mod.AddParam(session.NewStringParameter("wifi.interface",
 		"",
@@ -360,7 +381,7 @@ func NewWiFiModule(s *session.Session) *WiFiModule {
 	mod.AddParam(session.NewStringParameter("wifi.handshakes.file",
 		"~/bettercap-wifi-handshakes.pcap",
 		"",
-		"File path of the pcap file to save handshakes to."))
+		"File path of the pcap file to save handshakes to, written as pcapng with a comment for each handshake frame if its extension is .pcapng."))
 
 	mod.AddParam(session.NewBoolParameter("wifi.handshakes.aggregate",
 		"true",
@@ -394,6 +415,145 @@ func NewWiFiModule(s *session.Session) *WiFiModule {
 			return mod.ShowWPS(args[0])
 		}))
 
+	wpsPixie := session.NewModuleHandler("wifi.wps.pixie BSSID", `wifi\.wps\.pixie ((?:[a-fA-F0-9:]{11,}))`,
+		"Run the WPS registration protocol against the given access point up to M3 and perform the Pixie Dust offline attack on the collected data, if the PIN is found the passphrase is recovered too.",
+		func(args []string) error {
+			bssid, err := net.ParseMAC(args[0])
+			if err != nil {
+				return err
+			}
+			return mod.startWPS(bssid, true)
+		})
+
+	wpsPixie.Complete("wifi.wps.pixie", s.WiFiCompleter)
+
+	mod.AddHandler(wpsPixie)
+
+	wpsBrute := session.NewModuleHandler("wifi.wps.brute BSSID", `wifi\.wps\.brute ((?:[a-fA-F0-9:]{11,}))`,
+		"Start an online WPS PIN brute force attack against the given access point, waiting when the AP locks WPS setup or rate limits the attempts.",
+		func(args []string) error {
+			bssid, err := net.ParseMAC(args[0])
+			if err != nil {
+				return err
+			}
+			return mod.startWPS(bssid, false)
+		})
+
+	wpsBrute.Complete("wifi.wps.brute", s.WiFiCompleter)
+
+	mod.AddHandler(wpsBrute)
+
+	mod.AddHandler(session.NewModuleHandler("wifi.wps.stop", "",
+		"Stop the running WPS attack.",
+		func(args []string) error {
+			return mod.stopWPS()
+		}))
+
+	mod.AddParam(session.NewIntParameter("wifi.wps.timeout",
+		"5",
+		"Seconds to wait for each WPS message from the access point before retrying."))
+
+	mod.AddParam(session.NewIntParameter("wifi.wps.delay",
+		"1000",
+		"Milliseconds to wait between two WPS PIN attempts."))
+
+	mod.AddParam(session.NewIntParameter("wifi.wps.lock.delay",
+		"60",
+		"Seconds to wait when the access point locks WPS setup or rate limits the attempts."))
+
+	mod.AddParam(session.NewIntParameter("wifi.wps.retries",
+		"5",
+		"How many times to retry a PIN when the access point doesn't reply or the exchange fails, before giving up."))
+
+	mod.AddParam(session.NewIntParameter("wifi.wps.brute.start",
+		"0",
+		"First half of the PIN (0-9999) to start the WPS brute force from, useful to resume an interrupted attack."))
+
+	mod.AddHandler(session.NewModuleHandler("wifi.pmkid.harvest", "",
+		"Iterate every WPA2-PSK access point, associate with a random station address and capture the PMKID from the first EAPOL frame, without waiting for clients.",
+		func(args []string) error {
+			return mod.startPMKIDHarvest()
+		}))
+
+	mod.AddHandler(session.NewModuleHandler("wifi.pmkid.stop", "",
+		"Stop the running PMKID harvesting.",
+		func(args []string) error {
+			return mod.stopPMKIDHarvest()
+		}))
+
+	mod.AddHandler(session.NewModuleHandler("wifi.pmkid.progress", `wifi\.pmkid\.progress`,
+		"Print progress of the current PMKID harvesting session.",
+		func(args []string) error {
+			return mod.showPMKIDProgress()
+		}))
+
+	mod.AddParam(session.NewIntParameter("wifi.pmkid.timeout",
+		"3",
+		"Seconds to wait for the first EAPOL frame after each association request."))
+
+	mod.AddParam(session.NewIntParameter("wifi.pmkid.retries",
+		"3",
+		"Number of additional association attempts for access points not answering with an EAPOL frame."))
+
+	mod.AddParam(session.NewIntParameter("wifi.pmkid.backoff",
+		"5",
+		"Seconds to wait before retrying an access point, doubled after every failed attempt."))
+
+	mod.AddParam(session.NewBoolParameter("wifi.pmkid.acquired",
+		"false",
+		"Harvest PMKIDs from AP's for which key material was already acquired."))
+
+	mod.AddParam(session.NewStringParameter("wifi.pmkid.skip",
+		"",
+		"",
+		"Comma separated list of BSSID to skip while harvesting PMKIDs."))
+
+	mod.AddHandler(session.NewModuleHandler("wifi.decrypt on", "",
+		"Start decrypting CCMP and TKIP data frames of the networks using wifi.decrypt.psk, the 4-way handshake of each client must be captured, decrypted frames are parsed by net.sniff.",
+		func(args []string) error {
+			return mod.startDecrypt()
+		}))
+
+	mod.AddHandler(session.NewModuleHandler("wifi.decrypt off", "",
+		"Stop decrypting data frames.",
+		func(args []string) error {
+			return mod.stopDecrypt()
+		}))
+
+	mod.AddParam(session.NewStringParameter("wifi.decrypt.essid",
+		"",
+		"",
+		"ESSID of the network to decrypt, if empty wifi.decrypt.psk will be used for every network."))
+
+	mod.AddParam(session.NewStringParameter("wifi.decrypt.psk",
+		"",
+		"",
+		"WPA passphrase used to decrypt data frames."))
+
+	mod.AddParam(session.NewStringParameter("wifi.decrypt.output",
+		"",
+		"",
+		"If set, decrypted frames will be written to this pcap file as 802.3 frames."))
+
+	mod.AddHandler(session.NewModuleHandler("wifi.geo.export FORMAT FILENAME", `wifi\.geo\.export\s+(kml|geojson|wigle)\s+(.+)`,
+		"Export the estimated position and the GPS tagged sightings of each access point as kml, geojson or wigle CSV.",
+		func(args []string) error {
+			return mod.geoExport(args[0], args[1])
+		}))
+
+	mod.AddHandler(session.NewModuleHandler("wifi.inject FRAME", `wifi\.inject\s+([a-fA-F0-9]+)`,
+		"Inject a raw hex encoded 802.11 frame, radiotap header included.",
+		func(args []string) error {
+			if !mod.Running() {
+				return fmt.Errorf("wifi.recon must be running in order to inject frames")
+			} else if raw, err := hex.DecodeString(args[0]); err != nil {
+				return err
+			} else {
+				mod.injectPacket(raw)
+			}
+			return nil
+		}))
+
 	mod.AddHandler(session.NewModuleHandler("wifi.show", "",
 		"Show current wireless stations list (default sorting by essid).",
 		func(args []string) error {
@@ -705,6 +865,9 @@ func (mod *WiFiModule) Start() error {
 				mod.discoverClients(radiotap, dot11, packet)
 				mod.discoverHandshakes(radiotap, dot11, packet)
 				mod.discoverDeauths(radiotap, dot11, packet)
+				mod.discoverWPS(radiotap, dot11, packet)
+				mod.discoverScriptFrames(radiotap, dot11, packet)
+				mod.decryptFrame(dot11, packet)
 				mod.updateInfo(dot11, packet)
 				mod.updateStats(dot11, packet)
 			}
@@ -729,6 +892,10 @@ func (mod *WiFiModule) forcedStop() error {
 
 func (mod *WiFiModule) Stop() error {
 	return mod.SetRunning(false, func() {
+		// interrupt the WPS attack, if any, instead of waiting for its timeouts
+		mod.stopWPS()
+		// script frame listeners don't survive a restart
+		mod.script.clearListeners()
 		// wait any pending write operation
 		mod.writes.Wait()
 		// signal the main for loop we want to exit
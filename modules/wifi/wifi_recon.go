@@ -171,7 +171,12 @@ func (mod *WiFiModule) discoverClients(radiotap *layers.RadioTap, dot11 *layers.
 			freq := int(radiotap.ChannelFrequency)
 			rssi := radiotap.DBMAntennaSignal
 
-			if station, isNew := ap.AddClientIfNew(bssid, freq, rssi); isNew {
+			station, isNew := ap.AddClientIfNew(bssid, freq, rssi)
+			if fix, ok := mod.Session.WiFi.Locate(); ok && rssi != 0 {
+				station.Geo.Add(fix, rssi)
+			}
+
+			if isNew {
 				mod.Session.Events.Add("wifi.client.new", ClientEvent{
 					AP:     ap,
 					Client: station,
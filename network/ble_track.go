@@ -0,0 +1,392 @@
+package network
+
+import (
+	"crypto/aes"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// maximum number of addresses kept in the history of a track
+	bleTrackMaxAddresses = 32
+	// advertising intervals are between 20ms and 10.24s
+	bleMinAdvInterval = 20 * time.Millisecond
+	bleMaxAdvInterval = 10240 * time.Millisecond
+	// relative tolerance when comparing advertising intervals
+	bleIntervalTolerance = 0.25
+)
+
+// BLEFingerprint describes the parts of an advertisement that usually don't
+// change when a device rotates its random address.
+type BLEFingerprint struct {
+	Services  []string
+	CompanyID uint16
+	// manufacturer data structure, i.e. lengths and message types but not the values
+	Layout  string
+	TxPower int
+	Flags   uint8
+	Name    string
+}
+
+// Key returns the fingerprint as a string, or an empty string if the
+// advertisement doesn't carry enough information to identify the device.
+func (f BLEFingerprint) Key() string {
+	if len(f.Services) == 0 && f.Layout == "" && f.Name == "" {
+		return ""
+	}
+
+	services := append([]string{}, f.Services...)
+	sort.Strings(services)
+
+	return fmt.Sprintf("svc=%s|mfg=%04x:%s|tx=%d|flags=%02x|name=%s",
+		strings.Join(services, ","),
+		f.CompanyID,
+		f.Layout,
+		f.TxPower,
+		f.Flags,
+		f.Name)
+}
+
+// BLEManufacturerLayout returns the structure of manufacturer specific data,
+// for Apple devices the sequence of continuity message types and lengths.
+func BLEManufacturerLayout(companyID uint16, data []byte) string {
+	if companyID != BLECompanyApple {
+		return fmt.Sprintf("%d", len(data))
+	}
+
+	parts := make([]string, 0)
+	for len(data) >= 2 {
+		t, l := data[0], int(data[1])
+		if len(data) < 2+l {
+			break
+		}
+		parts = append(parts, fmt.Sprintf("%02x/%d", t, l))
+		data = data[2+l:]
+	}
+	return strings.Join(parts, ",")
+}
+
+// IsResolvablePrivateAddress returns true if the two most significant bits of
+// the address are 01, as for resolvable private random addresses.
+func IsResolvablePrivateAddress(hw net.HardwareAddr) bool {
+	return len(hw) == 6 && hw[0]&0xc0 == 0x40
+}
+
+// BLEResolveAddress returns true if the resolvable private address has been
+// generated with the given identity resolving key.
+func BLEResolveAddress(irk []byte, hw net.HardwareAddr) bool {
+	if len(irk) != 16 || !IsResolvablePrivateAddress(hw) {
+		return false
+	}
+
+	block, err := aes.NewCipher(irk)
+	if err != nil {
+		return false
+	}
+
+	// ah(k, prand) = e(k, padding || prand) mod 2^24
+	r := make([]byte, 16)
+	copy(r[13:], hw[0:3])
+	block.Encrypt(r, r)
+
+	return r[13] == hw[3] && r[14] == hw[4] && r[15] == hw[5]
+}
+
+// ParseBLEIRK parses an identity resolving key in hexadecimal form.
+func ParseBLEIRK(s string) ([]byte, error) {
+	irk, err := hex.DecodeString(strings.Replace(strings.TrimSpace(s), ":", "", -1))
+	if err != nil {
+		return nil, err
+	} else if len(irk) != 16 {
+		return nil, fmt.Errorf("an IRK must be 16 bytes long, got %d", len(irk))
+	}
+	return irk, nil
+}
+
+// BLETrack is a physical device seen with one or more addresses.
+type BLETrack struct {
+	ID          int           `json:"id"`
+	Addresses   []string      `json:"addresses"`
+	Fingerprint string        `json:"fingerprint"`
+	Interval    time.Duration `json:"interval"`
+	IRK         string        `json:"irk"`
+	RSSI        int           `json:"rssi"`
+	FirstSeen   time.Time     `json:"first_seen"`
+	LastSeen    time.Time     `json:"last_seen"`
+}
+
+// Address returns the last address used by the device.
+func (t BLETrack) Address() string {
+	return t.Addresses[len(t.Addresses)-1]
+}
+
+type bleAddrState struct {
+	track    *BLETrack
+	lastSeen time.Time
+	interval time.Duration
+}
+
+type bleIRK struct {
+	name string
+	key  []byte
+	// same key in reversed byte order, tools don't agree on the endianness
+	rev []byte
+}
+
+// BLETracker correlates the random addresses of the same physical device, either
+// by resolving them with a known IRK or by matching advertisement fingerprints.
+type BLETracker struct {
+	sync.Mutex
+	nextID int
+	irks   []bleIRK
+	tracks map[int]*BLETrack
+	addrs  map[string]*bleAddrState
+	// an address is considered rotated if silent for at least this long ...
+	Silence time.Duration
+	// ... and a new one with the same fingerprint shows up within this window
+	Window time.Duration
+}
+
+func NewBLETracker() *BLETracker {
+	return &BLETracker{
+		nextID:  1,
+		irks:    make([]bleIRK, 0),
+		tracks:  make(map[int]*BLETrack),
+		addrs:   make(map[string]*bleAddrState),
+		Silence: 2 * time.Second,
+		Window:  60 * time.Second,
+	}
+}
+
+// SetIRK adds an identity resolving key with the given name.
+func (t *BLETracker) SetIRK(name string, key []byte) {
+	t.Lock()
+	defer t.Unlock()
+
+	rev := make([]byte, len(key))
+	for i := range key {
+		rev[i] = key[len(key)-1-i]
+	}
+
+	for i, irk := range t.irks {
+		if irk.name == name {
+			t.irks[i] = bleIRK{name: name, key: key, rev: rev}
+			return
+		}
+	}
+	t.irks = append(t.irks, bleIRK{name: name, key: key, rev: rev})
+}
+
+// ClearIRKs removes every identity resolving key.
+func (t *BLETracker) ClearIRKs() {
+	t.Lock()
+	defer t.Unlock()
+	t.irks = make([]bleIRK, 0)
+}
+
+func (t *BLETracker) resolve(addr string) string {
+	hw, err := net.ParseMAC(addr)
+	if err != nil {
+		return ""
+	}
+	for _, irk := range t.irks {
+		if BLEResolveAddress(irk.key, hw) || BLEResolveAddress(irk.rev, hw) {
+			return irk.name
+		}
+	}
+	return ""
+}
+
+func intervalsMatch(a, b time.Duration) bool {
+	if a == 0 || b == 0 {
+		return true
+	}
+	diff := float64(a - b)
+	if diff < 0 {
+		diff = -diff
+	}
+	max := float64(a)
+	if b > a {
+		max = float64(b)
+	}
+	return diff/max <= bleIntervalTolerance
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+// candidate looks for a track whose last address went silent recently and
+// that was advertising with the same fingerprint.
+func (t *BLETracker) candidate(fp string, st *bleAddrState, rssi int, now time.Time) *BLETrack {
+	var best *BLETrack
+	for _, track := range t.tracks {
+		if track.IRK != "" || track.Fingerprint != fp {
+			continue
+		}
+
+		silent := now.Sub(track.LastSeen)
+		if silent < t.Silence || silent > t.Window {
+			continue
+		} else if !intervalsMatch(track.Interval, st.interval) {
+			continue
+		}
+
+		if best == nil || track.LastSeen.After(best.LastSeen) ||
+			(track.LastSeen.Equal(best.LastSeen) && abs(track.RSSI-rssi) < abs(best.RSSI-rssi)) {
+			best = track
+		}
+	}
+	return best
+}
+
+func (t *BLETracker) newTrack(addr string, fp string, now time.Time) *BLETrack {
+	track := &BLETrack{
+		ID:          t.nextID,
+		Addresses:   []string{addr},
+		Fingerprint: fp,
+		FirstSeen:   now,
+	}
+	t.nextID++
+	t.tracks[track.ID] = track
+	return track
+}
+
+func (track *BLETrack) addAddress(addr string) {
+	track.Addresses = append(track.Addresses, addr)
+	if len(track.Addresses) > bleTrackMaxAddresses {
+		track.Addresses = track.Addresses[1:]
+	}
+}
+
+// Observe processes an advertisement of the given address and returns the
+// track of the device and, if the address has just been linked to an already
+// known device, the address it was previously using.
+func (t *BLETracker) Observe(addr string, fp BLEFingerprint, rssi int, now time.Time) (track BLETrack, previous string) {
+	t.Lock()
+	defer t.Unlock()
+
+	addr = NormalizeMac(addr)
+	key := fp.Key()
+
+	st, found := t.addrs[addr]
+	if !found {
+		st = &bleAddrState{}
+		t.addrs[addr] = st
+	} else if delta := now.Sub(st.lastSeen); delta >= bleMinAdvInterval && delta <= bleMaxAdvInterval {
+		// the minimum delta is the closest thing to the advertising interval
+		// we can get, since the scanner misses some of the packets
+		if st.interval == 0 || delta < st.interval {
+			st.interval = delta
+		}
+	}
+	st.lastSeen = now
+
+	if st.track == nil {
+		if irk := t.resolve(addr); irk != "" {
+			for _, tr := range t.tracks {
+				if tr.IRK == irk {
+					st.track = tr
+					break
+				}
+			}
+			if st.track == nil {
+				st.track = t.newTrack(addr, key, now)
+				st.track.IRK = irk
+			} else {
+				previous = st.track.Address()
+				st.track.addAddress(addr)
+			}
+		} else if key != "" {
+			if tr := t.candidate(key, st, rssi, now); tr != nil {
+				previous = tr.Address()
+				tr.addAddress(addr)
+				st.track = tr
+			}
+		}
+
+		if st.track == nil {
+			st.track = t.newTrack(addr, key, now)
+		}
+	}
+
+	tr := st.track
+	if tr.Address() == addr {
+		// only the current address of the device updates the track
+		tr.LastSeen = now
+		tr.RSSI = rssi
+		if key != "" {
+			tr.Fingerprint = key
+		}
+		if st.interval != 0 {
+			tr.Interval = st.interval
+		}
+	}
+
+	return t.copyOf(tr), previous
+}
+
+func (t *BLETracker) copyOf(track *BLETrack) BLETrack {
+	cp := *track
+	cp.Addresses = append([]string{}, track.Addresses...)
+	return cp
+}
+
+// Get returns the track of the given address.
+func (t *BLETracker) Get(addr string) (BLETrack, bool) {
+	t.Lock()
+	defer t.Unlock()
+
+	if st, found := t.addrs[NormalizeMac(addr)]; found && st.track != nil {
+		return t.copyOf(st.track), true
+	}
+	return BLETrack{}, false
+}
+
+// Tracks returns all the tracked devices.
+func (t *BLETracker) Tracks() []BLETrack {
+	t.Lock()
+	defer t.Unlock()
+
+	tracks := make([]BLETrack, 0, len(t.tracks))
+	for _, track := range t.tracks {
+		tracks = append(tracks, t.copyOf(track))
+	}
+	sort.Slice(tracks, func(i, j int) bool {
+		return tracks[i].ID < tracks[j].ID
+	})
+	return tracks
+}
+
+// Prune removes the tracks and addresses not seen for longer than ttl.
+func (t *BLETracker) Prune(ttl time.Duration, now time.Time) {
+	t.Lock()
+	defer t.Unlock()
+
+	for addr, st := range t.addrs {
+		if now.Sub(st.lastSeen) > ttl {
+			delete(t.addrs, addr)
+		}
+	}
+	for id, track := range t.tracks {
+		if now.Sub(track.LastSeen) > ttl {
+			delete(t.tracks, id)
+		}
+	}
+}
+
+// Clear removes every track.
+func (t *BLETracker) Clear() {
+	t.Lock()
+	defer t.Unlock()
+	t.tracks = make(map[int]*BLETrack)
+	t.addrs = make(map[string]*bleAddrState)
+}
@@ -0,0 +1,167 @@
+package network
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestBLEResolveAddress(t *testing.T) {
+	// Bluetooth Core Specification, Vol 3, Part H, D.7 (ah)
+	irk, err := ParseBLEIRK("ec0234a357c8ad05341010a60a397d9b")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hw, _ := net.ParseMAC("70:81:94:0d:fb:aa")
+	if !IsResolvablePrivateAddress(hw) {
+		t.Fatal("expected resolvable private address")
+	} else if !BLEResolveAddress(irk, hw) {
+		t.Fatal("address should resolve with the IRK")
+	}
+
+	hw, _ = net.ParseMAC("70:81:94:0d:fb:ab")
+	if BLEResolveAddress(irk, hw) {
+		t.Fatal("address should not resolve with the IRK")
+	}
+
+	if _, err := ParseBLEIRK("ec0234"); err == nil {
+		t.Fatal("expected error for a short key")
+	}
+}
+
+func TestBLEManufacturerLayout(t *testing.T) {
+	// apple handoff + nearby info, values must not matter
+	a := BLEManufacturerLayout(BLECompanyApple, []byte{0x0c, 0x02, 0xaa, 0xbb, 0x10, 0x01, 0x01})
+	b := BLEManufacturerLayout(BLECompanyApple, []byte{0x0c, 0x02, 0x11, 0x22, 0x10, 0x01, 0x07})
+	if a != b || a != "0c/2,10/1" {
+		t.Fatalf("unexpected layouts %s %s", a, b)
+	}
+
+	if l := BLEManufacturerLayout(BLECompanyMicrosoft, make([]byte, 27)); l != "27" {
+		t.Fatalf("unexpected layout %s", l)
+	}
+}
+
+func TestBLEFingerprintKey(t *testing.T) {
+	if key := (BLEFingerprint{Flags: 0x1a}).Key(); key != "" {
+		t.Fatalf("expected empty key for flags only advertisement, got %s", key)
+	}
+
+	a := BLEFingerprint{Services: []string{"fe9f", "180f"}, TxPower: 7}
+	b := BLEFingerprint{Services: []string{"180f", "fe9f"}, TxPower: 7}
+	if a.Key() != b.Key() {
+		t.Fatalf("services order should not matter: %s vs %s", a.Key(), b.Key())
+	}
+}
+
+var testTrackFP = BLEFingerprint{
+	CompanyID: BLECompanyApple,
+	Layout:    "10/5",
+	TxPower:   12,
+	Flags:     0x1a,
+}
+
+func TestBLETrackerRotation(t *testing.T) {
+	tracker := NewBLETracker()
+	now := time.Now()
+
+	first, prev := tracker.Observe("4a:11:22:33:44:55", testTrackFP, -60, now)
+	if prev != "" || first.ID == 0 {
+		t.Fatalf("unexpected first observation %+v %s", first, prev)
+	}
+	tracker.Observe("4a:11:22:33:44:55", testTrackFP, -60, now.Add(100*time.Millisecond))
+
+	// a different device with the same fingerprint while the first one is still advertising
+	other, prev := tracker.Observe("5b:11:22:33:44:55", testTrackFP, -70, now.Add(200*time.Millisecond))
+	if prev != "" || other.ID == first.ID {
+		t.Fatal("simultaneously active addresses should not be merged")
+	}
+	// the second device keeps advertising
+	tracker.Observe("5b:11:22:33:44:55", testTrackFP, -70, now.Add(4900*time.Millisecond))
+
+	// the first device goes silent and a new address shows up
+	rotated, prev := tracker.Observe("6c:11:22:33:44:55", testTrackFP, -61, now.Add(5*time.Second))
+	if prev != "4a:11:22:33:44:55" {
+		t.Fatalf("expected rotation from the first address, got '%s'", prev)
+	} else if rotated.ID != first.ID || len(rotated.Addresses) != 2 || rotated.Address() != "6c:11:22:33:44:55" {
+		t.Fatalf("unexpected track %+v", rotated)
+	}
+
+	if track, found := tracker.Get("4a:11:22:33:44:55"); !found || track.ID != first.ID {
+		t.Fatal("old address should still belong to the track")
+	} else if n := len(tracker.Tracks()); n != 2 {
+		t.Fatalf("expected 2 tracks, got %d", n)
+	}
+
+	// too late to be considered the same device
+	late, prev := tracker.Observe("7d:11:22:33:44:55", testTrackFP, -61, now.Add(10*time.Minute))
+	if prev != "" || late.ID == first.ID {
+		t.Fatal("addresses appearing after the window should not be merged")
+	}
+}
+
+func TestBLETrackerInterval(t *testing.T) {
+	tracker := NewBLETracker()
+	now := time.Now()
+
+	for i := 0; i < 5; i++ {
+		tracker.Observe("4a:11:22:33:44:55", testTrackFP, -60, now.Add(time.Duration(i)*100*time.Millisecond))
+	}
+
+	next := now.Add(5 * time.Second)
+	tracker.Observe("6c:11:22:33:44:55", testTrackFP, -60, next)
+	// advertising every second instead of every 100ms
+	track, prev := tracker.Observe("6c:11:22:33:44:55", testTrackFP, -60, next.Add(time.Second))
+	if prev != "" {
+		t.Fatal("unexpected rotation")
+	} else if track.Interval != time.Second {
+		t.Fatalf("expected 1s interval, got %s", track.Interval)
+	}
+}
+
+func TestBLETrackerIRK(t *testing.T) {
+	tracker := NewBLETracker()
+	irk, _ := ParseBLEIRK("ec0234a357c8ad05341010a60a397d9b")
+	tracker.SetIRK("phone", irk)
+
+	now := time.Now()
+	first, _ := tracker.Observe("70:81:94:0d:fb:aa", BLEFingerprint{}, -50, now)
+	if first.IRK != "phone" {
+		t.Fatalf("expected address resolved by IRK, got %+v", first)
+	}
+
+	// different fingerprint but same identity, build a second address with the same key
+	hw := generateRPA(t, irk, []byte{0x55, 0x44, 0x33})
+	second, prev := tracker.Observe(hw.String(), BLEFingerprint{Name: "whatever"}, -50, now.Add(time.Second))
+	if prev != "70:81:94:0d:fb:aa" || second.ID != first.ID {
+		t.Fatalf("expected resolution to the same track, got %+v (previous '%s')", second, prev)
+	}
+}
+
+func generateRPA(t *testing.T, irk []byte, prand []byte) net.HardwareAddr {
+	prand[0] = prand[0]&0x3f | 0x40
+	for h := 0; h < 1<<24; h++ {
+		hw := net.HardwareAddr{prand[0], prand[1], prand[2], byte(h >> 16), byte(h >> 8), byte(h)}
+		if BLEResolveAddress(irk, hw) {
+			return hw
+		}
+	}
+	t.Fatal("could not generate address")
+	return nil
+}
+
+func TestBLETrackerPrune(t *testing.T) {
+	tracker := NewBLETracker()
+	now := time.Now()
+
+	tracker.Observe("4a:11:22:33:44:55", testTrackFP, -60, now)
+	tracker.Observe("5b:11:22:33:44:55", testTrackFP, -60, now.Add(time.Hour))
+	tracker.Prune(30*time.Minute, now.Add(time.Hour))
+
+	if _, found := tracker.Get("4a:11:22:33:44:55"); found {
+		t.Fatal("expected stale address to be pruned")
+	} else if n := len(tracker.Tracks()); n != 1 {
+		t.Fatalf("expected 1 track, got %d", n)
+	}
+}
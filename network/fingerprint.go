@@ -0,0 +1,352 @@
+package network
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// sources of passive fingerprints
+const (
+	FingerprintTCP       = "tcp"
+	FingerprintDHCP      = "dhcp"
+	FingerprintUserAgent = "http"
+	FingerprintMDNS      = "mdns"
+	FingerprintUPNP      = "upnp"
+)
+
+// OSEvidence is a guess about the operating system and the kind of an
+// endpoint, made from a single source.
+type OSEvidence struct {
+	Source     string `json:"source"`
+	OS         string `json:"os"`
+	Device     string `json:"device"`
+	Confidence int    `json:"confidence"`
+}
+
+func (e OSEvidence) String() string {
+	s := e.OS
+	if e.Device != "" {
+		if s != "" {
+			s += " "
+		}
+		s += "(" + e.Device + ")"
+	}
+	return fmt.Sprintf("%s %d%%", s, e.Confidence)
+}
+
+// TCPSignature is the p0f style signature of a TCP SYN packet.
+type TCPSignature struct {
+	TTL    uint8
+	Window uint16
+	MSS    uint16
+	// -1 if the window scale option is not present
+	WScale int
+	// comma separated option kinds: M(ss), N(op), W(scale), S(ack), T(imestamp), E(ol)
+	Layout string
+}
+
+func (s TCPSignature) String() string {
+	return fmt.Sprintf("%d:%d:%d:%d:%s", s.TTL, s.Window, s.MSS, s.WScale, s.Layout)
+}
+
+// InitialTTL returns the TTL the packet most likely had when it was sent.
+func (s TCPSignature) InitialTTL() uint8 {
+	for _, ttl := range []uint8{32, 64, 128} {
+		if s.TTL <= ttl {
+			return ttl
+		}
+	}
+	return 255
+}
+
+type tcpFingerprint struct {
+	ttl    uint8
+	layout string
+	// 0 matches any window
+	window uint16
+	// -1 matches any scale
+	wscale     int
+	os         string
+	device     string
+	confidence int
+}
+
+type dhcpFingerprint struct {
+	params     string
+	os         string
+	device     string
+	confidence int
+}
+
+type patternFingerprint struct {
+	re         *regexp.Regexp
+	os         string
+	device     string
+	confidence int
+}
+
+var (
+	// ordered from the most to the least specific
+	tcpFingerprints = []tcpFingerprint{
+		{128, "M,N,W,N,N,S", 64240, 8, "Windows", "", 70},
+		{128, "M,N,W,N,N,S", 65535, 8, "Windows", "", 70},
+		{128, "M,N,W,N,N,S", 8192, -1, "Windows", "", 60},
+		{128, "M,N,W,S,T", 0, -1, "Windows", "", 50},
+		{128, "M,N,N,S", 0, -1, "Windows", "", 50},
+		{64, "M,N,W,N,N,T,S,E,E", 65535, 6, "Apple", "", 70},
+		{64, "M,N,W,N,N,T,S,E", 65535, -1, "Apple", "", 70},
+		{64, "M,N,W,S,T", 65535, 6, "FreeBSD", "", 60},
+		{64, "M,N,N,S,N,W,N,N,T", 16384, -1, "OpenBSD", "", 60},
+		{64, "M,S,T,N,W", 0, -1, "Linux", "", 60},
+		{64, "M,N,N,S,N,W", 0, -1, "Linux", "", 40},
+		{64, "M,S,N,W", 0, -1, "Linux", "", 40},
+		{64, "M", 0, -1, "Linux", "embedded", 30},
+		{255, "M", 0, -1, "", "network device", 40},
+		{255, "", 0, -1, "", "network device", 30},
+	}
+
+	// DHCP option 55 parameter request lists
+	dhcpFingerprints = []dhcpFingerprint{
+		{"1,3,6,15,31,33,43,44,46,47,119,121,249,252", "Windows", "", 80},
+		{"1,15,3,6,44,46,47,31,33,121,249,43,252", "Windows", "", 75},
+		{"1,15,3,6,44,46,47,31,33,121,249,43", "Windows", "", 75},
+		{"1,15,3,6,44,46,47,31,33,249,43,252", "Windows", "", 70},
+		{"1,121,3,6,15,108,114,119,252,95,44,46", "Apple", "computer", 80},
+		{"1,121,3,6,15,119,252,95,44,46", "Apple", "computer", 80},
+		{"1,121,3,6,15,108,114,119,252", "Apple", "phone", 75},
+		{"1,121,3,6,15,119,252", "Apple", "phone", 75},
+		{"1,3,6,15,26,28,51,58,59,43", "Android", "phone", 80},
+		{"1,3,6,15,26,28,51,58,59,43,114,108", "Android", "phone", 80},
+		{"1,3,6,15,26,28,51,58,59", "Android", "phone", 70},
+		{"1,33,3,6,15,28,51,58,59", "Android", "phone", 70},
+		{"1,28,2,3,15,6,119,12,44,47,26,121,42", "Linux", "computer", 75},
+		{"1,28,2,121,15,6,12,40,41,42,26,119,3,121,249,33,252,42", "Linux", "computer", 70},
+		{"1,3,6,12,15,28,42", "Linux", "embedded", 60},
+		{"1,3,6,12,15,28,40,41,42", "Linux", "embedded", 60},
+		{"1,3,6,15,119,252", "Apple", "", 60},
+	}
+
+	userAgentFingerprints = []patternFingerprint{
+		{regexp.MustCompile(`Xbox`), "Windows", "game console", 85},
+		{regexp.MustCompile(`PlayStation`), "PlayStation", "game console", 85},
+		{regexp.MustCompile(`Nintendo`), "Nintendo", "game console", 85},
+		{regexp.MustCompile(`Windows Phone`), "Windows", "phone", 85},
+		{regexp.MustCompile(`Windows NT`), "Windows", "computer", 80},
+		{regexp.MustCompile(`iPad`), "iOS", "tablet", 85},
+		{regexp.MustCompile(`iPhone`), "iOS", "phone", 85},
+		{regexp.MustCompile(`AppleTV|tvOS`), "tvOS", "tv", 85},
+		{regexp.MustCompile(`Macintosh|Mac OS X`), "macOS", "computer", 80},
+		{regexp.MustCompile(`SMART-TV|SmartTV|Tizen|Web0S|webOS|BRAVIA|HbbTV`), "Linux", "tv", 80},
+		{regexp.MustCompile(`Android.*Mobile`), "Android", "phone", 85},
+		{regexp.MustCompile(`Android`), "Android", "tablet", 75},
+		{regexp.MustCompile(`CrOS`), "ChromeOS", "computer", 85},
+		{regexp.MustCompile(`Linux`), "Linux", "computer", 60},
+		{regexp.MustCompile(`(?i)^(curl|wget|python-requests|go-http-client)`), "", "", 0},
+	}
+
+	upnpFingerprints = []patternFingerprint{
+		{regexp.MustCompile(`(?i)Microsoft-Windows`), "Windows", "computer", 70},
+		{regexp.MustCompile(`(?i)Darwin`), "Apple", "", 60},
+		{regexp.MustCompile(`(?i)Android`), "Android", "", 60},
+		{regexp.MustCompile(`(?i)Tizen|webOS|Roku|BRAVIA|Chromecast`), "Linux", "tv", 70},
+		{regexp.MustCompile(`(?i)Sonos`), "Linux", "speaker", 70},
+		{regexp.MustCompile(`(?i)IGD|InternetGatewayDevice|router`), "", "router", 60},
+		{regexp.MustCompile(`(?i)printer`), "", "printer", 60},
+		{regexp.MustCompile(`(?i)Linux`), "Linux", "", 50},
+	}
+
+	// operating systems sharing the same network stack
+	osFamilies = map[string]string{
+		"macOS":    "Apple",
+		"iOS":      "Apple",
+		"tvOS":     "Apple",
+		"Android":  "Linux",
+		"ChromeOS": "Linux",
+	}
+
+	// Apple _device-info model names and other common mDNS TXT records
+	mdnsFingerprints = []patternFingerprint{
+		{regexp.MustCompile(`^(MacBook|iMac|Macmini|MacPro|Mac\d)`), "macOS", "computer", 90},
+		{regexp.MustCompile(`^iPhone`), "iOS", "phone", 90},
+		{regexp.MustCompile(`^iPad`), "iOS", "tablet", 90},
+		{regexp.MustCompile(`^(AppleTV|AudioAccessory)`), "tvOS", "tv", 90},
+		{regexp.MustCompile(`(?i)Chromecast|Google TV|Nest Hub`), "Linux", "tv", 85},
+		{regexp.MustCompile(`(?i)Nest|Google Home`), "Linux", "speaker", 80},
+		{regexp.MustCompile(`(?i)printer|LaserJet|OfficeJet|DeskJet|EPSON|Brother|Canon`), "", "printer", 80},
+	}
+)
+
+// FingerprintTCPSignature returns the evidence given by the signature of a
+// TCP SYN packet, if it matches a known one.
+func FingerprintTCPSignature(sig TCPSignature) *OSEvidence {
+	ttl := sig.InitialTTL()
+	for _, f := range tcpFingerprints {
+		if f.ttl == ttl && f.layout == sig.Layout &&
+			(f.window == 0 || f.window == sig.Window) &&
+			(f.wscale == -1 || f.wscale == sig.WScale) {
+			return &OSEvidence{Source: FingerprintTCP, OS: f.os, Device: f.device, Confidence: f.confidence}
+		}
+	}
+	return nil
+}
+
+// FingerprintDHCPParams returns the evidence given by the parameter request
+// list option of a DHCP request, if it matches a known one.
+func FingerprintDHCPParams(params []byte) *OSEvidence {
+	list := make([]string, len(params))
+	for i, p := range params {
+		list[i] = fmt.Sprintf("%d", p)
+	}
+	key := strings.Join(list, ",")
+
+	for _, f := range dhcpFingerprints {
+		if f.params == key {
+			return &OSEvidence{Source: FingerprintDHCP, OS: f.os, Device: f.device, Confidence: f.confidence}
+		}
+	}
+	return nil
+}
+
+func matchPatterns(source string, value string, patterns []patternFingerprint) *OSEvidence {
+	for _, f := range patterns {
+		if f.re.MatchString(value) {
+			if f.confidence == 0 {
+				// explicitly ignored
+				return nil
+			}
+			return &OSEvidence{Source: source, OS: f.os, Device: f.device, Confidence: f.confidence}
+		}
+	}
+	return nil
+}
+
+// FingerprintUserAgentString returns the evidence given by an HTTP
+// User-Agent, if it matches a known one.
+func FingerprintUserAgentString(ua string) *OSEvidence {
+	return matchPatterns(FingerprintUserAgent, ua, userAgentFingerprints)
+}
+
+// FingerprintMeta returns the evidence given by the mDNS and UPnP metadata
+// of an endpoint.
+func FingerprintMeta(meta map[string]string) []OSEvidence {
+	found := make([]OSEvidence, 0)
+	for _, key := range []string{"mdns:model", "mdns:md", "mdns:am", "mdns:ty", "mdns:usb_MDL"} {
+		if value, ok := meta[key]; ok {
+			if ev := matchPatterns(FingerprintMDNS, value, mdnsFingerprints); ev != nil {
+				found = append(found, *ev)
+				break
+			}
+		}
+	}
+
+	for _, key := range []string{"upnp:Server", "upnp:St", "upnp:Nt"} {
+		if value, ok := meta[key]; ok {
+			if ev := matchPatterns(FingerprintUPNP, value, upnpFingerprints); ev != nil {
+				found = append(found, *ev)
+				break
+			}
+		}
+	}
+
+	return found
+}
+
+// Fingerprint combines the evidence collected from the different sources.
+type Fingerprint struct {
+	sync.Mutex
+	evidence map[string]OSEvidence
+}
+
+func NewFingerprint() *Fingerprint {
+	return &Fingerprint{
+		evidence: make(map[string]OSEvidence),
+	}
+}
+
+// Add stores the evidence, replacing the previous one from the same source,
+// and returns the combined guess.
+func (f *Fingerprint) Add(ev OSEvidence) OSEvidence {
+	f.Lock()
+	defer f.Unlock()
+
+	f.evidence[ev.Source] = ev
+	return f.guess()
+}
+
+// Guess returns the combined guess.
+func (f *Fingerprint) Guess() OSEvidence {
+	f.Lock()
+	defer f.Unlock()
+	return f.guess()
+}
+
+func osFamily(os string) string {
+	if family, found := osFamilies[os]; found {
+		return family
+	}
+	return os
+}
+
+func (f *Fingerprint) guess() OSEvidence {
+	// the sources agreeing on an operating system family reinforce each
+	// other, the ones disagreeing lower the confidence proportionally
+	doubt := make(map[string]float64)
+	weight := make(map[string]int)
+	sources := make(map[string][]string)
+	total := 0
+	for _, ev := range f.evidence {
+		if ev.OS == "" {
+			continue
+		}
+		family := osFamily(ev.OS)
+		if _, found := doubt[family]; !found {
+			doubt[family] = 1.0
+		}
+		doubt[family] *= 1.0 - float64(ev.Confidence)/100.0
+		weight[family] += ev.Confidence
+		sources[family] = append(sources[family], ev.Source)
+		total += ev.Confidence
+	}
+
+	family := ""
+	for name := range weight {
+		if weight[name] > weight[family] || (weight[name] == weight[family] && name < family) {
+			family = name
+		}
+	}
+
+	guess := OSEvidence{}
+	if family != "" {
+		// the most specific name of the family wins
+		guess.OS = family
+		best := 0
+		for _, ev := range f.evidence {
+			if osFamily(ev.OS) == family && ev.OS != family && ev.Confidence > best {
+				guess.OS = ev.OS
+				best = ev.Confidence
+			}
+		}
+
+		confidence := (1.0 - doubt[family]) * float64(weight[family]) / float64(total)
+		guess.Confidence = int(math.Round(confidence * 100))
+		sort.Strings(sources[family])
+		guess.Source = strings.Join(sources[family], ",")
+	}
+
+	best := 0
+	for _, ev := range f.evidence {
+		if ev.Device != "" && (ev.Confidence > best || (ev.Confidence == best && ev.Device < guess.Device)) {
+			guess.Device = ev.Device
+			best = ev.Confidence
+		}
+	}
+	if guess.OS == "" && guess.Device != "" {
+		guess.Confidence = best
+	}
+
+	return guess
+}
@@ -0,0 +1,133 @@
+package network
+
+import (
+	"testing"
+)
+
+func TestTCPSignatureInitialTTL(t *testing.T) {
+	for ttl, exp := range map[uint8]uint8{30: 32, 50: 64, 64: 64, 120: 128, 200: 255} {
+		if got := (TCPSignature{TTL: ttl}).InitialTTL(); got != exp {
+			t.Fatalf("expected %d for %d, got %d", exp, ttl, got)
+		}
+	}
+}
+
+func TestFingerprintTCPSignature(t *testing.T) {
+	ev := FingerprintTCPSignature(TCPSignature{TTL: 61, Window: 64240, MSS: 1460, WScale: 7, Layout: "M,S,T,N,W"})
+	if ev == nil || ev.OS != "Linux" || ev.Source != FingerprintTCP {
+		t.Fatalf("unexpected evidence %+v", ev)
+	}
+
+	ev = FingerprintTCPSignature(TCPSignature{TTL: 127, Window: 64240, MSS: 1460, WScale: 8, Layout: "M,N,W,N,N,S"})
+	if ev == nil || ev.OS != "Windows" {
+		t.Fatalf("unexpected evidence %+v", ev)
+	}
+
+	if ev = FingerprintTCPSignature(TCPSignature{TTL: 64, Layout: "T,T,T"}); ev != nil {
+		t.Fatalf("unexpected evidence %+v", ev)
+	}
+}
+
+func TestFingerprintDHCPParams(t *testing.T) {
+	ev := FingerprintDHCPParams([]byte{1, 3, 6, 15, 26, 28, 51, 58, 59, 43})
+	if ev == nil || ev.OS != "Android" || ev.Device != "phone" || ev.Source != FingerprintDHCP {
+		t.Fatalf("unexpected evidence %+v", ev)
+	}
+
+	if ev = FingerprintDHCPParams([]byte{1, 3}); ev != nil {
+		t.Fatalf("unexpected evidence %+v", ev)
+	}
+}
+
+func TestFingerprintUserAgentString(t *testing.T) {
+	var units = []struct {
+		ua     string
+		os     string
+		device string
+	}{
+		{"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36", "Windows", "computer"},
+		{"Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) AppleWebKit/605.1.15", "iOS", "phone"},
+		{"Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36 Mobile Safari/537.36", "Android", "phone"},
+		{"Mozilla/5.0 (X11; Linux x86_64; rv:120.0) Gecko/20100101 Firefox/120.0", "Linux", "computer"},
+	}
+	for _, u := range units {
+		ev := FingerprintUserAgentString(u.ua)
+		if ev == nil || ev.OS != u.os || ev.Device != u.device {
+			t.Fatalf("unexpected evidence %+v for '%s'", ev, u.ua)
+		}
+	}
+
+	if ev := FingerprintUserAgentString("curl/8.4.0"); ev != nil {
+		t.Fatalf("unexpected evidence %+v", ev)
+	}
+}
+
+func TestFingerprintMeta(t *testing.T) {
+	found := FingerprintMeta(map[string]string{
+		"mdns:model":  "MacBookPro18,1",
+		"upnp:Server": "Linux/4.9 UPnP/1.0 Sonos/70.3",
+	})
+	if len(found) != 2 {
+		t.Fatalf("expected 2 evidences, got %+v", found)
+	}
+	if found[0].OS != "macOS" || found[0].Device != "computer" || found[0].Source != FingerprintMDNS {
+		t.Fatalf("unexpected evidence %+v", found[0])
+	}
+	if found[1].Device != "speaker" || found[1].Source != FingerprintUPNP {
+		t.Fatalf("unexpected evidence %+v", found[1])
+	}
+
+	if found = FingerprintMeta(map[string]string{"mdns:hostname": "foo.local"}); len(found) != 0 {
+		t.Fatalf("unexpected evidences %+v", found)
+	}
+}
+
+func TestFingerprintAgreement(t *testing.T) {
+	f := NewFingerprint()
+	f.Add(OSEvidence{Source: FingerprintTCP, OS: "Linux", Confidence: 60})
+	guess := f.Add(OSEvidence{Source: FingerprintUserAgent, OS: "Android", Device: "phone", Confidence: 85})
+
+	// 1 - (0.4 * 0.15)
+	exp := OSEvidence{Source: "http,tcp", OS: "Android", Device: "phone", Confidence: 94}
+	if guess != exp {
+		t.Fatalf("expected %+v, got %+v", exp, guess)
+	}
+}
+
+func TestFingerprintDisagreement(t *testing.T) {
+	f := NewFingerprint()
+	f.Add(OSEvidence{Source: FingerprintTCP, OS: "Windows", Confidence: 70})
+	guess := f.Add(OSEvidence{Source: FingerprintUserAgent, OS: "macOS", Device: "computer", Confidence: 80})
+
+	// 0.8 * 80 / 150
+	exp := OSEvidence{Source: "http", OS: "macOS", Device: "computer", Confidence: 43}
+	if guess != exp {
+		t.Fatalf("expected %+v, got %+v", exp, guess)
+	}
+}
+
+func TestFingerprintDeviceOnly(t *testing.T) {
+	f := NewFingerprint()
+	guess := f.Add(OSEvidence{Source: FingerprintUPNP, Device: "router", Confidence: 60})
+	if guess.OS != "" || guess.Device != "router" || guess.Confidence != 60 {
+		t.Fatalf("unexpected guess %+v", guess)
+	}
+}
+
+func TestEndpointOnFingerprint(t *testing.T) {
+	e := NewEndpointNoResolve(IpVersions{IPv4: "192.168.1.10"}, "aa:bb:cc:dd:ee:ff", "", 24)
+	e.OnMeta(map[string]string{"mdns:model": "MacBookPro18,1"})
+
+	if os := e.Meta.Get("os"); os != "macOS" {
+		t.Fatalf("unexpected os '%v'", os)
+	} else if device := e.Meta.Get("os:device"); device != "computer" {
+		t.Fatalf("unexpected device '%v'", device)
+	} else if confidence := e.Meta.Get("os:confidence"); confidence != "90%" {
+		t.Fatalf("unexpected confidence '%v'", confidence)
+	}
+
+	e.OnFingerprint(OSEvidence{Source: FingerprintTCP, OS: "Apple", Confidence: 70})
+	if guess := e.OS(); guess.OS != "macOS" || guess.Confidence != 97 {
+		t.Fatalf("unexpected guess %+v", guess)
+	}
+}
@@ -20,6 +20,8 @@ const (
 	HIDTypeAmazon    HIDType = 2
 	HIDTypeMicrosoft HIDType = 3
 	HIDTypeDell      HIDType = 4
+	// Microsoft models XORing the payloads with the device address
+	HIDTypeMicrosoftEncrypted HIDType = 5
 )
 
 func (t HIDType) String() string {
@@ -32,12 +34,17 @@ func (t HIDType) String() string {
 		return "Microsoft"
 	case HIDTypeDell:
 		return "Dell"
+	case HIDTypeMicrosoftEncrypted:
+		return "Microsoft (encrypted)"
 	}
 	return ""
 }
 
 type HIDPayload []byte
 
+// HIDMaxKeystrokes is the number of decoded characters kept for each device.
+const HIDMaxKeystrokes = 8192
+
 type HIDDevice struct {
 	sync.Mutex
 	LastSeen   time.Time
@@ -48,6 +55,9 @@ type HIDDevice struct {
 	channels   map[int]bool
 	payloads   []HIDPayload
 	payloadsSz uint64
+	keystrokes []rune
+	// the text typed since the last new line
+	line []rune
 }
 
 type hidDeviceJSON struct {
@@ -58,6 +68,7 @@ type hidDeviceJSON struct {
 	Channels     []string  `json:"channels"`
 	Payloads     []string  `json:"payloads"`
 	PayloadsSize uint64    `json:"payloads_size"`
+	Keystrokes   string    `json:"keystrokes"`
 }
 
 func NormalizeHIDAddress(address string) string {
@@ -88,6 +99,8 @@ func NewHIDDevice(address []byte, channel int, payload []byte) *HIDDevice {
 		channels:   make(map[int]bool),
 		payloads:   make([]HIDPayload, 0),
 		payloadsSz: 0,
+		keystrokes: make([]rune, 0),
+		line:       make([]rune, 0),
 	}
 
 	dev.AddChannel(channel)
@@ -108,6 +121,7 @@ func (dev *HIDDevice) MarshalJSON() ([]byte, error) {
 		Channels:     dev.channelsListUnlocked(),
 		Payloads:     make([]string, 0),
 		PayloadsSize: dev.payloadsSz,
+		Keystrokes:   string(dev.keystrokes),
 	}
 
 	// get the latest 50 payloads
@@ -179,9 +193,14 @@ func (dev *HIDDevice) onEventFrame(p []byte, sz int) {
 	if sz == 19 && (p[0] == 0x08 || p[0] == 0x0c) && p[6] == 0x40 {
 		dev.Type = HIDTypeMicrosoft
 		return
+	} else if sz == 19 && p[0] == 0x0a && len(dev.RawAddress) == 5 && p[6]^dev.RawAddress[2] == 0x40 {
+		// the keystroke type byte is XORed with the third byte of the address
+		dev.Type = HIDTypeMicrosoftEncrypted
+		return
 	}
 
-	// TODO: Dell
+	// Dell, HP, Lenovo and Gigabyte devices are not fingerprinted and
+	// can't be injected, they are reported with an unknown type.
 }
 
 func (dev *HIDDevice) AddPayload(payload []byte) {
@@ -218,3 +237,52 @@ func (dev *HIDDevice) PayloadsSize() uint64 {
 	defer dev.Unlock()
 	return dev.payloadsSz
 }
+
+func trimRunes(runes []rune, max int) []rune {
+	if n := len(runes) - max; n > 0 {
+		return append(runes[:0], runes[n:]...)
+	}
+	return runes
+}
+
+// AddKeystrokes appends the text typed on the device, a \b character
+// deletes the previous one, and returns the line being typed. Only the
+// last HIDMaxKeystrokes characters are kept.
+func (dev *HIDDevice) AddKeystrokes(text string) string {
+	dev.Lock()
+	defer dev.Unlock()
+
+	for _, r := range text {
+		if r == '\n' {
+			dev.keystrokes = append(dev.keystrokes, r)
+			dev.line = dev.line[:0]
+		} else if r != '\b' {
+			dev.keystrokes = append(dev.keystrokes, r)
+			dev.line = append(dev.line, r)
+		} else if n := len(dev.keystrokes); n > 0 {
+			dev.keystrokes = dev.keystrokes[:n-1]
+			if l := len(dev.line); l > 0 {
+				dev.line = dev.line[:l-1]
+			} else {
+				// back to the end of the previous line
+				start := n - 1
+				for start > 0 && dev.keystrokes[start-1] != '\n' {
+					start--
+				}
+				dev.line = append(dev.line, dev.keystrokes[start:]...)
+			}
+		}
+	}
+
+	dev.keystrokes = trimRunes(dev.keystrokes, HIDMaxKeystrokes)
+	dev.line = trimRunes(dev.line, HIDMaxKeystrokes)
+
+	return string(dev.line)
+}
+
+// Keystrokes returns the text typed on the device so far.
+func (dev *HIDDevice) Keystrokes() string {
+	dev.Lock()
+	defer dev.Unlock()
+	return string(dev.keystrokes)
+}
@@ -0,0 +1,70 @@
+package network
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestHIDDeviceKeystrokes(t *testing.T) {
+	dev := NewHIDDevice([]byte{0x01, 0x02, 0x03, 0x04, 0x05}, 1, nil)
+	if dev.Address != "01:02:03:04:05" {
+		t.Fatalf("unexpected address %s", dev.Address)
+	}
+
+	lines := []struct {
+		keys string
+		line string
+	}{
+		{"\bpassw", "passw"},
+		{"x\bd\n", ""},
+		{"[CTRL+c]", "[CTRL+c]"},
+	}
+	for _, l := range lines {
+		if got := dev.AddKeystrokes(l.keys); got != l.line {
+			t.Fatalf("expected line %q after %q, got %q", l.line, l.keys, got)
+		}
+	}
+
+	if got := dev.Keystrokes(); got != "passwd\n[CTRL+c]" {
+		t.Fatalf("unexpected keystrokes %q", got)
+	}
+
+	raw, err := json.Marshal(dev)
+	if err != nil {
+		t.Fatal(err)
+	} else if !strings.Contains(string(raw), `"keystrokes":"passwd\n[CTRL+c]"`) {
+		t.Fatalf("keystrokes missing from %s", raw)
+	}
+}
+
+func TestHIDDeviceKeystrokesLines(t *testing.T) {
+	dev := NewHIDDevice([]byte{0x01, 0x02, 0x03, 0x04, 0x05}, 1, nil)
+
+	dev.AddKeystrokes("ls\ncd")
+	// deleting the new line goes back to the previous line
+	if got := dev.AddKeystrokes("\b\b\b"); got != "ls" {
+		t.Fatalf("unexpected line %q", got)
+	} else if got := dev.AddKeystrokes("\b\b\b\bpwd"); got != "pwd" {
+		t.Fatalf("unexpected line %q", got)
+	}
+
+	dev.AddKeystrokes(strings.Repeat("a", HIDMaxKeystrokes))
+	if got := dev.AddKeystrokes("\nb"); got != "b" {
+		t.Fatalf("unexpected line %q", got)
+	} else if got := dev.Keystrokes(); len(got) != HIDMaxKeystrokes || !strings.HasSuffix(got, "a\nb") {
+		t.Fatalf("expected the last %d keystrokes, got %d", HIDMaxKeystrokes, len(got))
+	}
+}
+
+func TestHIDDeviceMicrosoftEncrypted(t *testing.T) {
+	address := []byte{0xa1, 0xb2, 0xc3, 0xd4, 0xe5}
+	payload := make([]byte, 19)
+	payload[0] = 0x0a
+	payload[6] = 0x40 ^ address[2]
+
+	dev := NewHIDDevice(address, 1, payload)
+	if dev.Type != HIDTypeMicrosoftEncrypted {
+		t.Fatalf("unexpected type %s", dev.Type)
+	}
+}
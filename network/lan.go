@@ -75,14 +75,16 @@ func (lan *LAN) GetByIp(ip string) *Endpoint {
 	lan.Lock()
 	defer lan.Unlock()
 
-	if ip == lan.iface.IpAddress {
+	if ip == "" {
+		return nil
+	} else if ip == lan.iface.IpAddress || ip == lan.iface.Ip6Address {
 		return lan.iface
-	} else if ip == lan.gateway.IpAddress {
+	} else if ip == lan.gateway.IpAddress || ip == lan.gateway.Ip6Address {
 		return lan.gateway
 	}
 
 	for _, e := range lan.hosts {
-		if e.IpAddress == ip {
+		if e.IpAddress == ip || e.Ip6Address == ip {
 			return e
 		}
 	}
@@ -179,6 +181,32 @@ func (lan *LAN) EachHost(cb func(mac string, e *Endpoint)) {
 	}
 }
 
+// Names returns the alias and hostname of each known address, to resolve
+// them in the pcapng captures.
+func (lan *LAN) Names() map[string][]string {
+	lan.Lock()
+	defer lan.Unlock()
+
+	names := make(map[string][]string)
+	for _, h := range lan.hosts {
+		hostNames := []string{}
+		for _, name := range []string{h.Alias, h.Hostname} {
+			if name != "" && (len(hostNames) == 0 || hostNames[0] != name) {
+				hostNames = append(hostNames, name)
+			}
+		}
+		if len(hostNames) == 0 {
+			continue
+		}
+		for _, address := range []string{h.IpAddress, h.Ip6Address} {
+			if address != "" {
+				names[address] = hostNames
+			}
+		}
+	}
+	return names
+}
+
 // Refresh ttl of host or create a new one
 // @return Endpoint if host already exists
 func (lan *LAN) AddIfNew(ipVersions IpVersions, mac string) *Endpoint {
@@ -31,6 +31,8 @@ type Endpoint struct {
 	FirstSeen        time.Time              `json:"first_seen"`
 	LastSeen         time.Time              `json:"last_seen"`
 	Meta             *Meta                  `json:"meta"`
+
+	fingerprint *Fingerprint
 }
 
 func NewEndpointNoResolve(ipVersions IpVersions, mac, name string, bits uint32) *Endpoint {
@@ -57,6 +59,7 @@ func NewEndpointNoResolve(ipVersions IpVersions, mac, name string, bits uint32)
 		FirstSeen:        now,
 		LastSeen:         now,
 		Meta:             NewMeta(),
+		fingerprint:      NewFingerprint(),
 	}
 
 	if ipVersions.IPv4 != "" {
@@ -186,4 +189,35 @@ func (t *Endpoint) OnMeta(meta map[string]string) {
 	if t.Hostname == "" {
 		t.Hostname = host
 	}
+
+	for _, ev := range FingerprintMeta(meta) {
+		t.OnFingerprint(ev)
+	}
+}
+
+// OnFingerprint combines the evidence with the previous ones and stores the
+// resulting guess in the metadata.
+func (t *Endpoint) OnFingerprint(ev OSEvidence) {
+	if t.fingerprint == nil {
+		return
+	}
+
+	guess := t.fingerprint.Add(ev)
+	if guess.OS != "" {
+		t.Meta.Set("os", guess.OS)
+		t.Meta.Set("os:sources", guess.Source)
+	}
+	if guess.Device != "" {
+		t.Meta.Set("os:device", guess.Device)
+	}
+	t.Meta.Set("os:confidence", fmt.Sprintf("%d%%", guess.Confidence))
+}
+
+// OS returns the combined guess about the operating system and the kind of
+// the endpoint.
+func (t *Endpoint) OS() OSEvidence {
+	if t.fingerprint == nil {
+		return OSEvidence{}
+	}
+	return t.fingerprint.Guess()
 }
@@ -171,6 +171,19 @@ func TestGetByIp(t *testing.T) {
 	}
 }
 
+func TestGetByIp6(t *testing.T) {
+	exampleLAN := buildExampleLAN()
+	exampleEndpoint := NewEndpointNoResolve(IpVersions{IPv4: "10.0.0.42", IPv6: "fe80::42"}, "aa:bb:cc:dd:ee:42", "", 24)
+	exampleLAN.hosts[exampleEndpoint.HwAddress] = exampleEndpoint
+
+	if got := exampleLAN.GetByIp("fe80::42"); got != exampleEndpoint {
+		t.Fatalf("expected '%v', got '%v'", exampleEndpoint, got)
+	}
+	if got := exampleLAN.GetByIp("fe80::43"); got != nil {
+		t.Fatalf("unexpected endpoint '%v'", got)
+	}
+}
+
 func TestAddIfNew(t *testing.T) {
 	exampleLAN := buildExampleLAN()
 	iface, _ := FindInterface("")
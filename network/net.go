@@ -242,6 +242,28 @@ func findInterfaceByName(name string, ifaces []net.Interface) (*Endpoint, error)
 	return nil, fmt.Errorf("no interface matching '%s' found.", name)
 }
 
+// FindLinkLocalIPv6 returns the fe80::/10 address of the interface, the one
+// the link scoped messages such as the MLD queries must be sent from.
+func FindLinkLocalIPv6(iface *Endpoint) (net.IP, error) {
+	ifi, err := net.InterfaceByIndex(iface.Index)
+	if err != nil {
+		return nil, err
+	}
+
+	addrs, err := ifi.Addrs()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, a := range addrs {
+		if ipnet, ok := a.(*net.IPNet); ok && ipnet.IP.To4() == nil && ipnet.IP.IsLinkLocalUnicast() {
+			return ipnet.IP, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%s has no link-local IPv6 address", iface.Name())
+}
+
 func FindInterface(name string) (*Endpoint, error) {
 	ifaces, err := net.Interfaces()
 	if err != nil {
@@ -0,0 +1,259 @@
+package network
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/bettercap/bettercap/core"
+
+	"github.com/gopacket/gopacket"
+	"github.com/gopacket/gopacket/layers"
+)
+
+// block and option types from https://www.ietf.org/archive/id/draft-ietf-opsawg-pcapng-02.html,
+// pcapgo.NgWriter can't write packet comments nor name resolution blocks
+const (
+	pcapNgSectionHeader       = 0x0a0d0d0a
+	pcapNgInterfaceDescriptor = 0x00000001
+	pcapNgNameResolution      = 0x00000004
+	pcapNgEnhancedPacket      = 0x00000006
+	pcapNgByteOrderMagic      = 0x1a2b3c4d
+
+	pcapNgOptEnd         = 0
+	pcapNgOptComment     = 1
+	pcapNgOptShbHardware = 2
+	pcapNgOptShbOS       = 3
+	pcapNgOptShbUserAppl = 4
+	pcapNgOptIfName      = 2
+	pcapNgOptIfDesc      = 3
+
+	pcapNgNrbEnd  = 0
+	pcapNgNrbIPv4 = 1
+	pcapNgNrbIPv6 = 2
+)
+
+var ErrNotPcapNg = errors.New("not a pcapng file")
+
+// IsPcapNg returns true if the file should be written as pcapng rather than
+// as classic pcap, according to its extension.
+func IsPcapNg(fileName string) bool {
+	return strings.ToLower(filepath.Ext(fileName)) == ".pcapng"
+}
+
+type PcapNgInterface struct {
+	Name        string
+	Description string
+	LinkType    layers.LinkType
+	SnapLen     uint32
+}
+
+type pcapNgOption struct {
+	code  uint16
+	value []byte
+}
+
+// PcapNgWriter writes pcapng files with interface descriptions, name
+// resolution records and per packet comments.
+type PcapNgWriter struct {
+	sync.Mutex
+	w          io.Writer
+	interfaces int
+}
+
+// NewPcapNgWriter starts a new section, the interfaces must be added before
+// writing any packet.
+func NewPcapNgWriter(w io.Writer) (*PcapNgWriter, error) {
+	writer := &PcapNgWriter{w: w}
+
+	body := bytes.Buffer{}
+	binary.Write(&body, binary.LittleEndian, uint32(pcapNgByteOrderMagic))
+	binary.Write(&body, binary.LittleEndian, uint16(1))
+	binary.Write(&body, binary.LittleEndian, uint16(0))
+	// unknown section length
+	binary.Write(&body, binary.LittleEndian, int64(-1))
+	writeNgOptions(&body, []pcapNgOption{
+		{pcapNgOptShbHardware, []byte(runtime.GOARCH)},
+		{pcapNgOptShbOS, []byte(runtime.GOOS)},
+		{pcapNgOptShbUserAppl, []byte(fmt.Sprintf("%s v%s", core.Name, core.Version))},
+	})
+
+	if err := writer.writeBlock(pcapNgSectionHeader, body.Bytes()); err != nil {
+		return nil, err
+	}
+	return writer, nil
+}
+
+// AppendPcapNgWriter continues the pcapng file of fp, which must have been
+// created by a writer whose first interface had the same link type. If the
+// file is empty a new section is started with the given interface.
+func AppendPcapNgWriter(fp *os.File, iface PcapNgInterface) (*PcapNgWriter, error) {
+	info, err := fp.Stat()
+	if err != nil {
+		return nil, err
+	} else if info.Size() == 0 {
+		writer, err := NewPcapNgWriter(fp)
+		if err != nil {
+			return nil, err
+		} else if _, err = writer.AddInterface(iface); err != nil {
+			return nil, err
+		}
+		return writer, nil
+	}
+
+	magic := make([]byte, 4)
+	if _, err = fp.ReadAt(magic, 0); err != nil {
+		return nil, err
+	} else if binary.LittleEndian.Uint32(magic) != pcapNgSectionHeader {
+		return nil, ErrNotPcapNg
+	}
+
+	return &PcapNgWriter{w: fp, interfaces: 1}, nil
+}
+
+func writeNgOptions(buf *bytes.Buffer, options []pcapNgOption) {
+	written := 0
+	for _, opt := range options {
+		if len(opt.value) == 0 {
+			continue
+		}
+		binary.Write(buf, binary.LittleEndian, opt.code)
+		binary.Write(buf, binary.LittleEndian, uint16(len(opt.value)))
+		buf.Write(opt.value)
+		pad32(buf)
+		written++
+	}
+	if written > 0 {
+		binary.Write(buf, binary.LittleEndian, uint16(pcapNgOptEnd))
+		binary.Write(buf, binary.LittleEndian, uint16(0))
+	}
+}
+
+func pad32(buf *bytes.Buffer) {
+	for buf.Len()%4 != 0 {
+		buf.WriteByte(0)
+	}
+}
+
+func (w *PcapNgWriter) writeBlock(blockType uint32, body []byte) error {
+	size := uint32(12 + len(body))
+	block := bytes.Buffer{}
+	binary.Write(&block, binary.LittleEndian, blockType)
+	binary.Write(&block, binary.LittleEndian, size)
+	block.Write(body)
+	binary.Write(&block, binary.LittleEndian, size)
+
+	w.Lock()
+	defer w.Unlock()
+	_, err := w.w.Write(block.Bytes())
+	return err
+}
+
+// AddInterface writes an interface description block and returns the id
+// to use when writing its packets.
+func (w *PcapNgWriter) AddInterface(iface PcapNgInterface) (int, error) {
+	snapLen := iface.SnapLen
+	if snapLen == 0 {
+		snapLen = PCAP_DEFAULT_SNAPLEN
+	}
+
+	body := bytes.Buffer{}
+	binary.Write(&body, binary.LittleEndian, uint16(iface.LinkType))
+	binary.Write(&body, binary.LittleEndian, uint16(0))
+	binary.Write(&body, binary.LittleEndian, snapLen)
+	writeNgOptions(&body, []pcapNgOption{
+		{pcapNgOptIfName, []byte(iface.Name)},
+		{pcapNgOptIfDesc, []byte(iface.Description)},
+	})
+
+	if err := w.writeBlock(pcapNgInterfaceDescriptor, body.Bytes()); err != nil {
+		return -1, err
+	}
+	w.interfaces++
+	return w.interfaces - 1, nil
+}
+
+// WriteNames writes a name resolution block with the names of each address.
+func (w *PcapNgWriter) WriteNames(names map[string][]string) error {
+	addresses := make([]string, 0, len(names))
+	for address := range names {
+		addresses = append(addresses, address)
+	}
+	sort.Strings(addresses)
+
+	body := bytes.Buffer{}
+	for _, address := range addresses {
+		ip := net.ParseIP(address)
+		if ip == nil || len(names[address]) == 0 {
+			continue
+		}
+
+		value := bytes.Buffer{}
+		recordType := uint16(pcapNgNrbIPv4)
+		if ip4 := ip.To4(); ip4 != nil {
+			value.Write(ip4)
+		} else {
+			recordType = pcapNgNrbIPv6
+			value.Write(ip.To16())
+		}
+		for _, name := range names[address] {
+			value.WriteString(name)
+			value.WriteByte(0)
+		}
+
+		binary.Write(&body, binary.LittleEndian, recordType)
+		binary.Write(&body, binary.LittleEndian, uint16(value.Len()))
+		body.Write(value.Bytes())
+		pad32(&body)
+	}
+
+	if body.Len() == 0 {
+		return nil
+	}
+
+	binary.Write(&body, binary.LittleEndian, uint16(pcapNgNrbEnd))
+	binary.Write(&body, binary.LittleEndian, uint16(0))
+
+	return w.writeBlock(pcapNgNameResolution, body.Bytes())
+}
+
+// WritePacket writes an enhanced packet block for the packet captured on the
+// given interface, with a comment for each of the comments.
+func (w *PcapNgWriter) WritePacket(iface int, ci gopacket.CaptureInfo, data []byte, comments ...string) error {
+	if iface < 0 || iface >= w.interfaces {
+		return fmt.Errorf("interface %d has not been added", iface)
+	}
+
+	// default resolution of microseconds
+	ts := uint64(ci.Timestamp.UnixNano() / 1000)
+	length := ci.Length
+	if length < len(data) {
+		length = len(data)
+	}
+
+	body := bytes.Buffer{}
+	binary.Write(&body, binary.LittleEndian, uint32(iface))
+	binary.Write(&body, binary.LittleEndian, uint32(ts>>32))
+	binary.Write(&body, binary.LittleEndian, uint32(ts))
+	binary.Write(&body, binary.LittleEndian, uint32(len(data)))
+	binary.Write(&body, binary.LittleEndian, uint32(length))
+	body.Write(data)
+	pad32(&body)
+
+	options := make([]pcapNgOption, 0, len(comments))
+	for _, comment := range comments {
+		options = append(options, pcapNgOption{pcapNgOptComment, []byte(comment)})
+	}
+	writeNgOptions(&body, options)
+
+	return w.writeBlock(pcapNgEnhancedPacket, body.Bytes())
+}
@@ -0,0 +1,134 @@
+package network
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gopacket/gopacket"
+	"github.com/gopacket/gopacket/layers"
+	"github.com/gopacket/gopacket/pcapgo"
+)
+
+func TestIsPcapNg(t *testing.T) {
+	for name, exp := range map[string]bool{
+		"capture.pcapng": true,
+		"CAPTURE.PCAPNG": true,
+		"capture.pcap":   false,
+		"capture":        false,
+	} {
+		if got := IsPcapNg(name); got != exp {
+			t.Fatalf("expected %v for %s, got %v", exp, name, got)
+		}
+	}
+}
+
+func TestPcapNgWriter(t *testing.T) {
+	buf := bytes.Buffer{}
+	writer, err := NewPcapNgWriter(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = writer.AddInterface(PcapNgInterface{Name: "wlan0mon", LinkType: layers.LinkTypeIEEE80211Radio}); err != nil {
+		t.Fatal(err)
+	} else if id, err := writer.AddInterface(PcapNgInterface{Name: "decrypted", LinkType: layers.LinkTypeEthernet}); err != nil || id != 1 {
+		t.Fatalf("unexpected interface %d: %v", id, err)
+	}
+
+	if err = writer.WriteNames(map[string][]string{
+		"192.168.1.10": {"laptop", "laptop.lan"},
+		"fe80::1":      {"router"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	when := time.Unix(1600000000, 123456000)
+	data := []byte{1, 2, 3, 4, 5}
+	ci := gopacket.CaptureInfo{Timestamp: when, CaptureLength: len(data), Length: len(data)}
+	if err = writer.WritePacket(1, ci, data, "NTLMv2 hash for user bob"); err != nil {
+		t.Fatal(err)
+	} else if err = writer.WritePacket(0, ci, data); err != nil {
+		t.Fatal(err)
+	} else if err = writer.WritePacket(2, ci, data); err == nil {
+		t.Fatal("expected an error for an unknown interface")
+	}
+
+	raw := buf.Bytes()
+	if len(raw)%4 != 0 {
+		t.Fatalf("blocks are not aligned to 32 bits")
+	} else if !bytes.Contains(raw, []byte("NTLMv2 hash for user bob")) {
+		t.Fatal("packet comment not found")
+	} else if !bytes.Contains(raw, []byte("laptop\x00laptop.lan\x00")) {
+		t.Fatal("name resolution record not found")
+	}
+
+	reader, err := pcapgo.NewNgReader(bytes.NewReader(raw), pcapgo.NgReaderOptions{WantMixedLinkType: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, linkType := range []layers.LinkType{layers.LinkTypeEthernet, layers.LinkTypeIEEE80211Radio} {
+		read, info, err := reader.ReadPacketData()
+		if err != nil {
+			t.Fatalf("packet %d: %v", i, err)
+		} else if !bytes.Equal(read, data) {
+			t.Fatalf("packet %d: unexpected data %x", i, read)
+		} else if !info.Timestamp.Equal(when) {
+			t.Fatalf("packet %d: unexpected timestamp %v", i, info.Timestamp)
+		} else if intf, err := reader.Interface(info.InterfaceIndex); err != nil || intf.LinkType != linkType {
+			t.Fatalf("packet %d: unexpected interface %+v", i, intf)
+		}
+	}
+}
+
+func TestAppendPcapNgWriter(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "handshakes.pcapng")
+	data := []byte{0xaa, 0xbb}
+	ci := gopacket.CaptureInfo{Timestamp: time.Now(), CaptureLength: len(data), Length: len(data)}
+
+	for i := 0; i < 2; i++ {
+		fp, err := os.OpenFile(fileName, os.O_APPEND|os.O_CREATE|os.O_RDWR, 0666)
+		if err != nil {
+			t.Fatal(err)
+		}
+		writer, err := AppendPcapNgWriter(fp, PcapNgInterface{Name: "wlan0", LinkType: layers.LinkTypeIEEE80211Radio})
+		if err != nil {
+			t.Fatal(err)
+		} else if err = writer.WritePacket(0, ci, data, "WPA handshake M1"); err != nil {
+			t.Fatal(err)
+		}
+		fp.Close()
+	}
+
+	fp, err := os.Open(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fp.Close()
+
+	reader, err := pcapgo.NewNgReader(fp, pcapgo.DefaultNgReaderOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, _, err := reader.ReadPacketData(); err != nil {
+			t.Fatalf("packet %d: %v", i, err)
+		}
+	}
+
+	classic := filepath.Join(t.TempDir(), "classic.pcapng")
+	if err = os.WriteFile(classic, []byte{0xd4, 0xc3, 0xb2, 0xa1}, 0644); err != nil {
+		t.Fatal(err)
+	}
+	cp, err := os.OpenFile(classic, os.O_APPEND|os.O_RDWR, 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cp.Close()
+	if _, err = AppendPcapNgWriter(cp, PcapNgInterface{LinkType: layers.LinkTypeEthernet}); err != ErrNotPcapNg {
+		t.Fatalf("expected ErrNotPcapNg, got %v", err)
+	}
+}
@@ -0,0 +1,381 @@
+package network
+
+import (
+	"encoding/binary"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ServiceInfo is what a service probe found out about a port.
+type ServiceInfo struct {
+	Service string `json:"service"`
+	Product string `json:"product"`
+	Version string `json:"version"`
+	Info    string `json:"info"`
+	CPE     string `json:"cpe"`
+}
+
+func (i ServiceInfo) String() string {
+	parts := []string{i.Service}
+	if i.Product != "" {
+		parts = append(parts, i.Product)
+	}
+	if i.Version != "" {
+		parts = append(parts, i.Version)
+	}
+	if i.Info != "" {
+		parts = append(parts, "("+i.Info+")")
+	}
+	return strings.Join(parts, " ")
+}
+
+// OverTLS returns the service as seen through a TLS tunnel.
+func (i ServiceInfo) OverTLS() ServiceInfo {
+	switch i.Service {
+	case "http":
+		i.Service = "https"
+	case "ssl":
+	default:
+		i.Service = "ssl/" + i.Service
+	}
+	return i
+}
+
+// ServiceMatch is a regular expression matching the response to a probe,
+// product, version, info and CPE can refer to its groups as ${N}.
+type ServiceMatch struct {
+	Service string
+	Pattern *regexp.Regexp
+	Product string
+	Version string
+	Info    string
+	CPE     string
+	// soft matches only identify the service, a later probe might find
+	// out more about it
+	Soft bool
+}
+
+// ServiceProbe is a payload to send to a port in order to identify the
+// service listening on it.
+type ServiceProbe struct {
+	Name    string
+	Payload []byte
+	// ports where the probe is always sent, regardless of its rarity
+	Ports []int
+	// from 1 (common) to 9 (rare)
+	Rarity  int
+	Matches []ServiceMatch
+}
+
+// the patterns are matched against the response decoded as latin1, so that
+// every \xNN in the expression matches exactly one byte
+func serviceMatch(service, pattern, product, version, info, cpe string) ServiceMatch {
+	return ServiceMatch{
+		Service: service,
+		Pattern: regexp.MustCompile(pattern),
+		Product: product,
+		Version: version,
+		Info:    info,
+		CPE:     cpe,
+	}
+}
+
+func serviceSoftMatch(service, pattern string) ServiceMatch {
+	m := serviceMatch(service, pattern, "", "", "", "")
+	m.Soft = true
+	return m
+}
+
+func latin1(data []byte) string {
+	runes := make([]rune, len(data))
+	for i, b := range data {
+		runes[i] = rune(b)
+	}
+	return string(runes)
+}
+
+func printable(s string) string {
+	clean := strings.Builder{}
+	for _, c := range s {
+		if c < 0x80 && strconv.IsPrint(c) {
+			clean.WriteRune(c)
+		}
+	}
+	return strings.TrimSpace(clean.String())
+}
+
+func (m *ServiceMatch) match(response string) *ServiceInfo {
+	groups := m.Pattern.FindStringSubmatchIndex(response)
+	if groups == nil {
+		return nil
+	}
+
+	expand := func(template string) string {
+		if template == "" {
+			return ""
+		}
+		return printable(string(m.Pattern.ExpandString(nil, template, response, groups)))
+	}
+
+	return &ServiceInfo{
+		Service: m.Service,
+		Product: expand(m.Product),
+		Version: expand(m.Version),
+		Info:    expand(m.Info),
+		CPE:     expand(m.CPE),
+	}
+}
+
+// Match returns what the response to this probe reveals about the service,
+// or nil if no signature matches it. The signatures of the NULL probe are
+// tried as a fallback, since many services greet the client first.
+func (p *ServiceProbe) Match(response []byte) (info *ServiceInfo, soft bool) {
+	if len(response) == 0 {
+		return nil, false
+	}
+
+	decoded := latin1(response)
+	matches := p.Matches
+	if p != serviceNullProbe {
+		matches = append(append([]ServiceMatch{}, matches...), serviceNullProbe.Matches...)
+	}
+
+	for i := range matches {
+		m := &matches[i]
+		if found := m.match(decoded); found != nil {
+			if !m.Soft {
+				return found, false
+			} else if info == nil {
+				info = found
+			}
+		}
+	}
+
+	return info, info != nil
+}
+
+func (p *ServiceProbe) isFor(port int) bool {
+	for _, p := range p.Ports {
+		if p == port {
+			return true
+		}
+	}
+	return false
+}
+
+// ServiceProbesFor returns the probes to send to a port, the NULL probe
+// first, followed by the ones for the port and then by the others not rarer
+// than the intensity.
+func ServiceProbesFor(port int, intensity int) []*ServiceProbe {
+	probes := []*ServiceProbe{serviceNullProbe}
+	for _, p := range ServiceProbes {
+		if p != serviceNullProbe && p.isFor(port) {
+			probes = append(probes, p)
+		}
+	}
+	for _, p := range ServiceProbes {
+		if p != serviceNullProbe && !p.isFor(port) && p.Rarity <= intensity {
+			probes = append(probes, p)
+		}
+	}
+	return probes
+}
+
+// ServiceProbeTLS returns the probe detecting TLS services.
+func ServiceProbeTLS() *ServiceProbe {
+	return serviceTLSProbe
+}
+
+// a TLS 1.2 ClientHello also offering TLS 1.3
+func serviceTLSHello() []byte {
+	suites := []uint16{0x1301, 0x1302, 0x1303, 0xc02b, 0xc02f, 0xc02c, 0xc030, 0xcca9, 0xcca8, 0xc013, 0xc014, 0x009c, 0x009d, 0x002f, 0x0035, 0x000a}
+	extensions := [][]byte{
+		// supported_groups: x25519, secp256r1, secp384r1
+		{0x00, 0x0a, 0x00, 0x08, 0x00, 0x06, 0x00, 0x1d, 0x00, 0x17, 0x00, 0x18},
+		// ec_point_formats: uncompressed
+		{0x00, 0x0b, 0x00, 0x02, 0x01, 0x00},
+		// signature_algorithms
+		{0x00, 0x0d, 0x00, 0x0e, 0x00, 0x0c, 0x04, 0x03, 0x08, 0x04, 0x04, 0x01, 0x05, 0x03, 0x08, 0x05, 0x05, 0x01},
+		// supported_versions: TLS 1.3, TLS 1.2
+		{0x00, 0x2b, 0x00, 0x05, 0x04, 0x03, 0x04, 0x03, 0x03},
+	}
+
+	body := []byte{0x03, 0x03}
+	for i := 0; i < 32; i++ {
+		body = append(body, byte(i))
+	}
+	// empty session id
+	body = append(body, 0x00)
+	body = binary.BigEndian.AppendUint16(body, uint16(len(suites)*2))
+	for _, s := range suites {
+		body = binary.BigEndian.AppendUint16(body, s)
+	}
+	// null compression
+	body = append(body, 0x01, 0x00)
+
+	exts := []byte{}
+	for _, e := range extensions {
+		exts = append(exts, e...)
+	}
+	body = binary.BigEndian.AppendUint16(body, uint16(len(exts)))
+	body = append(body, exts...)
+
+	handshake := []byte{0x01, byte(len(body) >> 16), byte(len(body) >> 8), byte(len(body))}
+	handshake = append(handshake, body...)
+
+	record := []byte{0x16, 0x03, 0x01}
+	record = binary.BigEndian.AppendUint16(record, uint16(len(handshake)))
+	return append(record, handshake...)
+}
+
+var (
+	serviceNullProbe = &ServiceProbe{
+		Name: "NULL",
+		Matches: []ServiceMatch{
+			serviceMatch("ssh", `^SSH-([\d.]+)-OpenSSH_([\w.]+)[ -]([^\r\n]+)\r?\n`, "OpenSSH", "${2}", "${3}; protocol ${1}", "cpe:/a:openbsd:openssh:${2}"),
+			serviceMatch("ssh", `^SSH-([\d.]+)-OpenSSH_([\w.]+)\r?\n`, "OpenSSH", "${2}", "protocol ${1}", "cpe:/a:openbsd:openssh:${2}"),
+			serviceMatch("ssh", `^SSH-([\d.]+)-dropbear_([\w.]+)\r?\n`, "Dropbear sshd", "${2}", "protocol ${1}", "cpe:/a:matt_johnston:dropbear_ssh_server:${2}"),
+			serviceMatch("ssh", `^SSH-([\d.]+)-Cisco-([\d.]+)\r?\n`, "Cisco SSH", "${2}", "protocol ${1}", "cpe:/o:cisco:ios"),
+			serviceMatch("ssh", `^SSH-([\d.]+)-libssh[_-]([\w.]+)\r?\n`, "libssh", "${2}", "protocol ${1}", "cpe:/a:libssh:libssh:${2}"),
+			serviceMatch("ssh", `^SSH-([\d.]+)-([^\r\n]+)\r?\n`, "${2}", "", "protocol ${1}", ""),
+			serviceMatch("ftp", `^220 \(vsFTPd ([\w.]+)\)`, "vsftpd", "${1}", "", "cpe:/a:vsftpd:vsftpd:${1}"),
+			serviceMatch("ftp", `^220 ProFTPD ([\w.]+) Server`, "ProFTPD", "${1}", "", "cpe:/a:proftpd:proftpd:${1}"),
+			serviceMatch("ftp", `^220 ProFTPD`, "ProFTPD", "", "", "cpe:/a:proftpd:proftpd"),
+			serviceMatch("ftp", `^220[ -]FileZilla Server(?: version)? ([\w.]+)`, "FileZilla ftpd", "${1}", "", "cpe:/a:filezilla-project:filezilla_server:${1}"),
+			serviceMatch("ftp", `^220[ -]Microsoft FTP Service`, "Microsoft ftpd", "", "", "cpe:/o:microsoft:windows"),
+			serviceMatch("ftp", `^220[ -]Pure-FTPd`, "Pure-FTPd", "", "", "cpe:/a:pureftpd:pure-ftpd"),
+			serviceSoftMatch("ftp", `(?i)^220[ -][^\r\n]*ftp`),
+			serviceMatch("smtp", `^220 ([\w.-]+) ESMTP Postfix`, "Postfix smtpd", "", "host ${1}", "cpe:/a:postfix:postfix"),
+			serviceMatch("smtp", `^220 ([\w.-]+) ESMTP Exim ([\w.]+)`, "Exim smtpd", "${2}", "host ${1}", "cpe:/a:exim:exim:${2}"),
+			serviceMatch("smtp", `^220 ([\w.-]+) Microsoft ESMTP MAIL Service`, "Microsoft ESMTP", "", "host ${1}", "cpe:/a:microsoft:exchange_server"),
+			serviceMatch("smtp", `^220 ([\w.-]+) ESMTP Sendmail ([\w.]+)`, "Sendmail", "${2}", "host ${1}", "cpe:/a:sendmail:sendmail:${2}"),
+			serviceSoftMatch("smtp", `^220[ -][^\r\n]*SMTP`),
+			serviceMatch("pop3", `^\+OK Dovecot`, "Dovecot pop3d", "", "", "cpe:/a:dovecot:dovecot"),
+			serviceSoftMatch("pop3", `^\+OK[ \r\n]`),
+			serviceMatch("imap", `^\* OK (?:\[[^\]]+\] )?Dovecot`, "Dovecot imapd", "", "", "cpe:/a:dovecot:dovecot"),
+			serviceMatch("imap", `^\* OK (?:\[[^\]]+\] )?The Microsoft Exchange IMAP4 service`, "Microsoft Exchange imapd", "", "", "cpe:/a:microsoft:exchange_server"),
+			serviceSoftMatch("imap", `^\* OK[ \[]`),
+			serviceMatch("mysql", `(?s)^.\x00\x00\x00\x0a([\d.]+)-([\d.]+)-MariaDB`, "MariaDB", "${2}", "", "cpe:/a:mariadb:mariadb:${2}"),
+			serviceMatch("mysql", `(?s)^.\x00\x00\x00\x0a([\d.]+)-MariaDB`, "MariaDB", "${1}", "", "cpe:/a:mariadb:mariadb:${1}"),
+			serviceMatch("mysql", `(?s)^.\x00\x00\x00\x0a(\d+\.\d+\.\d+)[\w.-]*\x00`, "MySQL", "${1}", "", "cpe:/a:mysql:mysql:${1}"),
+			serviceMatch("mysql", `(?s)^.\x00\x00\x00\xffj\x04Host '[^']+' is not allowed`, "MySQL", "", "unauthorized", "cpe:/a:mysql:mysql"),
+			serviceMatch("vnc", `^RFB (\d{3})\.(\d{3})\n`, "VNC", "", "protocol ${1}.${2}", ""),
+			serviceMatch("telnet", `^\xff[\xfb-\xfe]`, "", "", "", ""),
+			serviceMatch("rtsp", `^RTSP/1\.0 `, "", "", "", ""),
+		},
+	}
+
+	serviceTLSProbe = &ServiceProbe{
+		Name:    "TLSSessionReq",
+		Payload: serviceTLSHello(),
+		Ports:   []int{443, 465, 636, 853, 990, 992, 993, 994, 995, 2376, 4443, 5061, 5986, 6443, 8443, 8883, 9443},
+		Rarity:  1,
+		Matches: []ServiceMatch{
+			serviceMatch("ssl", `(?s)^\x16\x03[\x00-\x04]..\x02`, "", "", "", ""),
+			serviceMatch("ssl", `^\x15\x03[\x00-\x04]\x00\x02`, "", "", "", ""),
+		},
+	}
+
+	// ServiceProbes is the probes database, matches are ordered from the
+	// most to the least specific.
+	ServiceProbes = []*ServiceProbe{
+		serviceNullProbe,
+		serviceTLSProbe,
+		{
+			Name:    "GetRequest",
+			Payload: []byte("GET / HTTP/1.0\r\n\r\n"),
+			Ports:   []int{80, 81, 443, 591, 2375, 3000, 5000, 5985, 7080, 8000, 8008, 8080, 8081, 8088, 8443, 8888, 9000, 9090, 9200, 9443},
+			Rarity:  1,
+			Matches: []ServiceMatch{
+				serviceMatch("http", `(?is)^HTTP/1\.[01] \d\d\d.*?\r\nServer: nginx(?:/([\d.]+))?`, "nginx", "${1}", "", "cpe:/a:igor_sysoev:nginx:${1}"),
+				serviceMatch("http", `(?is)^HTTP/1\.[01] \d\d\d.*?\r\nServer: Apache(?:/([\d.]+))?(?: \(([^)\r\n]+)\))?`, "Apache httpd", "${1}", "${2}", "cpe:/a:apache:http_server:${1}"),
+				serviceMatch("http", `(?is)^HTTP/1\.[01] \d\d\d.*?\r\nServer: Microsoft-IIS/([\d.]+)`, "Microsoft IIS httpd", "${1}", "", "cpe:/a:microsoft:internet_information_services:${1}"),
+				serviceMatch("http", `(?is)^HTTP/1\.[01] \d\d\d.*?\r\nServer: Microsoft-HTTPAPI/([\d.]+)`, "Microsoft HTTPAPI httpd", "${1}", "SSDP/UPnP", "cpe:/o:microsoft:windows"),
+				serviceMatch("http", `(?is)^HTTP/1\.[01] \d\d\d.*?\r\nServer: lighttpd(?:/([\d.]+))?`, "lighttpd", "${1}", "", "cpe:/a:lighttpd:lighttpd:${1}"),
+				serviceMatch("http", `(?is)^HTTP/1\.[01] \d\d\d.*?\r\nServer: Jetty\(([\w.-]+)\)`, "Jetty", "${1}", "", "cpe:/a:eclipse:jetty:${1}"),
+				serviceMatch("http", `(?is)^HTTP/1\.[01] \d\d\d.*?\r\nServer: Caddy`, "Caddy httpd", "", "", "cpe:/a:caddyserver:caddy"),
+				serviceMatch("http", `(?is)^HTTP/1\.[01] \d\d\d.*?\r\nServer: Werkzeug/([\d.]+) Python/([\d.]+)`, "Werkzeug httpd", "${1}", "Python ${2}", "cpe:/a:palletsprojects:werkzeug:${1}"),
+				serviceMatch("http", `(?is)^HTTP/1\.[01] \d\d\d.*?\r\nServer: ([^\r\n/]+)/([\w.-]+)`, "${1}", "${2}", "", ""),
+				serviceMatch("http", `(?is)^HTTP/1\.[01] \d\d\d.*?\r\nServer: ([^\r\n]+)`, "${1}", "", "", ""),
+				serviceMatch("http", `^HTTP/1\.[01] \d\d\d`, "", "", "", ""),
+				serviceMatch("redis", `^-ERR wrong number of arguments for 'get' command`, "Redis key-value store", "", "", "cpe:/a:redislabs:redis"),
+			},
+		},
+		{
+			Name: "SMBProgNeg",
+			// SMB1 negotiate request offering NT LM 0.12, SMB 2.002 and SMB 2.???
+			Payload: []byte("\x00\x00\x00\x45\xffSMB\x72\x00\x00\x00\x00\x18\x01\x48\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\xff\xff\x00\x00\x00\x00" +
+				"\x00\x22\x00\x02NT LM 0.12\x00\x02SMB 2.002\x00\x02SMB 2.???\x00"),
+			Ports:  []int{139, 445},
+			Rarity: 4,
+			Matches: []ServiceMatch{
+				serviceMatch("microsoft-ds", `(?s)^\x00...\xfeSMB.{60}A\x00.\x00\xff\x02`, "SMB", "", "SMBv2+", ""),
+				serviceMatch("microsoft-ds", `(?s)^\x00...\xfeSMB.{60}A\x00.\x00\x02\x02`, "SMB", "2.0.2", "", ""),
+				serviceMatch("microsoft-ds", `(?s)^\x00...\xfeSMB`, "SMB", "", "SMBv2+", ""),
+				serviceMatch("microsoft-ds", `(?s)^\x00...\xffSMBr\x00\x00\x00\x00`, "SMB", "1", "SMBv1", ""),
+				serviceMatch("netbios-ssn", `^\x83\x00\x00\x01\x8f`, "", "", "", ""),
+			},
+		},
+		{
+			Name: "TerminalServerCookie",
+			// X.224 connection request asking for TLS and CredSSP
+			Payload: []byte("\x03\x00\x00\x13\x0e\xe0\x00\x00\x00\x00\x00\x01\x00\x08\x00\x03\x00\x00\x00"),
+			Ports:   []int{3389},
+			Rarity:  6,
+			Matches: []ServiceMatch{
+				serviceMatch("ms-wbt-server", `(?s)^\x03\x00\x00\x13\x0e\xd0\x00\x00.{3}\x02.\x08\x00\x02\x00\x00\x00`, "Microsoft Terminal Services", "", "CredSSP", "cpe:/o:microsoft:windows"),
+				serviceMatch("ms-wbt-server", `(?s)^\x03\x00\x00\x13\x0e\xd0\x00\x00.{3}\x02.\x08\x00\x01\x00\x00\x00`, "Microsoft Terminal Services", "", "TLS", "cpe:/o:microsoft:windows"),
+				serviceMatch("ms-wbt-server", `(?s)^\x03\x00\x00\x13\x0e\xd0\x00\x00.{3}\x03`, "Microsoft Terminal Services", "", "negotiation failure", "cpe:/o:microsoft:windows"),
+				serviceMatch("ms-wbt-server", `^\x03\x00\x00[\x0b\x13][\x06\x0e]\xd0`, "", "", "", ""),
+			},
+		},
+		{
+			Name:    "RedisInfo",
+			Payload: []byte("*1\r\n$4\r\nINFO\r\n"),
+			Ports:   []int{6379, 6380},
+			Rarity:  5,
+			Matches: []ServiceMatch{
+				serviceMatch("redis", `(?s)^\$\d+\r\n# Server\r\nredis_version:([\d.]+)\r\n.*?redis_mode:(\w+)`, "Redis key-value store", "${1}", "${2}", "cpe:/a:redislabs:redis:${1}"),
+				serviceMatch("redis", `(?s)^\$\d+\r\n# Server\r\nredis_version:([\d.]+)`, "Redis key-value store", "${1}", "", "cpe:/a:redislabs:redis:${1}"),
+				serviceMatch("redis", `^-NOAUTH `, "Redis key-value store", "", "authentication required", "cpe:/a:redislabs:redis"),
+				serviceMatch("redis", `^-DENIED Redis is running in protected mode`, "Redis key-value store", "", "protected mode", "cpe:/a:redislabs:redis"),
+			},
+		},
+		{
+			Name: "MQTTConnect",
+			// MQTT 3.1.1 CONNECT with a clean session and no credentials
+			Payload: []byte("\x10\x10\x00\x04MQTT\x04\x02\x00\x3c\x00\x04bcap"),
+			Ports:   []int{1883, 8883},
+			Rarity:  5,
+			Matches: []ServiceMatch{
+				serviceMatch("mqtt", `^\x20\x02[\x00\x01]\x00`, "", "", "anonymous access", ""),
+				serviceMatch("mqtt", `^\x20\x02[\x00\x01][\x04\x05]`, "", "", "authentication required", ""),
+				serviceMatch("mqtt", `^\x20\x02[\x00\x01][\x01-\x03]`, "", "", "", ""),
+			},
+		},
+		{
+			Name: "DNSVersionBindReqTCP",
+			// CHAOS TXT query for version.bind
+			Payload: []byte("\x00\x1e\x00\x06\x01\x00\x00\x01\x00\x00\x00\x00\x00\x00\x07version\x04bind\x00\x00\x10\x00\x03"),
+			Ports:   []int{53, 5353},
+			Rarity:  1,
+			Matches: []ServiceMatch{
+				serviceMatch("domain", `(?s)^..\x00\x06[\x81\x85].*\xc0\x0c\x00\x10\x00\x03.{6}.dnsmasq-([\w.]+)`, "dnsmasq", "${1}", "", "cpe:/a:thekelleys:dnsmasq:${1}"),
+				serviceMatch("domain", `(?s)^..\x00\x06[\x81\x85].*\xc0\x0c\x00\x10\x00\x03.{6}.unbound ([\w.]+)`, "Unbound", "${1}", "", "cpe:/a:nlnetlabs:unbound:${1}"),
+				serviceMatch("domain", `(?s)^..\x00\x06[\x81\x85].*\xc0\x0c\x00\x10\x00\x03.{6}.PowerDNS Recursor ([\w.]+)`, "PowerDNS Recursor", "${1}", "", "cpe:/a:powerdns:recursor:${1}"),
+				serviceMatch("domain", `(?s)^..\x00\x06[\x81\x85].*\xc0\x0c\x00\x10\x00\x03.{6}.(\d+\.\d+\.\d+[\w.-]*)`, "ISC BIND", "${1}", "", "cpe:/a:isc:bind:${1}"),
+				serviceMatch("domain", `(?s)^..\x00\x06[\x80-\x87]`, "", "", "", ""),
+			},
+		},
+		{
+			Name:    "GenericLines",
+			Payload: []byte("\r\n\r\n"),
+			Rarity:  1,
+			Matches: []ServiceMatch{
+				serviceMatch("redis", `^-ERR unknown command`, "Redis key-value store", "", "", "cpe:/a:redislabs:redis"),
+				serviceMatch("http", `^HTTP/1\.[01] 400`, "", "", "", ""),
+			},
+		},
+	}
+)
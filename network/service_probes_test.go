@@ -0,0 +1,199 @@
+package network
+
+import (
+	"testing"
+)
+
+func serviceProbeByName(t *testing.T, name string) *ServiceProbe {
+	for _, p := range ServiceProbes {
+		if p.Name == name {
+			return p
+		}
+	}
+	t.Fatalf("probe %s not found", name)
+	return nil
+}
+
+func TestServiceProbesMatch(t *testing.T) {
+	var units = []struct {
+		probe    string
+		response string
+		exp      ServiceInfo
+	}{
+		{
+			"NULL",
+			"SSH-2.0-OpenSSH_8.9p1 Ubuntu-3ubuntu0.4\r\n",
+			ServiceInfo{"ssh", "OpenSSH", "8.9p1", "Ubuntu-3ubuntu0.4; protocol 2.0", "cpe:/a:openbsd:openssh:8.9p1"},
+		},
+		{
+			"NULL",
+			"SSH-2.0-dropbear_2022.83\r\n",
+			ServiceInfo{"ssh", "Dropbear sshd", "2022.83", "protocol 2.0", "cpe:/a:matt_johnston:dropbear_ssh_server:2022.83"},
+		},
+		{
+			"NULL",
+			"220 (vsFTPd 3.0.3)\r\n",
+			ServiceInfo{"ftp", "vsftpd", "3.0.3", "", "cpe:/a:vsftpd:vsftpd:3.0.3"},
+		},
+		{
+			"NULL",
+			"J\x00\x00\x00\x0a8.0.35-0ubuntu0.22.04.1\x00\x08\x00\x00\x00",
+			ServiceInfo{"mysql", "MySQL", "8.0.35", "", "cpe:/a:mysql:mysql:8.0.35"},
+		},
+		{
+			"NULL",
+			"Y\x00\x00\x00\x0a5.5.5-10.6.12-MariaDB-0ubuntu0.22.04.1\x00",
+			ServiceInfo{"mysql", "MariaDB", "10.6.12", "", "cpe:/a:mariadb:mariadb:10.6.12"},
+		},
+		{
+			"NULL",
+			"RFB 003.008\n",
+			ServiceInfo{"vnc", "VNC", "", "protocol 003.008", ""},
+		},
+		{
+			"GetRequest",
+			"HTTP/1.1 200 OK\r\nDate: Mon, 01 Jan 2024 00:00:00 GMT\r\nServer: nginx/1.24.0\r\n\r\n<html></html>",
+			ServiceInfo{"http", "nginx", "1.24.0", "", "cpe:/a:igor_sysoev:nginx:1.24.0"},
+		},
+		{
+			"GetRequest",
+			"HTTP/1.1 403 Forbidden\r\nServer: Apache/2.4.57 (Debian)\r\n\r\n",
+			ServiceInfo{"http", "Apache httpd", "2.4.57", "Debian", "cpe:/a:apache:http_server:2.4.57"},
+		},
+		{
+			"GetRequest",
+			"HTTP/1.0 200 OK\r\nContent-Type: text/html\r\n\r\n",
+			ServiceInfo{"http", "", "", "", ""},
+		},
+		{
+			"RedisInfo",
+			"$3500\r\n# Server\r\nredis_version:7.2.3\r\nredis_git_sha1:00000000\r\nredis_mode:standalone\r\n",
+			ServiceInfo{"redis", "Redis key-value store", "7.2.3", "standalone", "cpe:/a:redislabs:redis:7.2.3"},
+		},
+		{
+			"RedisInfo",
+			"-NOAUTH Authentication required.\r\n",
+			ServiceInfo{"redis", "Redis key-value store", "", "authentication required", "cpe:/a:redislabs:redis"},
+		},
+		{
+			"MQTTConnect",
+			"\x20\x02\x00\x00",
+			ServiceInfo{"mqtt", "", "", "anonymous access", ""},
+		},
+		{
+			"MQTTConnect",
+			"\x20\x02\x00\x05",
+			ServiceInfo{"mqtt", "", "", "authentication required", ""},
+		},
+		{
+			"TerminalServerCookie",
+			"\x03\x00\x00\x13\x0e\xd0\x00\x00\x12\x34\x00\x02\x1f\x08\x00\x02\x00\x00\x00",
+			ServiceInfo{"ms-wbt-server", "Microsoft Terminal Services", "", "CredSSP", "cpe:/o:microsoft:windows"},
+		},
+		{
+			"SMBProgNeg",
+			"\x00\x00\x00\xc0\xfeSMB\x40\x00\x00\x00\x00\x00\x00\x00\x00\x00\x01\x00\x01\x00\x00\x00\x00\x00\x00\x00" +
+				"\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00" +
+				"\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00" +
+				"\x41\x00\x01\x00\xff\x02\x00\x00",
+			ServiceInfo{"microsoft-ds", "SMB", "", "SMBv2+", ""},
+		},
+		{
+			"TLSSessionReq",
+			"\x16\x03\x03\x00\x5a\x02\x00\x00\x56\x03\x03",
+			ServiceInfo{"ssl", "", "", "", ""},
+		},
+		{
+			"DNSVersionBindReqTCP",
+			"\x00\x3c\x00\x06\x85\x80\x00\x01\x00\x01\x00\x00\x00\x00\x07version\x04bind\x00\x00\x10\x00\x03" +
+				"\xc0\x0c\x00\x10\x00\x03\x00\x00\x00\x00\x00\x0d\x0cdnsmasq-2.90",
+			ServiceInfo{"domain", "dnsmasq", "2.90", "", "cpe:/a:thekelleys:dnsmasq:2.90"},
+		},
+		{
+			"DNSVersionBindReqTCP",
+			"\x00\x3a\x00\x06\x85\x80\x00\x01\x00\x01\x00\x00\x00\x00\x07version\x04bind\x00\x00\x10\x00\x03" +
+				"\xc0\x0c\x00\x10\x00\x03\x00\x00\x00\x00\x00\x0b\x0a9.18.19-1",
+			ServiceInfo{"domain", "ISC BIND", "9.18.19-1", "", "cpe:/a:isc:bind:9.18.19-1"},
+		},
+	}
+
+	for _, u := range units {
+		got, soft := serviceProbeByName(t, u.probe).Match([]byte(u.response))
+		if got == nil || soft {
+			t.Fatalf("%s: no hard match for %q", u.probe, u.response)
+		} else if *got != u.exp {
+			t.Fatalf("%s: expected %+v, got %+v", u.probe, u.exp, *got)
+		}
+	}
+}
+
+func TestServiceProbesSoftMatch(t *testing.T) {
+	got, soft := serviceNullProbe.Match([]byte("220 mail.example.com ESMTP ready\r\n"))
+	if got == nil || !soft || got.Service != "smtp" {
+		t.Fatalf("unexpected match %+v (soft=%v)", got, soft)
+	}
+
+	// the NULL probe signatures are a fallback for the other probes
+	got, soft = serviceProbeByName(t, "GetRequest").Match([]byte("SSH-2.0-OpenSSH_9.6\r\n"))
+	if got == nil || soft || got.Product != "OpenSSH" || got.Version != "9.6" {
+		t.Fatalf("unexpected match %+v (soft=%v)", got, soft)
+	}
+
+	if got, _ = serviceNullProbe.Match([]byte("\x00\x01\x02")); got != nil {
+		t.Fatalf("unexpected match %+v", got)
+	}
+	if got, _ = serviceNullProbe.Match(nil); got != nil {
+		t.Fatalf("unexpected match %+v", got)
+	}
+}
+
+func TestServiceProbesFor(t *testing.T) {
+	probes := ServiceProbesFor(6379, 1)
+	if probes[0].Name != "NULL" || probes[1].Name != "RedisInfo" {
+		t.Fatalf("unexpected probes order %s, %s", probes[0].Name, probes[1].Name)
+	}
+	for _, p := range probes[2:] {
+		if p.Rarity > 1 {
+			t.Fatalf("probe %s is rarer than the intensity", p.Name)
+		}
+	}
+
+	if all := ServiceProbesFor(1, 9); len(all) != len(ServiceProbes) {
+		t.Fatalf("expected %d probes, got %d", len(ServiceProbes), len(all))
+	}
+
+	if only := ServiceProbesFor(1, 0); len(only) != 1 {
+		t.Fatalf("expected only the NULL probe, got %d", len(only))
+	}
+}
+
+func TestServiceInfoOverTLS(t *testing.T) {
+	var units = []struct {
+		service string
+		exp     string
+	}{
+		{"http", "https"},
+		{"ssl", "ssl"},
+		{"smtp", "ssl/smtp"},
+	}
+	for _, u := range units {
+		if got := (ServiceInfo{Service: u.service}).OverTLS().Service; got != u.exp {
+			t.Fatalf("expected '%s', got '%s'", u.exp, got)
+		}
+	}
+
+	info := ServiceInfo{Service: "ssh", Product: "OpenSSH", Version: "9.6", Info: "protocol 2.0"}
+	if s := info.String(); s != "ssh OpenSSH 9.6 (protocol 2.0)" {
+		t.Fatalf("unexpected string '%s'", s)
+	}
+}
+
+func TestServiceTLSHello(t *testing.T) {
+	hello := serviceTLSHello()
+	if hello[0] != 0x16 || int(hello[3])<<8|int(hello[4]) != len(hello)-5 {
+		t.Fatalf("invalid record header %x", hello[:5])
+	}
+	if hello[5] != 0x01 || int(hello[6])<<16|int(hello[7])<<8|int(hello[8]) != len(hello)-9 {
+		t.Fatalf("invalid handshake header %x", hello[5:9])
+	}
+}
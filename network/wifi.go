@@ -2,6 +2,7 @@ package network
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
 	"strconv"
@@ -54,6 +55,7 @@ type WiFi struct {
 	iface   *Endpoint
 	newCb   APNewCallback
 	lostCb  APLostCallback
+	locator GeoLocator
 }
 
 type wifiJSON struct {
@@ -70,6 +72,27 @@ func NewWiFi(iface *Endpoint, aliases *data.UnsortedKV, newcb APNewCallback, los
 	}
 }
 
+// SetLocator sets the callback used to geotag access points and clients.
+func (w *WiFi) SetLocator(locator GeoLocator) {
+	w.Lock()
+	defer w.Unlock()
+	w.locator = locator
+}
+
+// Locate returns the current position, if a locator is set and has a valid fix.
+func (w *WiFi) Locate() (GeoFix, bool) {
+	w.RLock()
+	defer w.RUnlock()
+	return w.locate()
+}
+
+func (w *WiFi) locate() (GeoFix, bool) {
+	if w.locator == nil {
+		return GeoFix{}, false
+	}
+	return w.locator()
+}
+
 func (w *WiFi) MarshalJSON() ([]byte, error) {
 
 	doc := wifiJSON{
@@ -160,6 +183,9 @@ func (w *WiFi) AddIfNew(ssid, mac string, frequency int, rssi int8) (*AccessPoin
 		if alias != "" {
 			ap.Alias = alias
 		}
+		if fix, ok := w.locate(); ok && rssi != 0 {
+			ap.Geo.Add(fix, rssi)
+		}
 		return ap, false
 	}
 
@@ -167,6 +193,10 @@ func (w *WiFi) AddIfNew(ssid, mac string, frequency int, rssi int8) (*AccessPoin
 	newAp.Alias = alias
 	w.aps[mac] = newAp
 
+	if fix, ok := w.locate(); ok && rssi != 0 {
+		newAp.Geo.Add(fix, rssi)
+	}
+
 	if w.newCb != nil {
 		w.newCb(newAp)
 	}
@@ -219,6 +249,41 @@ func (w *WiFi) NumHandshakes() int {
 	return sum
 }
 
+// handshakeWriter returns a function writing the handshake frames to fp,
+// as pcapng with a comment for each frame if the file name says so.
+func (w *WiFi) handshakeWriter(fp *os.File, doHead bool, linkType layers.LinkType) (func(gopacket.Packet, string) error, error) {
+	if IsPcapNg(fp.Name()) {
+		name := ""
+		if w.iface != nil {
+			name = w.iface.Name()
+		}
+		writer, err := AppendPcapNgWriter(fp, PcapNgInterface{
+			Name:        name,
+			Description: "wifi handshakes",
+			LinkType:    linkType,
+		})
+		if err != nil {
+			return nil, err
+		}
+		return func(pkt gopacket.Packet, comment string) error {
+			if comment != "" {
+				return writer.WritePacket(0, pkt.Metadata().CaptureInfo, pkt.Data(), comment)
+			}
+			return writer.WritePacket(0, pkt.Metadata().CaptureInfo, pkt.Data())
+		}, nil
+	}
+
+	writer := pcapgo.NewWriter(fp)
+	if doHead {
+		if err := writer.WriteFileHeader(65536, linkType); err != nil {
+			return nil, err
+		}
+	}
+	return func(pkt gopacket.Packet, comment string) error {
+		return writer.WritePacket(pkt.Metadata().CaptureInfo, pkt.Data())
+	}, nil
+}
+
 func (w *WiFi) SaveHandshakesTo(fileName string, linkType layers.LinkType) error {
 	// check if folder exists first
 	dirName := filepath.Dir(fileName)
@@ -235,12 +300,9 @@ func (w *WiFi) SaveHandshakesTo(fileName string, linkType layers.LinkType) error
 	}
 	defer fp.Close()
 
-	writer := pcapgo.NewWriter(fp)
-
-	if doHead {
-		if err = writer.WriteFileHeader(65536, linkType); err != nil {
-			return err
-		}
+	write, err := w.handshakeWriter(fp, doHead, linkType)
+	if err != nil {
+		return err
 	}
 
 	w.RLock()
@@ -251,9 +313,13 @@ func (w *WiFi) SaveHandshakesTo(fileName string, linkType layers.LinkType) error
 			// if half (which includes also complete) or has pmkid
 			if station.Handshake.Any() {
 				err = nil
-				station.Handshake.EachUnsavedPacket(func(pkt gopacket.Packet) {
+				station.Handshake.EachUnsavedFrame(func(pkt gopacket.Packet, frame string) {
 					if err == nil {
-						err = writer.WritePacket(pkt.Metadata().CaptureInfo, pkt.Data())
+						comment := ""
+						if frame != "" {
+							comment = fmt.Sprintf("WPA handshake %s of %s (%s) with %s", frame, ap.ESSID(), ap.BSSID(), station.BSSID())
+						}
+						err = write(pkt, comment)
 					}
 				})
 				if err != nil {
@@ -0,0 +1,239 @@
+package network
+
+import (
+	"encoding/json"
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	// maximum number of sightings kept for each station
+	geoMaxSamples = 512
+	// sightings closer than this (in meters) to the previous one are merged
+	geoMinDistance = 5.0
+	// log-distance path loss model used to turn RSSI into a distance
+	geoRefRSSI      = -40.0
+	geoPathLossExp  = 2.7
+	geoEarthRadius  = 6371000.0
+	geoSolverRounds = 32
+)
+
+// GeoFix is a position as reported by the GPS.
+type GeoFix struct {
+	Latitude  float64
+	Longitude float64
+	Altitude  float64
+	Time      time.Time
+}
+
+// GeoLocator returns the current position, if a valid fix is available.
+type GeoLocator func() (GeoFix, bool)
+
+// GeoSample is a station sighting at a given position.
+type GeoSample struct {
+	Latitude  float64   `json:"latitude"`
+	Longitude float64   `json:"longitude"`
+	Altitude  float64   `json:"altitude"`
+	RSSI      int8      `json:"rssi"`
+	Time      time.Time `json:"time"`
+}
+
+// GeoEstimate is the estimated position of a station.
+type GeoEstimate struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	// rough accuracy of the estimate in meters
+	Accuracy float64 `json:"accuracy"`
+}
+
+// GeoTrack keeps the positions where a station has been seen.
+type GeoTrack struct {
+	sync.RWMutex
+	last    *GeoSample
+	best    *GeoSample
+	samples []GeoSample
+}
+
+type geoTrackJSON struct {
+	Last     *GeoSample   `json:"last"`
+	Best     *GeoSample   `json:"best"`
+	Estimate *GeoEstimate `json:"estimate"`
+	Samples  int          `json:"samples"`
+}
+
+func NewGeoTrack() *GeoTrack {
+	return &GeoTrack{
+		samples: make([]GeoSample, 0),
+	}
+}
+
+func (t *GeoTrack) MarshalJSON() ([]byte, error) {
+	estimate, _ := t.Estimate()
+
+	t.RLock()
+	defer t.RUnlock()
+
+	return json.Marshal(geoTrackJSON{
+		Last:     t.last,
+		Best:     t.best,
+		Estimate: estimate,
+		Samples:  len(t.samples),
+	})
+}
+
+// GeoDistance returns the distance in meters between two coordinates.
+func GeoDistance(lat1, lon1, lat2, lon2 float64) float64 {
+	rad := math.Pi / 180.0
+	dLat := (lat2 - lat1) * rad
+	dLon := (lon2 - lon1) * rad
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1*rad)*math.Cos(lat2*rad)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * geoEarthRadius * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
+}
+
+// Add records a sighting with the given signal strength at the given position.
+func (t *GeoTrack) Add(fix GeoFix, rssi int8) {
+	t.Lock()
+	defer t.Unlock()
+
+	sample := GeoSample{
+		Latitude:  fix.Latitude,
+		Longitude: fix.Longitude,
+		Altitude:  fix.Altitude,
+		RSSI:      rssi,
+		Time:      fix.Time,
+	}
+
+	t.last = &sample
+	if t.best == nil || rssi > t.best.RSSI {
+		best := sample
+		t.best = &best
+	}
+
+	// merge with the previous sighting if we didn't move, keeping the best signal
+	if n := len(t.samples); n > 0 {
+		prev := &t.samples[n-1]
+		if GeoDistance(prev.Latitude, prev.Longitude, sample.Latitude, sample.Longitude) < geoMinDistance {
+			if rssi > prev.RSSI {
+				*prev = sample
+			}
+			return
+		}
+	}
+
+	if len(t.samples) >= geoMaxSamples {
+		t.samples = t.samples[1:]
+	}
+	t.samples = append(t.samples, sample)
+}
+
+func (t *GeoTrack) Last() *GeoSample {
+	t.RLock()
+	defer t.RUnlock()
+	return t.last
+}
+
+func (t *GeoTrack) Best() *GeoSample {
+	t.RLock()
+	defer t.RUnlock()
+	return t.best
+}
+
+func (t *GeoTrack) Samples() []GeoSample {
+	t.RLock()
+	defer t.RUnlock()
+	return append([]GeoSample{}, t.samples...)
+}
+
+func geoRSSIToDistance(rssi int8) float64 {
+	return math.Pow(10, (geoRefRSSI-float64(rssi))/(10*geoPathLossExp))
+}
+
+// Estimate computes the station position from its sightings, using the signal
+// weighted centroid as a starting point for a least squares trilateration when
+// at least three distinct positions are available.
+func (t *GeoTrack) Estimate() (*GeoEstimate, bool) {
+	samples := t.Samples()
+	if len(samples) == 0 {
+		return nil, false
+	}
+
+	// work on a local plane in meters around the first sample
+	lat0, lon0 := samples[0].Latitude, samples[0].Longitude
+	rad := math.Pi / 180.0
+	kx := geoEarthRadius * math.Cos(lat0*rad) * rad
+	ky := geoEarthRadius * rad
+
+	n := len(samples)
+	xs := make([]float64, n)
+	ys := make([]float64, n)
+	ds := make([]float64, n)
+	ws := make([]float64, n)
+
+	cx, cy, wsum := 0.0, 0.0, 0.0
+	for i, s := range samples {
+		xs[i] = (s.Longitude - lon0) * kx
+		ys[i] = (s.Latitude - lat0) * ky
+		ds[i] = geoRSSIToDistance(s.RSSI)
+		// linear power, stronger signals weight more
+		ws[i] = math.Pow(10, float64(s.RSSI)/10)
+		cx += xs[i] * ws[i]
+		cy += ys[i] * ws[i]
+		wsum += ws[i]
+	}
+	cx /= wsum
+	cy /= wsum
+
+	x, y := cx, cy
+	if n >= 3 {
+		// Gauss-Newton iterations on sum(w * (|p - p_i| - d_i)^2)
+		for round := 0; round < geoSolverRounds; round++ {
+			var a11, a12, a22, b1, b2 float64
+			for i := 0; i < n; i++ {
+				dx, dy := x-xs[i], y-ys[i]
+				r := math.Hypot(dx, dy)
+				if r < 1e-6 {
+					continue
+				}
+				jx, jy := dx/r, dy/r
+				res := r - ds[i]
+				w := 1.0 / (ds[i] * ds[i])
+				a11 += w * jx * jx
+				a12 += w * jx * jy
+				a22 += w * jy * jy
+				b1 += w * jx * res
+				b2 += w * jy * res
+			}
+
+			det := a11*a22 - a12*a12
+			if math.Abs(det) < 1e-12 {
+				break
+			}
+			sx := (a22*b1 - a12*b2) / det
+			sy := (a11*b2 - a12*b1) / det
+			x -= sx
+			y -= sy
+			if math.Hypot(sx, sy) < 0.01 {
+				break
+			}
+		}
+
+		if math.IsNaN(x) || math.IsNaN(y) || math.IsInf(x, 0) || math.IsInf(y, 0) {
+			x, y = cx, cy
+		}
+	}
+
+	// accuracy as the weighted RMS error between the model and the estimate
+	errSum := 0.0
+	for i := 0; i < n; i++ {
+		res := math.Hypot(x-xs[i], y-ys[i]) - ds[i]
+		errSum += ws[i] * res * res
+	}
+
+	return &GeoEstimate{
+		Latitude:  lat0 + y/ky,
+		Longitude: lon0 + x/kx,
+		Accuracy:  math.Sqrt(errSum / wsum),
+	}, true
+}
@@ -0,0 +1,181 @@
+package network
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const wigleTimeFormat = "2006-01-02 15:04:05"
+
+// geoPosition returns the estimated position of the station, or the one
+// where it's been seen with the best signal if no estimate is available.
+func geoPosition(s *Station) (lat float64, lon float64, accuracy float64, found bool) {
+	if est, ok := s.Geo.Estimate(); ok {
+		return est.Latitude, est.Longitude, est.Accuracy, true
+	} else if best := s.Geo.Best(); best != nil {
+		return best.Latitude, best.Longitude, 0, true
+	}
+	return 0, 0, 0, false
+}
+
+func wigleAuthMode(s *Station) string {
+	if s.IsOpen() {
+		return "[ESS]"
+	}
+	parts := []string{s.Encryption}
+	if s.Authentication != "" {
+		parts = append(parts, s.Authentication)
+	}
+	if s.Cipher != "" {
+		parts = append(parts, s.Cipher)
+	}
+	return fmt.Sprintf("[%s][ESS]", strings.Join(parts, "-"))
+}
+
+type geoJSONGeometry struct {
+	Type        string    `json:"type"`
+	Coordinates []float64 `json:"coordinates"`
+}
+
+type geoJSONFeature struct {
+	Type       string                 `json:"type"`
+	Geometry   geoJSONGeometry        `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+type geoJSONCollection struct {
+	Type     string           `json:"type"`
+	Features []geoJSONFeature `json:"features"`
+}
+
+// WiFiToGeoJSON exports the estimated position of each access point and every
+// sighting with its signal strength, the latter can be used as a heatmap layer.
+func WiFiToGeoJSON(aps []*AccessPoint) ([]byte, error) {
+	doc := geoJSONCollection{
+		Type:     "FeatureCollection",
+		Features: make([]geoJSONFeature, 0),
+	}
+
+	for _, ap := range aps {
+		lat, lon, accuracy, found := geoPosition(ap.Station)
+		if !found {
+			continue
+		}
+
+		doc.Features = append(doc.Features, geoJSONFeature{
+			Type: "Feature",
+			Geometry: geoJSONGeometry{
+				Type:        "Point",
+				Coordinates: []float64{lon, lat},
+			},
+			Properties: map[string]interface{}{
+				"kind":       "ap",
+				"bssid":      ap.BSSID(),
+				"essid":      ap.ESSID(),
+				"channel":    ap.Channel,
+				"encryption": ap.Encryption,
+				"rssi":       ap.Geo.Best().RSSI,
+				"accuracy":   accuracy,
+			},
+		})
+
+		for _, s := range ap.Geo.Samples() {
+			doc.Features = append(doc.Features, geoJSONFeature{
+				Type: "Feature",
+				Geometry: geoJSONGeometry{
+					Type:        "Point",
+					Coordinates: []float64{s.Longitude, s.Latitude, s.Altitude},
+				},
+				Properties: map[string]interface{}{
+					"kind":  "sample",
+					"bssid": ap.BSSID(),
+					"rssi":  s.RSSI,
+					"time":  s.Time,
+				},
+			})
+		}
+	}
+
+	return json.MarshalIndent(doc, "", "  ")
+}
+
+func kmlEscape(s string) string {
+	buf := bytes.Buffer{}
+	xml.EscapeText(&buf, []byte(s))
+	return buf.String()
+}
+
+// WiFiToKML exports the estimated position of each access point as a KML
+// placemark, with the sightings in a separate folder.
+func WiFiToKML(aps []*AccessPoint) ([]byte, error) {
+	buf := bytes.Buffer{}
+	samples := bytes.Buffer{}
+
+	buf.WriteString(xml.Header)
+	buf.WriteString("<kml xmlns=\"http://www.opengis.net/kml/2.2\">\n<Document>\n<name>bettercap</name>\n")
+	buf.WriteString("<Folder>\n<name>Access Points</name>\n")
+
+	for _, ap := range aps {
+		lat, lon, accuracy, found := geoPosition(ap.Station)
+		if !found {
+			continue
+		}
+
+		fmt.Fprintf(&buf, "<Placemark>\n<name>%s</name>\n", kmlEscape(ap.ESSID()))
+		fmt.Fprintf(&buf, "<description>BSSID: %s\nChannel: %d\nEncryption: %s\nBest RSSI: %d dBm\nAccuracy: %.1f m</description>\n",
+			kmlEscape(ap.BSSID()), ap.Channel, kmlEscape(wigleAuthMode(ap.Station)), ap.Geo.Best().RSSI, accuracy)
+		fmt.Fprintf(&buf, "<Point><coordinates>%f,%f</coordinates></Point>\n</Placemark>\n", lon, lat)
+
+		for _, s := range ap.Geo.Samples() {
+			fmt.Fprintf(&samples, "<Placemark>\n<name>%d dBm</name>\n<description>%s</description>\n", s.RSSI, kmlEscape(ap.BSSID()))
+			fmt.Fprintf(&samples, "<Point><coordinates>%f,%f,%f</coordinates></Point>\n</Placemark>\n", s.Longitude, s.Latitude, s.Altitude)
+		}
+	}
+
+	buf.WriteString("</Folder>\n<Folder>\n<name>Samples</name>\n")
+	buf.Write(samples.Bytes())
+	buf.WriteString("</Folder>\n</Document>\n</kml>\n")
+
+	return buf.Bytes(), nil
+}
+
+// WiFiToWiGLE writes every access point sighting in the WiGLE CSV format.
+func WiFiToWiGLE(aps []*AccessPoint, w io.Writer) error {
+	if _, err := io.WriteString(w, "WigleWifi-1.4,appRelease=bettercap,model=bettercap,release=bettercap,device=bettercap,display=,board=,brand=bettercap\n"); err != nil {
+		return err
+	}
+
+	out := csv.NewWriter(w)
+	if err := out.Write([]string{"MAC", "SSID", "AuthMode", "FirstSeen", "Channel", "RSSI", "CurrentLatitude", "CurrentLongitude", "AltitudeMeters", "AccuracyMeters", "Type"}); err != nil {
+		return err
+	}
+
+	for _, ap := range aps {
+		for _, s := range ap.Geo.Samples() {
+			if err := out.Write([]string{
+				ap.BSSID(),
+				ap.ESSID(),
+				wigleAuthMode(ap.Station),
+				s.Time.UTC().Format(wigleTimeFormat),
+				strconv.Itoa(ap.Channel),
+				strconv.Itoa(int(s.RSSI)),
+				strconv.FormatFloat(s.Latitude, 'f', 8, 64),
+				strconv.FormatFloat(s.Longitude, 'f', 8, 64),
+				strconv.FormatFloat(s.Altitude, 'f', 1, 64),
+				"0",
+				"WIFI",
+			}); err != nil {
+				return err
+			}
+		}
+	}
+
+	out.Flush()
+	return out.Error()
+}
@@ -0,0 +1,176 @@
+package network
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func geoTestFix(lat, lon float64) GeoFix {
+	return GeoFix{Latitude: lat, Longitude: lon, Time: time.Now()}
+}
+
+// rssi that the path loss model would expect at the given distance
+func geoTestRSSI(meters float64) int8 {
+	return int8(math.Round(geoRefRSSI - 10*geoPathLossExp*math.Log10(meters)))
+}
+
+func TestGeoDistance(t *testing.T) {
+	// one degree of latitude is ~111.2km
+	if d := GeoDistance(45, 9, 46, 9); math.Abs(d-111195) > 100 {
+		t.Fatalf("unexpected distance %f", d)
+	}
+	if d := GeoDistance(45, 9, 45, 9); d != 0 {
+		t.Fatalf("expected 0, got %f", d)
+	}
+}
+
+func TestGeoTrackAdd(t *testing.T) {
+	track := NewGeoTrack()
+	if track.Last() != nil || track.Best() != nil {
+		t.Fatal("expected empty track")
+	} else if _, ok := track.Estimate(); ok {
+		t.Fatal("expected no estimate")
+	}
+
+	track.Add(geoTestFix(45.0, 9.0), -70)
+	// less than a meter away, should be merged keeping the best signal
+	track.Add(geoTestFix(45.000001, 9.0), -60)
+	track.Add(geoTestFix(45.000002, 9.0), -80)
+
+	if n := len(track.Samples()); n != 1 {
+		t.Fatalf("expected 1 sample, got %d", n)
+	} else if rssi := track.Samples()[0].RSSI; rssi != -60 {
+		t.Fatalf("expected merged sample with -60 dBm, got %d", rssi)
+	} else if last := track.Last(); last.RSSI != -80 {
+		t.Fatalf("expected last sample with -80 dBm, got %d", last.RSSI)
+	}
+
+	track.Add(geoTestFix(45.001, 9.0), -50)
+	if n := len(track.Samples()); n != 2 {
+		t.Fatalf("expected 2 samples, got %d", n)
+	} else if best := track.Best(); best.RSSI != -50 || best.Latitude != 45.001 {
+		t.Fatalf("unexpected best sample %+v", best)
+	}
+}
+
+func TestGeoTrackMaxSamples(t *testing.T) {
+	track := NewGeoTrack()
+	for i := 0; i < geoMaxSamples+10; i++ {
+		track.Add(geoTestFix(45.0+float64(i)*0.001, 9.0), -70)
+	}
+	if n := len(track.Samples()); n != geoMaxSamples {
+		t.Fatalf("expected %d samples, got %d", geoMaxSamples, n)
+	}
+}
+
+func TestGeoTrackEstimate(t *testing.T) {
+	apLat, apLon := 45.0, 9.0
+	// degrees per meter around the AP
+	dLat := 1.0 / 111195.0
+	dLon := dLat / math.Cos(apLat*math.Pi/180)
+
+	track := NewGeoTrack()
+	for _, off := range [][2]float64{{30, 0}, {0, 40}, {-25, -10}, {10, -35}, {-20, 30}} {
+		dist := math.Hypot(off[0], off[1])
+		track.Add(geoTestFix(apLat+off[1]*dLat, apLon+off[0]*dLon), geoTestRSSI(dist))
+	}
+
+	est, ok := track.Estimate()
+	if !ok {
+		t.Fatal("expected an estimate")
+	}
+	if d := GeoDistance(apLat, apLon, est.Latitude, est.Longitude); d > 5 {
+		t.Fatalf("estimate is %.1fm away from the access point", d)
+	}
+}
+
+func TestGeoTrackJSON(t *testing.T) {
+	track := NewGeoTrack()
+	track.Add(geoTestFix(45.0, 9.0), -60)
+
+	raw, err := json.Marshal(track)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var doc map[string]interface{}
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		t.Fatal(err)
+	} else if doc["samples"].(float64) != 1 {
+		t.Fatalf("unexpected json %s", raw)
+	} else if doc["estimate"] == nil {
+		t.Fatalf("expected estimate in %s", raw)
+	}
+}
+
+func buildGeoAccessPoints() []*AccessPoint {
+	w := buildExampleWiFi()
+	w.SetLocator(func() (GeoFix, bool) {
+		return geoTestFix(45.0, 9.0), true
+	})
+
+	ap, _ := w.AddIfNew("test & co", "aa:bb:cc:dd:ee:ff", 2437, -50)
+	ap.Encryption = "WPA2"
+	ap.Cipher = "CCMP"
+	ap.Authentication = "PSK"
+	// never geotagged
+	w.AddIfNew("nowhere", "11:22:33:44:55:66", 2437, 0)
+
+	return w.List()
+}
+
+func TestWiFiToGeoJSON(t *testing.T) {
+	raw, err := WiFiToGeoJSON(buildGeoAccessPoints())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var doc geoJSONCollection
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		t.Fatal(err)
+	} else if doc.Type != "FeatureCollection" {
+		t.Fatalf("unexpected type %s", doc.Type)
+	} else if len(doc.Features) != 2 {
+		t.Fatalf("expected 2 features, got %d", len(doc.Features))
+	} else if kind := doc.Features[0].Properties["kind"]; kind != "ap" {
+		t.Fatalf("expected ap feature, got %v", kind)
+	} else if coords := doc.Features[0].Geometry.Coordinates; coords[0] != 9.0 || coords[1] != 45.0 {
+		t.Fatalf("unexpected coordinates %v", coords)
+	}
+}
+
+func TestWiFiToKML(t *testing.T) {
+	raw, err := WiFiToKML(buildGeoAccessPoints())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	kml := string(raw)
+	if !strings.Contains(kml, "<name>test &amp; co</name>") {
+		t.Fatalf("access point placemark not found in %s", kml)
+	} else if strings.Contains(kml, "nowhere") {
+		t.Fatalf("unexpected placemark for an access point without position")
+	} else if !strings.Contains(kml, "<coordinates>9.000000,45.000000</coordinates>") {
+		t.Fatalf("coordinates not found in %s", kml)
+	}
+}
+
+func TestWiFiToWiGLE(t *testing.T) {
+	buf := bytes.Buffer{}
+	if err := WiFiToWiGLE(buildGeoAccessPoints(), &buf); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expected 3 lines, got %d: %s", len(lines), buf.String())
+	} else if !strings.HasPrefix(lines[0], "WigleWifi-1.4,") {
+		t.Fatalf("unexpected header %s", lines[0])
+	} else if !strings.HasPrefix(lines[2], "aa:bb:cc:dd:ee:ff,test & co,[WPA2-PSK-CCMP][ESS],") {
+		t.Fatalf("unexpected row %s", lines[2])
+	}
+}
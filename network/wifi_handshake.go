@@ -132,11 +132,46 @@ func (h *Handshake) NumUnsaved() int {
 }
 
 func (h *Handshake) EachUnsavedPacket(cb func(gopacket.Packet)) {
+	h.EachUnsavedFrame(func(pkt gopacket.Packet, frame string) {
+		cb(pkt)
+	})
+}
+
+func containsPacket(list []gopacket.Packet, pkt gopacket.Packet) bool {
+	for _, p := range list {
+		if p == pkt {
+			return true
+		}
+	}
+	return false
+}
+
+// frameName returns which part of the handshake the packet is, or an empty
+// string for the extra frames.
+func (h *Handshake) frameName(pkt gopacket.Packet) string {
+	if pkt == h.Beacon {
+		return "beacon"
+	} else if containsPacket(h.Challenges, pkt) {
+		if h.hasPMKID {
+			return "M1 (PMKID)"
+		}
+		return "M1"
+	} else if containsPacket(h.Responses, pkt) {
+		return "M2"
+	} else if containsPacket(h.Confirmations, pkt) {
+		return "M3"
+	}
+	return ""
+}
+
+// EachUnsavedFrame is like EachUnsavedPacket, but also passes the part of
+// the handshake each packet is.
+func (h *Handshake) EachUnsavedFrame(cb func(gopacket.Packet, string)) {
 	h.Lock()
 	defer h.Unlock()
 
 	for _, pkt := range h.unsaved {
-		cb(pkt)
+		cb(pkt, h.frameName(pkt))
 	}
 	h.unsaved = make([]gopacket.Packet, 0)
 }
@@ -23,6 +23,7 @@ type Station struct {
 	Authentication string            `json:"authentication"`
 	WPS            map[string]string `json:"wps"`
 	Handshake      *Handshake        `json:"-"`
+	Geo            *GeoTrack         `json:"geo"`
 }
 
 func cleanESSID(essid string) string {
@@ -45,6 +46,7 @@ func NewStation(essid, bssid string, frequency int, rssi int8) *Station {
 		RSSI:      rssi,
 		WPS:       make(map[string]string),
 		Handshake: NewHandshake(),
+		Geo:       NewGeoTrack(),
 	}
 }
 
@@ -0,0 +1,160 @@
+package packets
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+)
+
+// AuthCredentials are the credentials extracted from a sniffed
+// authentication exchange, either in clear text or as a crackable hash.
+type AuthCredentials struct {
+	Protocol    string `json:"protocol"`
+	Client      string `json:"client"`
+	Server      string `json:"server"`
+	Method      string `json:"method"`
+	Username    string `json:"username"`
+	Password    string `json:"password,omitempty"`
+	Hash        string `json:"hash,omitempty"`
+	HashcatMode int    `json:"hashcat_mode,omitempty"`
+	HashFormat  string `json:"hash_format,omitempty"`
+	Info        string `json:"info,omitempty"`
+}
+
+func (c AuthCredentials) String() string {
+	s := c.Method
+	if c.Username != "" {
+		s += " " + c.Username
+	}
+	if c.Password != "" {
+		s += " " + c.Password
+	} else if c.Hash != "" {
+		s += " " + c.Hash
+	}
+	if c.Info != "" {
+		s += " (" + c.Info + ")"
+	}
+	return s
+}
+
+// AuthFlow is a payload exchanged between a client and a server.
+type AuthFlow struct {
+	Client     string
+	Server     string
+	FromClient bool
+}
+
+// NewAuthFlow returns the flow of a packet given the ports the server
+// listens on, or false if neither port is one of them.
+func NewAuthFlow(srcIP net.IP, srcPort int, dstIP net.IP, dstPort int, ports ...int) (AuthFlow, bool) {
+	src := net.JoinHostPort(srcIP.String(), fmt.Sprintf("%d", srcPort))
+	dst := net.JoinHostPort(dstIP.String(), fmt.Sprintf("%d", dstPort))
+	for _, port := range ports {
+		if dstPort == port {
+			return AuthFlow{Client: src, Server: dst, FromClient: true}, true
+		} else if srcPort == port {
+			return AuthFlow{Client: dst, Server: src, FromClient: false}, true
+		}
+	}
+	return AuthFlow{}, false
+}
+
+func (f AuthFlow) Key() string {
+	return f.Client + ">" + f.Server
+}
+
+func (f AuthFlow) credentials(proto string, method string) *AuthCredentials {
+	return &AuthCredentials{
+		Protocol: proto,
+		Client:   f.Client,
+		Server:   f.Server,
+		Method:   method,
+	}
+}
+
+// max number of conversations tracked by a stateful parser before the
+// oldest state is dropped
+const authMaxFlows = 1024
+
+// authFlows keeps the parsing state of each conversation.
+type authFlows struct {
+	sync.Mutex
+	states map[string]interface{}
+	order  []string
+}
+
+func newAuthFlows() authFlows {
+	return authFlows{
+		states: make(map[string]interface{}),
+		order:  make([]string, 0),
+	}
+}
+
+// get returns the state of the flow, creating it with the factory if needed
+// and if not nil, must be called with the lock held.
+func (f *authFlows) get(flow AuthFlow, factory func() interface{}) interface{} {
+	key := flow.Key()
+	if state, found := f.states[key]; found {
+		return state
+	} else if factory == nil {
+		return nil
+	}
+
+	if len(f.order) >= authMaxFlows {
+		delete(f.states, f.order[0])
+		f.order = f.order[1:]
+	}
+
+	state := factory()
+	f.states[key] = state
+	f.order = append(f.order, key)
+	return state
+}
+
+// del removes the state of the flow, must be called with the lock held.
+func (f *authFlows) del(flow AuthFlow) {
+	key := flow.Key()
+	if _, found := f.states[key]; found {
+		delete(f.states, key)
+		for i, k := range f.order {
+			if k == key {
+				f.order = append(f.order[:i], f.order[i+1:]...)
+				break
+			}
+		}
+	}
+}
+
+func authBase64(s string) (string, bool) {
+	s = strings.TrimSpace(s)
+	if raw, err := base64.StdEncoding.DecodeString(s); err == nil {
+		return string(raw), true
+	}
+	return "", false
+}
+
+// authPlain parses a SASL PLAIN message: authzid\0authcid\0passwd
+func authPlain(s string) (user string, pass string, ok bool) {
+	raw, ok := authBase64(s)
+	if !ok {
+		return "", "", false
+	}
+	parts := strings.Split(raw, "\x00")
+	if len(parts) != 3 {
+		return "", "", false
+	}
+	return parts[1], parts[2], true
+}
+
+// authLines splits a text protocol payload in lines.
+func authLines(payload []byte) []string {
+	lines := make([]string, 0)
+	for _, line := range strings.Split(string(payload), "\n") {
+		if line = strings.TrimRight(line, "\r"); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
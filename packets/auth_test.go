@@ -0,0 +1,179 @@
+package packets
+
+import (
+	"bytes"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gopacket/gopacket"
+	"github.com/gopacket/gopacket/layers"
+	"github.com/gopacket/gopacket/pcapgo"
+)
+
+var (
+	testAuthClient = net.ParseIP("10.0.0.2").To4()
+	testAuthServer = net.ParseIP("10.0.0.1").To4()
+)
+
+const testAuthClientPort = 40000
+
+type authExchange struct {
+	fromClient bool
+	data       []byte
+}
+
+func fromClient(data string) authExchange {
+	return authExchange{true, []byte(data)}
+}
+
+func fromServer(data string) authExchange {
+	return authExchange{false, []byte(data)}
+}
+
+// authCapture writes the exchange to a pcap file in memory and reads it back.
+func authCapture(t *testing.T, udp bool, port int, exchange []authExchange) []gopacket.Packet {
+	buf := bytes.Buffer{}
+	w := pcapgo.NewWriter(&buf)
+	if err := w.WriteFileHeader(65536, layers.LinkTypeEthernet); err != nil {
+		t.Fatal(err)
+	}
+
+	clientMAC := net.HardwareAddr{0x02, 0, 0, 0, 0, 2}
+	serverMAC := net.HardwareAddr{0x02, 0, 0, 0, 0, 1}
+	seq := map[bool]uint32{true: 1000, false: 5000}
+	now := time.Now()
+
+	for i, ex := range exchange {
+		eth := layers.Ethernet{SrcMAC: clientMAC, DstMAC: serverMAC, EthernetType: layers.EthernetTypeIPv4}
+		ip := layers.IPv4{Version: 4, TTL: 64, SrcIP: testAuthClient, DstIP: testAuthServer}
+		srcPort, dstPort := testAuthClientPort, port
+		if !ex.fromClient {
+			eth.SrcMAC, eth.DstMAC = eth.DstMAC, eth.SrcMAC
+			ip.SrcIP, ip.DstIP = ip.DstIP, ip.SrcIP
+			srcPort, dstPort = dstPort, srcPort
+		}
+
+		var transport gopacket.SerializableLayer
+		if udp {
+			ip.Protocol = layers.IPProtocolUDP
+			l := &layers.UDP{SrcPort: layers.UDPPort(srcPort), DstPort: layers.UDPPort(dstPort)}
+			l.SetNetworkLayerForChecksum(&ip)
+			transport = l
+		} else {
+			ip.Protocol = layers.IPProtocolTCP
+			l := &layers.TCP{SrcPort: layers.TCPPort(srcPort), DstPort: layers.TCPPort(dstPort), Seq: seq[ex.fromClient], PSH: true, ACK: true, Window: 1024}
+			l.SetNetworkLayerForChecksum(&ip)
+			transport = l
+			seq[ex.fromClient] += uint32(len(ex.data))
+		}
+
+		raw := gopacket.NewSerializeBuffer()
+		opts := gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}
+		if err := gopacket.SerializeLayers(raw, opts, &eth, &ip, transport, gopacket.Payload(ex.data)); err != nil {
+			t.Fatal(err)
+		}
+
+		ci := gopacket.CaptureInfo{
+			Timestamp:     now.Add(time.Duration(i) * time.Millisecond),
+			CaptureLength: len(raw.Bytes()),
+			Length:        len(raw.Bytes()),
+		}
+		if err := w.WritePacket(ci, raw.Bytes()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	r, err := pcapgo.NewReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pkts := make([]gopacket.Packet, 0)
+	for pkt := range gopacket.NewPacketSource(r, layers.LinkTypeEthernet).Packets() {
+		pkts = append(pkts, pkt)
+	}
+	if len(pkts) != len(exchange) {
+		t.Fatalf("expected %d packets, got %d", len(exchange), len(pkts))
+	}
+	return pkts
+}
+
+// authReplay feeds the payloads of the capture to the parser and returns
+// every credential it extracted.
+func authReplay(t *testing.T, udp bool, port int, exchange []authExchange, parse func(AuthFlow, []byte) *AuthCredentials) []*AuthCredentials {
+	found := make([]*AuthCredentials, 0)
+	for _, pkt := range authCapture(t, udp, port, exchange) {
+		ip := pkt.Layer(layers.LayerTypeIPv4).(*layers.IPv4)
+		var srcPort, dstPort int
+		var payload []byte
+		if udp {
+			l := pkt.Layer(layers.LayerTypeUDP).(*layers.UDP)
+			srcPort, dstPort, payload = int(l.SrcPort), int(l.DstPort), l.Payload
+		} else {
+			l := pkt.Layer(layers.LayerTypeTCP).(*layers.TCP)
+			srcPort, dstPort, payload = int(l.SrcPort), int(l.DstPort), l.Payload
+		}
+
+		flow, ok := NewAuthFlow(ip.SrcIP, srcPort, ip.DstIP, dstPort, port)
+		if !ok {
+			t.Fatalf("unexpected flow %s:%d > %s:%d", ip.SrcIP, srcPort, ip.DstIP, dstPort)
+		}
+		if creds := parse(flow, payload); creds != nil {
+			found = append(found, creds)
+		}
+	}
+	return found
+}
+
+// authExpect replays the exchange and checks that the parser extracted
+// exactly the expected credentials.
+func authExpect(t *testing.T, udp bool, port int, exchange []authExchange, parse func(AuthFlow, []byte) *AuthCredentials, expected AuthCredentials) {
+	t.Helper()
+
+	found := authReplay(t, udp, port, exchange, parse)
+	if len(found) != 1 {
+		t.Fatalf("expected one set of credentials, got %d", len(found))
+	}
+
+	got := *found[0]
+	expected.Client = "10.0.0.2:40000"
+	expected.Server = net.JoinHostPort(testAuthServer.String(), strconv.Itoa(port))
+	if got != expected {
+		t.Fatalf("expected %+v, got %+v", expected, got)
+	}
+}
+
+func TestNewAuthFlow(t *testing.T) {
+	flow, ok := NewAuthFlow(testAuthClient, 40000, testAuthServer, 25, 25, 587)
+	if !ok || !flow.FromClient || flow.Client != "10.0.0.2:40000" || flow.Server != "10.0.0.1:25" {
+		t.Fatalf("unexpected flow %+v", flow)
+	}
+
+	reply, ok := NewAuthFlow(testAuthServer, 587, testAuthClient, 40000, 25, 587)
+	if !ok || reply.FromClient || reply.Key() != "10.0.0.2:40000>10.0.0.1:587" {
+		t.Fatalf("unexpected flow %+v", reply)
+	}
+
+	if _, ok := NewAuthFlow(testAuthClient, 40000, testAuthServer, 80, 25); ok {
+		t.Fatal("unexpected flow for a different port")
+	}
+}
+
+func TestAuthFlowsLimit(t *testing.T) {
+	flows := newAuthFlows()
+	for i := 0; i < authMaxFlows+10; i++ {
+		flow, _ := NewAuthFlow(testAuthClient, 1000+i, testAuthServer, 25, 25)
+		flows.get(flow, func() interface{} { return i })
+	}
+
+	if len(flows.states) != authMaxFlows || len(flows.order) != authMaxFlows {
+		t.Fatalf("expected %d flows, got %d", authMaxFlows, len(flows.states))
+	}
+
+	first, _ := NewAuthFlow(testAuthClient, 1000, testAuthServer, 25, 25)
+	if flows.get(first, nil) != nil {
+		t.Fatal("the oldest flow should have been dropped")
+	}
+}
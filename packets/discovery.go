@@ -0,0 +1,128 @@
+package packets
+
+import (
+	"net"
+
+	"github.com/gopacket/gopacket"
+	"github.com/gopacket/gopacket/layers"
+)
+
+// host discovery techniques, named after the probes the hosts replied to
+const (
+	DiscoveryARP           = "arp"
+	DiscoveryICMPEcho      = "icmp-echo"
+	DiscoveryICMPTimestamp = "icmp-timestamp"
+	DiscoveryICMP6Echo     = "icmp6-echo"
+	DiscoveryMLD           = "mld"
+	DiscoveryTCPSyn        = "tcp-syn"
+	DiscoveryTCPAck        = "tcp-ack"
+	DiscoveryNBNS          = "nbns"
+	DiscoveryMDNS          = "mdns"
+	DiscoveryUPNP          = "upnp"
+	DiscoveryWSD           = "wsd"
+)
+
+// DiscoveryProbes identifies the probes sent for host discovery, so that
+// their replies can be told apart from the rest of the traffic.
+type DiscoveryProbes struct {
+	// address of the prober, the replies to the UDP probes are sent to it
+	IP net.IP
+	// identifier of the ICMP and ICMPv6 echo and timestamp requests
+	ICMPID uint16
+	// source ports of the TCP SYN and ACK pings
+	SynPort int
+	AckPort int
+}
+
+type DiscoveryReply struct {
+	Technique string
+	IP        net.IP
+	MAC       net.HardwareAddr
+}
+
+// udpDiscoveryTechniques maps the source port of the replies to the UDP
+// probes to their technique
+var udpDiscoveryTechniques = map[layers.UDPPort]string{
+	NBNSPort: DiscoveryNBNS,
+	MDNSPort: DiscoveryMDNS,
+	UPNPPort: DiscoveryUPNP,
+	WSDPort:  DiscoveryWSD,
+}
+
+// ParseDiscoveryReply returns the host that sent the packet and the
+// technique it replied to, if the packet is a reply to one of the probes.
+func ParseDiscoveryReply(pkt gopacket.Packet, probes DiscoveryProbes) (reply DiscoveryReply, ok bool) {
+	eth, found := pkt.Layer(layers.LayerTypeEthernet).(*layers.Ethernet)
+	if !found {
+		return
+	}
+	reply.MAC = eth.SrcMAC
+
+	if arp, found := pkt.Layer(layers.LayerTypeARP).(*layers.ARP); found {
+		if arp.Operation != layers.ARPReply {
+			return
+		}
+		reply.Technique = DiscoveryARP
+		reply.IP = net.IP(arp.SourceProtAddress)
+		return reply, true
+	}
+
+	var dstIP net.IP
+	switch ip := pkt.NetworkLayer().(type) {
+	case *layers.IPv4:
+		reply.IP, dstIP = ip.SrcIP, ip.DstIP
+	case *layers.IPv6:
+		reply.IP, dstIP = ip.SrcIP, ip.DstIP
+	default:
+		return
+	}
+
+	if icmp, found := pkt.Layer(layers.LayerTypeICMPv4).(*layers.ICMPv4); found {
+		if icmp.Id != probes.ICMPID {
+			return
+		}
+		switch icmp.TypeCode.Type() {
+		case layers.ICMPv4TypeEchoReply:
+			reply.Technique = DiscoveryICMPEcho
+		case layers.ICMPv4TypeTimestampReply:
+			reply.Technique = DiscoveryICMPTimestamp
+		default:
+			return
+		}
+	} else if icmp6, found := pkt.Layer(layers.LayerTypeICMPv6).(*layers.ICMPv6); found {
+		switch icmp6.TypeCode.Type() {
+		case layers.ICMPv6TypeEchoReply:
+			echo, found := pkt.Layer(layers.LayerTypeICMPv6Echo).(*layers.ICMPv6Echo)
+			if !found || echo.Identifier != probes.ICMPID {
+				return
+			}
+			reply.Technique = DiscoveryICMP6Echo
+		case layers.ICMPv6TypeMLDv1MulticastListenerReportMessage, layers.ICMPv6TypeMLDv2MulticastListenerReportMessageV2:
+			// the hosts also send them when joining a group, it's up to
+			// the caller to check a query has been sent
+			reply.Technique = DiscoveryMLD
+		default:
+			return
+		}
+	} else if tcp, found := pkt.Layer(layers.LayerTypeTCP).(*layers.TCP); found {
+		// both a SYN/ACK and a RST mean the host is up
+		if int(tcp.DstPort) == probes.SynPort && (tcp.SYN && tcp.ACK || tcp.RST) {
+			reply.Technique = DiscoveryTCPSyn
+		} else if int(tcp.DstPort) == probes.AckPort && tcp.RST {
+			reply.Technique = DiscoveryTCPAck
+		} else {
+			return
+		}
+	} else if udp, found := pkt.Layer(layers.LayerTypeUDP).(*layers.UDP); found {
+		// not the replies to the queries of the other hosts
+		if !dstIP.Equal(probes.IP) {
+			return
+		} else if reply.Technique, found = udpDiscoveryTechniques[udp.SrcPort]; !found {
+			return
+		}
+	} else {
+		return
+	}
+
+	return reply, true
+}
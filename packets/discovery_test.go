@@ -0,0 +1,140 @@
+package packets
+
+import (
+	"net"
+	"testing"
+
+	"github.com/gopacket/gopacket"
+	"github.com/gopacket/gopacket/layers"
+)
+
+var (
+	testHostHW   = net.HardwareAddr{0x02, 0, 0, 0, 0, 0x10}
+	testProberHW = net.HardwareAddr{0x02, 0, 0, 0, 0, 0x01}
+	testHostIP   = net.IPv4(192, 168, 1, 10).To4()
+	testProberIP = net.IPv4(192, 168, 1, 2).To4()
+	testHostIP6  = net.ParseIP("fe80::10")
+	testProbes   = DiscoveryProbes{IP: testProberIP, ICMPID: 0x1234, SynPort: 40001, AckPort: 40002}
+)
+
+func decode(raw []byte) gopacket.Packet {
+	return gopacket.NewPacket(raw, layers.LayerTypeEthernet, gopacket.Default)
+}
+
+func TestNewICMPTimestamp(t *testing.T) {
+	err, raw := NewICMPTimestamp(testProberIP, testProberHW, testHostIP, testHostHW, 1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	icmp, found := decode(raw).Layer(layers.LayerTypeICMPv4).(*layers.ICMPv4)
+	if !found || icmp.TypeCode.Type() != layers.ICMPv4TypeTimestampRequest || len(icmp.Payload) != 12 {
+		t.Fatalf("unexpected timestamp request %+v", icmp)
+	}
+}
+
+func TestNewMLDQuery(t *testing.T) {
+	err, raw := NewMLDQuery(net.ParseIP("fe80::1"), testProberHW)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkt := decode(raw)
+
+	ip6 := pkt.Layer(layers.LayerTypeIPv6).(*layers.IPv6)
+	if ip6.HopLimit != 1 || !ip6.DstIP.Equal(net.ParseIP("ff02::1")) {
+		t.Fatalf("unexpected IPv6 header %+v", ip6)
+	}
+	if hbh, found := pkt.Layer(layers.LayerTypeIPv6HopByHop).(*layers.IPv6HopByHop); !found || len(hbh.Options) == 0 || hbh.Options[0].OptionType != 0x05 {
+		t.Fatal("missing router alert option")
+	}
+	if query, found := pkt.Layer(layers.LayerTypeMLDv2MulticastListenerQuery).(*layers.MLDv2MulticastListenerQueryMessage); !found {
+		t.Fatal("not an MLDv2 query")
+	} else if !query.MulticastAddress.IsUnspecified() {
+		t.Fatalf("not a general query: %s", query.MulticastAddress)
+	}
+}
+
+func TestParseDiscoveryReply(t *testing.T) {
+	reply := func(l ...gopacket.SerializableLayer) gopacket.Packet {
+		err, raw := Serialize(l...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return decode(raw)
+	}
+
+	eth4 := &layers.Ethernet{SrcMAC: testHostHW, DstMAC: testProberHW, EthernetType: layers.EthernetTypeIPv4}
+	eth6 := &layers.Ethernet{SrcMAC: testHostHW, DstMAC: testProberHW, EthernetType: layers.EthernetTypeIPv6}
+	ip4 := func(proto layers.IPProtocol) *layers.IPv4 {
+		return &layers.IPv4{Version: 4, TTL: 64, Protocol: proto, SrcIP: testHostIP, DstIP: testProberIP}
+	}
+	// the reply of a host to the multicast query of another one
+	multicast4 := &layers.IPv4{Version: 4, TTL: 255, Protocol: layers.IPProtocolUDP, SrcIP: testHostIP, DstIP: MDNSDestIP}
+	ip6 := &layers.IPv6{Version: 6, HopLimit: 64, NextHeader: layers.IPProtocolICMPv6, SrcIP: testHostIP6, DstIP: net.ParseIP("fe80::1")}
+	tcp := func(dstPort int, syn, ack, rst bool) *layers.TCP {
+		l := &layers.TCP{SrcPort: 80, DstPort: layers.TCPPort(dstPort), SYN: syn, ACK: ack, RST: rst}
+		l.SetNetworkLayerForChecksum(ip4(layers.IPProtocolTCP))
+		return l
+	}
+	udp := func(srcPort int) *layers.UDP {
+		l := &layers.UDP{SrcPort: layers.UDPPort(srcPort), DstPort: 50000}
+		l.SetNetworkLayerForChecksum(ip4(layers.IPProtocolUDP))
+		return l
+	}
+	icmp6 := func(typ uint8) *layers.ICMPv6 {
+		l := &layers.ICMPv6{TypeCode: layers.CreateICMPv6TypeCode(typ, 0)}
+		l.SetNetworkLayerForChecksum(ip6)
+		return l
+	}
+
+	tests := []struct {
+		name      string
+		pkt       gopacket.Packet
+		technique string
+	}{
+		{"arp reply", reply(&layers.Ethernet{SrcMAC: testHostHW, DstMAC: testProberHW, EthernetType: layers.EthernetTypeARP}, &layers.ARP{
+			AddrType: layers.LinkTypeEthernet, Protocol: layers.EthernetTypeIPv4, HwAddressSize: 6, ProtAddressSize: 4,
+			Operation: layers.ARPReply, SourceHwAddress: testHostHW, SourceProtAddress: testHostIP,
+			DstHwAddress: testProberHW, DstProtAddress: testProberIP,
+		}), DiscoveryARP},
+		{"echo reply", reply(eth4, ip4(layers.IPProtocolICMPv4), &layers.ICMPv4{
+			TypeCode: layers.CreateICMPv4TypeCode(layers.ICMPv4TypeEchoReply, 0), Id: testProbes.ICMPID,
+		}), DiscoveryICMPEcho},
+		{"echo reply to someone else", reply(eth4, ip4(layers.IPProtocolICMPv4), &layers.ICMPv4{
+			TypeCode: layers.CreateICMPv4TypeCode(layers.ICMPv4TypeEchoReply, 0), Id: 1,
+		}), ""},
+		{"timestamp reply", reply(eth4, ip4(layers.IPProtocolICMPv4), &layers.ICMPv4{
+			TypeCode: layers.CreateICMPv4TypeCode(layers.ICMPv4TypeTimestampReply, 0), Id: testProbes.ICMPID,
+		}, gopacket.Payload(make([]byte, 12))), DiscoveryICMPTimestamp},
+		{"icmp6 echo reply", reply(eth6, ip6, icmp6(layers.ICMPv6TypeEchoReply), &layers.ICMPv6Echo{
+			Identifier: testProbes.ICMPID,
+		}), DiscoveryICMP6Echo},
+		{"mld report", reply(eth6, ip6, icmp6(layers.ICMPv6TypeMLDv2MulticastListenerReportMessageV2), gopacket.Payload([]byte{0, 0, 0, 0})), DiscoveryMLD},
+		{"syn/ack", reply(eth4, ip4(layers.IPProtocolTCP), tcp(testProbes.SynPort, true, true, false)), DiscoveryTCPSyn},
+		{"rst to syn", reply(eth4, ip4(layers.IPProtocolTCP), tcp(testProbes.SynPort, false, true, true)), DiscoveryTCPSyn},
+		{"rst to ack", reply(eth4, ip4(layers.IPProtocolTCP), tcp(testProbes.AckPort, false, false, true)), DiscoveryTCPAck},
+		{"other tcp", reply(eth4, ip4(layers.IPProtocolTCP), tcp(443, true, true, false)), ""},
+		{"nbns", reply(eth4, ip4(layers.IPProtocolUDP), udp(NBNSPort)), DiscoveryNBNS},
+		{"mdns", reply(eth4, ip4(layers.IPProtocolUDP), udp(MDNSPort)), DiscoveryMDNS},
+		{"mdns to someone else", reply(eth4, multicast4, udp(MDNSPort)), ""},
+	}
+
+	for _, test := range tests {
+		got, ok := ParseDiscoveryReply(test.pkt, testProbes)
+		if test.technique == "" {
+			if ok {
+				t.Fatalf("%s: unexpected reply %+v", test.name, got)
+			}
+			continue
+		}
+
+		if !ok {
+			t.Fatalf("%s: reply not recognized", test.name)
+		} else if got.Technique != test.technique {
+			t.Fatalf("%s: expected %s, got %s", test.name, test.technique, got.Technique)
+		} else if got.MAC.String() != testHostHW.String() {
+			t.Fatalf("%s: unexpected mac %s", test.name, got.MAC)
+		} else if !got.IP.Equal(testHostIP) && !got.IP.Equal(testHostIP6) {
+			t.Fatalf("%s: unexpected ip %s", test.name, got.IP)
+		}
+	}
+}
@@ -188,6 +188,22 @@ func NewDot11AssociationRequest(sta net.HardwareAddr, apBSSID net.HardwareAddr,
 	)
 }
 
+// NewDot11Frame builds a generic 802.11 frame of the given type with an
+// arbitrary body, the frame check sequence is not included.
+func NewDot11Frame(t layers.Dot11Type, a1 net.HardwareAddr, a2 net.HardwareAddr, a3 net.HardwareAddr, seq uint16, body []byte) (error, []byte) {
+	return Serialize(
+		&layers.RadioTap{},
+		&layers.Dot11{
+			Address1:       a1,
+			Address2:       a2,
+			Address3:       a3,
+			Type:           t,
+			SequenceNumber: seq,
+		},
+		gopacket.Payload(body),
+	)
+}
+
 func Dot11Parse(packet gopacket.Packet) (ok bool, radiotap *layers.RadioTap, dot11 *layers.Dot11) {
 	ok = false
 	radiotap = nil
@@ -0,0 +1,482 @@
+package packets
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/hmac"
+	"crypto/rc4"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+
+	"github.com/gopacket/gopacket/layers"
+)
+
+const (
+	Dot11PMKSize  = 32
+	Dot11PTKSize  = 64
+	Dot11TKSize   = 16
+	ccmpHdrSize   = 8
+	ccmpMICSize   = 8
+	tkipHdrSize   = 8
+	tkipMICSize   = 8
+	tkipICVSize   = 4
+	ptkLabel      = "Pairwise key expansion"
+	pmkIterations = 4096
+)
+
+var (
+	rsnOUI     = []byte{0x00, 0x0f, 0xac}
+	llcSnapHdr = []byte{0xaa, 0xaa, 0x03, 0x00, 0x00, 0x00}
+)
+
+// Dot11PMK derives the pairwise master key from a WPA passphrase and the
+// network SSID (PBKDF2-HMAC-SHA1, 4096 iterations).
+func Dot11PMK(passphrase string, ssid string) []byte {
+	prf := hmac.New(sha1.New, []byte(passphrase))
+	out := make([]byte, 0, Dot11PMKSize+sha1.Size)
+	block := make([]byte, 4)
+	for i := uint32(1); len(out) < Dot11PMKSize; i++ {
+		binary.BigEndian.PutUint32(block, i)
+
+		prf.Reset()
+		prf.Write([]byte(ssid))
+		prf.Write(block)
+		u := prf.Sum(nil)
+
+		t := make([]byte, len(u))
+		copy(t, u)
+		for n := 1; n < pmkIterations; n++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			for j := range t {
+				t[j] ^= u[j]
+			}
+		}
+		out = append(out, t...)
+	}
+	return out[:Dot11PMKSize]
+}
+
+// Dot11PRF is the 802.11i HMAC-SHA1 based pseudo random function.
+func Dot11PRF(key []byte, label string, data []byte, bits int) []byte {
+	size := bits / 8
+	out := make([]byte, 0, size+sha1.Size)
+	mac := hmac.New(sha1.New, key)
+	for i := byte(0); len(out) < size; i++ {
+		mac.Reset()
+		mac.Write([]byte(label))
+		mac.Write([]byte{0})
+		mac.Write(data)
+		mac.Write([]byte{i})
+		out = mac.Sum(out)
+	}
+	return out[:size]
+}
+
+func minMax(a []byte, b []byte) ([]byte, []byte) {
+	if bytes.Compare(a, b) < 0 {
+		return a, b
+	}
+	return b, a
+}
+
+// Dot11PTK derives the pairwise transient key from the PMK, the authenticator
+// and supplicant addresses and the two handshake nonces, KCK, KEK and TK are
+// respectively at offsets 0, 16 and 32.
+func Dot11PTK(pmk []byte, aa []byte, spa []byte, anonce []byte, snonce []byte) []byte {
+	minAddr, maxAddr := minMax(aa, spa)
+	minNonce, maxNonce := minMax(anonce, snonce)
+
+	data := make([]byte, 0, 76)
+	data = append(data, minAddr...)
+	data = append(data, maxAddr...)
+	data = append(data, minNonce...)
+	data = append(data, maxNonce...)
+
+	return Dot11PRF(pmk, ptkLabel, data, Dot11PTKSize*8)
+}
+
+// Dot11PTKTemporalKey returns the temporal key part of a PTK.
+func Dot11PTKTemporalKey(ptk []byte) []byte {
+	return ptk[32:48]
+}
+
+// Dot11AESKeyUnwrap implements the RFC 3394 key unwrap algorithm used for the
+// EAPOL key data of CCMP networks.
+func Dot11AESKeyUnwrap(kek []byte, wrapped []byte) ([]byte, error) {
+	if len(wrapped) < 24 || len(wrapped)%8 != 0 {
+		return nil, fmt.Errorf("invalid wrapped key size %d", len(wrapped))
+	}
+
+	block, err := aes.NewCipher(kek)
+	if err != nil {
+		return nil, err
+	}
+
+	n := len(wrapped)/8 - 1
+	a := make([]byte, 8)
+	copy(a, wrapped[:8])
+	r := make([]byte, n*8)
+	copy(r, wrapped[8:])
+
+	buf := make([]byte, 16)
+	for j := 5; j >= 0; j-- {
+		for i := n; i >= 1; i-- {
+			t := uint64(n*j + i)
+			binary.BigEndian.PutUint64(buf, binary.BigEndian.Uint64(a)^t)
+			copy(buf[8:], r[(i-1)*8:i*8])
+			block.Decrypt(buf, buf)
+			copy(a, buf[:8])
+			copy(r[(i-1)*8:i*8], buf[8:])
+		}
+	}
+
+	for _, b := range a {
+		if b != 0xa6 {
+			return nil, fmt.Errorf("key unwrap integrity check failed")
+		}
+	}
+	return r, nil
+}
+
+// Dot11DecryptKeyData decrypts the key data field of an EAPOL key frame, using
+// RC4 for descriptor version 1 (TKIP) and AES key wrap for version 2 (CCMP).
+func Dot11DecryptKeyData(version uint8, kek []byte, iv []byte, data []byte) ([]byte, error) {
+	switch version {
+	case 1:
+		cipher, err := rc4.NewCipher(append(append([]byte{}, iv...), kek...))
+		if err != nil {
+			return nil, err
+		}
+		skip := make([]byte, 256)
+		cipher.XORKeyStream(skip, skip)
+		plain := make([]byte, len(data))
+		cipher.XORKeyStream(plain, data)
+		return plain, nil
+	case 2:
+		return Dot11AESKeyUnwrap(kek, data)
+	}
+	return nil, fmt.Errorf("unsupported key descriptor version %d", version)
+}
+
+// Dot11ParseGTK searches the GTK KDE inside decrypted EAPOL key data.
+func Dot11ParseGTK(keyData []byte) (found bool, keyID int, gtk []byte) {
+	for off := 0; off+2 <= len(keyData); {
+		id, size := keyData[off], int(keyData[off+1])
+		if off+2+size > len(keyData) {
+			break
+		}
+		kde := keyData[off+2 : off+2+size]
+		// GTK KDE: dd <len> 00-0f-ac 01 <key id> <reserved> <gtk>
+		if id == 0xdd && size > 6 && bytes.Equal(kde[:3], rsnOUI) && kde[3] == 0x01 {
+			return true, int(kde[4] & 0x03), kde[6:]
+		}
+		off += 2 + size
+	}
+	return false, 0, nil
+}
+
+// Dot11IsProtected returns true if the frame is a data frame with the
+// protected flag set.
+func Dot11IsProtected(dot11 *layers.Dot11) bool {
+	return dot11.Type.MainType() == layers.Dot11TypeData && dot11.Flags.WEP()
+}
+
+// Dot11ProtectedKeyID returns the key index of a CCMP or TKIP protected frame.
+func Dot11ProtectedKeyID(dot11 *layers.Dot11) int {
+	if len(dot11.Payload) < 4 {
+		return 0
+	}
+	return int(dot11.Payload[3] >> 6)
+}
+
+func ccmAESBlock(key []byte) (cipherBlock func(dst, src []byte), err error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return block.Encrypt, nil
+}
+
+// ccmMAC and ccmCTR implement AES-CCM with M=8 and L=2 as used by CCMP.
+func ccmMAC(encrypt func(dst, src []byte), nonce []byte, aad []byte, plain []byte) []byte {
+	x := make([]byte, 16)
+	b := make([]byte, 16)
+
+	b[0] = 0x59
+	copy(b[1:14], nonce)
+	binary.BigEndian.PutUint16(b[14:], uint16(len(plain)))
+	encrypt(x, b)
+
+	// associated data is prefixed by its length and zero padded
+	adata := make([]byte, 2, 2+len(aad)+15)
+	binary.BigEndian.PutUint16(adata, uint16(len(aad)))
+	adata = append(adata, aad...)
+
+	for _, data := range [][]byte{adata, plain} {
+		for off := 0; off < len(data); off += 16 {
+			for i := range b {
+				b[i] = 0
+			}
+			copy(b, data[off:])
+			for i := range x {
+				x[i] ^= b[i]
+			}
+			encrypt(x, x)
+		}
+	}
+	return x[:ccmpMICSize]
+}
+
+func ccmCTR(encrypt func(dst, src []byte), nonce []byte, counter uint16, dst []byte, src []byte) {
+	a := make([]byte, 16)
+	s := make([]byte, 16)
+	a[0] = 0x01
+	copy(a[1:14], nonce)
+	for off := 0; off < len(src); off += 16 {
+		binary.BigEndian.PutUint16(a[14:], counter)
+		encrypt(s, a)
+		for i := 0; i < 16 && off+i < len(src); i++ {
+			dst[off+i] = src[off+i] ^ s[i]
+		}
+		counter++
+	}
+}
+
+func ccmEncrypt(key []byte, nonce []byte, aad []byte, plain []byte) ([]byte, error) {
+	encrypt, err := ccmAESBlock(key)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, len(plain)+ccmpMICSize)
+	ccmCTR(encrypt, nonce, 1, out, plain)
+	ccmCTR(encrypt, nonce, 0, out[len(plain):], ccmMAC(encrypt, nonce, aad, plain))
+	return out, nil
+}
+
+func ccmDecrypt(key []byte, nonce []byte, aad []byte, data []byte) ([]byte, error) {
+	if len(data) < ccmpMICSize {
+		return nil, fmt.Errorf("CCM data too short")
+	}
+	encrypt, err := ccmAESBlock(key)
+	if err != nil {
+		return nil, err
+	}
+
+	size := len(data) - ccmpMICSize
+	plain := make([]byte, size)
+	mic := make([]byte, ccmpMICSize)
+	ccmCTR(encrypt, nonce, 1, plain, data[:size])
+	ccmCTR(encrypt, nonce, 0, mic, data[size:])
+
+	if subtle.ConstantTimeCompare(mic, ccmMAC(encrypt, nonce, aad, plain)) != 1 {
+		return nil, fmt.Errorf("CCMP MIC mismatch")
+	}
+	return plain, nil
+}
+
+// ccmpAADAndNonce builds the additional authentication data and the nonce of
+// a CCMP frame from its raw header and packet number.
+func ccmpAADAndNonce(dot11 *layers.Dot11, pn []byte) (aad []byte, nonce []byte) {
+	hdr := dot11.Contents
+	aad = make([]byte, 0, 30)
+
+	// mask subtype, retry, power management and more data bits, keep protected
+	fc0 := hdr[0]
+	fc1 := (hdr[1] &^ 0x38) | 0x40
+	if dot11.QOS != nil {
+		fc0 &= 0x8f
+		fc1 &^= 0x80
+	}
+	aad = append(aad, fc0, fc1)
+	aad = append(aad, hdr[4:22]...)
+	// sequence number is masked, fragment number is not
+	aad = append(aad, hdr[22]&0x0f, 0)
+
+	priority := byte(0)
+	if dot11.Flags.ToDS() && dot11.Flags.FromDS() {
+		aad = append(aad, hdr[24:30]...)
+	}
+	if dot11.QOS != nil {
+		priority = dot11.QOS.TID
+		aad = append(aad, priority, 0)
+	}
+
+	nonce = make([]byte, 0, 13)
+	nonce = append(nonce, priority)
+	nonce = append(nonce, dot11.Address2...)
+	nonce = append(nonce, pn...)
+	return
+}
+
+func ccmpPN(hdr []byte) []byte {
+	return []byte{hdr[7], hdr[6], hdr[5], hdr[4], hdr[1], hdr[0]}
+}
+
+// Dot11DecryptCCMP decrypts the body of a CCMP protected data frame.
+func Dot11DecryptCCMP(tk []byte, dot11 *layers.Dot11) ([]byte, error) {
+	body := dot11.Payload
+	if len(body) < ccmpHdrSize+ccmpMICSize {
+		return nil, fmt.Errorf("CCMP frame too short")
+	} else if body[3]&0x20 == 0 {
+		return nil, fmt.Errorf("frame is not using extended IV")
+	}
+
+	aad, nonce := ccmpAADAndNonce(dot11, ccmpPN(body))
+	return ccmDecrypt(tk, nonce, aad, body[ccmpHdrSize:])
+}
+
+var tkipSbox = func() (sbox [256]uint16) {
+	// the TKIP S-box is derived from the AES one, each entry being
+	// the pair (2*S[i], 3*S[i]) in GF(2^8).
+	xtime := func(b byte) byte {
+		if b&0x80 != 0 {
+			return (b << 1) ^ 0x1b
+		}
+		return b << 1
+	}
+
+	var p, q byte = 1, 1
+	var aes [256]byte
+	for {
+		// p is multiplied by 3 and q divided by 3 at each step
+		p = p ^ xtime(p)
+		q ^= q << 1
+		q ^= q << 2
+		q ^= q << 4
+		if q&0x80 != 0 {
+			q ^= 0x09
+		}
+		x := q ^ (q<<1 | q>>7) ^ (q<<2 | q>>6) ^ (q<<3 | q>>5) ^ (q<<4 | q>>4)
+		aes[p] = x ^ 0x63
+		if p == 1 {
+			break
+		}
+	}
+	aes[0] = 0x63
+
+	for i := range sbox {
+		s := aes[i]
+		sbox[i] = uint16(xtime(s))<<8 | uint16(xtime(s)^s)
+	}
+	return
+}()
+
+func tkipS(v uint16) uint16 {
+	hi := tkipSbox[v>>8]
+	return tkipSbox[v&0xff] ^ (hi<<8 | hi>>8)
+}
+
+func mk16(hi byte, lo byte) uint16 {
+	return uint16(hi)<<8 | uint16(lo)
+}
+
+func rotR1(v uint16) uint16 {
+	return v>>1 | v<<15
+}
+
+// tkipMix computes the per packet RC4 key from the temporal key, the
+// transmitter address and the 48 bits TKIP sequence counter.
+func tkipMix(tk []byte, ta []byte, iv32 uint32, iv16 uint16) []byte {
+	var ttak [5]uint16
+	ttak[0] = uint16(iv32)
+	ttak[1] = uint16(iv32 >> 16)
+	ttak[2] = mk16(ta[1], ta[0])
+	ttak[3] = mk16(ta[3], ta[2])
+	ttak[4] = mk16(ta[5], ta[4])
+
+	for i := 0; i < 8; i++ {
+		j := 2 * (i & 1)
+		ttak[0] += tkipS(ttak[4] ^ mk16(tk[1+j], tk[0+j]))
+		ttak[1] += tkipS(ttak[0] ^ mk16(tk[5+j], tk[4+j]))
+		ttak[2] += tkipS(ttak[1] ^ mk16(tk[9+j], tk[8+j]))
+		ttak[3] += tkipS(ttak[2] ^ mk16(tk[13+j], tk[12+j]))
+		ttak[4] += tkipS(ttak[3]^mk16(tk[1+j], tk[0+j])) + uint16(i)
+	}
+
+	var ppk [6]uint16
+	copy(ppk[:5], ttak[:])
+	ppk[5] = ttak[4] + iv16
+
+	ppk[0] += tkipS(ppk[5] ^ mk16(tk[1], tk[0]))
+	ppk[1] += tkipS(ppk[0] ^ mk16(tk[3], tk[2]))
+	ppk[2] += tkipS(ppk[1] ^ mk16(tk[5], tk[4]))
+	ppk[3] += tkipS(ppk[2] ^ mk16(tk[7], tk[6]))
+	ppk[4] += tkipS(ppk[3] ^ mk16(tk[9], tk[8]))
+	ppk[5] += tkipS(ppk[4] ^ mk16(tk[11], tk[10]))
+	ppk[0] += rotR1(ppk[5] ^ mk16(tk[13], tk[12]))
+	ppk[1] += rotR1(ppk[0] ^ mk16(tk[15], tk[14]))
+	ppk[2] += rotR1(ppk[1])
+	ppk[3] += rotR1(ppk[2])
+	ppk[4] += rotR1(ppk[3])
+	ppk[5] += rotR1(ppk[4])
+
+	key := make([]byte, 16)
+	key[0] = byte(iv16 >> 8)
+	key[1] = (byte(iv16>>8) | 0x20) & 0x7f
+	key[2] = byte(iv16)
+	key[3] = byte((ppk[5] ^ mk16(tk[1], tk[0])) >> 1)
+	for i := 0; i < 6; i++ {
+		key[4+2*i] = byte(ppk[i])
+		key[5+2*i] = byte(ppk[i] >> 8)
+	}
+	return key
+}
+
+// Dot11DecryptTKIP decrypts the body of a TKIP protected data frame, the
+// integrity check value is verified while the Michael MIC is just stripped.
+func Dot11DecryptTKIP(tk []byte, dot11 *layers.Dot11) ([]byte, error) {
+	body := dot11.Payload
+	if len(tk) < Dot11TKSize {
+		return nil, fmt.Errorf("invalid TKIP key size %d", len(tk))
+	} else if len(body) < tkipHdrSize+tkipMICSize+tkipICVSize {
+		return nil, fmt.Errorf("TKIP frame too short")
+	} else if body[3]&0x20 == 0 {
+		return nil, fmt.Errorf("frame is not using extended IV")
+	}
+
+	iv16 := mk16(body[0], body[2])
+	iv32 := binary.LittleEndian.Uint32(body[4:8])
+
+	cipher, err := rc4.NewCipher(tkipMix(tk, dot11.Address2, iv32, iv16))
+	if err != nil {
+		return nil, err
+	}
+
+	plain := make([]byte, len(body)-tkipHdrSize)
+	cipher.XORKeyStream(plain, body[tkipHdrSize:])
+
+	size := len(plain) - tkipICVSize
+	if crc32.ChecksumIEEE(plain[:size]) != binary.LittleEndian.Uint32(plain[size:]) {
+		return nil, fmt.Errorf("TKIP ICV mismatch")
+	}
+	return plain[:size-tkipMICSize], nil
+}
+
+// Dot11ToEthernet converts a decrypted LLC/SNAP data frame body to an 802.3
+// frame using the addresses of the original 802.11 header.
+func Dot11ToEthernet(dot11 *layers.Dot11, plain []byte) (error, []byte) {
+	if len(plain) < len(llcSnapHdr)+2 || !bytes.Equal(plain[:len(llcSnapHdr)], llcSnapHdr) {
+		return fmt.Errorf("missing LLC/SNAP header"), nil
+	}
+
+	dst, src := dot11.Address1, dot11.Address2
+	switch {
+	case dot11.Flags.ToDS() && dot11.Flags.FromDS():
+		dst, src = dot11.Address3, dot11.Address4
+	case dot11.Flags.ToDS():
+		dst = dot11.Address3
+	case dot11.Flags.FromDS():
+		src = dot11.Address3
+	}
+
+	frame := make([]byte, 0, 12+len(plain)-len(llcSnapHdr))
+	frame = append(frame, dst...)
+	frame = append(frame, src...)
+	frame = append(frame, plain[len(llcSnapHdr):]...)
+	return nil, frame
+}
@@ -0,0 +1,234 @@
+package packets
+
+import (
+	"bytes"
+	"crypto/rc4"
+	"encoding/binary"
+	"encoding/hex"
+	"hash/crc32"
+	"net"
+	"testing"
+
+	"github.com/gopacket/gopacket"
+	"github.com/gopacket/gopacket/layers"
+)
+
+func unhex(t *testing.T, s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestDot11PMK(t *testing.T) {
+	// IEEE 802.11i-2004 test vector
+	exp := unhex(t, "f42c6fc52df0ebef9ebb4b90b38a5f902e83fe1b135a70e23aed762e9710a12e")
+	if got := Dot11PMK("password", "IEEE"); !bytes.Equal(got, exp) {
+		t.Fatalf("expected %x, got %x", exp, got)
+	}
+}
+
+func TestDot11PRF(t *testing.T) {
+	// IEEE 802.11i-2004 PRF test vector
+	key := bytes.Repeat([]byte{0x0b}, 20)
+	exp := unhex(t, "bcd4c650b30b9684951829e0d75f9d54b862175ed9f00606e17d8da35402ffee"+
+		"75df78c3d31e0f889f012120c0862beb67753e7439ae242edb8373698356cf5a")
+	if got := Dot11PRF(key, "prefix", []byte("Hi There"), 512); !bytes.Equal(got, exp) {
+		t.Fatalf("expected %x, got %x", exp, got)
+	}
+}
+
+func TestDot11PTKSymmetric(t *testing.T) {
+	pmk := Dot11PMK("password", "IEEE")
+	aa, _ := net.ParseMAC("00:11:22:33:44:55")
+	spa, _ := net.ParseMAC("66:77:88:99:aa:bb")
+	anonce := bytes.Repeat([]byte{0x01}, 32)
+	snonce := bytes.Repeat([]byte{0x02}, 32)
+
+	a := Dot11PTK(pmk, aa, spa, anonce, snonce)
+	b := Dot11PTK(pmk, spa, aa, snonce, anonce)
+	if len(a) != Dot11PTKSize || !bytes.Equal(a, b) {
+		t.Fatalf("PTK derivation should not depend on the order of addresses and nonces")
+	}
+}
+
+func TestDot11AESKeyUnwrap(t *testing.T) {
+	// RFC 3394 section 4.1
+	kek := unhex(t, "000102030405060708090a0b0c0d0e0f")
+	wrapped := unhex(t, "1fa68b0a8112b447aef34bd8fb5a7b829d3e862371d2cfe5")
+	exp := unhex(t, "00112233445566778899aabbccddeeff")
+
+	if got, err := Dot11AESKeyUnwrap(kek, wrapped); err != nil {
+		t.Fatal(err)
+	} else if !bytes.Equal(got, exp) {
+		t.Fatalf("expected %x, got %x", exp, got)
+	}
+
+	wrapped[0] ^= 0xff
+	if _, err := Dot11AESKeyUnwrap(kek, wrapped); err == nil {
+		t.Fatalf("expected integrity check error")
+	}
+}
+
+func TestDot11ParseGTK(t *testing.T) {
+	gtk := bytes.Repeat([]byte{0x42}, 16)
+	data := []byte{
+		0x30, 0x02, 0x01, 0x00, // RSN IE
+		0xdd, 0x16, 0x00, 0x0f, 0xac, 0x01, 0x02, 0x00,
+	}
+	data = append(data, gtk...)
+	data = append(data, 0xdd, 0x00)
+
+	found, keyID, got := Dot11ParseGTK(data)
+	if !found {
+		t.Fatal("GTK KDE not found")
+	} else if keyID != 2 {
+		t.Fatalf("expected key id 2, got %d", keyID)
+	} else if !bytes.Equal(got, gtk) {
+		t.Fatalf("expected %x, got %x", gtk, got)
+	}
+}
+
+func TestCCMEncrypt(t *testing.T) {
+	// RFC 3610 packet vector #1
+	key := unhex(t, "c0c1c2c3c4c5c6c7c8c9cacbcccdcecf")
+	nonce := unhex(t, "00000003020100a0a1a2a3a4a5")
+	aad := unhex(t, "0001020304050607")
+	plain := unhex(t, "08090a0b0c0d0e0f101112131415161718191a1b1c1d1e")
+	exp := unhex(t, "588c979a61c663d2f066d0c2c0f989806d5f6b61dac38417e8d12cfdf926e0")
+
+	got, err := ccmEncrypt(key, nonce, aad, plain)
+	if err != nil {
+		t.Fatal(err)
+	} else if !bytes.Equal(got, exp) {
+		t.Fatalf("expected %x, got %x", exp, got)
+	}
+
+	if dec, err := ccmDecrypt(key, nonce, aad, got); err != nil {
+		t.Fatal(err)
+	} else if !bytes.Equal(dec, plain) {
+		t.Fatalf("expected %x, got %x", plain, dec)
+	}
+}
+
+func TestTKIPSbox(t *testing.T) {
+	// first entries of the table in IEEE 802.11i-2004 and hostapd
+	for i, exp := range []uint16{0xc6a5, 0xf884, 0xee99, 0xf68d} {
+		if tkipSbox[i] != exp {
+			t.Fatalf("sbox[%d]: expected %04x, got %04x", i, exp, tkipSbox[i])
+		}
+	}
+	if tkipSbox[255] != 0x2c3a {
+		t.Fatalf("sbox[255]: expected 2c3a, got %04x", tkipSbox[255])
+	}
+}
+
+func TestTKIPMix(t *testing.T) {
+	// IEEE 802.11i-2004 TKIP mixing function test vector #1
+	tk := unhex(t, "000102030405060708090a0b0c0d0e0f")
+	ta := unhex(t, "102233445566")
+	exp := unhex(t, "00200033ea8d2f60ca6d1374234a660b")
+	if got := tkipMix(tk, ta, 0, 0); !bytes.Equal(got, exp) {
+		t.Fatalf("expected %x, got %x", exp, got)
+	}
+}
+
+var (
+	testDecryptAP  = net.HardwareAddr{0x00, 0x11, 0x22, 0x33, 0x44, 0x55}
+	testDecryptSTA = net.HardwareAddr{0x66, 0x77, 0x88, 0x99, 0xaa, 0xbb}
+	testDecryptDst = net.HardwareAddr{0xde, 0xad, 0xbe, 0xef, 0xde, 0xad}
+	testDecryptLLC = append(append([]byte{}, llcSnapHdr...), 0x08, 0x00, 0x45, 0x00, 0x00, 0x14)
+)
+
+// build a QoS data frame from the station to the AP with the given protected body
+func testProtectedFrame(t *testing.T, body func(hdr []byte) []byte) *layers.Dot11 {
+	hdr := []byte{0x88, 0x41, 0x00, 0x00}
+	hdr = append(hdr, testDecryptAP...)
+	hdr = append(hdr, testDecryptSTA...)
+	hdr = append(hdr, testDecryptDst...)
+	hdr = append(hdr, 0x10, 0x00, 0x05, 0x00)
+
+	raw := append(append([]byte{}, hdr...), body(hdr)...)
+	raw = append(raw, 0, 0, 0, 0)
+
+	packet := gopacket.NewPacket(raw, layers.LayerTypeDot11, gopacket.Default)
+	dot11, ok := packet.Layer(layers.LayerTypeDot11).(*layers.Dot11)
+	if !ok {
+		t.Fatal("could not parse test frame")
+	}
+	return dot11
+}
+
+func TestDot11DecryptCCMP(t *testing.T) {
+	tk := bytes.Repeat([]byte{0xaa}, 16)
+
+	dot11 := testProtectedFrame(t, func(hdr []byte) []byte {
+		ccmpHdr := []byte{0x01, 0x00, 0x00, 0x20, 0x00, 0x00, 0x00, 0x00}
+		parsed := &layers.Dot11{}
+		if err := parsed.DecodeFromBytes(append(append(append([]byte{}, hdr...), ccmpHdr...), 0, 0, 0, 0), gopacket.NilDecodeFeedback); err != nil {
+			t.Fatal(err)
+		}
+		aad, nonce := ccmpAADAndNonce(parsed, ccmpPN(ccmpHdr))
+		enc, err := ccmEncrypt(tk, nonce, aad, testDecryptLLC)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return append(ccmpHdr, enc...)
+	})
+
+	if !Dot11IsProtected(dot11) {
+		t.Fatal("frame should be protected")
+	}
+
+	plain, err := Dot11DecryptCCMP(tk, dot11)
+	if err != nil {
+		t.Fatal(err)
+	} else if !bytes.Equal(plain, testDecryptLLC) {
+		t.Fatalf("expected %x, got %x", testDecryptLLC, plain)
+	}
+
+	if _, err := Dot11DecryptCCMP(bytes.Repeat([]byte{0xbb}, 16), dot11); err == nil {
+		t.Fatal("expected MIC error with the wrong key")
+	}
+
+	if err, eth := Dot11ToEthernet(dot11, plain); err != nil {
+		t.Fatal(err)
+	} else if exp := append(append(append([]byte{}, testDecryptDst...), testDecryptSTA...), 0x08, 0x00, 0x45, 0x00, 0x00, 0x14); !bytes.Equal(eth, exp) {
+		t.Fatalf("expected %x, got %x", exp, eth)
+	}
+}
+
+func TestDot11DecryptTKIP(t *testing.T) {
+	tk := bytes.Repeat([]byte{0x11}, 16)
+	iv16 := uint16(0x0102)
+	iv32 := uint32(0x03040506)
+
+	dot11 := testProtectedFrame(t, func(hdr []byte) []byte {
+		tkipHdr := []byte{byte(iv16 >> 8), (byte(iv16>>8) | 0x20) & 0x7f, byte(iv16), 0x20, 0, 0, 0, 0}
+		binary.LittleEndian.PutUint32(tkipHdr[4:], iv32)
+
+		plain := append(append([]byte{}, testDecryptLLC...), bytes.Repeat([]byte{0xff}, tkipMICSize)...)
+		icv := make([]byte, 4)
+		binary.LittleEndian.PutUint32(icv, crc32.ChecksumIEEE(plain))
+		plain = append(plain, icv...)
+
+		cipher, err := rc4.NewCipher(tkipMix(tk, testDecryptSTA, iv32, iv16))
+		if err != nil {
+			t.Fatal(err)
+		}
+		cipher.XORKeyStream(plain, plain)
+		return append(tkipHdr, plain...)
+	})
+
+	plain, err := Dot11DecryptTKIP(tk, dot11)
+	if err != nil {
+		t.Fatal(err)
+	} else if !bytes.Equal(plain, testDecryptLLC) {
+		t.Fatalf("expected %x, got %x", testDecryptLLC, plain)
+	}
+
+	if _, err := Dot11DecryptTKIP(bytes.Repeat([]byte{0x22}, 16), dot11); err == nil {
+		t.Fatal("expected ICV error with the wrong key")
+	}
+}
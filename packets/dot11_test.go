@@ -133,6 +133,41 @@ func TestNewDot11Deauth(t *testing.T) {
 	}
 }
 
+func TestNewDot11Frame(t *testing.T) {
+	sta, _ := net.ParseMAC("de:ad:be:ef:de:ad")
+	ap, _ := net.ParseMAC("01:23:45:67:89:ab")
+	body := []byte{0x7f, 0x00, 0x50, 0xf2, 0x01}
+
+	err, raw := NewDot11Frame(layers.Dot11TypeMgmtAction, ap, sta, ap, 42, body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	packet := gopacket.NewPacket(raw, layers.LayerTypeRadioTap, gopacket.Default)
+	dot11, ok := packet.Layer(layers.LayerTypeDot11).(*layers.Dot11)
+	if !ok {
+		t.Fatal("unable to parse dot11 frame")
+	}
+
+	var units = []struct {
+		got interface{}
+		exp interface{}
+	}{
+		{dot11.Type, layers.Dot11TypeMgmtAction},
+		{dot11.Address1, ap},
+		{dot11.Address2, sta},
+		{dot11.Address3, ap},
+		{dot11.SequenceNumber, uint16(42)},
+		{raw[len(raw)-len(body):], body},
+	}
+
+	for _, u := range units {
+		if !reflect.DeepEqual(u.exp, u.got) {
+			t.Fatalf("expected '%v', got '%v'", u.exp, u.got)
+		}
+	}
+}
+
 func BuildDot11Packet() gopacket.Packet {
 	mac, _ := net.ParseMAC("00:00:00:00:00:00")
 	seq := uint16(0)
@@ -35,8 +35,10 @@ func (a Dot11CipherType) String() string {
 type Dot11AuthType uint8
 
 const (
-	Dot11AuthMgt Dot11AuthType = 1
-	Dot11AuthPsk Dot11AuthType = 2
+	Dot11AuthMgt       Dot11AuthType = 1
+	Dot11AuthPsk       Dot11AuthType = 2
+	Dot11AuthFtPsk     Dot11AuthType = 4
+	Dot11AuthPskSha256 Dot11AuthType = 6
 )
 
 func (a Dot11AuthType) String() string {
@@ -45,6 +47,10 @@ func (a Dot11AuthType) String() string {
 		return "MGT"
 	case Dot11AuthPsk:
 		return "PSK"
+	case Dot11AuthFtPsk:
+		return "FT/PSK"
+	case Dot11AuthPskSha256:
+		return "PSK-SHA256"
 	default:
 		return "UNK"
 	}
@@ -48,6 +48,8 @@ func TestDot11AuthTypes(t *testing.T) {
 	}{
 		{uint8(Dot11AuthMgt), uint8(1)},
 		{uint8(Dot11AuthPsk), uint8(2)},
+		{uint8(Dot11AuthFtPsk), uint8(4)},
+		{uint8(Dot11AuthPskSha256), uint8(6)},
 	}
 	for _, u := range units {
 		if !reflect.DeepEqual(u.exp, u.got) {
@@ -63,6 +65,8 @@ func TestDot11AuthTypesAsString(t *testing.T) {
 	}{
 		{Dot11AuthMgt.String(), "MGT"},
 		{Dot11AuthPsk.String(), "PSK"},
+		{Dot11AuthFtPsk.String(), "FT/PSK"},
+		{Dot11AuthPskSha256.String(), "PSK-SHA256"},
 	}
 	for _, u := range units {
 		if !reflect.DeepEqual(u.exp, u.got) {
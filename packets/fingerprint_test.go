@@ -0,0 +1,102 @@
+package packets
+
+import (
+	"net"
+	"testing"
+
+	"github.com/bettercap/bettercap/network"
+
+	"github.com/gopacket/gopacket"
+	"github.com/gopacket/gopacket/layers"
+)
+
+func buildFingerprintPacket(t *testing.T, ip *layers.IPv4, l ...gopacket.SerializableLayer) gopacket.Packet {
+	eth := layers.Ethernet{
+		SrcMAC:       net.HardwareAddr{0x02, 0, 0, 0, 0, 2},
+		DstMAC:       net.HardwareAddr{0x02, 0, 0, 0, 0, 1},
+		EthernetType: layers.EthernetTypeIPv4,
+	}
+	raw := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}
+	if err := gopacket.SerializeLayers(raw, opts, append([]gopacket.SerializableLayer{&eth, ip}, l...)...); err != nil {
+		t.Fatal(err)
+	}
+	return gopacket.NewPacket(raw.Bytes(), layers.LayerTypeEthernet, gopacket.Default)
+}
+
+func buildSYN(t *testing.T, ttl uint8, options []layers.TCPOption) gopacket.Packet {
+	ip := layers.IPv4{Version: 4, TTL: ttl, Protocol: layers.IPProtocolTCP, SrcIP: testAuthClient, DstIP: testAuthServer}
+	tcp := layers.TCP{SrcPort: 40000, DstPort: 443, SYN: true, Window: 64240, Options: options}
+	tcp.SetNetworkLayerForChecksum(&ip)
+	return buildFingerprintPacket(t, &ip, &tcp)
+}
+
+func TestTCPGetSignature(t *testing.T) {
+	pkt := buildSYN(t, 63, []layers.TCPOption{
+		{OptionType: layers.TCPOptionKindMSS, OptionLength: 4, OptionData: []byte{0x05, 0xb4}},
+		{OptionType: layers.TCPOptionKindSACKPermitted, OptionLength: 2},
+		{OptionType: layers.TCPOptionKindTimestamps, OptionLength: 10, OptionData: make([]byte, 8)},
+		{OptionType: layers.TCPOptionKindNop},
+		{OptionType: layers.TCPOptionKindWindowScale, OptionLength: 3, OptionData: []byte{7}},
+	})
+
+	sig, ok := TCPGetSignature(pkt)
+	if !ok {
+		t.Fatal("expected a signature")
+	}
+	exp := network.TCPSignature{TTL: 63, Window: 64240, MSS: 1460, WScale: 7, Layout: "M,S,T,N,W"}
+	if sig != exp {
+		t.Fatalf("expected %+v, got %+v", exp, sig)
+	}
+
+	if ev := FingerprintGetEvidence(pkt); ev == nil || ev.OS != "Linux" {
+		t.Fatalf("unexpected evidence %+v", ev)
+	}
+}
+
+func TestTCPGetSignatureNotSYN(t *testing.T) {
+	ip := layers.IPv4{Version: 4, TTL: 64, Protocol: layers.IPProtocolTCP, SrcIP: testAuthClient, DstIP: testAuthServer}
+	tcp := layers.TCP{SrcPort: 443, DstPort: 40000, SYN: true, ACK: true, Window: 64240}
+	tcp.SetNetworkLayerForChecksum(&ip)
+
+	if _, ok := TCPGetSignature(buildFingerprintPacket(t, &ip, &tcp)); ok {
+		t.Fatal("unexpected signature for a SYN/ACK")
+	}
+}
+
+func TestDHCPGetEvidence(t *testing.T) {
+	ip := layers.IPv4{Version: 4, TTL: 64, Protocol: layers.IPProtocolUDP, SrcIP: net.IPv4zero.To4(), DstIP: net.IPv4bcast.To4()}
+	udp := layers.UDP{SrcPort: 68, DstPort: 67}
+	udp.SetNetworkLayerForChecksum(&ip)
+	dhcp := layers.DHCPv4{
+		Operation:    layers.DHCPOpRequest,
+		HardwareType: layers.LinkTypeEthernet,
+		ClientHWAddr: net.HardwareAddr{0x02, 0, 0, 0, 0, 2},
+		Options: layers.DHCPOptions{
+			layers.NewDHCPOption(layers.DHCPOptMessageType, []byte{byte(layers.DHCPMsgTypeRequest)}),
+			layers.NewDHCPOption(layers.DHCPOptRequestIP, []byte{192, 168, 1, 23}),
+			layers.NewDHCPOption(layers.DHCPOptParamsRequest, []byte{1, 3, 6, 15, 26, 28, 51, 58, 59, 43}),
+			layers.NewDHCPOption(layers.DHCPOptEnd, nil),
+		},
+	}
+
+	address, ev := DHCPGetEvidence(buildFingerprintPacket(t, &ip, &udp, &dhcp))
+	if !address.Equal(net.IPv4(192, 168, 1, 23)) {
+		t.Fatalf("unexpected address %s", address)
+	} else if ev == nil || ev.OS != "Android" || ev.Source != network.FingerprintDHCP {
+		t.Fatalf("unexpected evidence %+v", ev)
+	}
+}
+
+func TestUserAgentGetEvidence(t *testing.T) {
+	ip := layers.IPv4{Version: 4, TTL: 64, Protocol: layers.IPProtocolTCP, SrcIP: testAuthClient, DstIP: testAuthServer}
+	tcp := layers.TCP{SrcPort: 40000, DstPort: 80, PSH: true, ACK: true, Window: 1024}
+	tcp.SetNetworkLayerForChecksum(&ip)
+	payload := gopacket.Payload("GET / HTTP/1.1\r\nHost: example.com\r\n" +
+		"User-Agent: Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X)\r\n\r\n")
+
+	ev := FingerprintGetEvidence(buildFingerprintPacket(t, &ip, &tcp, payload))
+	if ev == nil || ev.OS != "iOS" || ev.Device != "phone" || ev.Source != network.FingerprintUserAgent {
+		t.Fatalf("unexpected evidence %+v", ev)
+	}
+}
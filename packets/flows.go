@@ -0,0 +1,308 @@
+package packets
+
+import (
+	"encoding/json"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gopacket/gopacket"
+	"github.com/gopacket/gopacket/layers"
+)
+
+// once a TCP connection is closed, wait this long for the last packets
+// before expiring its flows
+const flowEndGrace = time.Duration(1) * time.Second
+
+// TCP control bits as exported by NetFlow
+const (
+	FlowTCPFin = 0x01
+	FlowTCPSyn = 0x02
+	FlowTCPRst = 0x04
+	FlowTCPPsh = 0x08
+	FlowTCPAck = 0x10
+	FlowTCPUrg = 0x20
+	FlowTCPEce = 0x40
+	FlowTCPCwr = 0x80
+)
+
+type FlowKey struct {
+	Protocol layers.IPProtocol
+	SrcIP    string
+	DstIP    string
+	SrcPort  uint16
+	DstPort  uint16
+}
+
+func (k FlowKey) Reverse() FlowKey {
+	return FlowKey{
+		Protocol: k.Protocol,
+		SrcIP:    k.DstIP,
+		DstIP:    k.SrcIP,
+		SrcPort:  k.DstPort,
+		DstPort:  k.SrcPort,
+	}
+}
+
+// Flow accounts for the packets with the same protocol, addresses and ports
+// going in one direction.
+type Flow struct {
+	Protocol  layers.IPProtocol `json:"-"`
+	Proto     string            `json:"proto"`
+	SrcIP     net.IP            `json:"src_ip"`
+	DstIP     net.IP            `json:"dst_ip"`
+	SrcPort   uint16            `json:"src_port"`
+	DstPort   uint16            `json:"dst_port"`
+	Bytes     uint64            `json:"bytes"`
+	Packets   uint64            `json:"packets"`
+	TCPFlags  uint8             `json:"tcp_flags"`
+	FirstSeen time.Time         `json:"first_seen"`
+	LastSeen  time.Time         `json:"last_seen"`
+	// application protocol as detected by net.sniff
+	App string `json:"app"`
+}
+
+func (f *Flow) IsIPv6() bool {
+	return f.SrcIP.To4() == nil
+}
+
+// FlagsString returns the TCP control bits in the nfdump format.
+func (f *Flow) FlagsString() string {
+	if f.Protocol != layers.IPProtocolTCP {
+		return ""
+	}
+	flags := []byte("CEUAPRSF")
+	for i := range flags {
+		if f.TCPFlags&(0x80>>uint(i)) == 0 {
+			flags[i] = '.'
+		}
+	}
+	return string(flags)
+}
+
+func tcpFlagsOf(tcp *layers.TCP) uint8 {
+	flags := uint8(0)
+	if tcp.FIN {
+		flags |= FlowTCPFin
+	}
+	if tcp.SYN {
+		flags |= FlowTCPSyn
+	}
+	if tcp.RST {
+		flags |= FlowTCPRst
+	}
+	if tcp.PSH {
+		flags |= FlowTCPPsh
+	}
+	if tcp.ACK {
+		flags |= FlowTCPAck
+	}
+	if tcp.URG {
+		flags |= FlowTCPUrg
+	}
+	if tcp.ECE {
+		flags |= FlowTCPEce
+	}
+	if tcp.CWR {
+		flags |= FlowTCPCwr
+	}
+	return flags
+}
+
+// flowOf returns the key of the flow the packet belongs to, its size at
+// the IP level and its TCP flags.
+func flowOf(pkt gopacket.Packet) (key FlowKey, size uint64, flags uint8, ok bool) {
+	nlayer := pkt.NetworkLayer()
+	if nlayer == nil {
+		return
+	}
+
+	switch ip := nlayer.(type) {
+	case *layers.IPv4:
+		key.SrcIP = ip.SrcIP.String()
+		key.DstIP = ip.DstIP.String()
+		key.Protocol = ip.Protocol
+	case *layers.IPv6:
+		key.SrcIP = ip.SrcIP.String()
+		key.DstIP = ip.DstIP.String()
+		key.Protocol = ip.NextHeader
+	default:
+		return
+	}
+	size = uint64(len(nlayer.LayerContents()) + len(nlayer.LayerPayload()))
+
+	if tcp, found := pkt.Layer(layers.LayerTypeTCP).(*layers.TCP); found {
+		key.Protocol = layers.IPProtocolTCP
+		key.SrcPort = uint16(tcp.SrcPort)
+		key.DstPort = uint16(tcp.DstPort)
+		flags = tcpFlagsOf(tcp)
+	} else if udp, found := pkt.Layer(layers.LayerTypeUDP).(*layers.UDP); found {
+		key.Protocol = layers.IPProtocolUDP
+		key.SrcPort = uint16(udp.SrcPort)
+		key.DstPort = uint16(udp.DstPort)
+	} else if icmp, found := pkt.Layer(layers.LayerTypeICMPv4).(*layers.ICMPv4); found {
+		// as NetFlow does, the type and code are stored as destination port
+		key.Protocol = layers.IPProtocolICMPv4
+		key.DstPort = uint16(icmp.TypeCode)
+	} else if icmp6, found := pkt.Layer(layers.LayerTypeICMPv6).(*layers.ICMPv6); found {
+		key.Protocol = layers.IPProtocolICMPv6
+		key.DstPort = uint16(icmp6.TypeCode)
+	}
+
+	ok = true
+	return
+}
+
+func newFlow(key FlowKey, now time.Time) *Flow {
+	return &Flow{
+		Protocol:  key.Protocol,
+		Proto:     strings.ToLower(key.Protocol.String()),
+		SrcIP:     net.ParseIP(key.SrcIP),
+		DstIP:     net.ParseIP(key.DstIP),
+		SrcPort:   key.SrcPort,
+		DstPort:   key.DstPort,
+		FirstSeen: now,
+		LastSeen:  now,
+	}
+}
+
+// FlowTable accounts for the traffic seen by the packets queue as flows,
+// once enabled.
+type FlowTable struct {
+	sync.Mutex
+	enabled bool
+	flows   map[FlowKey]*Flow
+}
+
+func NewFlowTable() *FlowTable {
+	return &FlowTable{
+		flows: make(map[FlowKey]*Flow),
+	}
+}
+
+func (t *FlowTable) Enable(enabled bool) {
+	t.Lock()
+	defer t.Unlock()
+
+	t.enabled = enabled
+	if !enabled {
+		t.flows = make(map[FlowKey]*Flow)
+	}
+}
+
+func (t *FlowTable) Enabled() bool {
+	t.Lock()
+	defer t.Unlock()
+	return t.enabled
+}
+
+func (t *FlowTable) Track(pkt gopacket.Packet) {
+	t.Lock()
+	defer t.Unlock()
+
+	if !t.enabled {
+		return
+	}
+
+	key, size, flags, ok := flowOf(pkt)
+	if !ok {
+		return
+	}
+
+	now := pkt.Metadata().Timestamp
+	if now.IsZero() {
+		now = time.Now()
+	}
+
+	flow, found := t.flows[key]
+	if !found {
+		flow = newFlow(key, now)
+		t.flows[key] = flow
+	}
+
+	flow.Bytes += size
+	flow.Packets++
+	flow.TCPFlags |= flags
+	flow.LastSeen = now
+}
+
+// Label sets the application protocol of the flow of the packet, and of the
+// one in the opposite direction.
+func (t *FlowTable) Label(pkt gopacket.Packet, app string) {
+	t.Lock()
+	defer t.Unlock()
+
+	if !t.enabled {
+		return
+	}
+
+	key, _, _, ok := flowOf(pkt)
+	if !ok {
+		return
+	}
+
+	flow, found := t.flows[key]
+	if !found {
+		// the sniffer can be a little ahead of the queue
+		flow = newFlow(key, time.Now())
+		t.flows[key] = flow
+	}
+	flow.App = app
+
+	if reverse, found := t.flows[key.Reverse()]; found && reverse.App == "" {
+		reverse.App = app
+	}
+}
+
+// Expire removes and returns the flows that have been idle for longer than
+// the inactive timeout, the ones lasting longer than the active timeout and
+// the closed TCP connections.
+func (t *FlowTable) Expire(now time.Time, active, inactive time.Duration) []*Flow {
+	t.Lock()
+	defer t.Unlock()
+
+	expired := make([]*Flow, 0)
+	for key, flow := range t.flows {
+		idle := now.Sub(flow.LastSeen)
+		closed := flow.TCPFlags&(FlowTCPFin|FlowTCPRst) != 0 && idle >= flowEndGrace
+		if idle >= inactive || now.Sub(flow.FirstSeen) >= active || closed {
+			expired = append(expired, flow)
+			delete(t.flows, key)
+		}
+	}
+	return expired
+}
+
+// Flush removes and returns all the flows.
+func (t *FlowTable) Flush() []*Flow {
+	t.Lock()
+	defer t.Unlock()
+
+	flows := make([]*Flow, 0, len(t.flows))
+	for _, flow := range t.flows {
+		flows = append(flows, flow)
+	}
+	t.flows = make(map[FlowKey]*Flow)
+	return flows
+}
+
+// List returns a copy of the current flows, sorted by bytes.
+func (t *FlowTable) List() []Flow {
+	t.Lock()
+	defer t.Unlock()
+
+	flows := make([]Flow, 0, len(t.flows))
+	for _, flow := range t.flows {
+		flows = append(flows, *flow)
+	}
+	sort.Slice(flows, func(i, j int) bool {
+		return flows[i].Bytes > flows[j].Bytes
+	})
+	return flows
+}
+
+func (t *FlowTable) MarshalJSON() ([]byte, error) {
+	return json.Marshal(t.List())
+}
@@ -0,0 +1,103 @@
+package packets
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/gopacket/gopacket"
+	"github.com/gopacket/gopacket/layers"
+)
+
+var (
+	testFlowClient = net.IPv4(192, 168, 1, 10).To4()
+	testFlowServer = net.IPv4(93, 184, 216, 34).To4()
+)
+
+func buildFlowPacket(t *testing.T, when time.Time, from, to net.IP, tcp layers.TCP, payload string) gopacket.Packet {
+	eth := layers.Ethernet{
+		SrcMAC:       net.HardwareAddr{0x02, 0, 0, 0, 0, 1},
+		DstMAC:       net.HardwareAddr{0x02, 0, 0, 0, 0, 2},
+		EthernetType: layers.EthernetTypeIPv4,
+	}
+	ip := layers.IPv4{Version: 4, TTL: 64, Protocol: layers.IPProtocolTCP, SrcIP: from, DstIP: to}
+	tcp.SetNetworkLayerForChecksum(&ip)
+
+	err, raw := Serialize(&eth, &ip, &tcp, gopacket.Payload(payload))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkt := gopacket.NewPacket(raw, layers.LayerTypeEthernet, gopacket.Default)
+	pkt.Metadata().Timestamp = when
+	return pkt
+}
+
+func TestFlowTableTrack(t *testing.T) {
+	table := NewFlowTable()
+	start := time.Now()
+
+	syn := buildFlowPacket(t, start, testFlowClient, testFlowServer, layers.TCP{SrcPort: 40000, DstPort: 80, SYN: true}, "")
+	table.Track(syn)
+	if len(table.List()) != 0 {
+		t.Fatal("packets tracked while disabled")
+	}
+
+	table.Enable(true)
+	table.Track(syn)
+	table.Track(buildFlowPacket(t, start.Add(time.Second), testFlowClient, testFlowServer, layers.TCP{SrcPort: 40000, DstPort: 80, ACK: true, PSH: true}, "GET / HTTP/1.1\r\n\r\n"))
+	table.Track(buildFlowPacket(t, start.Add(time.Second), testFlowServer, testFlowClient, layers.TCP{SrcPort: 80, DstPort: 40000, SYN: true, ACK: true}, ""))
+
+	flows := table.List()
+	if len(flows) != 2 {
+		t.Fatalf("expected 2 flows, got %d", len(flows))
+	}
+
+	out := flows[0]
+	if out.Proto != "tcp" || !out.SrcIP.Equal(testFlowClient) || out.DstPort != 80 {
+		t.Fatalf("unexpected flow %+v", out)
+	} else if out.Packets != 2 || out.Bytes != 40+58 {
+		t.Fatalf("unexpected counters %d packets %d bytes", out.Packets, out.Bytes)
+	} else if out.TCPFlags != FlowTCPSyn|FlowTCPAck|FlowTCPPsh || out.FlagsString() != "...AP.S." {
+		t.Fatalf("unexpected flags %s", out.FlagsString())
+	} else if !out.FirstSeen.Equal(start) || !out.LastSeen.Equal(start.Add(time.Second)) {
+		t.Fatalf("unexpected times %v %v", out.FirstSeen, out.LastSeen)
+	}
+
+	table.Label(syn, "http")
+	for _, f := range table.List() {
+		if f.App != "http" {
+			t.Fatalf("flow %+v not labeled", f)
+		}
+	}
+}
+
+func TestFlowTableExpire(t *testing.T) {
+	table := NewFlowTable()
+	table.Enable(true)
+	start := time.Now()
+
+	table.Track(buildFlowPacket(t, start, testFlowClient, testFlowServer, layers.TCP{SrcPort: 40000, DstPort: 22, ACK: true}, ""))
+	table.Track(buildFlowPacket(t, start.Add(10*time.Second), testFlowClient, testFlowServer, layers.TCP{SrcPort: 40001, DstPort: 22, ACK: true}, ""))
+	table.Track(buildFlowPacket(t, start.Add(10*time.Second), testFlowClient, testFlowServer, layers.TCP{SrcPort: 40002, DstPort: 22, FIN: true, ACK: true}, ""))
+
+	// the first one is idle, the third one has been closed
+	expired := table.Expire(start.Add(12*time.Second), time.Minute, 5*time.Second)
+	if len(expired) != 2 {
+		t.Fatalf("expected 2 expired flows, got %d", len(expired))
+	}
+	for _, f := range expired {
+		if f.SrcPort == 40001 {
+			t.Fatalf("unexpected expired flow %+v", f)
+		}
+	}
+
+	// the remaining one lasted longer than the active timeout
+	table.Track(buildFlowPacket(t, start.Add(70*time.Second), testFlowClient, testFlowServer, layers.TCP{SrcPort: 40001, DstPort: 22, ACK: true}, ""))
+	if expired = table.Expire(start.Add(71*time.Second), time.Minute, 5*time.Second); len(expired) != 1 {
+		t.Fatalf("expected 1 expired flow, got %d", len(expired))
+	}
+
+	if len(table.Flush()) != 0 {
+		t.Fatal("unexpected flows left")
+	}
+}
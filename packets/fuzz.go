@@ -0,0 +1,398 @@
+package packets
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/bits"
+	"math/rand"
+
+	"github.com/gopacket/gopacket"
+	"github.com/gopacket/gopacket/layers"
+)
+
+type FuzzFieldKind int
+
+const (
+	// a generic number, mutated to its boundary values
+	FuzzInt FuzzFieldKind = iota
+	// the length or count of something else in the packet
+	FuzzLength
+	// a checksum, when not mutated it's fixed up after the other fields
+	FuzzChecksum
+	// a value out of a set of known ones
+	FuzzEnum
+	// a bit field
+	FuzzFlags
+	// variable content whose bytes are mutated individually
+	FuzzData
+)
+
+// FuzzField describes a field of a layer, Size is in bytes and -1 means
+// up to the end of the layer, Mask selects the bits of sub byte fields.
+type FuzzField struct {
+	Name   string
+	Offset int
+	Size   int
+	Mask   uint32
+	Kind   FuzzFieldKind
+	Values []uint32
+}
+
+// FuzzMutation describes a change made to a packet, for data fields Old
+// and New are the size of the field and the number of bytes changed.
+type FuzzMutation struct {
+	Layer string
+	Field string
+	Kind  FuzzFieldKind
+	Old   uint32
+	New   uint32
+}
+
+func (m FuzzMutation) String() string {
+	if m.Kind == FuzzData {
+		return fmt.Sprintf("%s.%s: %d/%d bytes", m.Layer, m.Field, m.New, m.Old)
+	}
+	return fmt.Sprintf("%s.%s: 0x%x -> 0x%x", m.Layer, m.Field, m.Old, m.New)
+}
+
+var FuzzFields = map[gopacket.LayerType][]FuzzField{
+	layers.LayerTypeEthernet: {
+		{Name: "type", Offset: 12, Size: 2, Kind: FuzzEnum, Values: []uint32{0x0800, 0x0806, 0x86dd, 0x8100, 0x888e}},
+	},
+	layers.LayerTypeARP: {
+		{Name: "htype", Offset: 0, Size: 2, Kind: FuzzEnum, Values: []uint32{1, 6}},
+		{Name: "ptype", Offset: 2, Size: 2, Kind: FuzzEnum, Values: []uint32{0x0800, 0x86dd}},
+		{Name: "hlen", Offset: 4, Size: 1, Kind: FuzzLength},
+		{Name: "plen", Offset: 5, Size: 1, Kind: FuzzLength},
+		{Name: "op", Offset: 6, Size: 2, Kind: FuzzEnum, Values: []uint32{1, 2, 3, 4}},
+		{Name: "addresses", Offset: 8, Size: -1, Kind: FuzzData},
+	},
+	layers.LayerTypeIPv4: {
+		{Name: "version", Offset: 0, Size: 1, Mask: 0xf0, Kind: FuzzEnum, Values: []uint32{4}},
+		{Name: "ihl", Offset: 0, Size: 1, Mask: 0x0f, Kind: FuzzLength},
+		{Name: "tos", Offset: 1, Size: 1, Kind: FuzzFlags},
+		{Name: "length", Offset: 2, Size: 2, Kind: FuzzLength},
+		{Name: "id", Offset: 4, Size: 2, Kind: FuzzInt},
+		{Name: "flags", Offset: 6, Size: 2, Mask: 0xe000, Kind: FuzzFlags},
+		{Name: "fragoffset", Offset: 6, Size: 2, Mask: 0x1fff, Kind: FuzzInt},
+		{Name: "ttl", Offset: 8, Size: 1, Kind: FuzzInt},
+		{Name: "protocol", Offset: 9, Size: 1, Kind: FuzzEnum, Values: []uint32{1, 2, 6, 17, 41, 47, 50, 58, 132}},
+		{Name: "checksum", Offset: 10, Size: 2, Kind: FuzzChecksum},
+	},
+	layers.LayerTypeIPv6: {
+		{Name: "version", Offset: 0, Size: 1, Mask: 0xf0, Kind: FuzzEnum, Values: []uint32{6}},
+		{Name: "class", Offset: 0, Size: 2, Mask: 0x0ff0, Kind: FuzzFlags},
+		{Name: "flow", Offset: 1, Size: 3, Mask: 0x0fffff, Kind: FuzzInt},
+		{Name: "length", Offset: 4, Size: 2, Kind: FuzzLength},
+		{Name: "next", Offset: 6, Size: 1, Kind: FuzzEnum, Values: []uint32{0, 6, 17, 43, 44, 58, 59, 60}},
+		{Name: "hoplimit", Offset: 7, Size: 1, Kind: FuzzInt},
+	},
+	layers.LayerTypeICMPv4: {
+		{Name: "type", Offset: 0, Size: 1, Kind: FuzzEnum, Values: []uint32{0, 3, 4, 5, 8, 11, 12, 13, 14}},
+		{Name: "code", Offset: 1, Size: 1, Kind: FuzzEnum, Values: []uint32{0, 1, 2, 3, 4, 5}},
+		{Name: "checksum", Offset: 2, Size: 2, Kind: FuzzChecksum},
+		{Name: "id", Offset: 4, Size: 2, Kind: FuzzInt},
+		{Name: "seq", Offset: 6, Size: 2, Kind: FuzzInt},
+	},
+	layers.LayerTypeICMPv6: {
+		{Name: "type", Offset: 0, Size: 1, Kind: FuzzEnum, Values: []uint32{1, 2, 3, 4, 128, 129, 133, 134, 135, 136, 137}},
+		{Name: "code", Offset: 1, Size: 1, Kind: FuzzInt},
+		{Name: "checksum", Offset: 2, Size: 2, Kind: FuzzChecksum},
+	},
+	layers.LayerTypeTCP: {
+		{Name: "srcport", Offset: 0, Size: 2, Kind: FuzzInt},
+		{Name: "dstport", Offset: 2, Size: 2, Kind: FuzzInt},
+		{Name: "seq", Offset: 4, Size: 4, Kind: FuzzInt},
+		{Name: "ack", Offset: 8, Size: 4, Kind: FuzzInt},
+		{Name: "dataoffset", Offset: 12, Size: 1, Mask: 0xf0, Kind: FuzzLength},
+		{Name: "flags", Offset: 12, Size: 2, Mask: 0x01ff, Kind: FuzzFlags},
+		{Name: "window", Offset: 14, Size: 2, Kind: FuzzInt},
+		{Name: "checksum", Offset: 16, Size: 2, Kind: FuzzChecksum},
+		{Name: "urgent", Offset: 18, Size: 2, Kind: FuzzInt},
+		{Name: "options", Offset: 20, Size: -1, Kind: FuzzData},
+	},
+	layers.LayerTypeUDP: {
+		{Name: "srcport", Offset: 0, Size: 2, Kind: FuzzInt},
+		{Name: "dstport", Offset: 2, Size: 2, Kind: FuzzInt},
+		{Name: "length", Offset: 4, Size: 2, Kind: FuzzLength},
+		{Name: "checksum", Offset: 6, Size: 2, Kind: FuzzChecksum},
+	},
+	layers.LayerTypeDNS: {
+		{Name: "id", Offset: 0, Size: 2, Kind: FuzzInt},
+		{Name: "flags", Offset: 2, Size: 2, Kind: FuzzFlags},
+		{Name: "qdcount", Offset: 4, Size: 2, Kind: FuzzLength},
+		{Name: "ancount", Offset: 6, Size: 2, Kind: FuzzLength},
+		{Name: "nscount", Offset: 8, Size: 2, Kind: FuzzLength},
+		{Name: "arcount", Offset: 10, Size: 2, Kind: FuzzLength},
+		{Name: "records", Offset: 12, Size: -1, Kind: FuzzData},
+	},
+}
+
+// the fields of the layers without a description
+var fuzzRawFields = []FuzzField{
+	{Name: "data", Offset: 0, Size: -1, Kind: FuzzData},
+}
+
+var FuzzByteMutators = []func(byte) byte{
+	func(b byte) byte {
+		return byte(rand.Intn(256) & 0xff)
+	},
+	func(b byte) byte {
+		return byte(b << uint(rand.Intn(9)))
+	},
+	func(b byte) byte {
+		return byte(b >> uint(rand.Intn(9)))
+	},
+}
+
+func (f FuzzField) mask() uint32 {
+	if f.Mask != 0 {
+		return f.Mask
+	}
+	return uint32(1<<uint(8*f.Size)) - 1
+}
+
+func (f FuzzField) get(data []byte) uint32 {
+	raw := uint32(0)
+	for _, b := range data[f.Offset : f.Offset+f.Size] {
+		raw = raw<<8 | uint32(b)
+	}
+	mask := f.mask()
+	return (raw & mask) >> uint(bits.TrailingZeros32(mask))
+}
+
+func (f FuzzField) set(data []byte, v uint32) {
+	raw := uint32(0)
+	for _, b := range data[f.Offset : f.Offset+f.Size] {
+		raw = raw<<8 | uint32(b)
+	}
+	mask := f.mask()
+	raw = raw&^mask | (v<<uint(bits.TrailingZeros32(mask)))&mask
+	for i := f.Size - 1; i >= 0; i-- {
+		data[f.Offset+i] = byte(raw)
+		raw >>= 8
+	}
+}
+
+// mutate returns a new value for the field, biased towards the ones most
+// likely to break the parsers.
+func (f FuzzField) mutate(v uint32) uint32 {
+	mask := f.mask()
+	max := mask >> uint(bits.TrailingZeros32(mask))
+
+	var candidates []uint32
+	switch f.Kind {
+	case FuzzLength:
+		candidates = []uint32{0, 1, v - 1, v + 1, v * 2, max, max - 1}
+	case FuzzEnum:
+		for _, known := range f.Values {
+			if known != v {
+				candidates = append(candidates, known)
+			}
+		}
+		// sometimes a value nobody expects
+		if len(candidates) == 0 || rand.Intn(3) == 0 {
+			candidates = []uint32{uint32(rand.Int63()) & max}
+		}
+	case FuzzFlags:
+		candidates = []uint32{0, max, v ^ (1 << uint(rand.Intn(bits.Len32(max))))}
+	case FuzzChecksum:
+		candidates = []uint32{0, max, v ^ 1, uint32(rand.Int63()) & max}
+	default:
+		candidates = []uint32{0, 1, max, max >> 1, max>>1 + 1, v - 1, v + 1, uint32(rand.Int63()) & max}
+	}
+
+	for tries := 0; tries < 8; tries++ {
+		if n := candidates[rand.Intn(len(candidates))] & max; n != v {
+			return n
+		}
+	}
+	return v ^ 1
+}
+
+func fuzzBytes(data []byte, ratio float64) int {
+	changes := 0
+	for off, b := range data {
+		if rand.Float64() > ratio {
+			continue
+		}
+		if n := FuzzByteMutators[rand.Intn(len(FuzzByteMutators))](b); n != b {
+			data[off] = n
+			changes++
+		}
+	}
+	return changes
+}
+
+// FuzzPacket returns a mutated copy of the packet, changing each field of
+// the layers with one of the given names with the given probability, and
+// the mutations made. The lengths are only changed on purpose, while the
+// checksums are fixed up unless they have been mutated themselves.
+func FuzzPacket(pkt gopacket.Packet, layerNames []string, ratio float64) ([]byte, []FuzzMutation) {
+	data := append([]byte{}, pkt.Data()...)
+	mutations := []FuzzMutation{}
+	brokenChecksums := make(map[int]bool)
+
+	offset := 0
+	for idx, layer := range pkt.Layers() {
+		start := offset
+		size := len(layer.LayerContents())
+		offset += size
+
+		name := layer.LayerType().String()
+		selected := false
+		for _, layerName := range layerNames {
+			if layerName == name {
+				selected = true
+				break
+			}
+		}
+		if !selected || size == 0 || start+size > len(data) {
+			continue
+		}
+
+		contents := data[start : start+size]
+		fields, found := FuzzFields[layer.LayerType()]
+		if !found {
+			fields = fuzzRawFields
+		}
+
+		for _, f := range fields {
+			if f.Kind == FuzzData {
+				if f.Offset >= size {
+					continue
+				}
+				end := size
+				if f.Size >= 0 && f.Offset+f.Size < size {
+					end = f.Offset + f.Size
+				}
+				if changes := fuzzBytes(contents[f.Offset:end], ratio); changes > 0 {
+					mutations = append(mutations, FuzzMutation{
+						Layer: name,
+						Field: f.Name,
+						Kind:  f.Kind,
+						Old:   uint32(end - f.Offset),
+						New:   uint32(changes),
+					})
+				}
+				continue
+			}
+
+			if f.Offset+f.Size > size || rand.Float64() > ratio {
+				continue
+			}
+
+			old := f.get(contents)
+			f.set(contents, f.mutate(old))
+			mutations = append(mutations, FuzzMutation{
+				Layer: name,
+				Field: f.Name,
+				Kind:  f.Kind,
+				Old:   old,
+				New:   f.get(contents),
+			})
+			if f.Kind == FuzzChecksum {
+				brokenChecksums[idx] = true
+			}
+		}
+	}
+
+	if len(mutations) == 0 {
+		return nil, nil
+	}
+
+	FuzzFixChecksums(pkt, data, brokenChecksums)
+
+	return data, mutations
+}
+
+func checksumAdd(sum uint32, data []byte) uint32 {
+	for i := 0; i+1 < len(data); i += 2 {
+		sum += uint32(data[i])<<8 | uint32(data[i+1])
+	}
+	if len(data)%2 == 1 {
+		sum += uint32(data[len(data)-1]) << 8
+	}
+	return sum
+}
+
+func checksumFold(sum uint32) uint16 {
+	for sum > 0xffff {
+		sum = (sum >> 16) + (sum & 0xffff)
+	}
+	return ^uint16(sum)
+}
+
+// FuzzFixChecksums recomputes in data, a modified copy of the packet, the
+// IPv4, TCP, UDP and ICMP checksums of every layer but the skipped ones.
+func FuzzFixChecksums(pkt gopacket.Packet, data []byte, skip map[int]bool) {
+	var pseudo []byte
+
+	offset := 0
+	for idx, layer := range pkt.Layers() {
+		start := offset
+		size := len(layer.LayerContents())
+		offset += size
+
+		end := start + size + len(layer.LayerPayload())
+		if end > len(data) {
+			end = len(data)
+		}
+		if start+size > len(data) {
+			break
+		}
+
+		switch l := layer.(type) {
+		case *layers.IPv4:
+			pseudo = make([]byte, 12)
+			copy(pseudo[0:4], l.SrcIP.To4())
+			copy(pseudo[4:8], l.DstIP.To4())
+			if size >= 20 && !skip[idx] {
+				header := data[start : start+size]
+				header[10], header[11] = 0, 0
+				binary.BigEndian.PutUint16(header[10:], checksumFold(checksumAdd(0, header)))
+			}
+		case *layers.IPv6:
+			pseudo = make([]byte, 40)
+			copy(pseudo[0:16], l.SrcIP.To16())
+			copy(pseudo[16:32], l.DstIP.To16())
+		case *layers.TCP:
+			fixTransportChecksum(data[start:end], 16, pseudo, 6, skip[idx])
+		case *layers.UDP:
+			// a zero checksum means none over IPv4
+			if !(len(pseudo) == 12 && l.Checksum == 0) {
+				fixTransportChecksum(data[start:end], 6, pseudo, 17, skip[idx])
+			}
+		case *layers.ICMPv4:
+			if size >= 4 && !skip[idx] {
+				message := data[start:end]
+				message[2], message[3] = 0, 0
+				binary.BigEndian.PutUint16(message[2:], checksumFold(checksumAdd(0, message)))
+			}
+		case *layers.ICMPv6:
+			fixTransportChecksum(data[start:end], 2, pseudo, 58, skip[idx])
+		}
+	}
+}
+
+func fixTransportChecksum(segment []byte, at int, pseudo []byte, proto byte, skip bool) {
+	if skip || pseudo == nil || len(segment) < at+2 {
+		return
+	}
+
+	header := append([]byte{}, pseudo...)
+	if len(header) == 12 {
+		header[9] = proto
+		binary.BigEndian.PutUint16(header[10:], uint16(len(segment)))
+	} else {
+		binary.BigEndian.PutUint32(header[32:], uint32(len(segment)))
+		header[39] = proto
+	}
+
+	segment[at], segment[at+1] = 0, 0
+	sum := checksumFold(checksumAdd(checksumAdd(0, header), segment))
+	if sum == 0 && proto == 17 {
+		sum = 0xffff
+	}
+	binary.BigEndian.PutUint16(segment[at:], sum)
+}
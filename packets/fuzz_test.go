@@ -0,0 +1,150 @@
+package packets
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+
+	"github.com/gopacket/gopacket"
+	"github.com/gopacket/gopacket/layers"
+)
+
+var (
+	testFuzzSrc = net.IPv4(192, 168, 1, 2).To4()
+	testFuzzDst = net.IPv4(192, 168, 1, 3).To4()
+)
+
+func buildFuzzPacket(t *testing.T, l ...gopacket.SerializableLayer) gopacket.Packet {
+	eth := layers.Ethernet{
+		SrcMAC:       net.HardwareAddr{0x02, 0, 0, 0, 0, 2},
+		DstMAC:       net.HardwareAddr{0x02, 0, 0, 0, 0, 3},
+		EthernetType: layers.EthernetTypeIPv4,
+	}
+	err, raw := Serialize(append([]gopacket.SerializableLayer{&eth}, l...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return gopacket.NewPacket(raw, layers.LayerTypeEthernet, gopacket.Default)
+}
+
+func buildFuzzTCP(t *testing.T) gopacket.Packet {
+	ip := layers.IPv4{Version: 4, TTL: 64, Protocol: layers.IPProtocolTCP, SrcIP: testFuzzSrc, DstIP: testFuzzDst}
+	tcp := layers.TCP{SrcPort: 40000, DstPort: 80, PSH: true, ACK: true, Seq: 1234, Window: 1024}
+	tcp.SetNetworkLayerForChecksum(&ip)
+	return buildFuzzPacket(t, &ip, &tcp, gopacket.Payload("GET / HTTP/1.1\r\nHost: example.com\r\n\r\n"))
+}
+
+// verifies the checksums of data by summing them with the covered bytes
+func checkFuzzChecksums(t *testing.T, data []byte, withPseudoHeader bool) {
+	ipStart := 14
+	ihl := int(data[ipStart]&0x0f) * 4
+	if sum := checksumFold(checksumAdd(0, data[ipStart:ipStart+ihl])); sum != 0 {
+		t.Fatalf("invalid IPv4 checksum")
+	}
+
+	segment := data[ipStart+ihl:]
+	pseudo := []byte{}
+	if withPseudoHeader {
+		pseudo = make([]byte, 12)
+		copy(pseudo[0:8], data[ipStart+12:ipStart+20])
+		pseudo[9] = data[ipStart+9]
+		binary.BigEndian.PutUint16(pseudo[10:], uint16(len(segment)))
+	}
+	if sum := checksumFold(checksumAdd(checksumAdd(0, pseudo), segment)); sum != 0 {
+		t.Fatalf("invalid transport checksum")
+	}
+}
+
+func TestFuzzFieldMask(t *testing.T) {
+	data := []byte{0x45, 0x00, 0x50, 0x18}
+
+	ihl := FuzzField{Offset: 0, Size: 1, Mask: 0x0f}
+	if v := ihl.get(data); v != 5 {
+		t.Fatalf("expected 5, got %d", v)
+	}
+	ihl.set(data, 0xf)
+	if data[0] != 0x4f {
+		t.Fatalf("unexpected byte 0x%x", data[0])
+	}
+
+	flags := FuzzField{Offset: 2, Size: 2, Mask: 0x01ff}
+	if v := flags.get(data); v != 0x18 {
+		t.Fatalf("expected 0x18, got 0x%x", v)
+	}
+	flags.set(data, 0x1ff)
+	if data[2] != 0x51 || data[3] != 0xff {
+		t.Fatalf("unexpected bytes %x", data[2:])
+	}
+
+	port := FuzzField{Offset: 0, Size: 4}
+	if port.mask() != 0xffffffff {
+		t.Fatalf("unexpected mask 0x%x", port.mask())
+	}
+}
+
+func TestFuzzFieldMutate(t *testing.T) {
+	enum := FuzzField{Size: 1, Kind: FuzzEnum, Values: []uint32{6, 17}}
+	length := FuzzField{Size: 1, Mask: 0x0f, Kind: FuzzLength}
+	for i := 0; i < 100; i++ {
+		if v := enum.mutate(6); v == 6 || v > 0xff {
+			t.Fatalf("unexpected enum value %d", v)
+		}
+		if v := length.mutate(5); v == 5 || v > 0x0f {
+			t.Fatalf("unexpected length value %d", v)
+		}
+	}
+}
+
+func TestFuzzPacketFixesChecksums(t *testing.T) {
+	pkt := buildFuzzTCP(t)
+
+	for i := 0; i < 50; i++ {
+		data, mutations := FuzzPacket(pkt, []string{"IPv4", "TCP", "Payload"}, 0.3)
+		if data == nil {
+			continue
+		} else if len(data) != len(pkt.Data()) {
+			t.Fatalf("size changed from %d to %d", len(pkt.Data()), len(data))
+		}
+
+		broken := false
+		for _, m := range mutations {
+			if m.Kind == FuzzChecksum || m.Field == "ihl" || m.Field == "protocol" {
+				broken = true
+			}
+		}
+		if !broken {
+			checkFuzzChecksums(t, data, true)
+		}
+	}
+}
+
+func TestFuzzPacketLeavesOriginal(t *testing.T) {
+	pkt := buildFuzzTCP(t)
+	orig := append([]byte{}, pkt.Data()...)
+
+	data, mutations := FuzzPacket(pkt, []string{"Payload"}, 1.0)
+	if data == nil || len(mutations) != 1 || mutations[0].Layer != "Payload" {
+		t.Fatalf("unexpected mutations %v", mutations)
+	} else if string(pkt.Data()) != string(orig) {
+		t.Fatal("the original packet has been changed")
+	}
+	checkFuzzChecksums(t, data, true)
+
+	if data, mutations = FuzzPacket(pkt, []string{"DNS"}, 1.0); data != nil || mutations != nil {
+		t.Fatalf("unexpected mutations %v", mutations)
+	}
+}
+
+func TestNewICMPEcho(t *testing.T) {
+	err, raw := NewICMPEcho(testFuzzSrc, net.HardwareAddr{0x02, 0, 0, 0, 0, 2}, testFuzzDst, net.HardwareAddr{0x02, 0, 0, 0, 0, 3}, 1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pkt := gopacket.NewPacket(raw, layers.LayerTypeEthernet, gopacket.Default)
+	icmp, ok := pkt.Layer(layers.LayerTypeICMPv4).(*layers.ICMPv4)
+	if !ok || icmp.TypeCode.Type() != layers.ICMPv4TypeEchoRequest || icmp.Id != 1 || icmp.Seq != 2 {
+		t.Fatalf("unexpected layer %+v", icmp)
+	}
+	checkFuzzChecksums(t, raw, false)
+}
@@ -0,0 +1,146 @@
+package packets
+
+import (
+	"fmt"
+)
+
+// Over the air payloads of nRF24 based wireless keyboards and mice, as
+// documented by the MouseJack research.
+
+const (
+	HIDLogitechKeystroke          = 0xc1
+	HIDLogitechMouse              = 0xc2
+	HIDLogitechEncryptedKeystroke = 0xd3
+	HIDLogitechKeepAlive          = 0x40
+
+	HIDMicrosoftKeystroke = 0x40
+
+	// mouse buttons
+	HIDMouseLeft   = 1
+	HIDMouseRight  = 2
+	HIDMouseMiddle = 4
+
+	// limits of the relative movement of a single Logitech mouse frame
+	HIDLogitechMouseMin = -2048
+	HIDLogitechMouseMax = 2047
+)
+
+var (
+	HIDLogitechHelloData     = []byte{0x00, 0x4F, 0x00, 0x04, 0xB0, 0x10, 0x00, 0x00, 0x00, 0xED}
+	HIDLogitechKeepAliveData = []byte{0x00, 0x40, 0x04, 0xB0, 0x0C}
+)
+
+// HIDLogitechChecksum returns the checksum of a Logitech frame, computed
+// on every byte but the last one.
+func HIDLogitechChecksum(data []byte) byte {
+	sum := byte(0xff)
+	for i := 0; i < len(data)-1; i++ {
+		sum -= data[i]
+	}
+	return sum + 1
+}
+
+// NewHIDLogitechKeystroke creates an unencrypted Logitech keystroke frame
+// with up to 6 pressed keys.
+func NewHIDLogitechKeystroke(mode byte, keys ...byte) (error, []byte) {
+	if len(keys) > 6 {
+		return fmt.Errorf("a keystroke frame can contain at most 6 keys, got %d", len(keys)), nil
+	}
+
+	data := []byte{0x00, HIDLogitechKeystroke, mode, 0, 0, 0, 0, 0, 0, 0}
+	copy(data[3:9], keys)
+	data[9] = HIDLogitechChecksum(data)
+
+	return nil, data
+}
+
+// NewHIDLogitechMouse creates a Logitech mouse frame with the state of the
+// buttons, a relative movement and the vertical and horizontal wheel motion.
+func NewHIDLogitechMouse(buttons byte, x int, y int, wheelV int, wheelH int) (error, []byte) {
+	if x < HIDLogitechMouseMin || x > HIDLogitechMouseMax || y < HIDLogitechMouseMin || y > HIDLogitechMouseMax {
+		return fmt.Errorf("mouse movement %d,%d out of range", x, y), nil
+	} else if wheelV < -128 || wheelV > 127 || wheelH < -128 || wheelH > 127 {
+		return fmt.Errorf("wheel motion %d,%d out of range", wheelV, wheelH), nil
+	}
+
+	// x and y are packed as 12 bits signed integers
+	data := []byte{
+		0x00,
+		HIDLogitechMouse,
+		buttons,
+		0x00,
+		byte(x & 0xff),
+		byte((y<<4)&0xf0) | byte((x>>8)&0x0f),
+		byte((y >> 4) & 0xff),
+		byte(int8(wheelV)),
+		byte(int8(wheelH)),
+		0x00,
+	}
+	data[9] = HIDLogitechChecksum(data)
+
+	return nil, data
+}
+
+// HIDMicrosoftChecksum returns the checksum of a Microsoft frame, computed
+// on every byte but the last one.
+func HIDMicrosoftChecksum(data []byte) byte {
+	sum := byte(0)
+	for i := 0; i < len(data)-1; i++ {
+		sum ^= data[i]
+	}
+	return ^sum
+}
+
+// NewHIDMicrosoftKeystroke creates a Microsoft keystroke frame from a 19 bytes
+// payload previously sniffed from the device.
+func NewHIDMicrosoftKeystroke(template []byte, seqn uint16, mode byte, key byte) (error, []byte) {
+	if len(template) != 19 {
+		return fmt.Errorf("a 19 bytes template is required, got %d bytes", len(template)), nil
+	}
+
+	data := make([]byte, len(template))
+	copy(data, template)
+
+	data[4] = byte(seqn & 0xff)
+	data[5] = byte((seqn >> 8) & 0xff)
+	data[6] = HIDMicrosoftKeystroke
+	data[7] = mode
+	data[9] = key
+	for i := 10; i < 15; i++ {
+		data[i] = 0
+	}
+	data[18] = HIDMicrosoftChecksum(data)
+
+	return nil, data
+}
+
+// HIDMicrosoftSequence returns the sequence number of a Microsoft frame.
+func HIDMicrosoftSequence(data []byte) uint16 {
+	if len(data) < 6 {
+		return 0
+	}
+	return uint16(data[4]) | uint16(data[5])<<8
+}
+
+// HIDMicrosoftCrypt encrypts or decrypts a Microsoft frame of the models
+// XORing the payload with the device address.
+func HIDMicrosoftCrypt(address []byte, data []byte) []byte {
+	out := make([]byte, len(data))
+	copy(out, data)
+	if len(address) == 0 {
+		return out
+	}
+
+	for i := 4; i < len(out); i++ {
+		out[i] ^= address[(i-4)%len(address)]
+	}
+	return out
+}
+
+// NewHIDAmazonKeystroke creates an Amazon keystroke frame.
+func NewHIDAmazonKeystroke(mode byte, key byte) (error, []byte) {
+	return nil, []byte{0x0f, 0x0f, 0x0f, 0x0f, 0x0f, 0x0f,
+		0x0f, 0x0f, 0x0f, 0x0f, 0x0f, 0x0f,
+		0x0f, 0x0f, 0x0f, 0x0f, 0x0f, 0x0f,
+		0x0f, 0, mode, 0, key, 0}
+}
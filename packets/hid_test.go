@@ -0,0 +1,136 @@
+package packets
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestHIDLogitechChecksum(t *testing.T) {
+	for _, frame := range [][]byte{HIDLogitechHelloData, HIDLogitechKeepAliveData} {
+		if sum := HIDLogitechChecksum(frame); sum != frame[len(frame)-1] {
+			t.Fatalf("expected checksum %02x for %x, got %02x", frame[len(frame)-1], frame, sum)
+		}
+	}
+}
+
+func TestNewHIDLogitechKeystroke(t *testing.T) {
+	cases := []struct {
+		mode     byte
+		keys     []byte
+		expected []byte
+	}{
+		// 'a'
+		{0, []byte{0x04}, []byte{0x00, 0xc1, 0x00, 0x04, 0x00, 0x00, 0x00, 0x00, 0x00, 0x3b}},
+		// key release
+		{0, nil, []byte{0x00, 0xc1, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x3f}},
+		// GUI+r
+		{8, []byte{0x15}, []byte{0x00, 0xc1, 0x08, 0x15, 0x00, 0x00, 0x00, 0x00, 0x00, 0x22}},
+	}
+
+	for _, c := range cases {
+		err, frame := NewHIDLogitechKeystroke(c.mode, c.keys...)
+		if err != nil {
+			t.Fatal(err)
+		} else if !bytes.Equal(frame, c.expected) {
+			t.Fatalf("expected %x, got %x", c.expected, frame)
+		}
+	}
+
+	if err, _ := NewHIDLogitechKeystroke(0, 1, 2, 3, 4, 5, 6, 7); err == nil {
+		t.Fatal("expected error for more than 6 keys")
+	}
+}
+
+func TestNewHIDLogitechMouse(t *testing.T) {
+	err, frame := NewHIDLogitechMouse(HIDMouseLeft, 1, 0, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	} else if exp := []byte{0x00, 0xc2, 0x01, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x3c}; !bytes.Equal(frame, exp) {
+		t.Fatalf("expected %x, got %x", exp, frame)
+	}
+
+	cases := []struct{ x, y int }{
+		{-1, -1}, {2047, -2048}, {-300, 150}, {0, 1},
+	}
+	for _, c := range cases {
+		err, frame := NewHIDLogitechMouse(0, c.x, c.y, -1, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// unpack the 12 bits values
+		x := int(frame[4]) | int(frame[5]&0x0f)<<8
+		y := int(frame[5]>>4) | int(frame[6])<<4
+		if x >= 2048 {
+			x -= 4096
+		}
+		if y >= 2048 {
+			y -= 4096
+		}
+
+		if x != c.x || y != c.y {
+			t.Fatalf("expected %d,%d, got %d,%d", c.x, c.y, x, y)
+		} else if frame[7] != 0xff {
+			t.Fatalf("unexpected wheel motion %02x", frame[7])
+		} else if HIDLogitechChecksum(frame) != frame[9] {
+			t.Fatalf("bad checksum for %x", frame)
+		}
+	}
+
+	if err, _ := NewHIDLogitechMouse(0, 2048, 0, 0, 0); err == nil {
+		t.Fatal("expected error for out of range movement")
+	}
+}
+
+var testMicrosoftTemplate = []byte{
+	0x08, 0x78, 0x06, 0x01, 0x10, 0x02, 0x40, 0x00,
+	0x00, 0x04, 0x05, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00,
+}
+
+func TestNewHIDMicrosoftKeystroke(t *testing.T) {
+	err, frame := NewHIDMicrosoftKeystroke(testMicrosoftTemplate, 0x0211, 2, 0x0b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exp := []byte{
+		0x08, 0x78, 0x06, 0x01, 0x11, 0x02, 0x40, 0x02,
+		0x00, 0x0b, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0xd2,
+	}
+	if !bytes.Equal(frame, exp) {
+		t.Fatalf("expected %x, got %x", exp, frame)
+	} else if seqn := HIDMicrosoftSequence(frame); seqn != 0x0211 {
+		t.Fatalf("unexpected sequence number %04x", seqn)
+	} else if !bytes.Equal(testMicrosoftTemplate[9:11], []byte{0x04, 0x05}) {
+		t.Fatal("template has been modified")
+	}
+
+	if err, _ := NewHIDMicrosoftKeystroke(testMicrosoftTemplate[:10], 0, 0, 0); err == nil {
+		t.Fatal("expected error for short template")
+	}
+}
+
+func TestHIDMicrosoftCrypt(t *testing.T) {
+	address := []byte{0xa1, 0xb2, 0xc3, 0xd4, 0xe5}
+	_, frame := NewHIDMicrosoftKeystroke(testMicrosoftTemplate, 1, 0, 0x04)
+
+	enc := HIDMicrosoftCrypt(address, frame)
+	if !bytes.Equal(enc[:4], frame[:4]) {
+		t.Fatal("header should not be encrypted")
+	} else if enc[6] != 0x40^address[2] {
+		t.Fatalf("unexpected encrypted frame type %02x", enc[6])
+	} else if dec := HIDMicrosoftCrypt(address, enc); !bytes.Equal(dec, frame) {
+		t.Fatalf("expected %x, got %x", frame, dec)
+	}
+}
+
+func TestNewHIDAmazonKeystroke(t *testing.T) {
+	_, frame := NewHIDAmazonKeystroke(2, 0x04)
+	if len(frame) != 24 {
+		t.Fatalf("unexpected frame size %d", len(frame))
+	} else if !bytes.Equal(frame[19:], []byte{0x00, 0x02, 0x00, 0x04, 0x00}) {
+		t.Fatalf("unexpected frame %x", frame)
+	}
+}
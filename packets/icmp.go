@@ -0,0 +1,87 @@
+package packets
+
+import (
+	"encoding/binary"
+	"net"
+	"time"
+
+	"github.com/gopacket/gopacket"
+	"github.com/gopacket/gopacket/layers"
+)
+
+// NewICMPEcho builds an echo request, over ICMPv6 if the addresses are IPv6.
+func NewICMPEcho(from net.IP, from_hw net.HardwareAddr, to net.IP, to_hw net.HardwareAddr, id uint16, seq uint16) (error, []byte) {
+	if from4, to4 := from.To4(), to.To4(); from4 != nil && to4 != nil {
+		eth := layers.Ethernet{
+			SrcMAC:       from_hw,
+			DstMAC:       to_hw,
+			EthernetType: layers.EthernetTypeIPv4,
+		}
+		ip4 := layers.IPv4{
+			Protocol: layers.IPProtocolICMPv4,
+			Version:  4,
+			TTL:      64,
+			SrcIP:    from4,
+			DstIP:    to4,
+		}
+		icmp := layers.ICMPv4{
+			TypeCode: layers.CreateICMPv4TypeCode(layers.ICMPv4TypeEchoRequest, 0),
+			Id:       id,
+			Seq:      seq,
+		}
+		return Serialize(&eth, &ip4, &icmp)
+	}
+
+	eth := layers.Ethernet{
+		SrcMAC:       from_hw,
+		DstMAC:       to_hw,
+		EthernetType: layers.EthernetTypeIPv6,
+	}
+	ip6 := layers.IPv6{
+		Version:    6,
+		NextHeader: layers.IPProtocolICMPv6,
+		HopLimit:   64,
+		SrcIP:      from,
+		DstIP:      to,
+	}
+	icmp6 := layers.ICMPv6{
+		TypeCode: layers.CreateICMPv6TypeCode(layers.ICMPv6TypeEchoRequest, 0),
+	}
+	icmp6.SetNetworkLayerForChecksum(&ip6)
+	echo := layers.ICMPv6Echo{
+		Identifier: id,
+		SeqNumber:  seq,
+	}
+	return Serialize(&eth, &ip6, &icmp6, &echo)
+}
+
+// NewICMPTimestamp builds a timestamp request, answered by some of the hosts
+// dropping echo requests.
+func NewICMPTimestamp(from net.IP, from_hw net.HardwareAddr, to net.IP, to_hw net.HardwareAddr, id uint16, seq uint16) (error, []byte) {
+	eth := layers.Ethernet{
+		SrcMAC:       from_hw,
+		DstMAC:       to_hw,
+		EthernetType: layers.EthernetTypeIPv4,
+	}
+	ip4 := layers.IPv4{
+		Protocol: layers.IPProtocolICMPv4,
+		Version:  4,
+		TTL:      64,
+		SrcIP:    from.To4(),
+		DstIP:    to.To4(),
+	}
+	icmp := layers.ICMPv4{
+		TypeCode: layers.CreateICMPv4TypeCode(layers.ICMPv4TypeTimestampRequest, 0),
+		Id:       id,
+		Seq:      seq,
+	}
+
+	// originate timestamp in milliseconds since midnight UT, receive and
+	// transmit ones are set by the target
+	now := time.Now().UTC()
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	payload := make([]byte, 12)
+	binary.BigEndian.PutUint32(payload, uint32(now.Sub(midnight)/time.Millisecond))
+
+	return Serialize(&eth, &ip4, &icmp, gopacket.Payload(payload))
+}
@@ -1,8 +1,10 @@
 package packets
 
 import (
+	"github.com/gopacket/gopacket"
 	"github.com/gopacket/gopacket/layers"
 	"net"
+	"time"
 )
 
 func ICMP6NeighborAdvertisement(srcHW net.HardwareAddr, srcIP net.IP, dstHW net.HardwareAddr, dstIP net.IP, routerIP net.IP) (error, []byte) {
@@ -89,3 +91,62 @@ func ICMP6RouterAdvertisement(ip net.IP, hw net.HardwareAddr, prefix string, pre
 
 	return Serialize(&eth, &ip6, &icmp6, &adv)
 }
+
+// NewICMP6MulticastEcho builds an echo request to all the nodes of the link,
+// every IPv6 host is expected to answer it.
+func NewICMP6MulticastEcho(from net.IP, from_hw net.HardwareAddr, id uint16, seq uint16) (error, []byte) {
+	return NewICMPEcho(from, from_hw, ipv6Multicast, macIpv6Multicast, id, seq)
+}
+
+// MLDQueryResponseTime is how long the hosts can wait before answering the
+// queries built by NewMLDQuery.
+const MLDQueryResponseTime = 10 * time.Second
+
+// NewMLDQuery builds an MLDv2 general query (RFC 3810), the hosts answer with
+// a report of the multicast groups they joined, even if they drop echoes.
+// The query must be sent from the link-local address of the interface.
+func NewMLDQuery(from net.IP, from_hw net.HardwareAddr) (error, []byte) {
+	eth := layers.Ethernet{
+		SrcMAC:       from_hw,
+		DstMAC:       macIpv6Multicast,
+		EthernetType: layers.EthernetTypeIPv6,
+	}
+	ip6 := layers.IPv6{
+		NextHeader: layers.IPProtocolIPv6HopByHop,
+		Version:    6,
+		HopLimit:   1,
+		SrcIP:      from,
+		DstIP:      ipv6Multicast,
+		HopByHop:   &layers.IPv6HopByHop{},
+	}
+	ip6.HopByHop.NextHeader = layers.IPProtocolICMPv6
+	// router alert option
+	ip6.HopByHop.Options = append(ip6.HopByHop.Options, &layers.IPv6HopByHopOption{
+		OptionType:   0x05,
+		OptionLength: 2,
+		OptionData:   []byte{0x00, 0x00},
+	}, &layers.IPv6HopByHopOption{
+		// PadN, the header must be a multiple of 8 bytes
+		OptionType:   0x01,
+		OptionLength: 0,
+		OptionData:   []byte{},
+	})
+	icmp6 := layers.ICMPv6{
+		TypeCode: layers.CreateICMPv6TypeCode(layers.ICMPv6TypeMLDv1MulticastListenerQueryMessage, 0),
+	}
+	icmp6.SetNetworkLayerForChecksum(&ip6)
+
+	query := []byte{
+		0x27, 0x10, // maximum response code (MLDQueryResponseTime in ms)
+		0x00, 0x00, // reserved
+	}
+	// unspecified multicast address for a general query
+	query = append(query, net.IPv6unspecified...)
+	query = append(query,
+		0x02,       // robustness variable
+		0x7d,       // query interval code (125s)
+		0x00, 0x00, // number of sources
+	)
+
+	return Serialize(&eth, &ip6, &icmp6, gopacket.Payload(query))
+}
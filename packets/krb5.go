@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"strconv"
+	"strings"
 	"time"
 
 	"encoding/asn1"
@@ -12,18 +13,51 @@ import (
 
 const (
 	Krb5AsRequestType         = 10
+	Krb5AsReplyType           = 11
+	Krb5TgsReplyType          = 13
 	Krb5Krb5PrincipalNameType = 1
 	Krb5CryptDesCbcMd4        = 2
 	Krb5CryptDescCbcMd5       = 3
+	Krb5CryptAes128           = 17
+	Krb5CryptAes256           = 18
 	Krb5CryptRc4Hmac          = 23
+
+	Krb5PaEncTimestamp = 2
+
+	// size of the HMAC appended to the AES ciphers
+	krb5AesChecksumSize = 12
+	// size of the HMAC prepended to the RC4 ciphers
+	krb5Rc4ChecksumSize = 16
 )
 
 var (
 	ErrNoCrypt  = errors.New("No crypt alg found")
 	ErrReqData  = errors.New("Failed to extract pnData from as-req")
 	ErrNoCipher = errors.New("No encryption type or cipher found")
+	ErrNoTicket = errors.New("Failed to extract the ticket from the reply")
+	// AES tickets are salted with the name of the service account, which is
+	// not in the ticket, only the SPN is
+	ErrNoServiceAccount = errors.New("The service account of the AES ticket is unknown")
 
-	Krb5AsReqParam = "application,explicit,tag:10"
+	Krb5AsReqParam  = "application,explicit,tag:10"
+	Krb5AsRepParam  = "application,explicit,tag:11"
+	Krb5TgsRepParam = "application,explicit,tag:13"
+	Krb5TicketParam = "application,explicit,tag:1"
+
+	// hashcat modes by encryption type
+	krb5PreAuthModes = map[int]int{
+		Krb5CryptRc4Hmac: 7500,
+		Krb5CryptAes128:  19800,
+		Krb5CryptAes256:  19900,
+	}
+	krb5AsRepModes = map[int]int{
+		Krb5CryptRc4Hmac: 18200,
+		Krb5CryptAes128:  32100,
+		Krb5CryptAes256:  32200,
+	}
+	krb5TgsRepModes = map[int]int{
+		Krb5CryptRc4Hmac: 13100,
+	}
 )
 
 type Krb5PrincipalName struct {
@@ -76,6 +110,36 @@ type Krb5Request struct {
 	ReqBody    Krb5ReqBody  `asn1:"explicit,tag:4"`
 }
 
+// Krb5Reply is a KDC-REP, either an AS-REP or a TGS-REP.
+type Krb5Reply struct {
+	Pvno       int               `asn1:"explicit,tag:0"`
+	MsgType    int               `asn1:"explicit,tag:1"`
+	Krb5PnData []Krb5PnData      `asn1:"optional,explicit,tag:2"`
+	Crealm     string            `asn1:"general,explicit,tag:3"`
+	Cname      Krb5PrincipalName `asn1:"explicit,tag:4"`
+	Ticket     asn1.RawValue     `asn1:"explicit,tag:5"`
+	EncPart    Krb5EncryptedData `asn1:"explicit,tag:6"`
+}
+
+// Krb5Hash is a crackable Kerberos hash along with the hashcat mode to use.
+type Krb5Hash struct {
+	Mode    int
+	User    string
+	Realm   string
+	Service string
+	Hash    string
+}
+
+// ParseKrb5Reply parses either an AS-REP or a TGS-REP.
+func ParseKrb5Reply(data []byte) (Krb5Reply, error) {
+	var rep Krb5Reply
+	_, err := asn1.UnmarshalWithParams(data, &rep, Krb5AsRepParam)
+	if err != nil {
+		_, err = asn1.UnmarshalWithParams(data, &rep, Krb5TgsRepParam)
+	}
+	return rep, err
+}
+
 func (kdc Krb5Request) String() (string, error) {
 	var eType, cipher string
 
@@ -112,3 +176,123 @@ func (pd Krb5PnData) getParsedValue() (Krb5EncryptedData, error) {
 	}
 	return encData, nil
 }
+
+// Hash returns the encrypted timestamp of the pre-authentication data in the
+// format expected by hashcat.
+func (kdc Krb5Request) Hash() (Krb5Hash, error) {
+	if kdc.ReqBody.Cname.NameType != Krb5Krb5PrincipalNameType || len(kdc.ReqBody.Cname.NameString) == 0 {
+		return Krb5Hash{}, ErrNoCrypt
+	}
+
+	for _, pn := range kdc.Krb5PnData {
+		if pn.Krb5PnDataType != Krb5PaEncTimestamp {
+			continue
+		}
+
+		enc, err := pn.getParsedValue()
+		if err != nil {
+			return Krb5Hash{}, err
+		}
+
+		mode, found := krb5PreAuthModes[enc.Etype]
+		if !found || len(enc.Cipher) <= krb5Rc4ChecksumSize {
+			return Krb5Hash{}, ErrNoCipher
+		}
+
+		h := Krb5Hash{
+			Mode:  mode,
+			User:  kdc.ReqBody.Cname.NameString[0],
+			Realm: kdc.ReqBody.Realm,
+		}
+		if enc.Etype == Krb5CryptRc4Hmac {
+			// the checksum goes after the encrypted timestamp
+			h.Hash = fmt.Sprintf("$krb5pa$23$%s$%s$$%s%s", h.User, h.Realm,
+				hex.EncodeToString(enc.Cipher[krb5Rc4ChecksumSize:]),
+				hex.EncodeToString(enc.Cipher[:krb5Rc4ChecksumSize]))
+		} else {
+			h.Hash = fmt.Sprintf("$krb5pa$%d$%s$%s$%s", enc.Etype, h.User, h.Realm, hex.EncodeToString(enc.Cipher))
+		}
+		return h, nil
+	}
+
+	return Krb5Hash{}, ErrNoCipher
+}
+
+// GetTicket returns the service ticket of the reply.
+func (rep Krb5Reply) GetTicket() (Krb5Ticket, error) {
+	var ticket Krb5Ticket
+	if _, err := asn1.UnmarshalWithParams(rep.Ticket.Bytes, &ticket, Krb5TicketParam); err != nil {
+		return Krb5Ticket{}, ErrNoTicket
+	}
+	return ticket, nil
+}
+
+func krb5Split(etype int, cipher []byte) (checksum string, data string, err error) {
+	if etype == Krb5CryptRc4Hmac {
+		if len(cipher) <= krb5Rc4ChecksumSize {
+			return "", "", ErrNoCipher
+		}
+		return hex.EncodeToString(cipher[:krb5Rc4ChecksumSize]), hex.EncodeToString(cipher[krb5Rc4ChecksumSize:]), nil
+	} else if len(cipher) <= krb5AesChecksumSize {
+		return "", "", ErrNoCipher
+	}
+	off := len(cipher) - krb5AesChecksumSize
+	return hex.EncodeToString(cipher[off:]), hex.EncodeToString(cipher[:off]), nil
+}
+
+// Hash returns the roastable part of the reply in the format expected by
+// hashcat: the encrypted part of an AS-REP or the service ticket of a TGS-REP.
+func (rep Krb5Reply) Hash() (Krb5Hash, error) {
+	if len(rep.Cname.NameString) == 0 {
+		return Krb5Hash{}, ErrNoCrypt
+	}
+
+	h := Krb5Hash{
+		User:  rep.Cname.NameString[0],
+		Realm: rep.Crealm,
+	}
+
+	if rep.MsgType == Krb5AsReplyType {
+		mode, found := krb5AsRepModes[rep.EncPart.Etype]
+		if !found {
+			return Krb5Hash{}, ErrNoCipher
+		}
+		checksum, data, err := krb5Split(rep.EncPart.Etype, rep.EncPart.Cipher)
+		if err != nil {
+			return Krb5Hash{}, err
+		}
+
+		h.Mode = mode
+		if rep.EncPart.Etype == Krb5CryptRc4Hmac {
+			h.Hash = fmt.Sprintf("$krb5asrep$23$%s@%s:%s$%s", h.User, h.Realm, checksum, data)
+		} else {
+			h.Hash = fmt.Sprintf("$krb5asrep$%d$%s$%s$%s$%s", rep.EncPart.Etype, h.User, h.Realm, checksum, data)
+		}
+		return h, nil
+	} else if rep.MsgType != Krb5TgsReplyType {
+		return Krb5Hash{}, ErrNoCrypt
+	}
+
+	ticket, err := rep.GetTicket()
+	if err != nil {
+		return Krb5Hash{}, err
+	}
+
+	enc := ticket.EncPart
+	mode, found := krb5TgsRepModes[enc.Etype]
+	if enc.Etype == Krb5CryptAes128 || enc.Etype == Krb5CryptAes256 {
+		return Krb5Hash{}, ErrNoServiceAccount
+	} else if !found {
+		return Krb5Hash{}, ErrNoCipher
+	}
+	checksum, data, err := krb5Split(enc.Etype, enc.Cipher)
+	if err != nil {
+		return Krb5Hash{}, err
+	}
+
+	// the user is just a label for RC4 tickets
+	h.Mode = mode
+	h.Service = strings.Join(ticket.Sname.NameString, "/")
+	h.Hash = fmt.Sprintf("$krb5tgs$23$*%s$%s$%s*$%s$%s", h.User, h.Realm, h.Service, checksum, data)
+	return h, nil
+}
@@ -206,3 +206,112 @@ func TestKrb5Request(t *testing.T) {
 
 // TODO: add test for func (kdc Krb5Request) String()
 // TODO: add test for func (pd Krb5PnData) getParsedValue()
+
+func TestKrb5RequestHash(t *testing.T) {
+	cipher := make([]byte, 52)
+	cipher[0] = 0xaa
+	cipher[16] = 0xbb
+	value, _ := asn1.Marshal(Krb5EncryptedData{Etype: Krb5CryptRc4Hmac, Cipher: cipher})
+	req := Krb5Request{
+		Krb5PnData: []Krb5PnData{{Krb5PnDataType: Krb5PaEncTimestamp, Krb5PnDataValue: value}},
+		ReqBody: Krb5ReqBody{
+			Cname: Krb5PrincipalName{NameType: Krb5Krb5PrincipalNameType, NameString: []string{"user"}},
+			Realm: "EXAMPLE.COM",
+		},
+	}
+
+	h, err := req.Hash()
+	if err != nil {
+		t.Fatal(err)
+	} else if h.Mode != 7500 {
+		t.Fatalf("unexpected mode %d", h.Mode)
+	} else if exp := "$krb5pa$23$user$EXAMPLE.COM$$bb"; h.Hash[:len(exp)] != exp {
+		t.Fatalf("unexpected hash %s", h.Hash)
+	} else if h.Hash[len(h.Hash)-32:len(h.Hash)-30] != "aa" {
+		t.Fatalf("checksum expected at the end of %s", h.Hash)
+	}
+}
+
+func buildKrb5Reply(t *testing.T, msgType int, params string, ticketEtype int) []byte {
+	cipher := []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d}
+	if ticketEtype == Krb5CryptRc4Hmac {
+		cipher = []byte{0x0a, 0x0a, 0x0a, 0x0a, 0x0a, 0x0a, 0x0a, 0x0a, 0x0a, 0x0a, 0x0a, 0x0a, 0x0a, 0x0a, 0x0a, 0x0a, 0xbb}
+	}
+	ticket, err := asn1.MarshalWithParams(Krb5Ticket{
+		TktVno: 5,
+		Realm:  "EXAMPLE.COM",
+		Sname:  Krb5PrincipalName{NameType: 2, NameString: []string{"MSSQLSvc", "db.example.com"}},
+		EncPart: Krb5EncryptedData{
+			Etype:  ticketEtype,
+			Cipher: cipher,
+		},
+	}, Krb5TicketParam)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// raw values are marshaled as they are, so the explicit tag is added here
+	ticket, err = asn1.Marshal(asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 5, IsCompound: true, Bytes: ticket})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := asn1.MarshalWithParams(Krb5Reply{
+		Pvno:    5,
+		MsgType: msgType,
+		Crealm:  "EXAMPLE.COM",
+		Cname:   Krb5PrincipalName{NameType: Krb5Krb5PrincipalNameType, NameString: []string{"user"}},
+		Ticket:  asn1.RawValue{FullBytes: ticket},
+		EncPart: Krb5EncryptedData{
+			Etype:  Krb5CryptRc4Hmac,
+			Cipher: []byte{0x0f, 0x0f, 0x0f, 0x0f, 0x0f, 0x0f, 0x0f, 0x0f, 0x0f, 0x0f, 0x0f, 0x0f, 0x0f, 0x0f, 0x0f, 0x0f, 0xee},
+		},
+	}, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestKrb5ReplyHash(t *testing.T) {
+	var units = []struct {
+		data []byte
+		mode int
+		hash string
+	}{
+		{
+			buildKrb5Reply(t, Krb5AsReplyType, Krb5AsRepParam, Krb5CryptAes256),
+			18200,
+			"$krb5asrep$23$user@EXAMPLE.COM:0f0f0f0f0f0f0f0f0f0f0f0f0f0f0f0f$ee",
+		},
+		{
+			buildKrb5Reply(t, Krb5TgsReplyType, Krb5TgsRepParam, Krb5CryptRc4Hmac),
+			13100,
+			"$krb5tgs$23$*user$EXAMPLE.COM$MSSQLSvc/db.example.com*$0a0a0a0a0a0a0a0a0a0a0a0a0a0a0a0a$bb",
+		},
+	}
+	for _, u := range units {
+		rep, err := ParseKrb5Reply(u.data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		h, err := rep.Hash()
+		if err != nil {
+			t.Fatal(err)
+		} else if h.Mode != u.mode {
+			t.Fatalf("expected mode %d, got %d", u.mode, h.Mode)
+		} else if h.Hash != u.hash {
+			t.Fatalf("expected '%s', got '%s'", u.hash, h.Hash)
+		}
+	}
+
+	// the salt of AES tickets can't be known
+	if rep, err := ParseKrb5Reply(buildKrb5Reply(t, Krb5TgsReplyType, Krb5TgsRepParam, Krb5CryptAes256)); err != nil {
+		t.Fatal(err)
+	} else if _, err = rep.Hash(); err != ErrNoServiceAccount {
+		t.Fatalf("expected '%v', got '%v'", ErrNoServiceAccount, err)
+	}
+
+	if _, err := ParseKrb5Reply([]byte{0x30, 0x00}); err == nil {
+		t.Fatal("expected error for invalid reply")
+	}
+}
@@ -0,0 +1,73 @@
+package packets
+
+import (
+	"encoding/asn1"
+)
+
+const (
+	LDAPPort = 389
+
+	ldapBindRequest = 0
+	ldapAuthSimple  = 0
+	ldapAuthSASL    = 3
+)
+
+type ldapMessage struct {
+	ID int
+	Op asn1.RawValue
+}
+
+type ldapBind struct {
+	Version int
+	Name    []byte
+	Auth    asn1.RawValue
+}
+
+type ldapSASL struct {
+	Mechanism []byte
+	Rest      asn1.RawValue `asn1:"optional"`
+}
+
+// LDAPCredentials returns the DN and password of a simple bind request, or
+// the DN and mechanism of a SASL one.
+func LDAPCredentials(flow AuthFlow, data []byte) *AuthCredentials {
+	msg := ldapMessage{}
+	if _, err := asn1.Unmarshal(data, &msg); err != nil {
+		return nil
+	} else if msg.Op.Class != asn1.ClassApplication || msg.Op.Tag != ldapBindRequest || !msg.Op.IsCompound {
+		return nil
+	}
+
+	// BindRequest ::= [APPLICATION 0] SEQUENCE { version, name, authentication }
+	bind := ldapBind{}
+	seq := asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSequence, IsCompound: true, Bytes: msg.Op.Bytes}
+	raw, err := asn1.Marshal(seq)
+	if err != nil {
+		return nil
+	} else if _, err = asn1.Unmarshal(raw, &bind); err != nil {
+		return nil
+	} else if bind.Auth.Class != asn1.ClassContextSpecific {
+		return nil
+	}
+
+	if bind.Auth.Tag == ldapAuthSimple {
+		creds := flow.credentials("ldap", "simple bind")
+		creds.Username = string(bind.Name)
+		creds.Password = string(bind.Auth.Bytes)
+		return creds
+	} else if bind.Auth.Tag == ldapAuthSASL {
+		sasl := ldapSASL{}
+		seq.Bytes = bind.Auth.Bytes
+		if raw, err = asn1.Marshal(seq); err != nil {
+			return nil
+		} else if _, err = asn1.Unmarshal(raw, &sasl); err != nil {
+			return nil
+		}
+		creds := flow.credentials("ldap", "sasl bind")
+		creds.Username = string(bind.Name)
+		creds.Info = string(sasl.Mechanism)
+		return creds
+	}
+
+	return nil
+}
@@ -0,0 +1,60 @@
+package packets
+
+import (
+	"encoding/asn1"
+	"testing"
+)
+
+func buildLDAPBind(t *testing.T, dn string, auth asn1.RawValue) []byte {
+	version, _ := asn1.Marshal(3)
+	name, _ := asn1.Marshal([]byte(dn))
+	authentication, err := asn1.Marshal(auth)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	content := append(append(version, name...), authentication...)
+	data, err := asn1.Marshal(ldapMessage{
+		ID: 1,
+		Op: asn1.RawValue{Class: asn1.ClassApplication, Tag: ldapBindRequest, IsCompound: true, Bytes: content},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestLDAPSimpleBind(t *testing.T) {
+	bind := buildLDAPBind(t, "cn=admin,dc=example,dc=com", asn1.RawValue{
+		Class: asn1.ClassContextSpecific,
+		Tag:   ldapAuthSimple,
+		Bytes: []byte("secret"),
+	})
+
+	authExpect(t, false, LDAPPort, []authExchange{
+		{true, bind},
+	}, LDAPCredentials, AuthCredentials{
+		Protocol: "ldap",
+		Method:   "simple bind",
+		Username: "cn=admin,dc=example,dc=com",
+		Password: "secret",
+	})
+}
+
+func TestLDAPSASLBind(t *testing.T) {
+	mech, _ := asn1.Marshal([]byte("GSSAPI"))
+	bind := buildLDAPBind(t, "", asn1.RawValue{
+		Class:      asn1.ClassContextSpecific,
+		Tag:        ldapAuthSASL,
+		IsCompound: true,
+		Bytes:      mech,
+	})
+
+	authExpect(t, false, LDAPPort, []authExchange{
+		{true, bind},
+	}, LDAPCredentials, AuthCredentials{
+		Protocol: "ldap",
+		Method:   "sasl bind",
+		Info:     "GSSAPI",
+	})
+}
@@ -0,0 +1,226 @@
+package packets
+
+import (
+	"strings"
+)
+
+var (
+	SMTPPorts = []int{25, 587, 2525}
+	POP3Ports = []int{110}
+	IMAPPorts = []int{143}
+)
+
+const (
+	mailMechPlain   = "PLAIN"
+	mailMechLogin   = "LOGIN"
+	mailMechCramMD5 = "CRAM-MD5"
+)
+
+type mailFlow struct {
+	proto     string
+	mech      string
+	step      int
+	user      string
+	challenge string
+}
+
+// MailAuthState tracks SMTP, POP3 and IMAP conversations to extract the
+// credentials of the USER/PASS, LOGIN and AUTH PLAIN/LOGIN/CRAM-MD5
+// authentication methods.
+type MailAuthState struct {
+	flows authFlows
+}
+
+func NewMailAuthState() *MailAuthState {
+	return &MailAuthState{
+		flows: newAuthFlows(),
+	}
+}
+
+// MailProtocol returns the name of the mail protocol for the port.
+func MailProtocol(port int) string {
+	for _, p := range SMTPPorts {
+		if p == port {
+			return "smtp"
+		}
+	}
+	for _, p := range POP3Ports {
+		if p == port {
+			return "pop3"
+		}
+	}
+	for _, p := range IMAPPorts {
+		if p == port {
+			return "imap"
+		}
+	}
+	return ""
+}
+
+// imapArgs splits an IMAP command in arguments, handling quoted strings.
+func imapArgs(line string) []string {
+	args := make([]string, 0)
+	arg := strings.Builder{}
+	quoted, escaped, hasArg := false, false, false
+
+	for _, c := range line {
+		if escaped {
+			arg.WriteRune(c)
+			escaped = false
+		} else if quoted && c == '\\' {
+			escaped = true
+		} else if c == '"' {
+			quoted = !quoted
+			hasArg = true
+		} else if c == ' ' && !quoted {
+			if hasArg {
+				args = append(args, arg.String())
+				arg.Reset()
+				hasArg = false
+			}
+		} else {
+			arg.WriteRune(c)
+			hasArg = true
+		}
+	}
+	if hasArg {
+		args = append(args, arg.String())
+	}
+	return args
+}
+
+// Parse feeds the state with a payload of the conversation and returns the
+// credentials once an authentication exchange is complete.
+func (s *MailAuthState) Parse(flow AuthFlow, proto string, payload []byte) *AuthCredentials {
+	s.flows.Lock()
+	defer s.flows.Unlock()
+
+	f := s.flows.get(flow, func() interface{} {
+		return &mailFlow{proto: proto}
+	}).(*mailFlow)
+
+	for _, line := range authLines(payload) {
+		if !flow.FromClient {
+			s.onServerLine(f, line)
+		} else if creds := s.onClientLine(flow, f, line); creds != nil {
+			s.flows.del(flow)
+			return creds
+		}
+	}
+
+	return nil
+}
+
+func (s *MailAuthState) onServerLine(f *mailFlow, line string) {
+	if f.mech != mailMechCramMD5 || f.challenge != "" {
+		return
+	}
+	// SMTP continuations start with 334, IMAP and POP3 ones with +
+	if strings.HasPrefix(line, "334 ") || strings.HasPrefix(line, "+ ") {
+		f.challenge = strings.TrimSpace(line[strings.Index(line, " ")+1:])
+	}
+}
+
+func (s *MailAuthState) onClientLine(flow AuthFlow, f *mailFlow, line string) *AuthCredentials {
+	if f.mech != "" {
+		return s.onContinuation(flow, f, line)
+	}
+
+	args := strings.Fields(line)
+	if f.proto == "imap" {
+		// skip the tag, LOGIN arguments can be quoted
+		if args = imapArgs(line); len(args) > 0 {
+			args = args[1:]
+		}
+	}
+	if len(args) == 0 {
+		return nil
+	}
+
+	switch cmd := strings.ToUpper(args[0]); cmd {
+	case "USER":
+		if len(args) > 1 {
+			f.user = args[1]
+		}
+	case "PASS":
+		if f.user != "" && len(args) > 1 {
+			creds := flow.credentials(f.proto, "USER/PASS")
+			creds.Username = f.user
+			creds.Password = strings.TrimSpace(line[strings.Index(line, " ")+1:])
+			return creds
+		}
+	case "LOGIN":
+		if len(args) == 3 {
+			creds := flow.credentials(f.proto, "LOGIN")
+			creds.Username = args[1]
+			creds.Password = args[2]
+			return creds
+		}
+	case "AUTH", "AUTHENTICATE":
+		if len(args) < 2 {
+			return nil
+		}
+		mech := strings.ToUpper(args[1])
+		if mech != mailMechPlain && mech != mailMechLogin && mech != mailMechCramMD5 {
+			return nil
+		}
+		f.mech = mech
+		f.step = 0
+		f.challenge = ""
+		// initial response
+		if len(args) > 2 {
+			return s.onContinuation(flow, f, args[2])
+		}
+	}
+
+	return nil
+}
+
+func (s *MailAuthState) onContinuation(flow AuthFlow, f *mailFlow, line string) *AuthCredentials {
+	mech := f.mech
+	// the client can cancel the exchange with a single *
+	if line == "*" {
+		f.mech = ""
+		return nil
+	}
+
+	switch mech {
+	case mailMechPlain:
+		f.mech = ""
+		if user, pass, ok := authPlain(line); ok {
+			creds := flow.credentials(f.proto, "AUTH PLAIN")
+			creds.Username = user
+			creds.Password = pass
+			return creds
+		}
+	case mailMechLogin:
+		value, ok := authBase64(line)
+		if !ok {
+			f.mech = ""
+		} else if f.step == 0 {
+			f.user = value
+			f.step++
+		} else {
+			f.mech = ""
+			creds := flow.credentials(f.proto, "AUTH LOGIN")
+			creds.Username = f.user
+			creds.Password = value
+			return creds
+		}
+	case mailMechCramMD5:
+		f.mech = ""
+		if value, ok := authBase64(line); ok && f.challenge != "" {
+			parts := strings.Fields(value)
+			if len(parts) != 2 {
+				return nil
+			}
+			creds := flow.credentials(f.proto, "AUTH CRAM-MD5")
+			creds.Username = parts[0]
+			creds.Hash = "$cram_md5$" + f.challenge + "$" + strings.TrimSpace(line)
+			creds.HashcatMode = 16400
+			return creds
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,123 @@
+package packets
+
+import (
+	"testing"
+)
+
+func mailParser(proto string) func(AuthFlow, []byte) *AuthCredentials {
+	state := NewMailAuthState()
+	return func(flow AuthFlow, payload []byte) *AuthCredentials {
+		return state.Parse(flow, proto, payload)
+	}
+}
+
+func TestMailProtocol(t *testing.T) {
+	for port, exp := range map[int]string{25: "smtp", 587: "smtp", 110: "pop3", 143: "imap", 80: ""} {
+		if got := MailProtocol(port); got != exp {
+			t.Fatalf("expected '%s' for port %d, got '%s'", exp, port, got)
+		}
+	}
+}
+
+func TestSMTPAuthPlain(t *testing.T) {
+	authExpect(t, false, 25, []authExchange{
+		fromServer("220 mail.example.com ESMTP\r\n"),
+		fromClient("EHLO client\r\n"),
+		fromServer("250-mail.example.com\r\n250 AUTH PLAIN LOGIN CRAM-MD5\r\n"),
+		// \x00user\x00secret
+		fromClient("AUTH PLAIN AHVzZXIAc2VjcmV0\r\n"),
+		fromServer("235 2.7.0 Authentication successful\r\n"),
+	}, mailParser("smtp"), AuthCredentials{
+		Protocol: "smtp",
+		Method:   "AUTH PLAIN",
+		Username: "user",
+		Password: "secret",
+	})
+}
+
+func TestSMTPAuthLogin(t *testing.T) {
+	authExpect(t, false, 587, []authExchange{
+		fromClient("AUTH LOGIN\r\n"),
+		fromServer("334 VXNlcm5hbWU6\r\n"),
+		fromClient("dXNlcg==\r\n"),
+		fromServer("334 UGFzc3dvcmQ6\r\n"),
+		fromClient("c2VjcmV0\r\n"),
+	}, mailParser("smtp"), AuthCredentials{
+		Protocol: "smtp",
+		Method:   "AUTH LOGIN",
+		Username: "user",
+		Password: "secret",
+	})
+}
+
+func TestSMTPAuthCramMD5(t *testing.T) {
+	// RFC 2195 example
+	challenge := "PDE4OTYuNjk3MTcwOTUyQHBvc3RvZmZpY2UucmVzdG9uLm1jaS5uZXQ+"
+	response := "dGltIGI5MTNhNjAyYzdlZGE3YTQ5NWI0ZTZlNzMzNGQzODkw"
+
+	authExpect(t, false, 25, []authExchange{
+		fromClient("AUTH CRAM-MD5\r\n"),
+		fromServer("334 " + challenge + "\r\n"),
+		fromClient(response + "\r\n"),
+	}, mailParser("smtp"), AuthCredentials{
+		Protocol:    "smtp",
+		Method:      "AUTH CRAM-MD5",
+		Username:    "tim",
+		Hash:        "$cram_md5$" + challenge + "$" + response,
+		HashcatMode: 16400,
+	})
+}
+
+func TestSMTPAuthCancel(t *testing.T) {
+	found := authReplay(t, false, 25, []authExchange{
+		fromClient("AUTH LOGIN\r\n"),
+		fromServer("334 VXNlcm5hbWU6\r\n"),
+		fromClient("*\r\n"),
+		fromServer("501 5.7.0 Authentication aborted\r\n"),
+		fromClient("QUIT\r\n"),
+	}, mailParser("smtp"))
+	if len(found) != 0 {
+		t.Fatalf("unexpected credentials %v", found)
+	}
+}
+
+func TestPOP3UserPass(t *testing.T) {
+	authExpect(t, false, 110, []authExchange{
+		fromServer("+OK POP3 server ready\r\n"),
+		fromClient("USER alice\r\n"),
+		fromServer("+OK\r\n"),
+		fromClient("PASS my secret\r\n"),
+		fromServer("+OK logged in\r\n"),
+	}, mailParser("pop3"), AuthCredentials{
+		Protocol: "pop3",
+		Method:   "USER/PASS",
+		Username: "alice",
+		Password: "my secret",
+	})
+}
+
+func TestIMAPLogin(t *testing.T) {
+	authExpect(t, false, 143, []authExchange{
+		fromServer("* OK IMAP4rev1 ready\r\n"),
+		fromClient("a001 LOGIN \"bob\" \"pass \\\"word\\\"\"\r\n"),
+		fromServer("a001 OK LOGIN completed\r\n"),
+	}, mailParser("imap"), AuthCredentials{
+		Protocol: "imap",
+		Method:   "LOGIN",
+		Username: "bob",
+		Password: "pass \"word\"",
+	})
+}
+
+func TestIMAPAuthenticatePlain(t *testing.T) {
+	authExpect(t, false, 143, []authExchange{
+		fromClient("a002 AUTHENTICATE PLAIN\r\n"),
+		fromServer("+ \r\n"),
+		fromClient("AHVzZXIAc2VjcmV0\r\n"),
+	}, mailParser("imap"), AuthCredentials{
+		Protocol: "imap",
+		Method:   "AUTH PLAIN",
+		Username: "user",
+		Password: "secret",
+	})
+}
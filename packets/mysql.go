@@ -1,5 +1,11 @@
 package packets
 
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
 var (
 	MySQLGreeting = []byte{
 		0x5b, 0x00, 0x00, 0x00, 0x0a, 0x35, 0x2e, 0x36,
@@ -31,3 +37,116 @@ func MySQLGetFile(infile string) []byte {
 		0x00, 0x00, 0x01, 0xfb,
 	}, infile...)
 }
+
+const (
+	MySQLPort = 3306
+
+	mysqlProtocol10  = 0x0a
+	mysqlProtocol41  = 0x0200
+	mysqlLoginOffset = 36
+	mysqlScrambleLen = 20
+)
+
+// MySQLParseGreeting returns the 20 bytes scramble of a server greeting.
+func MySQLParseGreeting(data []byte) ([]byte, bool) {
+	if len(data) < 5 || data[4] != mysqlProtocol10 {
+		return nil, false
+	}
+
+	end := bytes.IndexByte(data[5:], 0)
+	if end == -1 {
+		return nil, false
+	}
+
+	// thread id, first part of the scramble, filler, capabilities, charset,
+	// status, capabilities, scramble length and reserved bytes
+	off := 5 + end + 1
+	if off+31+12 > len(data) {
+		return nil, false
+	}
+
+	salt := make([]byte, 0, mysqlScrambleLen)
+	salt = append(salt, data[off+4:off+12]...)
+	return append(salt, data[off+31:off+31+12]...), true
+}
+
+// MySQLGreetingSalt returns the 20 bytes scramble sent with MySQLGreeting.
+func MySQLGreetingSalt() []byte {
+	salt, _ := MySQLParseGreeting(MySQLGreeting)
+	return salt
+}
+
+// MySQLParseLogin returns the username and the mysql_native_password
+// scramble of a client handshake response, if any.
+func MySQLParseLogin(data []byte) (user string, scramble []byte) {
+	if len(data) <= mysqlLoginOffset {
+		return "", nil
+	}
+
+	end := bytes.IndexByte(data[mysqlLoginOffset:], 0)
+	if end == -1 {
+		return string(data[mysqlLoginOffset:]), nil
+	}
+	user = string(data[mysqlLoginOffset : mysqlLoginOffset+end])
+
+	off := mysqlLoginOffset + end + 1
+	if off < len(data) && data[off] == mysqlScrambleLen && off+1+mysqlScrambleLen <= len(data) {
+		scramble = data[off+1 : off+1+mysqlScrambleLen]
+	}
+	return
+}
+
+// MySQLHashcatString returns the login scramble in the format used by hashcat
+// mode 11200.
+func MySQLHashcatString(salt []byte, scramble []byte) string {
+	return fmt.Sprintf("$mysqlna$%x*%x", salt, scramble)
+}
+
+// MySQLAuthState tracks MySQL connections to extract the login challenge
+// responses sent by clients.
+type MySQLAuthState struct {
+	flows authFlows
+}
+
+func NewMySQLAuthState() *MySQLAuthState {
+	return &MySQLAuthState{
+		flows: newAuthFlows(),
+	}
+}
+
+// Parse feeds the state with a payload of the connection and returns the
+// credentials once the client sent its handshake response.
+func (s *MySQLAuthState) Parse(flow AuthFlow, payload []byte) *AuthCredentials {
+	s.flows.Lock()
+	defer s.flows.Unlock()
+
+	if !flow.FromClient {
+		if salt, ok := MySQLParseGreeting(payload); ok {
+			s.flows.get(flow, func() interface{} {
+				return salt
+			})
+		}
+		return nil
+	}
+
+	// the handshake response is the second packet of the connection
+	if len(payload) <= mysqlLoginOffset || payload[3] != 1 {
+		return nil
+	} else if caps := binary.LittleEndian.Uint16(payload[4:]); caps&mysqlProtocol41 == 0 {
+		return nil
+	}
+
+	user, scramble := MySQLParseLogin(payload)
+	if user == "" {
+		return nil
+	}
+
+	creds := flow.credentials("mysql", "native password")
+	creds.Username = user
+	if state := s.flows.get(flow, nil); state != nil && scramble != nil {
+		creds.Hash = MySQLHashcatString(state.([]byte), scramble)
+		creds.HashcatMode = 11200
+	}
+	s.flows.del(flow)
+	return creds
+}
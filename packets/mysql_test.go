@@ -0,0 +1,56 @@
+package packets
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestMySQLGreetingSalt(t *testing.T) {
+	exp := []byte("@?Y&K+4`hiY_R_cU`dSR")
+	if salt := MySQLGreetingSalt(); !bytes.Equal(salt, exp) {
+		t.Fatalf("expected %x, got %x", exp, salt)
+	}
+}
+
+func TestMySQLParseLogin(t *testing.T) {
+	scramble := bytes.Repeat([]byte{0xaa}, 20)
+	data := make([]byte, 36)
+	data = append(data, "root\x00"...)
+	data = append(data, 20)
+	data = append(data, scramble...)
+	data = append(data, "mysql_native_password\x00"...)
+
+	user, got := MySQLParseLogin(data)
+	if user != "root" {
+		t.Fatalf("unexpected user '%s'", user)
+	} else if !bytes.Equal(got, scramble) {
+		t.Fatalf("expected %x, got %x", scramble, got)
+	} else if h := MySQLHashcatString(MySQLGreetingSalt(), got); h != "$mysqlna$403f59264b2b34606869595f525f635560645352*"+"aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa" {
+		t.Fatalf("unexpected hash %s", h)
+	}
+
+	if user, got = MySQLParseLogin(data[:41]); user != "root" || got != nil {
+		t.Fatalf("unexpected login %s %x", user, got)
+	}
+}
+
+func TestMySQLAuthState(t *testing.T) {
+	scramble := strings.Repeat("\xaa", 20)
+	// capabilities, max packet size, charset and filler
+	login := "\x85\xa6\x0f\x00" + "\x00\x00\x00\x01" + "\x21" + strings.Repeat("\x00", 23) +
+		"root\x00" + "\x14" + scramble + "mysql_native_password\x00"
+	login = string([]byte{byte(len(login)), 0, 0, 1}) + login
+
+	authExpect(t, false, MySQLPort, []authExchange{
+		{false, MySQLGreeting},
+		fromClient(login),
+		fromServer("\x07\x00\x00\x02\x00\x00\x00\x02\x00\x00\x00"),
+	}, NewMySQLAuthState().Parse, AuthCredentials{
+		Protocol:    "mysql",
+		Method:      "native password",
+		Username:    "root",
+		Hash:        "$mysqlna$403f59264b2b34606869595f525f635560645352*" + strings.Repeat("aa", 20),
+		HashcatMode: 11200,
+	})
+}
@@ -0,0 +1,200 @@
+package packets
+
+import (
+	"bytes"
+	"encoding/binary"
+	"time"
+)
+
+const (
+	NetFlowV9Version = 9
+	IPFIXVersion     = 10
+
+	// the default ports of the collectors
+	NetFlowPort = 2055
+	IPFIXPort   = 4739
+
+	flowTemplateIPv4 = 256
+	flowTemplateIPv6 = 257
+	// the application names are exported with a fixed length, since
+	// NetFlow v9 doesn't support variable length fields
+	flowAppNameLen = 16
+)
+
+// information elements from https://www.iana.org/assignments/ipfix/ipfix.xhtml,
+// with the same numbers in NetFlow v9
+const (
+	ieOctetDeltaCount          = 1
+	iePacketDeltaCount         = 2
+	ieProtocolIdentifier       = 4
+	ieTCPControlBits           = 6
+	ieSourceTransportPort      = 7
+	ieSourceIPv4Address        = 8
+	ieDestinationTransportPort = 11
+	ieDestinationIPv4Address   = 12
+	ieFlowEndSysUpTime         = 21
+	ieFlowStartSysUpTime       = 22
+	ieSourceIPv6Address        = 27
+	ieDestinationIPv6Address   = 28
+	ieApplicationName          = 96
+	ieFlowStartMilliseconds    = 152
+	ieFlowEndMilliseconds      = 153
+)
+
+type flowField struct {
+	ID     uint16
+	Length uint16
+}
+
+func flowTemplate(ipv6 bool, ipfix bool) []flowField {
+	fields := []flowField{}
+	if ipv6 {
+		fields = append(fields, flowField{ieSourceIPv6Address, 16}, flowField{ieDestinationIPv6Address, 16})
+	} else {
+		fields = append(fields, flowField{ieSourceIPv4Address, 4}, flowField{ieDestinationIPv4Address, 4})
+	}
+	fields = append(fields,
+		flowField{ieSourceTransportPort, 2},
+		flowField{ieDestinationTransportPort, 2},
+		flowField{ieProtocolIdentifier, 1},
+		flowField{ieTCPControlBits, 1},
+		flowField{ieOctetDeltaCount, 8},
+		flowField{iePacketDeltaCount, 8})
+	if ipfix {
+		fields = append(fields, flowField{ieFlowStartMilliseconds, 8}, flowField{ieFlowEndMilliseconds, 8})
+	} else {
+		fields = append(fields, flowField{ieFlowStartSysUpTime, 4}, flowField{ieFlowEndSysUpTime, 4})
+	}
+	return append(fields, flowField{ieApplicationName, flowAppNameLen})
+}
+
+func writeFlowTemplates(buf *bytes.Buffer, setID uint16, ipfix bool) int {
+	set := bytes.Buffer{}
+	for _, tpl := range []struct {
+		id   uint16
+		ipv6 bool
+	}{
+		{flowTemplateIPv4, false},
+		{flowTemplateIPv6, true},
+	} {
+		fields := flowTemplate(tpl.ipv6, ipfix)
+		binary.Write(&set, binary.BigEndian, tpl.id)
+		binary.Write(&set, binary.BigEndian, uint16(len(fields)))
+		for _, f := range fields {
+			binary.Write(&set, binary.BigEndian, f)
+		}
+	}
+
+	binary.Write(buf, binary.BigEndian, setID)
+	binary.Write(buf, binary.BigEndian, uint16(4+set.Len()))
+	buf.Write(set.Bytes())
+	return 2
+}
+
+// sysUptime returns the milliseconds elapsed from bootTime to t, the flows
+// seen before the exporter started are reported at its boot.
+func sysUptime(t time.Time, bootTime time.Time) uint32 {
+	if t.Before(bootTime) {
+		return 0
+	}
+	return uint32(t.Sub(bootTime) / time.Millisecond)
+}
+
+func writeFlowRecord(buf *bytes.Buffer, f *Flow, ipfix bool, bootTime time.Time) {
+	if f.IsIPv6() {
+		buf.Write(f.SrcIP.To16())
+		buf.Write(f.DstIP.To16())
+	} else {
+		buf.Write(f.SrcIP.To4())
+		buf.Write(f.DstIP.To4())
+	}
+	binary.Write(buf, binary.BigEndian, f.SrcPort)
+	binary.Write(buf, binary.BigEndian, f.DstPort)
+	buf.WriteByte(byte(f.Protocol))
+	buf.WriteByte(f.TCPFlags)
+	binary.Write(buf, binary.BigEndian, f.Bytes)
+	binary.Write(buf, binary.BigEndian, f.Packets)
+	if ipfix {
+		binary.Write(buf, binary.BigEndian, uint64(f.FirstSeen.UnixNano()/int64(time.Millisecond)))
+		binary.Write(buf, binary.BigEndian, uint64(f.LastSeen.UnixNano()/int64(time.Millisecond)))
+	} else {
+		binary.Write(buf, binary.BigEndian, sysUptime(f.FirstSeen, bootTime))
+		binary.Write(buf, binary.BigEndian, sysUptime(f.LastSeen, bootTime))
+	}
+	app := make([]byte, flowAppNameLen)
+	copy(app, f.App)
+	buf.Write(app)
+}
+
+// writeFlowData writes the data sets of the flows, grouped by template, and
+// returns the number of records.
+func writeFlowData(buf *bytes.Buffer, flows []*Flow, ipfix bool, bootTime time.Time) int {
+	records := 0
+	for _, tpl := range []struct {
+		id   uint16
+		ipv6 bool
+	}{
+		{flowTemplateIPv4, false},
+		{flowTemplateIPv6, true},
+	} {
+		set := bytes.Buffer{}
+		for _, f := range flows {
+			if f.IsIPv6() == tpl.ipv6 {
+				writeFlowRecord(&set, f, ipfix, bootTime)
+				records++
+			}
+		}
+		if set.Len() == 0 {
+			continue
+		}
+		// sets are padded to 32 bits
+		for set.Len()%4 != 0 {
+			set.WriteByte(0)
+		}
+		binary.Write(buf, binary.BigEndian, tpl.id)
+		binary.Write(buf, binary.BigEndian, uint16(4+set.Len()))
+		buf.Write(set.Bytes())
+	}
+	return records
+}
+
+// NewNetFlowV9 encodes the flows as a NetFlow v9 export packet (RFC 3954),
+// seq is the number of packets sent before this one and the flow times are
+// relative to bootTime.
+func NewNetFlowV9(flows []*Flow, withTemplates bool, bootTime time.Time, now time.Time, seq uint32, sourceID uint32) []byte {
+	body := bytes.Buffer{}
+	count := 0
+	if withTemplates {
+		count += writeFlowTemplates(&body, 0, false)
+	}
+	count += writeFlowData(&body, flows, false, bootTime)
+
+	buf := bytes.Buffer{}
+	binary.Write(&buf, binary.BigEndian, uint16(NetFlowV9Version))
+	binary.Write(&buf, binary.BigEndian, uint16(count))
+	binary.Write(&buf, binary.BigEndian, sysUptime(now, bootTime))
+	binary.Write(&buf, binary.BigEndian, uint32(now.Unix()))
+	binary.Write(&buf, binary.BigEndian, seq)
+	binary.Write(&buf, binary.BigEndian, sourceID)
+	buf.Write(body.Bytes())
+	return buf.Bytes()
+}
+
+// NewIPFIX encodes the flows as an IPFIX message (RFC 7011), seq is the
+// number of data records sent before this message.
+func NewIPFIX(flows []*Flow, withTemplates bool, now time.Time, seq uint32, domainID uint32) []byte {
+	body := bytes.Buffer{}
+	if withTemplates {
+		writeFlowTemplates(&body, 2, true)
+	}
+	writeFlowData(&body, flows, true, time.Time{})
+
+	buf := bytes.Buffer{}
+	binary.Write(&buf, binary.BigEndian, uint16(IPFIXVersion))
+	binary.Write(&buf, binary.BigEndian, uint16(16+body.Len()))
+	binary.Write(&buf, binary.BigEndian, uint32(now.Unix()))
+	binary.Write(&buf, binary.BigEndian, seq)
+	binary.Write(&buf, binary.BigEndian, domainID)
+	buf.Write(body.Bytes())
+	return buf.Bytes()
+}
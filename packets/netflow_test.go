@@ -0,0 +1,133 @@
+package packets
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/gopacket/gopacket/layers"
+)
+
+func testFlows(start time.Time) []*Flow {
+	return []*Flow{
+		{
+			Protocol:  layers.IPProtocolTCP,
+			SrcIP:     testFlowClient,
+			DstIP:     testFlowServer,
+			SrcPort:   40000,
+			DstPort:   443,
+			Bytes:     1500,
+			Packets:   3,
+			TCPFlags:  FlowTCPSyn | FlowTCPAck,
+			FirstSeen: start.Add(time.Second),
+			LastSeen:  start.Add(2 * time.Second),
+			App:       "tls",
+		},
+		{
+			Protocol:  layers.IPProtocolUDP,
+			SrcIP:     net.ParseIP("fe80::1"),
+			DstIP:     net.ParseIP("ff02::fb"),
+			SrcPort:   5353,
+			DstPort:   5353,
+			Bytes:     120,
+			Packets:   1,
+			FirstSeen: start.Add(time.Second),
+			LastSeen:  start.Add(time.Second),
+		},
+	}
+}
+
+// walks the sets after the header and returns their ids and contents
+func flowSets(t *testing.T, msg []byte, headerSize int) map[uint16][]byte {
+	sets := make(map[uint16][]byte)
+	for off := headerSize; off < len(msg); {
+		if off+4 > len(msg) {
+			t.Fatalf("truncated set header at %d", off)
+		}
+		id := binary.BigEndian.Uint16(msg[off:])
+		size := int(binary.BigEndian.Uint16(msg[off+2:]))
+		if size < 4 || off+size > len(msg) {
+			t.Fatalf("invalid set size %d at %d", size, off)
+		}
+		sets[id] = msg[off+4 : off+size]
+		off += size
+	}
+	return sets
+}
+
+func TestNewNetFlowV9(t *testing.T) {
+	boot := time.Now().Add(-time.Hour)
+	now := boot.Add(time.Hour)
+	msg := NewNetFlowV9(testFlows(boot), true, boot, now, 7, 42)
+
+	if v := binary.BigEndian.Uint16(msg[0:]); v != 9 {
+		t.Fatalf("unexpected version %d", v)
+	} else if count := binary.BigEndian.Uint16(msg[2:]); count != 4 {
+		t.Fatalf("expected 2 templates and 2 records, got %d", count)
+	} else if uptime := binary.BigEndian.Uint32(msg[4:]); uptime != 3600000 {
+		t.Fatalf("unexpected uptime %d", uptime)
+	} else if seq := binary.BigEndian.Uint32(msg[12:]); seq != 7 {
+		t.Fatalf("unexpected sequence %d", seq)
+	} else if source := binary.BigEndian.Uint32(msg[16:]); source != 42 {
+		t.Fatalf("unexpected source id %d", source)
+	}
+
+	sets := flowSets(t, msg, 20)
+	if _, found := sets[0]; !found {
+		t.Fatal("missing template flowset")
+	}
+
+	v4 := sets[flowTemplateIPv4]
+	if !net.IP(v4[0:4]).Equal(testFlowClient) || binary.BigEndian.Uint16(v4[10:]) != 443 || v4[12] != 6 {
+		t.Fatalf("unexpected IPv4 record %x", v4)
+	} else if bytes := binary.BigEndian.Uint64(v4[14:]); bytes != 1500 {
+		t.Fatalf("unexpected bytes %d", bytes)
+	} else if first := binary.BigEndian.Uint32(v4[30:]); first != 1000 {
+		t.Fatalf("unexpected first switched %d", first)
+	} else if string(v4[38:41]) != "tls" {
+		t.Fatalf("unexpected application name %q", v4[38:54])
+	}
+
+	if v6 := sets[flowTemplateIPv6]; !net.IP(v6[0:16]).Equal(net.ParseIP("fe80::1")) || v6[36] != 17 {
+		t.Fatalf("unexpected IPv6 record %x", v6)
+	}
+}
+
+func TestNewNetFlowV9BeforeBoot(t *testing.T) {
+	boot := time.Now()
+	// the flows were seen before the exporter started
+	flows := testFlows(boot.Add(-time.Minute))
+	msg := NewNetFlowV9(flows[:1], false, boot, boot.Add(time.Second), 0, 0)
+
+	v4 := flowSets(t, msg, 20)[flowTemplateIPv4]
+	if first := binary.BigEndian.Uint32(v4[30:]); first != 0 {
+		t.Fatalf("expected the first switched to be clamped to 0, got %d", first)
+	} else if last := binary.BigEndian.Uint32(v4[34:]); last != 0 {
+		t.Fatalf("expected the last switched to be clamped to 0, got %d", last)
+	}
+}
+
+func TestNewIPFIX(t *testing.T) {
+	start := time.Now()
+	msg := NewIPFIX(testFlows(start), false, start, 100, 1)
+
+	if v := binary.BigEndian.Uint16(msg[0:]); v != 10 {
+		t.Fatalf("unexpected version %d", v)
+	} else if size := binary.BigEndian.Uint16(msg[2:]); int(size) != len(msg) {
+		t.Fatalf("length %d doesn't match the message size %d", size, len(msg))
+	} else if seq := binary.BigEndian.Uint32(msg[8:]); seq != 100 {
+		t.Fatalf("unexpected sequence %d", seq)
+	}
+
+	sets := flowSets(t, msg, 16)
+	if _, found := sets[2]; found {
+		t.Fatal("unexpected template set")
+	}
+
+	v4 := sets[flowTemplateIPv4]
+	exp := uint64(start.Add(time.Second).UnixNano() / int64(time.Millisecond))
+	if first := binary.BigEndian.Uint64(v4[30:]); first != exp {
+		t.Fatalf("expected flow start %d, got %d", exp, first)
+	}
+}
@@ -57,6 +57,7 @@ type NTLMChallengeResponseParsed struct {
 	ServerChallenge string
 	User            string
 	Domain          string
+	Workstation     string
 	LmHash          string
 	NtHashOne       string
 	NtHashTwo       string
@@ -154,6 +155,7 @@ func (sr *NTLMChallengeResponse) ParsedNtLMv2() (NTLMChallengeResponseParsed, er
 		ServerChallenge: sr.getServerChallenge(),
 		User:            strings.Replace(string(b[r.UserOffset:r.UserOffset+r.UserLen]), "\x00", "", -1),
 		Domain:          strings.Replace(string(b[r.DomainOffset:r.DomainOffset+r.DomainLen]), "\x00", "", -1),
+		Workstation:     strings.Replace(string(b[r.HostOffset:r.HostOffset+r.HostLen]), "\x00", "", -1),
 		NtHashOne:       hex.EncodeToString(nthash[:16]), // first part of the hash is 16 bytes
 		NtHashTwo:       hex.EncodeToString(nthash[16:]),
 	}, nil
@@ -176,7 +178,9 @@ func (sr NTLMChallengeResponse) ParsedNtLMv1() (NTLMChallengeResponseParsed, err
 		ServerChallenge: sr.getServerChallenge(),
 		User:            strings.Replace(string(b[r.UserOffset:r.UserOffset+r.UserLen]), "\x00", "", -1),
 		Domain:          strings.Replace(string(b[r.DomainOffset:r.DomainOffset+r.DomainLen]), "\x00", "", -1),
+		Workstation:     strings.Replace(string(b[r.HostOffset:r.HostOffset+r.HostLen]), "\x00", "", -1),
 		LmHash:          hex.EncodeToString(b[r.LmOffset : r.LmOffset+r.LmLen]),
+		NtHashOne:       hex.EncodeToString(b[r.NtOffset : r.NtOffset+r.NtLen]),
 	}, nil
 }
 
@@ -235,3 +239,20 @@ func (data NTLMChallengeResponseParsed) LcString() string {
 	}
 	return data.User + "::" + data.Domain + ":" + data.ServerChallenge + ":" + data.NtHashOne + ":" + data.NtHashTwo + "\n"
 }
+
+// HashcatMode returns the hashcat mode to crack this response with.
+func (data NTLMChallengeResponseParsed) HashcatMode() int {
+	if data.Type == NtlmV1 {
+		return 5500
+	}
+	return 5600
+}
+
+// HashcatString returns the response in the NetNTLMv1/v2 format used by both
+// hashcat and john.
+func (data NTLMChallengeResponseParsed) HashcatString() string {
+	if data.Type == NtlmV1 {
+		return data.User + "::" + data.Domain + ":" + data.LmHash + ":" + data.NtHashOne + ":" + data.ServerChallenge
+	}
+	return data.User + "::" + data.Domain + ":" + data.ServerChallenge + ":" + data.NtHashOne + ":" + data.NtHashTwo
+}
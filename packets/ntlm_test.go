@@ -161,4 +161,38 @@ func TestNTLMStateAddServerResponse(t *testing.T) {
 	}
 }
 
+func TestNTLMHashcatString(t *testing.T) {
+	v1 := NTLMChallengeResponseParsed{
+		Type:            NtlmV1,
+		ServerChallenge: "1122334455667788",
+		User:            "user",
+		Domain:          "DOMAIN",
+		LmHash:          "aa",
+		NtHashOne:       "bb",
+	}
+	v2 := NTLMChallengeResponseParsed{
+		Type:            NtlmV2,
+		ServerChallenge: "1122334455667788",
+		User:            "user",
+		Domain:          "DOMAIN",
+		NtHashOne:       "cc",
+		NtHashTwo:       "dd",
+	}
+
+	var units = []struct {
+		got interface{}
+		exp interface{}
+	}{
+		{v1.HashcatMode(), 5500},
+		{v1.HashcatString(), "user::DOMAIN:aa:bb:1122334455667788"},
+		{v2.HashcatMode(), 5600},
+		{v2.HashcatString(), "user::DOMAIN:1122334455667788:cc:dd"},
+	}
+	for _, u := range units {
+		if !reflect.DeepEqual(u.exp, u.got) {
+			t.Fatalf("expected '%v', got '%v'", u.exp, u.got)
+		}
+	}
+}
+
 // TODO: add tests for the rest of NTLM :P
@@ -0,0 +1,142 @@
+package packets
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+const (
+	PostgresPort = 5432
+
+	postgresProtocol3   = 196608
+	postgresAuthClear   = 3
+	postgresAuthMD5     = 5
+	postgresAuthSASL    = 10
+	postgresMaxStartup  = 10000
+	postgresPasswordMsg = 'p'
+	postgresAuthMsg     = 'R'
+)
+
+type postgresFlow struct {
+	user     string
+	database string
+	method   int
+	salt     []byte
+}
+
+// PostgresAuthState tracks PostgreSQL connections to extract the clear text
+// passwords and the MD5 challenge responses sent by clients.
+type PostgresAuthState struct {
+	flows authFlows
+}
+
+func NewPostgresAuthState() *PostgresAuthState {
+	return &PostgresAuthState{
+		flows: newAuthFlows(),
+	}
+}
+
+// postgresStartup parses the parameters of a startup message.
+func postgresStartup(data []byte) (map[string]string, bool) {
+	if len(data) < 8 {
+		return nil, false
+	}
+
+	size := int(binary.BigEndian.Uint32(data))
+	if size != len(data) || size > postgresMaxStartup || binary.BigEndian.Uint32(data[4:]) != postgresProtocol3 {
+		return nil, false
+	}
+
+	params := make(map[string]string)
+	fields := bytes.Split(data[8:], []byte{0})
+	for i := 0; i+1 < len(fields); i += 2 {
+		if len(fields[i]) > 0 {
+			params[string(fields[i])] = string(fields[i+1])
+		}
+	}
+	return params, true
+}
+
+// postgresMessages splits a payload in typed messages.
+func postgresMessages(data []byte) (types []byte, bodies [][]byte) {
+	for len(data) >= 5 {
+		size := int(binary.BigEndian.Uint32(data[1:]))
+		if size < 4 || size+1 > len(data) {
+			break
+		}
+		types = append(types, data[0])
+		bodies = append(bodies, data[5:size+1])
+		data = data[size+1:]
+	}
+	return
+}
+
+// Parse feeds the state with a payload of the connection and returns the
+// credentials once the client sent its password.
+func (s *PostgresAuthState) Parse(flow AuthFlow, payload []byte) *AuthCredentials {
+	s.flows.Lock()
+	defer s.flows.Unlock()
+
+	if flow.FromClient {
+		if params, ok := postgresStartup(payload); ok {
+			f := s.flows.get(flow, func() interface{} {
+				return &postgresFlow{}
+			}).(*postgresFlow)
+			f.user = params["user"]
+			f.database = params["database"]
+			return nil
+		}
+	}
+
+	state := s.flows.get(flow, nil)
+	if state == nil {
+		return nil
+	}
+	f := state.(*postgresFlow)
+
+	types, bodies := postgresMessages(payload)
+	for i, t := range types {
+		body := bodies[i]
+		if !flow.FromClient && t == postgresAuthMsg && len(body) >= 4 {
+			f.method = int(binary.BigEndian.Uint32(body))
+			if f.method == postgresAuthMD5 && len(body) >= 8 {
+				f.salt = append([]byte(nil), body[4:8]...)
+			}
+		} else if flow.FromClient && t == postgresPasswordMsg {
+			s.flows.del(flow)
+			return f.credentials(flow, body)
+		}
+	}
+
+	return nil
+}
+
+func (f *postgresFlow) credentials(flow AuthFlow, body []byte) *AuthCredentials {
+	switch f.method {
+	case postgresAuthClear:
+		creds := flow.credentials("postgres", "password")
+		creds.Username = f.user
+		creds.Password = string(bytes.TrimRight(body, "\x00"))
+		creds.Info = f.database
+		return creds
+	case postgresAuthMD5:
+		hash := string(bytes.TrimRight(body, "\x00"))
+		if !strings.HasPrefix(hash, "md5") || f.salt == nil {
+			return nil
+		}
+		creds := flow.credentials("postgres", "md5")
+		creds.Username = f.user
+		creds.Hash = fmt.Sprintf("$postgres$%s*%x*%s", f.user, f.salt, hash[3:])
+		creds.HashcatMode = 11100
+		creds.Info = f.database
+		return creds
+	case postgresAuthSASL:
+		creds := flow.credentials("postgres", "scram")
+		creds.Username = f.user
+		creds.Info = f.database
+		return creds
+	}
+	return nil
+}
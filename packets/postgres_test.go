@@ -0,0 +1,64 @@
+package packets
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+func postgresMessage(t byte, body string) string {
+	size := make([]byte, 4)
+	binary.BigEndian.PutUint32(size, uint32(len(body)+4))
+	return string(t) + string(size) + body
+}
+
+func postgresStartupMessage(params ...string) string {
+	body := "\x00\x03\x00\x00"
+	for _, p := range params {
+		body += p + "\x00"
+	}
+	body += "\x00"
+
+	size := make([]byte, 4)
+	binary.BigEndian.PutUint32(size, uint32(len(body)+4))
+	return string(size) + body
+}
+
+func TestPostgresStartup(t *testing.T) {
+	params, ok := postgresStartup([]byte(postgresStartupMessage("user", "bob", "database", "shop")))
+	if !ok || params["user"] != "bob" || params["database"] != "shop" {
+		t.Fatalf("unexpected parameters %v", params)
+	}
+
+	if _, ok = postgresStartup([]byte("\x00\x00\x00\x08\x04\xd2\x16\x2f")); ok {
+		t.Fatal("ssl requests are not startup messages")
+	}
+}
+
+func TestPostgresMD5(t *testing.T) {
+	authExpect(t, false, PostgresPort, []authExchange{
+		fromClient(postgresStartupMessage("user", "bob", "database", "shop")),
+		fromServer(postgresMessage('R', "\x00\x00\x00\x05\xde\xad\xbe\xef")),
+		fromClient(postgresMessage('p', "md5a3556571e93b0d20722ba62be61e8c2d\x00")),
+		fromServer(postgresMessage('R', "\x00\x00\x00\x00")),
+	}, NewPostgresAuthState().Parse, AuthCredentials{
+		Protocol:    "postgres",
+		Method:      "md5",
+		Username:    "bob",
+		Hash:        "$postgres$bob*deadbeef*a3556571e93b0d20722ba62be61e8c2d",
+		HashcatMode: 11100,
+		Info:        "shop",
+	})
+}
+
+func TestPostgresCleartext(t *testing.T) {
+	authExpect(t, false, PostgresPort, []authExchange{
+		fromClient(postgresStartupMessage("user", "alice")),
+		fromServer(postgresMessage('R', "\x00\x00\x00\x03")),
+		fromClient(postgresMessage('p', "secret\x00")),
+	}, NewPostgresAuthState().Parse, AuthCredentials{
+		Protocol: "postgres",
+		Method:   "password",
+		Username: "alice",
+		Password: "secret",
+	})
+}
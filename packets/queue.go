@@ -14,11 +14,15 @@ import (
 	"github.com/gopacket/gopacket/pcap"
 )
 
+const decryptedQueueSize = 1024
+
 type Activity struct {
 	IP     net.IP
 	MAC    net.HardwareAddr
 	Meta   map[string]string
 	Source bool
+	// passive fingerprint of the operating system, if any
+	Evidence *network.OSEvidence
 }
 
 type Traffic struct {
@@ -41,6 +45,13 @@ type Queue struct {
 	Protos     sync.Map
 	Traffic    sync.Map
 	Activities chan Activity
+	// packets decrypted by other modules, such as the 802.11 data
+	// frames decrypted by wifi.decrypt, to be parsed by the sniffer.
+	Decrypted chan gopacket.Packet
+	// set while the sniffer is reading Decrypted
+	decrypting uint32
+	// traffic accounting by flow, enabled by the flows module
+	Flows *FlowTable
 
 	iface      *network.Endpoint
 	handle     *pcap.Handle
@@ -62,6 +73,8 @@ func NewQueue(iface *network.Endpoint) (q *Queue, err error) {
 		Traffic:    sync.Map{},
 		Stats:      Stats{},
 		Activities: make(chan Activity),
+		Decrypted:  make(chan gopacket.Packet, decryptedQueueSize),
+		Flows:      NewFlowTable(),
 
 		writes: &sync.WaitGroup{},
 		iface:  iface,
@@ -121,13 +134,14 @@ func (q *Queue) trackProtocols(pkt gopacket.Packet) {
 	}
 }
 
-func (q *Queue) trackActivity(eth *layers.Ethernet, address net.IP, meta map[string]string, pktSize uint64, isSent bool) {
+func (q *Queue) trackActivity(eth *layers.Ethernet, address net.IP, meta map[string]string, evidence *network.OSEvidence, pktSize uint64, isSent bool) {
 	// push to activity channel
 	q.Activities <- Activity{
-		IP:     address,
-		MAC:    eth.SrcMAC,
-		Meta:   meta,
-		Source: isSent,
+		IP:       address,
+		MAC:      eth.SrcMAC,
+		Meta:     meta,
+		Source:   isSent,
+		Evidence: evidence,
 	}
 
 	// initialize or update stats
@@ -183,6 +197,7 @@ func (q *Queue) worker() {
 		}
 
 		q.trackProtocols(pkt)
+		q.Flows.Track(pkt)
 
 		pktSize := uint64(len(pkt.Data()))
 
@@ -214,19 +229,59 @@ func (q *Queue) worker() {
 			isFromLAN := q.iface.Net.Contains(srcIP)
 			if !isFromMe && isFromLAN {
 				meta := q.getPacketMeta(pkt)
-				q.trackActivity(eth, srcIP, meta, pktSize, true)
+				evidence := FingerprintGetEvidence(pkt)
+				q.trackActivity(eth, srcIP, meta, evidence, pktSize, true)
+			} else if srcIP.IsUnspecified() {
+				// DHCP requests are sent before the client has an address
+				if address, evidence := DHCPGetEvidence(pkt); evidence != nil && q.iface.Net.Contains(address) {
+					q.trackActivity(eth, address, nil, evidence, pktSize, true)
+				}
 			}
 
 			// something going to someone on the LAN
 			isToMe := q.iface.IP.Equal(dstIP) || q.iface.IPv6.Equal(dstIP)
 			isToLAN := q.iface.Net.Contains(dstIP)
 			if !isToMe && isToLAN {
-				q.trackActivity(eth, dstIP, nil, pktSize, false)
+				q.trackActivity(eth, dstIP, nil, nil, pktSize, false)
 			}
 		}
 	}
 }
 
+// ConsumeDecrypted is called by the sniffer when it starts or stops reading
+// the Decrypted channel, the packets still in it are discarded so that they
+// are not parsed as new on the next start.
+func (q *Queue) ConsumeDecrypted(consume bool) {
+	if consume {
+		atomic.StoreUint32(&q.decrypting, 1)
+	} else {
+		atomic.StoreUint32(&q.decrypting, 0)
+	}
+
+	for {
+		select {
+		case <-q.Decrypted:
+		default:
+			return
+		}
+	}
+}
+
+// FeedDecrypted passes a decrypted packet to the sniffer, the packet is
+// dropped if the sniffer is not running or can't keep up.
+func (q *Queue) FeedDecrypted(pkt gopacket.Packet) bool {
+	if atomic.LoadUint32(&q.decrypting) == 0 {
+		return false
+	}
+
+	select {
+	case q.Decrypted <- pkt:
+		return true
+	default:
+		return false
+	}
+}
+
 func (q *Queue) Send(raw []byte) error {
 	q.Lock()
 	defer q.Unlock()
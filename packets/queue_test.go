@@ -4,6 +4,8 @@ import (
 	"net"
 	"reflect"
 	"testing"
+
+	"github.com/gopacket/gopacket"
 )
 
 func TestQueueActivity(t *testing.T) {
@@ -62,4 +64,27 @@ func TestQueueStats(t *testing.T) {
 	}
 }
 
+func TestQueueFeedDecrypted(t *testing.T) {
+	q := &Queue{Decrypted: make(chan gopacket.Packet, 2)}
+	pkt := gopacket.NewPacket([]byte{0}, gopacket.LayerTypePayload, gopacket.Default)
+
+	if q.FeedDecrypted(pkt) {
+		t.Fatal("expected the packet to be dropped without a consumer")
+	}
+
+	q.ConsumeDecrypted(true)
+	if !q.FeedDecrypted(pkt) || !q.FeedDecrypted(pkt) {
+		t.Fatal("expected the packets to be queued")
+	} else if q.FeedDecrypted(pkt) {
+		t.Fatal("expected the packet to be dropped with a full queue")
+	}
+
+	q.ConsumeDecrypted(false)
+	if len(q.Decrypted) != 0 {
+		t.Fatalf("expected the queue to be drained, %d packets left", len(q.Decrypted))
+	} else if q.FeedDecrypted(pkt) {
+		t.Fatal("expected the packet to be dropped after the consumer stopped")
+	}
+}
+
 // TODO: add tests for the rest of queue.go
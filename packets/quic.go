@@ -0,0 +1,402 @@
+package packets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+const (
+	QUICVersion1 = 0x00000001
+	QUICVersion2 = 0x6b3343cf
+
+	// clients pad the datagrams carrying Initial packets to this size
+	QUICMinInitialSize = 1200
+
+	quicLongHeader     = 0x80
+	quicFixedBit       = 0x40
+	quicInitialTypeV1  = 0x00
+	quicInitialTypeV2  = 0x01
+	quicMaxConnIDLen   = 20
+	quicSampleSize     = 16
+	quicMaxCrypto      = 65535
+	quicInitialKeySize = 16
+	quicInitialIVSize  = 12
+	quicSecretSize     = 32
+)
+
+const (
+	quicFramePadding  = 0x00
+	quicFramePing     = 0x01
+	quicFrameAck      = 0x02
+	quicFrameAckECN   = 0x03
+	quicFrameCrypto   = 0x06
+	quicFrameClose    = 0x1c
+	quicFrameCloseApp = 0x1d
+)
+
+const (
+	quicLabelPrefix   = "tls13 "
+	quicClientInitial = "client in"
+	quicLabelKeyV1    = "quic key"
+	quicLabelIVV1     = "quic iv"
+	quicLabelHPV1     = "quic hp"
+	quicLabelKeyV2    = "quicv2 key"
+	quicLabelIVV2     = "quicv2 iv"
+	quicLabelHPV2     = "quicv2 hp"
+)
+
+var (
+	ErrQUICNotInitial = errors.New("not a QUIC Initial packet")
+	ErrQUICVersion    = errors.New("unsupported QUIC version")
+	ErrQUICShort      = errors.New("truncated QUIC packet")
+	ErrQUICFrame      = errors.New("unsupported QUIC frame")
+
+	quicSaltV1 = []byte{
+		0x38, 0x76, 0x2c, 0xf7, 0xf5, 0x59, 0x34, 0xb3, 0x4d, 0x17,
+		0x9a, 0xe6, 0xa4, 0xc8, 0x0c, 0xad, 0xcc, 0xbb, 0x7f, 0x0a,
+	}
+	quicSaltV2 = []byte{
+		0x0d, 0xed, 0xe3, 0xde, 0xf7, 0x00, 0xa6, 0xdb, 0x81, 0x93,
+		0x81, 0xbe, 0x6e, 0x26, 0x9d, 0xcb, 0xf9, 0xbd, 0x2e, 0xd9,
+	}
+)
+
+// QUICKeys are the packet protection keys of one side of a connection.
+type QUICKeys struct {
+	Key []byte
+	IV  []byte
+	HP  []byte
+}
+
+// QUICCryptoFrame is a chunk of the TLS handshake stream.
+type QUICCryptoFrame struct {
+	Offset uint64
+	Data   []byte
+}
+
+// QUICInitial is a decrypted client Initial packet.
+type QUICInitial struct {
+	Version      uint32
+	DCID         []byte
+	SCID         []byte
+	Token        []byte
+	PacketNumber uint64
+	Crypto       []QUICCryptoFrame
+}
+
+func hkdfExtract(salt, secret []byte) []byte {
+	mac := hmac.New(sha256.New, salt)
+	mac.Write(secret)
+	return mac.Sum(nil)
+}
+
+func hkdfExpandLabel(secret []byte, label string, size int) []byte {
+	label = quicLabelPrefix + label
+	info := make([]byte, 0, 4+len(label))
+	info = binary.BigEndian.AppendUint16(info, uint16(size))
+	info = append(info, byte(len(label)))
+	info = append(info, label...)
+	// empty context
+	info = append(info, 0)
+
+	out := make([]byte, 0, size)
+	prev := []byte{}
+	for i := byte(1); len(out) < size; i++ {
+		mac := hmac.New(sha256.New, secret)
+		mac.Write(prev)
+		mac.Write(info)
+		mac.Write([]byte{i})
+		prev = mac.Sum(nil)
+		out = append(out, prev...)
+	}
+	return out[:size]
+}
+
+// QUICClientInitialKeys derives the keys protecting the client Initial
+// packets from the destination connection id, as per RFC 9001 and 9369.
+func QUICClientInitialKeys(version uint32, dcid []byte) (*QUICKeys, error) {
+	salt, labelKey, labelIV, labelHP := quicSaltV1, quicLabelKeyV1, quicLabelIVV1, quicLabelHPV1
+	if version == QUICVersion2 {
+		salt, labelKey, labelIV, labelHP = quicSaltV2, quicLabelKeyV2, quicLabelIVV2, quicLabelHPV2
+	} else if version != QUICVersion1 {
+		return nil, ErrQUICVersion
+	}
+
+	initial := hkdfExtract(salt, dcid)
+	client := hkdfExpandLabel(initial, quicClientInitial, quicSecretSize)
+
+	return &QUICKeys{
+		Key: hkdfExpandLabel(client, labelKey, quicInitialKeySize),
+		IV:  hkdfExpandLabel(client, labelIV, quicInitialIVSize),
+		HP:  hkdfExpandLabel(client, labelHP, quicInitialKeySize),
+	}, nil
+}
+
+// quicVarint decodes a variable length integer, returning the value and
+// the number of bytes it took.
+func quicVarint(data []byte) (uint64, int, error) {
+	if len(data) == 0 {
+		return 0, 0, ErrQUICShort
+	}
+	size := 1 << (data[0] >> 6)
+	if len(data) < size {
+		return 0, 0, ErrQUICShort
+	}
+	v := uint64(data[0] & 0x3f)
+	for _, b := range data[1:size] {
+		v = v<<8 | uint64(b)
+	}
+	return v, size, nil
+}
+
+// QUICIsInitial returns true if the datagram starts with a long header
+// Initial packet of a supported version.
+func QUICIsInitial(data []byte) bool {
+	if len(data) < 5 || data[0]&(quicLongHeader|quicFixedBit) != quicLongHeader|quicFixedBit {
+		return false
+	}
+	packetType := (data[0] >> 4) & 0x03
+	switch binary.BigEndian.Uint32(data[1:]) {
+	case QUICVersion1:
+		return packetType == quicInitialTypeV1
+	case QUICVersion2:
+		return packetType == quicInitialTypeV2
+	}
+	return false
+}
+
+// ParseQUICInitial removes the protection of the client Initial packet at
+// the start of a datagram and returns its CRYPTO frames.
+func ParseQUICInitial(data []byte) (*QUICInitial, error) {
+	if !QUICIsInitial(data) {
+		return nil, ErrQUICNotInitial
+	}
+
+	pkt := &QUICInitial{Version: binary.BigEndian.Uint32(data[1:])}
+	off := 5
+
+	for _, id := range []*[]byte{&pkt.DCID, &pkt.SCID} {
+		if off >= len(data) {
+			return nil, ErrQUICShort
+		}
+		size := int(data[off])
+		off++
+		if size > quicMaxConnIDLen || off+size > len(data) {
+			return nil, ErrQUICShort
+		}
+		*id = data[off : off+size]
+		off += size
+	}
+
+	tokenLen, n, err := quicVarint(data[off:])
+	if err != nil {
+		return nil, err
+	}
+	off += n
+	if uint64(len(data)-off) < tokenLen {
+		return nil, ErrQUICShort
+	}
+	pkt.Token = data[off : off+int(tokenLen)]
+	off += int(tokenLen)
+
+	length, n, err := quicVarint(data[off:])
+	if err != nil {
+		return nil, err
+	}
+	off += n
+	// the packet number offset
+	if uint64(len(data)-off) < length || off+4+quicSampleSize > len(data) {
+		return nil, ErrQUICShort
+	}
+	end := off + int(length)
+
+	keys, err := QUICClientInitialKeys(pkt.Version, pkt.DCID)
+	if err != nil {
+		return nil, err
+	}
+
+	// remove the header protection from a copy of the header
+	block, err := aes.NewCipher(keys.HP)
+	if err != nil {
+		return nil, err
+	}
+	mask := make([]byte, aes.BlockSize)
+	block.Encrypt(mask, data[off+4:off+4+quicSampleSize])
+
+	header := make([]byte, off+4)
+	copy(header, data)
+	header[0] ^= mask[0] & 0x0f
+	pnLen := int(header[0]&0x03) + 1
+	if off+pnLen > end {
+		return nil, ErrQUICShort
+	}
+	for i := 0; i < pnLen; i++ {
+		header[off+i] ^= mask[1+i]
+		pkt.PacketNumber = pkt.PacketNumber<<8 | uint64(header[off+i])
+	}
+	header = header[:off+pnLen]
+
+	block, err = aes.NewCipher(keys.Key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, len(keys.IV))
+	copy(nonce, keys.IV)
+	for i := 0; i < 8; i++ {
+		nonce[len(nonce)-1-i] ^= byte(pkt.PacketNumber >> (8 * i))
+	}
+
+	payload, err := aead.Open(nil, nonce, data[off+pnLen:end], header)
+	if err != nil {
+		return nil, fmt.Errorf("can't decrypt QUIC Initial: %v", err)
+	}
+
+	if pkt.Crypto, err = quicCryptoFrames(payload); err != nil {
+		return nil, err
+	}
+
+	return pkt, nil
+}
+
+// quicCryptoFrames returns the CRYPTO frames of a decrypted payload,
+// skipping the other frames allowed in Initial packets.
+func quicCryptoFrames(payload []byte) ([]QUICCryptoFrame, error) {
+	frames := make([]QUICCryptoFrame, 0)
+	varints := func(num int) error {
+		for i := 0; i < num; i++ {
+			_, n, err := quicVarint(payload)
+			if err != nil {
+				return err
+			}
+			payload = payload[n:]
+		}
+		return nil
+	}
+
+	for len(payload) > 0 {
+		frameType := payload[0]
+		payload = payload[1:]
+
+		switch frameType {
+		case quicFramePadding, quicFramePing:
+		case quicFrameAck, quicFrameAckECN:
+			// largest acknowledged, delay, range count and first range
+			if err := varints(2); err != nil {
+				return nil, err
+			}
+			ranges, n, err := quicVarint(payload)
+			if err != nil {
+				return nil, err
+			}
+			payload = payload[n:]
+			if ranges > uint64(len(payload)) {
+				return nil, ErrQUICShort
+			}
+			num := 1 + 2*int(ranges)
+			if frameType == quicFrameAckECN {
+				num += 3
+			}
+			if err := varints(num); err != nil {
+				return nil, err
+			}
+		case quicFrameCrypto:
+			offset, n, err := quicVarint(payload)
+			if err != nil {
+				return nil, err
+			}
+			payload = payload[n:]
+			size, n, err := quicVarint(payload)
+			if err != nil {
+				return nil, err
+			}
+			payload = payload[n:]
+			if size > uint64(len(payload)) {
+				return nil, ErrQUICShort
+			}
+			frames = append(frames, QUICCryptoFrame{Offset: offset, Data: payload[:size]})
+			payload = payload[size:]
+		case quicFrameClose, quicFrameCloseApp:
+			// error code, frame type and reason, nothing follows
+			return frames, nil
+		default:
+			return nil, ErrQUICFrame
+		}
+	}
+
+	return frames, nil
+}
+
+// QUICClientHellos reassembles the ClientHello messages split across the
+// CRYPTO frames of several Initial packets.
+type QUICClientHellos struct {
+	flows authFlows
+}
+
+func NewQUICClientHellos() *QUICClientHellos {
+	return &QUICClientHellos{
+		flows: newAuthFlows(),
+	}
+}
+
+type quicStream struct {
+	frames map[uint64][]byte
+	size   int
+}
+
+// Add feeds the CRYPTO frames of a packet and returns the ClientHello once
+// all of its fragments have been received.
+func (q *QUICClientHellos) Add(flow AuthFlow, pkt *QUICInitial) (*TLSClientHello, error) {
+	q.flows.Lock()
+	defer q.flows.Unlock()
+
+	// the connection id tells apart the connections sharing a tuple
+	flow.Server += "/" + fmt.Sprintf("%x", pkt.DCID)
+	s := q.flows.get(flow, func() interface{} {
+		return &quicStream{frames: make(map[uint64][]byte)}
+	}).(*quicStream)
+
+	for _, frame := range pkt.Crypto {
+		if _, found := s.frames[frame.Offset]; found || s.size+len(frame.Data) > quicMaxCrypto {
+			continue
+		}
+		s.frames[frame.Offset] = append([]byte(nil), frame.Data...)
+		s.size += len(frame.Data)
+	}
+
+	offsets := make([]uint64, 0, len(s.frames))
+	for offset := range s.frames {
+		offsets = append(offsets, offset)
+	}
+	sort.Slice(offsets, func(i, j int) bool { return offsets[i] < offsets[j] })
+
+	stream := make([]byte, 0, s.size)
+	for _, offset := range offsets {
+		if offset > uint64(len(stream)) {
+			break
+		} else if end := offset + uint64(len(s.frames[offset])); end > uint64(len(stream)) {
+			stream = append(stream, s.frames[offset][uint64(len(stream))-offset:]...)
+		}
+	}
+
+	if len(stream) < 4 && (len(stream) == 0 || stream[0] == TLSHandshakeClientHello) {
+		return nil, nil
+	}
+
+	hello, err := ParseTLSClientHello(stream)
+	if err == ErrTLSShort {
+		return nil, nil
+	}
+	q.flows.del(flow)
+	return hello, err
+}
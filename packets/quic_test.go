@@ -0,0 +1,180 @@
+package packets
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/binary"
+	"encoding/hex"
+	"testing"
+)
+
+func quicTestVarint(v int) []byte {
+	if v < 64 {
+		return []byte{byte(v)}
+	}
+	return binary.BigEndian.AppendUint16(nil, uint16(0x4000|v))
+}
+
+func quicTestCrypto(offset int, data []byte) []byte {
+	frame := []byte{quicFrameCrypto}
+	frame = append(frame, quicTestVarint(offset)...)
+	frame = append(frame, quicTestVarint(len(data))...)
+	return append(frame, data...)
+}
+
+// buildQUICInitial protects the frames in a client Initial packet padded
+// to the minimum datagram size.
+func buildQUICInitial(t *testing.T, version uint32, dcid []byte, pn uint16, frames []byte) []byte {
+	keys, err := QUICClientInitialKeys(version, dcid)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	packetType := byte(quicInitialTypeV1)
+	if version == QUICVersion2 {
+		packetType = quicInitialTypeV2
+	}
+
+	const pnLen = 2
+	header := []byte{quicLongHeader | quicFixedBit | packetType<<4 | (pnLen - 1)}
+	header = binary.BigEndian.AppendUint32(header, version)
+	header = append(header, byte(len(dcid)))
+	header = append(header, dcid...)
+	// empty source connection id and token
+	header = append(header, 0, 0)
+
+	overhead := len(header) + 2 + pnLen + 16
+	if padding := QUICMinInitialSize - overhead - len(frames); padding > 0 {
+		frames = append(frames, make([]byte, padding)...)
+	}
+	header = append(header, quicTestVarint(pnLen+len(frames)+16)...)
+	pnOffset := len(header)
+	header = binary.BigEndian.AppendUint16(header, pn)
+
+	block, _ := aes.NewCipher(keys.Key)
+	aead, _ := cipher.NewGCM(block)
+	nonce := append([]byte(nil), keys.IV...)
+	nonce[len(nonce)-1] ^= byte(pn)
+	nonce[len(nonce)-2] ^= byte(pn >> 8)
+	packet := aead.Seal(append([]byte(nil), header...), nonce, frames, header)
+
+	block, _ = aes.NewCipher(keys.HP)
+	mask := make([]byte, aes.BlockSize)
+	block.Encrypt(mask, packet[pnOffset+4:pnOffset+4+quicSampleSize])
+	packet[0] ^= mask[0] & 0x0f
+	for i := 0; i < pnLen; i++ {
+		packet[pnOffset+i] ^= mask[1+i]
+	}
+
+	return packet
+}
+
+func TestQUICClientInitialKeys(t *testing.T) {
+	dcid, _ := hex.DecodeString("8394c8f03e515708")
+	var units = []struct {
+		version uint32
+		key     string
+		iv      string
+		hp      string
+	}{
+		// RFC 9001 A.1
+		{QUICVersion1, "1f369613dd76d5467730efcbe3b1a22d", "fa044b2f42a3fd3b46fb255c", "9f50449e04a0e810283a1e9933adedd2"},
+		// RFC 9369 A.1
+		{QUICVersion2, "8b1a0bc121284290a29e0971b5cd045d", "91f73e2351d8fa91660e909f", "45b95e15235d6f45a6b19cbcb0294ba9"},
+	}
+	for _, u := range units {
+		keys, err := QUICClientInitialKeys(u.version, dcid)
+		if err != nil {
+			t.Fatal(err)
+		} else if hex.EncodeToString(keys.Key) != u.key {
+			t.Fatalf("expected key %s, got %x", u.key, keys.Key)
+		} else if hex.EncodeToString(keys.IV) != u.iv {
+			t.Fatalf("expected iv %s, got %x", u.iv, keys.IV)
+		} else if hex.EncodeToString(keys.HP) != u.hp {
+			t.Fatalf("expected hp %s, got %x", u.hp, keys.HP)
+		}
+	}
+
+	if _, err := QUICClientInitialKeys(0xff00001d, dcid); err != ErrQUICVersion {
+		t.Fatalf("expected %v, got %v", ErrQUICVersion, err)
+	}
+}
+
+func TestQUICHeaderProtectionMask(t *testing.T) {
+	// RFC 9001 A.2
+	dcid, _ := hex.DecodeString("8394c8f03e515708")
+	sample, _ := hex.DecodeString("d1b1c98dd7689fb8ec11d242b123dc9b")
+	keys, _ := QUICClientInitialKeys(QUICVersion1, dcid)
+
+	block, _ := aes.NewCipher(keys.HP)
+	mask := make([]byte, aes.BlockSize)
+	block.Encrypt(mask, sample)
+	if exp := "437b9aec36"; hex.EncodeToString(mask[:5]) != exp {
+		t.Fatalf("expected mask %s, got %x", exp, mask[:5])
+	}
+}
+
+func TestParseQUICInitial(t *testing.T) {
+	dcid, _ := hex.DecodeString("0011223344556677")
+	hello := chromeClientHello()
+
+	for _, version := range []uint32{QUICVersion1, QUICVersion2} {
+		// ack and ping frames can precede the handshake
+		frames := []byte{quicFramePing, quicFrameAck, 0x01, 0x00, 0x00, 0x00}
+		frames = append(frames, quicTestCrypto(0, hello)...)
+		data := buildQUICInitial(t, version, dcid, 1, frames)
+		if len(data) != QUICMinInitialSize {
+			t.Fatalf("expected %d bytes, got %d", QUICMinInitialSize, len(data))
+		}
+
+		pkt, err := ParseQUICInitial(data)
+		if err != nil {
+			t.Fatal(err)
+		} else if pkt.Version != version || !bytes.Equal(pkt.DCID, dcid) || pkt.PacketNumber != 1 {
+			t.Fatalf("unexpected packet %+v", pkt)
+		} else if len(pkt.Crypto) != 1 || !bytes.Equal(pkt.Crypto[0].Data, hello) {
+			t.Fatalf("unexpected crypto frames %+v", pkt.Crypto)
+		}
+
+		// corrupt the payload
+		data[len(data)-20] ^= 0xff
+		if _, err = ParseQUICInitial(data); err == nil {
+			t.Fatal("expected decryption error")
+		}
+	}
+
+	if _, err := ParseQUICInitial([]byte{0x40, 0x00}); err != ErrQUICNotInitial {
+		t.Fatalf("expected %v, got %v", ErrQUICNotInitial, err)
+	}
+}
+
+func TestQUICClientHellos(t *testing.T) {
+	dcid, _ := hex.DecodeString("0011223344556677")
+	hello := chromeClientHello()
+	split := len(hello) / 2
+
+	first, err := ParseQUICInitial(buildQUICInitial(t, QUICVersion1, dcid, 0, quicTestCrypto(split, hello[split:])))
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := ParseQUICInitial(buildQUICInitial(t, QUICVersion1, dcid, 1, quicTestCrypto(0, hello[:split])))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hellos := NewQUICClientHellos()
+	flow, _ := NewAuthFlow(testAuthClient, 40000, testAuthServer, 443, 443)
+	if got, err := hellos.Add(flow, first); got != nil || err != nil {
+		t.Fatalf("unexpected result %v %v", got, err)
+	}
+
+	got, err := hellos.Add(flow, second)
+	if err != nil {
+		t.Fatal(err)
+	} else if got == nil || got.ServerName != "www.example.com" {
+		t.Fatalf("unexpected client hello %+v", got)
+	} else if len(hellos.flows.states) != 0 {
+		t.Fatal("the stream should have been released")
+	}
+}
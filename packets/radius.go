@@ -0,0 +1,72 @@
+package packets
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+var RADIUSPorts = []int{1812, 1645}
+
+const (
+	radiusAccessRequest = 1
+	radiusHeaderSize    = 20
+
+	radiusUserName      = 1
+	radiusUserPassword  = 2
+	radiusCHAPPassword  = 3
+	radiusCHAPChallenge = 60
+)
+
+// RADIUSCredentials returns the username of an Access-Request along with its
+// authenticator and either the User-Password, hidden with the shared
+// secret, or the CHAP response.
+func RADIUSCredentials(flow AuthFlow, data []byte) *AuthCredentials {
+	if len(data) < radiusHeaderSize || data[0] != radiusAccessRequest {
+		return nil
+	}
+
+	size := int(binary.BigEndian.Uint16(data[2:]))
+	if size < radiusHeaderSize || size > len(data) {
+		return nil
+	}
+
+	id := data[1]
+	authenticator := data[4:radiusHeaderSize]
+	attrs := make(map[byte][]byte)
+	for off := radiusHeaderSize; off+2 <= size; {
+		attrType, attrLen := data[off], int(data[off+1])
+		if attrLen < 2 || off+attrLen > size {
+			return nil
+		}
+		attrs[attrType] = data[off+2 : off+attrLen]
+		off += attrLen
+	}
+
+	user, found := attrs[radiusUserName]
+	if !found {
+		return nil
+	}
+
+	creds := flow.credentials("radius", "")
+	creds.Username = string(user)
+	if password, found := attrs[radiusUserPassword]; found {
+		creds.Method = "PAP"
+		creds.Hash = fmt.Sprintf("%x:%x", authenticator, password)
+		creds.HashFormat = "radius-pap"
+	} else if chap, found := attrs[radiusCHAPPassword]; found && len(chap) == 17 {
+		// the challenge is the authenticator unless sent as attribute
+		challenge := authenticator
+		if c, found := attrs[radiusCHAPChallenge]; found {
+			challenge = c
+		}
+		creds.Method = "CHAP"
+		// MD5(id + secret + challenge)
+		creds.Hash = fmt.Sprintf("%x:%x:%02x", chap[1:], challenge, chap[0])
+		creds.HashcatMode = 4800
+	} else {
+		return nil
+	}
+	creds.Info = fmt.Sprintf("id %d", id)
+
+	return creds
+}
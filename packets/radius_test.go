@@ -0,0 +1,71 @@
+package packets
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+func buildRADIUS(id byte, authenticator []byte, attrs ...[]byte) []byte {
+	data := []byte{radiusAccessRequest, id, 0, 0}
+	data = append(data, authenticator...)
+	for _, attr := range attrs {
+		data = append(data, attr...)
+	}
+	binary.BigEndian.PutUint16(data[2:], uint16(len(data)))
+	return data
+}
+
+func radiusAttr(t byte, value []byte) []byte {
+	return append([]byte{t, byte(len(value) + 2)}, value...)
+}
+
+func TestRADIUSPAP(t *testing.T) {
+	authenticator := bytes.Repeat([]byte{0x11}, 16)
+	password := bytes.Repeat([]byte{0x22}, 16)
+	data := buildRADIUS(7, authenticator,
+		radiusAttr(radiusUserName, []byte("bob")),
+		radiusAttr(radiusUserPassword, password))
+
+	authExpect(t, true, 1812, []authExchange{
+		{true, data},
+	}, RADIUSCredentials, AuthCredentials{
+		Protocol:   "radius",
+		Method:     "PAP",
+		Username:   "bob",
+		Hash:       "11111111111111111111111111111111:22222222222222222222222222222222",
+		HashFormat: "radius-pap",
+		Info:       "id 7",
+	})
+}
+
+func TestRADIUSCHAP(t *testing.T) {
+	authenticator := bytes.Repeat([]byte{0x11}, 16)
+	challenge := bytes.Repeat([]byte{0x33}, 16)
+	chap := append([]byte{0x2a}, bytes.Repeat([]byte{0x44}, 16)...)
+	data := buildRADIUS(8, authenticator,
+		radiusAttr(radiusUserName, []byte("alice")),
+		radiusAttr(radiusCHAPPassword, chap),
+		radiusAttr(radiusCHAPChallenge, challenge))
+
+	authExpect(t, true, 1812, []authExchange{
+		{true, data},
+	}, RADIUSCredentials, AuthCredentials{
+		Protocol:    "radius",
+		Method:      "CHAP",
+		Username:    "alice",
+		Hash:        "44444444444444444444444444444444:33333333333333333333333333333333:2a",
+		HashcatMode: 4800,
+		Info:        "id 8",
+	})
+}
+
+func TestRADIUSMalformed(t *testing.T) {
+	data := buildRADIUS(9, make([]byte, 16), radiusAttr(radiusUserName, []byte("bob")))
+	// attribute length past the end of the packet
+	data[radiusHeaderSize+1] = 0xff
+	flow, _ := NewAuthFlow(testAuthClient, 40000, testAuthServer, 1812, RADIUSPorts...)
+	if creds := RADIUSCredentials(flow, data); creds != nil {
+		t.Fatalf("unexpected credentials %v", creds)
+	}
+}
@@ -0,0 +1,63 @@
+package packets
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const RedisPort = 6379
+
+// redisCommand parses a command either in the RESP array format or inline.
+func redisCommand(data []byte) []string {
+	r := bufio.NewReader(bytes.NewReader(data))
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil
+	}
+	line = strings.TrimRight(line, "\r\n")
+
+	if !strings.HasPrefix(line, "*") {
+		return strings.Fields(line)
+	}
+
+	num, err := strconv.Atoi(line[1:])
+	if err != nil || num <= 0 || num > 16 {
+		return nil
+	}
+
+	args := make([]string, 0, num)
+	for i := 0; i < num; i++ {
+		if line, err = r.ReadString('\n'); err != nil || !strings.HasPrefix(line, "$") {
+			return nil
+		}
+		size, err := strconv.Atoi(strings.TrimRight(line[1:], "\r\n"))
+		if err != nil || size < 0 || size > len(data) {
+			return nil
+		}
+		arg := make([]byte, size+2)
+		if _, err = io.ReadFull(r, arg); err != nil {
+			return nil
+		}
+		args = append(args, string(arg[:size]))
+	}
+	return args
+}
+
+// RedisCredentials returns the password, and the username for ACL users, of
+// an AUTH command.
+func RedisCredentials(flow AuthFlow, data []byte) *AuthCredentials {
+	args := redisCommand(data)
+	if len(args) < 2 || len(args) > 3 || strings.ToUpper(args[0]) != "AUTH" {
+		return nil
+	}
+
+	creds := flow.credentials("redis", "AUTH")
+	if len(args) == 3 {
+		creds.Username = args[1]
+	}
+	creds.Password = args[len(args)-1]
+	return creds
+}
@@ -0,0 +1,37 @@
+package packets
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRedisCommand(t *testing.T) {
+	var units = []struct {
+		data string
+		exp  []string
+	}{
+		{"*2\r\n$4\r\nAUTH\r\n$6\r\nsecret\r\n", []string{"AUTH", "secret"}},
+		{"AUTH user pass\r\n", []string{"AUTH", "user", "pass"}},
+		{"*2\r\n$4\r\nAUTH\r\n$60\r\nsecret\r\n", nil},
+		{"*1\r\n", nil},
+		{"PING", nil},
+	}
+	for _, u := range units {
+		if got := redisCommand([]byte(u.data)); !reflect.DeepEqual(got, u.exp) {
+			t.Fatalf("expected '%v' for %q, got '%v'", u.exp, u.data, got)
+		}
+	}
+}
+
+func TestRedisCredentials(t *testing.T) {
+	authExpect(t, false, RedisPort, []authExchange{
+		fromClient("*3\r\n$4\r\nauth\r\n$7\r\ndefault\r\n$6\r\nsecret\r\n"),
+		fromServer("+OK\r\n"),
+		fromClient("*1\r\n$4\r\nPING\r\n"),
+	}, RedisCredentials, AuthCredentials{
+		Protocol: "redis",
+		Method:   "AUTH",
+		Username: "default",
+		Password: "secret",
+	})
+}
@@ -0,0 +1,99 @@
+package packets
+
+import (
+	"net"
+	"regexp"
+	"strings"
+)
+
+var (
+	SIPPorts = []int{5060}
+
+	sipRequestRe = regexp.MustCompile(`^([A-Z]+) (\S+) SIP/2\.0$`)
+	sipParamRe   = regexp.MustCompile(`(\w+)\s*=\s*("[^"]*"|[^,\s]+)`)
+)
+
+// sipDigest returns the parameters of the Digest authorization header of a
+// request and its method.
+func sipDigest(data []byte) (method string, params map[string]string) {
+	lines := authLines(data)
+	if len(lines) == 0 {
+		return "", nil
+	}
+
+	m := sipRequestRe.FindStringSubmatch(lines[0])
+	if m == nil {
+		return "", nil
+	}
+
+	for _, line := range lines[1:] {
+		colon := strings.Index(line, ":")
+		if colon == -1 {
+			continue
+		}
+
+		name := strings.ToLower(strings.TrimSpace(line[:colon]))
+		value := strings.TrimSpace(line[colon+1:])
+		if (name != "authorization" && name != "proxy-authorization") || !strings.HasPrefix(strings.ToLower(value), "digest ") {
+			continue
+		}
+
+		params = make(map[string]string)
+		for _, p := range sipParamRe.FindAllStringSubmatch(value[7:], -1) {
+			params[strings.ToLower(p[1])] = strings.Trim(p[2], `"`)
+		}
+		return m[1], params
+	}
+
+	return "", nil
+}
+
+// SIPCredentials returns the digest authentication of a request in the
+// format used by hashcat mode 11400.
+func SIPCredentials(flow AuthFlow, data []byte) *AuthCredentials {
+	method, params := sipDigest(data)
+	if params == nil || params["username"] == "" || params["response"] == "" {
+		return nil
+	}
+
+	algo := params["algorithm"]
+	if algo == "" {
+		algo = "MD5"
+	} else if strings.ToUpper(algo) != "MD5" {
+		return nil
+	}
+
+	// sip:user@host:port;params
+	prefix, resource, suffix := "", params["uri"], ""
+	if parts := strings.SplitN(resource, ":", 2); len(parts) == 2 {
+		prefix, resource = parts[0], parts[1]
+	}
+	if parts := strings.SplitN(resource, ":", 2); len(parts) == 2 {
+		resource, suffix = parts[0], parts[1]
+	}
+
+	server, _, _ := net.SplitHostPort(flow.Server)
+	client, _, _ := net.SplitHostPort(flow.Client)
+
+	creds := flow.credentials("sip", "digest")
+	creds.Username = params["username"]
+	creds.Hash = "$sip$*" + strings.Join([]string{
+		server,
+		client,
+		params["username"],
+		params["realm"],
+		method,
+		prefix,
+		resource,
+		suffix,
+		params["nonce"],
+		params["cnonce"],
+		params["nc"],
+		params["qop"],
+		strings.ToUpper(algo),
+		params["response"],
+	}, "*")
+	creds.HashcatMode = 11400
+	creds.Info = params["realm"]
+	return creds
+}
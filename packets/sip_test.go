@@ -0,0 +1,39 @@
+package packets
+
+import (
+	"testing"
+)
+
+func TestSIPCredentials(t *testing.T) {
+	register := "REGISTER sip:10.0.0.1 SIP/2.0\r\n" +
+		"Via: SIP/2.0/UDP 10.0.0.2:40000;branch=z9hG4bK776asdhds\r\n" +
+		"From: <sip:1001@10.0.0.1>;tag=1928301774\r\n" +
+		"To: <sip:1001@10.0.0.1>\r\n" +
+		"Call-ID: a84b4c76e66710\r\n" +
+		"CSeq: 2 REGISTER\r\n" +
+		"Authorization: Digest username=\"1001\", realm=\"asterisk\", nonce=\"4a2c5b0e\", " +
+		"uri=\"sip:10.0.0.1:5060\", response=\"6c7d2b5e9d3cdb7b5bd0c41a7a3e7a02\", algorithm=MD5, " +
+		"cnonce=\"0a4f113b\", nc=00000001, qop=auth\r\n" +
+		"Content-Length: 0\r\n\r\n"
+
+	authExpect(t, true, 5060, []authExchange{
+		fromClient(register),
+	}, SIPCredentials, AuthCredentials{
+		Protocol:    "sip",
+		Method:      "digest",
+		Username:    "1001",
+		Hash:        "$sip$*10.0.0.1*10.0.0.2*1001*asterisk*REGISTER*sip*10.0.0.1*5060*4a2c5b0e*0a4f113b*00000001*auth*MD5*6c7d2b5e9d3cdb7b5bd0c41a7a3e7a02",
+		HashcatMode: 11400,
+		Info:        "asterisk",
+	})
+}
+
+func TestSIPWithoutDigest(t *testing.T) {
+	found := authReplay(t, true, 5060, []authExchange{
+		fromClient("OPTIONS sip:10.0.0.1 SIP/2.0\r\nAuthorization: Basic Zm9vOmJhcg==\r\n\r\n"),
+		fromServer("SIP/2.0 401 Unauthorized\r\nWWW-Authenticate: Digest realm=\"asterisk\", nonce=\"4a2c5b0e\"\r\n\r\n"),
+	}, SIPCredentials)
+	if len(found) != 0 {
+		t.Fatalf("unexpected credentials %v", found)
+	}
+}
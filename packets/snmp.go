@@ -0,0 +1,76 @@
+package packets
+
+import (
+	"encoding/asn1"
+	"errors"
+	"fmt"
+)
+
+var (
+	SNMPPorts = []int{161, 162}
+
+	ErrSNMPVersion = errors.New("unsupported SNMP version")
+
+	snmpVersions = map[int]string{
+		0: "v1",
+		1: "v2c",
+	}
+	snmpPDUs = map[int]string{
+		0: "get-request",
+		1: "get-next-request",
+		2: "get-response",
+		3: "set-request",
+		4: "trap",
+		5: "get-bulk-request",
+		6: "inform-request",
+		7: "trap-v2",
+		8: "report",
+	}
+)
+
+// SNMPMessage is a community based SNMP message.
+type SNMPMessage struct {
+	Version   int
+	Community []byte
+	PDU       asn1.RawValue
+}
+
+func (m SNMPMessage) VersionName() string {
+	return snmpVersions[m.Version]
+}
+
+func (m SNMPMessage) PDUName() string {
+	if name, found := snmpPDUs[m.PDU.Tag]; found {
+		return name
+	}
+	return fmt.Sprintf("pdu-%d", m.PDU.Tag)
+}
+
+// ParseSNMP parses SNMP v1 and v2c messages, v3 ones don't carry a
+// community string.
+func ParseSNMP(data []byte) (*SNMPMessage, error) {
+	msg := SNMPMessage{}
+	if rest, err := asn1.Unmarshal(data, &msg); err != nil {
+		return nil, err
+	} else if len(rest) > 0 {
+		return nil, asn1.SyntaxError{Msg: "trailing data"}
+	} else if _, found := snmpVersions[msg.Version]; !found {
+		return nil, ErrSNMPVersion
+	} else if msg.PDU.Class != asn1.ClassContextSpecific {
+		return nil, asn1.StructuralError{Msg: "unexpected PDU class"}
+	}
+	return &msg, nil
+}
+
+// SNMPCredentials returns the community string of the message.
+func SNMPCredentials(flow AuthFlow, data []byte) *AuthCredentials {
+	msg, err := ParseSNMP(data)
+	if err != nil {
+		return nil
+	}
+
+	creds := flow.credentials("snmp", "community")
+	creds.Password = string(msg.Community)
+	creds.Info = msg.VersionName() + " " + msg.PDUName()
+	return creds
+}
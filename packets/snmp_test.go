@@ -0,0 +1,62 @@
+package packets
+
+import (
+	"encoding/asn1"
+	"testing"
+)
+
+func buildSNMP(t *testing.T, version int, community string, pdu int) []byte {
+	// request id, error status, error index and an empty varbind list
+	body, err := asn1.Marshal(struct {
+		ID       int
+		Status   int
+		Index    int
+		Bindings []asn1.RawValue
+	}{1234, 0, 0, []asn1.RawValue{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := asn1.Marshal(SNMPMessage{
+		Version:   version,
+		Community: []byte(community),
+		PDU: asn1.RawValue{
+			Class:      asn1.ClassContextSpecific,
+			Tag:        pdu,
+			IsCompound: true,
+			// strip the sequence header
+			Bytes: body[2:],
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestParseSNMP(t *testing.T) {
+	msg, err := ParseSNMP(buildSNMP(t, 1, "private", 3))
+	if err != nil {
+		t.Fatal(err)
+	} else if string(msg.Community) != "private" || msg.VersionName() != "v2c" || msg.PDUName() != "set-request" {
+		t.Fatalf("unexpected message %+v", msg)
+	}
+
+	if _, err = ParseSNMP(buildSNMP(t, 3, "private", 0)); err != ErrSNMPVersion {
+		t.Fatalf("expected %v, got %v", ErrSNMPVersion, err)
+	}
+	if _, err = ParseSNMP([]byte("not snmp")); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestSNMPCredentials(t *testing.T) {
+	authExpect(t, true, 161, []authExchange{
+		{true, buildSNMP(t, 0, "public", 0)},
+	}, SNMPCredentials, AuthCredentials{
+		Protocol: "snmp",
+		Method:   "community",
+		Password: "public",
+		Info:     "v1 get-request",
+	})
+}
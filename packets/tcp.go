@@ -5,7 +5,7 @@ import (
 	"net"
 )
 
-func NewTCPSyn(from net.IP, from_hw net.HardwareAddr, to net.IP, to_hw net.HardwareAddr, srcPort int, dstPort int) (error, []byte) {
+func newTCPProbe(from net.IP, from_hw net.HardwareAddr, to net.IP, to_hw net.HardwareAddr, tcp layers.TCP) (error, []byte) {
 	from4 := from.To4()
 	to4 := to.To4()
 
@@ -22,11 +22,6 @@ func NewTCPSyn(from net.IP, from_hw net.HardwareAddr, to net.IP, to_hw net.Hardw
 			SrcIP:    from,
 			DstIP:    to,
 		}
-		tcp := layers.TCP{
-			SrcPort: layers.TCPPort(srcPort),
-			DstPort: layers.TCPPort(dstPort),
-			SYN:     true,
-		}
 		tcp.SetNetworkLayerForChecksum(&ip4)
 
 		return Serialize(&eth, &ip4, &tcp)
@@ -43,13 +38,27 @@ func NewTCPSyn(from net.IP, from_hw net.HardwareAddr, to net.IP, to_hw net.Hardw
 			SrcIP:      from,
 			DstIP:      to,
 		}
-		tcp := layers.TCP{
-			SrcPort: layers.TCPPort(srcPort),
-			DstPort: layers.TCPPort(dstPort),
-			SYN:     true,
-		}
 		tcp.SetNetworkLayerForChecksum(&ip6)
 
 		return Serialize(&eth, &ip6, &tcp)
 	}
 }
+
+func NewTCPSyn(from net.IP, from_hw net.HardwareAddr, to net.IP, to_hw net.HardwareAddr, srcPort int, dstPort int) (error, []byte) {
+	return newTCPProbe(from, from_hw, to, to_hw, layers.TCP{
+		SrcPort: layers.TCPPort(srcPort),
+		DstPort: layers.TCPPort(dstPort),
+		SYN:     true,
+	})
+}
+
+// NewTCPAck builds an ACK out of any connection, which hosts answer with a
+// RST whether the port is open or not.
+func NewTCPAck(from net.IP, from_hw net.HardwareAddr, to net.IP, to_hw net.HardwareAddr, srcPort int, dstPort int) (error, []byte) {
+	return newTCPProbe(from, from_hw, to, to_hw, layers.TCP{
+		SrcPort: layers.TCPPort(srcPort),
+		DstPort: layers.TCPPort(dstPort),
+		ACK:     true,
+		Ack:     1,
+	})
+}
@@ -0,0 +1,137 @@
+package packets
+
+import (
+	"regexp"
+)
+
+const (
+	TelnetPort = 23
+
+	telnetIAC     = 0xff
+	telnetSB      = 0xfa
+	telnetSE      = 0xf0
+	telnetMaxLine = 256
+)
+
+var (
+	telnetUserPrompt = regexp.MustCompile(`(?i)(login|username|user name)\s*:\s*$`)
+	telnetPassPrompt = regexp.MustCompile(`(?i)password\s*:\s*$`)
+)
+
+type telnetFlow struct {
+	prompt string
+	line   []byte
+	user   string
+	// the last prompt asked for the username or for the password
+	wantUser bool
+	wantPass bool
+}
+
+// TelnetAuthState tracks Telnet sessions to extract the credentials typed
+// after the login and password prompts.
+type TelnetAuthState struct {
+	flows authFlows
+}
+
+func NewTelnetAuthState() *TelnetAuthState {
+	return &TelnetAuthState{
+		flows: newAuthFlows(),
+	}
+}
+
+// telnetData removes the option negotiation commands from the payload.
+func telnetData(payload []byte) []byte {
+	data := make([]byte, 0, len(payload))
+	for i := 0; i < len(payload); i++ {
+		if payload[i] != telnetIAC {
+			data = append(data, payload[i])
+			continue
+		} else if i+1 >= len(payload) {
+			break
+		}
+
+		switch cmd := payload[i+1]; {
+		case cmd == telnetIAC:
+			// escaped 0xff
+			data = append(data, telnetIAC)
+			i++
+		case cmd == telnetSB:
+			// skip the sub negotiation until IAC SE
+			for i += 2; i+1 < len(payload) && !(payload[i] == telnetIAC && payload[i+1] == telnetSE); i++ {
+			}
+			i++
+		case cmd >= 0xfb:
+			// WILL, WONT, DO, DONT + option
+			i += 2
+		default:
+			i++
+		}
+	}
+	return data
+}
+
+// Parse feeds the state with a payload of the session and returns the
+// credentials once both the username and the password have been typed.
+func (s *TelnetAuthState) Parse(flow AuthFlow, payload []byte) *AuthCredentials {
+	s.flows.Lock()
+	defer s.flows.Unlock()
+
+	data := telnetData(payload)
+	if len(data) == 0 {
+		return nil
+	}
+
+	f := s.flows.get(flow, func() interface{} {
+		return &telnetFlow{}
+	}).(*telnetFlow)
+
+	if !flow.FromClient {
+		f.prompt += string(data)
+		if len(f.prompt) > telnetMaxLine {
+			f.prompt = f.prompt[len(f.prompt)-telnetMaxLine:]
+		}
+		// echoed characters are appended after the prompt, so only the
+		// last one matters
+		if telnetPassPrompt.MatchString(f.prompt) {
+			f.wantUser, f.wantPass = false, true
+			f.prompt = ""
+		} else if telnetUserPrompt.MatchString(f.prompt) {
+			f.wantUser, f.wantPass = true, false
+			f.prompt = ""
+		}
+		return nil
+	}
+
+	for _, c := range data {
+		switch c {
+		case 0x08, 0x7f:
+			if len(f.line) > 0 {
+				f.line = f.line[:len(f.line)-1]
+			}
+		case '\r', '\n', 0x00:
+			if len(f.line) == 0 {
+				continue
+			}
+			line := string(f.line)
+			f.line = f.line[:0]
+
+			if f.wantPass {
+				// some devices only ask for a password
+				s.flows.del(flow)
+				creds := flow.credentials("telnet", "login")
+				creds.Username = f.user
+				creds.Password = line
+				return creds
+			} else if f.wantUser {
+				f.user = line
+				f.wantUser = false
+			}
+		default:
+			if len(f.line) < telnetMaxLine {
+				f.line = append(f.line, c)
+			}
+		}
+	}
+
+	return nil
+}
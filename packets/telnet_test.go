@@ -0,0 +1,54 @@
+package packets
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestTelnetData(t *testing.T) {
+	payload := []byte("\xff\xfd\x18\xff\xfa\x18\x01\xff\xf0log\xff\xffin: ")
+	if got := telnetData(payload); !bytes.Equal(got, []byte("log\xffin: ")) {
+		t.Fatalf("unexpected data %q", got)
+	}
+}
+
+func TestTelnetLogin(t *testing.T) {
+	exchange := []authExchange{
+		fromServer("\xff\xfd\x18\xff\xfd\x20\xff\xfb\x01"),
+		fromClient("\xff\xfc\x18\xff\xfc\x20\xff\xfd\x01"),
+		fromServer("Ubuntu 22.04\r\nrouter login: "),
+	}
+	// typed one character at a time and echoed back by the server
+	for _, c := range "admn\x7fin" {
+		exchange = append(exchange, fromClient(string(c)))
+		if c != 0x7f {
+			exchange = append(exchange, fromServer(string(c)))
+		}
+	}
+	exchange = append(exchange,
+		fromClient("\r\n"),
+		fromServer("\r\nPassword: "),
+		fromClient("s3cret\r\n"),
+		fromServer("\r\nWelcome\r\n$ "),
+		fromClient("ls\r\n"),
+	)
+
+	authExpect(t, false, TelnetPort, exchange, NewTelnetAuthState().Parse, AuthCredentials{
+		Protocol: "telnet",
+		Method:   "login",
+		Username: "admin",
+		Password: "s3cret",
+	})
+}
+
+func TestTelnetPasswordOnly(t *testing.T) {
+	authExpect(t, false, TelnetPort, []authExchange{
+		fromServer("\r\nUser Access Verification\r\n\r\nPassword: "),
+		fromClient("cisco\r"),
+		fromServer("\r\nrouter>"),
+	}, NewTelnetAuthState().Parse, AuthCredentials{
+		Protocol: "telnet",
+		Method:   "login",
+		Password: "cisco",
+	})
+}
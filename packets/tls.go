@@ -0,0 +1,540 @@
+package packets
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const (
+	TLSHandshakeRecord      = 0x16
+	TLSHandshakeClientHello = 0x01
+	TLSHandshakeServerHello = 0x02
+
+	// hello messages are way smaller than this
+	TLSMaxHelloSize = 65535
+
+	TLSExtServerName          = 0x0000
+	TLSExtSupportedGroups     = 0x000a
+	TLSExtPointFormats        = 0x000b
+	TLSExtSignatureAlgorithms = 0x000d
+	TLSExtALPN                = 0x0010
+	TLSExtSupportedVersions   = 0x002b
+)
+
+var (
+	ErrTLSShort       = errors.New("truncated TLS message")
+	ErrTLSClientHello = errors.New("not a TLS client hello")
+	ErrTLSServerHello = errors.New("not a TLS server hello")
+	ErrTLSRecord      = errors.New("not a TLS handshake record")
+
+	tlsVersionNames = map[uint16]string{
+		0x0304: "13",
+		0x0303: "12",
+		0x0302: "11",
+		0x0301: "10",
+		0x0300: "s3",
+		0x0002: "s2",
+		0xfeff: "d1",
+		0xfefd: "d2",
+		0xfefc: "d3",
+	}
+)
+
+// TLSClientHello holds the fields of a ClientHello message needed to
+// identify the server and fingerprint the client.
+type TLSClientHello struct {
+	Version             uint16
+	CipherSuites        []uint16
+	Extensions          []uint16
+	ServerName          string
+	ALPN                []string
+	SupportedVersions   []uint16
+	SignatureAlgorithms []uint16
+	SupportedGroups     []uint16
+	PointFormats        []uint8
+}
+
+// TLSServerHello holds the fields of a ServerHello message needed to
+// fingerprint the server.
+type TLSServerHello struct {
+	Version          uint16
+	CipherSuite      uint16
+	Extensions       []uint16
+	SupportedVersion uint16
+	ALPN             string
+}
+
+// TLSIsGREASE returns true if the value is one of the reserved GREASE
+// values of RFC 8701.
+func TLSIsGREASE(v uint16) bool {
+	return v&0x0f0f == 0x0a0a && v>>8 == v&0xff
+}
+
+type tlsReader struct {
+	data []byte
+}
+
+func (r *tlsReader) bytes(n int) ([]byte, error) {
+	if n < 0 || n > len(r.data) {
+		return nil, ErrTLSShort
+	}
+	b := r.data[:n]
+	r.data = r.data[n:]
+	return b, nil
+}
+
+func (r *tlsReader) uint8() (uint8, error) {
+	b, err := r.bytes(1)
+	if err != nil {
+		return 0, err
+	}
+	return b[0], nil
+}
+
+func (r *tlsReader) uint16() (uint16, error) {
+	b, err := r.bytes(2)
+	if err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint16(b), nil
+}
+
+func (r *tlsReader) vector8() (*tlsReader, error) {
+	n, err := r.uint8()
+	if err != nil {
+		return nil, err
+	}
+	b, err := r.bytes(int(n))
+	return &tlsReader{b}, err
+}
+
+func (r *tlsReader) vector16() (*tlsReader, error) {
+	n, err := r.uint16()
+	if err != nil {
+		return nil, err
+	}
+	b, err := r.bytes(int(n))
+	return &tlsReader{b}, err
+}
+
+func (r *tlsReader) uint16s() (values []uint16, err error) {
+	for len(r.data) > 0 {
+		v, err := r.uint16()
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	return
+}
+
+// TLSHandshakeMessage returns the first handshake message carried by a
+// stream of TLS records, which can be fragmented over several records.
+func TLSHandshakeMessage(stream []byte) ([]byte, error) {
+	msg := make([]byte, 0)
+	for {
+		if len(msg) >= 4 {
+			size := 4 + (int(msg[1])<<16 | int(msg[2])<<8 | int(msg[3]))
+			if size > TLSMaxHelloSize {
+				return nil, ErrTLSRecord
+			} else if len(msg) >= size {
+				return msg[:size], nil
+			}
+		}
+
+		if len(stream) < 5 {
+			return nil, ErrTLSShort
+		} else if stream[0] != TLSHandshakeRecord || stream[1] != 0x03 {
+			return nil, ErrTLSRecord
+		}
+
+		size := int(binary.BigEndian.Uint16(stream[3:]))
+		if len(stream) < 5+size {
+			return nil, ErrTLSShort
+		}
+		msg = append(msg, stream[5:5+size]...)
+		stream = stream[5+size:]
+	}
+}
+
+// ParseTLSClientHello parses a ClientHello handshake message, including its
+// four bytes header.
+func ParseTLSClientHello(data []byte) (*TLSClientHello, error) {
+	if len(data) < 4 || data[0] != TLSHandshakeClientHello {
+		return nil, ErrTLSClientHello
+	}
+
+	size := int(data[1])<<16 | int(data[2])<<8 | int(data[3])
+	if len(data) < 4+size {
+		return nil, ErrTLSShort
+	}
+
+	hello := &TLSClientHello{}
+	r := &tlsReader{data[4 : 4+size]}
+
+	var err error
+	var ciphers *tlsReader
+	if hello.Version, err = r.uint16(); err != nil {
+		return nil, err
+	} else if _, err = r.bytes(32); err != nil {
+		// random
+		return nil, err
+	} else if _, err = r.vector8(); err != nil {
+		// session id
+		return nil, err
+	} else if ciphers, err = r.vector16(); err != nil {
+		return nil, err
+	} else if hello.CipherSuites, err = ciphers.uint16s(); err != nil {
+		return nil, err
+	} else if _, err = r.vector8(); err != nil {
+		// compression methods
+		return nil, err
+	}
+
+	// extensions are optional
+	if len(r.data) == 0 {
+		return hello, nil
+	}
+
+	exts, err := r.vector16()
+	if err != nil {
+		return nil, err
+	}
+
+	for len(exts.data) > 0 {
+		extType, err := exts.uint16()
+		if err != nil {
+			return nil, err
+		}
+		ext, err := exts.vector16()
+		if err != nil {
+			return nil, err
+		}
+
+		hello.Extensions = append(hello.Extensions, extType)
+		if err = hello.parseExtension(extType, ext); err != nil {
+			return nil, fmt.Errorf("extension %04x: %v", extType, err)
+		}
+	}
+
+	return hello, nil
+}
+
+func (h *TLSClientHello) parseExtension(extType uint16, ext *tlsReader) error {
+	switch extType {
+	case TLSExtServerName:
+		list, err := ext.vector16()
+		if err != nil {
+			return err
+		}
+		for len(list.data) > 0 {
+			nameType, err := list.uint8()
+			if err != nil {
+				return err
+			}
+			name, err := list.vector16()
+			if err != nil {
+				return err
+			}
+			// host_name
+			if nameType == 0 && h.ServerName == "" {
+				h.ServerName = string(name.data)
+			}
+		}
+	case TLSExtALPN:
+		list, err := ext.vector16()
+		if err != nil {
+			return err
+		}
+		for len(list.data) > 0 {
+			proto, err := list.vector8()
+			if err != nil {
+				return err
+			}
+			h.ALPN = append(h.ALPN, string(proto.data))
+		}
+	case TLSExtSupportedVersions:
+		list, err := ext.vector8()
+		if err != nil {
+			return err
+		}
+		h.SupportedVersions, err = list.uint16s()
+		return err
+	case TLSExtSignatureAlgorithms:
+		list, err := ext.vector16()
+		if err != nil {
+			return err
+		}
+		h.SignatureAlgorithms, err = list.uint16s()
+		return err
+	case TLSExtSupportedGroups:
+		list, err := ext.vector16()
+		if err != nil {
+			return err
+		}
+		h.SupportedGroups, err = list.uint16s()
+		return err
+	case TLSExtPointFormats:
+		list, err := ext.vector8()
+		if err != nil {
+			return err
+		}
+		h.PointFormats = list.data
+	}
+	return nil
+}
+
+// MaxVersion returns the highest version supported by the client, GREASE
+// values excluded.
+func (h *TLSClientHello) MaxVersion() uint16 {
+	max := uint16(0)
+	for _, v := range h.SupportedVersions {
+		if !TLSIsGREASE(v) && v > max {
+			max = v
+		}
+	}
+	if max == 0 {
+		return h.Version
+	}
+	return max
+}
+
+func tlsHexList(values []uint16) []string {
+	list := make([]string, 0, len(values))
+	for _, v := range values {
+		if !TLSIsGREASE(v) {
+			list = append(list, fmt.Sprintf("%04x", v))
+		}
+	}
+	return list
+}
+
+func ja4Hash(s string) string {
+	if s == "" {
+		return "000000000000"
+	}
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:6])
+}
+
+// JA4 returns the JA4 fingerprint of the ClientHello, transport is 't' for
+// TLS over TCP, 'q' for QUIC and 'd' for DTLS.
+func (h *TLSClientHello) JA4(transport byte) string {
+	version, found := tlsVersionNames[h.MaxVersion()]
+	if !found {
+		version = "00"
+	}
+
+	sni := "i"
+	if h.ServerName != "" {
+		sni = "d"
+	}
+
+	alpn := "00"
+	if len(h.ALPN) > 0 && h.ALPN[0] != "" {
+		first := h.ALPN[0]
+		a, b := first[0], first[len(first)-1]
+		if isAlnum(a) && isAlnum(b) {
+			alpn = string([]byte{a, b})
+		} else {
+			x := hex.EncodeToString([]byte(first))
+			alpn = x[:1] + x[len(x)-1:]
+		}
+	}
+
+	ciphers := tlsHexList(h.CipherSuites)
+	sort.Strings(ciphers)
+
+	// SNI and ALPN are counted but not hashed
+	all := tlsHexList(h.Extensions)
+	exts := make([]string, 0, len(all))
+	for _, e := range all {
+		if e != "0000" && e != "0010" {
+			exts = append(exts, e)
+		}
+	}
+	sort.Strings(exts)
+
+	extsHash := strings.Join(exts, ",")
+	if algs := tlsHexList(h.SignatureAlgorithms); len(algs) > 0 && extsHash != "" {
+		extsHash += "_" + strings.Join(algs, ",")
+	}
+
+	return fmt.Sprintf("%c%s%s%02d%02d%s_%s_%s",
+		transport,
+		version,
+		sni,
+		min(len(ciphers), 99),
+		min(len(all), 99),
+		alpn,
+		ja4Hash(strings.Join(ciphers, ",")),
+		ja4Hash(extsHash))
+}
+
+func tlsDecList(values []uint16) string {
+	list := make([]string, 0, len(values))
+	for _, v := range values {
+		if !TLSIsGREASE(v) {
+			list = append(list, fmt.Sprintf("%d", v))
+		}
+	}
+	return strings.Join(list, "-")
+}
+
+// JA3String returns the fields of the ClientHello hashed by JA3.
+func (h *TLSClientHello) JA3String() string {
+	formats := make([]string, 0, len(h.PointFormats))
+	for _, f := range h.PointFormats {
+		formats = append(formats, fmt.Sprintf("%d", f))
+	}
+
+	return fmt.Sprintf("%d,%s,%s,%s,%s",
+		h.Version,
+		tlsDecList(h.CipherSuites),
+		tlsDecList(h.Extensions),
+		tlsDecList(h.SupportedGroups),
+		strings.Join(formats, "-"))
+}
+
+// JA3 returns the JA3 fingerprint of the ClientHello.
+func (h *TLSClientHello) JA3() string {
+	sum := md5.Sum([]byte(h.JA3String()))
+	return hex.EncodeToString(sum[:])
+}
+
+// ParseTLSServerHello parses a ServerHello handshake message, including its
+// four bytes header.
+func ParseTLSServerHello(data []byte) (*TLSServerHello, error) {
+	if len(data) < 4 || data[0] != TLSHandshakeServerHello {
+		return nil, ErrTLSServerHello
+	}
+
+	size := int(data[1])<<16 | int(data[2])<<8 | int(data[3])
+	if len(data) < 4+size {
+		return nil, ErrTLSShort
+	}
+
+	hello := &TLSServerHello{}
+	r := &tlsReader{data[4 : 4+size]}
+
+	var err error
+	if hello.Version, err = r.uint16(); err != nil {
+		return nil, err
+	} else if _, err = r.bytes(32); err != nil {
+		// random
+		return nil, err
+	} else if _, err = r.vector8(); err != nil {
+		// session id
+		return nil, err
+	} else if hello.CipherSuite, err = r.uint16(); err != nil {
+		return nil, err
+	} else if _, err = r.uint8(); err != nil {
+		// compression method
+		return nil, err
+	}
+
+	if len(r.data) == 0 {
+		return hello, nil
+	}
+
+	exts, err := r.vector16()
+	if err != nil {
+		return nil, err
+	}
+
+	for len(exts.data) > 0 {
+		extType, err := exts.uint16()
+		if err != nil {
+			return nil, err
+		}
+		ext, err := exts.vector16()
+		if err != nil {
+			return nil, err
+		}
+
+		hello.Extensions = append(hello.Extensions, extType)
+		switch extType {
+		case TLSExtSupportedVersions:
+			if hello.SupportedVersion, err = ext.uint16(); err != nil {
+				return nil, err
+			}
+		case TLSExtALPN:
+			list, err := ext.vector16()
+			if err != nil {
+				return nil, err
+			}
+			proto, err := list.vector8()
+			if err != nil {
+				return nil, err
+			}
+			hello.ALPN = string(proto.data)
+		}
+	}
+
+	return hello, nil
+}
+
+// JA3SString returns the fields of the ServerHello hashed by JA3S.
+func (h *TLSServerHello) JA3SString() string {
+	return fmt.Sprintf("%d,%d,%s", h.Version, h.CipherSuite, tlsDecList(h.Extensions))
+}
+
+// JA3S returns the JA3S fingerprint of the ServerHello.
+func (h *TLSServerHello) JA3S() string {
+	sum := md5.Sum([]byte(h.JA3SString()))
+	return hex.EncodeToString(sum[:])
+}
+
+// TLSHellos reassembles the hello messages of TLS connections when they
+// span several TCP segments.
+type TLSHellos struct {
+	flows authFlows
+}
+
+func NewTLSHellos() *TLSHellos {
+	return &TLSHellos{
+		flows: newAuthFlows(),
+	}
+}
+
+// Add feeds a segment sent from src to dst and returns the hello message
+// once complete, or nil if the segment doesn't belong to a hello.
+func (t *TLSHellos) Add(src, dst string, payload []byte) []byte {
+	t.flows.Lock()
+	defer t.flows.Unlock()
+
+	flow := AuthFlow{Client: src, Server: dst, FromClient: true}
+	var stream []byte
+	if state := t.flows.get(flow, nil); state != nil {
+		stream = append(state.([]byte), payload...)
+	} else if len(payload) >= 6 && payload[0] == TLSHandshakeRecord && payload[1] == 0x03 &&
+		(payload[5] == TLSHandshakeClientHello || payload[5] == TLSHandshakeServerHello) {
+		stream = payload
+	} else {
+		return nil
+	}
+
+	t.flows.del(flow)
+	msg, err := TLSHandshakeMessage(stream)
+	if err == ErrTLSShort && len(stream) < TLSMaxHelloSize {
+		// wait for the next segments
+		buffered := append([]byte(nil), stream...)
+		t.flows.get(flow, func() interface{} {
+			return buffered
+		})
+		return nil
+	} else if err != nil {
+		return nil
+	}
+	return msg
+}
+
+func isAlnum(c byte) bool {
+	return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
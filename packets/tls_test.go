@@ -0,0 +1,241 @@
+package packets
+
+import (
+	"crypto/md5"
+	"encoding/binary"
+	"encoding/hex"
+	"reflect"
+	"testing"
+)
+
+type testExtension struct {
+	id   uint16
+	data []byte
+}
+
+func tlsVector16(data []byte) []byte {
+	return append(binary.BigEndian.AppendUint16(nil, uint16(len(data))), data...)
+}
+
+func tlsUint16s(values ...uint16) []byte {
+	data := make([]byte, 0, 2*len(values))
+	for _, v := range values {
+		data = binary.BigEndian.AppendUint16(data, v)
+	}
+	return data
+}
+
+// buildClientHello returns a ClientHello handshake message.
+func buildClientHello(ciphers []uint16, exts []testExtension) []byte {
+	body := []byte{0x03, 0x03}
+	body = append(body, make([]byte, 32)...)
+	// session id
+	body = append(body, 32)
+	body = append(body, make([]byte, 32)...)
+	body = append(body, tlsVector16(tlsUint16s(ciphers...))...)
+	// null compression
+	body = append(body, 1, 0)
+
+	extensions := make([]byte, 0)
+	for _, ext := range exts {
+		extensions = binary.BigEndian.AppendUint16(extensions, ext.id)
+		extensions = append(extensions, tlsVector16(ext.data)...)
+	}
+	body = append(body, tlsVector16(extensions)...)
+
+	return append([]byte{TLSHandshakeClientHello, byte(len(body) >> 16), byte(len(body) >> 8), byte(len(body))}, body...)
+}
+
+// chromeClientHello returns the ClientHello of the JA4 specification
+// example, whose fingerprint is t13d1516h2_8daaf6152771_e5627efa2ab1.
+func chromeClientHello() []byte {
+	ciphers := []uint16{
+		0x0a0a, 0x1301, 0x1302, 0x1303, 0xc02b, 0xc02f, 0xc02c, 0xc030,
+		0xcca9, 0xcca8, 0xc013, 0xc014, 0x009c, 0x009d, 0x002f, 0x0035,
+	}
+
+	host := []byte("www.example.com")
+	sni := tlsVector16(append([]byte{0}, tlsVector16(host)...))
+	alpn := tlsVector16([]byte("\x02h2\x08http/1.1"))
+	sigAlgs := tlsVector16(tlsUint16s(0x0403, 0x0804, 0x0401, 0x0503, 0x0805, 0x0501, 0x0806, 0x0601))
+	versions := append([]byte{6}, tlsUint16s(0x2a2a, 0x0304, 0x0303)...)
+	groups := tlsVector16(tlsUint16s(0x3a3a, 0x001d, 0x0017, 0x0018))
+
+	return buildClientHello(ciphers, []testExtension{
+		{0x1a1a, nil},
+		{TLSExtServerName, sni},
+		{0x0017, nil},
+		{0xff01, []byte{0}},
+		{TLSExtSupportedGroups, groups},
+		{TLSExtPointFormats, []byte{1, 0}},
+		{0x0023, nil},
+		{TLSExtALPN, alpn},
+		{0x0005, []byte{1, 0, 0, 0, 0}},
+		{TLSExtSignatureAlgorithms, sigAlgs},
+		{0x0012, nil},
+		{0x0033, tlsVector16(nil)},
+		{0x002d, []byte{1, 1}},
+		{TLSExtSupportedVersions, versions},
+		{0x001b, []byte{2, 0, 2}},
+		{0x4469, []byte{0, 3, 2, 'h', '2'}},
+		{0x5a5a, []byte{0}},
+		{0x0015, make([]byte, 16)},
+	})
+}
+
+func TestTLSIsGREASE(t *testing.T) {
+	for _, v := range []uint16{0x0a0a, 0x1a1a, 0xfafa} {
+		if !TLSIsGREASE(v) {
+			t.Fatalf("%04x is GREASE", v)
+		}
+	}
+	for _, v := range []uint16{0x0a1a, 0x1301, 0x0000} {
+		if TLSIsGREASE(v) {
+			t.Fatalf("%04x is not GREASE", v)
+		}
+	}
+}
+
+func TestParseTLSClientHello(t *testing.T) {
+	hello, err := ParseTLSClientHello(chromeClientHello())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var units = []struct {
+		got interface{}
+		exp interface{}
+	}{
+		{hello.Version, uint16(0x0303)},
+		{hello.MaxVersion(), uint16(0x0304)},
+		{hello.ServerName, "www.example.com"},
+		{hello.ALPN, []string{"h2", "http/1.1"}},
+		{len(hello.CipherSuites), 16},
+		{len(hello.Extensions), 18},
+		{hello.SupportedGroups, []uint16{0x3a3a, 0x001d, 0x0017, 0x0018}},
+		{hello.PointFormats, []uint8{0}},
+	}
+	for _, u := range units {
+		if !reflect.DeepEqual(u.exp, u.got) {
+			t.Fatalf("expected '%v', got '%v'", u.exp, u.got)
+		}
+	}
+}
+
+func TestParseTLSClientHelloErrors(t *testing.T) {
+	data := chromeClientHello()
+	if _, err := ParseTLSClientHello(data[:100]); err != ErrTLSShort {
+		t.Fatalf("expected %v, got %v", ErrTLSShort, err)
+	} else if _, err = ParseTLSClientHello([]byte{2, 0, 0, 0}); err != ErrTLSClientHello {
+		t.Fatalf("expected %v, got %v", ErrTLSClientHello, err)
+	}
+
+	// a hello fragmented over two records
+	first := append([]byte{TLSHandshakeRecord, 0x03, 0x01, 0x00, 100}, data[:100]...)
+	rest := len(data) - 100
+	second := append([]byte{TLSHandshakeRecord, 0x03, 0x01, byte(rest >> 8), byte(rest)}, data[100:]...)
+	stream := append(first, second...)
+	if msg, err := TLSHandshakeMessage(stream); err != nil || !reflect.DeepEqual(msg, data) {
+		t.Fatalf("unexpected handshake message: %v", err)
+	} else if _, err = TLSHandshakeMessage(stream[:len(stream)-1]); err != ErrTLSShort {
+		t.Fatalf("expected %v, got %v", ErrTLSShort, err)
+	} else if _, err = TLSHandshakeMessage([]byte("GET / HTTP/1.1\r\n")); err != ErrTLSRecord {
+		t.Fatalf("expected %v, got %v", ErrTLSRecord, err)
+	}
+}
+
+func TestTLSClientHelloJA4(t *testing.T) {
+	hello, err := ParseTLSClientHello(chromeClientHello())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if exp, got := "t13d1516h2_8daaf6152771_e5627efa2ab1", hello.JA4('t'); got != exp {
+		t.Fatalf("expected %s, got %s", exp, got)
+	} else if exp, got = "q13d1516h2_8daaf6152771_e5627efa2ab1", hello.JA4('q'); got != exp {
+		t.Fatalf("expected %s, got %s", exp, got)
+	}
+
+	bare, err := ParseTLSClientHello(buildClientHello([]uint16{0x002f}, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp, got := "t12i010000_"+ja4Hash("002f")+"_000000000000", bare.JA4('t'); got != exp {
+		t.Fatalf("expected %s, got %s", exp, got)
+	}
+}
+
+func TestTLSClientHelloJA3(t *testing.T) {
+	hello, err := ParseTLSClientHello(chromeClientHello())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exp := "771,4865-4866-4867-49195-49199-49196-49200-52393-52392-49171-49172-156-157-47-53," +
+		"0-23-65281-10-11-35-16-5-13-18-51-45-43-27-17513-21,29-23-24,0"
+	if got := hello.JA3String(); got != exp {
+		t.Fatalf("expected %s, got %s", exp, got)
+	}
+	if sum := md5.Sum([]byte(exp)); hello.JA3() != hex.EncodeToString(sum[:]) {
+		t.Fatalf("unexpected JA3 %s", hello.JA3())
+	}
+}
+
+func buildServerHello(cipher uint16, exts []testExtension) []byte {
+	body := []byte{0x03, 0x03}
+	body = append(body, make([]byte, 32)...)
+	body = append(body, 0)
+	body = binary.BigEndian.AppendUint16(body, cipher)
+	body = append(body, 0)
+
+	extensions := make([]byte, 0)
+	for _, ext := range exts {
+		extensions = binary.BigEndian.AppendUint16(extensions, ext.id)
+		extensions = append(extensions, tlsVector16(ext.data)...)
+	}
+	body = append(body, tlsVector16(extensions)...)
+
+	return append([]byte{TLSHandshakeServerHello, 0, byte(len(body) >> 8), byte(len(body))}, body...)
+}
+
+func TestParseTLSServerHello(t *testing.T) {
+	data := buildServerHello(0x1301, []testExtension{
+		{TLSExtSupportedVersions, []byte{0x03, 0x04}},
+		{0x0033, make([]byte, 36)},
+		{TLSExtALPN, tlsVector16([]byte("\x02h2"))},
+	})
+
+	hello, err := ParseTLSServerHello(data)
+	if err != nil {
+		t.Fatal(err)
+	} else if hello.CipherSuite != 0x1301 || hello.SupportedVersion != 0x0304 || hello.ALPN != "h2" {
+		t.Fatalf("unexpected server hello %+v", hello)
+	}
+
+	exp := "771,4865,43-51-16"
+	if got := hello.JA3SString(); got != exp {
+		t.Fatalf("expected %s, got %s", exp, got)
+	} else if sum := md5.Sum([]byte(exp)); hello.JA3S() != hex.EncodeToString(sum[:]) {
+		t.Fatalf("unexpected JA3S %s", hello.JA3S())
+	}
+
+	if _, err = ParseTLSServerHello(chromeClientHello()); err != ErrTLSServerHello {
+		t.Fatalf("expected %v, got %v", ErrTLSServerHello, err)
+	}
+}
+
+func TestTLSHellos(t *testing.T) {
+	data := chromeClientHello()
+	record := append([]byte{TLSHandshakeRecord, 0x03, 0x01, byte(len(data) >> 8), byte(len(data))}, data...)
+
+	hellos := NewTLSHellos()
+	if msg := hellos.Add("10.0.0.2:40000", "10.0.0.1:443", record[:50]); msg != nil {
+		t.Fatal("unexpected message from a partial record")
+	} else if msg = hellos.Add("10.0.0.1:443", "10.0.0.2:40000", []byte("unrelated")); msg != nil {
+		t.Fatal("unexpected message from the other direction")
+	} else if msg = hellos.Add("10.0.0.2:40000", "10.0.0.1:443", record[50:]); !reflect.DeepEqual(msg, data) {
+		t.Fatal("expected the reassembled client hello")
+	} else if len(hellos.flows.states) != 0 {
+		t.Fatal("the stream should have been released")
+	}
+}
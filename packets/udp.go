@@ -1,8 +1,11 @@
 package packets
 
 import (
-	"github.com/gopacket/gopacket/layers"
+	"encoding/binary"
 	"net"
+
+	"github.com/gopacket/gopacket"
+	"github.com/gopacket/gopacket/layers"
 )
 
 func NewUDPProbe(from net.IP, from_hw net.HardwareAddr, to net.IP, port int) (error, []byte) {
@@ -44,3 +47,170 @@ func NewUDPProbe(from net.IP, from_hw net.HardwareAddr, to net.IP, port int) (er
 		return Serialize(&eth, &ip4, &udp)
 	}
 }
+
+// UDP scan port states.
+const (
+	UDPPortOpen         = "open"
+	UDPPortClosed       = "closed"
+	UDPPortFiltered     = "filtered"
+	UDPPortOpenFiltered = "open|filtered"
+)
+
+// UDPScanPayloads are sent to the well known UDP services instead of an
+// empty datagram, since most of them do not reply to unexpected data.
+var UDPScanPayloads = map[int][]byte{
+	// CHAOS TXT query for version.bind
+	53: []byte("\x12\x34\x01\x00\x00\x01\x00\x00\x00\x00\x00\x00\x07version\x04bind\x00\x00\x10\x00\x03"),
+	// NTPv3 client request
+	123: append([]byte{0xe3, 0x00, 0x04, 0xfa, 0x00, 0x01, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00}, make([]byte, 36)...),
+	// NetBIOS NBSTAT query
+	NBNSPort: NBNSRequest,
+	// SNMPv1 get-request of sysDescr.0 with the public community
+	161: {
+		0x30, 0x26, 0x02, 0x01, 0x00, 0x04, 0x06, 'p', 'u', 'b', 'l', 'i', 'c',
+		0xa0, 0x19, 0x02, 0x01, 0x01, 0x02, 0x01, 0x00, 0x02, 0x01, 0x00,
+		0x30, 0x0e, 0x30, 0x0c, 0x06, 0x08, 0x2b, 0x06, 0x01, 0x02, 0x01, 0x01, 0x01, 0x00, 0x05, 0x00,
+	},
+	UPNPPort: UPNPDiscoveryPayload,
+}
+
+// NewUDPScanProbe returns a datagram for the port, with its service specific
+// payload if any.
+func NewUDPScanProbe(from net.IP, from_hw net.HardwareAddr, to net.IP, to_hw net.HardwareAddr, srcPort int, dstPort int) (error, []byte) {
+	eth := layers.Ethernet{
+		SrcMAC:       from_hw,
+		DstMAC:       to_hw,
+		EthernetType: layers.EthernetTypeIPv4,
+	}
+
+	udp := layers.UDP{
+		SrcPort: layers.UDPPort(srcPort),
+		DstPort: layers.UDPPort(dstPort),
+	}
+	payload := gopacket.Payload(UDPScanPayloads[dstPort])
+
+	if to.To4() == nil {
+		eth.EthernetType = layers.EthernetTypeIPv6
+		ip6 := layers.IPv6{
+			NextHeader: layers.IPProtocolUDP,
+			Version:    6,
+			SrcIP:      from,
+			DstIP:      to,
+			HopLimit:   64,
+		}
+
+		udp.SetNetworkLayerForChecksum(&ip6)
+
+		return Serialize(&eth, &ip6, &udp, payload)
+	} else {
+		ip4 := layers.IPv4{
+			Protocol: layers.IPProtocolUDP,
+			Version:  4,
+			TTL:      64,
+			SrcIP:    from,
+			DstIP:    to,
+		}
+
+		udp.SetNetworkLayerForChecksum(&ip4)
+
+		return Serialize(&eth, &ip4, &udp, payload)
+	}
+}
+
+// UDPScanReply is what a packet received during a UDP scan says about a
+// port of the scanned host.
+type UDPScanReply struct {
+	Address net.IP
+	Port    int
+	State   string
+}
+
+// original datagram quoted by an ICMP error, its UDP header must be there
+func quotedUDP(quoted []byte, isIPv6 bool) (net.IP, *layers.UDP) {
+	var dst net.IP
+	var udp []byte
+
+	if isIPv6 {
+		if len(quoted) < 48 || quoted[6] != byte(layers.IPProtocolUDP) {
+			return nil, nil
+		}
+		dst, udp = net.IP(quoted[24:40]), quoted[40:48]
+	} else {
+		if len(quoted) < 20 {
+			return nil, nil
+		}
+		ihl := int(quoted[0]&0x0f) * 4
+		if ihl < 20 || len(quoted) < ihl+8 || quoted[9] != byte(layers.IPProtocolUDP) {
+			return nil, nil
+		}
+		dst, udp = net.IP(quoted[16:20]), quoted[ihl:ihl+8]
+	}
+
+	return dst, &layers.UDP{
+		SrcPort: layers.UDPPort(binary.BigEndian.Uint16(udp[0:2])),
+		DstPort: layers.UDPPort(binary.BigEndian.Uint16(udp[2:4])),
+	}
+}
+
+// ParseUDPScanReply interprets the packet as the reply to a datagram sent
+// from srcPort: a datagram back means the port is open, an ICMP port
+// unreachable that it is closed and the other ICMP unreachable errors that
+// it is filtered.
+func ParseUDPScanReply(pkt gopacket.Packet, srcPort int) *UDPScanReply {
+	if ludp := pkt.Layer(layers.LayerTypeUDP); ludp != nil {
+		udp := ludp.(*layers.UDP)
+		if int(udp.DstPort) != srcPort {
+			return nil
+		}
+		var from net.IP
+		if lip4 := pkt.Layer(layers.LayerTypeIPv4); lip4 != nil {
+			from = lip4.(*layers.IPv4).SrcIP
+		} else if lip6 := pkt.Layer(layers.LayerTypeIPv6); lip6 != nil {
+			from = lip6.(*layers.IPv6).SrcIP
+		} else {
+			return nil
+		}
+		return &UDPScanReply{Address: from, Port: int(udp.SrcPort), State: UDPPortOpen}
+	}
+
+	var dst net.IP
+	var udp *layers.UDP
+	state := UDPPortFiltered
+
+	if licmp := pkt.Layer(layers.LayerTypeICMPv4); licmp != nil {
+		icmp := licmp.(*layers.ICMPv4)
+		if icmp.TypeCode.Type() != layers.ICMPv4TypeDestinationUnreachable {
+			return nil
+		}
+		switch icmp.TypeCode.Code() {
+		case layers.ICMPv4CodePort:
+			state = UDPPortClosed
+		case layers.ICMPv4CodeHost, layers.ICMPv4CodeProtocol, layers.ICMPv4CodeNet,
+			layers.ICMPv4CodeNetAdminProhibited, layers.ICMPv4CodeHostAdminProhibited,
+			layers.ICMPv4CodeCommAdminProhibited:
+		default:
+			return nil
+		}
+		dst, udp = quotedUDP(icmp.Payload, false)
+	} else if licmp := pkt.Layer(layers.LayerTypeICMPv6); licmp != nil {
+		icmp := licmp.(*layers.ICMPv6)
+		if icmp.TypeCode.Type() != layers.ICMPv6TypeDestinationUnreachable || len(icmp.Payload) < 4 {
+			return nil
+		}
+		switch icmp.TypeCode.Code() {
+		case layers.ICMPv6CodePortUnreachable:
+			state = UDPPortClosed
+		case layers.ICMPv6CodeAdminProhibited, layers.ICMPv6CodeAddressUnreachable,
+			layers.ICMPv6CodeNoRouteToDst:
+		default:
+			return nil
+		}
+		// skip the unused field
+		dst, udp = quotedUDP(icmp.Payload[4:], true)
+	}
+
+	if udp == nil || int(udp.SrcPort) != srcPort {
+		return nil
+	}
+	return &UDPScanReply{Address: dst, Port: int(udp.DstPort), State: state}
+}
@@ -0,0 +1,140 @@
+package packets
+
+import (
+	"net"
+	"testing"
+
+	"github.com/gopacket/gopacket"
+	"github.com/gopacket/gopacket/layers"
+)
+
+var (
+	testScanner     = net.ParseIP("192.168.1.2").To4()
+	testScanned     = net.ParseIP("192.168.1.10").To4()
+	testScanner6    = net.ParseIP("fe80::2")
+	testScanned6    = net.ParseIP("fe80::10")
+	testScannerHW   = net.HardwareAddr{0x02, 0, 0, 0, 0, 2}
+	testScannedHW   = net.HardwareAddr{0x02, 0, 0, 0, 0, 10}
+	testScanSrcPort = 666
+)
+
+func TestNewUDPScanProbe(t *testing.T) {
+	for _, to := range []net.IP{testScanned, testScanned6} {
+		from := testScanner
+		if to.To4() == nil {
+			from = testScanner6
+		}
+
+		err, raw := NewUDPScanProbe(from, testScannerHW, to, testScannedHW, testScanSrcPort, 161)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		pkt := gopacket.NewPacket(raw, layers.LayerTypeEthernet, gopacket.Default)
+		udp := pkt.Layer(layers.LayerTypeUDP).(*layers.UDP)
+		if int(udp.SrcPort) != testScanSrcPort || udp.DstPort != 161 {
+			t.Fatalf("unexpected ports %d > %d", udp.SrcPort, udp.DstPort)
+		} else if string(udp.Payload) != string(UDPScanPayloads[161]) {
+			t.Fatalf("unexpected payload %x", udp.Payload)
+		} else if eth := pkt.Layer(layers.LayerTypeEthernet).(*layers.Ethernet); eth.DstMAC.String() != testScannedHW.String() {
+			t.Fatalf("unexpected destination %s", eth.DstMAC)
+		}
+	}
+
+	err, raw := NewUDPScanProbe(testScanner, testScannerHW, testScanned, testScannedHW, testScanSrcPort, 31337)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkt := gopacket.NewPacket(raw, layers.LayerTypeEthernet, gopacket.Default)
+	if udp := pkt.Layer(layers.LayerTypeUDP).(*layers.UDP); len(udp.Payload) != 0 {
+		t.Fatalf("unexpected payload %x", udp.Payload)
+	}
+}
+
+func TestUDPScanPayloads(t *testing.T) {
+	// the SNMP request must be a well formed BER sequence
+	snmp := UDPScanPayloads[161]
+	if snmp[0] != 0x30 || int(snmp[1]) != len(snmp)-2 {
+		t.Fatalf("invalid SNMP request %x", snmp)
+	}
+	if ntp := UDPScanPayloads[123]; len(ntp) != 48 {
+		t.Fatalf("invalid NTP request size %d", len(ntp))
+	}
+}
+
+func TestParseUDPScanReplyOpen(t *testing.T) {
+	err, raw := NewUDPScanProbe(testScanned, testScannedHW, testScanner, testScannerHW, 53, testScanSrcPort)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reply := ParseUDPScanReply(gopacket.NewPacket(raw, layers.LayerTypeEthernet, gopacket.Default), testScanSrcPort)
+	if reply == nil || !reply.Address.Equal(testScanned) || reply.Port != 53 || reply.State != UDPPortOpen {
+		t.Fatalf("unexpected reply %+v", reply)
+	}
+
+	if reply = ParseUDPScanReply(gopacket.NewPacket(raw, layers.LayerTypeEthernet, gopacket.Default), 1234); reply != nil {
+		t.Fatalf("unexpected reply %+v", reply)
+	}
+}
+
+// buildUnreachable returns the ICMP error sent by the scanned host for the
+// probe to the port.
+func buildUnreachable(t *testing.T, to net.IP, port int, code uint8) gopacket.Packet {
+	from := testScanner
+	if to.To4() == nil {
+		from = testScanner6
+	}
+
+	err, probe := NewUDPScanProbe(from, testScannerHW, to, testScannedHW, testScanSrcPort, port)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// quote the original datagram without its ethernet header
+	quoted := probe[14:]
+
+	eth := layers.Ethernet{SrcMAC: testScannedHW, DstMAC: testScannerHW}
+	var l []gopacket.SerializableLayer
+	if to.To4() != nil {
+		eth.EthernetType = layers.EthernetTypeIPv4
+		ip := layers.IPv4{Version: 4, TTL: 64, Protocol: layers.IPProtocolICMPv4, SrcIP: to, DstIP: from}
+		icmp := layers.ICMPv4{TypeCode: layers.CreateICMPv4TypeCode(layers.ICMPv4TypeDestinationUnreachable, code)}
+		l = []gopacket.SerializableLayer{&eth, &ip, &icmp, gopacket.Payload(quoted)}
+	} else {
+		eth.EthernetType = layers.EthernetTypeIPv6
+		ip := layers.IPv6{Version: 6, HopLimit: 64, NextHeader: layers.IPProtocolICMPv6, SrcIP: to, DstIP: from}
+		icmp := layers.ICMPv6{TypeCode: layers.CreateICMPv6TypeCode(layers.ICMPv6TypeDestinationUnreachable, code)}
+		icmp.SetNetworkLayerForChecksum(&ip)
+		l = []gopacket.SerializableLayer{&eth, &ip, &icmp, gopacket.Payload(append([]byte{0, 0, 0, 0}, quoted...))}
+	}
+
+	raw := gopacket.NewSerializeBuffer()
+	if err := gopacket.SerializeLayers(raw, gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}, l...); err != nil {
+		t.Fatal(err)
+	}
+	return gopacket.NewPacket(raw.Bytes(), layers.LayerTypeEthernet, gopacket.Default)
+}
+
+func TestParseUDPScanReplyUnreachable(t *testing.T) {
+	var units = []struct {
+		to    net.IP
+		code  uint8
+		state string
+	}{
+		{testScanned, layers.ICMPv4CodePort, UDPPortClosed},
+		{testScanned, layers.ICMPv4CodeCommAdminProhibited, UDPPortFiltered},
+		{testScanned6, layers.ICMPv6CodePortUnreachable, UDPPortClosed},
+		{testScanned6, layers.ICMPv6CodeAdminProhibited, UDPPortFiltered},
+	}
+	for _, u := range units {
+		reply := ParseUDPScanReply(buildUnreachable(t, u.to, 161, u.code), testScanSrcPort)
+		if reply == nil || !reply.Address.Equal(u.to) || reply.Port != 161 || reply.State != u.state {
+			t.Fatalf("unexpected reply %+v for %s code %d", reply, u.to, u.code)
+		}
+	}
+
+	// fragmentation needed is not about the port
+	if reply := ParseUDPScanReply(buildUnreachable(t, testScanned, 161, layers.ICMPv4CodeFragmentationNeeded), testScanSrcPort); reply != nil {
+		t.Fatalf("unexpected reply %+v", reply)
+	}
+}
@@ -0,0 +1,71 @@
+package packets
+
+import (
+	"bytes"
+	"fmt"
+)
+
+var VNCPorts = []int{5900, 5901, 5902, 5903, 5904, 5905}
+
+const (
+	vncChallengeSize = 16
+	vncAuthType      = 2
+)
+
+var vncBanner = []byte("RFB ")
+
+type vncFlow struct {
+	version   string
+	challenge []byte
+}
+
+// VNCAuthState tracks RFB connections to extract the DES challenge and
+// response of the VNC authentication.
+type VNCAuthState struct {
+	flows authFlows
+}
+
+func NewVNCAuthState() *VNCAuthState {
+	return &VNCAuthState{
+		flows: newAuthFlows(),
+	}
+}
+
+// Parse feeds the state with a payload of the connection and returns the
+// credentials once the client answered the challenge.
+func (s *VNCAuthState) Parse(flow AuthFlow, payload []byte) *AuthCredentials {
+	s.flows.Lock()
+	defer s.flows.Unlock()
+
+	if !flow.FromClient && bytes.HasPrefix(payload, vncBanner) {
+		f := s.flows.get(flow, func() interface{} {
+			return &vncFlow{}
+		}).(*vncFlow)
+		f.version = string(bytes.TrimSpace(payload[len(vncBanner):]))
+		return nil
+	}
+
+	state := s.flows.get(flow, nil)
+	if state == nil {
+		return nil
+	}
+	f := state.(*vncFlow)
+
+	if !flow.FromClient {
+		// RFB 3.3 servers send the security type and the challenge together
+		if len(payload) == vncChallengeSize {
+			f.challenge = append([]byte(nil), payload...)
+		} else if len(payload) == vncChallengeSize+4 && bytes.Equal(payload[:4], []byte{0, 0, 0, vncAuthType}) {
+			f.challenge = append([]byte(nil), payload[4:]...)
+		}
+	} else if len(payload) == vncChallengeSize && f.challenge != nil {
+		s.flows.del(flow)
+		creds := flow.credentials("vnc", "vnc auth")
+		creds.Hash = fmt.Sprintf("$vnc$*%X*%X", f.challenge, payload)
+		creds.HashFormat = "john:vnc"
+		creds.Info = "RFB " + f.version
+		return creds
+	}
+
+	return nil
+}
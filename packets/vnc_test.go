@@ -0,0 +1,45 @@
+package packets
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestVNCAuth(t *testing.T) {
+	challenge := strings.Repeat("\xab", 16)
+	response := strings.Repeat("\x01", 16)
+
+	authExpect(t, false, 5900, []authExchange{
+		fromServer("RFB 003.008\n"),
+		fromClient("RFB 003.008\n"),
+		fromServer("\x01\x02"),
+		fromClient("\x02"),
+		fromServer(challenge),
+		fromClient(response),
+		fromServer("\x00\x00\x00\x00"),
+	}, NewVNCAuthState().Parse, AuthCredentials{
+		Protocol:   "vnc",
+		Method:     "vnc auth",
+		Hash:       "$vnc$*" + strings.Repeat("AB", 16) + "*" + strings.Repeat("01", 16),
+		HashFormat: "john:vnc",
+		Info:       "RFB 003.008",
+	})
+}
+
+func TestVNCAuthRFB33(t *testing.T) {
+	challenge := strings.Repeat("\xab", 16)
+	response := strings.Repeat("\x01", 16)
+
+	authExpect(t, false, 5901, []authExchange{
+		fromServer("RFB 003.003\n"),
+		fromClient("RFB 003.003\n"),
+		fromServer("\x00\x00\x00\x02" + challenge),
+		fromClient(response),
+	}, NewVNCAuthState().Parse, AuthCredentials{
+		Protocol:   "vnc",
+		Method:     "vnc auth",
+		Hash:       "$vnc$*" + strings.Repeat("AB", 16) + "*" + strings.Repeat("01", 16),
+		HashFormat: "john:vnc",
+		Info:       "RFB 003.003",
+	})
+}
@@ -0,0 +1,164 @@
+package session
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// the secret is a clear text password
+	CredentialPlain = "plain"
+	// the secret is the path of a capture file
+	CredentialCapture = "capture"
+	// hashes are stored with the hashcat mode to crack them, like hashcat:5600
+	credentialHashcatPrefix = "hashcat:"
+)
+
+// HashcatFormat returns the format of a hash crackable with the given
+// hashcat mode.
+func HashcatFormat(mode int) string {
+	return fmt.Sprintf("%s%d", credentialHashcatPrefix, mode)
+}
+
+// Credential is a set of credentials captured by a module.
+type Credential struct {
+	Service   string    `json:"service"`
+	Host      string    `json:"host"`
+	Client    string    `json:"client"`
+	Username  string    `json:"username"`
+	Secret    string    `json:"secret"`
+	Format    string    `json:"format"`
+	Source    string    `json:"source"`
+	Info      string    `json:"info"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+	Hits      int       `json:"hits"`
+}
+
+// HashcatMode returns the hashcat mode if the secret is a hash.
+func (c Credential) HashcatMode() (int, bool) {
+	if !strings.HasPrefix(c.Format, credentialHashcatPrefix) {
+		return 0, false
+	}
+	mode, err := strconv.Atoi(c.Format[len(credentialHashcatPrefix):])
+	return mode, err == nil
+}
+
+func (c Credential) key() string {
+	return strings.Join([]string{c.Service, c.Host, c.Username, c.Secret}, "\x00")
+}
+
+type CredentialNewCallback func(c *Credential)
+
+// Credentials stores the credentials captured by every module, without
+// duplicates.
+type Credentials struct {
+	sync.RWMutex
+	list  []*Credential
+	index map[string]*Credential
+	newCb CredentialNewCallback
+}
+
+var credentialsCSVHeader = []string{
+	"service", "host", "client", "username", "secret", "format", "source", "info", "first_seen", "last_seen", "hits",
+}
+
+func NewCredentials(newcb CredentialNewCallback) *Credentials {
+	return &Credentials{
+		list:  make([]*Credential, 0),
+		index: make(map[string]*Credential),
+		newCb: newcb,
+	}
+}
+
+// Add stores the credential and returns true if it was not captured before,
+// otherwise the existing one is just updated.
+func (c *Credentials) Add(cred Credential) bool {
+	if cred.LastSeen.IsZero() {
+		cred.LastSeen = time.Now()
+	}
+
+	c.Lock()
+	key := cred.key()
+	if found, ok := c.index[key]; ok {
+		found.LastSeen = cred.LastSeen
+		found.Hits++
+		c.Unlock()
+		return false
+	}
+
+	cred.FirstSeen = cred.LastSeen
+	cred.Hits = 1
+	c.list = append(c.list, &cred)
+	c.index[key] = &cred
+	c.Unlock()
+
+	if c.newCb != nil {
+		c.newCb(&cred)
+	}
+	return true
+}
+
+// List returns a copy of the stored credentials in the order they have been
+// captured.
+func (c *Credentials) List() []Credential {
+	c.RLock()
+	defer c.RUnlock()
+
+	list := make([]Credential, len(c.list))
+	for i, cred := range c.list {
+		list[i] = *cred
+	}
+	return list
+}
+
+func (c *Credentials) Len() int {
+	c.RLock()
+	defer c.RUnlock()
+	return len(c.list)
+}
+
+func (c *Credentials) Clear() {
+	c.Lock()
+	defer c.Unlock()
+	c.list = make([]*Credential, 0)
+	c.index = make(map[string]*Credential)
+}
+
+func (c *Credentials) MarshalJSON() ([]byte, error) {
+	return json.Marshal(c.List())
+}
+
+func (c *Credentials) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(c.List())
+}
+
+func (c *Credentials) WriteCSV(w io.Writer) error {
+	out := csv.NewWriter(w)
+	out.Write(credentialsCSVHeader)
+	for _, cred := range c.List() {
+		out.Write([]string{
+			cred.Service,
+			cred.Host,
+			cred.Client,
+			cred.Username,
+			cred.Secret,
+			cred.Format,
+			cred.Source,
+			cred.Info,
+			cred.FirstSeen.Format(time.RFC3339),
+			cred.LastSeen.Format(time.RFC3339),
+			strconv.Itoa(cred.Hits),
+		})
+	}
+	out.Flush()
+	return out.Error()
+}
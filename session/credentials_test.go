@@ -0,0 +1,82 @@
+package session
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"testing"
+)
+
+func TestCredentialsAdd(t *testing.T) {
+	news := 0
+	creds := NewCredentials(func(c *Credential) {
+		news++
+	})
+
+	ftp := Credential{Service: "ftp", Host: "10.0.0.1", Username: "admin", Secret: "s3cr3t", Format: CredentialPlain}
+	if !creds.Add(ftp) {
+		t.Fatal("expected new credential")
+	} else if creds.Add(ftp) {
+		t.Fatal("expected duplicated credential")
+	}
+
+	ftp.Host = "10.0.0.2"
+	if !creds.Add(ftp) {
+		t.Fatal("expected new credential for a different host")
+	}
+
+	list := creds.List()
+	if creds.Len() != 2 || len(list) != 2 {
+		t.Fatalf("expected 2 credentials, got %d", creds.Len())
+	} else if news != 2 {
+		t.Fatalf("expected 2 callbacks, got %d", news)
+	} else if list[0].Hits != 2 || list[1].Hits != 1 {
+		t.Fatalf("unexpected hits %d %d", list[0].Hits, list[1].Hits)
+	} else if list[0].FirstSeen.IsZero() || list[0].LastSeen.Before(list[0].FirstSeen) {
+		t.Fatal("unexpected timestamps")
+	}
+
+	creds.Clear()
+	if creds.Len() != 0 {
+		t.Fatalf("expected no credentials, got %d", creds.Len())
+	}
+}
+
+func TestCredentialHashcatMode(t *testing.T) {
+	c := Credential{Format: HashcatFormat(5600)}
+	if mode, ok := c.HashcatMode(); !ok || mode != 5600 {
+		t.Fatalf("expected mode 5600, got %d", mode)
+	}
+
+	c.Format = CredentialPlain
+	if _, ok := c.HashcatMode(); ok {
+		t.Fatal("plain credentials have no hashcat mode")
+	}
+}
+
+func TestCredentialsExport(t *testing.T) {
+	creds := NewCredentials(nil)
+	creds.Add(Credential{Service: "http", Host: "example.com", Username: "user", Secret: "pass, with comma", Format: CredentialPlain})
+
+	buf := bytes.Buffer{}
+	if err := creds.WriteJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var list []Credential
+	if err := json.Unmarshal(buf.Bytes(), &list); err != nil {
+		t.Fatal(err)
+	} else if len(list) != 1 || list[0].Secret != "pass, with comma" {
+		t.Fatalf("unexpected json export %s", buf.String())
+	}
+
+	buf.Reset()
+	if err := creds.WriteCSV(&buf); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	} else if len(rows) != 2 || len(rows[1]) != len(credentialsCSVHeader) || rows[1][4] != "pass, with comma" {
+		t.Fatalf("unexpected csv export %v", rows)
+	}
+}
@@ -32,6 +32,17 @@ func jsRunFunc(call otto.FunctionCall) otto.Value {
 	return js.NullValue
 }
 
+// CallScript invokes a callback registered by the session script, locking
+// the vm if ready and available.
+func (s *Session) CallScript(cb otto.Value, args ...interface{}) error {
+	if s.script != nil {
+		s.script.Lock()
+		defer s.script.Unlock()
+	}
+	_, err := cb.Call(otto.NullValue(), args...)
+	return err
+}
+
 func jsOnEventFunc(call otto.FunctionCall) otto.Value {
 	argv := call.ArgumentList
 	argc := len(argv)
@@ -73,21 +84,9 @@ func jsOnEventFunc(call otto.FunctionCall) otto.Value {
 					I.Events.Log(log.ERROR, "error serializing event %s: %v", event.Tag, err)
 				}
 
-				// lock vm if ready and available
-				locked := false
-				if I.script != nil {
-					I.script.Lock()
-					locked = true
-				}
-
-				if _, err := cb.Call(otto.NullValue(), opaque); err != nil {
+				if err := I.CallScript(cb, opaque); err != nil {
 					I.Events.Log(log.ERROR, "error dispatching event %s: %v", event.Tag, err)
 				}
-
-				// unlock vm if ready and available
-				if locked {
-					I.script.Unlock()
-				}
 			}
 		}
 	}(filterExpr, cb)
@@ -159,3 +158,30 @@ func jsFileExistsFunc(call otto.FunctionCall) otto.Value {
 
 	return otto.FalseValue()
 }
+
+// addCredential(service, host, username, secret[, format]) lets scripts store
+// credentials they captured, like the ones posted to a proxied login form.
+func jsAddCredentialFunc(call otto.FunctionCall) otto.Value {
+	argv := call.ArgumentList
+	argc := len(argv)
+	if argc != 4 && argc != 5 {
+		return js.ReportError("addCredential accepts four or five string arguments (service, host, username, secret, format)")
+	}
+
+	cred := Credential{
+		Service:  argv[0].String(),
+		Host:     argv[1].String(),
+		Username: argv[2].String(),
+		Secret:   argv[3].String(),
+		Format:   CredentialPlain,
+		Source:   "script",
+	}
+	if argc == 5 {
+		cred.Format = argv[4].String()
+	}
+
+	if I.Credentials.Add(cred) {
+		return otto.TrueValue()
+	}
+	return otto.FalseValue()
+}
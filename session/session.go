@@ -65,6 +65,9 @@ type GPS struct {
 
 const AliasesFile = "~/bettercap.aliases"
 
+// GPS positions older than this are not used to geotag wifi sightings.
+const GPSFixMaxAge = 10 * time.Second
+
 var aliasesFileName, _ = fs.Expand(AliasesFile)
 
 type Session struct {
@@ -83,6 +86,8 @@ type Session struct {
 	Modules   ModuleList
 	Aliases   *data.UnsortedKV
 
+	Credentials *Credentials
+
 	Input            *readline.Instance
 	Prompt           Prompt
 	CoreHandlers     []CommandHandler
@@ -140,6 +145,9 @@ func New() (*Session, error) {
 	}
 
 	s.Events = NewEventPool(*s.Options.Debug, *s.Options.Silent)
+	s.Credentials = NewCredentials(func(c *Credential) {
+		s.Events.Add("creds.new", *c)
+	})
 
 	s.registerCoreHandlers()
 
@@ -287,6 +295,8 @@ func (s *Session) Start() error {
 		s.Events.Add("wifi.ap.lost", ap)
 	})
 
+	s.WiFi.SetLocator(s.gpsFix)
+
 	s.Lan = network.NewLAN(s.Interface, s.Gateway, s.Aliases, func(e *network.Endpoint) {
 		s.Events.Add("endpoint.new", e)
 	}, func(e *network.Endpoint) {
@@ -316,6 +326,7 @@ func (s *Session) Start() error {
 	plugin.Defines["loadJSON"] = jsLoadJSONFunc
 	plugin.Defines["saveJSON"] = jsSaveJSONFunc
 	plugin.Defines["onEvent"] = jsOnEventFunc
+	plugin.Defines["addCredential"] = jsAddCredentialFunc
 	plugin.Defines["session"] = s
 
 	// load the script here so the session and its internal objects are ready
@@ -329,6 +340,23 @@ func (s *Session) Start() error {
 	return nil
 }
 
+// gpsFix returns the current GPS position if it's recent enough to be
+// used to geotag what we see around.
+func (s *Session) gpsFix() (network.GeoFix, bool) {
+	gps := s.GPS
+	if gps.Updated.IsZero() || time.Since(gps.Updated) > GPSFixMaxAge {
+		return network.GeoFix{}, false
+	} else if gps.Latitude == 0 && gps.Longitude == 0 {
+		return network.GeoFix{}, false
+	}
+	return network.GeoFix{
+		Latitude:  gps.Latitude,
+		Longitude: gps.Longitude,
+		Altitude:  gps.Altitude,
+		Time:      gps.Updated,
+	}, true
+}
+
 func (s *Session) Skip(ip net.IP) bool {
 	if ip.IsLoopback() {
 		return true
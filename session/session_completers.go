@@ -81,15 +81,22 @@ func (s *Session) EventsCompleter(prefix string) []string {
 		"ble.device.connected",
 		"ble.device.new",
 		"ble.device.lost",
+		"ble.device.rotated",
 		"ble.connection.timeout",
+		"ble.device.characteristic.read",
+		"ble.device.characteristic.notification",
+		"ble.fuzz.error",
+		"ble.fuzz.crash",
 		"dhcp4.message",
 		"hid.device.new",
 		"hid.device.lost",
+		"hid.keystrokes",
 		"http.spoofed-request",
 		"http.spoofed-response",
 		"https.spoofed-request",
 		"https.spoofed-response",
 		"syn.scan",
+		"creds.new",
 		"net.sniff.mdns",
 		"net.sniff.mdns",
 		"net.sniff.dot11",
@@ -104,6 +111,19 @@ func (s *Session) EventsCompleter(prefix string) []string {
 		"net.sniff.http.request",
 		"net.sniff.http.response",
 		"net.sniff.sni",
+		"net.sniff.quic",
+		"net.sniff.smtp",
+		"net.sniff.pop3",
+		"net.sniff.imap",
+		"net.sniff.telnet",
+		"net.sniff.snmp",
+		"net.sniff.ldap",
+		"net.sniff.redis",
+		"net.sniff.postgres",
+		"net.sniff.mysql",
+		"net.sniff.sip",
+		"net.sniff.radius",
+		"net.sniff.vnc",
 	}
 
 	for _, e := range all {
@@ -66,6 +66,8 @@ type SessionJSON struct {
 	GPS        GPS               `json:"gps"`
 	Modules    ModuleList        `json:"modules"`
 	Caplets    []*caplets.Caplet `json:"caplets"`
+
+	Credentials *Credentials `json:"credentials"`
 }
 
 func (s *Session) MarshalJSON() ([]byte, error) {
@@ -102,6 +104,8 @@ func (s *Session) MarshalJSON() ([]byte, error) {
 		GPS:        s.GPS,
 		Modules:    s.Modules,
 		Caplets:    caplets.List(),
+
+		Credentials: s.Credentials,
 	}
 
 	ifaces, err := net.Interfaces()
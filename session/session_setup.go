@@ -123,6 +123,10 @@ func (s *Session) startNetMon() {
 					if existing != nil && event.Meta != nil {
 						existing.OnMeta(event.Meta)
 					}
+
+					if existing != nil && event.Evidence != nil {
+						existing.OnFingerprint(*event.Evidence)
+					}
 				}
 			}
 		}